@@ -8,6 +8,7 @@ import (
 	"syscall"
 	"time"
 
+	"mockserver/internal/events"
 	httpServer "mockserver/internal/http"
 	wsHandlers "mockserver/internal/websocket"
 )
@@ -21,7 +22,7 @@ func main() {
 	server := httpServer.NewServer(HTTPPort)
 	
 	// Add WebSocket handlers
-	wsHandler := wsHandlers.NewWebSocketHandlers()
+	wsHandler := wsHandlers.NewWebSocketHandlers(wsHandlers.Config{}, events.NewBus())
 	server.SetupRoutes()
 	
 	// Add WebSocket routes
@@ -2,55 +2,596 @@ package main
 
 import (
 	"context"
-	"log"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/reflect/protoreflect"
 
+	"mockserver/internal/admin"
+	"mockserver/internal/config"
+	connectHandlers "mockserver/internal/connect"
+	"mockserver/internal/dashboard"
+	"mockserver/internal/demo"
+	"mockserver/internal/diagnostics"
+	"mockserver/internal/events"
+	"mockserver/internal/faults"
 	grpcServer "mockserver/internal/grpc"
 	httpHandlers "mockserver/internal/http"
+	"mockserver/internal/jobs"
+	"mockserver/internal/jose"
+	"mockserver/internal/journal"
+	"mockserver/internal/kafka"
+	"mockserver/internal/logging"
+	"mockserver/internal/metrics"
+	"mockserver/internal/oidc"
+	"mockserver/internal/panics"
+	"mockserver/internal/probe"
+	"mockserver/internal/proxy"
+	"mockserver/internal/queue"
+	"mockserver/internal/readiness"
+	"mockserver/internal/replay"
+	"mockserver/internal/selftest"
+	"mockserver/internal/sftp"
+	"mockserver/internal/signedurl"
+	"mockserver/internal/smtp"
+	"mockserver/internal/stubs"
+	"mockserver/internal/tcp"
+	"mockserver/internal/telemetry"
+	"mockserver/internal/timefmt"
+	"mockserver/internal/tui"
+	"mockserver/internal/tus"
+	"mockserver/internal/udp"
+	"mockserver/internal/verify"
 	wsHandlers "mockserver/internal/websocket"
+	"mockserver/internal/xds"
 	pb "mockserver/proto"
 )
 
+var logger = logging.For("server")
+
 func main() {
-	log.Println("Starting Multi-Protocol Mock Server...")
+	// "serve" is the default command, accepted so "mockserver serve --selftest"
+	// works; "tui" watches a running server and "probe" smoke-tests one
+	args := os.Args[1:]
+	if len(args) > 0 && args[0] == "tui" {
+		if err := tui.Main(args[1:]); err != nil {
+			fatal("TUI", "error", err)
+		}
+		return
+	}
+	if len(args) > 0 && args[0] == "probe" {
+		if err := probe.Main(args[1:]); err != nil {
+			fatal("Probe", "error", err)
+		}
+		return
+	}
+	if len(args) > 0 && args[0] == "serve" {
+		args = args[1:]
+	}
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	selfTest := flags.Bool("selftest", false, "run the self-test once the listeners are up; exit 1 if it fails")
+	selfTestReport := flags.String("selftest-report", "", "write the self-test report to this file instead of stdout")
+	var quickStubs stubFlags
+	flags.Var(&quickStubs, "stub", "add an HTTP stub, e.g. 'GET /users/1 -> 200 @users.json' (repeatable)")
+	flags.Parse(args)
+
+	cfg, err := loadConfig(nil)
+	if err != nil {
+		fatal("Failed to load configuration", "error", err)
+	}
+	logging.Setup(cfg.Logging, os.Stderr)
+	logger.Info("Starting Multi-Protocol Mock Server", "logging", logging.Describe(cfg.Logging))
+	// The stubs are normalized as they are compiled: the reload endpoint
+	// compares against the configuration as loaded
+	loadedCfg, err := cfg.Clone()
+	if err != nil {
+		fatal("Failed to load configuration", "error", err)
+	}
 
 	// Create handlers
+	bus := events.NewBus()
 	httpHandler := httpHandlers.NewHTTPHandlers()
-	wsHandler := wsHandlers.NewWebSocketHandlers()
-	grpcHandler := grpcServer.NewMockServer()
+	wsHandler := wsHandlers.NewWebSocketHandlers(cfg.WebSocket, bus)
+	grpcHandler := grpcServer.NewMockServer(bus)
+
+	// Readiness waits for every listener to be bound and the initial stubs
+	// to be loaded
+	readyTracker := readiness.New()
+
+	// listen binds a listener, stopping the startup when it cannot: a
+	// server missing one of its ports must not come up
+	listen := func(name, addr string) net.Listener {
+		lis, err := net.Listen("tcp", addr)
+		if err != nil {
+			fatal("Failed to listen", "listener", name, "addr", addr, "error", err)
+		}
+		readyTracker.Ready("listener:"+name, addr)
+		return lis
+	}
+	listenPacket := func(name, addr string) net.PacketConn {
+		conn, err := net.ListenPacket("udp", addr)
+		if err != nil {
+			fatal("Failed to listen", "listener", name, "addr", addr+"/udp", "error", err)
+		}
+		readyTracker.Ready("listener:"+name, addr+"/udp")
+		return conn
+	}
 
 	// Setup Echo server for HTTP and WebSocket
 	e := echo.New()
-	e.Use(middleware.Logger())
+	// Echo's own banner would break JSON logs; the listeners are logged
+	// as they start
+	e.HideBanner = true
+	e.HidePort = true
+	e.Use(logging.Middleware(journal.MatchedStubID))
 	e.Use(middleware.CORS())
 
+	// HTTP and gRPC request counts and latency for /metrics, measured
+	// outside every other middleware and interceptor so injected faults and
+	// delays are included
+	httpMetrics := metrics.NewHTTPMetrics()
+	e.Use(httpMetrics.Middleware())
+	grpcMetrics := metrics.NewGRPCMetrics()
+
+	// Read-only mode protects the mock's data from admin changes
+	readOnly := admin.NewReadOnly(cfg.ReadOnly)
+	e.Use(readOnly.Middleware())
+	e.GET(admin.ReadOnlyPath, readOnly.Get)
+	e.PUT(admin.ReadOnlyPath, readOnly.Set)
+	if cfg.ReadOnly {
+		logger.Info("Read-only mode: admin changes are rejected")
+	}
+
+	// Every HTTP and gRPC request is recorded in the shared journal, along
+	// with the bus events they cause
+	requestJournal := journal.New(journal.DefaultCapacity)
+	e.Use(requestJournal.Middleware())
+	defer requestJournal.RecordEvents(bus)()
+
+	// A panic fails the request or connection it was raised on, not the
+	// process, and is kept for /__admin/errors; after the journal so the
+	// 500s are recorded
+	panicRecorder := panics.NewRecorder(panics.DefaultCapacity)
+	e.Use(panicRecorder.Middleware())
+	wsHandler.SetPanics(panicRecorder)
+	errorHandler := admin.NewErrorHandlers(panicRecorder)
+	e.GET("/__admin/errors", errorHandler.List)
+	e.DELETE("/__admin/errors", errorHandler.Reset)
+	e.GET("/__admin/errors/:id", errorHandler.Get)
+
+	// Fault profiles fail and delay requests during their scheduled windows
+	// or when activated, after the journal so injected faults are recorded
+	faultCalendar, err := faults.NewCalendar(cfg.Faults)
+	if err != nil {
+		fatal("Invalid fault configuration", "error", err)
+	}
+	e.Use(faultCalendar.Middleware())
+	defer faultCalendar.Watch()()
+
+	// Signed URLs are verified before anything serves the request
+	var signer *signedurl.Signer
+	if cfg.SignedURLs.Enabled {
+		signer, err = signedurl.New(cfg.SignedURLs)
+		if err != nil {
+			fatal("Invalid signed URL configuration", "error", err)
+		}
+		e.Use(signer.Middleware())
+		signHandler := admin.NewSignHandlers(signer)
+		e.GET("/__admin/sign", signHandler.Sign)
+	}
+
+	// HTTP stubs take precedence over the built-in routes
+	keys, err := jose.NewKeySet(cfg.HTTP.Keys)
+	if err != nil {
+		fatal("Invalid JOSE keys", "error", err)
+	}
+	timestamps, err := timefmt.New(cfg.HTTP.Timestamps)
+	if err != nil {
+		fatal("Invalid timestamp settings", "error", err)
+	}
+	httpHandler.SetTimestamps(timestamps)
+	httpHandler.SetQuietEcho(cfg.HTTP.QuietEcho)
+	stubEngine := stubs.NewEngine()
+	stubEngine.SetKeys(keys)
+	stubEngine.SetTimestamps(timestamps)
+	stubEngine.SetJournal(requestJournal)
+	if cfg.HTTP.DevMode {
+		stubEngine.SetDevMode(true)
+		logger.Info("Dev mode: stub body files are reloaded when they change")
+	}
+	// Command-line stubs go first, so they win over configured stubs of the
+	// same priority
+	for _, spec := range quickStubs {
+		stub, err := stubs.ParseShorthand(spec)
+		if err != nil {
+			fatal("Invalid --stub", "error", err)
+		}
+		if _, err := stubEngine.Add(stub); err != nil {
+			fatal("Invalid --stub", "stub", spec, "error", err)
+		}
+	}
+	var configStubIDs []string
+	for _, stub := range cfg.HTTP.Stubs {
+		added, err := stubEngine.Add(stub)
+		if err != nil {
+			fatal("Invalid HTTP stub", "error", err)
+		}
+		configStubIDs = append(configStubIDs, added.ID)
+	}
+	if cfg.Demo.Enabled {
+		if err := demo.Start(cfg.Demo, stubEngine, wsHandler); err != nil {
+			fatal("Failed to start demo mode", "error", err)
+		}
+	}
+	readyTracker.Ready("stubs:http", fmt.Sprintf("%d stubs", len(stubEngine.List())))
+	e.Use(stubEngine.Middleware())
+
 	// HTTP routes
 	e.GET("/health", httpHandler.Health)
+	e.GET(readiness.Path, readyTracker.Handler)
 	e.GET("/echo", httpHandler.EchoGet)
 	e.POST("/echo", httpHandler.EchoPost)
 	e.GET("/delay/:seconds", httpHandler.Delay)
 	e.GET("/status/:code", httpHandler.Status)
+	e.GET("/abort", httpHandler.Abort)
+	e.GET("/i18n", httpHandler.I18n)
+	e.GET("/json/deep", httpHandler.JSONDeep)
+	e.GET("/json/wide", httpHandler.JSONWide)
+	e.GET("/json/nonstandard", httpHandler.JSONNonStandard)
+
+	// Optional record-and-proxy mode for requests without stub or route
+	var httpRecorder *stubs.Recorder
+	if cfg.HTTP.Proxy.Upstream != "" {
+		httpRecorder, err = stubs.NewRecorder(cfg.HTTP.Proxy)
+		if err != nil {
+			fatal("Invalid HTTP configuration", "error", err)
+		}
+		e.RouteNotFound("/*", httpRecorder.Forward)
+
+		httpRecordingHandler := admin.NewHTTPRecordingHandlers(httpRecorder)
+		e.GET("/__admin/recordings", httpRecordingHandler.List)
+		e.DELETE("/__admin/recordings", httpRecordingHandler.Reset)
+		e.GET("/__admin/recordings/stubs", httpRecordingHandler.Stubs)
+	}
+
+	keyHandler := admin.NewKeyHandlers(keys)
+	e.GET("/.well-known/jwks.json", keyHandler.JWKS)
+
+	// Mock identity provider
+	var oidcProvider *oidc.Provider
+	if cfg.OIDC.Enabled {
+		oidcProvider, err = oidc.New(cfg.OIDC, keys)
+		if err != nil {
+			fatal("Invalid OIDC configuration", "error", err)
+		}
+		oidcProvider.Register(e)
+	}
+
+	// Async job API
+	var jobManager *jobs.Manager
+	if cfg.Jobs.Enabled {
+		jobManager, err = jobs.New(cfg.Jobs)
+		if err != nil {
+			fatal("Invalid job API configuration", "error", err)
+		}
+		jobManager.Register(e)
+		jobHandler := admin.NewJobHandlers(jobManager)
+		e.GET("/__admin/jobs", jobHandler.List)
+		e.DELETE("/__admin/jobs", jobHandler.Reset)
+	}
+
+	// Resumable uploads
+	var tusServer *tus.Server
+	if cfg.Tus.Enabled {
+		tusServer, err = tus.New(cfg.Tus)
+		if err != nil {
+			fatal("Invalid tus configuration", "error", err)
+		}
+		tusServer.Register(e)
+		tusHandler := admin.NewTusHandlers(tusServer)
+		e.GET("/__admin/tus/uploads", tusHandler.Uploads)
+		e.DELETE("/__admin/tus/uploads", tusHandler.Reset)
+		e.GET("/__admin/tus/uploads/:id", tusHandler.Upload)
+		e.GET("/__admin/tus/uploads/:id/data", tusHandler.Data)
+	}
+
+	// Message queue
+	var queueBroker *queue.Broker
+	if cfg.Queues.Enabled {
+		queueBroker, err = queue.New(cfg.Queues)
+		if err != nil {
+			fatal("Invalid queue configuration", "error", err)
+		}
+		queueBroker.Register(e)
+		queueHandler := admin.NewQueueHandlers(queueBroker)
+		e.GET("/__admin/queues", queueHandler.List)
+		e.DELETE("/__admin/queues", queueHandler.Reset)
+		e.GET("/__admin/queues/:name", queueHandler.Get)
+	}
 
 	// WebSocket routes
 	e.GET("/ws/echo", wsHandler.Echo)
 	e.GET("/ws/broadcast", wsHandler.Broadcast)
 	e.GET("/ws/chat/:room", wsHandler.Chat)
+	e.GET("/ws/subprotocol", wsHandler.Subprotocol)
+	e.GET("/ws/stream", wsHandler.Stream)
+	e.GET("/ws/scenario/:name", wsHandler.Scenario)
+	e.GET("/socket.io/", wsHandler.SocketIO)
+	e.POST("/publish/broadcast", wsHandler.PublishBroadcast)
+	e.POST("/publish/chat/:room", wsHandler.PublishChat)
+
+	// Setup gRPC stubs
+	descriptors := grpcServer.NewDescriptorRegistry()
+	for _, path := range cfg.GRPC.DescriptorSets {
+		if err := descriptors.LoadFile(path); err != nil {
+			fatal("Failed to load descriptor set", "error", err)
+		}
+	}
+	attemptTracker := grpcServer.NewAttemptTracker()
+	stubHandler := grpcServer.NewStubHandler(descriptors, attemptTracker)
+	var configGRPCStubIDs []string
+	for _, stub := range cfg.GRPC.Stubs {
+		added, err := stubHandler.Add(stub)
+		if err != nil {
+			fatal("Invalid gRPC stub", "error", err)
+		}
+		configGRPCStubIDs = append(configGRPCStubIDs, added.ID)
+	}
+	readyTracker.Ready("stubs:grpc", fmt.Sprintf("%d stubs", len(stubHandler.List())))
+
+	// Health service covers MockService and every dynamically loaded service
+	healthServices := []string{pb.MockService_ServiceDesc.ServiceName}
+	for _, sd := range descriptors.Services() {
+		healthServices = append(healthServices, string(sd.FullName()))
+	}
+	healthController := grpcServer.NewHealthController(healthServices...)
+
+	metadataEcho := grpcServer.NewMetadataEcho(cfg.GRPC.Trailers)
+	sendCompressor, err := grpcServer.NewSendCompressor(cfg.GRPC.Compression)
+	if err != nil {
+		fatal("Invalid gRPC configuration", "error", err)
+	}
+	keepaliveOptions, err := cfg.GRPC.Keepalive.ServerOptions()
+	if err != nil {
+		fatal("Invalid gRPC configuration", "error", err)
+	}
+
+	// Admin routes
+	adminHandler := admin.NewAdminHandlers(bus, healthController, attemptTracker)
+	e.POST("/__admin/events", adminHandler.PublishEvent)
+	e.GET("/__admin/grpc/health", adminHandler.GRPCHealth)
+	e.POST("/__admin/grpc/health", adminHandler.SetGRPCHealth)
+	e.GET("/__admin/grpc/service-config", adminHandler.GRPCServiceConfig)
+	e.GET("/__admin/grpc/attempts", adminHandler.GRPCAttempts)
+	e.DELETE("/__admin/grpc/attempts", adminHandler.ResetGRPCAttempts)
+
+	descriptorHandler := admin.NewDescriptorHandlers(descriptors, healthController)
+	e.GET("/__admin/grpc/descriptors", descriptorHandler.Download)
+	e.POST("/__admin/grpc/descriptors", descriptorHandler.Upload)
+
+	grpcStubHandler := admin.NewGRPCStubHandlers(stubHandler)
+	e.GET("/__admin/grpc/stubs", grpcStubHandler.List)
+	e.POST("/__admin/grpc/stubs/import", grpcStubHandler.Import)
+
+	httpStubHandler := admin.NewStubHandlers(stubEngine)
+	e.GET("/__admin/stubs", httpStubHandler.List)
+	e.POST("/__admin/stubs", httpStubHandler.Create)
+	e.DELETE("/__admin/stubs", httpStubHandler.Reset)
+	e.POST("/__admin/stubs/import/har", httpStubHandler.ImportHAR)
+	e.GET("/__admin/stubs/cache", httpStubHandler.CacheStats)
+	e.DELETE("/__admin/stubs/cache", httpStubHandler.FlushCache)
+	e.GET("/__admin/stubs/attempts", httpStubHandler.Attempts)
+	e.DELETE("/__admin/stubs/attempts", httpStubHandler.ResetAttempts)
+	e.GET("/__admin/stubs/locks", httpStubHandler.Locks)
+	e.DELETE("/__admin/stubs/locks", httpStubHandler.ReleaseLocks)
+	e.GET("/__admin/stubs/callbacks", httpStubHandler.Callbacks)
+	e.DELETE("/__admin/stubs/callbacks", httpStubHandler.ResetCallbacks)
+	e.GET("/__admin/stubs/costs", httpStubHandler.Costs)
+	e.DELETE("/__admin/stubs/costs", httpStubHandler.ResetCosts)
+	e.GET("/__admin/stubs/:id", httpStubHandler.Get)
+	e.PUT("/__admin/stubs/:id", httpStubHandler.Update)
+	e.DELETE("/__admin/stubs/:id", httpStubHandler.Delete)
+	e.GET("/__admin/keys", keyHandler.List)
+	e.GET("/__admin/keys/:id/private.pem", keyHandler.PrivateKey)
+
+	coverageHandler := admin.NewCoverageHandlers(requestJournal, stubEngine)
+	e.GET("/__admin/stubs/coverage", coverageHandler.Report)
+
+	requestHandler := admin.NewRequestHandlers(requestJournal)
+	e.GET("/__admin/requests", requestHandler.List)
+	e.DELETE("/__admin/requests", requestHandler.Reset)
+	e.GET("/__admin/requests/stats", requestHandler.Stats)
+	e.GET("/__admin/requests/:id", requestHandler.Get)
+	e.GET("/__admin/requests/:id/correlated", requestHandler.RequestCorrelated)
+	e.GET("/__admin/requests/correlated", requestHandler.Correlations)
+	e.GET("/__admin/requests/correlated/:correlation_id", requestHandler.Correlated)
+
+	replayHandler := admin.NewReplayHandlers(replay.New(requestJournal))
+	e.POST("/__admin/replay", replayHandler.Start)
+	e.GET("/__admin/replay", replayHandler.List)
+	e.GET("/__admin/replay/:id", replayHandler.Get)
+	e.DELETE("/__admin/replay/:id", replayHandler.Cancel)
+	e.GET("/__admin/replay/:id/diff", replayHandler.Diff)
+
+	faultHandler := admin.NewFaultHandlers(faultCalendar)
+	e.GET("/__admin/faults", faultHandler.Status)
+	e.PUT("/__admin/faults/profiles/:name", faultHandler.SetProfile)
+	e.DELETE("/__admin/faults/profiles/:name", faultHandler.DeleteProfile)
+	e.POST("/__admin/faults/profiles/:name/activate", faultHandler.Activate)
+	e.DELETE("/__admin/faults/profiles/:name/activate", faultHandler.Deactivate)
+	e.POST("/__admin/faults/schedules", faultHandler.AddSchedule)
+	e.DELETE("/__admin/faults/schedules/:id", faultHandler.DeleteSchedule)
+
+	exportHandler := admin.NewExportHandlers(requestJournal, stubEngine, stubHandler)
+	e.GET("/__admin/requests/export/go", exportHandler.GoTest)
+	e.GET("/__admin/requests/export/har", exportHandler.HAR)
+
+	verifications := verify.NewStore()
+	var configVerificationIDs []string
+	for _, spec := range cfg.Verifications {
+		added, err := verifications.Add(spec)
+		if err != nil {
+			fatal("Invalid verification", "error", err)
+		}
+		configVerificationIDs = append(configVerificationIDs, added.ID)
+	}
+	verificationHandler := admin.NewVerificationHandlers(verifications, requestJournal)
+	e.GET("/__admin/verifications", verificationHandler.List)
+	e.POST("/__admin/verifications", verificationHandler.Create)
+	e.DELETE("/__admin/verifications", verificationHandler.Reset)
+	e.GET("/__admin/verifications/report", verificationHandler.Report)
+	e.DELETE("/__admin/verifications/:id", verificationHandler.Delete)
+
+	configHandler := admin.NewConfigHandlers(loadConfig, loadedCfg, stubEngine, configStubIDs,
+		stubHandler, configGRPCStubIDs, verifications, configVerificationIDs)
+	e.POST("/__admin/config/reload", configHandler.Reload)
+
+	metricsRegistry := metrics.NewRegistry()
+	metricsRegistry.Register(httpMetrics)
+	metricsRegistry.Register(grpcMetrics)
+	metricsRegistry.Register(wsHandler)
+	metricsRegistry.Register(stubEngine)
+	metricsRegistry.Register(panicRecorder)
+	e.GET(metrics.Path, metricsRegistry.Handler)
+
+	wsAdminHandler := admin.NewWebSocketHandlers(wsHandler)
+	e.POST("/__admin/ws/push", wsAdminHandler.Push)
+	e.GET("/__admin/ws/connections", wsAdminHandler.Connections)
+	e.DELETE("/__admin/ws/connections/:id", wsAdminHandler.Disconnect)
+	e.GET("/__admin/ws/rooms", wsAdminHandler.Rooms)
+	e.GET("/__admin/ws/chaos", wsAdminHandler.Chaos)
+	e.PUT("/__admin/ws/chaos", wsAdminHandler.SetChaos)
+	e.POST("/__admin/ws/rooms/:room/messages", wsAdminHandler.Inject)
+	e.GET("/__admin/ws/scenarios", wsAdminHandler.Scenarios)
+	e.GET("/__admin/ws/scenarios/asyncapi", wsAdminHandler.ExportAsyncAPI)
+	e.POST("/__admin/ws/scenarios/asyncapi", wsAdminHandler.ImportAsyncAPI)
+	e.DELETE("/__admin/ws/scenarios/:name", wsAdminHandler.DeleteScenario)
+
+	// Optional record-and-proxy mode for methods without stubs
+	unknownHandler := stubHandler.UnknownServiceHandler
+	var grpcProxy *grpcServer.Proxy
+	if cfg.GRPC.Proxy.Upstream != "" {
+		grpcProxy, err = grpcServer.NewProxy(cfg.GRPC.Proxy, descriptors)
+		if err != nil {
+			fatal("Invalid gRPC configuration", "error", err)
+		}
+		defer grpcProxy.Close()
+		unknownHandler = grpcProxy.UnknownServiceHandler(stubHandler)
+
+		recordingHandler := admin.NewRecordingHandlers(grpcProxy)
+		e.GET("/__admin/grpc/recordings", recordingHandler.List)
+		e.DELETE("/__admin/grpc/recordings", recordingHandler.Reset)
+		e.GET("/__admin/grpc/recordings/stubs", recordingHandler.Stubs)
+	}
 
 	// Setup gRPC server
-	grpcSrv := grpc.NewServer()
+	grpcOptions := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(
+			grpcMetrics.UnaryInterceptor(),
+			logging.UnaryInterceptor(),
+			requestJournal.UnaryInterceptor(),
+			panicRecorder.UnaryInterceptor(),
+			faultCalendar.UnaryInterceptor(),
+			attemptTracker.UnaryInterceptor(),
+			grpcServer.FaultUnaryInterceptor(),
+			metadataEcho.UnaryInterceptor(),
+			sendCompressor.UnaryInterceptor(),
+			stubHandler.UnaryInterceptor(),
+		),
+		grpc.ChainStreamInterceptor(
+			grpcMetrics.StreamInterceptor(),
+			logging.StreamInterceptor(),
+			requestJournal.StreamInterceptor(),
+			panicRecorder.StreamInterceptor(),
+			faultCalendar.StreamInterceptor(),
+			attemptTracker.StreamInterceptor(),
+			grpcServer.FaultStreamInterceptor(),
+			metadataEcho.StreamInterceptor(),
+			sendCompressor.StreamInterceptor(),
+			stubHandler.StreamInterceptor(),
+		),
+		grpc.UnknownServiceHandler(unknownHandler),
+	}
+	grpcOptions = append(grpcOptions, keepaliveOptions...)
+	if grpcProxy != nil {
+		grpcOptions = append(grpcOptions, grpcProxy.ServerOptions()...)
+	}
+	// The in-process client used by Connect mirrors the server limits.
+	var inprocCallOptions []grpc.CallOption
+	if cfg.GRPC.MaxRecvMsgSize > 0 {
+		grpcOptions = append(grpcOptions, grpc.MaxRecvMsgSize(cfg.GRPC.MaxRecvMsgSize))
+		inprocCallOptions = append(inprocCallOptions, grpc.MaxCallSendMsgSize(cfg.GRPC.MaxRecvMsgSize))
+	}
+	if cfg.GRPC.MaxSendMsgSize > 0 {
+		grpcOptions = append(grpcOptions, grpc.MaxSendMsgSize(cfg.GRPC.MaxSendMsgSize))
+		inprocCallOptions = append(inprocCallOptions, grpc.MaxCallRecvMsgSize(cfg.GRPC.MaxSendMsgSize))
+	}
+	grpcSrv := grpc.NewServer(grpcOptions...)
 	pb.RegisterMockServiceServer(grpcSrv, grpcHandler)
-	reflection.Register(grpcSrv) // Enable gRPC reflection
+	healthController.Register(grpcSrv)
+	grpcServer.RegisterReflection(grpcSrv, descriptors)
+
+	// Connect/gRPC-Web: calls are bridged in-process to the gRPC server
+	inprocLis := bufconn.Listen(1024 * 1024)
+	go func() {
+		if err := grpcSrv.Serve(inprocLis); err != nil {
+			logger.Error("In-process gRPC server failed", "error", err)
+		}
+	}()
+	inprocConn, err := grpc.NewClient("passthrough:///inproc",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return inprocLis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(inprocCallOptions...),
+	)
+	if err != nil {
+		fatal("Failed to create in-process gRPC client", "error", err)
+	}
+	defer inprocConn.Close()
+
+	// Connect and gRPC-Web: the built-in services and those loaded from
+	// descriptor sets, including sets uploaded while the server runs
+	builtinServices := make(map[protoreflect.FullName]protoreflect.ServiceDescriptor)
+	for _, sd := range []protoreflect.ServiceDescriptor{
+		pb.File_proto_mock_proto.Services().ByName("MockService"),
+		healthpb.File_grpc_health_v1_health_proto.Services().ByName("Health"),
+	} {
+		builtinServices[sd.FullName()] = sd
+	}
+	bridge := connectHandlers.NewBridge(inprocConn)
+	e.Use(bridge.Middleware(func(name protoreflect.FullName) (protoreflect.ServiceDescriptor, bool) {
+		if sd, ok := builtinServices[name]; ok {
+			return sd, true
+		}
+		return descriptors.FindService(name)
+	}))
+
+	// REST: MockService transcoded to JSON routes, bridged the same way
+	restHandler, err := bridge.REST(pb.File_proto_mock_proto.Services().ByName("MockService"), connectHandlers.MockServiceRoutes)
+	if err != nil {
+		fatal("Failed to set up REST transcoding", "error", err)
+	}
+	e.Any("/v1/mock/*", echo.WrapHandler(restHandler))
 
 	// Create listeners
 	httpAddr := os.Getenv("HTTP_ADDR")
@@ -63,67 +604,742 @@ func main() {
 		grpcAddr = ":50051"
 	}
 
-	// Start gRPC server
-	lis, err := net.Listen("tcp", grpcAddr)
-	if err != nil {
-		log.Fatalf("Failed to listen on %s: %v", grpcAddr, err)
+	// Optional xDS control plane on its own listener
+	var xdsSrv *grpc.Server
+	var xdsLis net.Listener
+	xdsAddr := os.Getenv("XDS_ADDR")
+	if xdsAddr != "" {
+		xdsControlPlane, err := xds.NewServer(cfg.XDS)
+		if err != nil {
+			fatal("Invalid xDS configuration", "error", err)
+		}
+		xdsSrv = grpc.NewServer(
+			grpc.UnaryInterceptor(panicRecorder.UnaryInterceptor()),
+			grpc.StreamInterceptor(panicRecorder.StreamInterceptor()),
+		)
+		xdsControlPlane.Register(xdsSrv)
+
+		xdsLis = listen("xds", xdsAddr)
+
+		xdsHandler := admin.NewXDSHandlers(xdsControlPlane, "localhost"+xdsAddr)
+		e.GET("/__admin/xds", xdsHandler.Services)
+		e.PUT("/__admin/xds/services/:name", xdsHandler.SetService)
+		e.DELETE("/__admin/xds/services/:name", xdsHandler.DeleteService)
+		e.GET("/__admin/xds/bootstrap", xdsHandler.Bootstrap)
 	}
 
+	// Optional fronting listener simulating a corporate proxy
+	var front *proxy.Front
+	var frontSrv *http.Server
+	var frontLis net.Listener
+	frontAddr := os.Getenv("PROXY_FRONT_ADDR")
+	if frontAddr != "" {
+		target := &url.URL{Scheme: "http", Host: loopbackAddr(httpAddr)}
+		front, err = proxy.NewFront(cfg.Proxy.Front, target)
+		if err != nil {
+			fatal("Invalid fronting proxy configuration", "error", err)
+		}
+		frontSrv = front.Server(frontAddr)
+		frontLis = listen("proxy_front", frontAddr)
+	}
+
+	// Optional forward proxies (CONNECT, SOCKS5) routing to the mock or real upstreams
+	var tunneler *proxy.Tunneler
+	var connectSrv *http.Server
+	var connectLis net.Listener
+	var socksLis net.Listener
+	proxyAddr := os.Getenv("PROXY_ADDR")
+	socksAddr := os.Getenv("SOCKS_ADDR")
+	if proxyAddr != "" || socksAddr != "" {
+		tunneler, err = proxy.NewTunneler(cfg.Proxy.Tunnel, map[string]string{
+			proxy.RouteHTTP: loopbackAddr(httpAddr),
+			proxy.RouteGRPC: loopbackAddr(grpcAddr),
+		})
+		if err != nil {
+			fatal("Invalid proxy configuration", "error", err)
+		}
+	}
+	if proxyAddr != "" {
+		connectSrv = proxy.NewConnectProxy(tunneler).Server(proxyAddr)
+		connectLis = listen("proxy", proxyAddr)
+	}
+	if socksAddr != "" {
+		socksLis = listen("socks", socksAddr)
+	}
+
+	proxyHandler := admin.NewProxyHandlers(front, tunneler)
+	e.GET("/__admin/proxy/front/ca.pem", proxyHandler.FrontCA)
+	e.GET("/__admin/proxy/tunnel/ca.pem", proxyHandler.TunnelCA)
+	e.GET("/__admin/proxy/captures", proxyHandler.Captures)
+	e.DELETE("/__admin/proxy/captures", proxyHandler.ResetCaptures)
+
+	// Optional SFTP fixture server
+	var sftpLis net.Listener
+	var sftpSrv *sftp.Server
+	sftpAddr := os.Getenv("SFTP_ADDR")
+	if sftpAddr != "" {
+		sftpSrv, err = sftp.NewServer(cfg.SFTP)
+		if err != nil {
+			fatal("Invalid SFTP configuration", "error", err)
+		}
+		sftpSrv.SetPanics(panicRecorder)
+		sftpLis = listen("sftp", sftpAddr)
+
+		sftpHandler := admin.NewSFTPHandlers(sftpSrv)
+		e.GET("/__admin/sftp/faults", sftpHandler.Faults)
+		e.POST("/__admin/sftp/faults", sftpHandler.AddFault)
+		e.DELETE("/__admin/sftp/faults", sftpHandler.ResetFaults)
+	}
+
+	// Optional Kafka broker
+	var kafkaLis net.Listener
+	var kafkaBroker *kafka.Broker
+	kafkaAddr := os.Getenv("KAFKA_ADDR")
+	if kafkaAddr != "" {
+		kafkaBroker, err = kafka.NewBroker(cfg.Kafka)
+		if err != nil {
+			fatal("Invalid Kafka configuration", "error", err)
+		}
+		kafkaBroker.SetPanics(panicRecorder)
+		kafkaLis = listen("kafka", kafkaAddr)
+
+		kafkaHandler := admin.NewKafkaHandlers(kafkaBroker)
+		e.GET("/__admin/kafka/topics", kafkaHandler.Topics)
+		e.GET("/__admin/kafka/topics/:topic/records", kafkaHandler.Records)
+		e.POST("/__admin/kafka/topics/:topic/records", kafkaHandler.Inject)
+		e.DELETE("/__admin/kafka/records", kafkaHandler.ResetRecords)
+		e.GET("/__admin/kafka/groups", kafkaHandler.Groups)
+		e.GET("/__admin/kafka/groups/:group", kafkaHandler.Group)
+	}
+
+	// Optional SMTP server
+	var smtpLis net.Listener
+	var smtpSrv *smtp.Server
+	smtpAddr := os.Getenv("SMTP_ADDR")
+	if smtpAddr != "" {
+		smtpSrv, err = smtp.NewServer(cfg.SMTP)
+		if err != nil {
+			fatal("Invalid SMTP configuration", "error", err)
+		}
+		smtpSrv.SetPanics(panicRecorder)
+		smtpLis = listen("smtp", smtpAddr)
+
+		smtpHandler := admin.NewSMTPHandlers(smtpSrv)
+		e.GET("/__admin/smtp/messages", smtpHandler.Messages)
+		e.DELETE("/__admin/smtp/messages", smtpHandler.Reset)
+		e.GET("/__admin/smtp/messages/:id", smtpHandler.Message)
+		e.DELETE("/__admin/smtp/messages/:id", smtpHandler.Delete)
+		e.GET("/__admin/smtp/messages/:id/raw", smtpHandler.Raw)
+		e.GET("/__admin/smtp/messages/:id/attachments/:index", smtpHandler.Attachment)
+	}
+
+	// Optional scripted raw TCP listener
+	var tcpLis net.Listener
+	var tcpSrv *tcp.Server
+	tcpAddr := os.Getenv("TCP_ADDR")
+	if tcpAddr != "" {
+		tcpSrv, err = tcp.NewServer(cfg.TCP)
+		if err != nil {
+			fatal("Invalid TCP configuration", "error", err)
+		}
+		tcpSrv.SetPanics(panicRecorder)
+		tcpLis = listen("tcp", tcpAddr)
+
+		tcpHandler := admin.NewTCPHandlers(tcpSrv)
+		e.GET("/__admin/tcp/connections", tcpHandler.Connections)
+		e.DELETE("/__admin/tcp/connections", tcpHandler.Reset)
+		e.GET("/__admin/tcp/connections/:id", tcpHandler.Connection)
+	}
+
+	// Optional UDP echo and scripted responder
+	var udpConn net.PacketConn
+	var udpSrv *udp.Server
+	udpAddr := os.Getenv("UDP_ADDR")
+	if udpAddr != "" {
+		udpSrv, err = udp.NewServer(cfg.UDP)
+		if err != nil {
+			fatal("Invalid UDP configuration", "error", err)
+		}
+		udpSrv.SetPanics(panicRecorder)
+		udpConn = listenPacket("udp", udpAddr)
+
+		udpHandler := admin.NewUDPHandlers(udpSrv)
+		e.GET("/__admin/udp/packets", udpHandler.Packets)
+		e.DELETE("/__admin/udp/packets", udpHandler.Reset)
+	}
+
+	// Optional telemetry sinks (syslog over UDP and TCP, OTLP/HTTP, StatsD,
+	// Prometheus remote-write)
+	var syslogConn, statsdConn net.PacketConn
+	var syslogLis, otlpLis, remoteWriteLis net.Listener
+	var otlpSrv, remoteWriteSrv *http.Server
+	var telemetryStore *telemetry.Store
+	syslogAddr := os.Getenv("SYSLOG_ADDR")
+	otlpAddr := os.Getenv("OTLP_ADDR")
+	statsdAddr := os.Getenv("STATSD_ADDR")
+	remoteWriteAddr := os.Getenv("REMOTE_WRITE_ADDR")
+	if syslogAddr != "" || otlpAddr != "" || statsdAddr != "" || remoteWriteAddr != "" {
+		telemetryStore = telemetry.NewStore(telemetry.DefaultCapacity)
+		if syslogAddr != "" {
+			syslogConn = listenPacket("syslog/udp", syslogAddr)
+			syslogLis = listen("syslog", syslogAddr)
+		}
+		if otlpAddr != "" {
+			otlpSrv = &http.Server{Addr: otlpAddr, Handler: telemetry.NewOTLPHandler(telemetryStore)}
+			otlpLis = listen("otlp", otlpAddr)
+		}
+		if statsdAddr != "" {
+			statsdConn = listenPacket("statsd", statsdAddr)
+		}
+		if remoteWriteAddr != "" {
+			remoteWriteSrv = &http.Server{Addr: remoteWriteAddr, Handler: telemetry.NewRemoteWriteHandler(telemetryStore)}
+			remoteWriteLis = listen("remote_write", remoteWriteAddr)
+		}
+
+		telemetryHandler := admin.NewTelemetryHandlers(telemetryStore)
+		e.GET("/__admin/telemetry", telemetryHandler.List)
+		e.DELETE("/__admin/telemetry", telemetryHandler.Reset)
+	}
+
+	// Optional mTLS listener for certificate-bound OIDC tokens; it serves
+	// the same routes as the HTTP port
+	var mtlsSrv *http.Server
+	var mtlsLis net.Listener
+	mtlsAddr := os.Getenv("OIDC_MTLS_ADDR")
+	if mtlsAddr != "" {
+		if oidcProvider == nil {
+			fatal("OIDC_MTLS_ADDR needs the OIDC provider (oidc.enabled or OIDC_ENABLED=true)")
+		}
+		tlsConfig, err := oidcProvider.TLSConfig()
+		if err != nil {
+			fatal("Invalid OIDC mTLS configuration", "error", err)
+		}
+		mtlsSrv = &http.Server{Addr: mtlsAddr, Handler: e, TLSConfig: tlsConfig}
+		mtlsLis = listen("oidc_mtls", mtlsAddr)
+		oidcProvider.SetMTLSURL("https://" + loopbackAddr(mtlsAddr))
+	}
+
+	// Optional pprof profiles and expvar variables, on the HTTP port and on
+	// a listener of their own, to profile the server under load
+	var debugSrv *http.Server
+	var debugLis net.Listener
+	debugAddr := os.Getenv("DEBUG_ADDR")
+	if cfg.Debug.Enabled || debugAddr != "" {
+		cfg.Debug.Apply()
+	}
+	if cfg.Debug.Enabled {
+		e.Any(diagnostics.AdminPrefix+"/*", echo.WrapHandler(diagnostics.Handler(diagnostics.AdminPrefix)))
+	}
+	if debugAddr != "" {
+		debugSrv = &http.Server{Addr: debugAddr, Handler: diagnostics.Handler(diagnostics.ListenerPrefix)}
+		debugLis = listen("debug", debugAddr)
+	}
+
+	// Bind the main listeners; the optional ones are bound above, so that
+	// every port is taken before any server starts
+	lis := listen("grpc", grpcAddr)
+	e.Listener = listen("http", httpAddr)
+
+	// serve runs a server in a goroutine until shutdown. A server failing
+	// before then fails readiness and stops the process, rather than
+	// leaving it up without one of its listeners.
 	var wg sync.WaitGroup
+	var shuttingDown atomic.Bool
+	serveErrors := make(chan error, 1)
+	serve := func(name string, run func() error) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := run()
+			if err == nil || errors.Is(err, http.ErrServerClosed) || shuttingDown.Load() {
+				return
+			}
+			readyTracker.Fail("listener:"+name, err)
+			select {
+			case serveErrors <- fmt.Errorf("%s: %w", name, err):
+			default:
+			}
+		}()
+	}
 
-	// Start gRPC server in goroutine
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		log.Printf("gRPC server starting on %s", grpcAddr)
-		if err := grpcSrv.Serve(lis); err != nil {
-			log.Printf("gRPC server error: %v", err)
+	serve("grpc", func() error {
+		logger.Info("gRPC server starting", "addr", grpcAddr)
+		return grpcSrv.Serve(lis)
+	})
+	if xdsSrv != nil {
+		serve("xds", func() error {
+			logger.Info("xDS server starting", "addr", xdsAddr)
+			return xdsSrv.Serve(xdsLis)
+		})
+	}
+	if sftpLis != nil {
+		serve("sftp", func() error {
+			logger.Info("SFTP server starting", "addr", sftpAddr)
+			return sftpSrv.Serve(sftpLis)
+		})
+	}
+	if kafkaLis != nil {
+		serve("kafka", func() error {
+			logger.Info("Kafka broker starting", "addr", kafkaAddr)
+			return kafkaBroker.Serve(kafkaLis)
+		})
+	}
+	if smtpLis != nil {
+		serve("smtp", func() error {
+			logger.Info("SMTP server starting", "addr", smtpAddr)
+			return smtpSrv.Serve(smtpLis)
+		})
+	}
+	if tcpLis != nil {
+		serve("tcp", func() error {
+			logger.Info("TCP listener starting", "addr", tcpAddr)
+			return tcpSrv.Serve(tcpLis)
+		})
+	}
+	if udpConn != nil {
+		serve("udp", func() error {
+			logger.Info("UDP responder starting", "addr", udpAddr)
+			return udpSrv.Serve(udpConn)
+		})
+	}
+	if syslogLis != nil {
+		syslogSrv := telemetry.NewSyslogServer(telemetryStore)
+		serve("syslog/udp", func() error {
+			logger.Info("Syslog sink starting", "addr", syslogAddr, "transport", "udp")
+			return syslogSrv.ServeUDP(syslogConn)
+		})
+		serve("syslog", func() error {
+			logger.Info("Syslog sink starting", "addr", syslogAddr, "transport", "tcp")
+			return syslogSrv.ServeTCP(syslogLis)
+		})
+	}
+	if otlpSrv != nil {
+		serve("otlp", func() error {
+			logger.Info("OTLP/HTTP receiver starting", "addr", otlpAddr)
+			return otlpSrv.Serve(otlpLis)
+		})
+	}
+	if statsdConn != nil {
+		serve("statsd", func() error {
+			logger.Info("StatsD sink starting", "addr", statsdAddr, "transport", "udp")
+			return telemetry.NewStatsDServer(telemetryStore).ServeUDP(statsdConn)
+		})
+	}
+	if remoteWriteSrv != nil {
+		serve("remote_write", func() error {
+			logger.Info("Remote-write receiver starting", "addr", remoteWriteAddr)
+			return remoteWriteSrv.Serve(remoteWriteLis)
+		})
+	}
+	if mtlsSrv != nil {
+		serve("oidc_mtls", func() error {
+			logger.Info("OIDC mTLS server starting", "addr", mtlsAddr)
+			return mtlsSrv.ServeTLS(mtlsLis, "", "")
+		})
+	}
+	if debugSrv != nil {
+		serve("debug", func() error {
+			logger.Info("Diagnostics server starting", "addr", debugAddr)
+			return debugSrv.Serve(debugLis)
+		})
+	}
+	if frontSrv != nil {
+		serve("proxy_front", func() error {
+			if frontSrv.TLSConfig != nil {
+				logger.Info("Fronting proxy starting", "addr", frontAddr, "tls", true)
+				return frontSrv.ServeTLS(frontLis, "", "")
+			}
+			logger.Info("Fronting proxy starting", "addr", frontAddr)
+			return frontSrv.Serve(frontLis)
+		})
+	}
+	if connectSrv != nil {
+		serve("proxy", func() error {
+			logger.Info("Proxy (CONNECT) starting", "addr", proxyAddr)
+			return connectSrv.Serve(connectLis)
+		})
+	}
+	if socksLis != nil {
+		serve("socks", func() error {
+			logger.Info("Proxy (SOCKS5) starting", "addr", socksAddr)
+			return proxy.NewSOCKSProxy(tunneler).Serve(socksLis)
+		})
+	}
+
+	// Self-test: every listener, plus a sample of the stubs
+	httpURL := "http://" + loopbackAddr(httpAddr)
+	suite := selftest.New()
+	suite.Add(selftest.HTTP("http", httpURL+"/health"))
+	wsURL := "ws://" + loopbackAddr(httpAddr) + "/ws/echo"
+	if tokens := cfg.WebSocket.Auth.Tokens; len(tokens) > 0 {
+		wsURL += "?token=" + url.QueryEscape(tokens[0])
+	}
+	suite.Add(selftest.WebSocket("websocket", wsURL))
+	suite.Add(selftest.GRPC("grpc", loopbackAddr(grpcAddr)))
+	for _, listener := range []struct{ name, addr string }{
+		{"xds", xdsAddr},
+		{"proxy_front", frontAddr},
+		{"proxy", proxyAddr},
+		{"socks", socksAddr},
+		{"sftp", sftpAddr},
+		{"kafka", kafkaAddr},
+		{"smtp", smtpAddr},
+		{"tcp", tcpAddr},
+		{"syslog", syslogAddr},
+		{"otlp", otlpAddr},
+		{"remote_write", remoteWriteAddr},
+		{"debug", debugAddr},
+		{"oidc_mtls", mtlsAddr},
+	} {
+		if listener.addr != "" {
+			suite.Add(selftest.TCP(listener.name, loopbackAddr(listener.addr)))
 		}
-	}()
+	}
+	suite.SampleStubs(stubEngine, httpURL)
+	selfTestHandler := admin.NewSelfTestHandlers(suite)
+	e.GET("/__admin/selftest", selfTestHandler.Run)
 
-	// Start HTTP/WebSocket server in goroutine
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		log.Printf("HTTP/WebSocket server starting on %s", httpAddr)
-		if err := e.Start(httpAddr); err != nil && err != http.ErrServerClosed {
-			log.Printf("HTTP server error: %v", err)
+	// Web dashboard over the admin API, for those not driving it with curl
+	dashboard.Register(e)
+
+	serve("http", func() error {
+		logger.Info("HTTP/WebSocket server starting", "addr", httpAddr)
+		if cfg.HTTP.HTTP2.Disabled {
+			return e.Start(httpAddr)
 		}
-	}()
+		// Validated by loadConfig
+		h2s, _ := cfg.HTTP.HTTP2.Server()
+		return e.StartH2CServer(httpAddr, h2s)
+	})
 
-	// Log server information
-	log.Println("═══════════════════════════════════════")
-	log.Println("🚀 Multi-Protocol Mock Server Running")
-	log.Println("═══════════════════════════════════════")
-	log.Printf("📡 HTTP/WebSocket: http://localhost%s", httpAddr)
-	log.Printf("🔗 gRPC:           localhost%s", grpcAddr)
-	log.Println("")
-	log.Println("HTTP Endpoints:")
-	log.Printf("  GET  %s/health", httpAddr)
-	log.Printf("  GET  %s/echo", httpAddr)
-	log.Printf("  POST %s/echo", httpAddr)
-	log.Printf("  GET  %s/delay/:seconds", httpAddr)
-	log.Printf("  GET  %s/status/:code", httpAddr)
-	log.Println("")
-	log.Println("WebSocket Endpoints:")
-	log.Printf("  WS   ws://localhost%s/ws/echo", httpAddr)
-	log.Printf("  WS   ws://localhost%s/ws/broadcast", httpAddr)
-	log.Printf("  WS   ws://localhost%s/ws/chat/:room", httpAddr)
-	log.Println("")
-	log.Println("gRPC Service:")
-	log.Printf("  GRPC localhost%s (MockService)", grpcAddr)
-	log.Println("  - Echo (unary)")
-	log.Println("  - ServerStream (server streaming)")
-	log.Println("  - ClientStream (client streaming)")
-	log.Println("  - BidiStream (bidirectional streaming)")
-	log.Println("═══════════════════════════════════════")
-
-	// Wait for interrupt signal to gracefully shutdown
+	// Log server information: a record for the log pipelines, and the
+	// banner for people reading the console
+	logger.Info("Server running", "http", httpAddr, "grpc", grpcAddr)
+	banner := logging.Banner()
+	banner.Println("═══════════════════════════════════════")
+	banner.Println("🚀 Multi-Protocol Mock Server Running")
+	banner.Println("═══════════════════════════════════════")
+	banner.Printf("📡 HTTP/WebSocket: http://localhost%s", httpAddr)
+	banner.Printf("🔗 gRPC:           localhost%s", grpcAddr)
+	banner.Printf("🖥️  Dashboard:      http://localhost%s%s/", httpAddr, dashboard.Path)
+	if xdsSrv != nil {
+		banner.Printf("🧭 xDS (ADS):      localhost%s", xdsAddr)
+	}
+	if frontSrv != nil {
+		banner.Printf("🏢 Fronting proxy: localhost%s -> %s", frontAddr, httpAddr)
+	}
+	if connectSrv != nil {
+		banner.Printf("🚇 Proxy (CONNECT): localhost%s", proxyAddr)
+	}
+	if socksLis != nil {
+		banner.Printf("🧦 Proxy (SOCKS5):  localhost%s", socksAddr)
+	}
+	if sftpLis != nil {
+		banner.Printf("📁 SFTP:           localhost%s (host key %s)", sftpAddr, sftpSrv.Fingerprint())
+	}
+	if kafkaLis != nil {
+		banner.Printf("📨 Kafka:          localhost%s", kafkaAddr)
+	}
+	if smtpLis != nil {
+		banner.Printf("✉️  SMTP:           localhost%s", smtpAddr)
+	}
+	if tcpLis != nil {
+		banner.Printf("🔌 TCP:            localhost%s", tcpAddr)
+	}
+	if udpConn != nil {
+		banner.Printf("📡 UDP:            localhost%s", udpAddr)
+	}
+	if syslogLis != nil {
+		banner.Printf("📜 Syslog:         localhost%s (UDP, TCP)", syslogAddr)
+	}
+	if otlpSrv != nil {
+		banner.Printf("🔭 OTLP/HTTP:      http://localhost%s/v1/{logs,traces,metrics}", otlpAddr)
+	}
+	if statsdConn != nil {
+		banner.Printf("📊 StatsD:         localhost%s (UDP)", statsdAddr)
+	}
+	if remoteWriteSrv != nil {
+		banner.Printf("📈 Remote write:   http://localhost%s/api/v1/write", remoteWriteAddr)
+	}
+	if debugSrv != nil {
+		banner.Printf("🩺 Diagnostics:    http://%s/debug/pprof/", loopbackAddr(debugAddr))
+	}
+	if mtlsSrv != nil {
+		banner.Printf("🔐 OIDC mTLS:      https://%s", loopbackAddr(mtlsAddr))
+	}
+	banner.Println("")
+	banner.Println("HTTP Endpoints:")
+	banner.Printf("  GET  %s/health", httpAddr)
+	banner.Printf("  GET  %s/metrics", httpAddr)
+	banner.Printf("  GET  %s/echo", httpAddr)
+	banner.Printf("  POST %s/echo", httpAddr)
+	banner.Printf("  GET  %s/delay/:seconds", httpAddr)
+	banner.Printf("  GET  %s/status/:code", httpAddr)
+	banner.Printf("  GET  %s/abort", httpAddr)
+	banner.Printf("  GET  %s/i18n", httpAddr)
+	banner.Printf("  GET  %s/json/deep", httpAddr)
+	banner.Printf("  GET  %s/json/wide", httpAddr)
+	banner.Printf("  GET  %s/json/nonstandard", httpAddr)
+	banner.Printf("  GET  %s/.well-known/jwks.json", httpAddr)
+	if cfg.OIDC.Enabled {
+		banner.Printf("  GET  %s%s", httpAddr, oidc.DiscoveryPath)
+		banner.Printf("  GET  %s%s", httpAddr, oidc.AuthorizePath)
+		banner.Printf("  POST %s%s", httpAddr, oidc.TokenPath)
+		banner.Printf("  GET  %s%s", httpAddr, oidc.UserInfoPath)
+		banner.Printf("  GET  %s%s", httpAddr, oidc.JWKSPath)
+		banner.Printf("  GET  %s%s", httpAddr, oidc.ResourcePath)
+	}
+	if jobManager != nil {
+		banner.Printf("  POST %s%s", httpAddr, jobManager.Path())
+		banner.Printf("  GET  %s%s", httpAddr, jobManager.Path())
+		banner.Printf("  GET  %s%s/:id", httpAddr, jobManager.Path())
+		banner.Printf("  DEL  %s%s/:id", httpAddr, jobManager.Path())
+	}
+	if tusServer != nil {
+		banner.Printf("  POST %s%s", httpAddr, tusServer.Path())
+		banner.Printf("  HEAD %s%s/:id", httpAddr, tusServer.Path())
+		banner.Printf("  PATCH %s%s/:id", httpAddr, tusServer.Path())
+		banner.Printf("  DEL  %s%s/:id", httpAddr, tusServer.Path())
+	}
+	if queueBroker != nil {
+		banner.Printf("  GET  %s%s", httpAddr, queueBroker.Path())
+		banner.Printf("  PUT  %s%s/:queue", httpAddr, queueBroker.Path())
+		banner.Printf("  DEL  %s%s/:queue", httpAddr, queueBroker.Path())
+		banner.Printf("  POST %s%s/:queue/messages", httpAddr, queueBroker.Path())
+		banner.Printf("  GET  %s%s/:queue/messages", httpAddr, queueBroker.Path())
+		banner.Printf("  DEL  %s%s/:queue/messages", httpAddr, queueBroker.Path())
+		banner.Printf("  DEL  %s%s/:queue/messages/:receipt", httpAddr, queueBroker.Path())
+		banner.Printf("  PUT  %s%s/:queue/messages/:receipt/visibility", httpAddr, queueBroker.Path())
+	}
+	banner.Println("")
+	banner.Println("WebSocket Endpoints:")
+	banner.Printf("  WS   ws://localhost%s/ws/echo", httpAddr)
+	banner.Printf("  WS   ws://localhost%s/ws/broadcast", httpAddr)
+	banner.Printf("  WS   ws://localhost%s/ws/chat/:room", httpAddr)
+	banner.Printf("  WS   ws://localhost%s/ws/subprotocol", httpAddr)
+	banner.Printf("  WS   ws://localhost%s/ws/stream", httpAddr)
+	banner.Printf("  WS   ws://localhost%s/ws/scenario/:name", httpAddr)
+	banner.Printf("  WS   ws://localhost%s/socket.io/", httpAddr)
+	banner.Printf("  POST %s/publish/broadcast", httpAddr)
+	banner.Printf("  POST %s/publish/chat/:room", httpAddr)
+	banner.Println("")
+	banner.Println("Admin Endpoints:")
+	banner.Printf("  POST %s/__admin/events", httpAddr)
+	banner.Printf("  GET  %s/__admin/grpc/health", httpAddr)
+	banner.Printf("  POST %s/__admin/grpc/health", httpAddr)
+	banner.Printf("  GET  %s/__admin/grpc/service-config", httpAddr)
+	banner.Printf("  GET  %s/__admin/grpc/attempts", httpAddr)
+	banner.Printf("  DEL  %s/__admin/grpc/attempts", httpAddr)
+	banner.Printf("  GET  %s/__admin/grpc/descriptors", httpAddr)
+	banner.Printf("  POST %s/__admin/grpc/descriptors", httpAddr)
+	banner.Printf("  GET  %s/__admin/grpc/stubs", httpAddr)
+	banner.Printf("  POST %s/__admin/grpc/stubs/import", httpAddr)
+	banner.Printf("  GET  %s/__admin/stubs", httpAddr)
+	banner.Printf("  POST %s/__admin/stubs", httpAddr)
+	banner.Printf("  DEL  %s/__admin/stubs", httpAddr)
+	banner.Printf("  POST %s/__admin/stubs/import/har", httpAddr)
+	banner.Printf("  GET  %s/__admin/stubs/:id", httpAddr)
+	banner.Printf("  PUT  %s/__admin/stubs/:id", httpAddr)
+	banner.Printf("  DEL  %s/__admin/stubs/:id", httpAddr)
+	banner.Printf("  GET  %s/__admin/stubs/cache", httpAddr)
+	banner.Printf("  DEL  %s/__admin/stubs/cache", httpAddr)
+	banner.Printf("  GET  %s/__admin/stubs/attempts", httpAddr)
+	banner.Printf("  DEL  %s/__admin/stubs/attempts", httpAddr)
+	banner.Printf("  GET  %s/__admin/stubs/locks", httpAddr)
+	banner.Printf("  DEL  %s/__admin/stubs/locks", httpAddr)
+	banner.Printf("  GET  %s/__admin/stubs/callbacks", httpAddr)
+	banner.Printf("  DEL  %s/__admin/stubs/callbacks", httpAddr)
+	banner.Printf("  GET  %s/__admin/stubs/costs", httpAddr)
+	banner.Printf("  DEL  %s/__admin/stubs/costs", httpAddr)
+	banner.Printf("  GET  %s/__admin/stubs/coverage", httpAddr)
+	banner.Printf("  GET  %s/__admin/keys", httpAddr)
+	banner.Printf("  GET  %s/__admin/keys/:id/private.pem", httpAddr)
+	banner.Printf("  GET  %s/__admin/read-only", httpAddr)
+	banner.Printf("  PUT  %s/__admin/read-only", httpAddr)
+	banner.Printf("  GET  %s/__admin/requests", httpAddr)
+	banner.Printf("  DEL  %s/__admin/requests", httpAddr)
+	banner.Printf("  GET  %s/__admin/requests/stats", httpAddr)
+	banner.Printf("  GET  %s/__admin/requests/:id", httpAddr)
+	banner.Printf("  GET  %s/__admin/requests/:id/correlated", httpAddr)
+	banner.Printf("  GET  %s/__admin/requests/correlated", httpAddr)
+	banner.Printf("  GET  %s/__admin/requests/correlated/:correlation_id", httpAddr)
+	banner.Printf("  GET  %s/__admin/requests/export/go", httpAddr)
+	banner.Printf("  GET  %s/__admin/requests/export/har", httpAddr)
+	banner.Printf("  POST %s/__admin/replay", httpAddr)
+	banner.Printf("  GET  %s/__admin/replay", httpAddr)
+	banner.Printf("  GET  %s/__admin/replay/:id", httpAddr)
+	banner.Printf("  DEL  %s/__admin/replay/:id", httpAddr)
+	banner.Printf("  GET  %s/__admin/replay/:id/diff", httpAddr)
+	banner.Printf("  GET  %s/__admin/faults", httpAddr)
+	banner.Printf("  PUT  %s/__admin/faults/profiles/:name", httpAddr)
+	banner.Printf("  DEL  %s/__admin/faults/profiles/:name", httpAddr)
+	banner.Printf("  POST %s/__admin/faults/profiles/:name/activate", httpAddr)
+	banner.Printf("  DEL  %s/__admin/faults/profiles/:name/activate", httpAddr)
+	banner.Printf("  POST %s/__admin/faults/schedules", httpAddr)
+	banner.Printf("  DEL  %s/__admin/faults/schedules/:id", httpAddr)
+	banner.Printf("  GET  %s/__admin/verifications", httpAddr)
+	banner.Printf("  POST %s/__admin/verifications", httpAddr)
+	banner.Printf("  DEL  %s/__admin/verifications", httpAddr)
+	banner.Printf("  GET  %s/__admin/verifications/report", httpAddr)
+	banner.Printf("  DEL  %s/__admin/verifications/:id", httpAddr)
+	banner.Printf("  GET  %s/__admin/errors", httpAddr)
+	banner.Printf("  DEL  %s/__admin/errors", httpAddr)
+	banner.Printf("  GET  %s/__admin/errors/:id", httpAddr)
+	if cfg.Debug.Enabled {
+		banner.Printf("  GET  %s/__admin/debug/pprof/", httpAddr)
+		banner.Printf("  GET  %s/__admin/debug/vars", httpAddr)
+	}
+	banner.Printf("  POST %s/__admin/config/reload", httpAddr)
+	banner.Printf("  GET  %s/__admin/selftest", httpAddr)
+	banner.Printf("  GET  %s/__admin/ui", httpAddr)
+	banner.Printf("  POST %s/__admin/ws/push", httpAddr)
+	banner.Printf("  GET  %s/__admin/ws/connections", httpAddr)
+	banner.Printf("  DEL  %s/__admin/ws/connections/:id", httpAddr)
+	banner.Printf("  GET  %s/__admin/ws/rooms", httpAddr)
+	banner.Printf("  POST %s/__admin/ws/rooms/:room/messages", httpAddr)
+	banner.Printf("  GET  %s/__admin/ws/chaos", httpAddr)
+	banner.Printf("  PUT  %s/__admin/ws/chaos", httpAddr)
+	banner.Printf("  GET  %s/__admin/ws/scenarios", httpAddr)
+	banner.Printf("  GET  %s/__admin/ws/scenarios/asyncapi", httpAddr)
+	banner.Printf("  POST %s/__admin/ws/scenarios/asyncapi", httpAddr)
+	banner.Printf("  DEL  %s/__admin/ws/scenarios/:name", httpAddr)
+	if xdsSrv != nil {
+		banner.Printf("  GET  %s/__admin/xds", httpAddr)
+		banner.Printf("  PUT  %s/__admin/xds/services/:name", httpAddr)
+		banner.Printf("  DEL  %s/__admin/xds/services/:name", httpAddr)
+		banner.Printf("  GET  %s/__admin/xds/bootstrap", httpAddr)
+	}
+	if httpRecorder != nil {
+		banner.Printf("  GET  %s/__admin/recordings", httpAddr)
+		banner.Printf("  DEL  %s/__admin/recordings", httpAddr)
+		banner.Printf("  GET  %s/__admin/recordings/stubs", httpAddr)
+	}
+	if grpcProxy != nil {
+		banner.Printf("  GET  %s/__admin/grpc/recordings", httpAddr)
+		banner.Printf("  DEL  %s/__admin/grpc/recordings", httpAddr)
+		banner.Printf("  GET  %s/__admin/grpc/recordings/stubs", httpAddr)
+	}
+	if jobManager != nil {
+		banner.Printf("  GET  %s/__admin/jobs", httpAddr)
+		banner.Printf("  DEL  %s/__admin/jobs", httpAddr)
+	}
+	if queueBroker != nil {
+		banner.Printf("  GET  %s/__admin/queues", httpAddr)
+		banner.Printf("  DEL  %s/__admin/queues", httpAddr)
+		banner.Printf("  GET  %s/__admin/queues/:name", httpAddr)
+	}
+	if signer != nil {
+		banner.Printf("  GET  %s/__admin/sign", httpAddr)
+	}
+	if tusServer != nil {
+		banner.Printf("  GET  %s/__admin/tus/uploads", httpAddr)
+		banner.Printf("  DEL  %s/__admin/tus/uploads", httpAddr)
+		banner.Printf("  GET  %s/__admin/tus/uploads/:id", httpAddr)
+		banner.Printf("  GET  %s/__admin/tus/uploads/:id/data", httpAddr)
+	}
+	if sftpLis != nil {
+		banner.Printf("  GET  %s/__admin/sftp/faults", httpAddr)
+		banner.Printf("  POST %s/__admin/sftp/faults", httpAddr)
+		banner.Printf("  DEL  %s/__admin/sftp/faults", httpAddr)
+	}
+	if kafkaLis != nil {
+		banner.Printf("  GET  %s/__admin/kafka/topics", httpAddr)
+		banner.Printf("  GET  %s/__admin/kafka/topics/:topic/records", httpAddr)
+		banner.Printf("  POST %s/__admin/kafka/topics/:topic/records", httpAddr)
+		banner.Printf("  DEL  %s/__admin/kafka/records", httpAddr)
+		banner.Printf("  GET  %s/__admin/kafka/groups", httpAddr)
+		banner.Printf("  GET  %s/__admin/kafka/groups/:group", httpAddr)
+	}
+	if smtpLis != nil {
+		banner.Printf("  GET  %s/__admin/smtp/messages", httpAddr)
+		banner.Printf("  DEL  %s/__admin/smtp/messages", httpAddr)
+		banner.Printf("  GET  %s/__admin/smtp/messages/:id", httpAddr)
+		banner.Printf("  DEL  %s/__admin/smtp/messages/:id", httpAddr)
+		banner.Printf("  GET  %s/__admin/smtp/messages/:id/raw", httpAddr)
+		banner.Printf("  GET  %s/__admin/smtp/messages/:id/attachments/:index", httpAddr)
+	}
+	if tcpLis != nil {
+		banner.Printf("  GET  %s/__admin/tcp/connections", httpAddr)
+		banner.Printf("  DEL  %s/__admin/tcp/connections", httpAddr)
+		banner.Printf("  GET  %s/__admin/tcp/connections/:id", httpAddr)
+	}
+	if udpConn != nil {
+		banner.Printf("  GET  %s/__admin/udp/packets", httpAddr)
+		banner.Printf("  DEL  %s/__admin/udp/packets", httpAddr)
+	}
+	if telemetryStore != nil {
+		banner.Printf("  GET  %s/__admin/telemetry", httpAddr)
+		banner.Printf("  DEL  %s/__admin/telemetry", httpAddr)
+	}
+	if frontSrv != nil {
+		banner.Printf("  GET  %s/__admin/proxy/front/ca.pem", httpAddr)
+	}
+	if tunneler != nil {
+		banner.Printf("  GET  %s/__admin/proxy/tunnel/ca.pem", httpAddr)
+		banner.Printf("  GET  %s/__admin/proxy/captures", httpAddr)
+		banner.Printf("  DEL  %s/__admin/proxy/captures", httpAddr)
+	}
+	banner.Println("")
+	banner.Println("gRPC Service:")
+	banner.Printf("  GRPC localhost%s (MockService)", grpcAddr)
+	banner.Println("  - Echo (unary)")
+	banner.Println("  - ServerStream (server streaming)")
+	banner.Println("  - ClientStream (client streaming)")
+	banner.Println("  - BidiStream (bidirectional streaming)")
+	banner.Println("  - Subscribe (event bus streaming)")
+	banner.Println("  - AnyPayloads, UnknownFields (unary)")
+	banner.Println("  - EchoAllTypes, SampleAllTypes (unary), EchoAllTypesStream (bidi)")
+	banner.Println("  - Oversized, Payload, ExceedDeadline (unary)")
+	banner.Println("  - grpc.health.v1.Health")
+	if keepalive := cfg.GRPC.Keepalive.String(); keepalive != "" {
+		banner.Printf("  Keepalive: %s", keepalive)
+	}
+	if grpcProxy != nil {
+		banner.Printf("  Proxy: unknown methods -> %s (recording)", cfg.GRPC.Proxy.Upstream)
+	}
+	banner.Println("")
+	banner.Println("Connect / gRPC-Web:")
+	banner.Printf("  POST %s/mock.MockService/<Method>", httpAddr)
+	banner.Println("")
+	banner.Println("REST (MockService):")
+	for _, route := range connectHandlers.MockServiceRoutes {
+		banner.Printf("  %-4s %s%s -> %s", route.Method, httpAddr, route.Path, route.RPC)
+	}
+	banner.Println("═══════════════════════════════════════")
+	readyTracker.Started()
+
+	if *selfTest {
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		report := suite.RunUntilReady(ctx, selftest.DefaultStubSample)
+		cancel()
+		out, _ := json.MarshalIndent(report, "", "  ")
+		out = append(out, '\n')
+		if *selfTestReport != "" {
+			if err := os.WriteFile(*selfTestReport, out, 0o644); err != nil {
+				fatal("Failed to write self-test report", "error", err)
+			}
+		} else {
+			os.Stdout.Write(out)
+		}
+		if !report.Passed {
+			fatal("Self-test failed", "failures", report.Failures, "checks", report.Tests)
+		}
+		logger.Info("Self-test passed", "checks", report.Tests)
+	}
+
+	// Wait for interrupt signal to gracefully shutdown, or for a server to
+	// fail
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
+	var serveErr error
+	select {
+	case <-quit:
+	case serveErr = <-serveErrors:
+		logger.Error("Server failed", "error", serveErr)
+	}
+	shuttingDown.Store(true)
 
-	log.Println("Shutting down servers...")
+	logger.Info("Shutting down servers")
 
 	// Graceful shutdown
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -131,11 +1347,206 @@ func main() {
 
 	// Shutdown HTTP server
 	if err := e.Shutdown(ctx); err != nil {
-		log.Printf("HTTP server shutdown error: %v", err)
+		logger.Error("HTTP server shutdown error", "error", err)
+	}
+
+	if frontSrv != nil {
+		if err := frontSrv.Shutdown(ctx); err != nil {
+			logger.Error("Fronting proxy shutdown error", "error", err)
+		}
+	}
+
+	if connectSrv != nil {
+		if err := connectSrv.Shutdown(ctx); err != nil {
+			logger.Error("Proxy shutdown error", "error", err)
+		}
+	}
+	if socksLis != nil {
+		socksLis.Close()
+	}
+	if sftpLis != nil {
+		sftpLis.Close()
+	}
+	if kafkaLis != nil {
+		kafkaLis.Close()
+	}
+	if smtpLis != nil {
+		smtpLis.Close()
+	}
+	if tcpLis != nil {
+		tcpLis.Close()
+	}
+	if udpConn != nil {
+		udpConn.Close()
+	}
+	if syslogLis != nil {
+		syslogConn.Close()
+		syslogLis.Close()
+	}
+	if otlpSrv != nil {
+		if err := otlpSrv.Shutdown(ctx); err != nil {
+			logger.Error("OTLP receiver shutdown error", "error", err)
+		}
+	}
+	if statsdConn != nil {
+		statsdConn.Close()
+	}
+	if remoteWriteSrv != nil {
+		if err := remoteWriteSrv.Shutdown(ctx); err != nil {
+			logger.Error("Remote-write receiver shutdown error", "error", err)
+		}
+	}
+	if mtlsSrv != nil {
+		if err := mtlsSrv.Shutdown(ctx); err != nil {
+			logger.Error("OIDC mTLS server shutdown error", "error", err)
+		}
+	}
+	if debugSrv != nil {
+		if err := debugSrv.Shutdown(ctx); err != nil {
+			logger.Error("Diagnostics server shutdown error", "error", err)
+		}
 	}
 
 	// Shutdown gRPC server
+	healthController.Shutdown()
 	grpcSrv.GracefulStop()
+	if xdsSrv != nil {
+		xdsSrv.Stop()
+	}
+
+	requestJournal.Close()
+	logger.Info("Servers stopped")
+	if serveErr != nil {
+		os.Exit(1)
+	}
+}
+
+// loadConfig reads the configuration file, or data when given, applies the
+// environment variables overriding it and validates the settings that are
+// not checked as the servers are built.
+func loadConfig(data []byte) (*config.Config, error) {
+	var cfg *config.Config
+	var err error
+	if data != nil {
+		cfg, err = config.Parse(data)
+	} else {
+		cfg, err = config.Load(os.Getenv("CONFIG_FILE"))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if enabled, _ := strconv.ParseBool(os.Getenv("DEMO_MODE")); enabled {
+		cfg.Demo.Enabled = true
+	}
+	if enabled, _ := strconv.ParseBool(os.Getenv("OIDC_ENABLED")); enabled {
+		cfg.OIDC.Enabled = true
+	}
+	if enabled, _ := strconv.ParseBool(os.Getenv("JOBS_ENABLED")); enabled {
+		cfg.Jobs.Enabled = true
+	}
+	if enabled, _ := strconv.ParseBool(os.Getenv("TUS_ENABLED")); enabled {
+		cfg.Tus.Enabled = true
+	}
+	if enabled, _ := strconv.ParseBool(os.Getenv("QUEUES_ENABLED")); enabled {
+		cfg.Queues.Enabled = true
+	}
+	if enabled, _ := strconv.ParseBool(os.Getenv("SIGNED_URLS_ENABLED")); enabled {
+		cfg.SignedURLs.Enabled = true
+	}
+	if enabled, _ := strconv.ParseBool(os.Getenv("DEV_MODE")); enabled {
+		cfg.HTTP.DevMode = true
+	}
+	if enabled, _ := strconv.ParseBool(os.Getenv("READ_ONLY")); enabled {
+		cfg.ReadOnly = true
+	}
+	if enabled, _ := strconv.ParseBool(os.Getenv("DEBUG_ENDPOINTS")); enabled {
+		cfg.Debug.Enabled = true
+	}
+	if upstream := os.Getenv("HTTP_PROXY_UPSTREAM"); upstream != "" {
+		cfg.HTTP.Proxy.Upstream = upstream
+	}
+	if upstream := os.Getenv("GRPC_PROXY_UPSTREAM"); upstream != "" {
+		cfg.GRPC.Proxy.Upstream = upstream
+	}
+	if cfg.SFTP.Root == "" {
+		cfg.SFTP.Root = os.Getenv("SFTP_ROOT")
+	}
+	if level := os.Getenv("LOG_LEVEL"); level != "" {
+		cfg.Logging.Level = level
+	}
+	if format := os.Getenv("LOG_FORMAT"); format != "" {
+		cfg.Logging.Format = format
+	}
+	if spec := os.Getenv("LOG_LEVELS"); spec != "" {
+		levels, err := logging.ParseSubsystems(spec)
+		if err != nil {
+			return nil, fmt.Errorf("invalid LOG_LEVELS: %w", err)
+		}
+		if cfg.Logging.Subsystems == nil {
+			cfg.Logging.Subsystems = make(map[string]string)
+		}
+		for name, level := range levels {
+			cfg.Logging.Subsystems[name] = level
+		}
+	}
+
+	if err := cfg.WebSocket.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid WebSocket configuration: %w", err)
+	}
+	if err := cfg.Demo.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid demo configuration: %w", err)
+	}
+	if err := cfg.HTTP.HTTP2.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid HTTP configuration: %w", err)
+	}
+	if err := cfg.Jobs.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid job API configuration: %w", err)
+	}
+	if err := cfg.Tus.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid tus configuration: %w", err)
+	}
+	if err := cfg.Queues.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid queue configuration: %w", err)
+	}
+	if err := cfg.SignedURLs.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid signed URL configuration: %w", err)
+	}
+	if err := cfg.Faults.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid fault configuration: %w", err)
+	}
+	if err := cfg.Logging.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid logging configuration: %w", err)
+	}
+	if err := cfg.Debug.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid debug configuration: %w", err)
+	}
+	return cfg, nil
+}
+
+// fatal logs msg at error level and exits.
+func fatal(msg string, args ...any) {
+	logger.Error(msg, args...)
+	os.Exit(1)
+}
+
+// loopbackAddr turns a listen address such as ":8080" into one that can be
+// dialed locally.
+func loopbackAddr(addr string) string {
+	if strings.HasPrefix(addr, ":") {
+		return "127.0.0.1" + addr
+	}
+	return addr
+}
+
+// stubFlags collects the repeatable --stub flag.
+type stubFlags []string
+
+func (s *stubFlags) String() string {
+	return strings.Join(*s, ", ")
+}
 
-	log.Println("Servers stopped")
+func (s *stubFlags) Set(value string) error {
+	*s = append(*s, value)
+	return nil
 }
@@ -0,0 +1,69 @@
+// Command verify-report evaluates the verification specs stored in a running
+// mock server and writes the result as JUnit XML or JSON, for CI systems that
+// collect test reports. It exits with status 1 when a verification failed.
+//
+//	go run ./cmd/verify-report -server http://localhost:8080 -format junit -o mock-report.xml
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"mockserver/pkg/adminclient"
+)
+
+func main() {
+	server := flag.String("server", envOr("MOCKSERVER_URL", "http://localhost:8080"), "mock server base URL")
+	format := flag.String("format", "junit", "report format: junit or json")
+	output := flag.String("o", "", "output file (default stdout)")
+	timeout := flag.Duration("timeout", 10*time.Second, "request timeout")
+	flag.Parse()
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	client := adminclient.New(*server)
+
+	report, err := client.Report(ctx)
+	if err != nil {
+		log.Fatalf("Failed to fetch verification report: %v", err)
+	}
+
+	// Both formats are rendered from one evaluation so the exit status always
+	// matches the written report.
+	var data []byte
+	switch *format {
+	case "junit":
+		data, err = report.JUnit()
+	case "json":
+		data, err = json.MarshalIndent(report, "", "  ")
+		data = append(data, '\n')
+	default:
+		log.Fatalf("Unknown format %q (want junit or json)", *format)
+	}
+	if err != nil {
+		log.Fatalf("Failed to render %s report: %v", *format, err)
+	}
+
+	if *output == "" {
+		os.Stdout.Write(data)
+	} else if err := os.WriteFile(*output, data, 0o644); err != nil {
+		log.Fatalf("Failed to write report: %v", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "%d verification(s), %d failure(s)\n", report.Tests, report.Failures)
+	if report.Failures > 0 {
+		os.Exit(1)
+	}
+}
+
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
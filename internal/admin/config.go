@@ -0,0 +1,170 @@
+package admin
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"mockserver/internal/config"
+	grpcServer "mockserver/internal/grpc"
+	"mockserver/internal/stubs"
+	"mockserver/internal/verify"
+)
+
+// ConfigLoader reads and validates a configuration: data when given, the
+// configuration file otherwise.
+type ConfigLoader func(data []byte) (*config.Config, error)
+
+// ConfigHandlers reload the configuration while the server runs. Only the
+// stubs and verifications of the configuration are swapped; stubs and
+// verifications added through the admin API or on the command line are
+// kept, and the other settings are reported until a restart applies them.
+type ConfigHandlers struct {
+	load          ConfigLoader
+	engine        *stubs.Engine
+	grpcStubs     *grpcServer.StubHandler
+	verifications *verify.Store
+
+	mutex sync.Mutex
+	// running is the configuration in effect, as it was loaded.
+	running *config.Config
+	// stubIDs, grpcStubIDs and verificationIDs are what the configuration
+	// registered.
+	stubIDs         []string
+	grpcStubIDs     []string
+	verificationIDs []string
+}
+
+func NewConfigHandlers(load ConfigLoader, running *config.Config, engine *stubs.Engine, stubIDs []string,
+	grpcStubs *grpcServer.StubHandler, grpcStubIDs []string, verifications *verify.Store, verificationIDs []string) *ConfigHandlers {
+	return &ConfigHandlers{
+		load:            load,
+		running:         running,
+		engine:          engine,
+		stubIDs:         stubIDs,
+		grpcStubs:       grpcStubs,
+		grpcStubIDs:     grpcStubIDs,
+		verifications:   verifications,
+		verificationIDs: verificationIDs,
+	}
+}
+
+// Reload loads the configuration posted in the body, or the configuration
+// file when the body is empty, and swaps the stubs and verifications that
+// changed once all of them are valid. An invalid configuration changes
+// nothing and answers 400; either way the response describes the changes.
+func (h *ConfigHandlers) Reload(c echo.Context) error {
+	if !h.mutex.TryLock() {
+		return c.JSON(http.StatusConflict, map[string]interface{}{
+			"error":     "A configuration reload is already in progress",
+			"timestamp": time.Now().Unix(),
+		})
+	}
+	defer h.mutex.Unlock()
+
+	body, err := io.ReadAll(c.Request().Body)
+	if err != nil {
+		return invalidConfig(c, err, nil)
+	}
+	var data []byte
+	if len(bytes.TrimSpace(body)) > 0 {
+		data = body
+	}
+	next, err := h.load(data)
+	if err != nil {
+		return invalidConfig(c, err, nil)
+	}
+	// Compiling normalizes the stubs: keep them as loaded to diff later
+	loaded, err := next.Clone()
+	if err != nil {
+		return invalidConfig(c, err, nil)
+	}
+	changes, err := h.running.Diff(loaded)
+	if err != nil {
+		return invalidConfig(c, err, nil)
+	}
+
+	// Everything is checked before anything is swapped
+	if !changes.HTTPStubs.Empty() {
+		if err := h.engine.Validate(next.HTTP.Stubs); err != nil {
+			return invalidConfig(c, err, &changes)
+		}
+	}
+	if !changes.GRPCStubs.Empty() {
+		if err := h.grpcStubs.Validate(next.GRPC.Stubs); err != nil {
+			return invalidConfig(c, err, &changes)
+		}
+	}
+	if !changes.Verifications.Empty() {
+		added, err := h.verifications.Swap(h.verificationIDs, next.Verifications)
+		if err != nil {
+			return invalidConfig(c, err, &changes)
+		}
+		h.verificationIDs = make([]string, len(added))
+		for i, spec := range added {
+			h.verificationIDs[i] = spec.ID
+		}
+		h.running.Verifications = loaded.Verifications
+	}
+	if !changes.GRPCStubs.Empty() {
+		added, err := h.grpcStubs.Swap(h.grpcStubIDs, next.GRPC.Stubs)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+				"error":     "Configuration partially applied",
+				"details":   err.Error(),
+				"timestamp": time.Now().Unix(),
+			})
+		}
+		h.grpcStubIDs = make([]string, len(added))
+		for i, stub := range added {
+			h.grpcStubIDs[i] = stub.ID
+		}
+		h.running.GRPC.Stubs = loaded.GRPC.Stubs
+	}
+	if !changes.HTTPStubs.Empty() {
+		added, err := h.engine.Swap(h.stubIDs, next.HTTP.Stubs)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+				"error":     "Configuration partially applied",
+				"details":   err.Error(),
+				"timestamp": time.Now().Unix(),
+			})
+		}
+		h.stubIDs = make([]string, len(added))
+		for i, stub := range added {
+			h.stubIDs[i] = stub.ID
+		}
+		h.running.HTTP.Stubs = loaded.HTTP.Stubs
+	}
+
+	if len(changes.Settings) > 0 {
+		logger.InfoContext(c.Request().Context(), "Configuration reloaded, restart to apply settings", "settings", changes.Settings)
+	} else {
+		logger.InfoContext(c.Request().Context(), "Configuration reloaded")
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"changed":          !changes.Empty(),
+		"changes":          changes,
+		"restart_required": len(changes.Settings) > 0,
+		"timestamp":        time.Now().Unix(),
+	})
+}
+
+// invalidConfig rejects a reload, with the changes it would have made when
+// they are known.
+func invalidConfig(c echo.Context, err error, changes *config.Changes) error {
+	logger.WarnContext(c.Request().Context(), "Configuration reload rejected", "error", err)
+	response := map[string]interface{}{
+		"error":     "Invalid configuration",
+		"details":   err.Error(),
+		"timestamp": time.Now().Unix(),
+	}
+	if changes != nil {
+		response["changes"] = changes
+	}
+	return c.JSON(http.StatusBadRequest, response)
+}
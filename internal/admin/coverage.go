@@ -0,0 +1,116 @@
+package admin
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"mockserver/internal/journal"
+	"mockserver/internal/stubs"
+)
+
+// CoverageHandlers report which HTTP stubs the recorded requests used.
+type CoverageHandlers struct {
+	journal *journal.Journal
+	engine  *stubs.Engine
+}
+
+func NewCoverageHandlers(j *journal.Journal, engine *stubs.Engine) *CoverageHandlers {
+	return &CoverageHandlers{journal: j, engine: engine}
+}
+
+// StubCoverage is the usage of one stub.
+type StubCoverage struct {
+	ID      string     `json:"id"`
+	Name    string     `json:"name,omitempty"`
+	Method  string     `json:"method,omitempty"`
+	Path    string     `json:"path,omitempty"`
+	Hits    int        `json:"hits"`
+	LastHit *time.Time `json:"last_hit,omitempty"`
+}
+
+// UnmatchedPath counts requests of a path that no stub answered.
+type UnmatchedPath struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Count  int    `json:"count"`
+}
+
+// Report lists the stubs hit by the requests selected with the journal
+// query parameters (correlation_id to scope it to one tagged test run,
+// since, path, ...), the stubs never used, and the requests no stub
+// answered. Stubs removed since are left out.
+func (h *CoverageHandlers) Report(c echo.Context) error {
+	filter, bad := requestFilter(c)
+	if bad != nil {
+		return invalidQuery(c, bad.name, bad.value)
+	}
+	filter.Protocol = journal.ProtocolHTTP
+	filter.Limit = 0
+
+	usage := make(map[string]*StubCoverage)
+	unmatched := make(map[[2]string]int)
+	requests := 0
+	for _, entry := range h.journal.Find(filter) {
+		requests++
+		if entry.StubID == "" {
+			unmatched[[2]string{entry.Method, entry.Path}]++
+			continue
+		}
+		u := usage[entry.StubID]
+		if u == nil {
+			u = &StubCoverage{}
+			usage[entry.StubID] = u
+		}
+		u.Hits++
+		if u.LastHit == nil || entry.Timestamp.After(*u.LastHit) {
+			timestamp := entry.Timestamp
+			u.LastHit = &timestamp
+		}
+	}
+
+	hit, unused := []StubCoverage{}, []StubCoverage{}
+	for _, stub := range h.engine.List() {
+		coverage := StubCoverage{ID: stub.ID, Name: stub.Name, Method: stub.Request.Method, Path: stub.Request.Path}
+		if coverage.Path == "" {
+			coverage.Path = stub.Request.PathRegex
+		}
+		if u := usage[stub.ID]; u != nil {
+			coverage.Hits, coverage.LastHit = u.Hits, u.LastHit
+			hit = append(hit, coverage)
+		} else {
+			unused = append(unused, coverage)
+		}
+	}
+	sort.SliceStable(hit, func(i, j int) bool { return hit[i].Hits > hit[j].Hits })
+
+	paths := make([]UnmatchedPath, 0, len(unmatched))
+	for key, count := range unmatched {
+		paths = append(paths, UnmatchedPath{Method: key[0], Path: key[1], Count: count})
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		if paths[i].Count != paths[j].Count {
+			return paths[i].Count > paths[j].Count
+		}
+		return paths[i].Path < paths[j].Path
+	})
+
+	coverage := 0.0
+	if total := len(hit) + len(unused); total > 0 {
+		coverage = math.Round(float64(len(hit))*10000/float64(total)) / 100
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"correlation_id": filter.CorrelationID,
+		"requests":       requests,
+		"stubs_total":    len(hit) + len(unused),
+		"stubs_hit":      len(hit),
+		"coverage":       coverage,
+		"hit":            hit,
+		"unused":         unused,
+		"unmatched":      paths,
+		"timestamp":      time.Now().Unix(),
+	})
+}
@@ -0,0 +1,308 @@
+package admin
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	grpcServer "mockserver/internal/grpc"
+)
+
+// maxDescriptorSet caps the size of an uploaded descriptor set.
+const maxDescriptorSet = 32 * 1024 * 1024
+
+// DescriptorHandlers load proto descriptors into the running server, making
+// their services available to gRPC stubs and reflection.
+type DescriptorHandlers struct {
+	registry *grpcServer.DescriptorRegistry
+	health   *grpcServer.HealthController
+}
+
+func NewDescriptorHandlers(registry *grpcServer.DescriptorRegistry, health *grpcServer.HealthController) *DescriptorHandlers {
+	return &DescriptorHandlers{registry: registry, health: health}
+}
+
+// Upload registers a FileDescriptorSet, binary (as written by
+// `protoc --include_imports -o`) or in protojson form when the request is
+// JSON. New services are reported SERVING by the health service.
+func (h *DescriptorHandlers) Upload(c echo.Context) error {
+	data, err := io.ReadAll(io.LimitReader(c.Request().Body, maxDescriptorSet+1))
+	if err != nil {
+		return invalidDescriptorSet(c, err)
+	}
+	if len(data) > maxDescriptorSet {
+		return c.JSON(http.StatusRequestEntityTooLarge, map[string]interface{}{
+			"error":     "Descriptor set too large",
+			"details":   "the descriptor set must be at most 32 MiB",
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	var set descriptorpb.FileDescriptorSet
+	if strings.HasPrefix(c.Request().Header.Get(echo.HeaderContentType), echo.MIMEApplicationJSON) {
+		err = protojson.Unmarshal(data, &set)
+	} else {
+		err = proto.Unmarshal(data, &set)
+	}
+	if err != nil {
+		return invalidDescriptorSet(c, err)
+	}
+
+	known := make(map[string]bool)
+	for _, sd := range h.registry.Services() {
+		known[string(sd.FullName())] = true
+	}
+	if err := h.registry.Register(&set); err != nil {
+		return invalidDescriptorSet(c, err)
+	}
+
+	added := []string{}
+	for _, sd := range h.registry.Services() {
+		name := string(sd.FullName())
+		if !known[name] {
+			added = append(added, name)
+			h.health.Set(name, healthpb.HealthCheckResponse_SERVING)
+		}
+	}
+	logger.InfoContext(c.Request().Context(), "Descriptor set loaded", "files", len(set.GetFile()), "new_services", added)
+
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+		"files":     len(set.GetFile()),
+		"services":  added,
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// Download returns the loaded descriptors with their imports: a browsable
+// JSON view of the files, services, messages and enums by default, or the
+// FileDescriptorSet itself with format=binary (also selected by an
+// application/octet-stream or application/x-protobuf Accept header) or
+// format=protojson.
+func (h *DescriptorHandlers) Download(c echo.Context) error {
+	format := c.QueryParam("format")
+	if format == "" {
+		accept := c.Request().Header.Get(echo.HeaderAccept)
+		if strings.Contains(accept, echo.MIMEOctetStream) || strings.Contains(accept, "application/x-protobuf") {
+			format = "binary"
+		}
+	}
+
+	set := h.registry.FileDescriptorSet()
+	switch format {
+	case "", "json":
+		loaded := make(map[string]bool)
+		for _, fd := range h.registry.Files() {
+			loaded[fd.Path()] = true
+		}
+		files := make([]DescriptorFile, 0, len(set.GetFile()))
+		for _, fdp := range set.GetFile() {
+			fd, err := h.registry.FindFileByPath(fdp.GetName())
+			if err != nil {
+				continue
+			}
+			files = append(files, describeFile(fd, loaded[fd.Path()]))
+		}
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"files":     files,
+			"count":     len(files),
+			"timestamp": time.Now().Unix(),
+		})
+	case "binary":
+		data, err := proto.Marshal(set)
+		if err != nil {
+			return encodeDescriptorSetFailed(c, err)
+		}
+		c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="descriptors.protoset"`)
+		return c.Blob(http.StatusOK, echo.MIMEOctetStream, data)
+	case "protojson":
+		data, err := protojson.Marshal(set)
+		if err != nil {
+			return encodeDescriptorSetFailed(c, err)
+		}
+		return c.JSONBlob(http.StatusOK, data)
+	default:
+		return invalidQuery(c, "format", format)
+	}
+}
+
+// DescriptorFile is the JSON view of a proto file.
+type DescriptorFile struct {
+	Name         string              `json:"name"`
+	Package      string              `json:"package,omitempty"`
+	Syntax       string              `json:"syntax"`
+	Loaded       bool                `json:"loaded"`
+	Dependencies []string            `json:"dependencies"`
+	Services     []DescriptorService `json:"services"`
+	Messages     []DescriptorMessage `json:"messages"`
+	Enums        []DescriptorEnum    `json:"enums"`
+}
+
+// DescriptorService is the JSON view of a service.
+type DescriptorService struct {
+	Name    string             `json:"name"`
+	Methods []DescriptorMethod `json:"methods"`
+}
+
+// DescriptorMethod is the JSON view of a method; Path is the method name
+// used by stubs and on the wire.
+type DescriptorMethod struct {
+	Name            string `json:"name"`
+	Path            string `json:"path"`
+	InputType       string `json:"input_type"`
+	OutputType      string `json:"output_type"`
+	ClientStreaming bool   `json:"client_streaming"`
+	ServerStreaming bool   `json:"server_streaming"`
+}
+
+// DescriptorMessage is the JSON view of a message, nested ones included in
+// the file's list under their full names.
+type DescriptorMessage struct {
+	Name   string            `json:"name"`
+	Fields []DescriptorField `json:"fields"`
+}
+
+// DescriptorField is the JSON view of a field. TypeName is the message or
+// enum it refers to; map fields carry their key and value types instead.
+type DescriptorField struct {
+	Name     string `json:"name"`
+	JSONName string `json:"json_name"`
+	Number   int32  `json:"number"`
+	Type     string `json:"type"`
+	TypeName string `json:"type_name,omitempty"`
+	Repeated bool   `json:"repeated,omitempty"`
+	Optional bool   `json:"optional,omitempty"`
+	Oneof    string `json:"oneof,omitempty"`
+	MapKey   string `json:"map_key,omitempty"`
+	MapValue string `json:"map_value,omitempty"`
+}
+
+// DescriptorEnum is the JSON view of an enum.
+type DescriptorEnum struct {
+	Name   string           `json:"name"`
+	Values map[string]int32 `json:"values"`
+}
+
+func describeFile(fd protoreflect.FileDescriptor, loaded bool) DescriptorFile {
+	file := DescriptorFile{
+		Name:         fd.Path(),
+		Package:      string(fd.Package()),
+		Syntax:       fd.Syntax().String(),
+		Loaded:       loaded,
+		Dependencies: []string{},
+		Services:     []DescriptorService{},
+		Messages:     []DescriptorMessage{},
+		Enums:        []DescriptorEnum{},
+	}
+	for i := 0; i < fd.Imports().Len(); i++ {
+		file.Dependencies = append(file.Dependencies, fd.Imports().Get(i).Path())
+	}
+	for i := 0; i < fd.Services().Len(); i++ {
+		sd := fd.Services().Get(i)
+		service := DescriptorService{Name: string(sd.FullName()), Methods: []DescriptorMethod{}}
+		for j := 0; j < sd.Methods().Len(); j++ {
+			md := sd.Methods().Get(j)
+			service.Methods = append(service.Methods, DescriptorMethod{
+				Name:            string(md.Name()),
+				Path:            "/" + string(sd.FullName()) + "/" + string(md.Name()),
+				InputType:       string(md.Input().FullName()),
+				OutputType:      string(md.Output().FullName()),
+				ClientStreaming: md.IsStreamingClient(),
+				ServerStreaming: md.IsStreamingServer(),
+			})
+		}
+		file.Services = append(file.Services, service)
+	}
+	file.Enums = appendEnums(file.Enums, fd.Enums())
+	file.Messages, file.Enums = appendMessages(file.Messages, file.Enums, fd.Messages())
+	return file
+}
+
+// appendMessages flattens the messages with their nested messages and
+// enums. Synthetic map entry messages are described by their fields.
+func appendMessages(messages []DescriptorMessage, enums []DescriptorEnum, mds protoreflect.MessageDescriptors) ([]DescriptorMessage, []DescriptorEnum) {
+	for i := 0; i < mds.Len(); i++ {
+		md := mds.Get(i)
+		if md.IsMapEntry() {
+			continue
+		}
+		message := DescriptorMessage{Name: string(md.FullName()), Fields: []DescriptorField{}}
+		for j := 0; j < md.Fields().Len(); j++ {
+			message.Fields = append(message.Fields, describeField(md.Fields().Get(j)))
+		}
+		messages = append(messages, message)
+		enums = appendEnums(enums, md.Enums())
+		messages, enums = appendMessages(messages, enums, md.Messages())
+	}
+	return messages, enums
+}
+
+func appendEnums(enums []DescriptorEnum, eds protoreflect.EnumDescriptors) []DescriptorEnum {
+	for i := 0; i < eds.Len(); i++ {
+		ed := eds.Get(i)
+		enum := DescriptorEnum{Name: string(ed.FullName()), Values: make(map[string]int32, ed.Values().Len())}
+		for j := 0; j < ed.Values().Len(); j++ {
+			value := ed.Values().Get(j)
+			enum.Values[string(value.Name())] = int32(value.Number())
+		}
+		enums = append(enums, enum)
+	}
+	return enums
+}
+
+func describeField(fd protoreflect.FieldDescriptor) DescriptorField {
+	field := DescriptorField{
+		Name:     string(fd.Name()),
+		JSONName: fd.JSONName(),
+		Number:   int32(fd.Number()),
+		Type:     fd.Kind().String(),
+		TypeName: typeName(fd),
+		Repeated: fd.IsList(),
+		Optional: fd.HasOptionalKeyword(),
+	}
+	if oneof := fd.ContainingOneof(); oneof != nil && !oneof.IsSynthetic() {
+		field.Oneof = string(oneof.Name())
+	}
+	if fd.IsMap() {
+		field.Type, field.TypeName = "map", ""
+		field.MapKey = fd.MapKey().Kind().String()
+		field.MapValue = fd.MapValue().Kind().String()
+		if name := typeName(fd.MapValue()); name != "" {
+			field.MapValue = name
+		}
+	}
+	return field
+}
+
+func typeName(fd protoreflect.FieldDescriptor) string {
+	switch {
+	case fd.Message() != nil:
+		return string(fd.Message().FullName())
+	case fd.Enum() != nil:
+		return string(fd.Enum().FullName())
+	}
+	return ""
+}
+
+func encodeDescriptorSetFailed(c echo.Context, err error) error {
+	return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+		"error":     "Failed to encode descriptor set",
+		"details":   err.Error(),
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+func invalidDescriptorSet(c echo.Context, err error) error {
+	return c.JSON(http.StatusBadRequest, map[string]interface{}{
+		"error":     "Invalid descriptor set",
+		"details":   err.Error(),
+		"timestamp": time.Now().Unix(),
+	})
+}
@@ -0,0 +1,51 @@
+package admin
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"mockserver/internal/panics"
+)
+
+// ErrorHandlers show the panics contained while serving requests and
+// connections, with their stack traces.
+type ErrorHandlers struct {
+	recorder *panics.Recorder
+}
+
+func NewErrorHandlers(recorder *panics.Recorder) *ErrorHandlers {
+	return &ErrorHandlers{recorder: recorder}
+}
+
+// List returns the recent panics, newest first. The protocol query
+// parameter filters them.
+func (h *ErrorHandlers) List(c echo.Context) error {
+	list := h.recorder.List(c.QueryParam("protocol"))
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"errors":    list,
+		"count":     len(list),
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// Get returns a panic by ID.
+func (h *ErrorHandlers) Get(c echo.Context) error {
+	p, ok := h.recorder.Get(c.Param("id"))
+	if !ok {
+		return c.JSON(http.StatusNotFound, map[string]interface{}{
+			"error":     "Unknown error",
+			"provided":  c.Param("id"),
+			"timestamp": time.Now().Unix(),
+		})
+	}
+	return c.JSON(http.StatusOK, p)
+}
+
+// Reset forgets the recent panics.
+func (h *ErrorHandlers) Reset(c echo.Context) error {
+	h.recorder.Reset()
+	logger.InfoContext(c.Request().Context(), "Errors cleared")
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,76 @@
+package admin
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"mockserver/internal/codegen"
+	grpcServer "mockserver/internal/grpc"
+	"mockserver/internal/har"
+	"mockserver/internal/journal"
+	"mockserver/internal/stubs"
+)
+
+// ExportHandlers turn the recorded session into test code.
+type ExportHandlers struct {
+	journal   *journal.Journal
+	engine    *stubs.Engine
+	grpcStubs *grpcServer.StubHandler
+}
+
+// NewExportHandlers creates the handlers; grpcStubs may be nil when the
+// server has no gRPC side.
+func NewExportHandlers(j *journal.Journal, engine *stubs.Engine, grpcStubs *grpcServer.StubHandler) *ExportHandlers {
+	return &ExportHandlers{journal: j, engine: engine, grpcStubs: grpcStubs}
+}
+
+// GoTest renders the current stubs and the recorded requests selected by the
+// journal filter parameters as a Go test using the library mode packages.
+// The package and test query parameters name the generated code.
+func (h *ExportHandlers) GoTest(c echo.Context) error {
+	filter, bad := requestFilter(c)
+	if bad != nil {
+		return invalidQuery(c, bad.name, bad.value)
+	}
+
+	session := codegen.Session{
+		HTTPStubs: h.engine.List(),
+		Requests:  h.journal.Find(filter),
+	}
+	if h.grpcStubs != nil {
+		session.GRPCStubs = h.grpcStubs.List()
+	}
+
+	source, err := codegen.GoTest(session, codegen.Options{
+		Package: c.QueryParam("package"),
+		Test:    c.QueryParam("test"),
+	})
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error":     "Failed to generate test",
+			"details":   err.Error(),
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="recorded_session_test.go"`)
+	return c.Blob(http.StatusOK, "text/x-go; charset=utf-8", source)
+}
+
+// HAR exports the recorded HTTP requests selected by the journal filter
+// parameters as a HAR file, for browser devtools and HAR viewers. The
+// requests the server received get URLs on the host this export was
+// requested from.
+func (h *ExportHandlers) HAR(c echo.Context) error {
+	filter, bad := requestFilter(c)
+	if bad != nil {
+		return invalidQuery(c, bad.name, bad.value)
+	}
+	filter.Protocol = journal.ProtocolHTTP
+
+	file := har.Export(h.journal.Find(filter), c.Scheme()+"://"+c.Request().Host)
+	c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="recorded_session.har"`)
+	return c.JSON(http.StatusOK, file)
+}
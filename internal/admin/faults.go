@@ -0,0 +1,140 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"mockserver/internal/faults"
+)
+
+// FaultHandlers manage the fault profiles, their schedules and their manual
+// activation.
+type FaultHandlers struct {
+	calendar *faults.Calendar
+}
+
+func NewFaultHandlers(calendar *faults.Calendar) *FaultHandlers {
+	return &FaultHandlers{calendar: calendar}
+}
+
+// Status lists the profiles, the schedules with their current or next
+// window, and the active profiles.
+func (h *FaultHandlers) Status(c echo.Context) error {
+	status := h.calendar.Status(time.Now())
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"profiles":  status.Profiles,
+		"schedules": status.Schedules,
+		"active":    status.Active,
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// SetProfile adds or replaces the profile with the given name.
+func (h *FaultHandlers) SetProfile(c echo.Context) error {
+	var profile faults.Profile
+	if err := c.Bind(&profile); err != nil {
+		return invalidFault(c, "Invalid profile payload", err)
+	}
+	name := c.Param("name")
+	if err := h.calendar.SetProfile(name, profile); err != nil {
+		return invalidFault(c, "Invalid profile", err)
+	}
+	logger.InfoContext(c.Request().Context(), "Fault profile saved", "profile", name)
+	return c.JSON(http.StatusOK, profile)
+}
+
+// DeleteProfile removes a profile that no schedule uses.
+func (h *FaultHandlers) DeleteProfile(c echo.Context) error {
+	name := c.Param("name")
+	if err := h.calendar.DeleteProfile(name); err != nil {
+		if errors.Is(err, faults.ErrUnknownProfile) {
+			return profileNotFound(c, name)
+		}
+		return c.JSON(http.StatusConflict, map[string]interface{}{
+			"error":     "Profile is used by a schedule, delete the schedule first",
+			"details":   err.Error(),
+			"timestamp": time.Now().Unix(),
+		})
+	}
+	logger.InfoContext(c.Request().Context(), "Fault profile deleted", "profile", name)
+	return c.NoContent(http.StatusNoContent)
+}
+
+// Activate switches a profile on, until it is deactivated or, with the
+// duration query parameter ("15m"), for that long.
+func (h *FaultHandlers) Activate(c echo.Context) error {
+	name := c.Param("name")
+	var duration time.Duration
+	if value := c.QueryParam("duration"); value != "" {
+		var err error
+		if duration, err = time.ParseDuration(value); err != nil || duration <= 0 {
+			return invalidQuery(c, "duration", value)
+		}
+	}
+	if err := h.calendar.Activate(name, duration); err != nil {
+		return profileNotFound(c, name)
+	}
+	logger.InfoContext(c.Request().Context(), "Fault profile activated", "profile", name)
+	return h.Status(c)
+}
+
+// Deactivate ends the manual activation of a profile.
+func (h *FaultHandlers) Deactivate(c echo.Context) error {
+	name := c.Param("name")
+	if !h.calendar.Deactivate(name) {
+		return c.JSON(http.StatusNotFound, map[string]interface{}{
+			"error":     "Profile is not activated manually",
+			"provided":  name,
+			"timestamp": time.Now().Unix(),
+		})
+	}
+	logger.InfoContext(c.Request().Context(), "Fault profile deactivated", "profile", name)
+	return c.NoContent(http.StatusNoContent)
+}
+
+// AddSchedule adds a window during which a profile is active.
+func (h *FaultHandlers) AddSchedule(c echo.Context) error {
+	var schedule faults.Schedule
+	if err := c.Bind(&schedule); err != nil {
+		return invalidFault(c, "Invalid schedule payload", err)
+	}
+	schedule, err := h.calendar.AddSchedule(schedule)
+	if err != nil {
+		return invalidFault(c, "Invalid schedule", err)
+	}
+	logger.InfoContext(c.Request().Context(), "Fault profile scheduled", "profile", schedule.Profile, "schedule_id", schedule.ID)
+	return c.JSON(http.StatusCreated, schedule)
+}
+
+// DeleteSchedule removes a schedule.
+func (h *FaultHandlers) DeleteSchedule(c echo.Context) error {
+	id := c.Param("id")
+	if !h.calendar.DeleteSchedule(id) {
+		return c.JSON(http.StatusNotFound, map[string]interface{}{
+			"error":     "Schedule not found",
+			"provided":  id,
+			"timestamp": time.Now().Unix(),
+		})
+	}
+	logger.InfoContext(c.Request().Context(), "Fault schedule deleted", "schedule_id", id)
+	return c.NoContent(http.StatusNoContent)
+}
+
+func invalidFault(c echo.Context, message string, err error) error {
+	return c.JSON(http.StatusBadRequest, map[string]interface{}{
+		"error":     message,
+		"details":   err.Error(),
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+func profileNotFound(c echo.Context, name string) error {
+	return c.JSON(http.StatusNotFound, map[string]interface{}{
+		"error":     "Fault profile not found",
+		"provided":  name,
+		"timestamp": time.Now().Unix(),
+	})
+}
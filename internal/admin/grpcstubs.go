@@ -0,0 +1,99 @@
+package admin
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	grpcServer "mockserver/internal/grpc"
+)
+
+// maxTranscript caps the size of an imported transcript.
+const maxTranscript = 16 * 1024 * 1024
+
+// GRPCStubHandlers list the gRPC stubs and import them from transcripts of
+// calls made with client tools.
+type GRPCStubHandlers struct {
+	stubs *grpcServer.StubHandler
+}
+
+func NewGRPCStubHandlers(stubs *grpcServer.StubHandler) *GRPCStubHandlers {
+	return &GRPCStubHandlers{stubs: stubs}
+}
+
+// List returns the registered gRPC stubs, grouped by method.
+func (h *GRPCStubHandlers) List(c echo.Context) error {
+	stubs := h.stubs.List()
+	if stubs == nil {
+		stubs = []grpcServer.Stub{}
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"stubs":     stubs,
+		"count":     len(stubs),
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// Import converts a transcript (JSON calls, or grpcurl output with the
+// method query parameter) into stubs and registers them, unless dry_run is
+// set. Calls that cannot be converted are reported in skipped.
+func (h *GRPCStubHandlers) Import(c echo.Context) error {
+	dryRun := false
+	if value := c.QueryParam("dry_run"); value != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(value); err != nil {
+			return invalidQuery(c, "dry_run", value)
+		}
+	}
+	data, err := io.ReadAll(io.LimitReader(c.Request().Body, maxTranscript))
+	if err != nil {
+		return invalidTranscript(c, err.Error())
+	}
+	calls, err := grpcServer.ParseTranscript(data, c.QueryParam("method"))
+	if err != nil {
+		return invalidTranscript(c, err.Error())
+	}
+
+	stubs, skipped := h.stubs.TranscriptStubs(calls)
+	if skipped == nil {
+		skipped = []string{}
+	}
+	if len(stubs) == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error":     "No call of the transcript could be converted",
+			"skipped":   skipped,
+			"timestamp": time.Now().Unix(),
+		})
+	}
+	if !dryRun {
+		added, err := h.stubs.Swap(nil, stubs)
+		if err != nil {
+			return invalidTranscript(c, err.Error())
+		}
+		stubs = added
+		logger.InfoContext(c.Request().Context(), "gRPC stubs imported from a transcript", "stubs", len(stubs), "skipped", len(skipped))
+	}
+
+	status := http.StatusCreated
+	if dryRun {
+		status = http.StatusOK
+	}
+	return c.JSON(status, map[string]interface{}{
+		"stubs":     stubs,
+		"skipped":   skipped,
+		"count":     len(stubs),
+		"dry_run":   dryRun,
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+func invalidTranscript(c echo.Context, details string) error {
+	return c.JSON(http.StatusBadRequest, map[string]interface{}{
+		"error":     "Invalid transcript",
+		"details":   details,
+		"timestamp": time.Now().Unix(),
+	})
+}
@@ -0,0 +1,161 @@
+// Package admin implements the /__admin control API used by tests to drive the
+// mock server at runtime.
+package admin
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"mockserver/internal/correlation"
+	"mockserver/internal/events"
+	grpcServer "mockserver/internal/grpc"
+	"mockserver/internal/logging"
+)
+
+var logger = logging.For("admin")
+
+type AdminHandlers struct {
+	bus      *events.Bus
+	health   *grpcServer.HealthController
+	attempts *grpcServer.AttemptTracker
+}
+
+func NewAdminHandlers(bus *events.Bus, health *grpcServer.HealthController, attempts *grpcServer.AttemptTracker) *AdminHandlers {
+	return &AdminHandlers{bus: bus, health: health, attempts: attempts}
+}
+
+type publishRequest struct {
+	Topic         string      `json:"topic"`
+	Type          string      `json:"type"`
+	Data          interface{} `json:"data"`
+	CorrelationID string      `json:"correlation_id"`
+}
+
+// PublishEvent pushes a test-driven event onto the event bus, e.g. for gRPC
+// Subscribe clients. The event carries the correlation ID of the body or, by
+// default, of the request headers.
+func (h *AdminHandlers) PublishEvent(c echo.Context) error {
+	var req publishRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error":     "Invalid event payload",
+			"details":   err.Error(),
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	if req.Topic == "" {
+		req.Topic = "admin"
+	}
+	if req.Type == "" {
+		req.Type = "push"
+	}
+	if req.CorrelationID == "" {
+		req.CorrelationID = correlation.FromHeader(c.Request().Header)
+	}
+
+	event := h.bus.Publish(events.Event{
+		Topic:         req.Topic,
+		Type:          req.Type,
+		Source:        "admin",
+		Data:          req.Data,
+		CorrelationID: req.CorrelationID,
+	})
+	logger.InfoContext(c.Request().Context(), "Event published", "event_id", event.ID, "topic", event.Topic)
+
+	return c.JSON(http.StatusAccepted, event)
+}
+
+// GRPCHealth lists the serving status of every gRPC health service.
+func (h *AdminHandlers) GRPCHealth(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"services":  h.health.Statuses(),
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+type healthRequest struct {
+	Service string `json:"service"`
+	Status  string `json:"status"`
+}
+
+// SetGRPCHealth flips a service between SERVING and NOT_SERVING. An empty
+// service name targets the overall server status.
+func (h *AdminHandlers) SetGRPCHealth(c echo.Context) error {
+	var req healthRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error":     "Invalid health payload",
+			"details":   err.Error(),
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	status, err := grpcServer.ParseServingStatus(req.Status)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error":     "Invalid status. Must be SERVING, NOT_SERVING or SERVICE_UNKNOWN",
+			"provided":  req.Status,
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	h.health.Set(req.Service, status)
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"service":   req.Service,
+		"status":    status.String(),
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// GRPCServiceConfig returns a service config with a retry (default) or hedging
+// policy to feed into grpc.WithDefaultServiceConfig when testing against the
+// mock-fail-sequence metadata.
+func (h *AdminHandlers) GRPCServiceConfig(c echo.Context) error {
+	service := c.QueryParam("service")
+	if service == "" {
+		service = "mock.MockService"
+	}
+
+	maxAttempts := 4
+	if value := c.QueryParam("max_attempts"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"error":     "Invalid max_attempts parameter",
+				"provided":  value,
+				"timestamp": time.Now().Unix(),
+			})
+		}
+		maxAttempts = n
+	}
+
+	serviceConfig, err := grpcServer.ServiceConfig(service, c.QueryParam("policy"), maxAttempts)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error":     err.Error(),
+			"timestamp": time.Now().Unix(),
+		})
+	}
+	return c.JSON(http.StatusOK, serviceConfig)
+}
+
+// GRPCAttempts lists the attempt counters kept per mock-request-id.
+func (h *AdminHandlers) GRPCAttempts(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"attempts":  h.attempts.Counts(),
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// ResetGRPCAttempts clears attempt counters, all of them unless a key
+// ("<method>|<request id>") is given.
+func (h *AdminHandlers) ResetGRPCAttempts(c echo.Context) error {
+	key := c.QueryParam("key")
+	h.attempts.Reset(key)
+	logger.InfoContext(c.Request().Context(), "gRPC attempt counters reset", "key", key)
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,50 @@
+package admin
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"mockserver/internal/stubs"
+)
+
+// HTTPRecordingHandlers expose the requests recorded by the HTTP proxy mode.
+type HTTPRecordingHandlers struct {
+	recorder *stubs.Recorder
+}
+
+func NewHTTPRecordingHandlers(recorder *stubs.Recorder) *HTTPRecordingHandlers {
+	return &HTTPRecordingHandlers{recorder: recorder}
+}
+
+// List returns the recorded requests and responses, oldest first.
+func (h *HTTPRecordingHandlers) List(c echo.Context) error {
+	recordings := h.recorder.Recordings()
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"recordings": recordings,
+		"count":      len(recordings),
+		"timestamp":  time.Now().Unix(),
+	})
+}
+
+// Reset drops every recording.
+func (h *HTTPRecordingHandlers) Reset(c echo.Context) error {
+	h.recorder.ResetRecordings()
+	return c.NoContent(http.StatusNoContent)
+}
+
+// Stubs converts the recordings into HTTP stubs, ready to be pasted into the
+// "http.stubs" section of the configuration file for offline runs.
+func (h *HTTPRecordingHandlers) Stubs(c echo.Context) error {
+	list, skipped := h.recorder.Stubs()
+	if list == nil {
+		list = []stubs.Stub{}
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"stubs":     list,
+		"skipped":   skipped,
+		"count":     len(list),
+		"timestamp": time.Now().Unix(),
+	})
+}
@@ -0,0 +1,35 @@
+package admin
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"mockserver/internal/jobs"
+)
+
+// JobHandlers inspect and clear the jobs of the async job API.
+type JobHandlers struct {
+	jobs *jobs.Manager
+}
+
+func NewJobHandlers(manager *jobs.Manager) *JobHandlers {
+	return &JobHandlers{jobs: manager}
+}
+
+// List returns every job with its webhook delivery, oldest first.
+func (h *JobHandlers) List(c echo.Context) error {
+	list := h.jobs.Jobs()
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"jobs":      list,
+		"count":     len(list),
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// Reset forgets every job.
+func (h *JobHandlers) Reset(c echo.Context) error {
+	h.jobs.Reset()
+	return c.NoContent(http.StatusNoContent)
+}
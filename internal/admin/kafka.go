@@ -0,0 +1,145 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"mockserver/internal/kafka"
+)
+
+// KafkaHandlers inspect and feed the topics of the Kafka broker.
+type KafkaHandlers struct {
+	broker *kafka.Broker
+}
+
+func NewKafkaHandlers(broker *kafka.Broker) *KafkaHandlers {
+	return &KafkaHandlers{broker: broker}
+}
+
+// Topics lists the topics with their high watermarks.
+func (h *KafkaHandlers) Topics(c echo.Context) error {
+	topics := h.broker.Topics()
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"topics":    topics,
+		"count":     len(topics),
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// Records lists the records of a topic, optionally filtered by partition,
+// key and source, so that tests can assert what was produced.
+func (h *KafkaHandlers) Records(c echo.Context) error {
+	var filter kafka.RecordFilter
+	if value := c.QueryParam("partition"); value != "" {
+		partition, err := strconv.ParseInt(value, 10, 32)
+		if err != nil || partition < 0 {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"error":     "Invalid partition",
+				"provided":  value,
+				"timestamp": time.Now().Unix(),
+			})
+		}
+		p := int32(partition)
+		filter.Partition = &p
+	}
+	if c.QueryParams().Has("key") {
+		key := c.QueryParam("key")
+		filter.Key = &key
+	}
+	filter.Source = c.QueryParam("source")
+	switch filter.Source {
+	case "", kafka.SourceProducer, kafka.SourceAdmin:
+	default:
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error":     "Invalid source (want producer or admin)",
+			"provided":  filter.Source,
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	topic := c.Param("topic")
+	records, err := h.broker.Records(topic, filter)
+	if err != nil {
+		return h.topicError(c, topic, err)
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"topic":     topic,
+		"records":   records,
+		"count":     len(records),
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// Inject appends records to a topic for consumers to fetch.
+func (h *KafkaHandlers) Inject(c echo.Context) error {
+	var payload struct {
+		Records []kafka.InjectedRecord `json:"records"`
+	}
+	if err := c.Bind(&payload); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error":     "Invalid Kafka records payload",
+			"details":   err.Error(),
+			"timestamp": time.Now().Unix(),
+		})
+	}
+	topic := c.Param("topic")
+	records, err := h.broker.Inject(topic, payload.Records)
+	if err != nil {
+		return h.topicError(c, topic, err)
+	}
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+		"topic":     topic,
+		"records":   records,
+		"count":     len(records),
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// ResetRecords empties every topic and forgets the committed offsets.
+func (h *KafkaHandlers) ResetRecords(c echo.Context) error {
+	h.broker.ResetRecords()
+	return c.NoContent(http.StatusNoContent)
+}
+
+// Groups lists the consumer groups with their members, assignments and
+// committed offsets.
+func (h *KafkaHandlers) Groups(c echo.Context) error {
+	groups := h.broker.Groups()
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"groups":    groups,
+		"count":     len(groups),
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// Group describes a consumer group.
+func (h *KafkaHandlers) Group(c echo.Context) error {
+	group, ok := h.broker.Group(c.Param("group"))
+	if !ok {
+		return c.JSON(http.StatusNotFound, map[string]interface{}{
+			"error":     "Unknown Kafka consumer group",
+			"provided":  c.Param("group"),
+			"timestamp": time.Now().Unix(),
+		})
+	}
+	return c.JSON(http.StatusOK, group)
+}
+
+func (h *KafkaHandlers) topicError(c echo.Context, topic string, err error) error {
+	if errors.Is(err, kafka.ErrUnknownTopic) {
+		return c.JSON(http.StatusNotFound, map[string]interface{}{
+			"error":     "Unknown Kafka topic",
+			"provided":  topic,
+			"timestamp": time.Now().Unix(),
+		})
+	}
+	return c.JSON(http.StatusBadRequest, map[string]interface{}{
+		"error":     "Invalid Kafka records",
+		"details":   err.Error(),
+		"timestamp": time.Now().Unix(),
+	})
+}
@@ -0,0 +1,49 @@
+package admin
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"mockserver/internal/jose"
+)
+
+// KeyHandlers publish the keys used by the jws and jwe stub helpers.
+type KeyHandlers struct {
+	keys *jose.KeySet
+}
+
+func NewKeyHandlers(keys *jose.KeySet) *KeyHandlers {
+	return &KeyHandlers{keys: keys}
+}
+
+// JWKS serves the public keys as a JWK set, the way providers publish them
+// at /.well-known/jwks.json.
+func (h *KeyHandlers) JWKS(c echo.Context) error {
+	return c.JSON(http.StatusOK, h.keys.JWKS())
+}
+
+// List describes the configured and generated keys.
+func (h *KeyHandlers) List(c echo.Context) error {
+	keys := h.keys.Keys()
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"keys":      keys,
+		"count":     len(keys),
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// PrivateKey returns the PEM private key of a generated key, for tests that
+// decrypt payloads encrypted for it.
+func (h *KeyHandlers) PrivateKey(c echo.Context) error {
+	data, err := h.keys.PrivateKeyPEM(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]interface{}{
+			"error":     "Key not available",
+			"details":   err.Error(),
+			"timestamp": time.Now().Unix(),
+		})
+	}
+	return c.Blob(http.StatusOK, "application/x-pem-file", data)
+}
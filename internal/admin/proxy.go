@@ -0,0 +1,67 @@
+package admin
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"mockserver/internal/proxy"
+)
+
+// ProxyHandlers expose the state of the proxy listeners. Either of them may
+// be nil when the corresponding listener is disabled.
+type ProxyHandlers struct {
+	front    *proxy.Front
+	tunneler *proxy.Tunneler
+}
+
+func NewProxyHandlers(front *proxy.Front, tunneler *proxy.Tunneler) *ProxyHandlers {
+	return &ProxyHandlers{front: front, tunneler: tunneler}
+}
+
+// FrontCA returns the PEM certificate of the CA the fronting listener
+// re-encrypts traffic with, for clients that should trust it.
+func (h *ProxyHandlers) FrontCA(c echo.Context) error {
+	if h.front == nil || h.front.CA() == nil {
+		return c.JSON(http.StatusNotFound, map[string]interface{}{
+			"error":     "Fronting proxy TLS is not enabled",
+			"timestamp": time.Now().Unix(),
+		})
+	}
+	return c.Blob(http.StatusOK, "application/x-pem-file", h.front.CA().PEM())
+}
+
+// TunnelCA returns the PEM certificate of the CA used to intercept TLS
+// tunneled to the mock's own listeners.
+func (h *ProxyHandlers) TunnelCA(c echo.Context) error {
+	if h.tunneler == nil {
+		return c.JSON(http.StatusNotFound, map[string]interface{}{
+			"error":     "Proxy listener is not enabled",
+			"timestamp": time.Now().Unix(),
+		})
+	}
+	return c.Blob(http.StatusOK, "application/x-pem-file", h.tunneler.CA().PEM())
+}
+
+// Captures lists the most recent tunnels and proxied requests.
+func (h *ProxyHandlers) Captures(c echo.Context) error {
+	captures := []proxy.Capture{}
+	if h.tunneler != nil {
+		captures = h.tunneler.Captures()
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"captures":  captures,
+		"count":     len(captures),
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// ResetCaptures clears the capture log.
+func (h *ProxyHandlers) ResetCaptures(c echo.Context) error {
+	if h.tunneler != nil {
+		h.tunneler.ResetCaptures()
+	}
+	logger.InfoContext(c.Request().Context(), "Proxy captures cleared")
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,49 @@
+package admin
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"mockserver/internal/queue"
+)
+
+// QueueHandlers inspect and clear the queues of the message queue.
+type QueueHandlers struct {
+	broker *queue.Broker
+}
+
+func NewQueueHandlers(broker *queue.Broker) *QueueHandlers {
+	return &QueueHandlers{broker: broker}
+}
+
+// List returns the queues with their message counts and counters.
+func (h *QueueHandlers) List(c echo.Context) error {
+	queues := h.broker.Queues()
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"queues":    queues,
+		"count":     len(queues),
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// Get returns a queue with its messages, in flight and delayed ones
+// included.
+func (h *QueueHandlers) Get(c echo.Context) error {
+	q, ok := h.broker.Queue(c.Param("name"))
+	if !ok {
+		return c.JSON(http.StatusNotFound, map[string]interface{}{
+			"error":     "Queue not found",
+			"provided":  c.Param("name"),
+			"timestamp": time.Now().Unix(),
+		})
+	}
+	return c.JSON(http.StatusOK, q)
+}
+
+// Reset deletes every message and the queues created at runtime.
+func (h *QueueHandlers) Reset(c echo.Context) error {
+	h.broker.Reset()
+	return c.NoContent(http.StatusNoContent)
+}
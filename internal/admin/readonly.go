@@ -0,0 +1,86 @@
+package admin
+
+import (
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// ReadOnlyPath is the switch of the read-only mode, the one admin path that
+// stays writable while it is on.
+const ReadOnlyPath = "/__admin/read-only"
+
+// ReadOnly is the read-only mode switch. While it is on, every admin request
+// that changes the server (any method but GET, HEAD and OPTIONS) is rejected
+// with 403, which protects a curated demo or staging dataset from test
+// suites.
+type ReadOnly struct {
+	enabled atomic.Bool
+}
+
+func NewReadOnly(enabled bool) *ReadOnly {
+	r := &ReadOnly{}
+	r.enabled.Store(enabled)
+	return r
+}
+
+// Middleware rejects the mutating admin requests while the mode is on.
+func (r *ReadOnly) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			if !r.enabled.Load() || !strings.HasPrefix(req.URL.Path, "/__admin") || req.URL.Path == ReadOnlyPath {
+				return next(c)
+			}
+			switch req.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				return next(c)
+			}
+			logger.InfoContext(req.Context(), "Change rejected in read-only mode")
+			return c.JSON(http.StatusForbidden, map[string]interface{}{
+				"error":     "Server is in read-only mode",
+				"details":   "PUT " + ReadOnlyPath + ` with {"read_only": false} to allow changes`,
+				"timestamp": time.Now().Unix(),
+			})
+		}
+	}
+}
+
+type readOnlyState struct {
+	ReadOnly *bool `json:"read_only"`
+}
+
+// Get reports whether the mode is on.
+func (r *ReadOnly) Get(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"read_only": r.enabled.Load(),
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// Set switches the mode on or off.
+func (r *ReadOnly) Set(c echo.Context) error {
+	var state readOnlyState
+	if err := c.Bind(&state); err != nil || state.ReadOnly == nil {
+		details := "read_only is required"
+		if err != nil {
+			details = err.Error()
+		}
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error":     "Invalid read-only payload",
+			"details":   details,
+			"timestamp": time.Now().Unix(),
+		})
+	}
+	if r.enabled.Swap(*state.ReadOnly) != *state.ReadOnly {
+		if *state.ReadOnly {
+			logger.InfoContext(c.Request().Context(), "Read-only mode on")
+		} else {
+			logger.InfoContext(c.Request().Context(), "Read-only mode off")
+		}
+	}
+	return r.Get(c)
+}
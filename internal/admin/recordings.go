@@ -0,0 +1,70 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	grpcServer "mockserver/internal/grpc"
+)
+
+// RecordingHandlers expose the calls recorded by the gRPC proxy mode.
+type RecordingHandlers struct {
+	proxy *grpcServer.Proxy
+}
+
+func NewRecordingHandlers(proxy *grpcServer.Proxy) *RecordingHandlers {
+	return &RecordingHandlers{proxy: proxy}
+}
+
+type recordingView struct {
+	grpcServer.Recording
+	// RequestBodies and ResponseBodies are the messages as JSON, present
+	// when the method's descriptors are loaded.
+	RequestBodies  []json.RawMessage `json:"request_bodies,omitempty"`
+	ResponseBodies []json.RawMessage `json:"response_bodies,omitempty"`
+}
+
+// List returns the recorded calls with the raw messages base64 encoded and,
+// where possible, decoded to JSON.
+func (h *RecordingHandlers) List(c echo.Context) error {
+	recordings := h.proxy.Recordings()
+	views := make([]recordingView, 0, len(recordings))
+	for _, recording := range recordings {
+		view := recordingView{Recording: recording}
+		if requests, responses, err := h.proxy.Decode(recording); err == nil {
+			view.RequestBodies = requests
+			view.ResponseBodies = responses
+		}
+		views = append(views, view)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"recordings": views,
+		"count":      len(views),
+		"timestamp":  time.Now().Unix(),
+	})
+}
+
+// Reset drops every recorded call.
+func (h *RecordingHandlers) Reset(c echo.Context) error {
+	h.proxy.ResetRecordings()
+	return c.NoContent(http.StatusNoContent)
+}
+
+// Stubs converts the recordings into gRPC stubs, ready to be pasted into the
+// "grpc.stubs" section of the configuration file for offline runs.
+func (h *RecordingHandlers) Stubs(c echo.Context) error {
+	stubs, skipped := h.proxy.Stubs()
+	if stubs == nil {
+		stubs = []grpcServer.Stub{}
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"stubs":     stubs,
+		"skipped":   skipped,
+		"count":     len(stubs),
+		"timestamp": time.Now().Unix(),
+	})
+}
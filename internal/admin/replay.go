@@ -0,0 +1,131 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"mockserver/internal/replay"
+)
+
+// ReplayHandlers replay recorded HTTP traffic against a target.
+type ReplayHandlers struct {
+	replayer *replay.Replayer
+}
+
+func NewReplayHandlers(replayer *replay.Replayer) *ReplayHandlers {
+	return &ReplayHandlers{replayer: replayer}
+}
+
+// Start replays the journal entries selected by the body in the background
+// and answers 202 with the run, to poll at /__admin/replay/:id.
+func (h *ReplayHandlers) Start(c echo.Context) error {
+	var req replay.Request
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error":     "Invalid replay request",
+			"details":   err.Error(),
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	run, err := h.replayer.Start(req)
+	if errors.Is(err, replay.ErrNothingToReplay) {
+		return c.JSON(http.StatusNotFound, map[string]interface{}{
+			"error":     "Nothing to replay",
+			"details":   err.Error(),
+			"timestamp": time.Now().Unix(),
+		})
+	}
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error":     "Invalid replay request",
+			"details":   err.Error(),
+			"timestamp": time.Now().Unix(),
+		})
+	}
+	c.Response().Header().Set(echo.HeaderLocation, "/__admin/replay/"+run.ID)
+	return c.JSON(http.StatusAccepted, run)
+}
+
+// List returns the recent runs, oldest first.
+func (h *ReplayHandlers) List(c echo.Context) error {
+	runs := h.replayer.Runs()
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"runs":      runs,
+		"count":     len(runs),
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// Get returns a run with the result of every request.
+func (h *ReplayHandlers) Get(c echo.Context) error {
+	run, ok := h.replayer.Get(c.Param("id"))
+	if !ok {
+		return replayNotFound(c)
+	}
+	return c.JSON(http.StatusOK, run)
+}
+
+// Cancel stops a run; the requests not sent yet are skipped.
+func (h *ReplayHandlers) Cancel(c echo.Context) error {
+	run, ok := h.replayer.Cancel(c.Param("id"))
+	if !ok {
+		return replayNotFound(c)
+	}
+	return c.JSON(http.StatusOK, run)
+}
+
+// Diff compares the recorded responses with those of the replay target.
+// The headers and ignore query parameters list the response headers compared
+// and the JSON paths left out, comma-separated; format=html renders the
+// report as a page.
+func (h *ReplayHandlers) Diff(c echo.Context) error {
+	opts := replay.DiffOptions{
+		Headers: splitList(c.QueryParam("headers")),
+		Ignore:  splitList(c.QueryParam("ignore")),
+	}
+	report, ok := h.replayer.Diff(c.Param("id"), opts)
+	if !ok {
+		return replayNotFound(c)
+	}
+
+	switch format := c.QueryParam("format"); format {
+	case "", "json":
+		return c.JSON(http.StatusOK, report)
+	case "html":
+		page, err := report.HTML()
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+				"error":     "Failed to render diff",
+				"details":   err.Error(),
+				"timestamp": time.Now().Unix(),
+			})
+		}
+		return c.HTMLBlob(http.StatusOK, page)
+	default:
+		return invalidQuery(c, "format", format)
+	}
+}
+
+func replayNotFound(c echo.Context) error {
+	return c.JSON(http.StatusNotFound, map[string]interface{}{
+		"error":     "Replay not found",
+		"provided":  c.Param("id"),
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// splitList splits a comma-separated query parameter, dropping empty items.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,172 @@
+package admin
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"mockserver/internal/journal"
+)
+
+// RequestHandlers expose the request journal shared by HTTP and gRPC.
+type RequestHandlers struct {
+	journal *journal.Journal
+}
+
+func NewRequestHandlers(j *journal.Journal) *RequestHandlers {
+	return &RequestHandlers{journal: j}
+}
+
+// List returns recorded requests, filtered by the protocol, method, path,
+// status, code, correlation_id, stub_id, since and until (RFC 3339, until
+// excluded) and limit query parameters.
+func (h *RequestHandlers) List(c echo.Context) error {
+	filter, bad := requestFilter(c)
+	if bad != nil {
+		return invalidQuery(c, bad.name, bad.value)
+	}
+
+	entries := h.journal.Find(filter)
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"requests":  entries,
+		"count":     len(entries),
+		"dropped":   h.journal.Stats().Dropped,
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// Get returns a single recorded request.
+func (h *RequestHandlers) Get(c echo.Context) error {
+	entry, ok := h.journal.Get(c.Param("id"))
+	if !ok {
+		return c.JSON(http.StatusNotFound, map[string]interface{}{
+			"error":     "Request not found",
+			"provided":  c.Param("id"),
+			"timestamp": time.Now().Unix(),
+		})
+	}
+	return c.JSON(http.StatusOK, entry)
+}
+
+// Correlations lists the correlation IDs of the recorded requests with a
+// summary of each, most recently active last, filtered like List.
+func (h *RequestHandlers) Correlations(c echo.Context) error {
+	filter, bad := requestFilter(c)
+	if bad != nil {
+		return invalidQuery(c, bad.name, bad.value)
+	}
+
+	correlations := h.journal.Correlations(filter)
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"correlations": correlations,
+		"count":        len(correlations),
+		"timestamp":    time.Now().Unix(),
+	})
+}
+
+// Correlated returns the unified view of a correlation ID: the gRPC calls,
+// HTTP requests and events it ties together, and their timeline.
+func (h *RequestHandlers) Correlated(c echo.Context) error {
+	view, ok := h.journal.Correlate(c.Param("correlation_id"))
+	if !ok {
+		return c.JSON(http.StatusNotFound, map[string]interface{}{
+			"error":     "No request with this correlation ID",
+			"provided":  c.Param("correlation_id"),
+			"timestamp": time.Now().Unix(),
+		})
+	}
+	return c.JSON(http.StatusOK, view)
+}
+
+// RequestCorrelated returns the unified view of the correlation ID of a
+// recorded request.
+func (h *RequestHandlers) RequestCorrelated(c echo.Context) error {
+	entry, ok := h.journal.Get(c.Param("id"))
+	if !ok {
+		return c.JSON(http.StatusNotFound, map[string]interface{}{
+			"error":     "Request not found",
+			"provided":  c.Param("id"),
+			"timestamp": time.Now().Unix(),
+		})
+	}
+	view, ok := h.journal.Correlate(entry.CorrelationID)
+	if !ok {
+		return c.JSON(http.StatusNotFound, map[string]interface{}{
+			"error":     "Request has no correlation ID",
+			"provided":  c.Param("id"),
+			"timestamp": time.Now().Unix(),
+		})
+	}
+	return c.JSON(http.StatusOK, view)
+}
+
+// Stats reports the journal size and the write buffer counters: entries
+// still pending and entries dropped because the buffer was saturated.
+func (h *RequestHandlers) Stats(c echo.Context) error {
+	return c.JSON(http.StatusOK, h.journal.Stats())
+}
+
+// Reset clears the journal.
+func (h *RequestHandlers) Reset(c echo.Context) error {
+	h.journal.Reset()
+	logger.InfoContext(c.Request().Context(), "Request journal cleared")
+	return c.NoContent(http.StatusNoContent)
+}
+
+// queryParam is a query parameter with an invalid value.
+type queryParam struct {
+	name, value string
+}
+
+// requestFilter reads the journal filter from the query parameters, returning
+// the offending parameter when one is invalid.
+func requestFilter(c echo.Context) (journal.Filter, *queryParam) {
+	filter := journal.Filter{
+		Protocol:      c.QueryParam("protocol"),
+		Method:        c.QueryParam("method"),
+		Path:          c.QueryParam("path"),
+		Code:          c.QueryParam("code"),
+		CorrelationID: c.QueryParam("correlation_id"),
+		StubID:        c.QueryParam("stub_id"),
+	}
+
+	if value := c.QueryParam("status"); value != "" {
+		status, err := strconv.Atoi(value)
+		if err != nil {
+			return filter, &queryParam{"status", value}
+		}
+		filter.Status = status
+	}
+	if value := c.QueryParam("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 0 {
+			return filter, &queryParam{"limit", value}
+		}
+		filter.Limit = limit
+	}
+	if value := c.QueryParam("since"); value != "" {
+		since, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return filter, &queryParam{"since", value}
+		}
+		filter.Since = since
+	}
+	if value := c.QueryParam("until"); value != "" {
+		until, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return filter, &queryParam{"until", value}
+		}
+		filter.Until = until
+	}
+	return filter, nil
+}
+
+func invalidQuery(c echo.Context, name, value string) error {
+	return c.JSON(http.StatusBadRequest, map[string]interface{}{
+		"error":     "Invalid " + name + " query parameter",
+		"provided":  value,
+		"timestamp": time.Now().Unix(),
+	})
+}
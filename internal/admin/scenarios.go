@@ -0,0 +1,106 @@
+package admin
+
+import (
+	"io"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"mockserver/internal/asyncapi"
+)
+
+// maxAsyncAPIDocument bounds the AsyncAPI documents accepted for import.
+const maxAsyncAPIDocument = 4 << 20
+
+// Scenarios returns the WebSocket scenarios by name.
+func (h *WebSocketHandlers) Scenarios(c echo.Context) error {
+	scenarios := h.ws.Scenarios()
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"scenarios": scenarios,
+		"count":     len(scenarios),
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// DeleteScenario removes a scenario.
+func (h *WebSocketHandlers) DeleteScenario(c echo.Context) error {
+	if !h.ws.RemoveScenario(c.Param("name")) {
+		return c.JSON(http.StatusNotFound, map[string]interface{}{
+			"error":     "Unknown WebSocket scenario",
+			"provided":  c.Param("name"),
+			"timestamp": time.Now().Unix(),
+		})
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ExportAsyncAPI describes the scenarios as an AsyncAPI 3.0 document, in
+// JSON or with format=yaml in YAML.
+func (h *WebSocketHandlers) ExportAsyncAPI(c echo.Context) error {
+	doc := asyncapi.Export(h.ws.Scenarios(), c.Request().Host)
+	switch format := c.QueryParam("format"); format {
+	case "", "json":
+		return c.JSON(http.StatusOK, doc)
+	case "yaml", "yml":
+		data, err := asyncapi.YAML(doc)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+				"error":     "Failed to encode AsyncAPI document",
+				"details":   err.Error(),
+				"timestamp": time.Now().Unix(),
+			})
+		}
+		return c.Blob(http.StatusOK, "application/yaml", data)
+	default:
+		return invalidQuery(c, "format", format)
+	}
+}
+
+// ImportAsyncAPI scaffolds scenarios from the AsyncAPI document (JSON or
+// YAML) of the request body, replacing scenarios with the same name, and
+// reports them along with what could not be imported.
+func (h *WebSocketHandlers) ImportAsyncAPI(c echo.Context) error {
+	data, err := io.ReadAll(io.LimitReader(c.Request().Body, maxAsyncAPIDocument+1))
+	if err != nil || len(data) > maxAsyncAPIDocument {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error":     "Invalid AsyncAPI document",
+			"details":   "the document must be at most 4 MiB",
+			"timestamp": time.Now().Unix(),
+		})
+	}
+	result, err := asyncapi.Import(data)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error":     "Invalid AsyncAPI document",
+			"details":   err.Error(),
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	names := make([]string, 0, len(result.Scenarios))
+	for name := range result.Scenarios {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	imported := []string{}
+	for _, name := range names {
+		if err := h.ws.SetScenario(name, result.Scenarios[name]); err != nil {
+			result.Warnings = append(result.Warnings, err.Error())
+			delete(result.Scenarios, name)
+			continue
+		}
+		imported = append(imported, name)
+	}
+	if result.Warnings == nil {
+		result.Warnings = []string{}
+	}
+	logger.InfoContext(c.Request().Context(), "WebSocket scenarios imported from AsyncAPI", "scenarios", len(imported))
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"imported":  imported,
+		"scenarios": result.Scenarios,
+		"warnings":  result.Warnings,
+		"timestamp": time.Now().Unix(),
+	})
+}
@@ -0,0 +1,51 @@
+package admin
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"mockserver/internal/selftest"
+)
+
+// selfTestTimeout bounds a self-test run started from the admin API.
+const selfTestTimeout = 10 * time.Second
+
+// SelfTestHandlers run the self-test on demand.
+type SelfTestHandlers struct {
+	suite *selftest.Suite
+}
+
+func NewSelfTestHandlers(suite *selftest.Suite) *SelfTestHandlers {
+	return &SelfTestHandlers{suite: suite}
+}
+
+// Run exercises every listener and a sample of the stubs (stubs=N, 5 by
+// default, 0 for none) and returns the report: 200 when every check passed,
+// 503 otherwise.
+func (h *SelfTestHandlers) Run(c echo.Context) error {
+	sample := selftest.DefaultStubSample
+	if value := c.QueryParam("stubs"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"error":     "Invalid stubs parameter",
+				"provided":  value,
+				"timestamp": time.Now().Unix(),
+			})
+		}
+		sample = n
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), selfTestTimeout)
+	defer cancel()
+	report := h.suite.Run(ctx, sample)
+	status := http.StatusOK
+	if !report.Passed {
+		status = http.StatusServiceUnavailable
+	}
+	return c.JSON(status, report)
+}
@@ -0,0 +1,57 @@
+package admin
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"mockserver/internal/sftp"
+)
+
+// SFTPHandlers manage the faults injected into the SFTP fixture server.
+type SFTPHandlers struct {
+	server *sftp.Server
+}
+
+func NewSFTPHandlers(server *sftp.Server) *SFTPHandlers {
+	return &SFTPHandlers{server: server}
+}
+
+// Faults lists the faults with how often each one fired.
+func (h *SFTPHandlers) Faults(c echo.Context) error {
+	faults := h.server.Faults()
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"faults":      faults,
+		"count":       len(faults),
+		"fingerprint": h.server.Fingerprint(),
+		"timestamp":   time.Now().Unix(),
+	})
+}
+
+// AddFault injects a fault.
+func (h *SFTPHandlers) AddFault(c echo.Context) error {
+	var fault sftp.Fault
+	if err := c.Bind(&fault); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error":     "Invalid SFTP fault payload",
+			"details":   err.Error(),
+			"timestamp": time.Now().Unix(),
+		})
+	}
+	added, err := h.server.AddFault(fault)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error":     "Invalid SFTP fault",
+			"details":   err.Error(),
+			"timestamp": time.Now().Unix(),
+		})
+	}
+	return c.JSON(http.StatusCreated, added)
+}
+
+// ResetFaults removes every fault.
+func (h *SFTPHandlers) ResetFaults(c echo.Context) error {
+	h.server.ResetFaults()
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,51 @@
+package admin
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"mockserver/internal/signedurl"
+)
+
+// SignHandlers issue signed URLs.
+type SignHandlers struct {
+	signer *signedurl.Signer
+}
+
+func NewSignHandlers(signer *signedurl.Signer) *SignHandlers {
+	return &SignHandlers{signer: signer}
+}
+
+// Sign signs the path query parameter for the method (GET by default),
+// valid for expires (seconds or a duration).
+func (h *SignHandlers) Sign(c echo.Context) error {
+	path := c.QueryParam("path")
+	if path == "" {
+		return invalidSignRequest(c, "path is required")
+	}
+	ttl, err := h.signer.ParseExpires(c.QueryParam("expires"))
+	if err != nil {
+		return invalidSignRequest(c, err.Error())
+	}
+	signed, err := h.signer.Sign(c.QueryParam("method"), path, ttl)
+	if err != nil {
+		return invalidSignRequest(c, err.Error())
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"url":        c.Scheme() + "://" + c.Request().Host + signed.Path,
+		"path":       signed.Path,
+		"method":     signed.Method,
+		"expires_at": signed.ExpiresAt,
+		"timestamp":  time.Now().Unix(),
+	})
+}
+
+func invalidSignRequest(c echo.Context, details string) error {
+	return c.JSON(http.StatusBadRequest, map[string]interface{}{
+		"error":     "Invalid sign request",
+		"details":   details,
+		"timestamp": time.Now().Unix(),
+	})
+}
@@ -0,0 +1,117 @@
+package admin
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"mockserver/internal/smtp"
+)
+
+// SMTPHandlers let tests list, search and fetch the mail captured by the
+// SMTP server.
+type SMTPHandlers struct {
+	server *smtp.Server
+}
+
+func NewSMTPHandlers(server *smtp.Server) *SMTPHandlers {
+	return &SMTPHandlers{server: server}
+}
+
+// Messages lists the captured messages, oldest first, filtered by the from,
+// to, subject and q (subject and bodies) substrings, since (RFC 3339) and
+// limit query parameters.
+func (h *SMTPHandlers) Messages(c echo.Context) error {
+	filter := smtp.Filter{
+		From:    c.QueryParam("from"),
+		To:      c.QueryParam("to"),
+		Subject: c.QueryParam("subject"),
+		Text:    c.QueryParam("q"),
+	}
+	if value := c.QueryParam("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 0 {
+			return invalidQuery(c, "limit", value)
+		}
+		filter.Limit = limit
+	}
+	if value := c.QueryParam("since"); value != "" {
+		since, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return invalidQuery(c, "since", value)
+		}
+		filter.Since = since
+	}
+
+	messages := h.server.Messages(filter)
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"messages":  messages,
+		"count":     len(messages),
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// Message returns a message with its headers, bodies and attachment list.
+func (h *SMTPHandlers) Message(c echo.Context) error {
+	message, ok := h.server.Message(c.Param("id"))
+	if !ok {
+		return unknownMessage(c)
+	}
+	return c.JSON(http.StatusOK, message)
+}
+
+// Raw returns a message as it was received.
+func (h *SMTPHandlers) Raw(c echo.Context) error {
+	message, ok := h.server.Message(c.Param("id"))
+	if !ok {
+		return unknownMessage(c)
+	}
+	return c.Blob(http.StatusOK, "message/rfc822", message.Raw())
+}
+
+// Attachment downloads an attachment of a message by index.
+func (h *SMTPHandlers) Attachment(c echo.Context) error {
+	message, ok := h.server.Message(c.Param("id"))
+	if !ok {
+		return unknownMessage(c)
+	}
+	index, err := strconv.Atoi(c.Param("index"))
+	attachment, data, found := message.Attachment(index)
+	if err != nil || !found {
+		return c.JSON(http.StatusNotFound, map[string]interface{}{
+			"error":     "Unknown attachment",
+			"provided":  c.Param("index"),
+			"timestamp": time.Now().Unix(),
+		})
+	}
+	if attachment.Filename != "" {
+		c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", attachment.Filename))
+	}
+	return c.Blob(http.StatusOK, attachment.ContentType, data)
+}
+
+// Delete removes a message.
+func (h *SMTPHandlers) Delete(c echo.Context) error {
+	if !h.server.Delete(c.Param("id")) {
+		return unknownMessage(c)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// Reset empties the mailbox.
+func (h *SMTPHandlers) Reset(c echo.Context) error {
+	h.server.Reset()
+	logger.InfoContext(c.Request().Context(), "SMTP messages cleared")
+	return c.NoContent(http.StatusNoContent)
+}
+
+func unknownMessage(c echo.Context) error {
+	return c.JSON(http.StatusNotFound, map[string]interface{}{
+		"error":     "Unknown SMTP message",
+		"provided":  c.Param("id"),
+		"timestamp": time.Now().Unix(),
+	})
+}
@@ -0,0 +1,286 @@
+package admin
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"mockserver/internal/har"
+	"mockserver/internal/stubs"
+)
+
+// maxHAR caps the size of an imported HAR file.
+const maxHAR = 64 * 1024 * 1024
+
+// StubHandlers manage HTTP stubs at runtime.
+type StubHandlers struct {
+	engine *stubs.Engine
+}
+
+func NewStubHandlers(engine *stubs.Engine) *StubHandlers {
+	return &StubHandlers{engine: engine}
+}
+
+// List returns every stub in evaluation order.
+func (h *StubHandlers) List(c echo.Context) error {
+	list := h.engine.List()
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"stubs":     list,
+		"count":     len(list),
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// Create registers a new stub.
+func (h *StubHandlers) Create(c echo.Context) error {
+	var stub stubs.Stub
+	if err := c.Bind(&stub); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error":     "Invalid stub payload",
+			"details":   err.Error(),
+			"timestamp": time.Now().Unix(),
+		})
+	}
+	if stub.ID != "" {
+		if _, exists := h.engine.Get(stub.ID); exists {
+			return c.JSON(http.StatusConflict, map[string]interface{}{
+				"error":     "Stub already exists, use PUT to replace it",
+				"provided":  stub.ID,
+				"timestamp": time.Now().Unix(),
+			})
+		}
+	}
+	return h.save(c, stub, http.StatusCreated)
+}
+
+// Get returns a single stub.
+func (h *StubHandlers) Get(c echo.Context) error {
+	stub, ok := h.engine.Get(c.Param("id"))
+	if !ok {
+		return stubNotFound(c)
+	}
+	return c.JSON(http.StatusOK, stub)
+}
+
+// Update replaces the stub with the given ID, creating it when missing.
+func (h *StubHandlers) Update(c echo.Context) error {
+	var stub stubs.Stub
+	if err := c.Bind(&stub); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error":     "Invalid stub payload",
+			"details":   err.Error(),
+			"timestamp": time.Now().Unix(),
+		})
+	}
+	stub.ID = c.Param("id")
+	return h.save(c, stub, http.StatusOK)
+}
+
+// Delete removes a stub.
+func (h *StubHandlers) Delete(c echo.Context) error {
+	if !h.engine.Remove(c.Param("id")) {
+		return stubNotFound(c)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// Reset removes every stub.
+func (h *StubHandlers) Reset(c echo.Context) error {
+	h.engine.Reset()
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ImportHAR converts the entries of a HAR file, sent as the request body or
+// as the "file" field of a form, into stubs and registers them, unless
+// dry_run is set. The host query parameter keeps the entries sent to one
+// host, and match_headers lists the request headers the stubs match.
+// Entries that cannot be converted are reported in skipped.
+func (h *StubHandlers) ImportHAR(c echo.Context) error {
+	dryRun := false
+	if value := c.QueryParam("dry_run"); value != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(value); err != nil {
+			return invalidQuery(c, "dry_run", value)
+		}
+	}
+	options := har.ImportOptions{Host: c.QueryParam("host")}
+	if value := c.QueryParam("match_headers"); value != "" {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				options.MatchHeaders = append(options.MatchHeaders, name)
+			}
+		}
+	}
+
+	data, err := readHAR(c)
+	if err != nil {
+		return invalidHAR(c, err.Error())
+	}
+	file, err := har.Parse(data)
+	if err != nil {
+		return invalidHAR(c, err.Error())
+	}
+	list, skipped := har.Stubs(file, options)
+	if skipped == nil {
+		skipped = []string{}
+	}
+	if len(list) == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error":     "No entry of the HAR file could be converted",
+			"skipped":   skipped,
+			"timestamp": time.Now().Unix(),
+		})
+	}
+	if dryRun {
+		if err := h.engine.Validate(list); err != nil {
+			return invalidHAR(c, err.Error())
+		}
+	} else {
+		if list, err = h.engine.Swap(nil, list); err != nil {
+			return invalidHAR(c, err.Error())
+		}
+		logger.InfoContext(c.Request().Context(), "Stubs imported from a HAR file", "stubs", len(list), "skipped", len(skipped))
+	}
+
+	status := http.StatusCreated
+	if dryRun {
+		status = http.StatusOK
+	}
+	return c.JSON(status, map[string]interface{}{
+		"stubs":     list,
+		"skipped":   skipped,
+		"count":     len(list),
+		"dry_run":   dryRun,
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// CacheStats reports response cache hits and misses, overall and per stub.
+func (h *StubHandlers) CacheStats(c echo.Context) error {
+	return c.JSON(http.StatusOK, h.engine.CacheStats())
+}
+
+// Attempts lists the call counters of stubs using "fail".
+func (h *StubHandlers) Attempts(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"attempts":  h.engine.Attempts(),
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// ResetAttempts clears the counter given by the key query parameter, or all
+// counters.
+func (h *StubHandlers) ResetAttempts(c echo.Context) error {
+	h.engine.ResetAttempts(c.QueryParam("key"))
+	return c.NoContent(http.StatusNoContent)
+}
+
+// Locks lists the resources locked by requests of stubs using "lock".
+func (h *StubHandlers) Locks(c echo.Context) error {
+	locks := h.engine.Locks()
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"locks":     locks,
+		"count":     len(locks),
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// ReleaseLocks releases the lock given by the key query parameter, or all
+// locks.
+func (h *StubHandlers) ReleaseLocks(c echo.Context) error {
+	key := c.QueryParam("key")
+	if h.engine.ReleaseLocks(key) == 0 && key != "" {
+		return c.JSON(http.StatusNotFound, map[string]interface{}{
+			"error":     "Lock not held",
+			"provided":  key,
+			"timestamp": time.Now().Unix(),
+		})
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// Callbacks lists the deliveries of the callbacks sent by stubs, with their
+// attempts.
+func (h *StubHandlers) Callbacks(c echo.Context) error {
+	deliveries := h.engine.Callbacks()
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"callbacks": deliveries,
+		"count":     len(deliveries),
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// ResetCallbacks clears the delivery log.
+func (h *StubHandlers) ResetCallbacks(c echo.Context) error {
+	h.engine.ResetCallbacks()
+	return c.NoContent(http.StatusNoContent)
+}
+
+// Costs reports the cost accumulated by the routes of the stubs with a
+// cost: requests, throughput, latency and the units by dimension.
+func (h *StubHandlers) Costs(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"costs":     h.engine.Costs(),
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// ResetCosts clears the cost report, between two load test runs.
+func (h *StubHandlers) ResetCosts(c echo.Context) error {
+	h.engine.ResetCosts()
+	return c.NoContent(http.StatusNoContent)
+}
+
+// FlushCache drops all cached responses and resets the counters.
+func (h *StubHandlers) FlushCache(c echo.Context) error {
+	h.engine.FlushCache()
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *StubHandlers) save(c echo.Context, stub stubs.Stub, status int) error {
+	saved, err := h.engine.Add(stub)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error":     err.Error(),
+			"timestamp": time.Now().Unix(),
+		})
+	}
+	return c.JSON(status, saved)
+}
+
+func readHAR(c echo.Context) ([]byte, error) {
+	body := c.Request().Body
+	if strings.HasPrefix(c.Request().Header.Get(echo.HeaderContentType), echo.MIMEMultipartForm) {
+		header, err := c.FormFile("file")
+		if err != nil {
+			return nil, err
+		}
+		file, err := header.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		body = file
+	}
+	return io.ReadAll(io.LimitReader(body, maxHAR))
+}
+
+func invalidHAR(c echo.Context, details string) error {
+	return c.JSON(http.StatusBadRequest, map[string]interface{}{
+		"error":     "Invalid HAR file",
+		"details":   details,
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+func stubNotFound(c echo.Context) error {
+	return c.JSON(http.StatusNotFound, map[string]interface{}{
+		"error":     "Stub not found",
+		"provided":  c.Param("id"),
+		"timestamp": time.Now().Unix(),
+	})
+}
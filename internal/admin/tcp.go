@@ -0,0 +1,50 @@
+package admin
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"mockserver/internal/tcp"
+)
+
+// TCPHandlers show the connections of the raw TCP listener and what was
+// exchanged on them.
+type TCPHandlers struct {
+	server *tcp.Server
+}
+
+func NewTCPHandlers(server *tcp.Server) *TCPHandlers {
+	return &TCPHandlers{server: server}
+}
+
+// Connections lists the open and finished connections, oldest first.
+func (h *TCPHandlers) Connections(c echo.Context) error {
+	sessions := h.server.Sessions()
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"connections": sessions,
+		"count":       len(sessions),
+		"timestamp":   time.Now().Unix(),
+	})
+}
+
+// Connection returns a connection with its transcript.
+func (h *TCPHandlers) Connection(c echo.Context) error {
+	session, ok := h.server.Session(c.Param("id"))
+	if !ok {
+		return c.JSON(http.StatusNotFound, map[string]interface{}{
+			"error":     "Unknown TCP connection",
+			"provided":  c.Param("id"),
+			"timestamp": time.Now().Unix(),
+		})
+	}
+	return c.JSON(http.StatusOK, session)
+}
+
+// Reset forgets the finished connections.
+func (h *TCPHandlers) Reset(c echo.Context) error {
+	h.server.Reset()
+	logger.InfoContext(c.Request().Context(), "TCP connections cleared")
+	return c.NoContent(http.StatusNoContent)
+}
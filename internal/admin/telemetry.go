@@ -0,0 +1,67 @@
+package admin
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"mockserver/internal/telemetry"
+)
+
+// TelemetryHandlers expose the logs, traces and metrics received by the
+// telemetry sinks.
+type TelemetryHandlers struct {
+	store *telemetry.Store
+}
+
+func NewTelemetryHandlers(store *telemetry.Store) *TelemetryHandlers {
+	return &TelemetryHandlers{store: store}
+}
+
+// List returns received telemetry, filtered by the signal, protocol,
+// service, name (of a metric), trace_id, contains, since (RFC 3339) and
+// limit query parameters.
+func (h *TelemetryHandlers) List(c echo.Context) error {
+	filter := telemetry.Filter{
+		Signal:   c.QueryParam("signal"),
+		Protocol: c.QueryParam("protocol"),
+		Service:  c.QueryParam("service"),
+		Name:     c.QueryParam("name"),
+		TraceID:  c.QueryParam("trace_id"),
+		Contains: c.QueryParam("contains"),
+	}
+	switch filter.Signal {
+	case "", telemetry.SignalLog, telemetry.SignalTrace, telemetry.SignalMetric:
+	default:
+		return invalidQuery(c, "signal", filter.Signal)
+	}
+	if value := c.QueryParam("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 0 {
+			return invalidQuery(c, "limit", value)
+		}
+		filter.Limit = limit
+	}
+	if value := c.QueryParam("since"); value != "" {
+		since, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return invalidQuery(c, "since", value)
+		}
+		filter.Since = since
+	}
+
+	entries := h.store.Find(filter)
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"entries":   entries,
+		"count":     len(entries),
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// Reset discards all received telemetry.
+func (h *TelemetryHandlers) Reset(c echo.Context) error {
+	h.store.Reset()
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,61 @@
+package admin
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"mockserver/internal/tus"
+)
+
+// TusHandlers inspect the uploads received by the tus server.
+type TusHandlers struct {
+	server *tus.Server
+}
+
+func NewTusHandlers(server *tus.Server) *TusHandlers {
+	return &TusHandlers{server: server}
+}
+
+// Uploads lists the uploads with their chunks, oldest first.
+func (h *TusHandlers) Uploads(c echo.Context) error {
+	uploads := h.server.Uploads()
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"uploads":   uploads,
+		"count":     len(uploads),
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// Upload returns a single upload with its chunks.
+func (h *TusHandlers) Upload(c echo.Context) error {
+	upload, _, ok := h.server.Upload(c.Param("id"))
+	if !ok {
+		return uploadNotFound(c)
+	}
+	return c.JSON(http.StatusOK, upload)
+}
+
+// Data returns the bytes received for an upload.
+func (h *TusHandlers) Data(c echo.Context) error {
+	_, data, ok := h.server.Upload(c.Param("id"))
+	if !ok {
+		return uploadNotFound(c)
+	}
+	return c.Blob(http.StatusOK, echo.MIMEOctetStream, data)
+}
+
+// Reset deletes every upload.
+func (h *TusHandlers) Reset(c echo.Context) error {
+	h.server.Reset()
+	return c.NoContent(http.StatusNoContent)
+}
+
+func uploadNotFound(c echo.Context) error {
+	return c.JSON(http.StatusNotFound, map[string]interface{}{
+		"error":     "Upload not found",
+		"provided":  c.Param("id"),
+		"timestamp": time.Now().Unix(),
+	})
+}
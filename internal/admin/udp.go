@@ -0,0 +1,44 @@
+package admin
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"mockserver/internal/udp"
+)
+
+// UDPHandlers show the datagrams the UDP listener received and how it
+// answered them.
+type UDPHandlers struct {
+	server *udp.Server
+}
+
+func NewUDPHandlers(server *udp.Server) *UDPHandlers {
+	return &UDPHandlers{server: server}
+}
+
+// Packets lists the datagrams received, oldest first. The peer and outcome
+// query parameters filter them.
+func (h *UDPHandlers) Packets(c echo.Context) error {
+	peer, outcome := c.QueryParam("peer"), c.QueryParam("outcome")
+	packets := []udp.Packet{}
+	for _, packet := range h.server.Packets() {
+		if (peer == "" || packet.Peer == peer) && (outcome == "" || packet.Outcome == outcome) {
+			packets = append(packets, packet)
+		}
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"packets":   packets,
+		"count":     len(packets),
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// Reset forgets the datagrams received.
+func (h *UDPHandlers) Reset(c echo.Context) error {
+	h.server.Reset()
+	logger.InfoContext(c.Request().Context(), "UDP packets cleared")
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,93 @@
+package admin
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"mockserver/internal/journal"
+	"mockserver/internal/verify"
+)
+
+// VerificationHandlers manage stored verification specs and evaluate them
+// against the request journal.
+type VerificationHandlers struct {
+	store   *verify.Store
+	journal *journal.Journal
+}
+
+func NewVerificationHandlers(store *verify.Store, j *journal.Journal) *VerificationHandlers {
+	return &VerificationHandlers{store: store, journal: j}
+}
+
+// List returns the stored specs.
+func (h *VerificationHandlers) List(c echo.Context) error {
+	specs := h.store.List()
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"verifications": specs,
+		"count":         len(specs),
+		"timestamp":     time.Now().Unix(),
+	})
+}
+
+// Create stores a spec, replacing one with the same ID.
+func (h *VerificationHandlers) Create(c echo.Context) error {
+	var spec verify.Spec
+	if err := c.Bind(&spec); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error":     "Invalid verification payload",
+			"details":   err.Error(),
+			"timestamp": time.Now().Unix(),
+		})
+	}
+	saved, err := h.store.Add(spec)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error":     err.Error(),
+			"timestamp": time.Now().Unix(),
+		})
+	}
+	return c.JSON(http.StatusCreated, saved)
+}
+
+// Delete removes a spec.
+func (h *VerificationHandlers) Delete(c echo.Context) error {
+	if !h.store.Remove(c.Param("id")) {
+		return c.JSON(http.StatusNotFound, map[string]interface{}{
+			"error":     "Verification not found",
+			"provided":  c.Param("id"),
+			"timestamp": time.Now().Unix(),
+		})
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// Reset removes every spec.
+func (h *VerificationHandlers) Reset(c echo.Context) error {
+	h.store.Reset()
+	return c.NoContent(http.StatusNoContent)
+}
+
+// Report evaluates every spec and returns a JSON report, or JUnit XML with
+// format=junit.
+func (h *VerificationHandlers) Report(c echo.Context) error {
+	report := h.store.Evaluate(h.journal)
+
+	switch format := c.QueryParam("format"); format {
+	case "", "json":
+		return c.JSON(http.StatusOK, report)
+	case "junit", "xml":
+		data, err := report.JUnit()
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+				"error":     "Failed to render JUnit report",
+				"details":   err.Error(),
+				"timestamp": time.Now().Unix(),
+			})
+		}
+		return c.Blob(http.StatusOK, echo.MIMEApplicationXMLCharsetUTF8, data)
+	default:
+		return invalidQuery(c, "format", format)
+	}
+}
@@ -0,0 +1,174 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo/v4"
+
+	"mockserver/internal/correlation"
+	wsHandlers "mockserver/internal/websocket"
+)
+
+// WebSocketHandlers let tests inspect the WebSocket connections and rooms,
+// send messages to them and disconnect clients.
+type WebSocketHandlers struct {
+	ws *wsHandlers.WebSocketHandlers
+}
+
+func NewWebSocketHandlers(ws *wsHandlers.WebSocketHandlers) *WebSocketHandlers {
+	return &WebSocketHandlers{ws: ws}
+}
+
+type pushRequest struct {
+	Room          string      `json:"room"`
+	Type          string      `json:"type"`
+	Data          interface{} `json:"data"`
+	CorrelationID string      `json:"correlation_id"`
+}
+
+// Push sends a message to the broadcast clients, or to a chat room when one
+// is given, and reports how many clients received it. Like PublishEvent, the
+// message carries the correlation ID of the body or the request headers.
+func (h *WebSocketHandlers) Push(c echo.Context) error {
+	var req pushRequest
+	if err := c.Bind(&req); err != nil {
+		return invalidPush(c, err)
+	}
+	return h.push(c, req)
+}
+
+// Inject sends a message to the members of an existing chat room, as Push
+// does with a room.
+func (h *WebSocketHandlers) Inject(c echo.Context) error {
+	var req pushRequest
+	if err := c.Bind(&req); err != nil {
+		return invalidPush(c, err)
+	}
+	req.Room = c.Param("room")
+	if h.ws.RoomSize(req.Room) == 0 {
+		return c.JSON(http.StatusNotFound, map[string]interface{}{
+			"error":     "Unknown WebSocket room",
+			"provided":  req.Room,
+			"timestamp": time.Now().Unix(),
+		})
+	}
+	return h.push(c, req)
+}
+
+func (h *WebSocketHandlers) push(c echo.Context, req pushRequest) error {
+	if req.Type == "" {
+		req.Type = "push"
+	}
+	if req.CorrelationID == "" {
+		req.CorrelationID = correlation.FromHeader(c.Request().Header)
+	}
+
+	delivered := h.ws.Push(req.Room, wsHandlers.Message{
+		Type:          req.Type,
+		Data:          req.Data,
+		CorrelationID: req.CorrelationID,
+	})
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"room":           req.Room,
+		"type":           req.Type,
+		"delivered":      delivered,
+		"correlation_id": req.CorrelationID,
+		"timestamp":      time.Now().Unix(),
+	})
+}
+
+func invalidPush(c echo.Context, err error) error {
+	return c.JSON(http.StatusBadRequest, map[string]interface{}{
+		"error":     "Invalid push payload",
+		"details":   err.Error(),
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// Connections lists the open WebSocket connections of every endpoint.
+func (h *WebSocketHandlers) Connections(c echo.Context) error {
+	connections := h.ws.Connections()
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"connections": connections,
+		"count":       len(connections),
+		"timestamp":   time.Now().Unix(),
+	})
+}
+
+// Rooms lists the chat rooms with their members, and counts the broadcast
+// clients.
+func (h *WebSocketHandlers) Rooms(c echo.Context) error {
+	rooms, broadcastClients := h.ws.Rooms()
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"rooms":             rooms,
+		"count":             len(rooms),
+		"broadcast_clients": broadcastClients,
+		"timestamp":         time.Now().Unix(),
+	})
+}
+
+// Disconnect closes a connection with the close code and reason given as
+// query parameters (1000 and no reason by default).
+func (h *WebSocketHandlers) Disconnect(c echo.Context) error {
+	code := websocket.CloseNormalClosure
+	if value := c.QueryParam("code"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return invalidQuery(c, "code", value)
+		}
+		code = parsed
+	}
+	reason := c.QueryParam("reason")
+	// A close frame's payload is the 2-byte code and at most 123 bytes of reason
+	if len(reason) > 123 {
+		return invalidQuery(c, "reason", reason)
+	}
+
+	id := c.Param("id")
+	err := h.ws.Disconnect(id, code, reason)
+	if errors.Is(err, wsHandlers.ErrUnknownConnection) {
+		return c.JSON(http.StatusNotFound, map[string]interface{}{
+			"error":     "Unknown WebSocket connection",
+			"provided":  id,
+			"timestamp": time.Now().Unix(),
+		})
+	}
+	if err != nil {
+		return invalidQuery(c, "code", strconv.Itoa(code))
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// Chaos reports whether the configured chaos is applied, and to which
+// endpoints.
+func (h *WebSocketHandlers) Chaos(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"enabled":   h.ws.ChaosEnabled(),
+		"endpoints": h.ws.ChaosEndpoints(),
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// SetChaos pauses or resumes the configured chaos: {"enabled": false}.
+func (h *WebSocketHandlers) SetChaos(c echo.Context) error {
+	var req struct {
+		Enabled *bool `json:"enabled"`
+	}
+	if err := c.Bind(&req); err != nil || req.Enabled == nil {
+		details := "enabled is required"
+		if err != nil {
+			details = err.Error()
+		}
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error":     "Invalid chaos payload",
+			"details":   details,
+			"timestamp": time.Now().Unix(),
+		})
+	}
+	h.ws.SetChaosEnabled(*req.Enabled)
+	return h.Chaos(c)
+}
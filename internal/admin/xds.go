@@ -0,0 +1,88 @@
+package admin
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"mockserver/internal/xds"
+)
+
+// XDSHandlers manage the services served by the xDS control plane.
+type XDSHandlers struct {
+	server    *xds.Server
+	serverURI string
+}
+
+// NewXDSHandlers creates the xDS admin handlers. serverURI is the address
+// clients should use to reach the ADS listener, as written in the bootstrap.
+func NewXDSHandlers(server *xds.Server, serverURI string) *XDSHandlers {
+	return &XDSHandlers{server: server, serverURI: serverURI}
+}
+
+// Services lists every xDS service and the current snapshot version.
+func (h *XDSHandlers) Services(c echo.Context) error {
+	services, version := h.server.Services()
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"version":   version,
+		"services":  services,
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// SetService creates or replaces the endpoints of a service. Connected
+// clients receive the new assignment right away.
+func (h *XDSHandlers) SetService(c echo.Context) error {
+	var service xds.Service
+	if err := c.Bind(&service); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error":     "Invalid xDS service payload",
+			"details":   err.Error(),
+			"timestamp": time.Now().Unix(),
+		})
+	}
+	service.Name = c.Param("name")
+
+	version, err := h.server.SetService(service)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error":     err.Error(),
+			"timestamp": time.Now().Unix(),
+		})
+	}
+	logger.InfoContext(c.Request().Context(), "xDS service set", "service", service.Name, "endpoints", len(service.Endpoints))
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"version":   version,
+		"service":   service,
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// DeleteService removes a service; clients see its listener disappear.
+func (h *XDSHandlers) DeleteService(c echo.Context) error {
+	name := c.Param("name")
+	version, found, err := h.server.DeleteService(name)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"error":     err.Error(),
+			"timestamp": time.Now().Unix(),
+		})
+	}
+	if !found {
+		return c.JSON(http.StatusNotFound, map[string]interface{}{
+			"error":     "Unknown xDS service",
+			"provided":  name,
+			"timestamp": time.Now().Unix(),
+		})
+	}
+	logger.InfoContext(c.Request().Context(), "xDS service deleted", "service", name, "version", version)
+	return c.NoContent(http.StatusNoContent)
+}
+
+// Bootstrap returns a bootstrap file for GRPC_XDS_BOOTSTRAP pointing gRPC
+// clients at this control plane.
+func (h *XDSHandlers) Bootstrap(c echo.Context) error {
+	return c.JSON(http.StatusOK, xds.Bootstrap(h.serverURI))
+}
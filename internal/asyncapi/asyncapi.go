@@ -0,0 +1,642 @@
+// Package asyncapi converts the WebSocket scenarios to and from AsyncAPI
+// documents. Export describes every scenario as an AsyncAPI 3.0 channel with
+// its send and receive/reply operations; import scaffolds scenarios from the
+// channels, operations and message examples of an AsyncAPI 2.x or 3.x
+// document (JSON or YAML).
+//
+// Exported channels also carry the scenario itself as x-mockserver-scenario,
+// since delays, expectations and close frames have no AsyncAPI equivalent;
+// importing such a channel restores the scenario as it was.
+package asyncapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"mockserver/internal/match"
+	"mockserver/internal/websocket"
+)
+
+// Version is the AsyncAPI version of exported documents.
+const Version = "3.0.0"
+
+// ScenarioExtension holds the full scenario on an exported channel.
+const ScenarioExtension = "x-mockserver-scenario"
+
+// ScenarioPath is the address of the scenario endpoint, followed by the
+// scenario name.
+const ScenarioPath = "/ws/scenario/"
+
+// maxRefDepth bounds the $ref chains followed while importing.
+const maxRefDepth = 32
+
+// Export builds an AsyncAPI 3.0 document describing the scenarios, served
+// by host.
+func Export(scenarios map[string]websocket.Scenario, host string) map[string]interface{} {
+	names := make([]string, 0, len(scenarios))
+	for name := range scenarios {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	channels := map[string]interface{}{}
+	operations := map[string]interface{}{}
+	for _, name := range names {
+		scenario := scenarios[name]
+		channelRef := map[string]interface{}{"$ref": "#/channels/" + escapePointer(name)}
+		messageRef := func(message string) map[string]interface{} {
+			return map[string]interface{}{"$ref": "#/channels/" + escapePointer(name) + "/messages/" + message}
+		}
+		messages := map[string]interface{}{}
+
+		for i, step := range scenario.Steps {
+			id := fmt.Sprintf("step%d", i+1)
+			if step.Expect != nil {
+				messages[id+"_expect"] = matcherMessage(step.Expect)
+				operations[name+"."+id+".expect"] = map[string]interface{}{
+					"action":   "receive",
+					"channel":  channelRef,
+					"summary":  fmt.Sprintf("Step %d waits for this client message", i+1),
+					"messages": []interface{}{messageRef(id + "_expect")},
+				}
+			}
+			if len(step.Send) > 0 {
+				messages[id] = sendMessage(step.Send)
+				operations[name+"."+id] = map[string]interface{}{
+					"action":   "send",
+					"channel":  channelRef,
+					"summary":  fmt.Sprintf("Step %d of the script", i+1),
+					"messages": []interface{}{messageRef(id)},
+				}
+			}
+		}
+		for i, reply := range scenario.Replies {
+			id := fmt.Sprintf("reply%d", i+1)
+			messages[id+"_request"] = matcherMessage(reply.Match)
+			operation := map[string]interface{}{
+				"action":   "receive",
+				"channel":  channelRef,
+				"summary":  "Answered whenever a client message matches",
+				"messages": []interface{}{messageRef(id + "_request")},
+			}
+			if len(reply.Send) > 0 {
+				messages[id] = sendMessage(reply.Send)
+				operation["reply"] = map[string]interface{}{
+					"channel":  channelRef,
+					"messages": []interface{}{messageRef(id)},
+				}
+			}
+			operations[name+"."+id] = operation
+		}
+
+		channels[name] = map[string]interface{}{
+			"address":         ScenarioPath + name,
+			"messages":        messages,
+			ScenarioExtension: scenario,
+		}
+	}
+
+	return map[string]interface{}{
+		"asyncapi": Version,
+		"info": map[string]interface{}{
+			"title":       "mockserver WebSocket scenarios",
+			"version":     "1.0.0",
+			"description": "Scripted WebSocket conversations served by mockserver.",
+		},
+		"servers": map[string]interface{}{
+			"mockserver": map[string]interface{}{"host": host, "protocol": "ws"},
+		},
+		"defaultContentType": "application/json",
+		"channels":           channels,
+		"operations":         operations,
+	}
+}
+
+// sendMessage describes a message the scenario sends. JSON strings are sent
+// as raw text.
+func sendMessage(send json.RawMessage) map[string]interface{} {
+	var value interface{}
+	json.Unmarshal(send, &value)
+	message := map[string]interface{}{
+		"payload":  schemaOf(value),
+		"examples": []interface{}{map[string]interface{}{"payload": value}},
+	}
+	if _, text := value.(string); text {
+		message["contentType"] = "text/plain"
+	}
+	return message
+}
+
+// matcherMessage describes the client messages matched by matchers.
+func matcherMessage(matchers []match.FieldMatcher) map[string]interface{} {
+	schema := map[string]interface{}{"type": "object"}
+	for _, m := range matchers {
+		node := schema
+		path := strings.Split(m.Field, ".")
+		for _, key := range path[:len(path)-1] {
+			node = property(node, key)
+			node["type"] = "object"
+		}
+		leaf := property(node, path[len(path)-1])
+		if m.Equals != nil {
+			leaf["const"] = m.Equals
+		}
+		if m.Regex != "" {
+			leaf["type"] = "string"
+			leaf["pattern"] = m.Regex
+		}
+		if m.Present != nil && *m.Present {
+			required, _ := node["required"].([]interface{})
+			node["required"] = append(required, path[len(path)-1])
+		}
+	}
+	return map[string]interface{}{"payload": schema}
+}
+
+func property(schema map[string]interface{}, key string) map[string]interface{} {
+	properties, ok := schema["properties"].(map[string]interface{})
+	if !ok {
+		properties = map[string]interface{}{}
+		schema["properties"] = properties
+	}
+	child, ok := properties[key].(map[string]interface{})
+	if !ok {
+		child = map[string]interface{}{}
+		properties[key] = child
+	}
+	return child
+}
+
+// schemaOf infers a JSON schema from an example value.
+func schemaOf(value interface{}) map[string]interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		properties := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			properties[key] = schemaOf(item)
+		}
+		return map[string]interface{}{"type": "object", "properties": properties}
+	case []interface{}:
+		schema := map[string]interface{}{"type": "array"}
+		if len(v) > 0 {
+			schema["items"] = schemaOf(v[0])
+		}
+		return schema
+	case string:
+		return map[string]interface{}{"type": "string"}
+	case float64:
+		if v == float64(int64(v)) {
+			return map[string]interface{}{"type": "integer"}
+		}
+		return map[string]interface{}{"type": "number"}
+	case bool:
+		return map[string]interface{}{"type": "boolean"}
+	}
+	return map[string]interface{}{"type": "null"}
+}
+
+func escapePointer(token string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+}
+
+func unescapePointer(token string) string {
+	return strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+}
+
+// Result is the outcome of an import: the scenarios, keyed by name, and
+// what could not be carried over.
+type Result struct {
+	Scenarios map[string]websocket.Scenario `json:"scenarios"`
+	Warnings  []string                      `json:"warnings,omitempty"`
+}
+
+// Import scaffolds scenarios from an AsyncAPI document, one per channel:
+//
+//   - AsyncAPI 3: send operations become steps, in document order, sending
+//     the first example of their message; receive operations with a reply
+//     become replies matching the request message and sending the reply.
+//   - AsyncAPI 2: the messages of a channel's subscribe operation become
+//     steps; publish operations have no replies to derive.
+//
+// Replies match on the const, single-value enum and pattern constraints of
+// the request payload, or else on the type, event or action field of its
+// example. Channels carrying x-mockserver-scenario are imported as is.
+func Import(data []byte) (Result, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return Result{}, fmt.Errorf("invalid document: %w", err)
+	}
+	var raw interface{}
+	if err := root.Decode(&raw); err != nil {
+		return Result{}, fmt.Errorf("invalid document: %w", err)
+	}
+	// Round-trip through JSON so values compare like the ones of scenarios
+	encoded, err := json.Marshal(raw)
+	if err != nil {
+		return Result{}, fmt.Errorf("invalid document: %w", err)
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(encoded, &doc); err != nil || doc == nil {
+		return Result{}, fmt.Errorf("invalid document: not an object")
+	}
+
+	version := ""
+	if value, ok := doc["asyncapi"]; ok {
+		version = fmt.Sprint(value)
+	}
+	im := &importer{doc: doc, root: &root, result: Result{Scenarios: map[string]websocket.Scenario{}}}
+	switch {
+	case strings.HasPrefix(version, "3."):
+		im.v3()
+	case strings.HasPrefix(version, "2."):
+		im.v2()
+	default:
+		return Result{}, fmt.Errorf("unsupported asyncapi version %q, expected 2.x or 3.x", version)
+	}
+	return im.result, nil
+}
+
+type importer struct {
+	doc    map[string]interface{}
+	root   *yaml.Node
+	result Result
+}
+
+func (im *importer) warn(format string, args ...interface{}) {
+	im.result.Warnings = append(im.result.Warnings, fmt.Sprintf(format, args...))
+}
+
+func (im *importer) v3() {
+	channels, _ := im.doc["channels"].(map[string]interface{})
+	operations, _ := im.doc["operations"].(map[string]interface{})
+	for _, key := range im.keys("channels") {
+		channel, _ := im.resolve(channels[key]).(map[string]interface{})
+		if channel == nil {
+			continue
+		}
+		name := scenarioName(key, channel["address"])
+		if im.extension(name, channel) {
+			continue
+		}
+
+		var scenario websocket.Scenario
+		for _, opKey := range im.keys("operations") {
+			operation, _ := im.resolve(operations[opKey]).(map[string]interface{})
+			if operation == nil || !im.sameChannel(operation["channel"], key, channel) {
+				continue
+			}
+			messages, _ := operation["messages"].([]interface{})
+			if len(messages) == 0 {
+				messages = channelMessages(channel)
+			}
+			if len(messages) == 0 {
+				im.warn("operation %s: no message", opKey)
+				continue
+			}
+			switch operation["action"] {
+			case "send":
+				send, err := im.example(messages[0])
+				if err != nil {
+					im.warn("operation %s: %v", opKey, err)
+					continue
+				}
+				scenario.Steps = append(scenario.Steps, websocket.ScenarioStep{Send: send})
+			case "receive":
+				reply, ok := im.resolve(operation["reply"]).(map[string]interface{})
+				if !ok {
+					continue
+				}
+				matchers := im.matchers(messages[0])
+				if len(matchers) == 0 {
+					im.warn("operation %s: nothing to match the request on, add a reply by hand", opKey)
+					continue
+				}
+				replyMessages, _ := reply["messages"].([]interface{})
+				if len(replyMessages) == 0 {
+					im.warn("operation %s: reply has no message", opKey)
+					continue
+				}
+				send, err := im.example(replyMessages[0])
+				if err != nil {
+					im.warn("operation %s: %v", opKey, err)
+					continue
+				}
+				scenario.Replies = append(scenario.Replies, websocket.ScenarioReply{Match: matchers, Send: send})
+			}
+		}
+		im.add(name, key, scenario)
+	}
+}
+
+func (im *importer) v2() {
+	channels, _ := im.doc["channels"].(map[string]interface{})
+	for _, key := range im.keys("channels") {
+		channel, _ := im.resolve(channels[key]).(map[string]interface{})
+		if channel == nil {
+			continue
+		}
+		name := scenarioName(key, nil)
+		if im.extension(name, channel) {
+			continue
+		}
+
+		var scenario websocket.Scenario
+		if subscribe, ok := im.resolve(channel["subscribe"]).(map[string]interface{}); ok {
+			for _, message := range im.messages(subscribe["message"]) {
+				send, err := im.example(message)
+				if err != nil {
+					im.warn("channel %s: %v", key, err)
+					continue
+				}
+				scenario.Steps = append(scenario.Steps, websocket.ScenarioStep{Send: send})
+			}
+		}
+		if _, ok := channel["publish"]; ok {
+			im.warn("channel %s: publish messages have no replies in AsyncAPI 2, add replies by hand", key)
+		}
+		im.add(name, key, scenario)
+	}
+}
+
+func (im *importer) add(name, channel string, scenario websocket.Scenario) {
+	if len(scenario.Steps) == 0 && len(scenario.Replies) == 0 {
+		im.warn("channel %s: no operation to derive a scenario from", channel)
+		return
+	}
+	im.result.Scenarios[name] = scenario
+}
+
+// extension imports a channel carrying x-mockserver-scenario.
+func (im *importer) extension(name string, channel map[string]interface{}) bool {
+	value, ok := channel[ScenarioExtension]
+	if !ok {
+		return false
+	}
+	var scenario websocket.Scenario
+	encoded, _ := json.Marshal(value)
+	if err := json.Unmarshal(encoded, &scenario); err != nil {
+		im.warn("channel %s: invalid %s: %v", name, ScenarioExtension, err)
+		return false
+	}
+	im.result.Scenarios[name] = scenario
+	return true
+}
+
+// scenarioName names the scenario of a channel after its address when it
+// is a scenario path, or else after its key.
+func scenarioName(key string, address interface{}) string {
+	if address, ok := address.(string); ok && strings.HasPrefix(address, ScenarioPath) {
+		if name := strings.Trim(strings.TrimPrefix(address, ScenarioPath), "/"); name != "" && !strings.Contains(name, "/") {
+			return name
+		}
+	}
+	name := strings.Trim(strings.ReplaceAll(key, "/", "-"), "-")
+	if name == "" {
+		name = "scenario"
+	}
+	return name
+}
+
+// sameChannel reports whether an operation's channel reference designates
+// the channel.
+func (im *importer) sameChannel(ref interface{}, key string, channel map[string]interface{}) bool {
+	if object, ok := ref.(map[string]interface{}); ok {
+		if pointer, ok := object["$ref"].(string); ok {
+			return pointer == "#/channels/"+escapePointer(key)
+		}
+	}
+	resolved, ok := im.resolve(ref).(map[string]interface{})
+	return ok && fmt.Sprint(resolved["address"]) == fmt.Sprint(channel["address"])
+}
+
+func channelMessages(channel map[string]interface{}) []interface{} {
+	messages, _ := channel["messages"].(map[string]interface{})
+	keys := make([]string, 0, len(messages))
+	for key := range messages {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	list := make([]interface{}, len(keys))
+	for i, key := range keys {
+		list[i] = messages[key]
+	}
+	return list
+}
+
+// messages expands an AsyncAPI 2 message, which may be a oneOf.
+func (im *importer) messages(value interface{}) []interface{} {
+	message, ok := im.resolve(value).(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	if oneOf, ok := message["oneOf"].([]interface{}); ok {
+		return oneOf
+	}
+	return []interface{}{message}
+}
+
+// example returns the payload to send for a message: its first example,
+// else an example of its schema, else a value built from the schema.
+func (im *importer) example(value interface{}) (json.RawMessage, error) {
+	message, ok := im.resolve(value).(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unresolvable message")
+	}
+	var payload interface{}
+	found := false
+	if examples, ok := message["examples"].([]interface{}); ok && len(examples) > 0 {
+		if example, ok := examples[0].(map[string]interface{}); ok {
+			payload, found = example["payload"]
+		}
+	}
+	if !found {
+		schema := im.schema(message["payload"])
+		payload, found = schemaExample(schema)
+		if !found {
+			payload = im.sample(schema, 0)
+		}
+	}
+	return json.Marshal(payload)
+}
+
+// schema resolves the payload schema of a message, unwrapping AsyncAPI 3
+// multi-format schemas.
+func (im *importer) schema(value interface{}) map[string]interface{} {
+	schema, _ := im.resolve(value).(map[string]interface{})
+	if inner, ok := schema["schema"]; ok {
+		if _, multiFormat := schema["schemaFormat"]; multiFormat {
+			schema, _ = im.resolve(inner).(map[string]interface{})
+		}
+	}
+	return schema
+}
+
+func schemaExample(schema map[string]interface{}) (interface{}, bool) {
+	if examples, ok := schema["examples"].([]interface{}); ok && len(examples) > 0 {
+		return examples[0], true
+	}
+	for _, key := range []string{"example", "const", "default"} {
+		if value, ok := schema[key]; ok {
+			return value, true
+		}
+	}
+	if enum, ok := schema["enum"].([]interface{}); ok && len(enum) > 0 {
+		return enum[0], true
+	}
+	return nil, false
+}
+
+// sample builds a value matching a schema.
+func (im *importer) sample(schema map[string]interface{}, depth int) interface{} {
+	if value, ok := schemaExample(schema); ok {
+		return value
+	}
+	if depth > maxRefDepth {
+		return nil
+	}
+	switch schema["type"] {
+	case "object":
+		object := map[string]interface{}{}
+		properties, _ := schema["properties"].(map[string]interface{})
+		for key, property := range properties {
+			child, _ := im.resolve(property).(map[string]interface{})
+			object[key] = im.sample(child, depth+1)
+		}
+		return object
+	case "array":
+		items, _ := im.resolve(schema["items"]).(map[string]interface{})
+		return []interface{}{im.sample(items, depth+1)}
+	case "string":
+		return "string"
+	case "integer", "number":
+		return 0
+	case "boolean":
+		return false
+	}
+	if _, ok := schema["properties"]; ok {
+		return im.sample(map[string]interface{}{"type": "object", "properties": schema["properties"]}, depth)
+	}
+	return nil
+}
+
+// matchers derives the matchers of a reply from its request message.
+func (im *importer) matchers(value interface{}) []match.FieldMatcher {
+	message, ok := im.resolve(value).(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	var matchers []match.FieldMatcher
+	im.schemaMatchers(im.schema(message["payload"]), "", &matchers, 0)
+	if len(matchers) > 0 {
+		return matchers
+	}
+
+	var example map[string]interface{}
+	if examples, ok := message["examples"].([]interface{}); ok && len(examples) > 0 {
+		if first, ok := examples[0].(map[string]interface{}); ok {
+			example, _ = first["payload"].(map[string]interface{})
+		}
+	}
+	for _, field := range []string{"type", "event", "action"} {
+		if value, ok := example[field]; ok {
+			return []match.FieldMatcher{{Field: field, Equals: value}}
+		}
+	}
+	return nil
+}
+
+func (im *importer) schemaMatchers(schema map[string]interface{}, prefix string, matchers *[]match.FieldMatcher, depth int) {
+	properties, _ := schema["properties"].(map[string]interface{})
+	if depth > maxRefDepth {
+		return
+	}
+	keys := make([]string, 0, len(properties))
+	for key := range properties {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		property, _ := im.resolve(properties[key]).(map[string]interface{})
+		field := prefix + key
+		enum, _ := property["enum"].([]interface{})
+		switch {
+		case property["const"] != nil:
+			*matchers = append(*matchers, match.FieldMatcher{Field: field, Equals: property["const"]})
+		case len(enum) == 1:
+			*matchers = append(*matchers, match.FieldMatcher{Field: field, Equals: enum[0]})
+		case property["pattern"] != nil:
+			*matchers = append(*matchers, match.FieldMatcher{Field: field, Regex: fmt.Sprint(property["pattern"])})
+		default:
+			im.schemaMatchers(property, field+".", matchers, depth+1)
+		}
+	}
+}
+
+// resolve follows local $ref pointers.
+func (im *importer) resolve(value interface{}) interface{} {
+	for i := 0; i < maxRefDepth; i++ {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return value
+		}
+		ref, ok := object["$ref"].(string)
+		if !ok {
+			return value
+		}
+		if !strings.HasPrefix(ref, "#/") {
+			im.warn("external reference %s is not supported", ref)
+			return nil
+		}
+		var target interface{} = im.doc
+		for _, token := range strings.Split(ref[2:], "/") {
+			object, ok := target.(map[string]interface{})
+			if !ok {
+				return nil
+			}
+			target = object[unescapePointer(token)]
+		}
+		value = target
+	}
+	return nil
+}
+
+// keys returns the keys of a top-level mapping in document order.
+func (im *importer) keys(name string) []string {
+	node := im.root
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value != name || node.Content[i+1].Kind != yaml.MappingNode {
+			continue
+		}
+		mapping := node.Content[i+1]
+		keys := make([]string, 0, len(mapping.Content)/2)
+		for j := 0; j+1 < len(mapping.Content); j += 2 {
+			keys = append(keys, mapping.Content[j].Value)
+		}
+		return keys
+	}
+	return nil
+}
+
+// YAML encodes a document as YAML. Values go through JSON first so that
+// they keep their JSON field names.
+func YAML(doc map[string]interface{}) ([]byte, error) {
+	encoded, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	var generic interface{}
+	if err := json.Unmarshal(encoded, &generic); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(generic)
+}
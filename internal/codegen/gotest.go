@@ -0,0 +1,339 @@
+// Package codegen turns a recorded session, the stubs in place plus the
+// requests in the journal, into Go test scaffolds built on the library mode
+// packages (pkg/mockserver and pkg/adminclient).
+package codegen
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+	"unicode/utf8"
+
+	grpcServer "mockserver/internal/grpc"
+	"mockserver/internal/journal"
+	"mockserver/internal/stubs"
+)
+
+// skippedHeaders are set by the Go client or are connection specific, so
+// replaying them adds noise.
+var skippedHeaders = map[string]bool{
+	"Accept-Encoding": true,
+	"Connection":      true,
+	"Content-Length":  true,
+	"User-Agent":      true,
+}
+
+// Session is what gets exported.
+type Session struct {
+	HTTPStubs []stubs.Stub
+	GRPCStubs []grpcServer.Stub
+	Requests  []journal.Entry
+}
+
+// Options name the generated package and test function.
+type Options struct {
+	Package string
+	Test    string
+}
+
+type testData struct {
+	Package   string
+	Test      string
+	Stubs     []string
+	Requests  []requestData
+	Verify    []verifyData
+	GRPCStubs string
+	GRPCCalls []string
+	WSConns   []string
+	HasBody   bool
+}
+
+type requestData struct {
+	Comment string
+	Method  string
+	Target  string
+	Headers [][2]string
+	Body    string
+	Status  int
+}
+
+type verifyData struct {
+	Method string
+	Path   string
+	Times  int
+}
+
+var goTestTemplate = template.Must(template.New("gotest").Parse(`// Code generated from a recorded mockserver session. Review the responses and
+// assertions before committing.
+
+package {{.Package}}
+
+import (
+	"encoding/json"
+{{- if .Requests}}
+	"io"
+	"net/http"
+{{- end}}
+{{- if .HasBody}}
+	"strings"
+{{- end}}
+	"testing"
+
+	"mockserver/pkg/adminclient"
+	"mockserver/pkg/mockserver"
+)
+
+func {{.Test}}(t *testing.T) {
+	srv, err := mockserver.Start(mockserver.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Closed after the stub cleanups registered below.
+	t.Cleanup(func() { srv.Close() })
+	admin := adminclient.New(srv.URL())
+{{if .Stubs}}
+	// HTTP stubs in place during the session.
+{{- range .Stubs}}
+	admin.MustCreateStub(t, parseStub(t, {{.}}))
+{{- end}}
+{{end}}
+{{- range .Requests}}
+	// {{.Comment}}
+	{
+		req, err := http.NewRequest({{printf "%q" .Method}}, srv.URL()+{{printf "%q" .Target}}, {{if .Body}}strings.NewReader({{.Body}}){{else}}nil{{end}})
+		if err != nil {
+			t.Fatal(err)
+		}
+{{- range .Headers}}
+		req.Header.Set({{printf "%q" (index . 0)}}, {{printf "%q" (index . 1)}})
+{{- end}}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != {{.Status}} {
+			t.Errorf("%s: got status %d, want {{.Status}}", {{printf "%q" (print .Method " " .Target)}}, resp.StatusCode)
+		}
+	}
+{{end}}
+{{- range .Verify}}
+	admin.ExpectRequests(t, adminclient.Filter{Method: {{printf "%q" .Method}}, Path: {{printf "%q" .Path}}}, {{.Times}})
+{{- end}}
+{{- if .WSConns}}
+
+	// WebSocket connections recorded in the session. Library mode has no
+	// WebSocket endpoints; script them against the standalone server.
+{{- range .WSConns}}
+	//   {{.}}
+{{- end}}
+{{- end}}
+{{- if .GRPCCalls}}
+
+	// gRPC calls recorded in the session. Library mode serves HTTP only; run
+	// them against the standalone server with the stubs below.
+{{- range .GRPCCalls}}
+	//   {{.}}
+{{- end}}
+{{- end}}
+{{- if .GRPCStubs}}
+	//
+	// gRPC stubs ("grpc.stubs" in the configuration file):
+{{.GRPCStubs}}
+{{- end}}
+}
+
+func parseStub(t *testing.T, data string) adminclient.Stub {
+	t.Helper()
+	var stub adminclient.Stub
+	if err := json.Unmarshal([]byte(data), &stub); err != nil {
+		t.Fatalf("invalid stub: %v", err)
+	}
+	return stub
+}
+`))
+
+// GoTest renders the session as a gofmt'ed Go test file. Each HTTP stub is
+// registered on an embedded server, each recorded HTTP request is replayed
+// with its recorded status as the expectation, and the request counts are
+// verified through the journal. gRPC calls and stubs are listed as comments
+// since library mode has no gRPC side.
+func GoTest(session Session, opts Options) ([]byte, error) {
+	data := testData{
+		Package: opts.Package,
+		Test:    testName(opts.Test),
+	}
+	if data.Package == "" {
+		data.Package = "mocktest"
+	}
+	if !isIdentifier(data.Package) {
+		return nil, fmt.Errorf("invalid package name %q", data.Package)
+	}
+
+	for _, stub := range session.HTTPStubs {
+		// IDs are assigned again by the embedded server.
+		stub.ID = ""
+		encoded, err := json.Marshal(stub)
+		if err != nil {
+			return nil, fmt.Errorf("encode stub: %w", err)
+		}
+		data.Stubs = append(data.Stubs, goString(string(encoded)))
+	}
+
+	counts := make(map[verifyData]int)
+	for _, entry := range session.Requests {
+		if entry.Protocol == journal.ProtocolEvent {
+			// Caused by the requests, not sent by the client.
+			continue
+		}
+		if entry.Protocol == journal.ProtocolGRPC {
+			call := entry.Method
+			if entry.Code != "" {
+				call += " -> " + entry.Code
+			}
+			data.GRPCCalls = append(data.GRPCCalls, fmt.Sprintf("%s: %s", entry.ID, call))
+			continue
+		}
+		if isConnectCall(entry) {
+			data.GRPCCalls = append(data.GRPCCalls, fmt.Sprintf("%s: %s (Connect / gRPC-Web) -> %d", entry.ID, entry.Path, entry.Status))
+			continue
+		}
+		if isWebSocket(entry) {
+			data.WSConns = append(data.WSConns, fmt.Sprintf("%s: %s", entry.ID, entry.Path))
+			continue
+		}
+		req := newRequestData(entry)
+		data.HasBody = data.HasBody || req.Body != ""
+		data.Requests = append(data.Requests, req)
+		counts[verifyData{Method: entry.Method, Path: entry.Path}]++
+	}
+	for key, times := range counts {
+		key.Times = times
+		data.Verify = append(data.Verify, key)
+	}
+	sort.Slice(data.Verify, func(i, j int) bool {
+		if data.Verify[i].Path != data.Verify[j].Path {
+			return data.Verify[i].Path < data.Verify[j].Path
+		}
+		return data.Verify[i].Method < data.Verify[j].Method
+	})
+
+	if len(session.GRPCStubs) > 0 {
+		encoded, err := json.MarshalIndent(session.GRPCStubs, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("encode gRPC stubs: %w", err)
+		}
+		var lines []string
+		for _, line := range strings.Split(string(encoded), "\n") {
+			lines = append(lines, "\t//   "+line)
+		}
+		data.GRPCStubs = strings.Join(lines, "\n")
+	}
+
+	var buf bytes.Buffer
+	if err := goTestTemplate.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	source, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("format generated test: %w", err)
+	}
+	return source, nil
+}
+
+func newRequestData(entry journal.Entry) requestData {
+	target := entry.Path
+	if entry.Query != "" {
+		target += "?" + entry.Query
+	}
+	req := requestData{
+		Comment: fmt.Sprintf("%s: %s %s -> %d", entry.ID, entry.Method, target, entry.Status),
+		Method:  entry.Method,
+		Target:  target,
+		Status:  entry.Status,
+	}
+	if req.Status == 0 {
+		req.Status = http.StatusOK
+	}
+	if entry.Body != "" {
+		req.Body = goString(entry.Body)
+	}
+
+	names := make([]string, 0, len(entry.Headers))
+	for name := range entry.Headers {
+		if !skippedHeaders[http.CanonicalHeaderKey(name)] && len(entry.Headers[name]) > 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		req.Headers = append(req.Headers, [2]string{name, entry.Headers[name][0]})
+	}
+	return req
+}
+
+// isConnectCall reports whether an HTTP request is a Connect or gRPC-Web
+// call, i.e. a POST to "/<package>.<Service>/<Method>".
+func isConnectCall(entry journal.Entry) bool {
+	service, method, ok := strings.Cut(strings.TrimPrefix(entry.Path, "/"), "/")
+	return ok && entry.Method == http.MethodPost && strings.Contains(service, ".") &&
+		method != "" && !strings.Contains(method, "/")
+}
+
+func isWebSocket(entry journal.Entry) bool {
+	for name, values := range entry.Headers {
+		if strings.EqualFold(name, "Upgrade") && len(values) > 0 && strings.EqualFold(values[0], "websocket") {
+			return true
+		}
+	}
+	return false
+}
+
+// goString quotes s as a Go string literal, preferring a raw string.
+func goString(s string) string {
+	if utf8.ValidString(s) && !strings.ContainsAny(s, "`\r") {
+		return "`" + s + "`"
+	}
+	return strconv.Quote(s)
+}
+
+// testName turns a session name into a test function name.
+func testName(name string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	if b.Len() == 0 {
+		return "TestRecordedSession"
+	}
+	if !strings.HasPrefix(b.String(), "Test") {
+		return "Test" + b.String()
+	}
+	return b.String()
+}
+
+func isIdentifier(name string) bool {
+	for i, r := range name {
+		if !unicode.IsLetter(r) && r != '_' && (i == 0 || !unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return name != ""
+}
@@ -0,0 +1,156 @@
+// Package config loads the optional JSON configuration file of the mock server.
+//
+// Listener addresses keep coming from environment variables; the file holds
+// the structured settings (stubs and the like) that do not fit in a variable.
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"mockserver/internal/demo"
+	"mockserver/internal/diagnostics"
+	"mockserver/internal/faults"
+	grpcServer "mockserver/internal/grpc"
+	httpHandlers "mockserver/internal/http"
+	"mockserver/internal/jobs"
+	"mockserver/internal/jose"
+	"mockserver/internal/kafka"
+	"mockserver/internal/logging"
+	"mockserver/internal/oidc"
+	"mockserver/internal/proxy"
+	"mockserver/internal/queue"
+	"mockserver/internal/sftp"
+	"mockserver/internal/signedurl"
+	"mockserver/internal/smtp"
+	"mockserver/internal/stubs"
+	"mockserver/internal/tcp"
+	"mockserver/internal/timefmt"
+	"mockserver/internal/tus"
+	"mockserver/internal/udp"
+	"mockserver/internal/verify"
+	wsHandlers "mockserver/internal/websocket"
+	"mockserver/internal/xds"
+)
+
+type Config struct {
+	HTTP      HTTPConfig        `json:"http"`
+	GRPC      GRPCConfig        `json:"grpc"`
+	WebSocket wsHandlers.Config `json:"websocket"`
+	XDS       xds.Config        `json:"xds"`
+	Proxy     ProxyConfig       `json:"proxy"`
+	// SFTP configures the fixture server on SFTP_ADDR.
+	SFTP sftp.Config `json:"sftp"`
+	// Kafka configures the broker on KAFKA_ADDR.
+	Kafka kafka.Config `json:"kafka"`
+	// SMTP configures the capturing mail server on SMTP_ADDR.
+	SMTP smtp.Config `json:"smtp"`
+	// TCP configures the scripted raw TCP listener on TCP_ADDR.
+	TCP tcp.Config `json:"tcp"`
+	// UDP configures the echoing and scripted UDP responder on UDP_ADDR.
+	UDP udp.Config `json:"udp"`
+	// Verifications are request expectations evaluated by
+	// /__admin/verifications/report.
+	Verifications []verify.Spec `json:"verifications,omitempty"`
+	// Faults are the fault profiles and the calendar of their activation.
+	Faults faults.Config `json:"faults"`
+	// ReadOnly rejects the admin requests that change the server with 403
+	// (also READ_ONLY=true); PUT /__admin/read-only switches it at runtime.
+	ReadOnly bool `json:"read_only,omitempty"`
+	// Demo enables the self-animating demo data (also DEMO_MODE=true).
+	Demo demo.Config `json:"demo"`
+	// OIDC enables the mock identity provider (also OIDC_ENABLED=true).
+	OIDC oidc.Config `json:"oidc"`
+	// Jobs enables the async job API simulation (also JOBS_ENABLED=true).
+	Jobs jobs.Config `json:"jobs"`
+	// Tus enables the resumable upload server (also TUS_ENABLED=true).
+	Tus tus.Config `json:"tus"`
+	// Queues enables the SQS-style message queue (also QUEUES_ENABLED=true).
+	Queues queue.Config `json:"queues"`
+	// SignedURLs issues and enforces signed URLs (also
+	// SIGNED_URLS_ENABLED=true).
+	SignedURLs signedurl.Config `json:"signed_urls"`
+	// Logging sets the level and format of the logs, per subsystem if need
+	// be (also LOG_LEVEL, LOG_FORMAT and LOG_LEVELS).
+	Logging logging.Config `json:"logging"`
+	// Debug serves the pprof profiles and expvar variables under
+	// /__admin/debug (also DEBUG_ENDPOINTS=true).
+	Debug diagnostics.Config `json:"debug"`
+}
+
+type ProxyConfig struct {
+	// Front configures the corporate proxy simulation on PROXY_FRONT_ADDR.
+	Front proxy.FrontConfig `json:"front"`
+	// Tunnel routes CONNECT and forward proxy traffic on PROXY_ADDR.
+	Tunnel proxy.TunnelConfig `json:"tunnel"`
+}
+
+type HTTPConfig struct {
+	Stubs []stubs.Stub `json:"stubs,omitempty"`
+	// Keys sign and encrypt stub payloads (jws and jwe); an RSA and an EC
+	// key are generated when none is configured.
+	Keys []jose.KeyConfig `json:"keys,omitempty"`
+	// Timestamps sets the format and zone of the timestamp field of the
+	// built-in endpoints and of {{.Timestamp}} in stub templates.
+	Timestamps timefmt.Config `json:"timestamps"`
+	// QuietEcho makes /echo answer without a timestamp, and POST /echo with
+	// the request body alone (also ?quiet=true per request).
+	QuietEcho bool `json:"quiet_echo,omitempty"`
+	// DevMode reloads stub body files when they change and reports their
+	// errors in the responses (also DEV_MODE=true).
+	DevMode bool `json:"dev_mode,omitempty"`
+	// Proxy forwards the requests no stub or route answers to an upstream
+	// server and records them.
+	Proxy stubs.ProxyConfig `json:"proxy"`
+	// HTTP2 tunes the cleartext HTTP/2 (h2c) served next to HTTP/1.1.
+	HTTP2 httpHandlers.HTTP2Config `json:"http2"`
+}
+
+type GRPCConfig struct {
+	// DescriptorSets are binary FileDescriptorSet files describing services
+	// that should be mocked in addition to the built-in MockService.
+	DescriptorSets []string          `json:"descriptor_sets,omitempty"`
+	Stubs          []grpcServer.Stub `json:"stubs,omitempty"`
+	// Trailers are sent on every MockService call.
+	Trailers map[string]string `json:"trailers,omitempty"`
+	// MaxRecvMsgSize and MaxSendMsgSize limit message sizes in bytes (gRPC
+	// defaults: 4 MiB received, unlimited sent).
+	MaxRecvMsgSize int `json:"max_recv_msg_size,omitempty"`
+	MaxSendMsgSize int `json:"max_send_msg_size,omitempty"`
+	// Compression compresses every MockService response with the named codec
+	// ("gzip"). Compressed requests are accepted regardless.
+	Compression string `json:"compression,omitempty"`
+	// Keepalive sets ping enforcement and connection age policies.
+	Keepalive grpcServer.KeepaliveConfig `json:"keepalive"`
+	// Proxy forwards calls to unimplemented, unstubbed methods to an upstream
+	// server and records them.
+	Proxy grpcServer.ProxyConfig `json:"proxy"`
+}
+
+// Load reads the configuration file at path. An empty path yields the default
+// (empty) configuration.
+func Load(path string) (*Config, error) {
+	cfg := &Config{}
+	if path == "" {
+		return cfg, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("parse config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Parse reads a configuration given as JSON.
+func Parse(data []byte) (*Config, error) {
+	cfg := &Config{}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("parse config: %w", err)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,186 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+)
+
+// Changes is what a configuration changes compared to another. Stubs and
+// verifications are compared entry by entry: entries with an id are matched
+// by id, the others by content.
+type Changes struct {
+	HTTPStubs     ListChanges `json:"http_stubs"`
+	GRPCStubs     ListChanges `json:"grpc_stubs"`
+	Verifications ListChanges `json:"verifications"`
+	// Settings lists the other settings that changed ("http.keys",
+	// "websocket"); they only take effect on a restart.
+	Settings []string `json:"settings"`
+}
+
+// ListChanges lists the added and removed entries of a list, and the new
+// version of the entries whose id was kept.
+type ListChanges struct {
+	Added   []json.RawMessage `json:"added"`
+	Removed []json.RawMessage `json:"removed"`
+	Changed []json.RawMessage `json:"changed"`
+}
+
+// Empty reports whether nothing changed.
+func (c Changes) Empty() bool {
+	return c.HTTPStubs.Empty() && c.GRPCStubs.Empty() && c.Verifications.Empty() && len(c.Settings) == 0
+}
+
+// Empty reports whether nothing changed.
+func (l ListChanges) Empty() bool {
+	return len(l.Added) == 0 && len(l.Removed) == 0 && len(l.Changed) == 0
+}
+
+// Clone returns a deep copy, to keep the configuration as loaded while the
+// servers normalize the stubs they compile.
+func (c *Config) Clone() (*Config, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	clone := &Config{}
+	if err := json.Unmarshal(data, clone); err != nil {
+		return nil, err
+	}
+	return clone, nil
+}
+
+// Diff compares the configuration next to c.
+func (c *Config) Diff(next *Config) (Changes, error) {
+	var changes Changes
+	var err error
+	if changes.HTTPStubs, err = diffList(c.HTTP.Stubs, next.HTTP.Stubs); err != nil {
+		return changes, err
+	}
+	if changes.GRPCStubs, err = diffList(c.GRPC.Stubs, next.GRPC.Stubs); err != nil {
+		return changes, err
+	}
+	if changes.Verifications, err = diffList(c.Verifications, next.Verifications); err != nil {
+		return changes, err
+	}
+	changes.Settings, err = diffSettings(c, next)
+	return changes, err
+}
+
+func diffList[T any](before, after []T) (ListChanges, error) {
+	changes := ListChanges{Added: []json.RawMessage{}, Removed: []json.RawMessage{}, Changed: []json.RawMessage{}}
+	encode := func(entries []T) ([]json.RawMessage, []string, error) {
+		raws := make([]json.RawMessage, len(entries))
+		keys := make([]string, len(entries))
+		for i, entry := range entries {
+			raw, err := json.Marshal(entry)
+			if err != nil {
+				return nil, nil, err
+			}
+			var identified struct {
+				ID string `json:"id"`
+			}
+			json.Unmarshal(raw, &identified)
+			raws[i], keys[i] = raw, "content:"+string(raw)
+			if identified.ID != "" {
+				keys[i] = "id:" + identified.ID
+			}
+		}
+		return raws, keys, nil
+	}
+	oldRaws, oldKeys, err := encode(before)
+	if err != nil {
+		return changes, err
+	}
+	newRaws, newKeys, err := encode(after)
+	if err != nil {
+		return changes, err
+	}
+
+	// Duplicate entries without id are paired in order
+	unmatched := make(map[string][]int)
+	for i, key := range oldKeys {
+		unmatched[key] = append(unmatched[key], i)
+	}
+	matched := make([]bool, len(oldRaws))
+	for i, key := range newKeys {
+		candidates := unmatched[key]
+		if len(candidates) == 0 {
+			changes.Added = append(changes.Added, newRaws[i])
+			continue
+		}
+		unmatched[key] = candidates[1:]
+		matched[candidates[0]] = true
+		if !bytes.Equal(oldRaws[candidates[0]], newRaws[i]) {
+			changes.Changed = append(changes.Changed, newRaws[i])
+		}
+	}
+	for i, raw := range oldRaws {
+		if !matched[i] {
+			changes.Removed = append(changes.Removed, raw)
+		}
+	}
+	return changes, nil
+}
+
+// diffSettings names the changed settings other than the stubs and
+// verifications, down to the second level ("http.keys").
+func diffSettings(before, after *Config) ([]string, error) {
+	settings := func(c *Config) (map[string]map[string]json.RawMessage, error) {
+		stripped := *c
+		stripped.HTTP.Stubs, stripped.GRPC.Stubs, stripped.Verifications = nil, nil, nil
+		data, err := json.Marshal(stripped)
+		if err != nil {
+			return nil, err
+		}
+		var sections map[string]json.RawMessage
+		if err := json.Unmarshal(data, &sections); err != nil {
+			return nil, err
+		}
+		fields := make(map[string]map[string]json.RawMessage, len(sections))
+		for name, raw := range sections {
+			var section map[string]json.RawMessage
+			if json.Unmarshal(raw, &section) != nil {
+				// Not an object: compared as a whole
+				section = map[string]json.RawMessage{"": raw}
+			}
+			fields[name] = section
+		}
+		return fields, nil
+	}
+	old, err := settings(before)
+	if err != nil {
+		return nil, err
+	}
+	next, err := settings(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changed := []string{}
+	for name := range union(old, next) {
+		for field := range union(old[name], next[name]) {
+			if bytes.Equal(old[name][field], next[name][field]) {
+				continue
+			}
+			if field == "" {
+				changed = append(changed, name)
+			} else {
+				changed = append(changed, name+"."+field)
+			}
+		}
+	}
+	sort.Strings(changed)
+	return changed, nil
+}
+
+func union[V any](a, b map[string]V) map[string]bool {
+	keys := make(map[string]bool, len(a)+len(b))
+	for key := range a {
+		keys[key] = true
+	}
+	for key := range b {
+		keys[key] = true
+	}
+	return keys
+}
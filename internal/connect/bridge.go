@@ -0,0 +1,313 @@
+// Package connect serves the mocked gRPC services over the Connect protocol
+// (and gRPC-Web, which connect-go handlers speak as well) and as transcoded
+// JSON/REST routes.
+//
+// Every Connect and REST call is forwarded in-process to the gRPC server, so
+// interceptors (fault injection, retry sequences, stubs) and dynamically
+// loaded services behave exactly as they do for native gRPC clients.
+package connect
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	connectgo "connectrpc.com/connect"
+	"github.com/labstack/echo/v4"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"mockserver/internal/logging"
+)
+
+var logger = logging.For("connect")
+
+// Bridge translates Connect requests into calls on a gRPC client connection.
+type Bridge struct {
+	conn *grpc.ClientConn
+}
+
+func NewBridge(conn *grpc.ClientConn) *Bridge {
+	return &Bridge{conn: conn}
+}
+
+// Services finds a served service by its full name.
+type Services func(name protoreflect.FullName) (protoreflect.ServiceDescriptor, bool)
+
+// Middleware serves the methods of the services found by services at the
+// standard "/<package.Service>/<Method>" paths. Services are looked up on
+// every request, so those loaded while the server runs are served as well;
+// other requests go on to next.
+func (b *Bridge) Middleware(services Services) echo.MiddlewareFunc {
+	var handlers sync.Map // procedure -> http.Handler
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			r := c.Request()
+			if r.Method != http.MethodPost {
+				return next(c)
+			}
+			service, method, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+			if !ok || method == "" || strings.Contains(method, "/") {
+				return next(c)
+			}
+
+			procedure := "/" + service + "/" + method
+			handler, ok := handlers.Load(procedure)
+			if !ok {
+				sd, found := services(protoreflect.FullName(service))
+				if !found {
+					return next(c)
+				}
+				md := sd.Methods().ByName(protoreflect.Name(method))
+				if md == nil {
+					return next(c)
+				}
+				handler, _ = handlers.LoadOrStore(procedure, b.methodHandler(procedure, md))
+			}
+			// Metrics and logs group the calls of a service under one route
+			c.SetPath("/" + service + "/*")
+			handler.(http.Handler).ServeHTTP(c.Response(), r)
+			return nil
+		}
+	}
+}
+
+func (b *Bridge) methodHandler(procedure string, md protoreflect.MethodDescriptor) http.Handler {
+	options := []connectgo.HandlerOption{
+		connectgo.WithSchema(md),
+		connectgo.WithRequestInitializer(initializeRequest),
+	}
+
+	switch {
+	case md.IsStreamingClient() && md.IsStreamingServer():
+		return connectgo.NewBidiStreamHandler(procedure, b.bidi(procedure, md), options...)
+	case md.IsStreamingClient():
+		return connectgo.NewClientStreamHandler(procedure, b.clientStream(procedure, md), options...)
+	case md.IsStreamingServer():
+		return connectgo.NewServerStreamHandler(procedure, b.serverStream(procedure, md), options...)
+	default:
+		return connectgo.NewUnaryHandler(procedure, b.unary(procedure, md), options...)
+	}
+}
+
+// initializeRequest gives dynamic request messages their descriptor before
+// connect-go unmarshals into them.
+func initializeRequest(spec connectgo.Spec, message any) error {
+	msg, ok := message.(*dynamicpb.Message)
+	if !ok {
+		return nil
+	}
+	md, ok := spec.Schema.(protoreflect.MethodDescriptor)
+	if !ok {
+		return errors.New("missing method schema")
+	}
+	*msg = *dynamicpb.NewMessage(md.Input())
+	return nil
+}
+
+func (b *Bridge) unary(procedure string, md protoreflect.MethodDescriptor) func(context.Context, *connectgo.Request[dynamicpb.Message]) (*connectgo.Response[dynamicpb.Message], error) {
+	return func(ctx context.Context, req *connectgo.Request[dynamicpb.Message]) (*connectgo.Response[dynamicpb.Message], error) {
+		logger.DebugContext(ctx, "Bridging call", "protocol", req.Peer().Protocol, "procedure", procedure)
+
+		ctx = outgoingContext(ctx, req.Header())
+		var header, trailer metadata.MD
+		res := dynamicpb.NewMessage(md.Output())
+
+		err := b.conn.Invoke(ctx, procedure, req.Msg, res, grpc.Header(&header), grpc.Trailer(&trailer))
+		if err != nil {
+			return nil, toConnectError(err, header, trailer)
+		}
+
+		response := connectgo.NewResponse(res)
+		copyMetadata(response.Header(), header)
+		copyMetadata(response.Trailer(), trailer)
+		return response, nil
+	}
+}
+
+func (b *Bridge) serverStream(procedure string, md protoreflect.MethodDescriptor) func(context.Context, *connectgo.Request[dynamicpb.Message], *connectgo.ServerStream[dynamicpb.Message]) error {
+	return func(ctx context.Context, req *connectgo.Request[dynamicpb.Message], stream *connectgo.ServerStream[dynamicpb.Message]) error {
+		logger.DebugContext(ctx, "Bridging server stream", "protocol", req.Peer().Protocol, "procedure", procedure)
+
+		ctx, cancel := context.WithCancel(outgoingContext(ctx, req.Header()))
+		defer cancel()
+
+		upstream, err := b.conn.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true}, procedure)
+		if err != nil {
+			return toConnectError(err, nil, nil)
+		}
+		if err := upstream.SendMsg(req.Msg); err != nil {
+			return b.finish(upstream, err, stream.ResponseTrailer())
+		}
+		if err := upstream.CloseSend(); err != nil {
+			return b.finish(upstream, err, stream.ResponseTrailer())
+		}
+
+		headerSent := false
+		for {
+			res := dynamicpb.NewMessage(md.Output())
+			if err := upstream.RecvMsg(res); err != nil {
+				if !headerSent {
+					header, _ := upstream.Header()
+					copyMetadata(stream.ResponseHeader(), header)
+				}
+				return b.finish(upstream, err, stream.ResponseTrailer())
+			}
+			if !headerSent {
+				header, _ := upstream.Header()
+				copyMetadata(stream.ResponseHeader(), header)
+				headerSent = true
+			}
+			if err := stream.Send(res); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (b *Bridge) clientStream(procedure string, md protoreflect.MethodDescriptor) func(context.Context, *connectgo.ClientStream[dynamicpb.Message]) (*connectgo.Response[dynamicpb.Message], error) {
+	return func(ctx context.Context, stream *connectgo.ClientStream[dynamicpb.Message]) (*connectgo.Response[dynamicpb.Message], error) {
+		logger.DebugContext(ctx, "Bridging client stream", "protocol", stream.Peer().Protocol, "procedure", procedure)
+
+		ctx, cancel := context.WithCancel(outgoingContext(ctx, stream.RequestHeader()))
+		defer cancel()
+
+		upstream, err := b.conn.NewStream(ctx, &grpc.StreamDesc{ClientStreams: true}, procedure)
+		if err != nil {
+			return nil, toConnectError(err, nil, nil)
+		}
+
+		for stream.Receive() {
+			if err := upstream.SendMsg(stream.Msg()); err != nil {
+				break
+			}
+		}
+		if err := stream.Err(); err != nil {
+			return nil, err
+		}
+		upstream.CloseSend()
+
+		res := dynamicpb.NewMessage(md.Output())
+		err = upstream.RecvMsg(res)
+		header, _ := upstream.Header()
+		if err != nil {
+			return nil, toConnectError(err, header, upstream.Trailer())
+		}
+		// Drain to EOF so the trailers are available.
+		for upstream.RecvMsg(dynamicpb.NewMessage(md.Output())) == nil {
+		}
+
+		response := connectgo.NewResponse(res)
+		copyMetadata(response.Header(), header)
+		copyMetadata(response.Trailer(), upstream.Trailer())
+		return response, nil
+	}
+}
+
+func (b *Bridge) bidi(procedure string, md protoreflect.MethodDescriptor) func(context.Context, *connectgo.BidiStream[dynamicpb.Message, dynamicpb.Message]) error {
+	return func(ctx context.Context, stream *connectgo.BidiStream[dynamicpb.Message, dynamicpb.Message]) error {
+		logger.DebugContext(ctx, "Bridging bidi stream", "protocol", stream.Peer().Protocol, "procedure", procedure)
+
+		ctx, cancel := context.WithCancel(outgoingContext(ctx, stream.RequestHeader()))
+		defer cancel()
+
+		upstream, err := b.conn.NewStream(ctx, &grpc.StreamDesc{ClientStreams: true, ServerStreams: true}, procedure)
+		if err != nil {
+			return toConnectError(err, nil, nil)
+		}
+
+		// Forward client messages upstream while responses flow back below.
+		go func() {
+			for {
+				msg, err := stream.Receive()
+				if err != nil {
+					if !errors.Is(err, io.EOF) {
+						cancel()
+					}
+					upstream.CloseSend()
+					return
+				}
+				if err := upstream.SendMsg(msg); err != nil {
+					return
+				}
+			}
+		}()
+
+		headerSent := false
+		for {
+			res := dynamicpb.NewMessage(md.Output())
+			err := upstream.RecvMsg(res)
+			if !headerSent {
+				header, _ := upstream.Header()
+				copyMetadata(stream.ResponseHeader(), header)
+				headerSent = true
+			}
+			if err != nil {
+				return b.finish(upstream, err, stream.ResponseTrailer())
+			}
+			if err := stream.Send(res); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// finish turns the end of an upstream stream into the Connect result.
+func (b *Bridge) finish(upstream grpc.ClientStream, err error, trailer http.Header) error {
+	copyMetadata(trailer, upstream.Trailer())
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	return toConnectError(err, nil, upstream.Trailer())
+}
+
+// protocolHeaders are owned by the Connect/gRPC transports and never forwarded.
+var protocolHeaders = map[string]bool{
+	"accept-encoding": true, "connection": true, "content-encoding": true,
+	"content-length": true, "content-type": true, "host": true, "te": true,
+	"trailer": true, "transfer-encoding": true, "user-agent": true,
+}
+
+func isProtocolHeader(key string) bool {
+	key = strings.ToLower(key)
+	return protocolHeaders[key] || strings.HasPrefix(key, "connect-") ||
+		strings.HasPrefix(key, "grpc-") || strings.HasPrefix(key, "x-grpc-web") || strings.HasPrefix(key, ":")
+}
+
+func outgoingContext(ctx context.Context, header http.Header) context.Context {
+	md := metadata.MD{}
+	for key, values := range header {
+		if isProtocolHeader(key) {
+			continue
+		}
+		md.Append(strings.ToLower(key), values...)
+	}
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+func copyMetadata(dst http.Header, md metadata.MD) {
+	for key, values := range md {
+		if isProtocolHeader(key) {
+			continue
+		}
+		for _, value := range values {
+			dst.Add(key, value)
+		}
+	}
+}
+
+// toConnectError maps a gRPC status onto a Connect error. Both protocols share
+// the same code numbering.
+func toConnectError(err error, header, trailer metadata.MD) error {
+	st := status.Convert(err)
+	connectErr := connectgo.NewError(connectgo.Code(st.Code()), errors.New(st.Message()))
+	copyMetadata(connectErr.Meta(), header)
+	copyMetadata(connectErr.Meta(), trailer)
+	return connectErr
+}
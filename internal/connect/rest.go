@@ -0,0 +1,460 @@
+package connect
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// Route maps an HTTP method and path onto an RPC, in the manner of
+// grpc-gateway. Path wildcards ("{id}") and query parameters set top-level
+// request fields by their proto or JSON name.
+type Route struct {
+	Method string
+	Path   string
+	RPC    string
+}
+
+// MockServiceRoutes exposes MockService under /v1/mock.
+var MockServiceRoutes = []Route{
+	{Method: http.MethodGet, Path: "/v1/mock/echo", RPC: "Echo"},
+	{Method: http.MethodPost, Path: "/v1/mock/echo", RPC: "Echo"},
+	{Method: http.MethodGet, Path: "/v1/mock/stream/{id}", RPC: "ServerStream"},
+	{Method: http.MethodPost, Path: "/v1/mock/stream", RPC: "ServerStream"},
+	{Method: http.MethodPost, Path: "/v1/mock/client-stream", RPC: "ClientStream"},
+	{Method: http.MethodPost, Path: "/v1/mock/bidi", RPC: "BidiStream"},
+	{Method: http.MethodGet, Path: "/v1/mock/events", RPC: "Subscribe"},
+	{Method: http.MethodGet, Path: "/v1/mock/all-types", RPC: "SampleAllTypes"},
+	{Method: http.MethodPost, Path: "/v1/mock/all-types", RPC: "EchoAllTypes"},
+	{Method: http.MethodGet, Path: "/v1/mock/payload", RPC: "Payload"},
+	{Method: http.MethodPost, Path: "/v1/mock/payload", RPC: "Payload"},
+}
+
+var (
+	restUnmarshal = protojson.UnmarshalOptions{}
+	restMarshal   = protojson.MarshalOptions{EmitUnpopulated: true}
+)
+
+// REST builds an http.Handler transcoding JSON requests on the routes into
+// calls of the service. Unary responses are JSON objects; server streams are
+// newline-delimited {"result": ...} objects, ending with {"error": ...} when
+// the call fails midway. Client and bidi streams read their messages from a
+// JSON array or newline-delimited JSON body.
+func (b *Bridge) REST(service protoreflect.ServiceDescriptor, routes []Route) (http.Handler, error) {
+	mux := http.NewServeMux()
+	for _, route := range routes {
+		md := service.Methods().ByName(protoreflect.Name(route.RPC))
+		if md == nil {
+			return nil, fmt.Errorf("route %s %s: %s has no method %s", route.Method, route.Path, service.FullName(), route.RPC)
+		}
+		procedure := "/" + string(service.FullName()) + "/" + string(md.Name())
+		mux.Handle(route.Method+" "+route.Path, &restHandler{bridge: b, procedure: procedure, md: md})
+	}
+	logger.Debug("Serving REST routes", "service", string(service.FullName()), "routes", len(routes))
+	return mux, nil
+}
+
+type restHandler struct {
+	bridge    *Bridge
+	procedure string
+	md        protoreflect.MethodDescriptor
+}
+
+func (h *restHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger.DebugContext(r.Context(), "Transcoding request", "procedure", h.procedure)
+	ctx := outgoingContext(r.Context(), r.Header)
+
+	if h.md.IsStreamingClient() {
+		h.stream(ctx, w, r)
+		return
+	}
+
+	req := dynamicpb.NewMessage(h.md.Input())
+	if r.Method != http.MethodGet {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeRESTError(w, status.Error(codes.InvalidArgument, err.Error()))
+			return
+		}
+		if len(strings.TrimSpace(string(body))) > 0 {
+			if err := restUnmarshal.Unmarshal(body, req); err != nil {
+				writeRESTError(w, status.Errorf(codes.InvalidArgument, "invalid request body: %v", err))
+				return
+			}
+		}
+	}
+	if err := setParams(req, r); err != nil {
+		writeRESTError(w, status.Error(codes.InvalidArgument, err.Error()))
+		return
+	}
+
+	if !h.md.IsStreamingServer() {
+		var header, trailer metadata.MD
+		res := dynamicpb.NewMessage(h.md.Output())
+		err := h.bridge.conn.Invoke(ctx, h.procedure, req, res, grpc.Header(&header), grpc.Trailer(&trailer))
+		copyMetadata(w.Header(), header)
+		copyMetadata(w.Header(), trailer)
+		if err != nil {
+			writeRESTError(w, err)
+			return
+		}
+		writeRESTMessage(w, res)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	upstream, err := h.bridge.conn.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true}, h.procedure)
+	if err == nil {
+		err = upstream.SendMsg(req)
+	}
+	if err == nil {
+		err = upstream.CloseSend()
+	}
+	if err != nil {
+		writeRESTError(w, err)
+		return
+	}
+	h.relay(w, upstream)
+}
+
+// stream serves client and bidi streaming RPCs. Request messages are sent
+// upstream as they are decoded while responses are written back.
+func (h *restHandler) stream(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	upstream, err := h.bridge.conn.NewStream(ctx, &grpc.StreamDesc{ClientStreams: true, ServerStreams: h.md.IsStreamingServer()}, h.procedure)
+	if err != nil {
+		writeRESTError(w, err)
+		return
+	}
+
+	// HTTP/1.x handlers can only read the body while writing the response
+	// with full duplex enabled; HTTP/2 always allows it.
+	http.NewResponseController(w).EnableFullDuplex()
+
+	sendErr := make(chan error, 1)
+	go func() {
+		err := decodeMessages(r.Body, h.md.Input(), upstream.SendMsg)
+		upstream.CloseSend()
+		if err != nil {
+			cancel()
+		}
+		sendErr <- err
+	}()
+
+	if !h.md.IsStreamingServer() {
+		res := dynamicpb.NewMessage(h.md.Output())
+		err := upstream.RecvMsg(res)
+		if bodyErr := <-sendErr; bodyErr != nil {
+			writeRESTError(w, status.Errorf(codes.InvalidArgument, "invalid request body: %v", bodyErr))
+			return
+		}
+		header, _ := upstream.Header()
+		copyMetadata(w.Header(), header)
+		if err != nil {
+			copyMetadata(w.Header(), upstream.Trailer())
+			writeRESTError(w, err)
+			return
+		}
+		for upstream.RecvMsg(dynamicpb.NewMessage(h.md.Output())) == nil {
+		}
+		copyMetadata(w.Header(), upstream.Trailer())
+		writeRESTMessage(w, res)
+		return
+	}
+
+	h.relay(w, upstream)
+	if bodyErr := <-sendErr; bodyErr != nil {
+		logger.DebugContext(r.Context(), "Cannot send request body", "procedure", h.procedure, "error", bodyErr)
+	}
+}
+
+// relay writes a server stream as newline-delimited JSON. The status is only
+// known once the first message or the error arrives.
+func (h *restHandler) relay(w http.ResponseWriter, upstream grpc.ClientStream) {
+	controller := http.NewResponseController(w)
+	started := false
+	for {
+		res := dynamicpb.NewMessage(h.md.Output())
+		err := upstream.RecvMsg(res)
+		if !started {
+			header, _ := upstream.Header()
+			copyMetadata(w.Header(), header)
+			if err != nil && !errors.Is(err, io.EOF) {
+				writeRESTError(w, err)
+				return
+			}
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			w.WriteHeader(http.StatusOK)
+			started = true
+		}
+		if errors.Is(err, io.EOF) {
+			return
+		}
+		if err != nil {
+			st := status.Convert(err)
+			writeJSONLine(w, map[string]interface{}{"error": restError(st)})
+			return
+		}
+
+		data, err := restMarshal.Marshal(res)
+		if err != nil {
+			writeJSONLine(w, map[string]interface{}{"error": restError(status.New(codes.Internal, err.Error()))})
+			return
+		}
+		writeJSONLine(w, map[string]json.RawMessage{"result": data})
+		if err := controller.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// decodeMessages reads a JSON array or a sequence of JSON objects (one per
+// line, typically) and passes each as a message to send.
+func decodeMessages(body io.Reader, desc protoreflect.MessageDescriptor, send func(interface{}) error) error {
+	reader := bufio.NewReader(body)
+	decoder := json.NewDecoder(reader)
+
+	array := false
+	for {
+		b, err := reader.Peek(1)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if b[0] == ' ' || b[0] == '\t' || b[0] == '\r' || b[0] == '\n' {
+			reader.ReadByte()
+			continue
+		}
+		if b[0] == '[' {
+			decoder.Token()
+			array = true
+		}
+		break
+	}
+
+	for array && decoder.More() || !array {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			if !array && errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		msg := dynamicpb.NewMessage(desc)
+		if err := restUnmarshal.Unmarshal(raw, msg); err != nil {
+			return err
+		}
+		if err := send(msg); err != nil {
+			// The upstream call ended; its status is reported by the reader.
+			return nil
+		}
+	}
+	return nil
+}
+
+// setParams copies path wildcards and query parameters into top-level fields.
+func setParams(msg *dynamicpb.Message, r *http.Request) error {
+	fields := msg.Descriptor().Fields()
+	lookup := func(name string) protoreflect.FieldDescriptor {
+		if fd := fields.ByName(protoreflect.Name(name)); fd != nil {
+			return fd
+		}
+		return fields.ByJSONName(name)
+	}
+
+	for name, values := range r.URL.Query() {
+		fd := lookup(name)
+		if fd == nil {
+			return fmt.Errorf("unknown query parameter %q", name)
+		}
+		if err := setField(msg, fd, values); err != nil {
+			return err
+		}
+	}
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if value := r.PathValue(string(fd.Name())); value != "" {
+			if err := setField(msg, fd, []string{value}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func setField(msg *dynamicpb.Message, fd protoreflect.FieldDescriptor, values []string) error {
+	if fd.IsMap() || fd.Kind() == protoreflect.MessageKind || fd.Kind() == protoreflect.GroupKind {
+		return fmt.Errorf("field %s cannot be set from a parameter", fd.Name())
+	}
+	if fd.IsList() {
+		list := msg.Mutable(fd).List()
+		for _, value := range values {
+			v, err := parseScalar(fd, value)
+			if err != nil {
+				return err
+			}
+			list.Append(v)
+		}
+		return nil
+	}
+	v, err := parseScalar(fd, values[len(values)-1])
+	if err != nil {
+		return err
+	}
+	msg.Set(fd, v)
+	return nil
+}
+
+func parseScalar(fd protoreflect.FieldDescriptor, value string) (protoreflect.Value, error) {
+	invalid := func(err error) (protoreflect.Value, error) {
+		return protoreflect.Value{}, fmt.Errorf("invalid value %q for %s: %v", value, fd.Name(), err)
+	}
+
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(value), nil
+	case protoreflect.BytesKind:
+		data, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			data, err = base64.URLEncoding.DecodeString(value)
+		}
+		if err != nil {
+			return invalid(err)
+		}
+		return protoreflect.ValueOfBytes(data), nil
+	case protoreflect.BoolKind:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return invalid(err)
+		}
+		return protoreflect.ValueOfBool(b), nil
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByName(protoreflect.Name(value)); ev != nil {
+			return protoreflect.ValueOfEnum(ev.Number()), nil
+		}
+		n, err := strconv.ParseInt(value, 10, 32)
+		if err != nil {
+			return invalid(err)
+		}
+		return protoreflect.ValueOfEnum(protoreflect.EnumNumber(n)), nil
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		n, err := strconv.ParseInt(value, 10, 32)
+		if err != nil {
+			return invalid(err)
+		}
+		return protoreflect.ValueOfInt32(int32(n)), nil
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return invalid(err)
+		}
+		return protoreflect.ValueOfInt64(n), nil
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		n, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return invalid(err)
+		}
+		return protoreflect.ValueOfUint32(uint32(n)), nil
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		n, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return invalid(err)
+		}
+		return protoreflect.ValueOfUint64(n), nil
+	case protoreflect.FloatKind:
+		f, err := strconv.ParseFloat(value, 32)
+		if err != nil {
+			return invalid(err)
+		}
+		return protoreflect.ValueOfFloat32(float32(f)), nil
+	case protoreflect.DoubleKind:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return invalid(err)
+		}
+		return protoreflect.ValueOfFloat64(f), nil
+	}
+	return protoreflect.Value{}, fmt.Errorf("unsupported field type %s for %s", fd.Kind(), fd.Name())
+}
+
+func writeRESTMessage(w http.ResponseWriter, msg proto.Message) {
+	data, err := restMarshal.Marshal(msg)
+	if err != nil {
+		writeRESTError(w, status.Error(codes.Internal, err.Error()))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+// writeRESTError answers with the HTTP status of the gRPC code and a
+// grpc-gateway style {"code", "message", "details"} body.
+func writeRESTError(w http.ResponseWriter, err error) {
+	st := status.Convert(err)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(httpStatus(st.Code()))
+	json.NewEncoder(w).Encode(restError(st))
+}
+
+func restError(st *status.Status) map[string]interface{} {
+	return map[string]interface{}{
+		"code":    int(st.Code()),
+		"message": st.Message(),
+		"details": []interface{}{},
+	}
+}
+
+func writeJSONLine(w io.Writer, value interface{}) {
+	json.NewEncoder(w).Encode(value)
+}
+
+// httpStatus maps gRPC codes the way grpc-gateway does.
+func httpStatus(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,49 @@
+// Package correlation extracts the correlation ID that ties together what a
+// single test triggers across protocols: the X-Mock-Test-ID header (or
+// metadata) when present, otherwise the trace ID of a W3C traceparent.
+package correlation
+
+import (
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// Header is the explicit correlation header. gRPC metadata uses its
+// lower-case form.
+const Header = "X-Mock-Test-ID"
+
+// FromHeader returns the correlation ID of an HTTP request, or "".
+func FromHeader(h http.Header) string {
+	if id := strings.TrimSpace(h.Get(Header)); id != "" {
+		return id
+	}
+	return traceID(h.Get("Traceparent"))
+}
+
+// FromMetadata returns the correlation ID carried by gRPC metadata, or "".
+func FromMetadata(md metadata.MD) string {
+	if values := md.Get(Header); len(values) > 0 && strings.TrimSpace(values[0]) != "" {
+		return strings.TrimSpace(values[0])
+	}
+	if values := md.Get("traceparent"); len(values) > 0 {
+		return traceID(values[0])
+	}
+	return ""
+}
+
+// traceID returns the trace-id field of a traceparent value
+// ("00-<trace-id>-<parent-id>-<flags>"), or "" when it is malformed.
+func traceID(traceparent string) string {
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) < 4 || len(parts[1]) != 32 || strings.Trim(parts[1], "0") == "" {
+		return ""
+	}
+	for _, r := range parts[1] {
+		if !strings.ContainsRune("0123456789abcdef", r) {
+			return ""
+		}
+	}
+	return parts[1]
+}
@@ -0,0 +1,34 @@
+// Package dashboard serves the web UI of the admin API: the live traffic
+// with the stub that answered each request, the open WebSocket connections,
+// and forms to create, edit and delete HTTP stubs. The page is static and
+// embedded in the binary; it drives the admin API like any other client.
+package dashboard
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Path is where the dashboard is served.
+const Path = "/__admin/ui"
+
+//go:embed static
+var static embed.FS
+
+// Register serves the dashboard on e.
+func Register(e *echo.Echo) {
+	files, _ := fs.Sub(static, "static")
+	fileServer := http.StripPrefix(Path+"/", http.FileServer(http.FS(files)))
+	e.GET(Path, func(c echo.Context) error {
+		return c.Redirect(http.StatusMovedPermanently, Path+"/")
+	})
+	e.GET(Path+"/*", func(c echo.Context) error {
+		// The page changes with the server version; browsers revalidate it
+		c.Response().Header().Set("Cache-Control", "no-cache")
+		fileServer.ServeHTTP(c.Response(), c.Request())
+		return nil
+	})
+}
@@ -0,0 +1,508 @@
+// Dashboard of the mock server. It only uses the admin API: the request
+// journal, the WebSocket connections and the HTTP stubs.
+"use strict";
+
+const REQUESTS_LIMIT = 200;
+const KEPT_ENTRIES = 500;
+const TRAFFIC_INTERVAL = 1000;
+const CONNECTIONS_INTERVAL = 2000;
+const STUBS_INTERVAL = 5000;
+
+const $ = (id) => document.getElementById(id);
+
+const state = {
+  entries: [], // newest first
+  lastID: 0,
+  paused: false,
+  selected: null,
+  stubs: [],
+  editing: null, // ID of the stub in the form, null for a new one
+};
+
+// api calls the admin API. Polls are marked quiet so that the server logs
+// them at debug level only.
+async function api(method, path, body, quiet) {
+  const headers = {};
+  if (quiet) {
+    headers["X-Mock-Quiet"] = "1";
+  }
+  const init = { method, headers };
+  if (body !== undefined) {
+    headers["Content-Type"] = "application/json";
+    init.body = typeof body === "string" ? body : JSON.stringify(body);
+  }
+  const resp = await fetch(path, init);
+  if (resp.status === 204) {
+    return null;
+  }
+  const data = await resp.json().catch(() => null);
+  if (!resp.ok) {
+    const err = new Error((data && data.error) || resp.status + " " + resp.statusText);
+    err.status = resp.status;
+    err.details = data && (data.details || data.provided);
+    throw err;
+  }
+  return data;
+}
+
+function cell(text, className) {
+  const td = document.createElement("td");
+  td.textContent = text === undefined || text === null ? "" : String(text);
+  if (className) {
+    td.className = className;
+  }
+  return td;
+}
+
+function button(label, onClick, className) {
+  const b = document.createElement("button");
+  b.type = "button";
+  b.textContent = label;
+  if (className) {
+    b.className = className;
+  }
+  b.addEventListener("click", (event) => {
+    event.stopPropagation();
+    onClick();
+  });
+  return b;
+}
+
+function setStatus(text, error) {
+  const status = $("status");
+  status.textContent = text;
+  status.classList.toggle("error", Boolean(error));
+}
+
+function formatTime(timestamp) {
+  const date = new Date(timestamp);
+  return date.toLocaleTimeString([], { hour12: false }) + "." + String(date.getMilliseconds()).padStart(3, "0");
+}
+
+function entryNumber(entry) {
+  return Number(String(entry.id).replace(/^\D+/, "")) || 0;
+}
+
+// Tabs
+
+for (const tab of document.querySelectorAll(".tab")) {
+  tab.addEventListener("click", () => showTab(tab.dataset.tab));
+}
+
+function showTab(name) {
+  for (const tab of document.querySelectorAll(".tab")) {
+    tab.classList.toggle("active", tab.dataset.tab === name);
+  }
+  for (const panel of document.querySelectorAll(".panel")) {
+    panel.classList.toggle("active", panel.id === name);
+  }
+}
+
+// Live traffic
+
+async function pollTraffic() {
+  if (state.paused) {
+    return;
+  }
+  try {
+    const data = await api("GET", "/__admin/requests?limit=" + REQUESTS_LIMIT, undefined, true);
+    const entries = data.requests || [];
+    const newest = entries.length ? entryNumber(entries[entries.length - 1]) : 0;
+    if (newest < state.lastID) {
+      // The journal was reset
+      state.lastID = 0;
+    }
+    const fresh = entries.filter((entry) => entryNumber(entry) > state.lastID).reverse();
+    if (fresh.length) {
+      state.lastID = entryNumber(fresh[0]);
+      state.entries = fresh.concat(state.entries).slice(0, KEPT_ENTRIES);
+      renderTraffic();
+    }
+    setStatus("Live");
+  } catch (err) {
+    setStatus("Disconnected: " + err.message, true);
+  }
+}
+
+function matchOf(entry) {
+  if (entry.protocol !== "http") {
+    return { text: "", className: "" };
+  }
+  if (entry.outbound) {
+    return { text: "outbound", className: "muted" };
+  }
+  if (entry.stub_id) {
+    return { text: entry.stub_id, className: "matched" };
+  }
+  return { text: "unmatched", className: "unmatched" };
+}
+
+function renderTraffic() {
+  const protocol = $("traffic-protocol").value;
+  const path = $("traffic-path").value;
+  const unmatchedOnly = $("traffic-unmatched").checked;
+  const rows = $("traffic-rows");
+  rows.replaceChildren();
+  let shown = 0;
+  for (const entry of state.entries) {
+    const match = matchOf(entry);
+    if ((protocol && entry.protocol !== protocol) ||
+        (path && !(entry.path || "").startsWith(path)) ||
+        (unmatchedOnly && match.className !== "unmatched")) {
+      continue;
+    }
+    shown++;
+    const tr = document.createElement("tr");
+    tr.classList.toggle("selected", state.selected === entry.id);
+    tr.appendChild(cell(formatTime(entry.timestamp), "mono"));
+    tr.appendChild(cell(entry.protocol));
+    tr.appendChild(cell(entry.method));
+    tr.appendChild(cell((entry.path || "") + (entry.query ? "?" + entry.query : ""), "mono path"));
+    const status = entry.protocol === "grpc" ? entry.code : entry.status || "";
+    tr.appendChild(cell(status, statusClass(entry)));
+    const stub = cell(match.text, match.className);
+    if (entry.stub_id) {
+      stub.title = "Edit the stub";
+      stub.addEventListener("click", (event) => {
+        event.stopPropagation();
+        editStubByID(entry.stub_id);
+      });
+    }
+    tr.appendChild(stub);
+    tr.appendChild(cell(entry.latency_ms.toFixed(1) + " ms", "number"));
+    tr.addEventListener("click", () => showEntry(entry));
+    rows.appendChild(tr);
+  }
+  $("traffic-empty").hidden = shown > 0;
+}
+
+function statusClass(entry) {
+  if (entry.protocol === "grpc") {
+    return entry.code && entry.code !== "OK" ? "bad" : "good";
+  }
+  if (!entry.status) {
+    return "";
+  }
+  return entry.status >= 400 ? "bad" : "good";
+}
+
+function showEntry(entry) {
+  state.selected = entry.id;
+  $("traffic-detail-title").textContent = entry.id + " " + entry.method + " " + (entry.path || "");
+  $("traffic-detail-body").textContent = JSON.stringify(entry, null, 2);
+  $("traffic-to-stub").hidden = entry.protocol !== "http" || entry.outbound;
+  $("traffic-detail").hidden = false;
+  renderTraffic();
+}
+
+$("traffic-detail-close").addEventListener("click", () => {
+  state.selected = null;
+  $("traffic-detail").hidden = true;
+  renderTraffic();
+});
+
+$("traffic-to-stub").addEventListener("click", () => {
+  const entry = state.entries.find((e) => e.id === state.selected);
+  if (!entry) {
+    return;
+  }
+  const headers = entry.response_headers || {};
+  const contentType = (headers["Content-Type"] || [""])[0];
+  const stub = {
+    name: entry.method + " " + entry.path,
+    request: { method: entry.method, path: entry.path },
+    response: { status: entry.status || 200 },
+  };
+  if (contentType) {
+    stub.response.headers = { "Content-Type": contentType };
+  }
+  setBody(stub, contentType, entry.response_body || "");
+  editStub(stub, null);
+  showTab("stubs");
+});
+
+$("traffic-pause").addEventListener("click", () => {
+  state.paused = !state.paused;
+  $("traffic-pause").textContent = state.paused ? "Resume" : "Pause";
+  setStatus(state.paused ? "Paused" : "Live");
+  if (!state.paused) {
+    pollTraffic();
+  }
+});
+
+$("traffic-clear").addEventListener("click", () => {
+  state.entries = [];
+  renderTraffic();
+});
+
+for (const id of ["traffic-protocol", "traffic-path", "traffic-unmatched"]) {
+  $(id).addEventListener("input", renderTraffic);
+}
+
+// WebSocket connections
+
+async function pollConnections() {
+  const rows = $("connection-rows");
+  try {
+    const data = await api("GET", "/__admin/ws/connections", undefined, true);
+    const connections = data.connections || [];
+    $("connection-count").textContent = connections.length;
+    rows.replaceChildren();
+    for (const conn of connections) {
+      const tr = document.createElement("tr");
+      tr.appendChild(cell(conn.id, "mono"));
+      tr.appendChild(cell(conn.endpoint, "mono"));
+      tr.appendChild(cell(conn.room));
+      tr.appendChild(cell(conn.username));
+      tr.appendChild(cell(conn.remote_addr, "mono"));
+      tr.appendChild(cell(formatTime(conn.connected_at), "mono"));
+      tr.appendChild(cell(conn.queued, "number"));
+      const actions = cell("");
+      actions.appendChild(button("Disconnect", () => disconnect(conn.id), "danger"));
+      tr.appendChild(actions);
+      rows.appendChild(tr);
+    }
+    $("connection-empty").hidden = connections.length > 0;
+  } catch (err) {
+    $("connection-empty").textContent = "Connections unavailable: " + err.message;
+    $("connection-empty").hidden = false;
+  }
+}
+
+async function disconnect(id) {
+  try {
+    await api("DELETE", "/__admin/ws/connections/" + encodeURIComponent(id));
+  } catch (err) {
+    alert("Disconnect failed: " + err.message);
+  }
+  pollConnections();
+}
+
+// Stubs
+
+async function loadStubs(quiet) {
+  try {
+    const data = await api("GET", "/__admin/stubs", undefined, quiet);
+    state.stubs = data.stubs || [];
+    renderStubs();
+  } catch (err) {
+    $("stub-empty").textContent = "Stubs unavailable: " + err.message;
+    $("stub-empty").hidden = false;
+  }
+}
+
+function renderStubs() {
+  $("stub-count").textContent = state.stubs.length;
+  const rows = $("stub-rows");
+  rows.replaceChildren();
+  for (const stub of state.stubs) {
+    const tr = document.createElement("tr");
+    tr.classList.toggle("selected", state.editing === stub.id);
+    tr.appendChild(cell(stub.id, "mono"));
+    tr.appendChild(cell(stub.name));
+    tr.appendChild(cell(stub.priority || 0, "number"));
+    tr.appendChild(cell(stub.request.method || "ANY"));
+    tr.appendChild(cell(stub.request.path || stub.request.path_regex, "mono path"));
+    tr.appendChild(cell(stub.response.status || 200));
+    const actions = cell("");
+    actions.appendChild(button("Edit", () => editStub(stub, stub.id)));
+    actions.appendChild(button("Delete", () => deleteStub(stub.id), "danger"));
+    tr.appendChild(actions);
+    tr.addEventListener("click", () => editStub(stub, stub.id));
+    rows.appendChild(tr);
+  }
+  $("stub-empty").hidden = state.stubs.length > 0;
+}
+
+async function editStubByID(id) {
+  try {
+    editStub(await api("GET", "/__admin/stubs/" + encodeURIComponent(id)), id);
+    showTab("stubs");
+  } catch (err) {
+    alert("Stub " + id + ": " + err.message);
+  }
+}
+
+async function deleteStub(id) {
+  if (!confirm("Delete stub " + id + "?")) {
+    return;
+  }
+  try {
+    await api("DELETE", "/__admin/stubs/" + encodeURIComponent(id));
+    if (state.editing === id) {
+      editStub(newStub(), null);
+    }
+  } catch (err) {
+    alert("Delete failed: " + err.message);
+  }
+  loadStubs();
+}
+
+function newStub() {
+  return {
+    request: { method: "GET", path: "/" },
+    response: { status: 200, headers: { "Content-Type": "application/json" }, json_body: {} },
+  };
+}
+
+// setBody sets the response body of a stub from text: JSON bodies as
+// json_body, anything else as body.
+function setBody(stub, contentType, text) {
+  delete stub.response.body;
+  delete stub.response.json_body;
+  if (contentType.includes("json") && text.trim() !== "") {
+    try {
+      stub.response.json_body = JSON.parse(text);
+      return;
+    } catch (err) {
+      // Not JSON after all: kept as text
+    }
+  }
+  if (text !== "") {
+    stub.response.body = text;
+  }
+}
+
+const form = $("stub-form");
+
+// editStub loads a stub into the form; id is null for a new stub.
+function editStub(stub, id) {
+  state.editing = id;
+  const copy = JSON.parse(JSON.stringify(stub));
+  delete copy.id;
+  $("stub-form-title").textContent = id ? "Edit " + id : "New stub";
+  form.elements.json.value = JSON.stringify(copy, null, 2);
+  fieldsFromJSON();
+  showError("");
+  renderStubs();
+}
+
+function fieldsFromJSON() {
+  let stub;
+  try {
+    stub = JSON.parse(form.elements.json.value);
+  } catch (err) {
+    showError("Invalid stub JSON: " + err.message);
+    return;
+  }
+  showError("");
+  const request = stub.request || {};
+  const response = stub.response || {};
+  const headers = response.headers || {};
+  form.elements.name.value = stub.name || "";
+  form.elements.method.value = (request.method || "ANY").toUpperCase();
+  form.elements.path.value = request.path || request.path_regex || "";
+  form.elements.priority.value = stub.priority || 0;
+  form.elements.status.value = response.status || 200;
+  form.elements.content_type.value = headers["Content-Type"] || "";
+  if (response.json_body !== undefined) {
+    form.elements.body.value = JSON.stringify(response.json_body, null, 2);
+  } else {
+    form.elements.body.value = response.body || "";
+  }
+}
+
+function jsonFromFields() {
+  let stub;
+  try {
+    stub = JSON.parse(form.elements.json.value || "{}");
+  } catch (err) {
+    showError("Fix the stub JSON first: " + err.message);
+    return;
+  }
+  showError("");
+  stub.request = stub.request || {};
+  stub.response = stub.response || {};
+  const name = form.elements.name.value.trim();
+  if (name) {
+    stub.name = name;
+  } else {
+    delete stub.name;
+  }
+  const priority = Number(form.elements.priority.value);
+  if (priority) {
+    stub.priority = priority;
+  } else {
+    delete stub.priority;
+  }
+  stub.request.method = form.elements.method.value;
+  const path = form.elements.path.value.trim();
+  if (stub.request.path_regex !== undefined && path === stub.request.path_regex) {
+    delete stub.request.path;
+  } else {
+    delete stub.request.path_regex;
+    stub.request.path = path;
+  }
+  stub.response.status = Number(form.elements.status.value) || 200;
+  const contentType = form.elements.content_type.value.trim();
+  stub.response.headers = stub.response.headers || {};
+  if (contentType) {
+    stub.response.headers["Content-Type"] = contentType;
+  } else {
+    delete stub.response.headers["Content-Type"];
+  }
+  if (Object.keys(stub.response.headers).length === 0) {
+    delete stub.response.headers;
+  }
+  setBody(stub, contentType, form.elements.body.value);
+  form.elements.json.value = JSON.stringify(stub, null, 2);
+}
+
+for (const name of ["name", "method", "path", "priority", "status", "content_type", "body"]) {
+  form.elements[name].addEventListener("input", jsonFromFields);
+}
+form.elements.json.addEventListener("input", fieldsFromJSON);
+
+function showError(message, details) {
+  const error = $("stub-error");
+  error.textContent = details ? message + ": " + details : message;
+  error.hidden = !message;
+}
+
+form.addEventListener("submit", async (event) => {
+  event.preventDefault();
+  let stub;
+  try {
+    stub = JSON.parse(form.elements.json.value);
+  } catch (err) {
+    showError("Invalid stub JSON: " + err.message);
+    return;
+  }
+  try {
+    let saved;
+    if (state.editing) {
+      saved = await api("PUT", "/__admin/stubs/" + encodeURIComponent(state.editing), stub);
+    } else {
+      saved = await api("POST", "/__admin/stubs", stub);
+    }
+    editStub(saved, saved.id);
+    loadStubs();
+  } catch (err) {
+    showError(err.message, err.details);
+  }
+});
+
+$("stub-new").addEventListener("click", () => editStub(newStub(), null));
+$("stub-cancel").addEventListener("click", () => editStub(newStub(), null));
+
+// Read-only mode
+
+async function pollReadOnly() {
+  try {
+    const data = await api("GET", "/__admin/read-only", undefined, true);
+    $("read-only").hidden = !data.read_only;
+  } catch (err) {
+    $("read-only").hidden = true;
+  }
+}
+
+function every(interval, poll) {
+  poll();
+  setInterval(poll, interval);
+}
+
+editStub(newStub(), null);
+every(TRAFFIC_INTERVAL, pollTraffic);
+every(CONNECTIONS_INTERVAL, pollConnections);
+every(STUBS_INTERVAL, () => loadStubs(true));
+every(STUBS_INTERVAL, pollReadOnly);
@@ -0,0 +1,164 @@
+// Package demo makes the mock look alive without any configuration: seeded
+// catalogue stubs whose numbers move on every call, and periodic WebSocket
+// pushes of the same kind of data.
+package demo
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"mockserver/internal/logging"
+	"mockserver/internal/stubs"
+	wsHandlers "mockserver/internal/websocket"
+)
+
+var logger = logging.For("demo")
+
+// Room is the chat room that receives the demo pushes, besides the
+// /ws/broadcast clients.
+const Room = "demo"
+
+const defaultPushInterval = 2 * time.Second
+
+// Config holds the demo mode settings; DEMO_MODE=true also enables it.
+type Config struct {
+	Enabled bool `json:"enabled"`
+	// PushInterval is the time between WebSocket pushes, 2s by default.
+	PushInterval string `json:"push_interval,omitempty"`
+	// Seed makes the generated values repeat from one run to the next.
+	Seed uint64 `json:"seed,omitempty"`
+}
+
+// Validate checks the push interval.
+func (c Config) Validate() error {
+	if _, err := c.interval(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (c Config) interval() (time.Duration, error) {
+	if c.PushInterval == "" {
+		return defaultPushInterval, nil
+	}
+	interval, err := time.ParseDuration(c.PushInterval)
+	if err != nil || interval <= 0 {
+		return 0, fmt.Errorf("demo: invalid push_interval %q", c.PushInterval)
+	}
+	return interval, nil
+}
+
+type product struct {
+	ID       int
+	Name     string
+	Category string
+	Price    float64
+	Stock    int
+}
+
+var products = []product{
+	{1, "Espresso Machine", "kitchen", 249.00, 42},
+	{2, "Noise Cancelling Headphones", "audio", 199.99, 130},
+	{3, "Mechanical Keyboard", "computers", 89.50, 75},
+	{4, "Trail Running Shoes", "sports", 129.95, 58},
+	{5, "Smart Watch", "wearables", 299.00, 96},
+	{6, "Standing Desk", "furniture", 549.00, 17},
+}
+
+// Stubs returns the demo stubs: GET /demo/products, /demo/products/:id (1 to
+// 6) and /demo/stats. Prices drift and stock levels fluctuate on every call;
+// stubs loaded from the configuration take precedence.
+func Stubs(cfg Config) []stubs.Stub {
+	seed := cfg.Seed
+	items := make([]string, len(products))
+	list := make([]stubs.Stub, 0, len(products)+2)
+	for i, p := range products {
+		items[i] = productTemplate(p)
+		list = append(list, demoStub(fmt.Sprintf("/demo/products/%d", p.ID), items[i], seed+uint64(p.ID)))
+	}
+	list = append(list,
+		demoStub("/demo/products", "["+strings.Join(items, ",")+"]", seed),
+		demoStub("/demo/stats", `{`+
+			`"active_users": {{drift "users" 1200 40 800 2000}}, `+
+			`"orders_today": {{drift "orders" 350 6 300 5000}}, `+
+			`"revenue": {{drift "revenue" 48250.00 350 30000 90000 2}}, `+
+			`"conversion_rate": {{drift "conversion" 3.2 0.1 1.5 5.5}}, `+
+			`"cpu": {{jitter 45 15}}, `+
+			`"updated_at": "{{now}}"}`, seed),
+	)
+	return list
+}
+
+func productTemplate(p product) string {
+	return fmt.Sprintf(`{"id": %d, "name": %q, "category": %q, `+
+		`"price": {{drift "price-%d" %.2f %.2f %.2f %.2f 2}}, "stock": {{drift "stock-%d" %d 3 0 %d}}}`,
+		p.ID, p.Name, p.Category,
+		p.ID, p.Price, p.Price*0.01, p.Price*0.8, p.Price*1.2,
+		p.ID, p.Stock, p.Stock*2)
+}
+
+func demoStub(path, body string, seed uint64) stubs.Stub {
+	return stubs.Stub{
+		Name:     "demo",
+		Priority: -1,
+		Request:  stubs.Request{Method: "GET", Path: path},
+		Response: stubs.Response{
+			Headers:    map[string]string{"Content-Type": "application/json"},
+			Body:       body,
+			Template:   true,
+			JitterSeed: &seed,
+		},
+	}
+}
+
+// Push sends a "demo.update" message with drifting product prices and
+// activity counters to the /ws/broadcast clients and the demo room every
+// push interval.
+func Push(cfg Config, ws *wsHandlers.WebSocketHandlers) {
+	interval, err := cfg.interval()
+	if err != nil {
+		interval = defaultPushInterval
+	}
+	rng := rand.New(rand.NewPCG(cfg.Seed, cfg.Seed))
+	prices := make([]float64, len(products))
+	for i, p := range products {
+		prices[i] = p.Price
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for seq := 1; ; seq++ {
+		<-ticker.C
+		quotes := make([]map[string]interface{}, len(products))
+		for i, p := range products {
+			step := (rng.Float64()*2 - 1) * p.Price * 0.01
+			prices[i] = math.Round(math.Max(p.Price*0.8, math.Min(p.Price*1.2, prices[i]+step))*100) / 100
+			quotes[i] = map[string]interface{}{"id": p.ID, "name": p.Name, "price": prices[i]}
+		}
+		update := map[string]interface{}{
+			"seq":          seq,
+			"prices":       quotes,
+			"active_users": 800 + rng.IntN(1200),
+			"new_orders":   rng.IntN(20),
+		}
+		for _, room := range []string{"", Room} {
+			ws.Push(room, wsHandlers.Message{Type: "demo.update", Data: update})
+		}
+	}
+}
+
+// Start registers the demo stubs and starts the pushes.
+func Start(cfg Config, engine *stubs.Engine, ws *wsHandlers.WebSocketHandlers) error {
+	for _, stub := range Stubs(cfg) {
+		if _, err := engine.Add(stub); err != nil {
+			return err
+		}
+	}
+	go Push(cfg, ws)
+	interval, _ := cfg.interval()
+	logger.Info("Demo data enabled", "routes", "/demo/products,/demo/stats", "room", Room, "interval", interval.String())
+	return nil
+}
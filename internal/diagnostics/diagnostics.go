@@ -0,0 +1,82 @@
+// Package diagnostics serves the profiles of the Go runtime (net/http/pprof)
+// and the expvar variables, to profile the server when it misbehaves under
+// load.
+package diagnostics
+
+import (
+	"expvar"
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// The endpoints are served under AdminPrefix on the HTTP port, and under
+// the usual /debug on the diagnostics listener.
+const (
+	AdminPrefix    = "/__admin/debug"
+	ListenerPrefix = "/debug"
+)
+
+// Config enables the endpoints on the HTTP port (also DEBUG_ENDPOINTS=true).
+// DEBUG_ADDR serves them on a listener of their own, whether or not they
+// are on the HTTP port.
+type Config struct {
+	Enabled bool `json:"enabled"`
+	// BlockProfileRate and MutexProfileFraction turn on the block and mutex
+	// profiles, empty otherwise; see runtime.SetBlockProfileRate and
+	// runtime.SetMutexProfileFraction. Both cost some throughput.
+	BlockProfileRate     int `json:"block_profile_rate,omitempty"`
+	MutexProfileFraction int `json:"mutex_profile_fraction,omitempty"`
+}
+
+// Validate checks the profile rates.
+func (c Config) Validate() error {
+	if c.BlockProfileRate < 0 {
+		return fmt.Errorf("block_profile_rate must not be negative")
+	}
+	if c.MutexProfileFraction < 0 {
+		return fmt.Errorf("mutex_profile_fraction must not be negative")
+	}
+	return nil
+}
+
+// Apply sets the block and mutex profile rates of the runtime.
+func (c Config) Apply() {
+	runtime.SetBlockProfileRate(c.BlockProfileRate)
+	runtime.SetMutexProfileFraction(c.MutexProfileFraction)
+}
+
+var start = time.Now()
+
+func init() {
+	expvar.Publish("goroutines", expvar.Func(func() any { return runtime.NumGoroutine() }))
+	expvar.Publish("uptime_seconds", expvar.Func(func() any { return time.Since(start).Seconds() }))
+}
+
+// Handler serves the pprof index and profiles under prefix+"/pprof/", and
+// the expvar variables (memstats, cmdline, goroutines, uptime_seconds) at
+// prefix+"/vars".
+func Handler(prefix string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(prefix+"/pprof/", func(w http.ResponseWriter, r *http.Request) {
+		switch name := strings.TrimPrefix(r.URL.Path, prefix+"/pprof/"); name {
+		case "":
+			pprof.Index(w, r)
+		case "cmdline":
+			pprof.Cmdline(w, r)
+		case "profile":
+			pprof.Profile(w, r)
+		case "symbol":
+			pprof.Symbol(w, r)
+		case "trace":
+			pprof.Trace(w, r)
+		default:
+			pprof.Handler(name).ServeHTTP(w, r)
+		}
+	})
+	mux.Handle(prefix+"/vars", expvar.Handler())
+	return mux
+}
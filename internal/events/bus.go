@@ -0,0 +1,126 @@
+// Package events provides the in-process event bus that connects the protocol
+// surfaces: WebSocket traffic and admin pushes are published here, and gRPC
+// subscribers receive them.
+package events
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"mockserver/internal/logging"
+)
+
+var logger = logging.For("events")
+
+// subscriberBuffer is the number of events a slow subscriber may lag behind
+// before new events are dropped for it.
+const subscriberBuffer = 256
+
+// Event is a message on the bus. CorrelationID carries the X-Mock-Test-ID or
+// trace ID of the request that caused the event, when there was one.
+type Event struct {
+	ID            string      `json:"id"`
+	Topic         string      `json:"topic"`
+	Type          string      `json:"type"`
+	Source        string      `json:"source"`
+	Data          interface{} `json:"data"`
+	Timestamp     int64       `json:"timestamp"`
+	CorrelationID string      `json:"correlation_id,omitempty"`
+}
+
+// Filter selects events by topic and type. Empty lists match everything; a
+// topic ending in "*" matches by prefix ("ws.chat.*").
+type Filter struct {
+	Topics []string
+	Types  []string
+}
+
+func (f Filter) Matches(event Event) bool {
+	return matchesAny(f.Topics, event.Topic) && matchesAny(f.Types, event.Type)
+}
+
+func matchesAny(patterns []string, value string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if strings.HasSuffix(pattern, "*") {
+			if strings.HasPrefix(value, strings.TrimSuffix(pattern, "*")) {
+				return true
+			}
+		} else if pattern == value {
+			return true
+		}
+	}
+	return false
+}
+
+type subscriber struct {
+	filter Filter
+	ch     chan Event
+}
+
+type Bus struct {
+	subscribers map[*subscriber]bool
+	mutex       sync.RWMutex
+	nextID      atomic.Uint64
+}
+
+func NewBus() *Bus {
+	return &Bus{subscribers: make(map[*subscriber]bool)}
+}
+
+// Publish delivers the event to every matching subscriber without blocking.
+// Missing IDs and timestamps are filled in. Publishing on a nil bus is a no-op.
+func (b *Bus) Publish(event Event) Event {
+	if b == nil {
+		return event
+	}
+	if event.ID == "" {
+		event.ID = fmt.Sprintf("evt-%d", b.nextID.Add(1))
+	}
+	if event.Timestamp == 0 {
+		event.Timestamp = time.Now().Unix()
+	}
+
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	for sub := range b.subscribers {
+		if !sub.filter.Matches(event) {
+			continue
+		}
+		select {
+		case sub.ch <- event:
+		default:
+			logger.Warn("Subscriber is lagging, dropped event", "event_id", event.ID, "topic", event.Topic)
+		}
+	}
+	return event
+}
+
+// Subscribe registers a subscriber. The returned cancel function must be
+// called to release it; the channel is closed afterwards.
+func (b *Bus) Subscribe(filter Filter) (<-chan Event, func()) {
+	sub := &subscriber{filter: filter, ch: make(chan Event, subscriberBuffer)}
+
+	b.mutex.Lock()
+	b.subscribers[sub] = true
+	logger.Debug("Subscriber added", "subscribers", len(b.subscribers))
+	b.mutex.Unlock()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			b.mutex.Lock()
+			delete(b.subscribers, sub)
+			logger.Debug("Subscriber removed", "subscribers", len(b.subscribers))
+			b.mutex.Unlock()
+			close(sub.ch)
+		})
+	}
+	return sub.ch, cancel
+}
@@ -0,0 +1,298 @@
+package faults
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"mockserver/internal/logging"
+)
+
+var logger = logging.For("faults")
+
+// Config holds the fault profiles and their calendar from the configuration
+// file.
+type Config struct {
+	Profiles  map[string]Profile `json:"profiles,omitempty"`
+	Schedules []Schedule         `json:"schedules,omitempty"`
+}
+
+// Validate reports the first invalid profile or schedule.
+func (c Config) Validate() error {
+	_, err := NewCalendar(c)
+	return err
+}
+
+// Errors returned by the calendar.
+var (
+	ErrUnknownProfile = errors.New("unknown profile")
+	ErrProfileInUse   = errors.New("profile is scheduled")
+)
+
+// Activation is an active profile, with what activated it: a schedule ID,
+// or "manual". Until is zero for a manual activation without a duration.
+type Activation struct {
+	Profile string    `json:"profile"`
+	Reason  string    `json:"reason"`
+	Until   time.Time `json:"until,omitempty"`
+}
+
+// ScheduleStatus is a schedule with its current or next window. NextStart
+// and NextEnd are omitted once a one-off window is over.
+type ScheduleStatus struct {
+	Schedule
+	Active    bool       `json:"active"`
+	NextStart *time.Time `json:"next_start,omitempty"`
+	NextEnd   *time.Time `json:"next_end,omitempty"`
+}
+
+// Status is the state of the calendar at a point in time.
+type Status struct {
+	Profiles  map[string]Profile `json:"profiles"`
+	Schedules []ScheduleStatus   `json:"schedules"`
+	Active    []Activation       `json:"active"`
+}
+
+// Calendar holds the profiles, when they are active, and injects their
+// faults.
+type Calendar struct {
+	mutex     sync.RWMutex
+	profiles  map[string]*profile
+	sources   map[string]Profile
+	schedules []*schedule
+	nextID    int
+	// manual are the profiles activated through the admin API, with the
+	// end of their activation (zero: until deactivated).
+	manual map[string]time.Time
+	// active are the profiles last seen active, to log transitions.
+	active map[string]bool
+}
+
+// NewCalendar validates the configuration.
+func NewCalendar(cfg Config) (*Calendar, error) {
+	c := &Calendar{
+		profiles: map[string]*profile{},
+		sources:  map[string]Profile{},
+		manual:   map[string]time.Time{},
+		active:   map[string]bool{},
+	}
+	for name, p := range cfg.Profiles {
+		if err := c.setProfileLocked(name, p); err != nil {
+			return nil, err
+		}
+	}
+	for i, s := range cfg.Schedules {
+		if _, err := c.addScheduleLocked(s); err != nil {
+			return nil, fmt.Errorf("schedule %d: %w", i+1, err)
+		}
+	}
+	return c, nil
+}
+
+// SetProfile adds or replaces a profile.
+func (c *Calendar) SetProfile(name string, p Profile) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.setProfileLocked(name, p)
+}
+
+func (c *Calendar) setProfileLocked(name string, p Profile) error {
+	compiled, err := p.compile(name)
+	if err != nil {
+		return err
+	}
+	c.profiles[name], c.sources[name] = compiled, p
+	return nil
+}
+
+// DeleteProfile removes a profile that no schedule uses.
+func (c *Calendar) DeleteProfile(name string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.profiles[name] == nil {
+		return ErrUnknownProfile
+	}
+	for _, s := range c.schedules {
+		if s.Profile == name {
+			return fmt.Errorf("%w by %s", ErrProfileInUse, s.ID)
+		}
+	}
+	delete(c.profiles, name)
+	delete(c.sources, name)
+	delete(c.manual, name)
+	return nil
+}
+
+// AddSchedule adds a schedule and returns it with its ID.
+func (c *Calendar) AddSchedule(s Schedule) (Schedule, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.addScheduleLocked(s)
+}
+
+func (c *Calendar) addScheduleLocked(s Schedule) (Schedule, error) {
+	if c.profiles[s.Profile] == nil {
+		return Schedule{}, fmt.Errorf("%w %q", ErrUnknownProfile, s.Profile)
+	}
+	c.nextID++
+	s.ID = fmt.Sprintf("schedule-%d", c.nextID)
+	compiled, err := s.compile()
+	if err != nil {
+		c.nextID--
+		return Schedule{}, err
+	}
+	c.schedules = append(c.schedules, compiled)
+	return s, nil
+}
+
+// DeleteSchedule removes a schedule, false when it is unknown.
+func (c *Calendar) DeleteSchedule(id string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for i, s := range c.schedules {
+		if s.ID == id {
+			c.schedules = append(c.schedules[:i], c.schedules[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Activate turns a profile on, for a duration or, when it is 0, until it
+// is deactivated.
+func (c *Calendar) Activate(name string, duration time.Duration) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.profiles[name] == nil {
+		return ErrUnknownProfile
+	}
+	var until time.Time
+	if duration > 0 {
+		until = time.Now().Add(duration)
+	}
+	c.manual[name] = until
+	return nil
+}
+
+// Deactivate ends the manual activation of a profile, false when it was not
+// activated. Its schedules still apply.
+func (c *Calendar) Deactivate(name string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	_, ok := c.manual[name]
+	delete(c.manual, name)
+	return ok
+}
+
+// Status returns the profiles, the schedules with their windows and the
+// active profiles at now.
+func (c *Calendar) Status(now time.Time) Status {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	status := Status{Profiles: map[string]Profile{}, Schedules: []ScheduleStatus{}, Active: c.activationsLocked(now)}
+	for name, p := range c.sources {
+		status.Profiles[name] = p
+	}
+	for _, s := range c.schedules {
+		entry := ScheduleStatus{Schedule: s.Schedule}
+		if start, end, ok := s.window(now); ok {
+			entry.Active = !now.Before(start)
+			entry.NextStart, entry.NextEnd = &start, &end
+		}
+		status.Schedules = append(status.Schedules, entry)
+	}
+	return status
+}
+
+// activationsLocked lists the active profiles by name; a profile both
+// scheduled and activated manually is listed once per reason.
+func (c *Calendar) activationsLocked(now time.Time) []Activation {
+	activations := []Activation{}
+	for name, until := range c.manual {
+		if until.IsZero() || now.Before(until) {
+			activations = append(activations, Activation{Profile: name, Reason: "manual", Until: until})
+		}
+	}
+	for _, s := range c.schedules {
+		if until, ok := s.active(now); ok {
+			activations = append(activations, Activation{Profile: s.Profile, Reason: s.ID, Until: until})
+		}
+	}
+	sort.SliceStable(activations, func(a, b int) bool { return activations[a].Profile < activations[b].Profile })
+	return activations
+}
+
+// current returns the active profiles, in name order, logging the profiles
+// that turned on or off since the last call.
+func (c *Calendar) current() []*profile {
+	now := time.Now()
+	c.mutex.RLock()
+	activations := c.activationsLocked(now)
+	var active []*profile
+	changed := len(activations) == 0 && len(c.active) > 0
+	for _, a := range activations {
+		if n := len(active); n > 0 && active[n-1].name == a.Profile {
+			continue
+		}
+		active = append(active, c.profiles[a.Profile])
+		if !c.active[a.Profile] {
+			changed = true
+		}
+	}
+	if len(active) != len(c.active) {
+		changed = true
+	}
+	c.mutex.RUnlock()
+
+	if changed {
+		c.logTransitions(active)
+	}
+	return active
+}
+
+func (c *Calendar) logTransitions(active []*profile) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	now := map[string]bool{}
+	for _, p := range active {
+		now[p.name] = true
+		if !c.active[p.name] {
+			logger.Info("Profile active", "profile", p.name)
+		}
+	}
+	for name := range c.active {
+		if !now[name] {
+			logger.Info("Profile no longer active", "profile", name)
+		}
+	}
+	c.active = now
+}
+
+// Watch logs the profiles turning on and off, checking every second.
+// Without it, transitions are logged when requests arrive. The returned
+// function stops watching.
+func (c *Calendar) Watch() func() {
+	ticker := time.NewTicker(time.Second)
+	stop := make(chan struct{})
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				c.current()
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return func() { close(stop) }
+}
+
+// isAdminPath reports whether a path belongs to the admin API or metrics,
+// which faults never affect.
+func isAdminPath(path string) bool {
+	return strings.HasPrefix(path, "/__admin") || path == "/metrics"
+}
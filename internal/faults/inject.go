@@ -0,0 +1,116 @@
+package faults
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// Header names the profile that failed an HTTP request.
+const Header = "X-Mock-Fault"
+
+// Middleware applies the first active profile with HTTP faults matching the
+// request path: it waits for the delay, then fails the request at the
+// error rate.
+func (c *Calendar) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			path := ctx.Request().URL.Path
+			if isAdminPath(path) {
+				return next(ctx)
+			}
+			for _, p := range c.current() {
+				f := p.http
+				if f == nil || !matches(f.Paths, path) {
+					continue
+				}
+				if delay := f.delay.pick(); delay > 0 {
+					select {
+					case <-time.After(delay):
+					case <-ctx.Request().Context().Done():
+						return nil
+					}
+				}
+				if !failing(f.ErrorRate) {
+					break
+				}
+				logger.DebugContext(ctx.Request().Context(), "Failing request", "status", f.Status, "profile", p.name)
+				ctx.Response().Header().Set(Header, p.name)
+				if f.Body != "" {
+					return ctx.Blob(f.Status, echo.MIMEApplicationJSON, []byte(f.Body))
+				}
+				return ctx.JSON(f.Status, map[string]interface{}{
+					"error":     "Injected fault",
+					"details":   http.StatusText(f.Status),
+					"profile":   p.name,
+					"timestamp": time.Now().Unix(),
+				})
+			}
+			return next(ctx)
+		}
+	}
+}
+
+// grpcFault returns the first active profile with gRPC faults matching a
+// method.
+func (c *Calendar) grpcFault(method string) (*profile, bool) {
+	for _, p := range c.current() {
+		if p.grpc != nil && matches(p.grpc.Methods, method) {
+			return p, true
+		}
+	}
+	return nil, false
+}
+
+// apply waits for the delay of a profile's gRPC faults and returns its error
+// at the error rate.
+func (p *profile) apply(ctx context.Context) error {
+	f := p.grpc
+	if delay := f.delay.pick(); delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		}
+	}
+	if !failing(f.ErrorRate) {
+		return nil
+	}
+	message := f.Message
+	if message == "" {
+		message = "injected " + f.code.String() + " error (profile " + p.name + ")"
+	}
+	logger.DebugContext(ctx, "Failing call", "code", f.code.String(), "profile", p.name)
+	return status.Error(f.code, message)
+}
+
+// UnaryInterceptor applies the active profiles to unary calls.
+func (c *Calendar) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if p, ok := c.grpcFault(info.FullMethod); ok {
+			if err := p.apply(ctx); err != nil {
+				return nil, err
+			}
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamInterceptor applies the active profiles to streaming calls, before
+// the handler sees the stream.
+func (c *Calendar) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if p, ok := c.grpcFault(info.FullMethod); ok {
+			if err := p.apply(ss.Context()); err != nil {
+				return err
+			}
+		}
+		return handler(srv, ss)
+	}
+}
@@ -0,0 +1,193 @@
+// Package faults injects named fault profiles (HTTP errors and delays, gRPC
+// errors and delays) while they are active: during the wall-clock windows
+// of a calendar, such as a nightly chaos window, or when switched on through
+// the admin API. Resilience drills against a shared mock environment can
+// then run unattended.
+package faults
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+)
+
+// Profile is a named set of faults, applied to the requests it matches
+// while it is active.
+type Profile struct {
+	// Description says what the profile simulates.
+	Description string     `json:"description,omitempty"`
+	HTTP        *HTTPFault `json:"http,omitempty"`
+	GRPC        *GRPCFault `json:"grpc,omitempty"`
+}
+
+// HTTPFault delays HTTP requests and fails a share of them. The admin API
+// and /metrics are never affected.
+type HTTPFault struct {
+	// Paths are the path prefixes affected; every path when empty.
+	Paths []string `json:"paths,omitempty"`
+	// ErrorRate is the probability, from 0 to 1, that a request fails.
+	ErrorRate float64 `json:"error_rate,omitempty"`
+	// Status is the status of failed requests (default 503).
+	Status int `json:"status,omitempty"`
+	// Body replaces the JSON error body of failed requests.
+	Body string `json:"body,omitempty"`
+	// Delay holds every request back, fixed ("200ms") or random within a
+	// range ("100ms-2s").
+	Delay string `json:"delay,omitempty"`
+}
+
+// GRPCFault delays gRPC calls and fails a share of them.
+type GRPCFault struct {
+	// Methods are the full method name prefixes affected
+	// ("/mock.MockService/"); every method when empty.
+	Methods []string `json:"methods,omitempty"`
+	// ErrorRate is the probability, from 0 to 1, that a call fails.
+	ErrorRate float64 `json:"error_rate,omitempty"`
+	// Code is the status code of failed calls, by name or number (default
+	// UNAVAILABLE).
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+	// Delay holds every call back, fixed or random within a range.
+	Delay string `json:"delay,omitempty"`
+}
+
+// profile is a compiled Profile.
+type profile struct {
+	name string
+	http *httpFault
+	grpc *grpcFault
+}
+
+type httpFault struct {
+	HTTPFault
+	delay durationRange
+}
+
+type grpcFault struct {
+	GRPCFault
+	code  codes.Code
+	delay durationRange
+}
+
+func (p Profile) compile(name string) (*profile, error) {
+	if name == "" {
+		return nil, errors.New("a profile needs a name")
+	}
+	if p.HTTP == nil && p.GRPC == nil {
+		return nil, fmt.Errorf("profile %q: needs http or grpc faults", name)
+	}
+	compiled := &profile{name: name}
+	if p.HTTP != nil {
+		f := *p.HTTP
+		if err := checkRate(f.ErrorRate); err != nil {
+			return nil, fmt.Errorf("profile %q: http: %w", name, err)
+		}
+		if f.Status == 0 {
+			f.Status = http.StatusServiceUnavailable
+		}
+		if f.Status < 100 || f.Status > 599 {
+			return nil, fmt.Errorf("profile %q: http: invalid status %d", name, f.Status)
+		}
+		delay, err := parseDurationRange(f.Delay)
+		if err != nil {
+			return nil, fmt.Errorf("profile %q: http: delay: %w", name, err)
+		}
+		compiled.http = &httpFault{HTTPFault: f, delay: delay}
+	}
+	if f := p.GRPC; f != nil {
+		if err := checkRate(f.ErrorRate); err != nil {
+			return nil, fmt.Errorf("profile %q: grpc: %w", name, err)
+		}
+		code := codes.Unavailable
+		if f.Code != "" {
+			var err error
+			if code, err = parseCode(f.Code); err != nil || code == codes.OK {
+				return nil, fmt.Errorf("profile %q: grpc: invalid code %q", name, f.Code)
+			}
+		}
+		delay, err := parseDurationRange(f.Delay)
+		if err != nil {
+			return nil, fmt.Errorf("profile %q: grpc: delay: %w", name, err)
+		}
+		compiled.grpc = &grpcFault{GRPCFault: *f, code: code, delay: delay}
+	}
+	return compiled, nil
+}
+
+func checkRate(rate float64) error {
+	if rate < 0 || rate > 1 {
+		return fmt.Errorf("error_rate must be between 0 and 1, got %v", rate)
+	}
+	return nil
+}
+
+// parseCode accepts numeric codes ("14") and names ("UNAVAILABLE").
+func parseCode(value string) (codes.Code, error) {
+	value = strings.TrimSpace(value)
+	if n, err := strconv.ParseUint(value, 10, 32); err == nil {
+		if n > uint64(codes.Unauthenticated) {
+			return codes.Unknown, fmt.Errorf("status code %d out of range", n)
+		}
+		return codes.Code(n), nil
+	}
+	var code codes.Code
+	err := json.Unmarshal([]byte(strconv.Quote(strings.ToUpper(value))), &code)
+	return code, err
+}
+
+// matches reports whether a fault with these prefixes applies to a path or
+// method.
+func matches(prefixes []string, target string) bool {
+	if len(prefixes) == 0 {
+		return true
+	}
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(target, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// failing rolls the error rate.
+func failing(rate float64) bool {
+	return rate > 0 && rand.Float64() < rate
+}
+
+// durationRange is a fixed duration when min equals max.
+type durationRange struct {
+	min, max time.Duration
+}
+
+// parseDurationRange parses "200ms" or "100ms-2s"; "" is no delay.
+func parseDurationRange(value string) (durationRange, error) {
+	if value == "" {
+		return durationRange{}, nil
+	}
+	low, high, isRange := strings.Cut(value, "-")
+	min, err := time.ParseDuration(strings.TrimSpace(low))
+	if err != nil || min < 0 {
+		return durationRange{}, fmt.Errorf("invalid duration %q", value)
+	}
+	max := min
+	if isRange {
+		if max, err = time.ParseDuration(strings.TrimSpace(high)); err != nil || max < min {
+			return durationRange{}, fmt.Errorf("invalid duration range %q", value)
+		}
+	}
+	return durationRange{min: min, max: max}, nil
+}
+
+func (d durationRange) pick() time.Duration {
+	if d.max <= d.min {
+		return d.min
+	}
+	return d.min + time.Duration(rand.Int63n(int64(d.max-d.min)+1))
+}
@@ -0,0 +1,149 @@
+package faults
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"mockserver/internal/timefmt"
+)
+
+// Schedule activates a profile during a wall-clock window: every day (or
+// on some days) between Start and End, or once between From and Until.
+type Schedule struct {
+	// ID is assigned when the schedule is added.
+	ID      string `json:"id,omitempty"`
+	Profile string `json:"profile"`
+	// Start and End are the daily window ("02:00", "03:00"); an End before
+	// Start ends on the next day.
+	Start string `json:"start,omitempty"`
+	End   string `json:"end,omitempty"`
+	// Days are the days the window starts on ("mon" to "sun"); every day
+	// when empty.
+	Days []string `json:"days,omitempty"`
+	// TimeZone is an IANA name ("Europe/Berlin"), "Local" or an offset
+	// ("+05:30"); UTC by default. It applies to Start and End.
+	TimeZone string `json:"time_zone,omitempty"`
+	// From and Until are a one-off window (RFC 3339).
+	From  string `json:"from,omitempty"`
+	Until string `json:"until,omitempty"`
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// schedule is a compiled Schedule.
+type schedule struct {
+	Schedule
+	loc *time.Location
+	// start and end are minutes past midnight of a daily window.
+	start, end int
+	days       map[time.Weekday]bool
+	from       time.Time
+	until      time.Time
+}
+
+func (s Schedule) compile() (*schedule, error) {
+	if s.Profile == "" {
+		return nil, errors.New("a schedule needs a profile")
+	}
+	compiled := &schedule{Schedule: s, loc: time.UTC}
+	daily := s.Start != "" || s.End != ""
+	oneOff := s.From != "" || s.Until != ""
+	switch {
+	case daily && oneOff:
+		return nil, errors.New("a schedule takes start and end, or from and until, not both")
+	case daily:
+		var err error
+		if compiled.start, err = parseClock(s.Start); err != nil {
+			return nil, fmt.Errorf("start: %w", err)
+		}
+		if compiled.end, err = parseClock(s.End); err != nil {
+			return nil, fmt.Errorf("end: %w", err)
+		}
+		if compiled.start == compiled.end {
+			return nil, errors.New("start and end must differ")
+		}
+		if s.TimeZone != "" {
+			if compiled.loc, err = timefmt.LoadZone(s.TimeZone); err != nil {
+				return nil, err
+			}
+		}
+		for _, day := range s.Days {
+			weekday, ok := weekdays[strings.ToLower(day)]
+			if !ok {
+				return nil, fmt.Errorf("unknown day %q (want mon to sun)", day)
+			}
+			if compiled.days == nil {
+				compiled.days = map[time.Weekday]bool{}
+			}
+			compiled.days[weekday] = true
+		}
+	case oneOff:
+		var err error
+		if compiled.from, err = time.Parse(time.RFC3339, s.From); err != nil {
+			return nil, fmt.Errorf("invalid from %q", s.From)
+		}
+		if compiled.until, err = time.Parse(time.RFC3339, s.Until); err != nil {
+			return nil, fmt.Errorf("invalid until %q", s.Until)
+		}
+		if !compiled.until.After(compiled.from) {
+			return nil, errors.New("until must be after from")
+		}
+		if len(s.Days) > 0 || s.TimeZone != "" {
+			return nil, errors.New("days and time_zone only apply to start and end")
+		}
+	default:
+		return nil, errors.New("a schedule needs start and end, or from and until")
+	}
+	return compiled, nil
+}
+
+// parseClock parses "HH:MM" into minutes past midnight.
+func parseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// window returns the window in progress at now or, when there is none, the
+// next one; ok is false once a one-off window is over.
+func (s *schedule) window(now time.Time) (start, end time.Time, ok bool) {
+	if !s.from.IsZero() {
+		return s.from, s.until, now.Before(s.until)
+	}
+	local := now.In(s.loc)
+	// A window that started yesterday may still run; the next one starts
+	// within a week
+	for day := -1; day <= 7; day++ {
+		date := local.AddDate(0, 0, day)
+		if s.days != nil && !s.days[date.Weekday()] {
+			continue
+		}
+		start = clockTime(date, s.start, s.loc)
+		endDate := date
+		if s.end < s.start {
+			endDate = date.AddDate(0, 0, 1)
+		}
+		end = clockTime(endDate, s.end, s.loc)
+		if now.Before(end) {
+			return start, end, true
+		}
+	}
+	return time.Time{}, time.Time{}, false
+}
+
+func clockTime(date time.Time, minutes int, loc *time.Location) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), minutes/60, minutes%60, 0, 0, loc)
+}
+
+// active reports whether now is within a window, and until when.
+func (s *schedule) active(now time.Time) (time.Time, bool) {
+	start, end, ok := s.window(now)
+	return end, ok && !now.Before(start)
+}
@@ -0,0 +1,115 @@
+package grpc
+
+import (
+	"context"
+	"io"
+	"math"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	pb "mockserver/proto"
+)
+
+// EchoAllTypes returns the request unchanged, so clients can round-trip every
+// field kind through their generated code.
+func (s *MockServer) EchoAllTypes(ctx context.Context, req *pb.AllTypes) (*pb.AllTypes, error) {
+	logger.DebugContext(ctx, "EchoAllTypes received", "bytes", proto.Size(req))
+	return req, nil
+}
+
+// EchoAllTypesStream echoes each message as soon as it arrives.
+func (s *MockServer) EchoAllTypesStream(stream pb.MockService_EchoAllTypesStreamServer) error {
+	ctx := stream.Context()
+	logger.DebugContext(ctx, "EchoAllTypesStream started")
+
+	count := 0
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			logger.DebugContext(ctx, "EchoAllTypesStream completed", "received", count)
+			return nil
+		}
+		if err != nil {
+			logger.DebugContext(ctx, "EchoAllTypesStream receive failed", "error", err)
+			return err
+		}
+
+		count++
+		if err := stream.Send(req); err != nil {
+			logger.DebugContext(ctx, "EchoAllTypesStream send failed", "error", err)
+			return err
+		}
+	}
+}
+
+// SampleAllTypes returns a message full of values that commonly trip up
+// serializers: integer extremes, NaN and infinities, negative enums, non-ASCII
+// strings, empty bytes, deep nesting and every oneof-adjacent field set.
+func (s *MockServer) SampleAllTypes(ctx context.Context, _ *emptypb.Empty) (*pb.AllTypes, error) {
+	logger.DebugContext(ctx, "SampleAllTypes building sample")
+
+	nested := &pb.Nested{Name: "level-0", Depth: 0, Child: &pb.Nested{
+		Name: "level-1", Depth: 1, Child: &pb.Nested{Name: "level-2 ✓", Depth: 2},
+	}}
+
+	packed, err := anypb.New(&pb.SimpleResponse{Message: "packed in Any", Timestamp: 1700000000})
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "pack Any: %v", err)
+	}
+	structValue, err := structpb.NewStruct(map[string]interface{}{
+		"null":   nil,
+		"number": 1.5,
+		"string": "text",
+		"bool":   true,
+		"list":   []interface{}{1, "two", false},
+		"object": map[string]interface{}{"key": "value"},
+	})
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "build Struct: %v", err)
+	}
+
+	return &pb.AllTypes{
+		DoubleValue:    math.MaxFloat64,
+		FloatValue:     -math.SmallestNonzeroFloat32,
+		Int32Value:     math.MinInt32,
+		Int64Value:     math.MinInt64,
+		Uint32Value:    math.MaxUint32,
+		Uint64Value:    math.MaxUint64,
+		Sint32Value:    -1,
+		Sint64Value:    math.MinInt64,
+		Fixed32Value:   math.MaxUint32,
+		Fixed64Value:   math.MaxUint64,
+		Sfixed32Value:  math.MinInt32,
+		Sfixed64Value:  math.MaxInt64,
+		BoolValue:      true,
+		StringValue:    "héllo 世界 🚀 \"quoted\" \\ \n\t\u0000",
+		BytesValue:     []byte{0x00, 0xff, 0x80, 0x7f},
+		OptionalInt32:  proto.Int32(0),
+		OptionalString: proto.String(""),
+		Color:          pb.Color_COLOR_NEGATIVE,
+		Nested:         nested,
+		RepeatedInt32:  []int32{0, -1, math.MaxInt32, math.MinInt32},
+		RepeatedDouble: []float64{math.NaN(), math.Inf(1), math.Inf(-1), math.Copysign(0, -1), 1e-300},
+		RepeatedString: []string{"", "a", "ünïcödé"},
+		RepeatedBytes:  [][]byte{{}, {0x01}},
+		RepeatedColor:  []pb.Color{pb.Color_COLOR_RED, pb.Color_COLOR_UNSPECIFIED, pb.Color(42)},
+		RepeatedNested: []*pb.Nested{{}, {Name: "second"}},
+		StringMap:      map[string]string{"": "empty key", "key": "", "ключ": "значение"},
+		IntMap:         map[int64]*pb.Nested{math.MinInt64: {Name: "min"}, 0: {}, math.MaxInt64: {Name: "max"}},
+		BoolMap:        map[bool][]byte{true: []byte("yes"), false: {}},
+		EnumMap:        map[uint32]pb.Color{0: pb.Color_COLOR_UNSPECIFIED, math.MaxUint32: pb.Color_COLOR_BLUE},
+		Choice:         &pb.AllTypes_ChoiceNested{ChoiceNested: &pb.Nested{Name: "chosen"}},
+		Timestamp:      timestamppb.New(time.Date(9999, 12, 31, 23, 59, 59, 999999999, time.UTC)),
+		Duration:       &durationpb.Duration{Seconds: -315576000000, Nanos: -999999999},
+		Any:            packed,
+		StructValue:    structValue,
+	}, nil
+}
@@ -0,0 +1,72 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"google.golang.org/grpc/status"
+
+	pb "mockserver/proto"
+)
+
+const (
+	defaultDeadlineOverrun  = time.Second
+	defaultDeadlineInterval = 50 * time.Millisecond
+)
+
+// ExceedDeadline works until overrun_ms past the client's deadline, polling
+// ctx.Err() every check_interval_ms. When the context ends first it logs how
+// long after the deadline that happened and whether the client cancelled or
+// the deadline propagated, then returns the matching status.
+func (s *MockServer) ExceedDeadline(ctx context.Context, req *pb.DeadlineRequest) (*pb.DeadlineResponse, error) {
+	overrun := time.Duration(req.OverrunMs) * time.Millisecond
+	if overrun <= 0 {
+		overrun = defaultDeadlineOverrun
+	}
+	interval := time.Duration(req.CheckIntervalMs) * time.Millisecond
+	if interval <= 0 {
+		interval = defaultDeadlineInterval
+	}
+
+	start := time.Now()
+	resp := &pb.DeadlineResponse{}
+	until := start.Add(overrun)
+	deadline, ok := ctx.Deadline()
+	if ok {
+		resp.DeadlineSet = true
+		resp.DeadlineRemainingMs = time.Until(deadline).Milliseconds()
+		until = deadline.Add(overrun)
+		logger.DebugContext(ctx, "ExceedDeadline working past the deadline", "deadline_ms", resp.DeadlineRemainingMs, "overrun_ms", overrun.Milliseconds())
+	} else {
+		logger.DebugContext(ctx, "ExceedDeadline working without a deadline", "overrun_ms", overrun.Milliseconds())
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for time.Now().Before(until) {
+		if err := ctx.Err(); err != nil {
+			elapsed := time.Since(start)
+			switch {
+			case errors.Is(err, context.DeadlineExceeded):
+				logger.InfoContext(ctx, "ExceedDeadline deadline exceeded",
+					"elapsed_ms", elapsed.Milliseconds(), "past_deadline_ms", time.Since(deadline).Milliseconds())
+			case ok && time.Now().After(deadline):
+				logger.InfoContext(ctx, "ExceedDeadline cancelled by the client",
+					"elapsed_ms", elapsed.Milliseconds(), "past_deadline_ms", time.Since(deadline).Milliseconds())
+			default:
+				logger.InfoContext(ctx, "ExceedDeadline cancelled by the client before any deadline", "elapsed_ms", elapsed.Milliseconds())
+			}
+			return nil, status.FromContextError(err).Err()
+		}
+		<-ticker.C
+	}
+
+	resp.WorkedMs = time.Since(start).Milliseconds()
+	if ok {
+		logger.WarnContext(ctx, "ExceedDeadline context still alive past the deadline, deadline was not enforced", "overrun_ms", overrun.Milliseconds())
+	} else {
+		logger.InfoContext(ctx, "ExceedDeadline completed without a deadline", "worked_ms", resp.WorkedMs)
+	}
+	return resp, nil
+}
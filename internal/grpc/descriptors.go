@@ -0,0 +1,266 @@
+package grpc
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// DescriptorRegistry resolves service and message descriptors for stubbed
+// methods. It knows every file compiled into the binary (MockService, health,
+// well-known types) plus any FileDescriptorSet loaded at startup, so stubs can
+// target services that have no generated Go code. Files can be added while
+// the server runs.
+type DescriptorRegistry struct {
+	files *protoregistry.Files
+	mutex sync.RWMutex
+}
+
+func NewDescriptorRegistry() *DescriptorRegistry {
+	return &DescriptorRegistry{files: new(protoregistry.Files)}
+}
+
+// LoadFile reads a binary FileDescriptorSet, as produced by
+// `protoc --include_imports -o services.protoset` or `buf build -o`.
+func (r *DescriptorRegistry) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read descriptor set %s: %w", path, err)
+	}
+
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(data, &set); err != nil {
+		return fmt.Errorf("parse descriptor set %s: %w", path, err)
+	}
+
+	return r.Register(&set)
+}
+
+// Register adds every file of the set that is not already known. Imports are
+// resolved against the set itself and the files linked into the binary. The
+// files are built apart and only added once all of them are valid, so a set
+// that fails leaves the registry as it was.
+func (r *DescriptorRegistry) Register(set *descriptorpb.FileDescriptorSet) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	staged := stagedResolver{files: new(protoregistry.Files), r: r}
+	var built []protoreflect.FileDescriptor
+	for _, fdp := range set.GetFile() {
+		if _, err := staged.FindFileByPath(fdp.GetName()); err == nil {
+			continue
+		}
+
+		fd, err := protodesc.NewFile(fdp, staged)
+		if err != nil {
+			return fmt.Errorf("build descriptor for %s: %w", fdp.GetName(), err)
+		}
+		if err := staged.files.RegisterFile(fd); err != nil {
+			return fmt.Errorf("register %s: %w", fdp.GetName(), err)
+		}
+		if name, ok := r.conflict(fd); ok {
+			return fmt.Errorf("register %s: name %s is already registered", fdp.GetName(), name)
+		}
+		built = append(built, fd)
+	}
+
+	for _, fd := range built {
+		if err := r.files.RegisterFile(fd); err != nil {
+			return fmt.Errorf("register %s: %w", fd.Path(), err)
+		}
+	}
+	return nil
+}
+
+// conflict returns a top-level name of fd that a registered file already
+// declares, which would make registering fd fail.
+func (r *DescriptorRegistry) conflict(fd protoreflect.FileDescriptor) (protoreflect.FullName, bool) {
+	var names []protoreflect.FullName
+	for i := 0; i < fd.Messages().Len(); i++ {
+		names = append(names, fd.Messages().Get(i).FullName())
+	}
+	for i := 0; i < fd.Enums().Len(); i++ {
+		enum := fd.Enums().Get(i)
+		names = append(names, enum.FullName())
+		for j := 0; j < enum.Values().Len(); j++ {
+			names = append(names, enum.Values().Get(j).FullName())
+		}
+	}
+	for i := 0; i < fd.Extensions().Len(); i++ {
+		names = append(names, fd.Extensions().Get(i).FullName())
+	}
+	for i := 0; i < fd.Services().Len(); i++ {
+		names = append(names, fd.Services().Get(i).FullName())
+	}
+	for _, name := range names {
+		if _, err := r.files.FindDescriptorByName(name); err == nil {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// FindFileByPath implements protodesc.Resolver.
+func (r *DescriptorRegistry) FindFileByPath(path string) (protoreflect.FileDescriptor, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return unlockedResolver{r}.FindFileByPath(path)
+}
+
+// FindDescriptorByName implements protodesc.Resolver.
+func (r *DescriptorRegistry) FindDescriptorByName(name protoreflect.FullName) (protoreflect.Descriptor, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return unlockedResolver{r}.FindDescriptorByName(name)
+}
+
+// unlockedResolver resolves against the registry while Register holds the
+// lock.
+type unlockedResolver struct {
+	r *DescriptorRegistry
+}
+
+func (u unlockedResolver) FindFileByPath(path string) (protoreflect.FileDescriptor, error) {
+	if fd, err := u.r.files.FindFileByPath(path); err == nil {
+		return fd, nil
+	}
+	return protoregistry.GlobalFiles.FindFileByPath(path)
+}
+
+func (u unlockedResolver) FindDescriptorByName(name protoreflect.FullName) (protoreflect.Descriptor, error) {
+	if d, err := u.r.files.FindDescriptorByName(name); err == nil {
+		return d, nil
+	}
+	return protoregistry.GlobalFiles.FindDescriptorByName(name)
+}
+
+// stagedResolver resolves against the files of a set being registered, then
+// the registry.
+type stagedResolver struct {
+	files *protoregistry.Files
+	r     *DescriptorRegistry
+}
+
+func (s stagedResolver) FindFileByPath(path string) (protoreflect.FileDescriptor, error) {
+	if fd, err := s.files.FindFileByPath(path); err == nil {
+		return fd, nil
+	}
+	return unlockedResolver{s.r}.FindFileByPath(path)
+}
+
+func (s stagedResolver) FindDescriptorByName(name protoreflect.FullName) (protoreflect.Descriptor, error) {
+	if d, err := s.files.FindDescriptorByName(name); err == nil {
+		return d, nil
+	}
+	return unlockedResolver{s.r}.FindDescriptorByName(name)
+}
+
+// FindMethod resolves a full gRPC method name ("/pkg.Service/Method" or
+// "pkg.Service/Method") to its descriptor.
+func (r *DescriptorRegistry) FindMethod(fullMethod string) (protoreflect.MethodDescriptor, error) {
+	service, method, ok := splitMethod(fullMethod)
+	if !ok {
+		return nil, fmt.Errorf("invalid method name %q", fullMethod)
+	}
+
+	d, err := r.FindDescriptorByName(protoreflect.FullName(service))
+	if err != nil {
+		return nil, fmt.Errorf("service %s not found: %w", service, err)
+	}
+	sd, ok := d.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a service", service)
+	}
+
+	md := sd.Methods().ByName(protoreflect.Name(method))
+	if md == nil {
+		return nil, fmt.Errorf("method %s not found in service %s", method, service)
+	}
+	return md, nil
+}
+
+// FindService returns a service defined by a dynamically loaded file.
+func (r *DescriptorRegistry) FindService(name protoreflect.FullName) (protoreflect.ServiceDescriptor, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	d, err := r.files.FindDescriptorByName(name)
+	if err != nil {
+		return nil, false
+	}
+	sd, ok := d.(protoreflect.ServiceDescriptor)
+	return sd, ok
+}
+
+// Services lists the services defined by dynamically loaded files.
+func (r *DescriptorRegistry) Services() []protoreflect.ServiceDescriptor {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	var services []protoreflect.ServiceDescriptor
+	r.files.RangeFiles(func(fd protoreflect.FileDescriptor) bool {
+		for i := 0; i < fd.Services().Len(); i++ {
+			services = append(services, fd.Services().Get(i))
+		}
+		return true
+	})
+	return services
+}
+
+// normalizeMethod returns the canonical "/pkg.Service/Method" form.
+func normalizeMethod(fullMethod string) string {
+	return "/" + strings.TrimPrefix(fullMethod, "/")
+}
+
+func splitMethod(fullMethod string) (string, string, bool) {
+	name := strings.TrimPrefix(fullMethod, "/")
+	idx := strings.LastIndex(name, "/")
+	if idx <= 0 || idx == len(name)-1 {
+		return "", "", false
+	}
+	return name[:idx], name[idx+1:], true
+}
+
+// Files lists the dynamically loaded files, ordered by path.
+func (r *DescriptorRegistry) Files() []protoreflect.FileDescriptor {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	var files []protoreflect.FileDescriptor
+	r.files.RangeFiles(func(fd protoreflect.FileDescriptor) bool {
+		files = append(files, fd)
+		return true
+	})
+	sort.Slice(files, func(i, j int) bool { return files[i].Path() < files[j].Path() })
+	return files
+}
+
+// FileDescriptorSet returns the dynamically loaded files with everything they
+// import, dependencies first, so the set can be loaded on its own (it is what
+// `protoc --include_imports` would have produced).
+func (r *DescriptorRegistry) FileDescriptorSet() *descriptorpb.FileDescriptorSet {
+	set := &descriptorpb.FileDescriptorSet{}
+	seen := make(map[string]bool)
+	var add func(fd protoreflect.FileDescriptor)
+	add = func(fd protoreflect.FileDescriptor) {
+		if seen[fd.Path()] || fd.IsPlaceholder() {
+			return
+		}
+		seen[fd.Path()] = true
+		for i := 0; i < fd.Imports().Len(); i++ {
+			add(fd.Imports().Get(i).FileDescriptor)
+		}
+		set.File = append(set.File, protodesc.ToFileDescriptorProto(fd))
+	}
+	for _, fd := range r.Files() {
+		add(fd)
+	}
+	return set
+}
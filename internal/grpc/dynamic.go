@@ -0,0 +1,156 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	pb "mockserver/proto"
+)
+
+// Message types that exist only inside the server. Clients have no descriptor
+// for them, which is exactly what the Any and unknown field RPCs need.
+var (
+	unregisteredMessage    protoreflect.MessageDescriptor
+	extendedSimpleResponse protoreflect.MessageDescriptor
+)
+
+func init() {
+	field := func(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type, label descriptorpb.FieldDescriptorProto_Label) *descriptorpb.FieldDescriptorProto {
+		return &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(name),
+			JsonName: proto.String(name),
+			Number:   proto.Int32(number),
+			Type:     typ.Enum(),
+			Label:    label.Enum(),
+		}
+	}
+	optional := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+	repeated := descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+
+	nested := field("extra_nested", 103, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, optional)
+	nested.TypeName = proto.String(".mock.internal.Unregistered")
+
+	fdp := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("mock_internal.proto"),
+		Package: proto.String("mock.internal"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{
+				Name: proto.String("Unregistered"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("note", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, optional),
+					field("code", 2, descriptorpb.FieldDescriptorProto_TYPE_INT32, optional),
+				},
+			},
+			{
+				// Wire compatible with SimpleResponse, plus fields 100+.
+				Name: proto.String("SimpleResponseV2"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("message", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, optional),
+					field("timestamp", 2, descriptorpb.FieldDescriptorProto_TYPE_INT64, optional),
+					field("extra_string", 100, descriptorpb.FieldDescriptorProto_TYPE_STRING, optional),
+					field("extra_number", 101, descriptorpb.FieldDescriptorProto_TYPE_SINT64, optional),
+					field("extra_double", 102, descriptorpb.FieldDescriptorProto_TYPE_DOUBLE, optional),
+					nested,
+					field("extra_tags", 104, descriptorpb.FieldDescriptorProto_TYPE_STRING, repeated),
+				},
+			},
+		},
+	}
+
+	fd, err := protodesc.NewFile(fdp, new(protoregistry.Files))
+	if err != nil {
+		panic(fmt.Sprintf("build internal descriptors: %v", err))
+	}
+	unregisteredMessage = fd.Messages().ByName("Unregistered")
+	extendedSimpleResponse = fd.Messages().ByName("SimpleResponseV2")
+}
+
+func newUnregistered(note string, code int32) *dynamicpb.Message {
+	msg := dynamicpb.NewMessage(unregisteredMessage)
+	msg.Set(unregisteredMessage.Fields().ByName("note"), protoreflect.ValueOfString(note))
+	msg.Set(unregisteredMessage.Fields().ByName("code"), protoreflect.ValueOfInt32(code))
+	return msg
+}
+
+// AnyPayloads returns Any values packing a MockService message, well-known
+// types and a message type the client cannot resolve.
+func (s *MockServer) AnyPayloads(ctx context.Context, req *pb.AnyRequest) (*pb.AnyResponse, error) {
+	logger.DebugContext(ctx, "AnyPayloads received", "echo_type", req.GetEcho().GetTypeUrl())
+
+	messages := []proto.Message{
+		&pb.SimpleResponse{Message: "packed SimpleResponse", Timestamp: time.Now().Unix()},
+		timestamppb.Now(),
+		durationpb.New(1500 * time.Millisecond),
+		wrapperspb.String("packed StringValue"),
+		newUnregistered("this type is not known to clients", 42),
+	}
+
+	response := &pb.AnyResponse{}
+	if req.Echo != nil {
+		response.Payloads = append(response.Payloads, req.Echo)
+	}
+	for _, msg := range messages {
+		packed, err := anypb.New(msg)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "pack %s: %v", msg.ProtoReflect().Descriptor().FullName(), err)
+		}
+		response.Payloads = append(response.Payloads, packed)
+	}
+
+	logger.DebugContext(ctx, "AnyPayloads sending", "payloads", len(response.Payloads))
+	return response, nil
+}
+
+// UnknownFields returns a SimpleResponse that carries fields 100-104 from a
+// newer, server-only schema, followed by the unknown fields of the request so
+// clients can check that they preserve them on re-serialization.
+func (s *MockServer) UnknownFields(ctx context.Context, req *pb.UnknownFieldsRequest) (*pb.SimpleResponse, error) {
+	requestUnknown := req.ProtoReflect().GetUnknown()
+	logger.DebugContext(ctx, "UnknownFields received", "message", req.Message, "unknown_bytes", len(requestUnknown))
+
+	fields := extendedSimpleResponse.Fields()
+	extended := dynamicpb.NewMessage(extendedSimpleResponse)
+	extended.Set(fields.ByName("message"), protoreflect.ValueOfString("UnknownFields: "+req.Message))
+	extended.Set(fields.ByName("timestamp"), protoreflect.ValueOfInt64(time.Now().Unix()))
+	extended.Set(fields.ByName("extra_string"), protoreflect.ValueOfString("from a newer schema"))
+	extended.Set(fields.ByName("extra_number"), protoreflect.ValueOfInt64(-12345))
+	extended.Set(fields.ByName("extra_double"), protoreflect.ValueOfFloat64(3.14159))
+	extended.Set(fields.ByName("extra_nested"), protoreflect.ValueOfMessage(newUnregistered("nested unknown", 7)))
+	tags := extended.Mutable(fields.ByName("extra_tags")).List()
+	tags.Append(protoreflect.ValueOfString("alpha"))
+	tags.Append(protoreflect.ValueOfString("beta"))
+
+	data, err := proto.Marshal(extended)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "marshal extended response: %v", err)
+	}
+
+	// Fields 100+ land in the unknown field set of the generated type and are
+	// written back out verbatim when the response is serialized.
+	response := &pb.SimpleResponse{}
+	if err := proto.Unmarshal(data, response); err != nil {
+		return nil, status.Errorf(codes.Internal, "unmarshal extended response: %v", err)
+	}
+	if len(requestUnknown) > 0 {
+		unknown := append(response.ProtoReflect().GetUnknown(), requestUnknown...)
+		response.ProtoReflect().SetUnknown(unknown)
+	}
+
+	logger.DebugContext(ctx, "UnknownFields sending", "unknown_bytes", len(response.ProtoReflect().GetUnknown()))
+	return response, nil
+}
@@ -0,0 +1,136 @@
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Request metadata keys understood by the fault injection interceptors.
+const (
+	StatusCodeKey    = "mock-status-code"
+	StatusMessageKey = "mock-status-message"
+	DelayKey         = "mock-delay"
+)
+
+// maxInjectedDelay caps mock-delay so a typo cannot park a call forever.
+const maxInjectedDelay = 5 * time.Minute
+
+type fault struct {
+	delay   time.Duration
+	code    codes.Code
+	message string
+}
+
+// faultFromContext reads the injection metadata of an incoming call. Invalid
+// values are logged and ignored.
+func faultFromContext(ctx context.Context) (fault, bool) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return fault{}, false
+	}
+
+	f := fault{code: codes.OK}
+	found := false
+
+	if values := md.Get(DelayKey); len(values) > 0 {
+		delay, err := time.ParseDuration(values[0])
+		if err != nil || delay < 0 {
+			logger.WarnContext(ctx, "Ignoring invalid fault metadata", "key", DelayKey, "value", values[0])
+		} else {
+			if delay > maxInjectedDelay {
+				delay = maxInjectedDelay
+			}
+			f.delay = delay
+			found = true
+		}
+	}
+
+	if values := md.Get(StatusCodeKey); len(values) > 0 {
+		code, err := parseCode(values[0])
+		if err != nil {
+			logger.WarnContext(ctx, "Ignoring invalid fault metadata", "key", StatusCodeKey, "value", values[0])
+		} else {
+			f.code = code
+			found = true
+		}
+	}
+
+	if values := md.Get(StatusMessageKey); len(values) > 0 {
+		f.message = values[0]
+	}
+	if f.message == "" && f.code != codes.OK {
+		f.message = "injected " + f.code.String() + " error"
+	}
+
+	return f, found
+}
+
+// apply waits for the requested delay and returns the requested status, if any.
+func (f fault) apply(ctx context.Context) error {
+	if f.delay > 0 {
+		logger.DebugContext(ctx, "Fault delaying call", "delay", f.delay)
+		timer := time.NewTimer(f.delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			logger.DebugContext(ctx, "Fault delay cancelled", "error", ctx.Err())
+			return status.FromContextError(ctx.Err()).Err()
+		}
+	}
+
+	if f.code != codes.OK {
+		logger.DebugContext(ctx, "Fault failing call", "code", f.code.String())
+		return status.Error(f.code, f.message)
+	}
+	return nil
+}
+
+// FaultUnaryInterceptor honours the mock-* metadata on unary calls.
+func FaultUnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if f, ok := faultFromContext(ctx); ok {
+			if err := f.apply(ctx); err != nil {
+				return nil, err
+			}
+		}
+		return handler(ctx, req)
+	}
+}
+
+// FaultStreamInterceptor honours the mock-* metadata on streaming calls. The
+// delay and error happen before the handler sees the stream.
+func FaultStreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if f, ok := faultFromContext(ss.Context()); ok {
+			if err := f.apply(ss.Context()); err != nil {
+				return err
+			}
+		}
+		return handler(srv, ss)
+	}
+}
+
+// parseCode accepts numeric codes ("14") and names ("UNAVAILABLE").
+func parseCode(value string) (codes.Code, error) {
+	value = strings.TrimSpace(value)
+	if n, err := strconv.ParseUint(value, 10, 32); err == nil {
+		if n > uint64(codes.Unauthenticated) {
+			return codes.Unknown, fmt.Errorf("status code %d out of range", n)
+		}
+		return codes.Code(n), nil
+	}
+
+	var code codes.Code
+	err := json.Unmarshal([]byte(strconv.Quote(strings.ToUpper(value))), &code)
+	return code, err
+}
@@ -0,0 +1,76 @@
+package grpc
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// HealthController serves grpc.health.v1.Health and lets the admin API flip
+// services between SERVING and NOT_SERVING at runtime.
+type HealthController struct {
+	server   *health.Server
+	statuses map[string]healthpb.HealthCheckResponse_ServingStatus
+	mutex    sync.RWMutex
+}
+
+// NewHealthController marks the overall server ("") and the given services as
+// SERVING.
+func NewHealthController(services ...string) *HealthController {
+	h := &HealthController{
+		server:   health.NewServer(),
+		statuses: make(map[string]healthpb.HealthCheckResponse_ServingStatus),
+	}
+	h.Set("", healthpb.HealthCheckResponse_SERVING)
+	for _, service := range services {
+		h.Set(service, healthpb.HealthCheckResponse_SERVING)
+	}
+	return h
+}
+
+func (h *HealthController) Register(srv *grpc.Server) {
+	healthpb.RegisterHealthServer(srv, h.server)
+}
+
+// Set updates the status of a service; "" is the overall server status.
+// Watchers are notified immediately.
+func (h *HealthController) Set(service string, status healthpb.HealthCheckResponse_ServingStatus) {
+	h.mutex.Lock()
+	h.statuses[service] = status
+	h.mutex.Unlock()
+
+	h.server.SetServingStatus(service, status)
+	logger.Info("Health status changed", "service", service, "status", status.String())
+}
+
+// Statuses returns a snapshot of all known service statuses.
+func (h *HealthController) Statuses() map[string]string {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	statuses := make(map[string]string, len(h.statuses))
+	for service, status := range h.statuses {
+		statuses[service] = status.String()
+	}
+	return statuses
+}
+
+// Shutdown sets every service to NOT_SERVING so clients drain before the
+// server stops.
+func (h *HealthController) Shutdown() {
+	h.server.Shutdown()
+}
+
+// ParseServingStatus accepts "SERVING", "NOT_SERVING", "SERVICE_UNKNOWN" and
+// "UNKNOWN" (case-insensitive).
+func ParseServingStatus(value string) (healthpb.HealthCheckResponse_ServingStatus, error) {
+	status, ok := healthpb.HealthCheckResponse_ServingStatus_value[strings.ToUpper(value)]
+	if !ok {
+		return healthpb.HealthCheckResponse_UNKNOWN, fmt.Errorf("unknown serving status %q", value)
+	}
+	return healthpb.HealthCheckResponse_ServingStatus(status), nil
+}
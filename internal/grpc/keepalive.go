@@ -0,0 +1,95 @@
+package grpc
+
+import (
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
+)
+
+// KeepaliveConfig sets the server keepalive enforcement and connection
+// lifetime policies. Durations use Go syntax ("30s", "5m"); unset values keep
+// the gRPC defaults.
+type KeepaliveConfig struct {
+	// MinTime is the shortest client ping interval tolerated before the server
+	// answers with GOAWAY "too_many_pings" (gRPC default 5m).
+	MinTime string `json:"min_time,omitempty"`
+	// PermitWithoutStream allows client pings on connections without calls.
+	PermitWithoutStream bool `json:"permit_without_stream,omitempty"`
+	// MaxConnectionIdle closes connections idle for this long with GOAWAY.
+	MaxConnectionIdle string `json:"max_connection_idle,omitempty"`
+	// MaxConnectionAge sends GOAWAY to connections older than this (plus a
+	// random 10% jitter), and MaxConnectionAgeGrace then force-closes them.
+	MaxConnectionAge      string `json:"max_connection_age,omitempty"`
+	MaxConnectionAgeGrace string `json:"max_connection_age_grace,omitempty"`
+	// Time and Timeout control the server's own pings to idle clients.
+	Time    string `json:"time,omitempty"`
+	Timeout string `json:"timeout,omitempty"`
+}
+
+// ServerOptions converts the configuration into gRPC server options.
+func (c KeepaliveConfig) ServerOptions() ([]grpc.ServerOption, error) {
+	var params keepalive.ServerParameters
+	var policy keepalive.EnforcementPolicy
+
+	fields := []struct {
+		name  string
+		value string
+		dst   *time.Duration
+	}{
+		{"min_time", c.MinTime, &policy.MinTime},
+		{"max_connection_idle", c.MaxConnectionIdle, &params.MaxConnectionIdle},
+		{"max_connection_age", c.MaxConnectionAge, &params.MaxConnectionAge},
+		{"max_connection_age_grace", c.MaxConnectionAgeGrace, &params.MaxConnectionAgeGrace},
+		{"time", c.Time, &params.Time},
+		{"timeout", c.Timeout, &params.Timeout},
+	}
+	for _, field := range fields {
+		if field.value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(field.value)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid keepalive %s %q", field.name, field.value)
+		}
+		*field.dst = d
+	}
+
+	var options []grpc.ServerOption
+	if policy.MinTime > 0 || c.PermitWithoutStream {
+		if policy.MinTime == 0 {
+			policy.MinTime = 5 * time.Minute
+		}
+		policy.PermitWithoutStream = c.PermitWithoutStream
+		options = append(options, grpc.KeepaliveEnforcementPolicy(policy))
+	}
+	if params != (keepalive.ServerParameters{}) {
+		options = append(options, grpc.KeepaliveParams(params))
+	}
+	return options, nil
+}
+
+// String summarizes the configured policies for the startup banner.
+func (c KeepaliveConfig) String() string {
+	summary := ""
+	add := func(name, value string) {
+		if value == "" {
+			return
+		}
+		if summary != "" {
+			summary += ", "
+		}
+		summary += name + "=" + value
+	}
+	add("min_time", c.MinTime)
+	if c.PermitWithoutStream {
+		add("permit_without_stream", "true")
+	}
+	add("max_connection_idle", c.MaxConnectionIdle)
+	add("max_connection_age", c.MaxConnectionAge)
+	add("max_connection_age_grace", c.MaxConnectionAgeGrace)
+	add("time", c.Time)
+	add("timeout", c.Timeout)
+	return summary
+}
@@ -0,0 +1,139 @@
+package grpc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/encoding"
+	_ "google.golang.org/grpc/encoding/gzip" // accept and send gzip-compressed messages
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+
+	pb "mockserver/proto"
+)
+
+// maxOversizedBytes caps the responses Oversized builds.
+const maxOversizedBytes = 256 * 1024 * 1024
+
+// Oversized returns a response whose serialized size is exactly limit_bytes +
+// overshoot_bytes, so a client configured with that receive limit fails with
+// RESOURCE_EXHAUSTED.
+func (s *MockServer) Oversized(ctx context.Context, req *pb.OversizedRequest) (*pb.OversizedResponse, error) {
+	overshoot := req.OvershootBytes
+	if overshoot <= 0 {
+		overshoot = 1
+	}
+	target := req.LimitBytes + overshoot
+	if req.LimitBytes <= 0 || target > maxOversizedBytes {
+		return nil, status.Errorf(codes.InvalidArgument, "limit_bytes must be between 1 and %d", maxOversizedBytes-overshoot)
+	}
+
+	resp := sizedResponse(target)
+	logger.DebugContext(ctx, "Oversized sending", "bytes", resp.SizeBytes, "limit_bytes", req.LimitBytes)
+	return resp, nil
+}
+
+// sizedResponse builds a response serializing to target bytes, or one byte
+// more where varint length boundaries make target itself unreachable.
+func sizedResponse(target int64) *pb.OversizedResponse {
+	resp := &pb.OversizedResponse{SizeBytes: target}
+	// Tag bytes of both fields plus the size_bytes varint; the payload adds its
+	// length varint and the bytes themselves.
+	fixed := int64(2 + protowire.SizeVarint(uint64(target)))
+	n := target - fixed - 5
+	if n < 1 {
+		n = 1
+	}
+	for fixed+int64(protowire.SizeVarint(uint64(n)))+n < target {
+		n++
+	}
+	resp.Payload = make([]byte, n)
+	// Measured twice: the new value may change the length of its own varint.
+	resp.SizeBytes = int64(proto.Size(resp))
+	resp.SizeBytes = int64(proto.Size(resp))
+	return resp
+}
+
+// Payload returns size_bytes of payload and its SHA-256, and reports the size
+// and SHA-256 of the uploaded payload, so both directions can be verified when
+// measuring throughput or message size limits.
+func (s *MockServer) Payload(ctx context.Context, req *pb.PayloadRequest) (*pb.PayloadResponse, error) {
+	if req.SizeBytes < 0 || req.SizeBytes > maxOversizedBytes {
+		return nil, status.Errorf(codes.InvalidArgument, "size_bytes must be between 0 and %d", maxOversizedBytes)
+	}
+
+	payload := make([]byte, req.SizeBytes)
+	switch req.Fill {
+	case "", "zeros":
+	case "sequence":
+		for i := range payload {
+			payload[i] = byte(i)
+		}
+	case "random":
+		rand.Read(payload)
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "unknown fill %q (want zeros, sequence or random)", req.Fill)
+	}
+
+	sum := sha256.Sum256(payload)
+	received := sha256.Sum256(req.Payload)
+	logger.DebugContext(ctx, "Payload sending", "bytes", len(payload), "fill", fillName(req.Fill), "received_bytes", len(req.Payload))
+	return &pb.PayloadResponse{
+		Payload:        payload,
+		Sha256:         hex.EncodeToString(sum[:]),
+		SizeBytes:      req.SizeBytes,
+		ReceivedBytes:  int64(len(req.Payload)),
+		ReceivedSha256: hex.EncodeToString(received[:]),
+	}, nil
+}
+
+func fillName(fill string) string {
+	if fill == "" {
+		return "zeros"
+	}
+	return fill
+}
+
+// SendCompressor compresses the responses of MockService calls with a fixed
+// codec, even when requests are not compressed.
+type SendCompressor struct {
+	name string
+}
+
+// NewSendCompressor validates the codec name ("gzip"); an empty name disables
+// forced compression.
+func NewSendCompressor(name string) (*SendCompressor, error) {
+	if name != "" && encoding.GetCompressor(name) == nil {
+		return nil, fmt.Errorf("unknown gRPC compressor %q", name)
+	}
+	return &SendCompressor{name: name}, nil
+}
+
+func (c *SendCompressor) apply(ctx context.Context, method string) {
+	if c.name == "" || !isMockService(method) {
+		return
+	}
+	if err := grpc.SetSendCompressor(ctx, c.name); err != nil {
+		logger.WarnContext(ctx, "Cannot compress response", "compressor", c.name, "error", err)
+	}
+}
+
+func (c *SendCompressor) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		c.apply(ctx, info.FullMethod)
+		return handler(ctx, req)
+	}
+}
+
+func (c *SendCompressor) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		c.apply(ss.Context(), info.FullMethod)
+		return handler(srv, ss)
+	}
+}
@@ -0,0 +1,96 @@
+package grpc
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	pb "mockserver/proto"
+)
+
+// TrailerKey adds trailers to a single call, e.g. "mock-trailer: x-cost=3".
+// It may be repeated.
+const TrailerKey = "mock-trailer"
+
+// MetadataEcho reflects the request metadata of MockService calls back as
+// response headers and attaches the configured trailers.
+type MetadataEcho struct {
+	trailers metadata.MD
+}
+
+// NewMetadataEcho creates the interceptors; trailers are sent on every
+// MockService call.
+func NewMetadataEcho(trailers map[string]string) *MetadataEcho {
+	return &MetadataEcho{trailers: metadata.New(trailers)}
+}
+
+// isTransportMetadata reports keys owned by gRPC or HTTP/2 that cannot be
+// sent back as custom metadata.
+func isTransportMetadata(key string) bool {
+	switch key {
+	case "content-type", "user-agent", "te", "authority":
+		return true
+	}
+	return strings.HasPrefix(key, ":") || strings.HasPrefix(key, "grpc-")
+}
+
+func isMockService(method string) bool {
+	return strings.HasPrefix(method, "/"+pb.MockService_ServiceDesc.ServiceName+"/")
+}
+
+// metadataFor computes the echoed headers and the trailers of a call.
+func (m *MetadataEcho) metadataFor(ctx context.Context) (metadata.MD, metadata.MD) {
+	header := metadata.MD{}
+	trailer := m.trailers.Copy()
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	for key, values := range md {
+		if isTransportMetadata(key) {
+			continue
+		}
+		if key == TrailerKey {
+			for _, value := range values {
+				name, val, ok := strings.Cut(value, "=")
+				name = strings.ToLower(strings.TrimSpace(name))
+				if !ok || name == "" || isTransportMetadata(name) {
+					logger.WarnContext(ctx, "Ignoring invalid trailer metadata", "key", TrailerKey, "value", value)
+					continue
+				}
+				trailer.Append(name, strings.TrimSpace(val))
+			}
+			continue
+		}
+		header.Append(key, values...)
+	}
+
+	logger.DebugContext(ctx, "Echoing metadata", "header_keys", len(header), "trailer_keys", len(trailer))
+	return header, trailer
+}
+
+// UnaryInterceptor echoes metadata on unary MockService calls.
+func (m *MetadataEcho) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if !isMockService(info.FullMethod) {
+			return handler(ctx, req)
+		}
+		header, trailer := m.metadataFor(ctx)
+		grpc.SetHeader(ctx, header)
+		grpc.SetTrailer(ctx, trailer)
+		return handler(ctx, req)
+	}
+}
+
+// StreamInterceptor echoes metadata on streaming MockService calls.
+func (m *MetadataEcho) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if !isMockService(info.FullMethod) {
+			return handler(srv, ss)
+		}
+		header, trailer := m.metadataFor(ss.Context())
+		ss.SetHeader(header)
+		ss.SetTrailer(trailer)
+		return handler(srv, ss)
+	}
+}
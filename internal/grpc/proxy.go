@@ -0,0 +1,377 @@
+package grpc
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/mem"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"mockserver/internal/match"
+)
+
+// maxRecordings bounds the proxied calls kept; older ones are dropped.
+const maxRecordings = 1000
+
+// ProxyConfig enables the record-and-proxy mode: calls to methods without a
+// registered implementation or stubs are forwarded to Upstream unchanged.
+type ProxyConfig struct {
+	// Upstream is the target address, e.g. "backend:50051".
+	Upstream string `json:"upstream,omitempty"`
+	// TLS dials the upstream with TLS; InsecureSkipVerify skips certificate
+	// verification.
+	TLS                bool `json:"tls,omitempty"`
+	InsecureSkipVerify bool `json:"insecure_skip_verify,omitempty"`
+}
+
+// Recording is one proxied call. Messages are kept as the raw wire bytes.
+type Recording struct {
+	ID        string            `json:"id"`
+	Method    string            `json:"method"`
+	Timestamp time.Time         `json:"timestamp"`
+	Requests  [][]byte          `json:"requests"`
+	Responses [][]byte          `json:"responses"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	Trailers  map[string]string `json:"trailers,omitempty"`
+	Code      codes.Code        `json:"code"`
+	Message   string            `json:"message,omitempty"`
+	LatencyMs float64           `json:"latency_ms"`
+}
+
+// frame carries a message as raw bytes through the proxy codec.
+type frame struct {
+	data []byte
+}
+
+// proxyCodec passes frames through untouched and everything else to the
+// regular proto codec, so proxied messages are forwarded byte for byte.
+type proxyCodec struct {
+	proto encoding.CodecV2
+}
+
+func newProxyCodec() proxyCodec {
+	return proxyCodec{proto: encoding.GetCodecV2("proto")}
+}
+
+func (c proxyCodec) Marshal(v any) (mem.BufferSlice, error) {
+	if f, ok := v.(*frame); ok {
+		return mem.BufferSlice{mem.SliceBuffer(f.data)}, nil
+	}
+	return c.proto.Marshal(v)
+}
+
+func (c proxyCodec) Unmarshal(data mem.BufferSlice, v any) error {
+	if f, ok := v.(*frame); ok {
+		f.data = data.Materialize()
+		return nil
+	}
+	return c.proto.Unmarshal(data, v)
+}
+
+func (proxyCodec) Name() string {
+	return "proto"
+}
+
+// Proxy forwards unimplemented methods to an upstream server and records the
+// calls so they can be turned into stubs for offline runs.
+type Proxy struct {
+	config     ProxyConfig
+	conn       *grpc.ClientConn
+	registry   *DescriptorRegistry
+	recordings []Recording
+	nextID     int
+	mutex      sync.RWMutex
+}
+
+// NewProxy dials the upstream lazily; descriptors in registry are used to
+// decode recordings into stubs.
+func NewProxy(config ProxyConfig, registry *DescriptorRegistry) (*Proxy, error) {
+	creds := insecure.NewCredentials()
+	if config.TLS {
+		creds = credentials.NewTLS(&tls.Config{InsecureSkipVerify: config.InsecureSkipVerify})
+	}
+	conn, err := grpc.NewClient(config.Upstream,
+		grpc.WithTransportCredentials(creds),
+		grpc.WithDefaultCallOptions(grpc.ForceCodecV2(newProxyCodec())),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("proxy upstream %s: %w", config.Upstream, err)
+	}
+	return &Proxy{config: config, conn: conn, registry: registry}, nil
+}
+
+// ServerOptions returns the options the gRPC server needs to hand raw
+// messages to the proxy.
+func (p *Proxy) ServerOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{grpc.ForceServerCodecV2(newProxyCodec())}
+}
+
+// Close closes the upstream connection.
+func (p *Proxy) Close() error {
+	return p.conn.Close()
+}
+
+// UnknownServiceHandler serves methods with stubs through the stub handler
+// and forwards every other unimplemented method upstream.
+func (p *Proxy) UnknownServiceHandler(stubs *StubHandler) grpc.StreamHandler {
+	return func(srv interface{}, stream grpc.ServerStream) error {
+		fullMethod, ok := grpc.MethodFromServerStream(stream)
+		if !ok {
+			return status.Error(codes.Internal, "unable to determine method")
+		}
+		if stubs.hasStubs(normalizeMethod(fullMethod)) {
+			return stubs.UnknownServiceHandler(srv, stream)
+		}
+		return p.forward(stream, fullMethod)
+	}
+}
+
+func (p *Proxy) forward(stream grpc.ServerStream, fullMethod string) error {
+	start := time.Now()
+	recording := Recording{Method: fullMethod, Timestamp: start}
+
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+	md, _ := metadata.FromIncomingContext(ctx)
+	ctx = metadata.NewOutgoingContext(ctx, forwardedMetadata(md))
+
+	desc := &grpc.StreamDesc{ClientStreams: true, ServerStreams: true}
+	upstream, err := p.conn.NewStream(ctx, desc, fullMethod)
+	if err != nil {
+		return p.finish(recording, start, err)
+	}
+
+	// Client messages flow upstream while responses are relayed below.
+	var requestsMutex sync.Mutex
+	go func() {
+		for {
+			f := &frame{}
+			if err := stream.RecvMsg(f); err != nil {
+				if !errors.Is(err, io.EOF) {
+					cancel()
+				}
+				upstream.CloseSend()
+				return
+			}
+			requestsMutex.Lock()
+			recording.Requests = append(recording.Requests, f.data)
+			requestsMutex.Unlock()
+			if err := upstream.SendMsg(f); err != nil {
+				return
+			}
+		}
+	}()
+
+	headerSent := false
+	for {
+		f := &frame{}
+		err := upstream.RecvMsg(f)
+		if !headerSent {
+			headerSent = true
+			if header, herr := upstream.Header(); herr == nil && len(header) > 0 {
+				header = forwardedMetadata(header)
+				recording.Headers = flatten(header)
+				stream.SendHeader(header)
+			}
+		}
+		if err != nil {
+			trailer := forwardedMetadata(upstream.Trailer())
+			recording.Trailers = flatten(trailer)
+			stream.SetTrailer(trailer)
+			requestsMutex.Lock()
+			defer requestsMutex.Unlock()
+			if errors.Is(err, io.EOF) {
+				err = nil
+			}
+			return p.finish(recording, start, err)
+		}
+		recording.Responses = append(recording.Responses, f.data)
+		if err := stream.SendMsg(f); err != nil {
+			return err
+		}
+	}
+}
+
+// finish stores the recording and returns the call result.
+func (p *Proxy) finish(recording Recording, start time.Time, err error) error {
+	st := status.Convert(err)
+	recording.Code = st.Code()
+	recording.Message = st.Message()
+	recording.LatencyMs = float64(time.Since(start).Microseconds()) / 1000
+
+	p.mutex.Lock()
+	p.nextID++
+	recording.ID = fmt.Sprintf("rec-%d", p.nextID)
+	p.recordings = append(p.recordings, recording)
+	if len(p.recordings) > maxRecordings {
+		p.recordings = p.recordings[len(p.recordings)-maxRecordings:]
+	}
+	p.mutex.Unlock()
+
+	logger.Debug("Proxied call", "rpc", recording.Method, "upstream", p.config.Upstream, "code", recording.Code,
+		"messages_in", len(recording.Requests), "messages_out", len(recording.Responses), "recording_id", recording.ID)
+	return err
+}
+
+// Recordings returns the recorded calls, oldest first.
+func (p *Proxy) Recordings() []Recording {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return append([]Recording{}, p.recordings...)
+}
+
+// ResetRecordings drops every recorded call.
+func (p *Proxy) ResetRecordings() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.recordings = nil
+	logger.Info("Proxy recordings cleared")
+}
+
+// Stubs converts the recordings into stubs. Each stub matches the top-level
+// scalar fields of the first request message and answers with the recorded
+// response, status and metadata. Methods without a descriptor in the
+// registry cannot be decoded and are skipped.
+func (p *Proxy) Stubs() ([]Stub, []string) {
+	var stubs []Stub
+	var skipped []string
+	for _, recording := range p.Recordings() {
+		stub, err := p.stub(recording)
+		if err != nil {
+			skipped = append(skipped, fmt.Sprintf("%s %s: %v", recording.ID, recording.Method, err))
+			continue
+		}
+		stubs = append(stubs, stub)
+	}
+	return stubs, skipped
+}
+
+// Decode renders the recorded messages as JSON using the descriptors in the
+// registry.
+func (p *Proxy) Decode(recording Recording) (requests, responses []json.RawMessage, err error) {
+	md, err := p.registry.FindMethod(recording.Method)
+	if err != nil {
+		return nil, nil, err
+	}
+	if requests, err = decodeFrames(recording.Requests, md.Input()); err != nil {
+		return nil, nil, fmt.Errorf("decode request: %w", err)
+	}
+	if responses, err = decodeFrames(recording.Responses, md.Output()); err != nil {
+		return nil, nil, fmt.Errorf("decode response: %w", err)
+	}
+	return requests, responses, nil
+}
+
+func (p *Proxy) stub(recording Recording) (Stub, error) {
+	md, err := p.registry.FindMethod(recording.Method)
+	if err != nil {
+		return Stub{}, err
+	}
+	requests, responses, err := p.Decode(recording)
+	if err != nil {
+		return Stub{}, err
+	}
+
+	stub := Stub{
+		Method: normalizeMethod(recording.Method),
+		Response: StubResponse{
+			Code:     recording.Code,
+			Message:  recording.Message,
+			Headers:  recording.Headers,
+			Trailers: recording.Trailers,
+		},
+	}
+
+	if len(requests) > 0 {
+		if stub.Match, err = requestMatchers(requests[0]); err != nil {
+			return Stub{}, err
+		}
+	}
+
+	if md.IsStreamingServer() {
+		stub.Response.Stream = responses
+	} else if len(responses) > 0 {
+		stub.Response.Body = responses[0]
+	}
+	return stub, nil
+}
+
+// requestMatchers matches the scalar top-level fields of a request message,
+// in field name order.
+func requestMatchers(request json.RawMessage) ([]match.FieldMatcher, error) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(request, &fields); err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var matchers []match.FieldMatcher
+	for _, name := range names {
+		switch value := fields[name].(type) {
+		case string, float64, bool:
+			matchers = append(matchers, match.FieldMatcher{Field: name, Equals: value})
+		}
+	}
+	return matchers, nil
+}
+
+func decodeFrames(frames [][]byte, desc protoreflect.MessageDescriptor) ([]json.RawMessage, error) {
+	var out []json.RawMessage
+	for _, data := range frames {
+		msg := newMessage(desc)
+		if err := proto.Unmarshal(data, msg); err != nil {
+			return nil, err
+		}
+		doc, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(msg)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, doc)
+	}
+	return out, nil
+}
+
+// forwardedMetadata drops the metadata owned by the transport, which each
+// side sets on its own.
+func forwardedMetadata(md metadata.MD) metadata.MD {
+	out := metadata.MD{}
+	for key, values := range md {
+		if strings.HasPrefix(key, ":") || strings.HasPrefix(key, "grpc-") ||
+			key == "content-type" || key == "user-agent" || key == "te" {
+			continue
+		}
+		out[key] = values
+	}
+	return out
+}
+
+func flatten(md metadata.MD) map[string]string {
+	if len(md) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(md))
+	for key, values := range md {
+		out[key] = strings.Join(values, ",")
+	}
+	return out
+}
@@ -0,0 +1,55 @@
+package grpc
+
+import (
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/reflection"
+	v1reflectiongrpc "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	v1alphareflectiongrpc "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// RegisterReflection registers the v1 and v1alpha reflection services. Next
+// to the services registered on s they advertise every service of the
+// descriptor registry, which are served by the stub handler and unknown to
+// the gRPC server, and resolve descriptors through the registry. Files added
+// to the registry at runtime are picked up on the next reflection request.
+func RegisterReflection(s *grpc.Server, registry *DescriptorRegistry) {
+	opts := reflection.ServerOptions{
+		Services:           reflectedServices{server: s, registry: registry},
+		DescriptorResolver: registry,
+	}
+	v1reflectiongrpc.RegisterServerReflectionServer(s, reflection.NewServerV1(opts))
+	v1alphareflectiongrpc.RegisterServerReflectionServer(s, reflection.NewServer(opts))
+}
+
+// reflectedServices merges the registered services with the dynamic ones.
+type reflectedServices struct {
+	server   *grpc.Server
+	registry *DescriptorRegistry
+}
+
+func (p reflectedServices) GetServiceInfo() map[string]grpc.ServiceInfo {
+	services := p.server.GetServiceInfo()
+	for _, sd := range p.registry.Services() {
+		name := string(sd.FullName())
+		if _, ok := services[name]; ok {
+			continue
+		}
+		services[name] = serviceInfo(sd)
+	}
+	return services
+}
+
+func serviceInfo(sd protoreflect.ServiceDescriptor) grpc.ServiceInfo {
+	info := grpc.ServiceInfo{Metadata: sd.ParentFile().Path()}
+	methods := sd.Methods()
+	for i := 0; i < methods.Len(); i++ {
+		md := methods.Get(i)
+		info.Methods = append(info.Methods, grpc.MethodInfo{
+			Name:           string(md.Name()),
+			IsClientStream: md.IsStreamingClient(),
+			IsServerStream: md.IsStreamingServer(),
+		})
+	}
+	return info
+}
@@ -0,0 +1,276 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Metadata used for attempt-by-attempt retry and hedging tests.
+const (
+	// FailSequenceKey lists the outcome of each attempt, e.g.
+	// "UNAVAILABLE, UNAVAILABLE@200ms, OK". Attempts past the end of the
+	// sequence repeat its last entry.
+	FailSequenceKey = "mock-fail-sequence"
+	// RequestIDKey groups attempts of one logical call. Without it the attempt
+	// number comes from grpc-previous-rpc-attempts, set by gRPC's own retries.
+	RequestIDKey = "mock-request-id"
+	// AttemptKey carries the attempt number in the trailers (and in the
+	// headers of successful attempts).
+	AttemptKey = "mock-attempt"
+
+	previousAttemptsKey = "grpc-previous-rpc-attempts"
+	attemptTTL          = 10 * time.Minute
+)
+
+type attemptRecord struct {
+	count    int
+	lastSeen time.Time
+}
+
+// AttemptTracker counts attempts per request id.
+type AttemptTracker struct {
+	attempts map[string]*attemptRecord
+	mutex    sync.Mutex
+}
+
+func NewAttemptTracker() *AttemptTracker {
+	return &AttemptTracker{attempts: make(map[string]*attemptRecord)}
+}
+
+// Next records an attempt for the key and returns its 1-based number.
+func (t *AttemptTracker) Next(key string) int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	now := time.Now()
+	for k, record := range t.attempts {
+		if now.Sub(record.lastSeen) > attemptTTL {
+			delete(t.attempts, k)
+		}
+	}
+
+	record, ok := t.attempts[key]
+	if !ok {
+		record = &attemptRecord{}
+		t.attempts[key] = record
+	}
+	record.count++
+	record.lastSeen = now
+	return record.count
+}
+
+// Reset clears the counter for one key, or all counters when key is empty.
+func (t *AttemptTracker) Reset(key string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if key == "" {
+		t.attempts = make(map[string]*attemptRecord)
+		return
+	}
+	delete(t.attempts, key)
+}
+
+// resetCounter clears a counter along with its per request id variants,
+// "counter|id".
+func (t *AttemptTracker) resetCounter(counter string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	for key := range t.attempts {
+		if key == counter || strings.HasPrefix(key, counter+"|") {
+			delete(t.attempts, key)
+		}
+	}
+}
+
+// Counts returns a snapshot of the tracked attempt counters.
+func (t *AttemptTracker) Counts() map[string]int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	counts := make(map[string]int, len(t.attempts))
+	for key, record := range t.attempts {
+		counts[key] = record.count
+	}
+	return counts
+}
+
+type attemptOutcome struct {
+	code  codes.Code
+	delay time.Duration
+}
+
+// parseFailSequence parses "CODE[@delay], ..." entries.
+func parseFailSequence(value string) ([]attemptOutcome, error) {
+	var outcomes []attemptOutcome
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		outcome := attemptOutcome{}
+		codeStr, delayStr, hasDelay := strings.Cut(entry, "@")
+		if hasDelay {
+			delay, err := time.ParseDuration(strings.TrimSpace(delayStr))
+			if err != nil || delay < 0 {
+				return nil, fmt.Errorf("invalid delay in %q", entry)
+			}
+			outcome.delay = min(delay, maxInjectedDelay)
+		}
+
+		code, err := parseCode(codeStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid status code in %q", entry)
+		}
+		outcome.code = code
+		outcomes = append(outcomes, outcome)
+	}
+
+	if len(outcomes) == 0 {
+		return nil, fmt.Errorf("empty fail sequence")
+	}
+	return outcomes, nil
+}
+
+// attemptNumber determines which attempt of a logical call this is.
+func (t *AttemptTracker) attemptNumber(md metadata.MD, method string) int {
+	if ids := md.Get(RequestIDKey); len(ids) > 0 && ids[0] != "" {
+		return t.Next(method + "|" + ids[0])
+	}
+	if previous := md.Get(previousAttemptsKey); len(previous) > 0 {
+		if n, err := strconv.Atoi(previous[0]); err == nil && n >= 0 {
+			return n + 1
+		}
+	}
+	return 1
+}
+
+// outcome resolves the fail sequence for the call, if one was requested.
+func (t *AttemptTracker) outcome(ctx context.Context, method string) (attemptOutcome, int, bool) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return attemptOutcome{}, 0, false
+	}
+	values := md.Get(FailSequenceKey)
+	if len(values) == 0 {
+		return attemptOutcome{}, 0, false
+	}
+
+	sequence, err := parseFailSequence(strings.Join(values, ","))
+	if err != nil {
+		logger.WarnContext(ctx, "Ignoring invalid fail sequence", "key", FailSequenceKey, "error", err)
+		return attemptOutcome{}, 0, false
+	}
+
+	attempt := t.attemptNumber(md, method)
+	index := min(attempt, len(sequence)) - 1
+	return sequence[index], attempt, true
+}
+
+func (o attemptOutcome) apply(ctx context.Context, attempt int) error {
+	if o.delay > 0 {
+		timer := time.NewTimer(o.delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			logger.DebugContext(ctx, "Retry attempt cancelled during delay", "attempt", attempt, "error", ctx.Err())
+			return status.FromContextError(ctx.Err()).Err()
+		}
+	}
+
+	if o.code != codes.OK {
+		logger.DebugContext(ctx, "Retry attempt failing", "attempt", attempt, "code", o.code.String())
+		return status.Errorf(o.code, "attempt %d failed with injected %s", attempt, o.code)
+	}
+	logger.DebugContext(ctx, "Retry attempt succeeding", "attempt", attempt)
+	return nil
+}
+
+// UnaryInterceptor applies mock-fail-sequence to unary calls.
+func (t *AttemptTracker) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		outcome, attempt, ok := t.outcome(ctx, info.FullMethod)
+		if !ok {
+			return handler(ctx, req)
+		}
+
+		// Failed attempts only carry trailers: gRPC clients do not retry a
+		// call once response headers have been received.
+		attemptMD := metadata.Pairs(AttemptKey, strconv.Itoa(attempt))
+		grpc.SetTrailer(ctx, attemptMD)
+		if err := outcome.apply(ctx, attempt); err != nil {
+			return nil, err
+		}
+		grpc.SetHeader(ctx, attemptMD)
+		return handler(ctx, req)
+	}
+}
+
+// StreamInterceptor applies mock-fail-sequence to streaming calls.
+func (t *AttemptTracker) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		outcome, attempt, ok := t.outcome(ss.Context(), info.FullMethod)
+		if !ok {
+			return handler(srv, ss)
+		}
+
+		attemptMD := metadata.Pairs(AttemptKey, strconv.Itoa(attempt))
+		ss.SetTrailer(attemptMD)
+		if err := outcome.apply(ss.Context(), attempt); err != nil {
+			return err
+		}
+		ss.SetHeader(attemptMD)
+		return handler(srv, ss)
+	}
+}
+
+// ServiceConfig builds a gRPC service config with a retry or hedging policy
+// for the given service, suitable for grpc.WithDefaultServiceConfig.
+func ServiceConfig(service, policy string, maxAttempts int) (map[string]interface{}, error) {
+	if maxAttempts < 2 || maxAttempts > 5 {
+		return nil, fmt.Errorf("max attempts must be between 2 and 5")
+	}
+
+	methodConfig := map[string]interface{}{
+		"name": []map[string]string{{"service": service}},
+	}
+
+	switch policy {
+	case "", "retry":
+		methodConfig["retryPolicy"] = map[string]interface{}{
+			"maxAttempts":          maxAttempts,
+			"initialBackoff":       "0.1s",
+			"maxBackoff":           "1s",
+			"backoffMultiplier":    2,
+			"retryableStatusCodes": []string{"UNAVAILABLE", "RESOURCE_EXHAUSTED"},
+		}
+	case "hedging":
+		methodConfig["hedgingPolicy"] = map[string]interface{}{
+			"maxAttempts":         maxAttempts,
+			"hedgingDelay":        "0.2s",
+			"nonFatalStatusCodes": []string{"UNAVAILABLE", "INTERNAL", "ABORTED"},
+		}
+	default:
+		return nil, fmt.Errorf("unknown policy %q", policy)
+	}
+
+	return map[string]interface{}{
+		"methodConfig": []interface{}{methodConfig},
+		"retryThrottling": map[string]interface{}{
+			"maxTokens":  10,
+			"tokenRatio": 0.1,
+		},
+	}, nil
+}
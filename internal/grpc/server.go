@@ -1,78 +1,142 @@
 package grpc
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
-	"log"
-	"sync"
 	"time"
 
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"mockserver/internal/events"
+	"mockserver/internal/logging"
 	pb "mockserver/proto"
 )
 
+var logger = logging.For("grpc")
+
 type MockServer struct {
 	pb.UnimplementedMockServiceServer
+	bus *events.Bus
 }
 
-func NewMockServer() *MockServer {
-	return &MockServer{}
+func NewMockServer(bus *events.Bus) *MockServer {
+	return &MockServer{bus: bus}
 }
 
 // Echo implements unary RPC
 func (s *MockServer) Echo(ctx context.Context, req *pb.SimpleRequest) (*pb.SimpleResponse, error) {
-	log.Printf("gRPC Echo: Received message: %s, value: %d", req.Message, req.Value)
-	
+	logger.DebugContext(ctx, "Echo received", "message", req.Message, "value", req.Value)
+
 	response := &pb.SimpleResponse{
 		Message:   fmt.Sprintf("Echo: %s (value: %d)", req.Message, req.Value),
 		Timestamp: time.Now().Unix(),
 	}
-	
-	log.Printf("gRPC Echo: Sending response: %s", response.Message)
+
+	logger.DebugContext(ctx, "Echo sending", "response", response.Message)
 	return response, nil
 }
 
+// ServerStream defaults and limits, overridable per request
+const (
+	defaultStreamCount    = 5
+	defaultStreamInterval = 100 * time.Millisecond
+	maxStreamCount        = 100000
+	maxStreamInterval     = 60 * time.Second
+	maxStreamPayloadSize  = 4 * 1024 * 1024
+)
+
+// streamParams reads the count, interval_ms and payload_size of a stream
+// request, applying the defaults and limits.
+func streamParams(req *pb.StreamRequest, defaultCount int, defaultInterval time.Duration) (int, time.Duration, []byte, error) {
+	count := int(req.Count)
+	if count == 0 {
+		count = defaultCount
+	}
+	interval := defaultInterval
+	if req.IntervalMs != nil {
+		interval = time.Duration(req.GetIntervalMs()) * time.Millisecond
+	}
+	if count < 0 || count > maxStreamCount {
+		return 0, 0, nil, status.Errorf(codes.InvalidArgument, "count must be between 1 and %d", maxStreamCount)
+	}
+	if interval < 0 || interval > maxStreamInterval {
+		return 0, 0, nil, status.Errorf(codes.InvalidArgument, "interval_ms must be between 0 and %d", maxStreamInterval.Milliseconds())
+	}
+	if req.PayloadSize < 0 || req.PayloadSize > maxStreamPayloadSize {
+		return 0, 0, nil, status.Errorf(codes.InvalidArgument, "payload_size must be between 0 and %d", maxStreamPayloadSize)
+	}
+
+	var payload []byte
+	if req.PayloadSize > 0 {
+		payload = bytes.Repeat([]byte("x"), int(req.PayloadSize))
+	}
+	return count, interval, payload, nil
+}
+
 // ServerStream implements server streaming RPC
 func (s *MockServer) ServerStream(req *pb.StreamRequest, stream pb.MockService_ServerStreamServer) error {
-	log.Printf("gRPC ServerStream: Starting stream for ID: %s, data: %s", req.Id, req.Data)
-	
-	// Send 5 responses with incremental sequence numbers
-	for i := 0; i < 5; i++ {
-		if err := stream.Context().Err(); err != nil {
-			log.Printf("gRPC ServerStream: Context error: %v", err)
+	ctx := stream.Context()
+	logger.DebugContext(ctx, "ServerStream started", "id", req.Id, "data", req.Data)
+
+	count, interval, payload, err := streamParams(req, defaultStreamCount, defaultStreamInterval)
+	if err != nil {
+		return err
+	}
+
+	logger.DebugContext(ctx, "ServerStream sending", "count", count, "interval", interval, "payload_size", req.PayloadSize)
+
+	// Send responses with incremental sequence numbers
+	for i := 0; i < count; i++ {
+		if err := ctx.Err(); err != nil {
+			logger.DebugContext(ctx, "ServerStream ended by the client", "error", err)
 			return err
 		}
-		
+
 		response := &pb.StreamResponse{
 			Id:        req.Id,
 			Data:      fmt.Sprintf("%s - response %d", req.Data, i+1),
 			Timestamp: time.Now().Unix(),
 			Sequence:  int32(i + 1),
+			Payload:   payload,
 		}
-		
+
 		if err := stream.Send(response); err != nil {
-			log.Printf("gRPC ServerStream: Send error: %v", err)
+			logger.DebugContext(ctx, "ServerStream send failed", "error", err)
 			return err
 		}
-		
-		log.Printf("gRPC ServerStream: Sent response %d: %s", i+1, response.Data)
-		
-		// Small delay between responses
-		time.Sleep(100 * time.Millisecond)
-	}
-	
-	log.Printf("gRPC ServerStream: Completed stream for ID: %s", req.Id)
+
+		logger.DebugContext(ctx, "ServerStream sent", "sequence", i+1, "data", response.Data)
+
+		// Delay between responses
+		if interval > 0 && i < count-1 {
+			select {
+			case <-time.After(interval):
+			case <-ctx.Done():
+				logger.DebugContext(ctx, "ServerStream ended by the client", "error", ctx.Err())
+				return ctx.Err()
+			}
+		}
+	}
+
+	logger.DebugContext(ctx, "ServerStream completed", "id", req.Id)
 	return nil
 }
 
 // ClientStream implements client streaming RPC
 func (s *MockServer) ClientStream(stream pb.MockService_ClientStreamServer) error {
-	log.Printf("gRPC ClientStream: Starting client stream")
-	
+	ctx := stream.Context()
+	logger.DebugContext(ctx, "ClientStream started")
+
 	var messages []string
 	var totalValue int32
 	count := 0
-	
+
 	for {
 		req, err := stream.Recv()
 		if err == io.EOF {
@@ -81,81 +145,174 @@ func (s *MockServer) ClientStream(stream pb.MockService_ClientStreamServer) erro
 				Message:   fmt.Sprintf("Received %d messages: %v (total value: %d)", count, messages, totalValue),
 				Timestamp: time.Now().Unix(),
 			}
-			
-			log.Printf("gRPC ClientStream: Sending final response: %s", response.Message)
+
+			logger.DebugContext(ctx, "ClientStream sending", "response", response.Message)
 			return stream.SendAndClose(response)
 		}
 		if err != nil {
-			log.Printf("gRPC ClientStream: Receive error: %v", err)
+			logger.DebugContext(ctx, "ClientStream receive failed", "error", err)
 			return err
 		}
-		
+
 		messages = append(messages, req.Data)
 		count++
-		
-		log.Printf("gRPC ClientStream: Received message %d: ID=%s, data=%s", count, req.Id, req.Data)
+
+		logger.DebugContext(ctx, "ClientStream received", "count", count, "id", req.Id, "data", req.Data)
 	}
 }
 
-// BidiStream implements bidirectional streaming RPC
+// BidiStream implements bidirectional streaming RPC. Every message is echoed
+// count times (default 1), each response preceded by an interval_ms delay
+// (default 0) and carrying payload_size bytes. The call completes with OK once
+// the client closes its side and the pending responses are sent, and stops
+// as soon as the stream context is cancelled.
 func (s *MockServer) BidiStream(stream pb.MockService_BidiStreamServer) error {
-	log.Printf("gRPC BidiStream: Starting bidirectional stream")
-	
-	var wg sync.WaitGroup
-	errChan := make(chan error, 2)
-	
-	// Goroutine to receive messages from client
-	wg.Add(1)
+	ctx := stream.Context()
+	logger.DebugContext(ctx, "BidiStream started")
+
+	// Messages are received in the background so that cancellation is
+	// noticed while responses are delayed.
+	requests := make(chan *pb.StreamRequest)
+	recvErr := make(chan error, 1)
 	go func() {
-		defer wg.Done()
-		sequence := int32(0)
-		
+		defer close(requests)
 		for {
 			req, err := stream.Recv()
-			if err == io.EOF {
-				log.Printf("gRPC BidiStream: Client closed stream")
+			if err != nil {
+				if err != io.EOF {
+					recvErr <- err
+				}
 				return
 			}
-			if err != nil {
-				log.Printf("gRPC BidiStream: Receive error: %v", err)
-				errChan <- err
+			select {
+			case requests <- req:
+			case <-ctx.Done():
 				return
 			}
-			
+		}
+	}()
+
+	received := 0
+	sequence := int32(0)
+	for {
+		var req *pb.StreamRequest
+		select {
+		case <-ctx.Done():
+			logger.DebugContext(ctx, "BidiStream ended by the client", "received", received, "error", ctx.Err())
+			return status.FromContextError(ctx.Err()).Err()
+		case r, ok := <-requests:
+			if !ok {
+				select {
+				case err := <-recvErr:
+					logger.DebugContext(ctx, "BidiStream receive failed", "error", err)
+					return err
+				default:
+				}
+				logger.DebugContext(ctx, "BidiStream completed", "received", received, "sent", sequence)
+				return nil
+			}
+			req = r
+		}
+
+		received++
+		logger.DebugContext(ctx, "BidiStream received", "count", received, "id", req.Id, "data", req.Data)
+
+		count, interval, payload, err := streamParams(req, 1, 0)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < count; i++ {
+			if interval > 0 {
+				select {
+				case <-time.After(interval):
+				case <-ctx.Done():
+					logger.DebugContext(ctx, "BidiStream ended by the client", "error", ctx.Err())
+					return status.FromContextError(ctx.Err()).Err()
+				}
+			}
+
 			sequence++
-			log.Printf("gRPC BidiStream: Received message %d: ID=%s, data=%s", sequence, req.Id, req.Data)
-			
-			// Echo back the message with modifications
 			response := &pb.StreamResponse{
 				Id:        req.Id,
 				Data:      fmt.Sprintf("Echo: %s (processed)", req.Data),
 				Timestamp: time.Now().Unix(),
 				Sequence:  sequence,
+				Payload:   payload,
 			}
-			
 			if err := stream.Send(response); err != nil {
-				log.Printf("gRPC BidiStream: Send error: %v", err)
-				errChan <- err
-				return
+				logger.DebugContext(ctx, "BidiStream send failed", "error", err)
+				return err
 			}
-			
-			log.Printf("gRPC BidiStream: Sent response %d: %s", sequence, response.Data)
+			logger.DebugContext(ctx, "BidiStream sent", "sequence", sequence, "data", response.Data)
 		}
-	}()
-	
-	// Wait for receiving goroutine to complete
-	go func() {
-		wg.Wait()
-		close(errChan)
-	}()
-	
-	// Wait for any errors or completion
-	err := <-errChan
-	if err != nil {
-		log.Printf("gRPC BidiStream: Stream error: %v", err)
+	}
+}
+
+// Subscribe streams bus events matching the filter until the client goes away.
+// Response headers are sent as soon as the subscription is registered, so
+// clients can wait on them before triggering events.
+func (s *MockServer) Subscribe(filter *pb.StreamFilter, stream pb.MockService_SubscribeServer) error {
+	if s.bus == nil {
+		return status.Error(codes.Unavailable, "event bus is not configured")
+	}
+
+	ctx := stream.Context()
+	logger.DebugContext(ctx, "Subscription started", "topics", filter.Topics, "types", filter.Types)
+
+	ch, cancel := s.bus.Subscribe(events.Filter{Topics: filter.Topics, Types: filter.Types})
+	defer cancel()
+
+	if err := stream.SendHeader(metadata.Pairs("mock-subscribed", "true")); err != nil {
 		return err
 	}
-	
-	log.Printf("gRPC BidiStream: Stream completed")
-	return nil
-}
\ No newline at end of file
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.DebugContext(ctx, "Subscription closed", "error", ctx.Err())
+			return nil
+		case event, ok := <-ch:
+			if !ok {
+				return nil
+			}
+
+			data, err := structpb.NewValue(toStructValue(event.Data))
+			if err != nil {
+				logger.WarnContext(ctx, "Skipping event with unsupported data", "event_id", event.ID, "error", err)
+				continue
+			}
+
+			if err := stream.Send(&pb.Event{
+				Id:            event.ID,
+				Topic:         event.Topic,
+				Type:          event.Type,
+				Source:        event.Source,
+				Data:          data,
+				Timestamp:     event.Timestamp,
+				CorrelationId: event.CorrelationID,
+			}); err != nil {
+				logger.DebugContext(ctx, "Subscription send failed", "error", err)
+				return err
+			}
+		}
+	}
+}
+
+// toStructValue normalises event data (arbitrary Go values) into the generic
+// JSON shapes accepted by structpb.
+func toStructValue(data interface{}) interface{} {
+	switch data.(type) {
+	case nil, bool, string, float64, map[string]interface{}, []interface{}:
+		return data
+	}
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Sprint(data)
+	}
+	var generic interface{}
+	if err := json.Unmarshal(raw, &generic); err != nil {
+		return string(raw)
+	}
+	return generic
+}
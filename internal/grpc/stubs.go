@@ -0,0 +1,487 @@
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"mockserver/internal/match"
+)
+
+// Stub configures a canned response for a gRPC method. Matchers are evaluated
+// against the request message rendered as JSON with the original proto field
+// names; for streaming calls the first request message is used. ID is
+// assigned when the stub is registered.
+type Stub struct {
+	ID       string               `json:"id,omitempty"`
+	Method   string               `json:"method"`
+	Match    []match.FieldMatcher `json:"match,omitempty"`
+	Response StubResponse         `json:"response"`
+	Fail     *StubFailure         `json:"fail,omitempty"`
+}
+
+// StubFailure makes the first Times calls matching a stub fail before the
+// response is served, counted per request id: the value of the Key metadata
+// (default mock-request-id), or a single counter when it is absent.
+type StubFailure struct {
+	Times   int        `json:"times"`
+	Code    codes.Code `json:"code,omitempty"`
+	Message string     `json:"message,omitempty"`
+	Key     string     `json:"key,omitempty"`
+}
+
+// StubResponse is what a matched stub sends back. Body is used for unary
+// methods; Stream lists the messages of a server-streaming response (Body is
+// sent as a single message when Stream is empty). A non-OK Code turns the
+// response into an error status.
+type StubResponse struct {
+	Body     json.RawMessage   `json:"body,omitempty"`
+	Stream   []json.RawMessage `json:"stream,omitempty"`
+	Code     codes.Code        `json:"code,omitempty"`
+	Message  string            `json:"message,omitempty"`
+	Headers  map[string]string `json:"headers,omitempty"`
+	Trailers map[string]string `json:"trailers,omitempty"`
+}
+
+type compiledStub struct {
+	Stub
+	method   protoreflect.MethodDescriptor
+	messages []proto.Message
+	// counter prefixes the attempt counters of the stub's failures:
+	// "stub:<method>#<id>".
+	counter string
+}
+
+// StubHandler serves configured stubs. Stubs for compiled-in services are
+// applied through interceptors and fall through to the real implementation
+// when nothing matches; stubs for services loaded from descriptor sets are
+// served by the unknown service handler.
+type StubHandler struct {
+	registry *DescriptorRegistry
+	attempts *AttemptTracker
+	stubs    map[string][]*compiledStub
+	nextID   int
+	mutex    sync.RWMutex
+}
+
+// NewStubHandler creates the handler; failure counters of stubs using "fail"
+// are kept in attempts.
+func NewStubHandler(registry *DescriptorRegistry, attempts *AttemptTracker) *StubHandler {
+	return &StubHandler{
+		registry: registry,
+		attempts: attempts,
+		stubs:    make(map[string][]*compiledStub),
+	}
+}
+
+// Add validates a stub and registers it, returning it with its ID. Stubs
+// are evaluated in the order they were added.
+func (h *StubHandler) Add(stub Stub) (Stub, error) {
+	added, err := h.Swap(nil, []Stub{stub})
+	if err != nil {
+		return Stub{}, err
+	}
+	return added[0], nil
+}
+
+// Swap removes the stubs with the given IDs and adds stubs in one step, so
+// calls see either the old or the new set, and returns the added stubs with
+// their IDs. The attempt counters of the removed stubs are reset. Nothing
+// changes when one of the stubs is invalid.
+func (h *StubHandler) Swap(remove []string, add []Stub) ([]Stub, error) {
+	compiled := make([]*compiledStub, len(add))
+	for i, stub := range add {
+		c, err := h.compile(stub)
+		if err != nil {
+			return nil, err
+		}
+		compiled[i] = c
+	}
+	removed := make(map[string]bool, len(remove))
+	for _, id := range remove {
+		removed[id] = true
+	}
+
+	var counters []string
+	added := make([]Stub, len(compiled))
+	h.mutex.Lock()
+	if len(removed) > 0 {
+		// Calls being matched keep the lists they read: build new ones
+		for method, list := range h.stubs {
+			kept := make([]*compiledStub, 0, len(list))
+			for _, stub := range list {
+				if removed[stub.ID] {
+					counters = append(counters, stub.counter)
+				} else {
+					kept = append(kept, stub)
+				}
+			}
+			if len(kept) == 0 {
+				delete(h.stubs, method)
+			} else {
+				h.stubs[method] = kept
+			}
+		}
+	}
+	for i, c := range compiled {
+		h.nextID++
+		c.ID = fmt.Sprintf("grpc-stub-%d", h.nextID)
+		key := normalizeMethod(c.Method)
+		c.counter = fmt.Sprintf("stub:%s#%s", key, c.ID)
+		h.stubs[key] = append(h.stubs[key], c)
+		added[i] = c.Stub
+		logger.Debug("Stub registered", "rpc", key, "id", c.ID, "matchers", len(c.Match))
+	}
+	h.mutex.Unlock()
+
+	for _, counter := range counters {
+		h.attempts.resetCounter(counter)
+	}
+	if len(remove) > 0 {
+		logger.Info("Stubs replaced", "removed", len(counters), "added", len(added))
+	}
+	return added, nil
+}
+
+// Validate checks stubs the way Add would, without registering them.
+func (h *StubHandler) Validate(stubs []Stub) error {
+	for _, stub := range stubs {
+		if _, err := h.compile(stub); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *StubHandler) compile(stub Stub) (*compiledStub, error) {
+	md, err := h.registry.FindMethod(stub.Method)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range stub.Match {
+		if err := stub.Match[i].Compile(); err != nil {
+			return nil, fmt.Errorf("stub for %s: %w", stub.Method, err)
+		}
+	}
+
+	if stub.Fail != nil {
+		if stub.Fail.Times < 0 {
+			return nil, fmt.Errorf("stub for %s: fail.times must not be negative", stub.Method)
+		}
+		if stub.Fail.Code == codes.OK {
+			stub.Fail.Code = codes.Unavailable
+		}
+		if stub.Fail.Key == "" {
+			stub.Fail.Key = RequestIDKey
+		}
+		stub.Fail.Key = strings.ToLower(stub.Fail.Key)
+	}
+
+	bodies := stub.Response.Stream
+	if len(bodies) == 0 && len(stub.Response.Body) > 0 {
+		bodies = []json.RawMessage{stub.Response.Body}
+	}
+
+	compiled := &compiledStub{Stub: stub, method: md}
+	for _, body := range bodies {
+		msg := newMessage(md.Output())
+		if err := protojson.Unmarshal(body, msg); err != nil {
+			return nil, fmt.Errorf("stub for %s: invalid %s body: %w", stub.Method, md.Output().FullName(), err)
+		}
+		compiled.messages = append(compiled.messages, msg)
+	}
+	return compiled, nil
+}
+
+// List returns the registered stubs grouped by method, in evaluation order.
+func (h *StubHandler) List() []Stub {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	methods := make([]string, 0, len(h.stubs))
+	for method := range h.stubs {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+
+	var stubs []Stub
+	for _, method := range methods {
+		for _, stub := range h.stubs[method] {
+			stubs = append(stubs, stub.Stub)
+		}
+	}
+	return stubs
+}
+
+func (h *StubHandler) hasStubs(fullMethod string) bool {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	return len(h.stubs[fullMethod]) > 0
+}
+
+func (h *StubHandler) find(fullMethod string, req proto.Message) *compiledStub {
+	h.mutex.RLock()
+	candidates := h.stubs[fullMethod]
+	h.mutex.RUnlock()
+
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	doc, err := toDocument(req)
+	if err != nil {
+		logger.Warn("Cannot render request for matching", "rpc", fullMethod, "error", err)
+		return nil
+	}
+
+	for _, stub := range candidates {
+		if match.All(stub.Match, doc) {
+			return stub
+		}
+	}
+	return nil
+}
+
+// failure counts a call against the stub's "fail" setting and returns the
+// configured error while the request id has failed fewer than Times calls.
+func (h *StubHandler) failure(ctx context.Context, stub *compiledStub) error {
+	fail := stub.Fail
+	if fail == nil {
+		return nil
+	}
+
+	key := stub.counter
+	md, _ := metadata.FromIncomingContext(ctx)
+	if ids := md.Get(fail.Key); len(ids) > 0 {
+		key += "|" + ids[0]
+	}
+	attempt := h.attempts.Next(key)
+	grpc.SetTrailer(ctx, metadata.Pairs(AttemptKey, strconv.Itoa(attempt)))
+	if attempt > fail.Times {
+		logger.DebugContext(ctx, "Stub call succeeding after failures", "counter", key, "attempt", attempt, "failures", fail.Times)
+		return nil
+	}
+
+	logger.DebugContext(ctx, "Stub call failing", "counter", key, "attempt", attempt, "failures", fail.Times, "code", fail.Code.String())
+	message := fail.Message
+	if message == "" {
+		message = fmt.Sprintf("call %d failed with injected %s", attempt, fail.Code)
+	}
+	return status.Error(fail.Code, message)
+}
+
+// UnaryInterceptor answers unary calls from a matching stub.
+func (h *StubHandler) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		msg, ok := req.(proto.Message)
+		if !ok || !h.hasStubs(info.FullMethod) {
+			return handler(ctx, req)
+		}
+
+		stub := h.find(info.FullMethod, msg)
+		if stub == nil {
+			return handler(ctx, req)
+		}
+
+		logger.DebugContext(ctx, "Stub matched")
+		if err := h.failure(ctx, stub); err != nil {
+			return nil, err
+		}
+		if len(stub.Response.Headers) > 0 {
+			grpc.SetHeader(ctx, metadata.New(stub.Response.Headers))
+		}
+		if len(stub.Response.Trailers) > 0 {
+			grpc.SetTrailer(ctx, metadata.New(stub.Response.Trailers))
+		}
+		if stub.Response.Code != codes.OK {
+			return nil, status.Error(stub.Response.Code, stub.Response.Message)
+		}
+		if len(stub.messages) == 0 {
+			return newMessage(stub.method.Output()), nil
+		}
+		return stub.messages[0], nil
+	}
+}
+
+// StreamInterceptor answers server-streaming calls from a matching stub. The
+// first request message is buffered and replayed to the real handler when no
+// stub matches.
+func (h *StubHandler) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if info.IsClientStream || !info.IsServerStream || !h.hasStubs(info.FullMethod) {
+			return handler(srv, ss)
+		}
+
+		md, err := h.registry.FindMethod(info.FullMethod)
+		if err != nil {
+			return handler(srv, ss)
+		}
+
+		req := newMessage(md.Input())
+		if err := ss.RecvMsg(req); err != nil {
+			return err
+		}
+
+		if stub := h.find(info.FullMethod, req); stub != nil {
+			logger.DebugContext(ss.Context(), "Stub matched")
+			if err := h.failure(ss.Context(), stub); err != nil {
+				return err
+			}
+			return sendStub(ss, stub)
+		}
+
+		return handler(srv, &replayStream{ServerStream: ss, first: req})
+	}
+}
+
+// UnknownServiceHandler serves methods of services that have no registered
+// implementation, using descriptors loaded into the registry.
+func (h *StubHandler) UnknownServiceHandler(srv interface{}, stream grpc.ServerStream) error {
+	fullMethod, ok := grpc.MethodFromServerStream(stream)
+	if !ok {
+		return status.Error(codes.Internal, "unable to determine method")
+	}
+
+	md, err := h.registry.FindMethod(fullMethod)
+	if err != nil {
+		return status.Errorf(codes.Unimplemented, "unknown method %s", fullMethod)
+	}
+
+	req := newMessage(md.Input())
+	if err := stream.RecvMsg(req); err != nil && err != io.EOF {
+		return err
+	}
+
+	// Drain the remaining client messages before answering.
+	if md.IsStreamingClient() && !md.IsStreamingServer() {
+		for {
+			if err := stream.RecvMsg(newMessage(md.Input())); err != nil {
+				if err == io.EOF {
+					break
+				}
+				return err
+			}
+		}
+	}
+
+	stub := h.find(normalizeMethod(fullMethod), req)
+	if stub == nil {
+		logger.DebugContext(stream.Context(), "No stub matched")
+		return status.Errorf(codes.Unimplemented, "no stub matched for %s", fullMethod)
+	}
+
+	logger.DebugContext(stream.Context(), "Stub matched")
+	if err := h.failure(stream.Context(), stub); err != nil {
+		return err
+	}
+	return sendStub(stream, stub)
+}
+
+func sendStub(stream grpc.ServerStream, stub *compiledStub) error {
+	if len(stub.Response.Headers) > 0 {
+		if err := stream.SetHeader(metadata.New(stub.Response.Headers)); err != nil {
+			return err
+		}
+	}
+	if len(stub.Response.Trailers) > 0 {
+		stream.SetTrailer(metadata.New(stub.Response.Trailers))
+	}
+	if stub.Response.Code != codes.OK {
+		return status.Error(stub.Response.Code, stub.Response.Message)
+	}
+
+	messages := stub.messages
+	if len(messages) == 0 && !stub.method.IsStreamingServer() {
+		messages = []proto.Message{newMessage(stub.method.Output())}
+	}
+	for _, msg := range messages {
+		if err := stream.SendMsg(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replayStream hands a message that was already received back to the handler.
+type replayStream struct {
+	grpc.ServerStream
+	first    proto.Message
+	replayed bool
+}
+
+func (s *replayStream) RecvMsg(m interface{}) error {
+	if s.replayed {
+		return s.ServerStream.RecvMsg(m)
+	}
+	s.replayed = true
+
+	dst, ok := m.(proto.Message)
+	if !ok {
+		return status.Error(codes.Internal, "unexpected message type")
+	}
+	data, err := proto.Marshal(s.first)
+	if err != nil {
+		return err
+	}
+	return proto.Unmarshal(data, dst)
+}
+
+// newMessage prefers the generated Go type when one is linked in.
+func newMessage(desc protoreflect.MessageDescriptor) proto.Message {
+	if mt, err := protoregistry.GlobalTypes.FindMessageByName(desc.FullName()); err == nil {
+		return mt.New().Interface()
+	}
+	return dynamicpb.NewMessage(desc)
+}
+
+// toDocument renders a request for the field matchers. Zero values are
+// emitted, so that a matcher on 0, false or "" can match a proto3 field left
+// unset; unset message fields are left out, so "present" still tells them
+// from set ones.
+func toDocument(msg proto.Message) (interface{}, error) {
+	data, err := protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true}.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	dropNulls(doc)
+	return doc, nil
+}
+
+// dropNulls removes the null fields of a document, the unset message fields
+// emitted by EmitUnpopulated.
+func dropNulls(doc interface{}) {
+	switch v := doc.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if value == nil {
+				delete(v, key)
+			} else {
+				dropNulls(value)
+			}
+		}
+	case []interface{}:
+		for _, value := range v {
+			dropNulls(value)
+		}
+	}
+}
@@ -0,0 +1,328 @@
+package grpc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// TranscriptCall is a call captured by a client tool, such as grpcurl or
+// evans: the method ("pkg.Service/Method"), the request and response
+// messages as JSON and how the call ended. Request and Response are
+// shorthands for single messages.
+type TranscriptCall struct {
+	Method    string            `json:"method"`
+	Request   json.RawMessage   `json:"request,omitempty"`
+	Requests  []json.RawMessage `json:"requests,omitempty"`
+	Response  json.RawMessage   `json:"response,omitempty"`
+	Responses []json.RawMessage `json:"responses,omitempty"`
+	Error     *TranscriptError  `json:"error,omitempty"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	Trailers  map[string]string `json:"trailers,omitempty"`
+}
+
+// TranscriptError is the status of a failed call. Code is a number, an
+// upper-case name ("NOT_FOUND") or the name grpcurl prints ("NotFound").
+type TranscriptError struct {
+	Code    json.RawMessage `json:"code"`
+	Message string          `json:"message,omitempty"`
+}
+
+// ParseTranscript reads the calls of a transcript, in one of these forms:
+//
+//   - JSON calls (TranscriptCall), one object, an array or one object per
+//     line;
+//   - the output of grpcurl -format json, with or without -v: the response
+//     messages, their metadata when verbose, and the ERROR block of a failed
+//     call. It is one call, to method, since grpcurl does not print it.
+func ParseTranscript(data []byte, method string) ([]TranscriptCall, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return nil, errors.New("empty transcript")
+	}
+	if trimmed[0] == '[' {
+		var calls []TranscriptCall
+		if err := json.Unmarshal(trimmed, &calls); err != nil {
+			return nil, fmt.Errorf("invalid transcript: %w", err)
+		}
+		return calls, nil
+	}
+	if calls, ok := parseCallObjects(trimmed); ok {
+		return calls, nil
+	}
+
+	if method == "" {
+		return nil, errors.New("the method of a grpcurl transcript must be given")
+	}
+	call, err := parseGrpcurl(string(trimmed))
+	if err != nil {
+		return nil, err
+	}
+	call.Method = method
+	return []TranscriptCall{call}, nil
+}
+
+// parseCallObjects reads a sequence of JSON calls; ok is false when the
+// data is something else, such as response messages.
+func parseCallObjects(data []byte) ([]TranscriptCall, bool) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	var calls []TranscriptCall
+	for {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err == io.EOF {
+			return calls, len(calls) > 0
+		} else if err != nil {
+			return nil, false
+		}
+		var call TranscriptCall
+		if json.Unmarshal(raw, &call) != nil || call.Method == "" {
+			return nil, false
+		}
+		calls = append(calls, call)
+	}
+}
+
+// Section headers of grpcurl -v.
+const (
+	grpcurlContents = "Response contents:"
+	grpcurlHeaders  = "Response headers received:"
+	grpcurlTrailers = "Response trailers received:"
+	grpcurlError    = "ERROR:"
+)
+
+var grpcurlSkipped = []string{
+	"Resolved method descriptor:",
+	"Request metadata to send:",
+}
+
+// parseGrpcurl reads the output of grpcurl -format json.
+func parseGrpcurl(output string) (TranscriptCall, error) {
+	var call TranscriptCall
+	var section string
+	var body strings.Builder
+	flush := func() error {
+		text := strings.TrimSpace(body.String())
+		body.Reset()
+		switch section {
+		case "", grpcurlContents:
+			messages, err := jsonValues(text)
+			if err != nil {
+				return fmt.Errorf("invalid response message: %w", err)
+			}
+			call.Responses = append(call.Responses, messages...)
+		case grpcurlHeaders:
+			call.Headers = grpcurlMetadata(text)
+		case grpcurlTrailers:
+			call.Trailers = grpcurlMetadata(text)
+		case grpcurlError:
+			call.Error = grpcurlStatus(text)
+		}
+		return nil
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	scanner.Buffer(nil, 16<<20)
+	for scanner.Scan() {
+		line := scanner.Text()
+		header := strings.TrimSpace(line)
+		switch {
+		case header == grpcurlContents || header == grpcurlHeaders || header == grpcurlTrailers || header == grpcurlError || isGrpcurlSkipped(header):
+			if err := flush(); err != nil {
+				return call, err
+			}
+			section = header
+			continue
+		case strings.HasPrefix(header, "Sent ") && strings.Contains(header, " and received "),
+			strings.HasPrefix(header, "Estimated response size:"),
+			strings.HasPrefix(header, "Timing Data:"):
+			continue
+		}
+		body.WriteString(line)
+		body.WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		return call, err
+	}
+	if err := flush(); err != nil {
+		return call, err
+	}
+	if len(call.Responses) == 0 && call.Error == nil {
+		return call, errors.New("transcript has neither response messages nor an error")
+	}
+	return call, nil
+}
+
+func isGrpcurlSkipped(header string) bool {
+	for _, skipped := range grpcurlSkipped {
+		if header == skipped {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonValues splits concatenated JSON values.
+func jsonValues(text string) ([]json.RawMessage, error) {
+	var values []json.RawMessage
+	decoder := json.NewDecoder(strings.NewReader(text))
+	for {
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err == io.EOF {
+			return values, nil
+		} else if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+}
+
+// grpcurlMetadata reads "key: value" lines; "(empty)" has none.
+func grpcurlMetadata(text string) map[string]string {
+	md := make(map[string]string)
+	for _, line := range strings.Split(text, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		key = strings.ToLower(strings.TrimSpace(key))
+		// The transport sets the content type
+		if !ok || key == "" || key == "content-type" {
+			continue
+		}
+		md[key] = strings.TrimSpace(value)
+	}
+	if len(md) == 0 {
+		return nil
+	}
+	return md
+}
+
+// grpcurlStatus reads the "Code:" and "Message:" lines of an ERROR block.
+func grpcurlStatus(text string) *TranscriptError {
+	status := &TranscriptError{}
+	for _, line := range strings.Split(text, "\n") {
+		key, value, _ := strings.Cut(strings.TrimSpace(line), ":")
+		switch key {
+		case "Code":
+			status.Code, _ = json.Marshal(strings.TrimSpace(value))
+		case "Message":
+			status.Message = strings.TrimSpace(value)
+		}
+	}
+	return status
+}
+
+// transcriptCode parses the code of a TranscriptError.
+func transcriptCode(raw json.RawMessage) (codes.Code, error) {
+	var name string
+	if err := json.Unmarshal(raw, &name); err != nil {
+		name = string(raw)
+	}
+	name = strings.TrimSpace(name)
+	for c := codes.OK; c <= codes.Unauthenticated; c++ {
+		if name == c.String() {
+			return c, nil
+		}
+	}
+	code, err := parseCode(name)
+	if err != nil {
+		return codes.Unknown, fmt.Errorf("invalid status code %s", raw)
+	}
+	return code, nil
+}
+
+// TranscriptStubs converts the calls of a transcript into stubs for the
+// loaded services. Like recorded calls, each stub matches the scalar
+// top-level fields of the first request and returns the responses, or the
+// error, of its call. Calls that cannot be converted are listed in skipped.
+func (h *StubHandler) TranscriptStubs(calls []TranscriptCall) (stubs []Stub, skipped []string) {
+	for i, call := range calls {
+		stub, err := h.transcriptStub(call)
+		if err != nil {
+			skipped = append(skipped, fmt.Sprintf("call %d %s: %v", i+1, call.Method, err))
+			continue
+		}
+		stubs = append(stubs, stub)
+	}
+	return stubs, skipped
+}
+
+func (h *StubHandler) transcriptStub(call TranscriptCall) (Stub, error) {
+	method := call.Method
+	if !strings.Contains(method, "/") {
+		// evans names methods pkg.Service.Method
+		if i := strings.LastIndex(method, "."); i > 0 {
+			method = method[:i] + "/" + method[i+1:]
+		}
+	}
+	md, err := h.registry.FindMethod(method)
+	if err != nil {
+		return Stub{}, err
+	}
+
+	requests := call.Requests
+	if len(call.Request) > 0 {
+		requests = append([]json.RawMessage{call.Request}, requests...)
+	}
+	responses := call.Responses
+	if len(call.Response) > 0 {
+		responses = append([]json.RawMessage{call.Response}, responses...)
+	}
+
+	stub := Stub{
+		Method: normalizeMethod(method),
+		Response: StubResponse{
+			Headers:  call.Headers,
+			Trailers: call.Trailers,
+		},
+	}
+	if len(requests) > 0 {
+		request, err := protoNames(requests[0], md.Input())
+		if err != nil {
+			return Stub{}, fmt.Errorf("request: %w", err)
+		}
+		if stub.Match, err = requestMatchers(request); err != nil {
+			return Stub{}, err
+		}
+	}
+	for i, response := range responses {
+		if responses[i], err = protoNames(response, md.Output()); err != nil {
+			return Stub{}, fmt.Errorf("response: %w", err)
+		}
+	}
+	if call.Error != nil {
+		if stub.Response.Code, err = transcriptCode(call.Error.Code); err != nil {
+			return Stub{}, err
+		}
+		stub.Response.Message = call.Error.Message
+	}
+	if stub.Response.Code == codes.OK && len(responses) == 0 {
+		return Stub{}, errors.New("neither responses nor an error")
+	}
+
+	if md.IsStreamingServer() {
+		stub.Response.Stream = responses
+	} else if len(responses) > 0 {
+		stub.Response.Body = responses[0]
+	}
+	if _, err := h.compile(stub); err != nil {
+		return Stub{}, err
+	}
+	return stub, nil
+}
+
+// protoNames checks a message and renders it with the proto field names
+// matchers use; tools print the JSON names (fullName rather than
+// full_name).
+func protoNames(message json.RawMessage, desc protoreflect.MessageDescriptor) (json.RawMessage, error) {
+	msg := newMessage(desc)
+	if err := protojson.Unmarshal(message, msg); err != nil {
+		return nil, err
+	}
+	return protojson.MarshalOptions{UseProtoNames: true}.Marshal(msg)
+}
@@ -0,0 +1,223 @@
+package har
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/url"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"mockserver/internal/journal"
+)
+
+// creatorName names the server in the files it writes.
+const creatorName = "mockserver"
+
+// base64Comment marks a request body that is not text, base64 encoded since
+// postData has no encoding field.
+const base64Comment = "body base64 encoded"
+
+// truncatedComment marks a body longer than what the journal keeps.
+const truncatedComment = "body truncated to the first 64 KiB"
+
+// Export converts the HTTP entries of the journal into a HAR file, in the
+// order given. The URLs of the requests the server received are on base,
+// the scheme and host they were sent to ("http://localhost:8080"); those of
+// the outbound requests are complete already. Bodies are what the journal
+// keeps, their first 64 KiB, and gzip-encoded responses are decoded.
+func Export(entries []journal.Entry, base string) *File {
+	file := &File{Log: Log{
+		Version: Version,
+		Creator: Creator{Name: creatorName, Version: creatorVersion()},
+		Entries: []Entry{},
+	}}
+	for _, entry := range entries {
+		if entry.Protocol != journal.ProtocolHTTP {
+			continue
+		}
+		file.Log.Entries = append(file.Log.Entries, exportEntry(entry, base))
+	}
+	return file
+}
+
+func creatorVersion() string {
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "(devel)"
+}
+
+func exportEntry(entry journal.Entry, base string) Entry {
+	proto := entry.Proto
+	if proto == "" {
+		proto = "HTTP/1.1"
+	}
+	target := entry.Path
+	if !entry.Outbound {
+		target = strings.TrimSuffix(base, "/") + entry.Path
+	}
+	if entry.Query != "" {
+		target += "?" + entry.Query
+	}
+	headers := http.Header(entry.Headers)
+	responseHeaders := http.Header(entry.ResponseHeaders)
+
+	out := Entry{
+		StartedDateTime: entry.Timestamp,
+		Time:            entry.LatencyMs,
+		Request: Request{
+			Method:      entry.Method,
+			URL:         target,
+			HTTPVersion: proto,
+			Cookies:     cookies((&http.Request{Header: headers}).Cookies()),
+			Headers:     nameValues(headers),
+			QueryString: queryString(entry.Query),
+			HeadersSize: -1,
+			BodySize:    entry.RequestSize,
+		},
+		Response: Response{
+			Status:      entry.Status,
+			StatusText:  http.StatusText(entry.Status),
+			HTTPVersion: proto,
+			Cookies:     cookies((&http.Response{Header: responseHeaders}).Cookies()),
+			Headers:     nameValues(responseHeaders),
+			Content:     content(entry, responseHeaders),
+			RedirectURL: responseHeaders.Get("Location"),
+			HeadersSize: -1,
+			BodySize:    entry.ResponseSize,
+		},
+		Timings: Timings{
+			Blocked: -1,
+			DNS:     -1,
+			Connect: -1,
+			Wait:    entry.LatencyMs,
+			SSL:     -1,
+		},
+		JournalID:     entry.ID,
+		StubID:        entry.StubID,
+		CorrelationID: entry.CorrelationID,
+	}
+	if !entry.Outbound {
+		out.ClientIP = entry.Peer
+	}
+	if entry.Body != "" || entry.RequestSize > 0 {
+		out.Request.PostData = postData(entry, headers.Get("Content-Type"))
+		if entry.RequestSize > int64(len(entry.Body)) {
+			out.Request.Comment = truncatedComment
+		}
+	}
+	if entry.Status == 0 {
+		out.Response.Comment = "no response: " + entry.Message
+	}
+	return out
+}
+
+func postData(entry journal.Entry, mimeType string) *PostData {
+	data := &PostData{MimeType: mimeType, Text: entry.Body}
+	if !utf8.ValidString(entry.Body) {
+		data.Text = base64.StdEncoding.EncodeToString([]byte(entry.Body))
+		data.Comment = base64Comment
+		return data
+	}
+	if strings.HasPrefix(mimeType, "application/x-www-form-urlencoded") {
+		data.Params = queryString(entry.Body)
+	}
+	return data
+}
+
+func content(entry journal.Entry, headers http.Header) Content {
+	body := entry.ResponseBody
+	c := Content{Size: int64(len(body)), MimeType: headers.Get("Content-Type")}
+	if c.MimeType == "" {
+		c.MimeType = "x-unknown"
+	}
+	if strings.EqualFold(headers.Get("Content-Encoding"), "gzip") {
+		if decoded, ok := gunzip(body); ok {
+			body = decoded
+			c.Size = int64(len(body))
+		}
+	}
+	if entry.ResponseSize > int64(len(entry.ResponseBody)) {
+		c.Comment = truncatedComment
+	}
+	if utf8.ValidString(body) {
+		c.Text = body
+	} else {
+		c.Text = base64.StdEncoding.EncodeToString([]byte(body))
+		c.Encoding = "base64"
+	}
+	return c
+}
+
+func gunzip(body string) (string, bool) {
+	reader, err := gzip.NewReader(strings.NewReader(body))
+	if err != nil {
+		return "", false
+	}
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, reader); err != nil {
+		return "", false
+	}
+	return buf.String(), true
+}
+
+// nameValues lists headers by name, each value on its own.
+func nameValues(headers http.Header) []NameValue {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pairs := []NameValue{}
+	for _, name := range names {
+		for _, value := range headers[name] {
+			pairs = append(pairs, NameValue{Name: name, Value: value})
+		}
+	}
+	return pairs
+}
+
+// queryString splits a query, or a form body, keeping the order of its
+// parameters.
+func queryString(query string) []NameValue {
+	pairs := []NameValue{}
+	for _, part := range strings.Split(query, "&") {
+		if part == "" {
+			continue
+		}
+		name, value, _ := strings.Cut(part, "=")
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+		if unescaped, err := url.QueryUnescape(value); err == nil {
+			value = unescaped
+		}
+		pairs = append(pairs, NameValue{Name: name, Value: value})
+	}
+	return pairs
+}
+
+func cookies(list []*http.Cookie) []Cookie {
+	out := []Cookie{}
+	for _, cookie := range list {
+		c := Cookie{
+			Name:     cookie.Name,
+			Value:    cookie.Value,
+			Path:     cookie.Path,
+			Domain:   cookie.Domain,
+			HTTPOnly: cookie.HttpOnly,
+			Secure:   cookie.Secure,
+		}
+		if !cookie.Expires.IsZero() {
+			expires := cookie.Expires
+			c.Expires = &expires
+		}
+		out = append(out, c)
+	}
+	return out
+}
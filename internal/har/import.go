@@ -0,0 +1,180 @@
+package har
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"mockserver/internal/match"
+	"mockserver/internal/stubs"
+)
+
+// ImportOptions select the entries of a HAR file turned into stubs and what
+// the stubs match.
+type ImportOptions struct {
+	// Host keeps the entries sent to this host only, with or without its
+	// port ("api.example.com"). Browser recordings hold the requests of every
+	// site a page loads from.
+	Host string
+	// MatchHeaders are the request headers the stubs match, in addition to
+	// the method, path, query and JSON body fields.
+	MatchHeaders []string
+}
+
+// skippedHeaders are the response headers the stubs do not replay: they
+// describe the connection or the encoding of the recorded body, or are
+// recomputed when serving.
+var skippedHeaders = map[string]bool{
+	"Connection":        true,
+	"Content-Encoding":  true,
+	"Content-Length":    true,
+	"Date":              true,
+	"Keep-Alive":        true,
+	"Trailer":           true,
+	"Transfer-Encoding": true,
+	"Upgrade":           true,
+}
+
+// stubPathSyntax finds the path segments a stub path would read as
+// parameters or wildcards.
+var stubPathSyntax = regexp.MustCompile(`(^|/)[:*]`)
+
+// Parse reads a HAR file.
+func Parse(data []byte) (*File, error) {
+	var file File
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	if file.Log.Version == "" && file.Log.Entries == nil {
+		return nil, fmt.Errorf("no log in the file")
+	}
+	return &file, nil
+}
+
+// Stubs converts the entries of a HAR file into HTTP stubs, in the order of
+// the file: each answers the method, path and query of its request (and its
+// JSON body fields) with the recorded response. Entries that cannot be
+// replayed, or that repeat the request of an earlier one, are reported in
+// skipped.
+func Stubs(file *File, options ImportOptions) ([]stubs.Stub, []string) {
+	var list []stubs.Stub
+	var skipped []string
+	seen := make(map[string]string)
+	for i, entry := range file.Log.Entries {
+		name := fmt.Sprintf("har-%d", i+1)
+		target, err := url.Parse(entry.Request.URL)
+		if err != nil {
+			skipped = append(skipped, fmt.Sprintf("%s %s %s: invalid URL", name, entry.Request.Method, entry.Request.URL))
+			continue
+		}
+		if options.Host != "" && target.Host != options.Host && target.Hostname() != options.Host {
+			continue
+		}
+		stub, err := importEntry(entry, target, options)
+		if err != nil {
+			skipped = append(skipped, fmt.Sprintf("%s %s %s: %v", name, entry.Request.Method, entry.Request.URL, err))
+			continue
+		}
+		stub.Name = name
+		key, _ := json.Marshal(stub.Request)
+		if first, ok := seen[string(key)]; ok {
+			skipped = append(skipped, fmt.Sprintf("%s %s %s: same request as %s", name, entry.Request.Method, entry.Request.URL, first))
+			continue
+		}
+		seen[string(key)] = name
+		list = append(list, stub)
+	}
+	return list, skipped
+}
+
+func importEntry(entry Entry, target *url.URL, options ImportOptions) (stubs.Stub, error) {
+	response := entry.Response
+	switch {
+	case response.Status == 0:
+		return stubs.Stub{}, fmt.Errorf("no response")
+	case response.Content.Comment == truncatedComment:
+		return stubs.Stub{}, fmt.Errorf("response body truncated")
+	}
+	body := response.Content.Text
+	if response.Content.Encoding == "base64" {
+		decoded, err := base64.StdEncoding.DecodeString(body)
+		if err != nil {
+			return stubs.Stub{}, fmt.Errorf("invalid base64 response body")
+		}
+		body = string(decoded)
+	}
+	if !utf8.ValidString(body) {
+		return stubs.Stub{}, fmt.Errorf("binary response body")
+	}
+
+	path := target.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	stub := stubs.Stub{
+		Request: stubs.Request{Method: entry.Request.Method, Path: path},
+		Response: stubs.Response{
+			Status:  response.Status,
+			Headers: responseHeaders(response.Headers),
+		},
+	}
+	if stubPathSyntax.MatchString(path) {
+		stub.Request.Path = ""
+		stub.Request.PathRegex = "^" + regexp.QuoteMeta(path) + "$"
+	}
+
+	query := target.Query()
+	for _, name := range slices.Sorted(maps.Keys(query)) {
+		stub.Request.Query = append(stub.Request.Query, match.FieldMatcher{Field: name, Equals: query.Get(name)})
+	}
+	for _, name := range options.MatchHeaders {
+		for _, header := range entry.Request.Headers {
+			if strings.EqualFold(header.Name, name) {
+				stub.Request.Headers = append(stub.Request.Headers, match.FieldMatcher{Field: name, Equals: header.Value})
+				break
+			}
+		}
+	}
+	if data := entry.Request.PostData; data != nil && data.Comment != base64Comment {
+		stub.Request.Body = stubs.BodyMatchers(data.Text)
+	}
+
+	contentType := stub.Response.Headers["Content-Type"]
+	if contentType == "" {
+		contentType = response.Content.MimeType
+	}
+	if strings.Contains(contentType, "json") && json.Valid([]byte(body)) {
+		stub.Response.JSONBody = json.RawMessage(body)
+	} else {
+		stub.Response.Body = body
+	}
+	return stub, nil
+}
+
+// responseHeaders flattens the replayed response headers, dropping the
+// pseudo-headers of HTTP/2 recordings.
+func responseHeaders(headers []NameValue) map[string]string {
+	out := make(map[string]string)
+	for _, header := range headers {
+		name := http.CanonicalHeaderKey(header.Name)
+		if strings.HasPrefix(name, ":") || skippedHeaders[name] {
+			continue
+		}
+		if value, ok := out[name]; ok {
+			out[name] = value + "," + header.Value
+		} else {
+			out[name] = header.Value
+		}
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
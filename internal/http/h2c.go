@@ -0,0 +1,56 @@
+package http
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/net/http2"
+)
+
+// HTTP2Config tunes HTTP/2, served in cleartext (h2c) on the HTTP listener
+// next to HTTP/1.1, both with prior knowledge and through the h2c upgrade.
+// The flow control windows are the amounts of request data a client may
+// send ahead, per connection and per stream. Zero values keep the defaults.
+type HTTP2Config struct {
+	// Disabled serves HTTP/1.1 only.
+	Disabled                     bool   `json:"disabled,omitempty"`
+	MaxConcurrentStreams         uint32 `json:"max_concurrent_streams,omitempty"`
+	MaxReadFrameSize             uint32 `json:"max_read_frame_size,omitempty"`
+	MaxUploadBufferPerConnection int32  `json:"max_upload_buffer_per_connection,omitempty"`
+	MaxUploadBufferPerStream     int32  `json:"max_upload_buffer_per_stream,omitempty"`
+	// IdleTimeout closes idle connections with a GOAWAY.
+	IdleTimeout string `json:"idle_timeout,omitempty"`
+}
+
+// Validate checks the frame size, windows and timeout.
+func (c HTTP2Config) Validate() error {
+	_, err := c.Server()
+	return err
+}
+
+// Server returns the HTTP/2 server settings.
+func (c HTTP2Config) Server() (*http2.Server, error) {
+	s := &http2.Server{
+		MaxConcurrentStreams:         c.MaxConcurrentStreams,
+		MaxReadFrameSize:             c.MaxReadFrameSize,
+		MaxUploadBufferPerConnection: c.MaxUploadBufferPerConnection,
+		MaxUploadBufferPerStream:     c.MaxUploadBufferPerStream,
+	}
+	if c.MaxReadFrameSize != 0 && (c.MaxReadFrameSize < 16<<10 || c.MaxReadFrameSize > 1<<24-1) {
+		return nil, fmt.Errorf("http2: max_read_frame_size must be between 16384 and 16777215")
+	}
+	if c.MaxUploadBufferPerConnection < 0 || (c.MaxUploadBufferPerConnection > 0 && c.MaxUploadBufferPerConnection < 65535) {
+		return nil, fmt.Errorf("http2: max_upload_buffer_per_connection must be at least 65535")
+	}
+	if c.MaxUploadBufferPerStream < 0 {
+		return nil, fmt.Errorf("http2: invalid max_upload_buffer_per_stream %d", c.MaxUploadBufferPerStream)
+	}
+	if c.IdleTimeout != "" {
+		timeout, err := time.ParseDuration(c.IdleTimeout)
+		if err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("http2: invalid idle_timeout %q", c.IdleTimeout)
+		}
+		s.IdleTimeout = timeout
+	}
+	return s, nil
+}
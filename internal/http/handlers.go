@@ -10,19 +10,63 @@ import (
 	"time"
 
 	"github.com/labstack/echo/v4"
+
+	"mockserver/internal/locale"
+	"mockserver/internal/timefmt"
 )
 
-type HTTPHandlers struct{}
+type HTTPHandlers struct {
+	timestamps timefmt.Formatter
+	quietEcho  bool
+}
 
 func NewHTTPHandlers() *HTTPHandlers {
 	return &HTTPHandlers{}
 }
 
+// SetTimestamps sets the format and zone of the timestamp field of responses
+// (unix seconds by default). Call it before serving requests.
+func (h *HTTPHandlers) SetTimestamps(f timefmt.Formatter) {
+	h.timestamps = f
+}
+
+// SetQuietEcho makes /echo answer without a timestamp, and POST /echo with
+// the request body as it came instead of a description of the request.
+// Call it before serving requests.
+func (h *HTTPHandlers) SetQuietEcho(quiet bool) {
+	h.quietEcho = quiet
+}
+
+// quiet reports whether an echo response is quiet: the quiet query
+// parameter overrides the configuration; an invalid one is ignored.
+func (h *HTTPHandlers) quiet(c echo.Context) bool {
+	if quiet, err := strconv.ParseBool(c.QueryParam("quiet")); err == nil {
+		return quiet
+	}
+	return h.quietEcho
+}
+
+// timestamp renders the current time for a response. The ts_format and
+// ts_zone query parameters override the configured format and zone; an
+// invalid override is ignored.
+func (h *HTTPHandlers) timestamp(c echo.Context) interface{} {
+	f := h.timestamps
+	if format := c.QueryParam("ts_format"); format != "" {
+		f = f.WithFormat(format)
+	}
+	if zone := c.QueryParam("ts_zone"); zone != "" {
+		if loc, err := timefmt.LoadZone(zone); err == nil {
+			f = f.In(loc)
+		}
+	}
+	return f.Value(time.Now())
+}
+
 // Health check endpoint
 func (h *HTTPHandlers) Health(c echo.Context) error {
 	return c.JSON(http.StatusOK, map[string]interface{}{
 		"status": "healthy",
-		"timestamp": time.Now().Unix(),
+		"timestamp": h.timestamp(c),
 	})
 }
 
@@ -35,13 +79,16 @@ func (h *HTTPHandlers) EchoGet(c echo.Context) error {
 		}
 	}
 
-	return c.JSON(http.StatusOK, map[string]interface{}{
+	response := map[string]interface{}{
 		"method": c.Request().Method,
 		"path": c.Path(),
 		"query": c.QueryParams(),
 		"headers": headers,
-		"timestamp": time.Now().Unix(),
-	})
+	}
+	if !h.quiet(c) {
+		response["timestamp"] = h.timestamp(c)
+	}
+	return c.JSON(http.StatusOK, response)
 }
 
 // Echo back JSON payload with graceful error handling
@@ -59,10 +106,19 @@ func (h *HTTPHandlers) EchoPost(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]interface{}{
 			"error": "Failed to read request body",
 			"details": err.Error(),
-			"timestamp": time.Now().Unix(),
+			"timestamp": h.timestamp(c),
 		})
 	}
 
+	// Quiet: the body as it came, under the request's content type
+	if h.quiet(c) {
+		contentType := c.Request().Header.Get(echo.HeaderContentType)
+		if contentType == "" {
+			contentType = echo.MIMEOctetStream
+		}
+		return c.Blob(http.StatusOK, contentType, bodyBytes)
+	}
+
 	bodyString := string(bodyBytes)
 	
 	// Response structure
@@ -70,7 +126,7 @@ func (h *HTTPHandlers) EchoPost(c echo.Context) error {
 		"method": c.Request().Method,
 		"path": c.Path(),
 		"headers": headers,
-		"timestamp": time.Now().Unix(),
+		"timestamp": h.timestamp(c),
 	}
 
 	// Check if body is empty
@@ -121,7 +177,7 @@ func (h *HTTPHandlers) Delay(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]interface{}{
 			"error": "Invalid delay parameter. Must be 0-30 seconds",
 			"provided": secondsStr,
-			"timestamp": time.Now().Unix(),
+			"timestamp": h.timestamp(c),
 		})
 	}
 
@@ -130,7 +186,7 @@ func (h *HTTPHandlers) Delay(c echo.Context) error {
 	return c.JSON(http.StatusOK, map[string]interface{}{
 		"message": "Response after delay",
 		"delay_seconds": seconds,
-		"timestamp": time.Now().Unix(),
+		"timestamp": h.timestamp(c),
 	})
 }
 
@@ -142,7 +198,7 @@ func (h *HTTPHandlers) Status(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]interface{}{
 			"error": "Invalid status code. Must be 100-599",
 			"provided": codeStr,
-			"timestamp": time.Now().Unix(),
+			"timestamp": h.timestamp(c),
 		})
 	}
 
@@ -154,6 +210,85 @@ func (h *HTTPHandlers) Status(c echo.Context) error {
 	return c.JSON(code, map[string]interface{}{
 		"status_code": code,
 		"message": message,
-		"timestamp": time.Now().Unix(),
+		"timestamp": h.timestamp(c),
 	})
-}
\ No newline at end of file
+}
+
+// maxAbortBytes caps the body written before an aborted response breaks off.
+const maxAbortBytes = 1 << 20
+
+// Abort breaks the response off after writing after_bytes of its body (none
+// by default, not even the headers): an HTTP/2 stream is reset, an HTTP/1.1
+// connection closed. The announced Content-Length is twice what is sent.
+func (h *HTTPHandlers) Abort(c echo.Context) error {
+	after := 0
+	if value := c.QueryParam("after_bytes"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 || n > maxAbortBytes {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"error":     fmt.Sprintf("Invalid after_bytes. Must be 0-%d", maxAbortBytes),
+				"provided":  value,
+				"timestamp": h.timestamp(c),
+			})
+		}
+		after = n
+	}
+
+	if after > 0 {
+		res := c.Response()
+		res.Header().Set(echo.HeaderContentType, echo.MIMETextPlain)
+		res.Header().Set(echo.HeaderContentLength, strconv.Itoa(2*after))
+		res.WriteHeader(http.StatusOK)
+		res.Write([]byte(strings.Repeat("x", after)))
+		res.Flush()
+	}
+	// net/http resets the stream, or closes the connection, without logging
+	panic(http.ErrAbortHandler)
+}
+
+// Report the locale negotiated from Accept-Language. The supported and
+// fallback query parameters are comma-separated locales; without supported,
+// every requested locale is accepted.
+func (h *HTTPHandlers) I18n(c echo.Context) error {
+	header := c.Request().Header.Get("Accept-Language")
+	preferences := locale.Parse(header)
+	if preferences == nil {
+		preferences = []locale.Preference{}
+	}
+
+	supported := splitList(c.QueryParam("supported"))
+	if len(supported) == 0 {
+		for _, pref := range preferences {
+			if pref.Quality > 0 && pref.Range != "*" {
+				supported = append(supported, pref.Range)
+			}
+		}
+	}
+	fallback := splitList(c.QueryParam("fallback"))
+	match := locale.Negotiate(header, supported, fallback)
+
+	c.Response().Header().Add("Vary", "Accept-Language")
+	if match.Locale != "" {
+		c.Response().Header().Set("Content-Language", match.Locale)
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"accept_language": header,
+		"preferences": preferences,
+		"supported": supported,
+		"fallback": fallback,
+		"locale": match.Locale,
+		"matched_by": match.By,
+		"timestamp": h.timestamp(c),
+	})
+}
+
+// splitList splits a comma-separated query parameter, dropping empty items.
+func splitList(value string) []string {
+	items := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
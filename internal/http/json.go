@@ -0,0 +1,182 @@
+package http
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Caps of the JSON generators; the largest documents are a few tens of MB.
+const (
+	MaxJSONDepth = 1000000
+	MaxJSONKeys  = 1000000
+	MaxJSONArray = 5000000
+)
+
+// JSONDeep generates a valid document nested depth levels deep, as arrays
+// ([[[...]]]) or, with type=object, objects ({"a":{"a":...}}), for testing
+// the recursion limits of client parsers.
+func (h *HTTPHandlers) JSONDeep(c echo.Context) error {
+	depth, ok := intQuery(c, "depth", 1000, 1, MaxJSONDepth)
+	if !ok {
+		return h.invalidGenerator(c, "depth", 1, MaxJSONDepth)
+	}
+	kind := c.QueryParam("type")
+	if kind != "" && kind != "array" && kind != "object" {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error":     "Invalid type parameter. Must be array or object",
+			"provided":  kind,
+			"timestamp": h.timestamp(c),
+		})
+	}
+
+	open, end, leaf := "[", "]", "[]"
+	if kind == "object" {
+		open, end, leaf = `{"a":`, "}", "{}"
+	}
+	return streamJSON(c, func(w *bufio.Writer) {
+		for i := 1; i < depth; i++ {
+			w.WriteString(open)
+		}
+		w.WriteString(leaf)
+		for i := 1; i < depth; i++ {
+			w.WriteString(end)
+		}
+	})
+}
+
+// JSONWide generates an object with keys members ("k0":0, "k1":1, ...) and,
+// with array, an "items" member holding that many numbers, for testing the
+// memory limits of client parsers.
+func (h *HTTPHandlers) JSONWide(c echo.Context) error {
+	keys, ok := intQuery(c, "keys", 1000, 0, MaxJSONKeys)
+	if !ok {
+		return h.invalidGenerator(c, "keys", 0, MaxJSONKeys)
+	}
+	items, ok := intQuery(c, "array", 0, 0, MaxJSONArray)
+	if !ok {
+		return h.invalidGenerator(c, "array", 0, MaxJSONArray)
+	}
+
+	return streamJSON(c, func(w *bufio.Writer) {
+		w.WriteByte('{')
+		for i := 0; i < keys; i++ {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, `"k%d":%d`, i, i)
+		}
+		if items > 0 {
+			if keys > 0 {
+				w.WriteByte(',')
+			}
+			w.WriteString(`"items":[`)
+			for i := 0; i < items; i++ {
+				if i > 0 {
+					w.WriteByte(',')
+				}
+				w.WriteString(strconv.Itoa(i))
+			}
+			w.WriteByte(']')
+		}
+		w.WriteByte('}')
+	})
+}
+
+// NonStandardJSONFeatures are the deviations from RFC 8259 that
+// /json/nonstandard can emit, each as object members with known values.
+var NonStandardJSONFeatures = []string{
+	"duplicate_keys", "nan", "infinity", "comments", "trailing_commas", "single_quotes", "unquoted_keys",
+}
+
+var nonStandardMembers = map[string][]string{
+	"duplicate_keys":  {`"id": 1`, `"id": 2`, `"nested": {"name": "first", "name": "last"}`},
+	"nan":             {`"nan": NaN`},
+	"infinity":        {`"infinity": Infinity`, `"negative_infinity": -Infinity`},
+	"trailing_commas": {`"list": [1, 2, 3,]`, `"object": {"a": 1,}`},
+	"single_quotes":   {`'single_quoted': 'value'`},
+	"unquoted_keys":   {`unquoted: true`},
+}
+
+// JSONNonStandard writes a document using the comma-separated features
+// (all of them by default), for testing how strict client parsers are.
+// Comments add a block comment before the document and a line comment
+// before each member; trailing commas also end the object.
+func (h *HTTPHandlers) JSONNonStandard(c echo.Context) error {
+	features := splitList(c.QueryParam("features"))
+	if len(features) == 0 {
+		features = NonStandardJSONFeatures
+	}
+	enabled := make(map[string]bool, len(features))
+	for _, feature := range features {
+		if !slices.Contains(NonStandardJSONFeatures, feature) {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"error":     "Invalid features parameter",
+				"provided":  feature,
+				"supported": NonStandardJSONFeatures,
+				"timestamp": h.timestamp(c),
+			})
+		}
+		enabled[feature] = true
+	}
+
+	members := []string{`"standard": "a plain member"`}
+	for _, feature := range NonStandardJSONFeatures {
+		if enabled[feature] {
+			members = append(members, nonStandardMembers[feature]...)
+		}
+	}
+
+	var b strings.Builder
+	if enabled["comments"] {
+		b.WriteString("/* non-standard JSON generated by mockserver */\n")
+	}
+	b.WriteString("{\n")
+	for i, member := range members {
+		if enabled["comments"] {
+			b.WriteString("  // member " + strconv.Itoa(i+1) + "\n")
+		}
+		b.WriteString("  " + member)
+		if i < len(members)-1 || enabled["trailing_commas"] {
+			b.WriteString(",")
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("}\n")
+	return c.Blob(http.StatusOK, echo.MIMEApplicationJSON, []byte(b.String()))
+}
+
+// streamJSON writes a generated document without holding it in memory.
+func streamJSON(c echo.Context, generate func(w *bufio.Writer)) error {
+	c.Response().Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	c.Response().WriteHeader(http.StatusOK)
+	w := bufio.NewWriterSize(c.Response(), 64<<10)
+	generate(w)
+	return w.Flush()
+}
+
+// intQuery reads a query parameter between min and max, fallback when absent.
+func intQuery(c echo.Context, name string, fallback, min, max int) (int, bool) {
+	value := strings.TrimSpace(c.QueryParam(name))
+	if value == "" {
+		return fallback, true
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < min || n > max {
+		return 0, false
+	}
+	return n, true
+}
+
+func (h *HTTPHandlers) invalidGenerator(c echo.Context, name string, min, max int) error {
+	return c.JSON(http.StatusBadRequest, map[string]interface{}{
+		"error":     fmt.Sprintf("Invalid %s parameter. Must be %d-%d", name, min, max),
+		"provided":  c.QueryParam(name),
+		"timestamp": h.timestamp(c),
+	})
+}
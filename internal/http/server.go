@@ -35,6 +35,11 @@ func (s *Server) SetupRoutes() {
 	s.echo.POST("/echo", s.handlers.EchoPost)
 	s.echo.GET("/delay/:seconds", s.handlers.Delay)
 	s.echo.GET("/status/:code", s.handlers.Status)
+	s.echo.GET("/abort", s.handlers.Abort)
+	s.echo.GET("/i18n", s.handlers.I18n)
+	s.echo.GET("/json/deep", s.handlers.JSONDeep)
+	s.echo.GET("/json/wide", s.handlers.JSONWide)
+	s.echo.GET("/json/nonstandard", s.handlers.JSONNonStandard)
 }
 
 func (s *Server) GetEcho() *echo.Echo {
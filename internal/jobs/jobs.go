@@ -0,0 +1,130 @@
+// Package jobs simulates an asynchronous job API, the long-running
+// operation pattern: submitting a job answers 202 with its location, and
+// polling it shows the job moving through its states on a configurable
+// timeline until it succeeds or fails, optionally notifying a webhook.
+package jobs
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Terminal states of a job.
+const (
+	StateSucceeded = "succeeded"
+	StateFailed    = "failed"
+	StateCancelled = "cancelled"
+)
+
+// Request headers overriding the configuration for one job.
+const (
+	OutcomeHeader = "X-Mock-Job-Outcome"
+	WebhookHeader = "X-Mock-Job-Webhook"
+)
+
+const defaultPath = "/jobs"
+
+// DefaultStates are the states jobs go through when the configuration lists
+// none.
+var DefaultStates = []State{{Name: "queued", Duration: "1s"}, {Name: "running", Duration: "3s"}}
+
+// Config holds the job API settings; JOBS_ENABLED=true also enables it.
+type Config struct {
+	Enabled bool `json:"enabled"`
+	// Path is the base path of the API, /jobs by default.
+	Path string `json:"path,omitempty"`
+	// States are the states a job goes through, in order, before it ends.
+	States []State `json:"states,omitempty"`
+	// Outcome is the final state, succeeded (default) or failed; the
+	// X-Mock-Job-Outcome header chooses it per job.
+	Outcome string `json:"outcome,omitempty"`
+	// Result is the result of succeeded jobs and Error the error of failed
+	// ones.
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  json.RawMessage `json:"error,omitempty"`
+	// Webhook receives a POST of the job when it ends; the
+	// X-Mock-Job-Webhook header sets it per job.
+	Webhook string `json:"webhook,omitempty"`
+}
+
+// State is a step of the timeline of a job, lasting Duration.
+type State struct {
+	Name     string `json:"name"`
+	Duration string `json:"duration"`
+}
+
+// Validate checks the path, states, outcome and webhook.
+func (c Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.Path != "" && (!strings.HasPrefix(c.Path, "/") || strings.HasPrefix(c.Path, "/__admin")) {
+		return fmt.Errorf("jobs: invalid path %q", c.Path)
+	}
+	if _, err := c.timeline(); err != nil {
+		return err
+	}
+	if err := validOutcome(c.Outcome); err != nil {
+		return err
+	}
+	if c.Webhook != "" {
+		if err := validWebhook(c.Webhook); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c Config) path() string {
+	if c.Path == "" {
+		return defaultPath
+	}
+	return strings.TrimRight(c.Path, "/")
+}
+
+// step is a parsed state.
+type step struct {
+	name     string
+	duration time.Duration
+}
+
+func (c Config) timeline() ([]step, error) {
+	states := c.States
+	if len(states) == 0 {
+		states = DefaultStates
+	}
+	steps := make([]step, len(states))
+	for i, state := range states {
+		switch state.Name {
+		case "":
+			return nil, fmt.Errorf("jobs: state %d has no name", i+1)
+		case StateSucceeded, StateFailed, StateCancelled:
+			return nil, fmt.Errorf("jobs: %s is a final state", state.Name)
+		}
+		d, err := time.ParseDuration(state.Duration)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("jobs: state %s: invalid duration %q", state.Name, state.Duration)
+		}
+		steps[i] = step{name: state.Name, duration: d}
+	}
+	return steps, nil
+}
+
+func validOutcome(outcome string) error {
+	switch outcome {
+	case "", StateSucceeded, StateFailed:
+		return nil
+	}
+	return fmt.Errorf("jobs: invalid outcome %q, want %s or %s", outcome, StateSucceeded, StateFailed)
+}
+
+func validWebhook(webhook string) error {
+	u, err := url.Parse(webhook)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("jobs: invalid webhook %q", webhook)
+	}
+	return nil
+}
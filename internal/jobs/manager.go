@@ -0,0 +1,363 @@
+package jobs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"mockserver/internal/logging"
+)
+
+var logger = logging.For("jobs")
+
+const (
+	// maxJobs bounds memory use; the oldest jobs are forgotten beyond it.
+	maxJobs = 10000
+	// maxRequest caps the submitted payload kept with a job.
+	maxRequest = 1024 * 1024
+
+	webhookTimeout = 10 * time.Second
+)
+
+// Job is the view of a job served by the API. UpdatedAt is when the job
+// entered its current state.
+type Job struct {
+	ID        string          `json:"id"`
+	State     string          `json:"state"`
+	Progress  int             `json:"progress"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+	Request   json.RawMessage `json:"request,omitempty"`
+	Result    json.RawMessage `json:"result,omitempty"`
+	Error     json.RawMessage `json:"error,omitempty"`
+	Webhook   *Delivery       `json:"webhook,omitempty"`
+}
+
+// Delivery is the completion webhook of a job and, once sent, its outcome.
+type Delivery struct {
+	URL         string     `json:"url"`
+	StatusCode  int        `json:"status_code,omitempty"`
+	Error       string     `json:"error,omitempty"`
+	DeliveredAt *time.Time `json:"delivered_at,omitempty"`
+}
+
+type job struct {
+	id        string
+	created   time.Time
+	outcome   string
+	request   json.RawMessage
+	cancelled time.Time
+	webhook   *Delivery
+	timer     *time.Timer
+}
+
+// Manager runs the jobs of the API.
+type Manager struct {
+	config Config
+	path   string
+	steps  []step
+	total  time.Duration
+	client *http.Client
+
+	jobs   map[string]*job
+	order  []string
+	nextID int
+	mutex  sync.Mutex
+}
+
+func New(config Config) (*Manager, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	steps, _ := config.timeline()
+	m := &Manager{
+		config: config,
+		path:   config.path(),
+		steps:  steps,
+		client: &http.Client{Timeout: webhookTimeout},
+		jobs:   make(map[string]*job),
+	}
+	for _, s := range steps {
+		m.total += s.duration
+	}
+	return m, nil
+}
+
+// Path returns the base path of the API.
+func (m *Manager) Path() string {
+	return m.path
+}
+
+// Register adds the endpoints to the server.
+func (m *Manager) Register(e *echo.Echo) {
+	e.POST(m.path, m.Submit)
+	e.GET(m.path, m.List)
+	e.GET(m.path+"/:id", m.Get)
+	e.DELETE(m.path+"/:id", m.Cancel)
+}
+
+// Submit creates a job from the request and answers 202 with its location.
+func (m *Manager) Submit(c echo.Context) error {
+	outcome := c.Request().Header.Get(OutcomeHeader)
+	if outcome == "" {
+		outcome = m.config.Outcome
+	}
+	if err := validOutcome(outcome); err != nil {
+		return invalidJob(c, err.Error())
+	}
+	if outcome == "" {
+		outcome = StateSucceeded
+	}
+	webhook := c.Request().Header.Get(WebhookHeader)
+	if webhook == "" {
+		webhook = m.config.Webhook
+	} else if err := validWebhook(webhook); err != nil {
+		return invalidJob(c, err.Error())
+	}
+	data, err := io.ReadAll(io.LimitReader(c.Request().Body, maxRequest))
+	if err != nil {
+		return invalidJob(c, err.Error())
+	}
+
+	now := time.Now()
+	j := &job{created: now, outcome: outcome}
+	if json.Valid(data) {
+		j.request = data
+	}
+	if webhook != "" {
+		j.webhook = &Delivery{URL: webhook}
+	}
+
+	m.mutex.Lock()
+	m.nextID++
+	j.id = fmt.Sprintf("job-%d", m.nextID)
+	m.jobs[j.id] = j
+	m.order = append(m.order, j.id)
+	if len(m.order) > maxJobs {
+		m.forgetLocked(m.order[0])
+	}
+	id := j.id
+	j.timer = time.AfterFunc(m.total, func() { m.end(id) })
+	view, next := m.viewLocked(j, now)
+	m.mutex.Unlock()
+
+	logger.DebugContext(c.Request().Context(), "Job submitted", "job_id", id, "outcome", outcome, "duration", m.total)
+	c.Response().Header().Set(echo.HeaderLocation, m.path+"/"+id)
+	setRetryAfter(c, next)
+	return c.JSON(http.StatusAccepted, view)
+}
+
+// List returns every job, oldest first.
+func (m *Manager) List(c echo.Context) error {
+	jobs := m.Jobs()
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"jobs":      jobs,
+		"count":     len(jobs),
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// Get returns the current state of a job, with a Retry-After until its next
+// state while it runs.
+func (m *Manager) Get(c echo.Context) error {
+	m.mutex.Lock()
+	j, ok := m.jobs[c.Param("id")]
+	var view Job
+	var next time.Duration
+	if ok {
+		view, next = m.viewLocked(j, time.Now())
+	}
+	m.mutex.Unlock()
+	if !ok {
+		return jobNotFound(c)
+	}
+	setRetryAfter(c, next)
+	return c.JSON(http.StatusOK, view)
+}
+
+// Cancel cancels a running job; a job that ended answers 409.
+func (m *Manager) Cancel(c echo.Context) error {
+	m.mutex.Lock()
+	j, ok := m.jobs[c.Param("id")]
+	if !ok {
+		m.mutex.Unlock()
+		return jobNotFound(c)
+	}
+	now := time.Now()
+	if view, _ := m.viewLocked(j, now); isFinal(view.State) {
+		m.mutex.Unlock()
+		return c.JSON(http.StatusConflict, map[string]interface{}{
+			"error":     "Job already ended",
+			"provided":  j.id,
+			"state":     view.State,
+			"timestamp": now.Unix(),
+		})
+	}
+	j.cancelled = now
+	j.timer.Stop()
+	view, _ := m.viewLocked(j, now)
+	m.mutex.Unlock()
+
+	logger.DebugContext(c.Request().Context(), "Job cancelled", "job_id", j.id)
+	go m.notify(j.id)
+	return c.JSON(http.StatusOK, view)
+}
+
+// Jobs returns every job, oldest first.
+func (m *Manager) Jobs() []Job {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	now := time.Now()
+	jobs := make([]Job, 0, len(m.order))
+	for _, id := range m.order {
+		view, _ := m.viewLocked(m.jobs[id], now)
+		jobs = append(jobs, view)
+	}
+	return jobs
+}
+
+// Reset forgets every job, without notifying their webhooks.
+func (m *Manager) Reset() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for _, id := range m.order {
+		m.jobs[id].timer.Stop()
+	}
+	m.jobs = make(map[string]*job)
+	m.order = nil
+	logger.Info("All jobs removed")
+}
+
+func (m *Manager) forgetLocked(id string) {
+	m.jobs[id].timer.Stop()
+	delete(m.jobs, id)
+	m.order = m.order[1:]
+}
+
+// viewLocked computes the state of a job at now from its timeline, and the
+// time left until the next state (0 once it ended).
+func (m *Manager) viewLocked(j *job, now time.Time) (Job, time.Duration) {
+	view := Job{ID: j.id, CreatedAt: j.created, Request: j.request}
+	if j.webhook != nil {
+		delivery := *j.webhook
+		view.Webhook = &delivery
+	}
+	if !j.cancelled.IsZero() {
+		view.State = StateCancelled
+		view.UpdatedAt = j.cancelled
+		view.Progress = m.progress(j.cancelled.Sub(j.created))
+		return view, 0
+	}
+
+	elapsed := now.Sub(j.created)
+	start := time.Duration(0)
+	for _, s := range m.steps {
+		if elapsed < start+s.duration {
+			view.State = s.name
+			view.UpdatedAt = j.created.Add(start)
+			view.Progress = m.progress(elapsed)
+			return view, start + s.duration - elapsed
+		}
+		start += s.duration
+	}
+	view.State = j.outcome
+	view.UpdatedAt = j.created.Add(m.total)
+	view.Progress = 100
+	if j.outcome == StateSucceeded {
+		view.Result = m.config.Result
+	} else {
+		view.Error = m.config.Error
+		if view.Error == nil {
+			view.Error = json.RawMessage(`{"message": "job failed"}`)
+		}
+	}
+	return view, 0
+}
+
+func (m *Manager) progress(elapsed time.Duration) int {
+	if m.total == 0 || elapsed >= m.total {
+		return 100
+	}
+	return int(elapsed * 100 / m.total)
+}
+
+// end is called when the timeline of a job is over.
+func (m *Manager) end(id string) {
+	m.mutex.Lock()
+	j, ok := m.jobs[id]
+	m.mutex.Unlock()
+	if !ok {
+		return
+	}
+	logger.Debug("Job ended", "job_id", id, "outcome", j.outcome)
+	m.notify(id)
+}
+
+// notify posts a job that ended to its webhook.
+func (m *Manager) notify(id string) {
+	m.mutex.Lock()
+	j, ok := m.jobs[id]
+	if !ok || j.webhook == nil {
+		m.mutex.Unlock()
+		return
+	}
+	view, _ := m.viewLocked(j, time.Now())
+	m.mutex.Unlock()
+
+	body, _ := json.Marshal(view)
+	delivery := Delivery{URL: view.Webhook.URL}
+	resp, err := m.client.Post(delivery.URL, echo.MIMEApplicationJSON, bytes.NewReader(body))
+	if err != nil {
+		delivery.Error = err.Error()
+		logger.Warn("Webhook failed", "job_id", id, "url", delivery.URL, "error", err)
+	} else {
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		delivery.StatusCode = resp.StatusCode
+		logger.Debug("Webhook delivered", "job_id", id, "url", delivery.URL, "status", resp.StatusCode)
+	}
+	delivered := time.Now()
+	delivery.DeliveredAt = &delivered
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if j, ok := m.jobs[id]; ok {
+		j.webhook = &delivery
+	}
+}
+
+func isFinal(state string) bool {
+	return state == StateSucceeded || state == StateFailed || state == StateCancelled
+}
+
+func setRetryAfter(c echo.Context, next time.Duration) {
+	if next <= 0 {
+		return
+	}
+	seconds := int64((next + time.Second - 1) / time.Second)
+	c.Response().Header().Set("Retry-After", strconv.FormatInt(seconds, 10))
+}
+
+func invalidJob(c echo.Context, details string) error {
+	return c.JSON(http.StatusBadRequest, map[string]interface{}{
+		"error":     "Invalid job",
+		"details":   details,
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+func jobNotFound(c echo.Context) error {
+	return c.JSON(http.StatusNotFound, map[string]interface{}{
+		"error":     "Job not found",
+		"provided":  c.Param("id"),
+		"timestamp": time.Now().Unix(),
+	})
+}
@@ -0,0 +1,423 @@
+// Package jose produces JWS-signed and JWE-encrypted payloads with the keys
+// of the mock server, and publishes their public halves as a JWK set so that
+// clients can verify and decrypt them like real provider payloads.
+package jose
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+
+	"mockserver/internal/logging"
+)
+
+var logger = logging.For("jose")
+
+// Signature and key management algorithms.
+const (
+	RS256 = "RS256"
+	ES256 = "ES256"
+	HS256 = "HS256"
+
+	// KeyEncryption and ContentEncryption are used for every JWE.
+	KeyEncryption     = "RSA-OAEP-256"
+	ContentEncryption = "A256GCM"
+)
+
+// KeyConfig declares a key. Algorithm is RS256 (the default), ES256 or
+// HS256. Asymmetric keys are read from PrivateKeyFile (PEM, PKCS#1, PKCS#8
+// or SEC 1) or generated at startup; PublicKeyFile instead adds the public
+// key of a client, which can only be used to encrypt payloads for it. HS256
+// keys use Secret and are never published.
+type KeyConfig struct {
+	ID             string `json:"id"`
+	Algorithm      string `json:"algorithm,omitempty"`
+	PrivateKeyFile string `json:"private_key_file,omitempty"`
+	PublicKeyFile  string `json:"public_key_file,omitempty"`
+	Secret         string `json:"secret,omitempty"`
+}
+
+// Default key IDs, generated when no key is configured.
+const (
+	DefaultRSAKey = "mock-rs256"
+	DefaultECKey  = "mock-es256"
+)
+
+// Key is a loaded key.
+type Key struct {
+	ID        string
+	Algorithm string
+	private   crypto.Signer
+	public    crypto.PublicKey
+	secret    []byte
+	generated bool
+}
+
+// KeyInfo describes a key in the admin API.
+type KeyInfo struct {
+	ID        string `json:"id"`
+	Algorithm string `json:"algorithm"`
+	Type      string `json:"type"`
+	Private   bool   `json:"private"`
+	Generated bool   `json:"generated"`
+	Encrypt   bool   `json:"encrypt"`
+}
+
+// KeySet holds the keys by ID.
+type KeySet struct {
+	keys map[string]*Key
+}
+
+// NewKeySet loads the configured keys, or generates an RSA and an EC key
+// when there are none.
+func NewKeySet(configs []KeyConfig) (*KeySet, error) {
+	if len(configs) == 0 {
+		configs = []KeyConfig{
+			{ID: DefaultRSAKey, Algorithm: RS256},
+			{ID: DefaultECKey, Algorithm: ES256},
+		}
+	}
+
+	ks := &KeySet{keys: make(map[string]*Key, len(configs))}
+	for _, cfg := range configs {
+		key, err := loadKey(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", cfg.ID, err)
+		}
+		if ks.keys[key.ID] != nil {
+			return nil, fmt.Errorf("key %q: duplicate id", cfg.ID)
+		}
+		ks.keys[key.ID] = key
+		if key.generated {
+			logger.Info("Key generated", "algorithm", key.Algorithm, "kid", key.ID)
+		}
+	}
+	return ks, nil
+}
+
+func loadKey(cfg KeyConfig) (*Key, error) {
+	if cfg.ID == "" {
+		return nil, fmt.Errorf("id is required")
+	}
+	key := &Key{ID: cfg.ID, Algorithm: cfg.Algorithm}
+	if key.Algorithm == "" {
+		key.Algorithm = RS256
+	}
+
+	switch key.Algorithm {
+	case HS256:
+		if cfg.Secret == "" {
+			return nil, fmt.Errorf("HS256 needs a secret")
+		}
+		key.secret = []byte(cfg.Secret)
+		return key, nil
+	case RS256, ES256:
+	default:
+		return nil, fmt.Errorf("unknown algorithm %q (want RS256, ES256 or HS256)", key.Algorithm)
+	}
+
+	var err error
+	switch {
+	case cfg.PrivateKeyFile != "":
+		key.private, err = readPrivateKey(cfg.PrivateKeyFile)
+		if err == nil {
+			key.public = key.private.Public()
+		}
+	case cfg.PublicKeyFile != "":
+		key.public, err = readPublicKey(cfg.PublicKeyFile)
+	case key.Algorithm == RS256:
+		key.private, err = rsa.GenerateKey(rand.Reader, 2048)
+		key.generated = true
+	default:
+		key.private, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		key.generated = true
+	}
+	if err != nil {
+		return nil, err
+	}
+	if key.public == nil {
+		key.public = key.private.Public()
+	}
+
+	switch pub := key.public.(type) {
+	case *rsa.PublicKey:
+		if key.Algorithm != RS256 {
+			return nil, fmt.Errorf("RSA key used with %s", key.Algorithm)
+		}
+	case *ecdsa.PublicKey:
+		if key.Algorithm != ES256 || pub.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("ES256 needs a P-256 key")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", key.public)
+	}
+	return key, nil
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM data", path)
+	}
+	return block, nil
+}
+
+func readPrivateKey(path string) (crypto.Signer, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	signer, ok := parsed.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("%s: unsupported private key", path)
+	}
+	return signer, nil
+}
+
+func readPublicKey(path string) (crypto.PublicKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	if block.Type == "CERTIFICATE" {
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return cert.PublicKey, nil
+	}
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return key, nil
+}
+
+// Lookup returns a key by ID.
+func (ks *KeySet) Lookup(id string) (*Key, error) {
+	key, ok := ks.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown key %q", id)
+	}
+	return key, nil
+}
+
+// Keys lists the keys sorted by ID.
+func (ks *KeySet) Keys() []KeyInfo {
+	infos := make([]KeyInfo, 0, len(ks.keys))
+	for _, key := range ks.keys {
+		info := KeyInfo{
+			ID:        key.ID,
+			Algorithm: key.Algorithm,
+			Type:      key.keyType(),
+			Private:   key.private != nil || key.secret != nil,
+			Generated: key.generated,
+			Encrypt:   key.Algorithm == RS256,
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ID < infos[j].ID })
+	return infos
+}
+
+func (k *Key) keyType() string {
+	switch k.Algorithm {
+	case RS256:
+		return "RSA"
+	case ES256:
+		return "EC"
+	}
+	return "oct"
+}
+
+// PrivateKeyPEM returns the PKCS#8 private key of a generated key, so that
+// tests can decrypt payloads encrypted for it.
+func (ks *KeySet) PrivateKeyPEM(id string) ([]byte, error) {
+	key, err := ks.Lookup(id)
+	if err != nil {
+		return nil, err
+	}
+	if !key.generated {
+		return nil, fmt.Errorf("key %q was not generated by the server", id)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key.private)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// JWK is a public JSON Web Key (RFC 7517).
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use,omitempty"`
+	Algorithm string `json:"alg,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	Y         string `json:"y,omitempty"`
+}
+
+// JWKSet is the document served at /.well-known/jwks.json.
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys. RSA keys are listed twice: for signature
+// verification (RS256) and for encryption (RSA-OAEP-256), matching how
+// providers publish separate signing and encryption keys.
+func (ks *KeySet) JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	for _, info := range ks.Keys() {
+		key := ks.keys[info.ID]
+		switch pub := key.public.(type) {
+		case *rsa.PublicKey:
+			jwk := JWK{
+				KeyType: "RSA",
+				KeyID:   key.ID,
+				N:       encode(pub.N.Bytes()),
+				E:       encode(big.NewInt(int64(pub.E)).Bytes()),
+			}
+			sig, enc := jwk, jwk
+			sig.Use, sig.Algorithm = "sig", RS256
+			enc.Use, enc.Algorithm = "enc", KeyEncryption
+			set.Keys = append(set.Keys, sig, enc)
+		case *ecdsa.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				KeyType:   "EC",
+				KeyID:     key.ID,
+				Use:       "sig",
+				Algorithm: ES256,
+				Curve:     "P-256",
+				X:         encode(pub.X.FillBytes(make([]byte, 32))),
+				Y:         encode(pub.Y.FillBytes(make([]byte, 32))),
+			})
+		}
+	}
+	return set
+}
+
+// Sign returns the compact JWS of payload signed with the key.
+func (ks *KeySet) Sign(id string, payload []byte) (string, error) {
+	key, err := ks.Lookup(id)
+	if err != nil {
+		return "", err
+	}
+	if key.private == nil && key.secret == nil {
+		return "", fmt.Errorf("key %q has no private key to sign with", id)
+	}
+
+	header, err := json.Marshal(map[string]string{"alg": key.Algorithm, "kid": key.ID})
+	if err != nil {
+		return "", err
+	}
+	input := encode(header) + "." + encode(payload)
+	digest := sha256.Sum256([]byte(input))
+
+	var signature []byte
+	switch priv := key.private.(type) {
+	case *rsa.PrivateKey:
+		signature, err = rsa.SignPKCS1v15(rand.Reader, priv, crypto.SHA256, digest[:])
+	case *ecdsa.PrivateKey:
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, priv, digest[:])
+		if err == nil {
+			signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+		}
+	default:
+		mac := hmac.New(sha256.New, key.secret)
+		mac.Write([]byte(input))
+		signature = mac.Sum(nil)
+	}
+	if err != nil {
+		return "", err
+	}
+	return input + "." + encode(signature), nil
+}
+
+// Encrypt returns the compact JWE (RSA-OAEP-256, A256GCM) of payload for the
+// public key. contentType, when set, becomes the "cty" header, e.g. "JWT"
+// for a nested signed payload.
+func (ks *KeySet) Encrypt(id string, payload []byte, contentType string) (string, error) {
+	key, err := ks.Lookup(id)
+	if err != nil {
+		return "", err
+	}
+	pub, ok := key.public.(*rsa.PublicKey)
+	if !ok {
+		return "", fmt.Errorf("key %q cannot encrypt: only RSA keys are supported", id)
+	}
+
+	fields := map[string]string{"alg": KeyEncryption, "enc": ContentEncryption, "kid": key.ID}
+	if contentType != "" {
+		fields["cty"] = contentType
+	}
+	header, err := json.Marshal(fields)
+	if err != nil {
+		return "", err
+	}
+	protected := encode(header)
+
+	cek := make([]byte, 32)
+	if _, err := rand.Read(cek); err != nil {
+		return "", err
+	}
+	encryptedKey, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, pub, cek, nil)
+	if err != nil {
+		return "", err
+	}
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	iv := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(iv); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nil, iv, payload, []byte(protected))
+	ciphertext, tag := sealed[:len(sealed)-gcm.Overhead()], sealed[len(sealed)-gcm.Overhead():]
+
+	return protected + "." + encode(encryptedKey) + "." + encode(iv) + "." +
+		encode(ciphertext) + "." + encode(tag), nil
+}
+
+func encode(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
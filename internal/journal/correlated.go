@@ -0,0 +1,160 @@
+package journal
+
+import (
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Correlation summarizes the entries sharing a correlation ID: one action of
+// a test, across protocols.
+type Correlation struct {
+	CorrelationID string    `json:"correlation_id"`
+	Start         time.Time `json:"start"`
+	End           time.Time `json:"end"`
+	// DurationMs spans from the first entry to the end of the last one.
+	DurationMs float64 `json:"duration_ms"`
+	// Protocols are in the order they first appear.
+	Protocols []string       `json:"protocols"`
+	Counts    map[string]int `json:"counts"`
+	// Errors counts the HTTP responses of 400 and above and the gRPC calls
+	// that did not end with OK.
+	Errors int `json:"errors"`
+}
+
+// Correlated is the unified view of a correlation ID: the gRPC calls with
+// their metadata, the HTTP requests (callbacks and webhooks included) and
+// the bus events, each oldest first, and the timeline of all of them.
+type Correlated struct {
+	Correlation
+	// Root is the entry that started the action: the first gRPC call when
+	// there is one, the first entry otherwise.
+	Root     Entry          `json:"root"`
+	GRPC     []Entry        `json:"grpc"`
+	HTTP     []Entry        `json:"http"`
+	Events   []Entry        `json:"events"`
+	Timeline []TimelineStep `json:"timeline"`
+}
+
+// TimelineStep is an entry of a correlated view in a line: OffsetMs is its
+// start relative to the first entry and Outcome its status or code.
+type TimelineStep struct {
+	ID       string  `json:"id"`
+	Protocol string  `json:"protocol"`
+	OffsetMs float64 `json:"offset_ms"`
+	Method   string  `json:"method"`
+	Path     string  `json:"path,omitempty"`
+	Outcome  string  `json:"outcome,omitempty"`
+}
+
+// Correlations summarizes the correlation IDs of the entries matching the
+// filter (its CorrelationID and Limit aside), most recently active last.
+// With a Limit only the most recent ones are returned.
+func (j *Journal) Correlations(filter Filter) []Correlation {
+	limit := filter.Limit
+	filter.Limit, filter.CorrelationID = 0, ""
+	groups := map[string]*Correlated{}
+	// lastSeen orders the groups by their most recent entry
+	lastSeen := map[string]int{}
+	for i, entry := range j.Find(filter) {
+		if entry.CorrelationID == "" {
+			continue
+		}
+		group, ok := groups[entry.CorrelationID]
+		if !ok {
+			group = &Correlated{}
+			groups[entry.CorrelationID] = group
+		}
+		group.add(entry)
+		lastSeen[entry.CorrelationID] = i
+	}
+
+	correlations := make([]Correlation, 0, len(groups))
+	for _, group := range groups {
+		correlations = append(correlations, group.Correlation)
+	}
+	sort.Slice(correlations, func(a, b int) bool {
+		return lastSeen[correlations[a].CorrelationID] < lastSeen[correlations[b].CorrelationID]
+	})
+	if limit > 0 && len(correlations) > limit {
+		correlations = correlations[len(correlations)-limit:]
+	}
+	return correlations
+}
+
+// Correlate returns the unified view of a correlation ID, false when no
+// entry carries it.
+func (j *Journal) Correlate(correlationID string) (Correlated, bool) {
+	if correlationID == "" {
+		return Correlated{}, false
+	}
+	entries := j.Find(Filter{CorrelationID: correlationID})
+	if len(entries) == 0 {
+		return Correlated{}, false
+	}
+	// Entries are written as they end: a callback made during a gRPC call
+	// is recorded before it
+	sort.SliceStable(entries, func(a, b int) bool { return entries[a].Timestamp.Before(entries[b].Timestamp) })
+
+	view := Correlated{GRPC: []Entry{}, HTTP: []Entry{}, Events: []Entry{}, Timeline: []TimelineStep{}}
+	for _, entry := range entries {
+		view.add(entry)
+		switch entry.Protocol {
+		case ProtocolGRPC:
+			view.GRPC = append(view.GRPC, entry)
+		case ProtocolHTTP:
+			view.HTTP = append(view.HTTP, entry)
+		default:
+			view.Events = append(view.Events, entry)
+		}
+	}
+	view.Root = entries[0]
+	if len(view.GRPC) > 0 {
+		view.Root = view.GRPC[0]
+	}
+	for _, entry := range entries {
+		view.Timeline = append(view.Timeline, TimelineStep{
+			ID:       entry.ID,
+			Protocol: entry.Protocol,
+			OffsetMs: float64(entry.Timestamp.Sub(view.Start).Microseconds()) / 1000,
+			Method:   entry.Method,
+			Path:     entry.Path,
+			Outcome:  outcome(entry),
+		})
+	}
+	return view, true
+}
+
+// add accounts for an entry in the summary.
+func (c *Correlated) add(entry Entry) {
+	s := &c.Correlation
+	if s.CorrelationID == "" {
+		s.CorrelationID, s.Start, s.Counts = entry.CorrelationID, entry.Timestamp, map[string]int{}
+	}
+	if entry.Timestamp.Before(s.Start) {
+		s.Start = entry.Timestamp
+	}
+	end := entry.Timestamp.Add(time.Duration(entry.LatencyMs * float64(time.Millisecond)))
+	if end.After(s.End) {
+		s.End = end
+	}
+	s.DurationMs = float64(s.End.Sub(s.Start).Microseconds()) / 1000
+	if s.Counts[entry.Protocol] == 0 {
+		s.Protocols = append(s.Protocols, entry.Protocol)
+	}
+	s.Counts[entry.Protocol]++
+	if (entry.Protocol == ProtocolHTTP && entry.Status >= 400) || (entry.Protocol == ProtocolGRPC && entry.Code != "OK") {
+		s.Errors++
+	}
+}
+
+// outcome is the status of an HTTP entry or the code of a gRPC one.
+func outcome(entry Entry) string {
+	switch entry.Protocol {
+	case ProtocolHTTP:
+		return strconv.Itoa(entry.Status)
+	case ProtocolGRPC:
+		return entry.Code
+	}
+	return ""
+}
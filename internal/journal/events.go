@@ -0,0 +1,39 @@
+package journal
+
+import (
+	"encoding/json"
+	"time"
+
+	"mockserver/internal/events"
+)
+
+// RecordEvents subscribes to the bus and records every published event as an
+// event entry: Method is the event type, Path the topic and Peer the source.
+// Together with the correlation IDs this makes the journal a timeline of what
+// each request caused on the other protocols. The returned function stops
+// recording.
+func (j *Journal) RecordEvents(bus *events.Bus) func() {
+	ch, cancel := bus.Subscribe(events.Filter{})
+	go func() {
+		for event := range ch {
+			entry := Entry{
+				Protocol:      ProtocolEvent,
+				Timestamp:     time.Now(),
+				Method:        event.Type,
+				Path:          event.Topic,
+				Peer:          event.Source,
+				Message:       event.ID,
+				CorrelationID: event.CorrelationID,
+			}
+			if data, err := json.Marshal(event.Data); err == nil {
+				entry.RequestSize = int64(len(data))
+				if len(data) > maxRecordedBody {
+					data = data[:maxRecordedBody]
+				}
+				entry.Body = string(data)
+			}
+			j.Record(entry)
+		}
+	}()
+	return cancel
+}
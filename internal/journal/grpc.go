@@ -0,0 +1,124 @@
+package journal
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"mockserver/internal/correlation"
+)
+
+func newGRPCEntry(ctx context.Context, method string, start time.Time) Entry {
+	entry := Entry{
+		Protocol:  ProtocolGRPC,
+		Timestamp: start,
+		Method:    method,
+		Path:      method,
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		entry.Peer = p.Addr.String()
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		entry.Headers = md.Copy()
+		entry.CorrelationID = correlation.FromMetadata(md)
+	}
+	return entry
+}
+
+// unrecorded reports whether a call carries UnrecordedHeader.
+func unrecorded(ctx context.Context) bool {
+	md, _ := metadata.FromIncomingContext(ctx)
+	return len(md.Get(UnrecordedHeader)) > 0
+}
+
+func messageSize(msg interface{}) int64 {
+	if m, ok := msg.(proto.Message); ok {
+		return int64(proto.Size(m))
+	}
+	return 0
+}
+
+func (j *Journal) finishGRPC(entry Entry, err error, start time.Time) {
+	st := status.Convert(err)
+	entry.Code = st.Code().String()
+	entry.Message = st.Message()
+	entry.LatencyMs = latencyMs(start)
+	j.Record(entry)
+}
+
+// UnaryInterceptor records unary calls: peer, metadata, message sizes, status
+// and latency.
+func (j *Journal) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if unrecorded(ctx) {
+			return handler(ctx, req)
+		}
+		start := time.Now()
+		entry := newGRPCEntry(ctx, info.FullMethod, start)
+		entry.StreamType = "unary"
+		entry.RequestMsgs = 1
+		entry.RequestSize = messageSize(req)
+
+		resp, err := handler(ctx, req)
+		if err == nil {
+			entry.ResponseMsgs = 1
+			entry.ResponseSize = messageSize(resp)
+		}
+		j.finishGRPC(entry, err, start)
+		return resp, err
+	}
+}
+
+// StreamInterceptor records streaming calls, counting the messages and bytes
+// exchanged in each direction.
+func (j *Journal) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if unrecorded(ss.Context()) {
+			return handler(srv, ss)
+		}
+		start := time.Now()
+		entry := newGRPCEntry(ss.Context(), info.FullMethod, start)
+		switch {
+		case info.IsClientStream && info.IsServerStream:
+			entry.StreamType = "bidi_stream"
+		case info.IsClientStream:
+			entry.StreamType = "client_stream"
+		default:
+			entry.StreamType = "server_stream"
+		}
+
+		counting := &countingStream{ServerStream: ss, entry: &entry}
+		err := handler(srv, counting)
+		j.finishGRPC(entry, err, start)
+		return err
+	}
+}
+
+// countingStream tallies the messages passing through a server stream.
+type countingStream struct {
+	grpc.ServerStream
+	entry *Entry
+}
+
+func (s *countingStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.entry.RequestMsgs++
+		s.entry.RequestSize += messageSize(m)
+	}
+	return err
+}
+
+func (s *countingStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.entry.ResponseMsgs++
+		s.entry.ResponseSize += messageSize(m)
+	}
+	return err
+}
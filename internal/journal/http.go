@@ -0,0 +1,176 @@
+package journal
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"mockserver/internal/correlation"
+)
+
+// maxRecordedBody caps the request body kept per journal entry.
+const maxRecordedBody = 64 * 1024
+
+// stubKey is the echo context key holding the stub that answered a request.
+const stubKey = "journal.stub"
+
+// MatchedStub notes the stub answering the current request, recorded with
+// its journal entry.
+func MatchedStub(c echo.Context, id string) {
+	c.Set(stubKey, id)
+}
+
+// MatchedStubID returns the stub noted by MatchedStub, or "".
+func MatchedStubID(c echo.Context) string {
+	id, _ := c.Get(stubKey).(string)
+	return id
+}
+
+// Middleware records every HTTP request except the admin API, metrics
+// scrapes, readiness probes and requests carrying UnrecordedHeader.
+func (j *Journal) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			r := c.Request()
+			if strings.HasPrefix(r.URL.Path, "/__admin") || r.URL.Path == "/metrics" || r.URL.Path == "/readyz" || r.Header.Get(UnrecordedHeader) != "" {
+				return next(c)
+			}
+
+			start := time.Now()
+			entry := Entry{
+				Protocol:      ProtocolHTTP,
+				Timestamp:     start,
+				Method:        r.Method,
+				Path:          r.URL.Path,
+				Query:         r.URL.RawQuery,
+				Peer:          c.RealIP(),
+				Headers:       r.Header.Clone(),
+				CorrelationID: correlation.FromHeader(r.Header),
+				Proto:         r.Proto,
+			}
+
+			var body *recordingBody
+			if r.Body != nil && r.ContentLength != 0 {
+				body = &recordingBody{ReadCloser: r.Body}
+				r.Body = body
+			}
+
+			capture := &capturingWriter{ResponseWriter: c.Response().Writer}
+			c.Response().Writer = capture
+			err := next(c)
+			if err != nil {
+				// Let echo write the error response so the recorded status is final.
+				c.Error(err)
+			}
+			c.Response().Writer = capture.ResponseWriter
+
+			if body != nil {
+				entry.Body = string(body.data)
+				entry.RequestSize = body.size
+				// A body the handler left unread counts for its declared length
+				if !body.done && body.err == nil && r.ContentLength > body.size {
+					entry.RequestSize = r.ContentLength
+				}
+				if body.err != nil {
+					entry.Message = "request body: " + body.err.Error()
+				}
+			}
+			entry.Status = c.Response().Status
+			entry.StubID = MatchedStubID(c)
+			entry.ResponseHeaders = c.Response().Header().Clone()
+			entry.ResponseBody = string(capture.body)
+			entry.ResponseSize = c.Response().Size
+			entry.LatencyMs = latencyMs(start)
+			j.Record(entry)
+			return nil
+		}
+	}
+}
+
+// recordingBody passes the request body through to the handler as it reads
+// it, keeping the first maxRecordedBody bytes and counting the rest. Nothing
+// is read ahead, so the limits of the handler still apply, and read errors,
+// such as a client aborting an upload, reach the handler unchanged.
+type recordingBody struct {
+	io.ReadCloser
+	data []byte
+	size int64
+	done bool
+	err  error
+}
+
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if room := maxRecordedBody - len(b.data); room > 0 {
+		b.data = append(b.data, p[:min(n, room)]...)
+	}
+	b.size += int64(n)
+	switch {
+	case err == io.EOF:
+		b.done = true
+	case err != nil && b.err == nil:
+		b.err = err
+	}
+	return n, err
+}
+
+// capturingWriter keeps the start of the response body. Echo asserts
+// flushing and hijacking on its writer, so both are passed through.
+type capturingWriter struct {
+	http.ResponseWriter
+	body []byte
+}
+
+func (w *capturingWriter) Write(p []byte) (int, error) {
+	if room := maxRecordedBody - len(w.body); room > 0 {
+		w.body = append(w.body, p[:min(len(p), room)]...)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *capturingWriter) Flush() {
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *capturingWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
+
+func (w *capturingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// RecordOutbound records a request the server sent on behalf of a stub, such
+// as a webhook callback: Peer is the host called, and status 0 with the
+// error as Message means no response came back.
+func (j *Journal) RecordOutbound(req *http.Request, body []byte, status int, err error, start time.Time, stubID string) {
+	entry := Entry{
+		Protocol:      ProtocolHTTP,
+		Timestamp:     start,
+		Method:        req.Method,
+		Path:          req.URL.Scheme + "://" + req.URL.Host + req.URL.Path,
+		Query:         req.URL.RawQuery,
+		Peer:          req.URL.Host,
+		Headers:       req.Header.Clone(),
+		Status:        status,
+		RequestSize:   int64(len(body)),
+		LatencyMs:     latencyMs(start),
+		CorrelationID: correlation.FromHeader(req.Header),
+		StubID:        stubID,
+		Outbound:      true,
+		Proto:         req.Proto,
+	}
+	if len(body) > maxRecordedBody {
+		body = body[:maxRecordedBody]
+	}
+	entry.Body = string(body)
+	if err != nil {
+		entry.Message = err.Error()
+	}
+	j.Record(entry)
+}
@@ -0,0 +1,267 @@
+// Package journal records the requests served by the mock server, across
+// protocols, so tests can assert on what the system under test sent.
+package journal
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Protocols recorded in the journal. Event entries are the event bus traffic
+// (WebSocket messages and pushes, admin events) rather than requests.
+const (
+	ProtocolHTTP  = "http"
+	ProtocolGRPC  = "grpc"
+	ProtocolEvent = "event"
+)
+
+// UnrecordedHeader marks requests the journal leaves out, such as the
+// self-test's own traffic (also honored as gRPC metadata).
+const UnrecordedHeader = "X-Mock-Unrecorded"
+
+// DefaultCapacity is the number of entries kept before the oldest are dropped.
+const DefaultCapacity = 10000
+
+const (
+	// bufferSize bounds the entries recorded but not yet written; beyond it
+	// new entries are dropped rather than slowing requests down.
+	bufferSize = 8192
+	// flushInterval is how often the background writer drains the buffer.
+	flushInterval = 10 * time.Millisecond
+)
+
+// Entry is one recorded request. Method is the HTTP method or the full gRPC
+// method name; Status is the HTTP status code, Code the gRPC status code.
+// CorrelationID is taken from X-Mock-Test-ID or traceparent (see package
+// correlation) and lets tests follow one action across protocols.
+type Entry struct {
+	ID            string              `json:"id"`
+	Protocol      string              `json:"protocol"`
+	Timestamp     time.Time           `json:"timestamp"`
+	Method        string              `json:"method"`
+	Path          string              `json:"path,omitempty"`
+	Query         string              `json:"query,omitempty"`
+	Peer          string              `json:"peer,omitempty"`
+	Headers       map[string][]string `json:"headers,omitempty"`
+	Body          string              `json:"body,omitempty"`
+	Status        int                 `json:"status,omitempty"`
+	Code          string              `json:"code,omitempty"`
+	Message       string              `json:"message,omitempty"`
+	StreamType    string              `json:"stream_type,omitempty"`
+	RequestMsgs   int                 `json:"request_messages,omitempty"`
+	ResponseMsgs  int                 `json:"response_messages,omitempty"`
+	RequestSize   int64               `json:"request_size"`
+	ResponseSize  int64               `json:"response_size"`
+	LatencyMs     float64             `json:"latency_ms"`
+	CorrelationID string              `json:"correlation_id,omitempty"`
+	// StubID is the HTTP stub that answered the request, if any.
+	StubID string `json:"stub_id,omitempty"`
+	// ResponseHeaders and ResponseBody, its first 64 KiB, are what an HTTP
+	// request was answered with.
+	ResponseHeaders map[string][]string `json:"response_headers,omitempty"`
+	ResponseBody    string              `json:"response_body,omitempty"`
+	// Outbound entries are requests the server sent, such as stub callbacks.
+	Outbound bool `json:"outbound,omitempty"`
+	// Proto is the HTTP version of an HTTP request, such as HTTP/1.1.
+	Proto string `json:"proto,omitempty"`
+}
+
+// Filter selects journal entries. Zero values match everything; Path is a
+// prefix and Method is case-insensitive.
+type Filter struct {
+	Protocol string
+	Method   string
+	Path     string
+	Status   int
+	Code     string
+	Since    time.Time
+	Until    time.Time
+	Limit    int
+	// CorrelationID selects the entries of a single correlated action.
+	CorrelationID string
+	// StubID selects the requests answered by a stub.
+	StubID string
+}
+
+func (f Filter) matches(entry *Entry) bool {
+	if f.Protocol != "" && entry.Protocol != f.Protocol {
+		return false
+	}
+	if f.Method != "" && !strings.EqualFold(entry.Method, f.Method) {
+		return false
+	}
+	if f.Path != "" && !strings.HasPrefix(entry.Path, f.Path) {
+		return false
+	}
+	if f.Status != 0 && entry.Status != f.Status {
+		return false
+	}
+	if f.Code != "" && !strings.EqualFold(entry.Code, f.Code) {
+		return false
+	}
+	if f.CorrelationID != "" && entry.CorrelationID != f.CorrelationID {
+		return false
+	}
+	if f.StubID != "" && entry.StubID != f.StubID {
+		return false
+	}
+	if !f.Since.IsZero() && entry.Timestamp.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !entry.Timestamp.Before(f.Until) {
+		return false
+	}
+	return true
+}
+
+// Journal keeps the most recent entries in memory. Requests only push onto a
+// lock-free ring buffer; a background writer moves entries into the journal,
+// so recording stays off the request hot path.
+type Journal struct {
+	buffer   *ring
+	dropped  atomic.Uint64
+	entries  []Entry
+	capacity int
+	nextID   int64
+	mutex    sync.RWMutex
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// Stats describes the journal and its write buffer.
+type Stats struct {
+	Entries  int    `json:"entries"`
+	Capacity int    `json:"capacity"`
+	Pending  int    `json:"pending"`
+	Dropped  uint64 `json:"dropped"`
+}
+
+func New(capacity int) *Journal {
+	if capacity <= 0 {
+		capacity = DefaultCapacity
+	}
+	j := &Journal{
+		buffer:   newRing(bufferSize),
+		capacity: capacity,
+		done:     make(chan struct{}),
+	}
+	go j.writer()
+	return j
+}
+
+// Record queues an entry; it never blocks and drops the entry when the
+// buffer is saturated. It is safe to call on a nil journal, which records
+// nothing.
+func (j *Journal) Record(entry Entry) {
+	if j == nil {
+		return
+	}
+	if !j.buffer.push(entry) {
+		j.dropped.Add(1)
+	}
+}
+
+// Close stops the background writer after writing pending entries.
+func (j *Journal) Close() {
+	j.stopOnce.Do(func() { close(j.done) })
+	j.drain()
+}
+
+func (j *Journal) writer() {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			j.drain()
+		case <-j.done:
+			return
+		}
+	}
+}
+
+// drain moves buffered entries into the journal, assigning their IDs. The
+// write lock is held throughout so entries keep their recording order.
+func (j *Journal) drain() {
+	if j.buffer.len() == 0 {
+		return
+	}
+
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	for {
+		entry, ok := j.buffer.pop()
+		if !ok {
+			break
+		}
+		j.nextID++
+		entry.ID = fmt.Sprintf("req-%d", j.nextID)
+		j.entries = append(j.entries, entry)
+	}
+	if len(j.entries) > j.capacity {
+		j.entries = j.entries[len(j.entries)-j.capacity:]
+	}
+}
+
+// Stats returns the entry count and the write buffer counters.
+func (j *Journal) Stats() Stats {
+	j.mutex.RLock()
+	defer j.mutex.RUnlock()
+	return Stats{
+		Entries:  len(j.entries),
+		Capacity: j.capacity,
+		Pending:  j.buffer.len(),
+		Dropped:  j.dropped.Load(),
+	}
+}
+
+// Find returns the entries matching the filter, oldest first. With a Limit
+// only the most recent matches are returned.
+func (j *Journal) Find(filter Filter) []Entry {
+	j.drain()
+	j.mutex.RLock()
+	defer j.mutex.RUnlock()
+
+	matched := []Entry{}
+	for i := range j.entries {
+		if filter.matches(&j.entries[i]) {
+			matched = append(matched, j.entries[i])
+		}
+	}
+	if filter.Limit > 0 && len(matched) > filter.Limit {
+		matched = matched[len(matched)-filter.Limit:]
+	}
+	return matched
+}
+
+// Get returns a single entry by ID.
+func (j *Journal) Get(id string) (Entry, bool) {
+	j.drain()
+	j.mutex.RLock()
+	defer j.mutex.RUnlock()
+
+	for i := range j.entries {
+		if j.entries[i].ID == id {
+			return j.entries[i], true
+		}
+	}
+	return Entry{}, false
+}
+
+// Reset removes every entry, including those not yet written, and clears
+// the drop counter.
+func (j *Journal) Reset() {
+	j.drain()
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	j.entries = nil
+	j.dropped.Store(0)
+}
+
+func latencyMs(start time.Time) float64 {
+	return float64(time.Since(start).Microseconds()) / 1000
+}
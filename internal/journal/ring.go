@@ -0,0 +1,81 @@
+package journal
+
+import "sync/atomic"
+
+// ring is a bounded lock-free queue (Vyukov's MPMC design): producers and the
+// consumer only coordinate through per-slot sequence numbers, so recording a
+// request never blocks on a mutex.
+type ring struct {
+	mask  uint64
+	slots []ringSlot
+	head  atomic.Uint64
+	tail  atomic.Uint64
+}
+
+type ringSlot struct {
+	seq   atomic.Uint64
+	entry Entry
+}
+
+// newRing creates a ring holding size entries, rounded up to a power of two.
+func newRing(size int) *ring {
+	n := 1
+	for n < size {
+		n <<= 1
+	}
+	r := &ring{mask: uint64(n - 1), slots: make([]ringSlot, n)}
+	for i := range r.slots {
+		r.slots[i].seq.Store(uint64(i))
+	}
+	return r
+}
+
+// push enqueues an entry, reporting false when the ring is full.
+func (r *ring) push(entry Entry) bool {
+	pos := r.head.Load()
+	for {
+		slot := &r.slots[pos&r.mask]
+		seq := slot.seq.Load()
+		switch diff := int64(seq) - int64(pos); {
+		case diff == 0:
+			if r.head.CompareAndSwap(pos, pos+1) {
+				slot.entry = entry
+				slot.seq.Store(pos + 1)
+				return true
+			}
+			pos = r.head.Load()
+		case diff < 0:
+			return false
+		default:
+			pos = r.head.Load()
+		}
+	}
+}
+
+// pop dequeues the oldest entry, reporting false when the ring is empty.
+func (r *ring) pop() (Entry, bool) {
+	pos := r.tail.Load()
+	for {
+		slot := &r.slots[pos&r.mask]
+		seq := slot.seq.Load()
+		switch diff := int64(seq) - int64(pos+1); {
+		case diff == 0:
+			if r.tail.CompareAndSwap(pos, pos+1) {
+				entry := slot.entry
+				slot.entry = Entry{}
+				slot.seq.Store(pos + r.mask + 1)
+				return entry, true
+			}
+			pos = r.tail.Load()
+		case diff < 0:
+			return Entry{}, false
+		default:
+			pos = r.tail.Load()
+		}
+	}
+}
+
+// len approximates the number of queued entries.
+func (r *ring) len() int {
+	return int(r.head.Load() - r.tail.Load())
+}
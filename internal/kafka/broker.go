@@ -0,0 +1,384 @@
+// Package kafka is a smoke-level Kafka broker: a single node that answers
+// metadata, produce and fetch requests for topics kept in memory, and
+// coordinates consumer groups, so that event-driven services can be tested
+// without a cluster. Records produced by clients and consumer groups can be
+// inspected, and records injected, through the admin API.
+package kafka
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"mockserver/internal/logging"
+	"mockserver/internal/panics"
+)
+
+var logger = logging.For("kafka")
+
+// Config holds the Kafka settings from the configuration file.
+type Config struct {
+	Topics []TopicConfig `json:"topics,omitempty"`
+	// AutoCreateTopics creates unknown topics, with one partition, when a
+	// client asks for them.
+	AutoCreateTopics bool `json:"auto_create_topics,omitempty"`
+	// AdvertisedHost is the broker host returned in metadata (default
+	// "localhost"); the port is the one of the listener.
+	AdvertisedHost string `json:"advertised_host,omitempty"`
+}
+
+// TopicConfig declares a topic; Partitions defaults to 1.
+type TopicConfig struct {
+	Name       string `json:"name"`
+	Partitions int    `json:"partitions,omitempty"`
+}
+
+// Record sources.
+const (
+	SourceProducer = "producer"
+	SourceAdmin    = "admin"
+)
+
+// Record is a decoded record of a topic.
+type Record struct {
+	Topic     string            `json:"topic"`
+	Partition int32             `json:"partition"`
+	Offset    int64             `json:"offset"`
+	Timestamp time.Time         `json:"timestamp"`
+	Key       *string           `json:"key"`
+	Value     *string           `json:"value"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	// Source tells records produced by clients from injected ones.
+	Source string `json:"source"`
+}
+
+// InjectedRecord is a record added through the admin API. A JSON string
+// value is used as is, other JSON values are stored as their encoding and
+// a missing value makes a tombstone.
+type InjectedRecord struct {
+	Partition int32             `json:"partition"`
+	Key       *string           `json:"key,omitempty"`
+	Value     json.RawMessage   `json:"value,omitempty"`
+	Headers   map[string]string `json:"headers,omitempty"`
+}
+
+// TopicInfo describes a topic and the next offset of each partition.
+type TopicInfo struct {
+	Name           string  `json:"name"`
+	Partitions     int     `json:"partitions"`
+	HighWatermarks []int64 `json:"high_watermarks"`
+}
+
+// RecordFilter selects the records returned by Broker.Records; zero
+// values match everything.
+type RecordFilter struct {
+	Partition *int32
+	Key       *string
+	Source    string
+}
+
+// ErrUnknownTopic is returned for topics that do not exist.
+var ErrUnknownTopic = errors.New("unknown topic")
+
+const nodeID = 1
+
+// Broker is the Kafka listener and its in-memory logs.
+type Broker struct {
+	host       string
+	autoCreate bool
+	port       int32
+
+	topics         map[string][]*partitionLog
+	nextProducerID int64
+	// changed is closed and replaced whenever records are appended, which
+	// wakes up the fetches waiting for data.
+	changed chan struct{}
+	mutex   sync.Mutex
+
+	// groups are the consumer groups, guarded by groupMutex so that joins
+	// waiting for a rebalance do not hold up produce and fetch requests.
+	groups       map[string]*group
+	nextMemberID int
+	groupMutex   sync.Mutex
+
+	panics *panics.Recorder
+}
+
+type partitionLog struct {
+	batches []storedBatch
+	// next is the offset of the next record, the high watermark.
+	next int64
+}
+
+type storedBatch struct {
+	baseOffset   int64
+	lastOffset   int64
+	maxTimestamp int64
+	source       string
+	data         []byte
+}
+
+// NewBroker creates the configured topics.
+func NewBroker(cfg Config) (*Broker, error) {
+	b := &Broker{
+		host:       cfg.AdvertisedHost,
+		autoCreate: cfg.AutoCreateTopics,
+		topics:     make(map[string][]*partitionLog),
+		changed:    make(chan struct{}),
+		groups:     make(map[string]*group),
+	}
+	if b.host == "" {
+		b.host = "localhost"
+	}
+	for _, topic := range cfg.Topics {
+		if err := validTopicName(topic.Name); err != nil {
+			return nil, err
+		}
+		if _, exists := b.topics[topic.Name]; exists {
+			return nil, fmt.Errorf("duplicate topic %q", topic.Name)
+		}
+		partitions := topic.Partitions
+		if partitions == 0 {
+			partitions = 1
+		}
+		if partitions < 0 {
+			return nil, fmt.Errorf("topic %q: partitions must be positive", topic.Name)
+		}
+		b.createTopic(topic.Name, partitions)
+	}
+	return b, nil
+}
+
+func validTopicName(name string) error {
+	if name == "" || len(name) > 249 {
+		return fmt.Errorf("invalid topic name %q", name)
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '_' || r == '-') {
+			return fmt.Errorf("invalid topic name %q", name)
+		}
+	}
+	return nil
+}
+
+func (b *Broker) createTopic(name string, partitions int) []*partitionLog {
+	logs := make([]*partitionLog, partitions)
+	for i := range logs {
+		logs[i] = &partitionLog{}
+	}
+	b.topics[name] = logs
+	return logs
+}
+
+// topic returns the partitions of a topic, creating it when allowed. The
+// caller holds the mutex.
+func (b *Broker) topic(name string, create bool) []*partitionLog {
+	if logs, ok := b.topics[name]; ok {
+		return logs
+	}
+	if !create || !b.autoCreate || validTopicName(name) != nil {
+		return nil
+	}
+	logger.Info("Topic auto-created", "topic", name)
+	return b.createTopic(name, 1)
+}
+
+func (b *Broker) partition(topic string, partition int32, create bool) *partitionLog {
+	logs := b.topic(topic, create)
+	if partition < 0 || int(partition) >= len(logs) {
+		return nil
+	}
+	return logs[partition]
+}
+
+// append stores a batch and assigns its offsets. The caller holds the
+// mutex and notifies the waiting fetches.
+func (l *partitionLog) append(batch []byte, source string) int64 {
+	data := append([]byte(nil), batch...)
+	header := parseBatchHeader(data)
+	header.setBaseOffset(l.next)
+	base := l.next
+	l.next += int64(header.lastOffsetDelta()) + 1
+	l.batches = append(l.batches, storedBatch{
+		baseOffset:   base,
+		lastOffset:   l.next - 1,
+		maxTimestamp: header.maxTimestamp(),
+		source:       source,
+		data:         data,
+	})
+	return base
+}
+
+func (b *Broker) notify() {
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
+
+// Topics lists the topics with their high watermarks.
+func (b *Broker) Topics() []TopicInfo {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	topics := make([]TopicInfo, 0, len(b.topics))
+	for name, logs := range b.topics {
+		info := TopicInfo{Name: name, Partitions: len(logs), HighWatermarks: make([]int64, len(logs))}
+		for i, l := range logs {
+			info.HighWatermarks[i] = l.next
+		}
+		topics = append(topics, info)
+	}
+	sort.Slice(topics, func(i, j int) bool { return topics[i].Name < topics[j].Name })
+	return topics
+}
+
+// Records decodes the records of a topic that match the filter, in
+// partition and offset order.
+func (b *Broker) Records(topic string, filter RecordFilter) ([]Record, error) {
+	b.mutex.Lock()
+	logs, ok := b.topics[topic]
+	if !ok {
+		b.mutex.Unlock()
+		return nil, ErrUnknownTopic
+	}
+	// Batches are never modified once stored, copying the slices is enough
+	partitions := make([][]storedBatch, len(logs))
+	for i, l := range logs {
+		partitions[i] = l.batches
+	}
+	b.mutex.Unlock()
+
+	records := []Record{}
+	for partition, batches := range partitions {
+		if filter.Partition != nil && *filter.Partition != int32(partition) {
+			continue
+		}
+		for _, batch := range batches {
+			if filter.Source != "" && filter.Source != batch.source {
+				continue
+			}
+			decoded, err := decodeBatch(batch.data)
+			if err != nil {
+				logger.Warn("Cannot decode batch", "topic", topic, "partition", partition, "offset", batch.baseOffset, "error", err)
+				continue
+			}
+			for _, record := range decoded {
+				if filter.Key != nil && (record.Key == nil || *record.Key != *filter.Key) {
+					continue
+				}
+				record.Topic = topic
+				record.Partition = int32(partition)
+				record.Source = batch.source
+				records = append(records, record)
+			}
+		}
+	}
+	return records, nil
+}
+
+// Inject appends records to a topic, one batch per partition, and returns
+// them with their offsets.
+func (b *Broker) Inject(topic string, injected []InjectedRecord) ([]Record, error) {
+	if len(injected) == 0 {
+		return nil, fmt.Errorf("no records to inject")
+	}
+	// Record timestamps have millisecond precision
+	now := time.Now().Truncate(time.Millisecond)
+	batches := make(map[int32][]Record)
+	var order []int32
+	for i, r := range injected {
+		record := Record{
+			Topic:     topic,
+			Partition: r.Partition,
+			Timestamp: now,
+			Key:       r.Key,
+			Headers:   r.Headers,
+			Source:    SourceAdmin,
+		}
+		if len(r.Value) > 0 && string(r.Value) != "null" {
+			var text string
+			if err := json.Unmarshal(r.Value, &text); err != nil {
+				if !json.Valid(r.Value) {
+					return nil, fmt.Errorf("record %d: invalid value", i)
+				}
+				text = string(r.Value)
+			}
+			record.Value = &text
+		}
+		if _, ok := batches[r.Partition]; !ok {
+			order = append(order, r.Partition)
+		}
+		batches[r.Partition] = append(batches[r.Partition], record)
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	logs := b.topic(topic, true)
+	if logs == nil {
+		return nil, ErrUnknownTopic
+	}
+	for _, partition := range order {
+		if partition < 0 || int(partition) >= len(logs) {
+			return nil, fmt.Errorf("topic %s has no partition %d", topic, partition)
+		}
+	}
+
+	var records []Record
+	for _, partition := range order {
+		base := logs[partition].append(encodeBatch(batches[partition]), SourceAdmin)
+		for i, record := range batches[partition] {
+			record.Offset = base + int64(i)
+			records = append(records, record)
+		}
+	}
+	b.notify()
+	logger.Info("Records injected", "topic", topic, "records", len(records))
+	return records, nil
+}
+
+// ResetRecords empties every partition and forgets the offsets committed
+// by consumer groups; topics and group members are kept.
+func (b *Broker) ResetRecords() {
+	b.mutex.Lock()
+	for _, logs := range b.topics {
+		for _, l := range logs {
+			*l = partitionLog{}
+		}
+	}
+	b.notify()
+	b.mutex.Unlock()
+
+	b.groupMutex.Lock()
+	for _, g := range b.groups {
+		g.offsets = make(map[topicPartition]committed)
+	}
+	b.groupMutex.Unlock()
+	logger.Info("All records and committed offsets removed")
+}
+
+// SetPanics keeps the panics contained on the connections in recorder; it
+// is called before Serve.
+func (b *Broker) SetPanics(recorder *panics.Recorder) {
+	b.panics = recorder
+}
+
+// Serve accepts Kafka connections until the listener is closed.
+func (b *Broker) Serve(lis net.Listener) error {
+	if addr, ok := lis.Addr().(*net.TCPAddr); ok {
+		b.mutex.Lock()
+		b.port = int32(addr.Port)
+		b.mutex.Unlock()
+	}
+	for {
+		conn, err := lis.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go b.handleConn(conn)
+	}
+}
@@ -0,0 +1,361 @@
+package kafka
+
+import (
+	"time"
+)
+
+// The requests below make the broker the coordinator of every consumer
+// group, so that consumers subscribing to topics can join groups and
+// commit their offsets.
+
+// coordinatorGroup is the FindCoordinator key type of consumer groups; the
+// other one, of transactions, is not supported.
+const coordinatorGroup = 0
+
+func (b *Broker) findCoordinator(version int16, d *decoder, e *encoder) {
+	d.string() // key
+	keyType := int8(coordinatorGroup)
+	if version >= 1 {
+		keyType = d.int8()
+	}
+	b.mutex.Lock()
+	port := b.port
+	b.mutex.Unlock()
+
+	code := int16(codeNone)
+	if keyType != coordinatorGroup {
+		code = codeCoordinatorNotAvailable
+	}
+	if version >= 1 {
+		e.int32(0) // throttle time
+	}
+	e.int16(code)
+	if version >= 1 {
+		e.nullableString(nil) // error message
+	}
+	e.int32(nodeID)
+	e.string(b.host)
+	e.int32(port)
+}
+
+func (b *Broker) joinGroupRequest(version int16, clientID, clientHost string, d *decoder, e *encoder) {
+	req := joinRequest{clientID: clientID, clientHost: clientHost}
+	req.groupID = d.string()
+	req.sessionTimeout = time.Duration(d.int32()) * time.Millisecond
+	req.rebalanceTimeout = req.sessionTimeout
+	if version >= 1 {
+		req.rebalanceTimeout = time.Duration(d.int32()) * time.Millisecond
+	}
+	req.memberID = d.string()
+	if version >= 5 {
+		req.instanceID = d.nullableString()
+	}
+	req.protocolType = d.string()
+	for i := d.arrayLen(); i > 0; i-- {
+		req.protocols = append(req.protocols, memberProtocol{name: d.string(), metadata: d.bytes()})
+	}
+	if d.err != nil {
+		return
+	}
+
+	result := b.joinGroup(req)
+	if version >= 2 {
+		e.int32(0) // throttle time
+	}
+	e.int16(result.code)
+	e.int32(result.generation)
+	e.string(result.protocol)
+	e.string(result.leader)
+	e.string(result.memberID)
+	e.arrayLen(len(result.members))
+	for _, m := range result.members {
+		e.string(m.id)
+		if version >= 5 {
+			e.nullableString(optionalString(m.instanceID))
+		}
+		e.bytes(m.metadata)
+	}
+}
+
+func (b *Broker) syncGroupRequest(version int16, d *decoder, e *encoder) {
+	groupID := d.string()
+	generation := d.int32()
+	memberID := d.string()
+	if version >= 3 {
+		d.nullableString() // group instance ID
+	}
+	assignments := make(map[string][]byte)
+	for i := d.arrayLen(); i > 0; i-- {
+		assignments[d.string()] = append([]byte{}, d.bytes()...)
+	}
+	if d.err != nil {
+		return
+	}
+
+	assignment, code := b.syncGroup(groupID, generation, memberID, assignments)
+	if version >= 1 {
+		e.int32(0) // throttle time
+	}
+	e.int16(code)
+	if assignment == nil {
+		assignment = []byte{}
+	}
+	e.bytes(assignment)
+}
+
+func (b *Broker) heartbeatRequest(version int16, d *decoder, e *encoder) {
+	groupID := d.string()
+	generation := d.int32()
+	memberID := d.string()
+	if version >= 3 {
+		d.nullableString() // group instance ID
+	}
+	if d.err != nil {
+		return
+	}
+	code := b.heartbeat(groupID, generation, memberID)
+	if version >= 1 {
+		e.int32(0) // throttle time
+	}
+	e.int16(code)
+}
+
+func (b *Broker) leaveGroupRequest(version int16, d *decoder, e *encoder) {
+	groupID := d.string()
+	if version < 3 {
+		memberID := d.string()
+		if d.err != nil {
+			return
+		}
+		code := b.leaveGroup(groupID, memberID)
+		if version >= 1 {
+			e.int32(0) // throttle time
+		}
+		e.int16(code)
+		return
+	}
+
+	type leaving struct {
+		memberID, instanceID string
+		code                 int16
+	}
+	var members []leaving
+	for i := d.arrayLen(); i > 0; i-- {
+		members = append(members, leaving{memberID: d.string(), instanceID: d.nullableString()})
+	}
+	if d.err != nil {
+		return
+	}
+	for i := range members {
+		members[i].code = b.leaveGroup(groupID, members[i].memberID)
+	}
+	e.int32(0) // throttle time
+	e.int16(codeNone)
+	e.arrayLen(len(members))
+	for _, m := range members {
+		e.string(m.memberID)
+		e.nullableString(optionalString(m.instanceID))
+		e.int16(m.code)
+	}
+}
+
+func (b *Broker) offsetCommit(version int16, d *decoder, e *encoder) {
+	groupID := d.string()
+	generation := d.int32()
+	memberID := d.string()
+	if version >= 7 {
+		d.nullableString() // group instance ID
+	}
+	if version <= 4 {
+		d.int64() // retention time
+	}
+
+	type commitTopic struct {
+		name       string
+		partitions []int32
+		codes      []int16
+	}
+	var topics []commitTopic
+	offsets := make(map[topicPartition]committed)
+	for i := d.arrayLen(); i > 0; i-- {
+		topic := commitTopic{name: d.string()}
+		for j := d.arrayLen(); j > 0; j-- {
+			partition := d.int32()
+			offset := d.int64()
+			if version >= 6 {
+				d.int32() // committed leader epoch
+			}
+			metadata := d.nullableString()
+			code := int16(codeNone)
+			if !b.partitionExists(topic.name, partition) {
+				code = codeUnknownTopicOrPartition
+			} else {
+				offsets[topicPartition{topic.name, partition}] = committed{offset: offset, metadata: metadata}
+			}
+			topic.partitions = append(topic.partitions, partition)
+			topic.codes = append(topic.codes, code)
+		}
+		topics = append(topics, topic)
+	}
+	if d.err != nil {
+		return
+	}
+
+	code := b.commitOffsets(groupID, generation, memberID, offsets)
+	if version >= 3 {
+		e.int32(0) // throttle time
+	}
+	e.arrayLen(len(topics))
+	for _, topic := range topics {
+		e.string(topic.name)
+		e.arrayLen(len(topic.partitions))
+		for j, partition := range topic.partitions {
+			e.int32(partition)
+			if topic.codes[j] == codeNone {
+				e.int16(code)
+			} else {
+				e.int16(topic.codes[j])
+			}
+		}
+	}
+}
+
+func (b *Broker) offsetFetch(version int16, d *decoder, e *encoder) {
+	groupID := d.string()
+	type fetchTopic struct {
+		name       string
+		partitions []int32
+	}
+	var topics []fetchTopic
+	count := d.arrayLen()
+	for i := 0; i < count; i++ {
+		topic := fetchTopic{name: d.string()}
+		for j := d.arrayLen(); j > 0; j-- {
+			topic.partitions = append(topic.partitions, d.int32())
+		}
+		topics = append(topics, topic)
+	}
+	if d.err != nil {
+		return
+	}
+
+	if version >= 3 {
+		e.int32(0) // throttle time
+	}
+	offsets := b.committedOffsets(groupID)
+	if count < 0 {
+		// A null topic list asks for every committed offset
+		byTopic := make(map[string]int)
+		for tp := range offsets {
+			i, ok := byTopic[tp.topic]
+			if !ok {
+				i = len(topics)
+				byTopic[tp.topic] = i
+				topics = append(topics, fetchTopic{name: tp.topic})
+			}
+			topics[i].partitions = append(topics[i].partitions, tp.partition)
+		}
+	}
+	e.arrayLen(len(topics))
+	for _, topic := range topics {
+		e.string(topic.name)
+		e.arrayLen(len(topic.partitions))
+		for _, partition := range topic.partitions {
+			offset, ok := offsets[topicPartition{topic.name, partition}]
+			if !ok {
+				offset.offset = -1
+			}
+			e.int32(partition)
+			e.int64(offset.offset)
+			if version >= 5 {
+				e.int32(-1) // committed leader epoch
+			}
+			e.nullableString(&offset.metadata)
+			e.int16(codeNone)
+		}
+	}
+	if version >= 2 {
+		e.int16(codeNone)
+	}
+}
+
+func (b *Broker) describeGroups(version int16, d *decoder, e *encoder) {
+	var ids []string
+	for i := d.arrayLen(); i > 0; i-- {
+		ids = append(ids, d.string())
+	}
+	if version >= 3 {
+		d.bool() // include authorized operations
+	}
+	if d.err != nil {
+		return
+	}
+
+	if version >= 1 {
+		e.int32(0) // throttle time
+	}
+	e.arrayLen(len(ids))
+	b.groupMutex.Lock()
+	defer b.groupMutex.Unlock()
+	now := time.Now()
+	for _, id := range ids {
+		g := b.group(id, false)
+		e.int16(codeNone)
+		e.string(id)
+		if g == nil {
+			e.string(groupDead)
+			e.string("") // protocol type
+			e.string("") // protocol
+			e.arrayLen(0)
+		} else {
+			g.expire(now)
+			e.string(g.state)
+			e.string(g.protocolType)
+			e.string(g.protocol)
+			e.arrayLen(len(g.members))
+			for _, m := range g.members {
+				e.string(m.id)
+				if version >= 4 {
+					e.nullableString(optionalString(m.instanceID))
+				}
+				e.string(m.clientID)
+				e.string(m.clientHost)
+				e.bytes(m.protocol(g.protocol))
+				e.bytes(append([]byte{}, m.assignment...))
+			}
+		}
+		if version >= 3 {
+			e.int32(noAuthorizedOperations)
+		}
+	}
+}
+
+func (b *Broker) listGroups(version int16, e *encoder) {
+	if version >= 1 {
+		e.int32(0) // throttle time
+	}
+	e.int16(codeNone)
+	b.groupMutex.Lock()
+	defer b.groupMutex.Unlock()
+	e.arrayLen(len(b.groups))
+	for _, g := range b.groups {
+		e.string(g.id)
+		e.string(g.protocolType)
+	}
+}
+
+// partitionExists reports whether a topic has a partition, without
+// creating it.
+func (b *Broker) partitionExists(topic string, partition int32) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.partition(topic, partition, false) != nil
+}
+
+// optionalString maps "" to a null string.
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
@@ -0,0 +1,567 @@
+package kafka
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Group states, as reported by DescribeGroups.
+const (
+	groupEmpty               = "Empty"
+	groupPreparingRebalance  = "PreparingRebalance"
+	groupCompletingRebalance = "CompletingRebalance"
+	groupStable              = "Stable"
+	groupDead                = "Dead"
+)
+
+// GroupInfo describes a consumer group: its members with their assigned
+// partitions and the offsets it committed.
+type GroupInfo struct {
+	GroupID      string            `json:"group_id"`
+	State        string            `json:"state"`
+	ProtocolType string            `json:"protocol_type,omitempty"`
+	Protocol     string            `json:"protocol,omitempty"`
+	Generation   int32             `json:"generation"`
+	Leader       string            `json:"leader,omitempty"`
+	Members      []MemberInfo      `json:"members"`
+	Offsets      []CommittedOffset `json:"offsets"`
+}
+
+// MemberInfo describes a member of a group. Assignment lists the assigned
+// partitions by topic when the group uses the consumer protocol.
+type MemberInfo struct {
+	MemberID   string             `json:"member_id"`
+	InstanceID string             `json:"group_instance_id,omitempty"`
+	ClientID   string             `json:"client_id"`
+	ClientHost string             `json:"client_host"`
+	Assignment map[string][]int32 `json:"assignment,omitempty"`
+}
+
+// CommittedOffset is the offset a group committed for a partition; Lag is
+// the number of records after it.
+type CommittedOffset struct {
+	Topic     string `json:"topic"`
+	Partition int32  `json:"partition"`
+	Offset    int64  `json:"offset"`
+	Metadata  string `json:"metadata,omitempty"`
+	Lag       int64  `json:"lag"`
+}
+
+// group is a consumer group coordinated by the broker. Rebalances follow
+// the classic protocol: every member rejoins, the leader assigns the
+// partitions and the others receive their share through SyncGroup.
+type group struct {
+	id           string
+	state        string
+	protocolType string
+	protocol     string
+	generation   int32
+	leader       string
+	members      map[string]*member
+	// deadline ends the rebalance in progress; members that did not rejoin
+	// by then are removed.
+	deadline time.Time
+	// changed is closed and replaced whenever the state changes, which
+	// wakes up the joins and syncs waiting for the rebalance.
+	changed chan struct{}
+	offsets map[topicPartition]committed
+}
+
+type member struct {
+	id, instanceID       string
+	clientID, clientHost string
+	since, lastSeen      time.Time
+	sessionTimeout       time.Duration
+	rebalanceTimeout     time.Duration
+	protocols            []memberProtocol
+	// joined is set once the member rejoined the rebalance in progress.
+	joined     bool
+	assignment []byte
+}
+
+type memberProtocol struct {
+	name     string
+	metadata []byte
+}
+
+type topicPartition struct {
+	topic     string
+	partition int32
+}
+
+type committed struct {
+	offset   int64
+	metadata string
+}
+
+// group returns a group, creating it when asked. The caller holds the group
+// mutex.
+func (b *Broker) group(id string, create bool) *group {
+	if g, ok := b.groups[id]; ok {
+		return g
+	}
+	if !create {
+		return nil
+	}
+	g := &group{
+		id:      id,
+		state:   groupEmpty,
+		members: make(map[string]*member),
+		changed: make(chan struct{}),
+		offsets: make(map[topicPartition]committed),
+	}
+	b.groups[id] = g
+	return g
+}
+
+func (g *group) notify() {
+	close(g.changed)
+	g.changed = make(chan struct{})
+}
+
+// supports reports whether every other member speaks one of the protocols.
+func (g *group) supports(memberID string, protocols []memberProtocol) bool {
+	for _, p := range protocols {
+		shared := true
+		for _, m := range g.members {
+			if m.id != memberID && m.protocol(p.name) == nil {
+				shared = false
+				break
+			}
+		}
+		if shared {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *member) protocol(name string) []byte {
+	for _, p := range m.protocols {
+		if p.name == name {
+			return append([]byte{}, p.metadata...)
+		}
+	}
+	return nil
+}
+
+// prepareRebalance asks every member but the one joining to rejoin.
+func (g *group) prepareRebalance(now time.Time, joining string) {
+	g.state = groupPreparingRebalance
+	timeout := time.Duration(0)
+	for _, m := range g.members {
+		m.joined = m.id == joining
+		timeout = max(timeout, m.rebalanceTimeout)
+	}
+	g.deadline = now.Add(timeout)
+	g.notify()
+}
+
+// completeRebalance removes the members that did not rejoin, starts a new
+// generation and picks its leader and protocol.
+func (g *group) completeRebalance() {
+	for id, m := range g.members {
+		if !m.joined {
+			logger.Debug("Member left group", "member_id", id, "group", g.id, "reason", "rebalance timeout")
+			delete(g.members, id)
+		}
+	}
+	g.generation++
+	for _, m := range g.members {
+		m.assignment = nil
+	}
+	if len(g.members) == 0 {
+		g.state, g.leader, g.protocol = groupEmpty, "", ""
+		g.notify()
+		return
+	}
+	if g.members[g.leader] == nil {
+		g.leader = ""
+		for _, m := range g.members {
+			if g.leader == "" || m.since.Before(g.members[g.leader].since) {
+				g.leader = m.id
+			}
+		}
+	}
+	// The leader's preferred protocol among those every member speaks
+	for _, p := range g.members[g.leader].protocols {
+		if g.supports("", []memberProtocol{p}) {
+			g.protocol = p.name
+			break
+		}
+	}
+	g.state = groupCompletingRebalance
+	g.notify()
+	logger.Debug("Group rebalanced", "group", g.id, "generation", g.generation, "members", len(g.members))
+}
+
+// expire removes the members whose session timed out and completes a
+// rebalance past its deadline.
+func (g *group) expire(now time.Time) {
+	if g.state == groupPreparingRebalance {
+		if !now.Before(g.deadline) {
+			g.completeRebalance()
+		}
+		return
+	}
+	expired := false
+	for id, m := range g.members {
+		if now.Sub(m.lastSeen) > m.sessionTimeout {
+			logger.Debug("Member left group", "member_id", id, "group", g.id, "reason", "session timeout")
+			delete(g.members, id)
+			expired = true
+		}
+	}
+	if expired {
+		g.membersChanged(now)
+	}
+}
+
+// membersChanged rebalances the remaining members after some left, or
+// empties the group.
+func (g *group) membersChanged(now time.Time) {
+	if len(g.members) > 0 {
+		g.prepareRebalance(now, "")
+		return
+	}
+	g.generation++
+	g.state, g.leader, g.protocol = groupEmpty, "", ""
+	g.notify()
+}
+
+// checkMember validates the generation and member of a request.
+func (g *group) checkMember(generation int32, memberID string) (*member, int16) {
+	m := g.members[memberID]
+	switch {
+	case m == nil:
+		return nil, codeUnknownMemberID
+	case generation != g.generation:
+		return nil, codeIllegalGeneration
+	case g.state == groupPreparingRebalance:
+		return nil, codeRebalanceInProgress
+	}
+	return m, codeNone
+}
+
+type joinRequest struct {
+	groupID, memberID, instanceID string
+	clientID, clientHost          string
+	protocolType                  string
+	sessionTimeout                time.Duration
+	rebalanceTimeout              time.Duration
+	protocols                     []memberProtocol
+}
+
+type joinResult struct {
+	code       int16
+	generation int32
+	protocol   string
+	leader     string
+	memberID   string
+	// members are sent to the leader, with their metadata for the protocol
+	members []joinedMember
+}
+
+type joinedMember struct {
+	id, instanceID string
+	metadata       []byte
+}
+
+// joinGroup adds a member to a group, or has it rejoin, and waits for the
+// other members to rejoin before answering with the new generation.
+func (b *Broker) joinGroup(req joinRequest) joinResult {
+	if req.groupID == "" {
+		return joinResult{code: codeInvalidGroupID, memberID: req.memberID}
+	}
+	if len(req.protocols) == 0 {
+		return joinResult{code: codeInconsistentGroupProtocol, memberID: req.memberID}
+	}
+	b.groupMutex.Lock()
+	defer b.groupMutex.Unlock()
+	g := b.group(req.groupID, true)
+	now := time.Now()
+	g.expire(now)
+	if len(g.members) > 0 && (g.protocolType != req.protocolType || !g.supports(req.memberID, req.protocols)) {
+		return joinResult{code: codeInconsistentGroupProtocol, memberID: req.memberID}
+	}
+	m := g.members[req.memberID]
+	if req.memberID == "" {
+		prefix := req.clientID
+		if prefix == "" {
+			prefix = "member"
+		}
+		b.nextMemberID++
+		m = &member{id: fmt.Sprintf("%s-%d", prefix, b.nextMemberID), since: now}
+		g.members[m.id] = m
+		logger.Debug("Member joined group", "client_id", req.clientID, "group", g.id, "member_id", m.id)
+	} else if m == nil {
+		return joinResult{code: codeUnknownMemberID, memberID: req.memberID}
+	}
+	m.instanceID, m.clientID, m.clientHost = req.instanceID, req.clientID, req.clientHost
+	m.sessionTimeout, m.rebalanceTimeout = req.sessionTimeout, req.rebalanceTimeout
+	m.protocols, m.lastSeen, m.joined = req.protocols, now, true
+	g.protocolType = req.protocolType
+	if g.state != groupPreparingRebalance {
+		g.prepareRebalance(now, m.id)
+	}
+
+	generation := g.generation
+	for g.generation == generation {
+		waiting := false
+		for _, other := range g.members {
+			waiting = waiting || !other.joined
+		}
+		wait := time.Until(g.deadline)
+		if !waiting || wait <= 0 {
+			g.completeRebalance()
+			break
+		}
+		changed := g.changed
+		b.groupMutex.Unlock()
+		timer := time.NewTimer(wait)
+		select {
+		case <-changed:
+		case <-timer.C:
+		}
+		timer.Stop()
+		b.groupMutex.Lock()
+	}
+	if g.members[m.id] != m {
+		return joinResult{code: codeUnknownMemberID, memberID: m.id}
+	}
+
+	m.lastSeen = time.Now()
+	result := joinResult{generation: g.generation, protocol: g.protocol, leader: g.leader, memberID: m.id}
+	if m.id == g.leader {
+		for _, other := range g.members {
+			result.members = append(result.members, joinedMember{
+				id:         other.id,
+				instanceID: other.instanceID,
+				metadata:   other.protocol(g.protocol),
+			})
+		}
+		sort.Slice(result.members, func(i, j int) bool { return result.members[i].id < result.members[j].id })
+	}
+	return result
+}
+
+// syncGroup stores the assignments sent by the leader and returns the
+// assignment of a member, waiting for the leader when needed.
+func (b *Broker) syncGroup(groupID string, generation int32, memberID string, assignments map[string][]byte) ([]byte, int16) {
+	b.groupMutex.Lock()
+	defer b.groupMutex.Unlock()
+	g := b.group(groupID, false)
+	if g == nil {
+		return nil, codeUnknownMemberID
+	}
+	now := time.Now()
+	g.expire(now)
+	m, code := g.checkMember(generation, memberID)
+	if code != codeNone {
+		return nil, code
+	}
+	m.lastSeen = now
+	if m.id == g.leader && g.state == groupCompletingRebalance {
+		for id, assignment := range assignments {
+			if assigned := g.members[id]; assigned != nil {
+				assigned.assignment = assignment
+			}
+		}
+		g.state = groupStable
+		g.notify()
+	}
+
+	deadline := now.Add(m.sessionTimeout)
+	for g.state == groupCompletingRebalance && g.generation == generation {
+		wait := time.Until(deadline)
+		if wait <= 0 {
+			return nil, codeRebalanceInProgress
+		}
+		changed := g.changed
+		b.groupMutex.Unlock()
+		timer := time.NewTimer(wait)
+		select {
+		case <-changed:
+		case <-timer.C:
+		}
+		timer.Stop()
+		b.groupMutex.Lock()
+	}
+	if _, code := g.checkMember(generation, memberID); code != codeNone {
+		return nil, code
+	}
+	m.lastSeen = time.Now()
+	if m.assignment == nil {
+		return []byte{}, codeNone
+	}
+	return m.assignment, codeNone
+}
+
+// heartbeat keeps a member in its group and tells it when to rejoin.
+func (b *Broker) heartbeat(groupID string, generation int32, memberID string) int16 {
+	b.groupMutex.Lock()
+	defer b.groupMutex.Unlock()
+	g := b.group(groupID, false)
+	if g == nil {
+		return codeUnknownMemberID
+	}
+	now := time.Now()
+	g.expire(now)
+	m, code := g.checkMember(generation, memberID)
+	if code != codeNone {
+		return code
+	}
+	m.lastSeen = now
+	return codeNone
+}
+
+// leaveGroup removes a member and has the others rebalance.
+func (b *Broker) leaveGroup(groupID, memberID string) int16 {
+	b.groupMutex.Lock()
+	defer b.groupMutex.Unlock()
+	g := b.group(groupID, false)
+	if g == nil || g.members[memberID] == nil {
+		return codeUnknownMemberID
+	}
+	delete(g.members, memberID)
+	logger.Debug("Member left group", "member_id", memberID, "group", groupID)
+	g.membersChanged(time.Now())
+	return codeNone
+}
+
+// commitOffsets stores the offsets of a group member, or of a consumer
+// without a group membership (generation -1) when the group is empty.
+func (b *Broker) commitOffsets(groupID string, generation int32, memberID string, offsets map[topicPartition]committed) int16 {
+	if groupID == "" {
+		return codeInvalidGroupID
+	}
+	b.groupMutex.Lock()
+	defer b.groupMutex.Unlock()
+	g := b.group(groupID, true)
+	now := time.Now()
+	g.expire(now)
+	if generation < 0 && memberID == "" {
+		if len(g.members) > 0 {
+			return codeIllegalGeneration
+		}
+	} else {
+		m, code := g.checkMember(generation, memberID)
+		if code != codeNone {
+			return code
+		}
+		m.lastSeen = now
+	}
+	for tp, offset := range offsets {
+		g.offsets[tp] = offset
+	}
+	return codeNone
+}
+
+// committedOffsets returns every offset a group committed.
+func (b *Broker) committedOffsets(groupID string) map[topicPartition]committed {
+	b.groupMutex.Lock()
+	defer b.groupMutex.Unlock()
+	offsets := make(map[topicPartition]committed)
+	if g := b.group(groupID, false); g != nil {
+		for tp, offset := range g.offsets {
+			offsets[tp] = offset
+		}
+	}
+	return offsets
+}
+
+// Groups lists the consumer groups, by ID.
+func (b *Broker) Groups() []GroupInfo {
+	b.groupMutex.Lock()
+	groups := make([]GroupInfo, 0, len(b.groups))
+	for _, g := range b.groups {
+		groups = append(groups, g.info(time.Now()))
+	}
+	b.groupMutex.Unlock()
+	sort.Slice(groups, func(i, j int) bool { return groups[i].GroupID < groups[j].GroupID })
+	for i := range groups {
+		b.addLag(groups[i].Offsets)
+	}
+	return groups
+}
+
+// Group describes a consumer group, false when it is unknown.
+func (b *Broker) Group(id string) (GroupInfo, bool) {
+	b.groupMutex.Lock()
+	g := b.group(id, false)
+	if g == nil {
+		b.groupMutex.Unlock()
+		return GroupInfo{}, false
+	}
+	info := g.info(time.Now())
+	b.groupMutex.Unlock()
+	b.addLag(info.Offsets)
+	return info, true
+}
+
+func (g *group) info(now time.Time) GroupInfo {
+	g.expire(now)
+	info := GroupInfo{
+		GroupID:      g.id,
+		State:        g.state,
+		ProtocolType: g.protocolType,
+		Protocol:     g.protocol,
+		Generation:   g.generation,
+		Leader:       g.leader,
+		Members:      []MemberInfo{},
+		Offsets:      []CommittedOffset{},
+	}
+	for _, m := range g.members {
+		member := MemberInfo{MemberID: m.id, InstanceID: m.instanceID, ClientID: m.clientID, ClientHost: m.clientHost}
+		if g.protocolType == "consumer" && len(m.assignment) > 0 {
+			member.Assignment = decodeAssignment(m.assignment)
+		}
+		info.Members = append(info.Members, member)
+	}
+	sort.Slice(info.Members, func(i, j int) bool { return info.Members[i].MemberID < info.Members[j].MemberID })
+	for tp, offset := range g.offsets {
+		info.Offsets = append(info.Offsets, CommittedOffset{
+			Topic:     tp.topic,
+			Partition: tp.partition,
+			Offset:    offset.offset,
+			Metadata:  offset.metadata,
+		})
+	}
+	sort.Slice(info.Offsets, func(i, j int) bool {
+		a, b := info.Offsets[i], info.Offsets[j]
+		return a.Topic < b.Topic || a.Topic == b.Topic && a.Partition < b.Partition
+	})
+	return info
+}
+
+// addLag computes the lag of committed offsets from the high watermarks.
+func (b *Broker) addLag(offsets []CommittedOffset) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for i := range offsets {
+		if l := b.partition(offsets[i].Topic, offsets[i].Partition, false); l != nil {
+			offsets[i].Lag = max(l.next-offsets[i].Offset, 0)
+		}
+	}
+}
+
+// decodeAssignment decodes the partitions of a consumer protocol
+// assignment: version, then topics with their partitions, then user data.
+func decodeAssignment(data []byte) map[string][]int32 {
+	d := &decoder{buf: data}
+	d.int16() // version
+	assignment := make(map[string][]int32)
+	for i := d.arrayLen(); i > 0; i-- {
+		topic := d.string()
+		partitions := []int32{}
+		for j := d.arrayLen(); j > 0; j-- {
+			partitions = append(partitions, d.int32())
+		}
+		assignment[topic] = partitions
+	}
+	if d.err != nil {
+		return nil
+	}
+	return assignment
+}
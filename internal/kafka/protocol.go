@@ -0,0 +1,695 @@
+package kafka
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"time"
+
+	"mockserver/internal/panics"
+)
+
+// API keys of the supported requests.
+const (
+	apiProduce         = 0
+	apiFetch           = 1
+	apiListOffsets     = 2
+	apiMetadata        = 3
+	apiOffsetCommit    = 8
+	apiOffsetFetch     = 9
+	apiFindCoordinator = 10
+	apiJoinGroup       = 11
+	apiHeartbeat       = 12
+	apiLeaveGroup      = 13
+	apiSyncGroup       = 14
+	apiDescribeGroups  = 15
+	apiListGroups      = 16
+	apiVersions        = 18
+	apiInitProducerID  = 22
+)
+
+// Only the versions before the flexible (tagged fields) encoding are
+// served; clients pick the highest one advertised by ApiVersions.
+var supportedAPIs = []struct {
+	key, min, max int16
+}{
+	{apiProduce, 3, 8},
+	{apiFetch, 4, 11},
+	{apiListOffsets, 1, 5},
+	{apiMetadata, 0, 8},
+	{apiOffsetCommit, 2, 7},
+	{apiOffsetFetch, 1, 5},
+	{apiFindCoordinator, 0, 2},
+	{apiJoinGroup, 0, 5},
+	{apiHeartbeat, 0, 3},
+	{apiLeaveGroup, 0, 3},
+	{apiSyncGroup, 0, 3},
+	{apiDescribeGroups, 0, 4},
+	{apiListGroups, 0, 2},
+	{apiVersions, 0, 2},
+	{apiInitProducerID, 0, 1},
+}
+
+// Error codes of the protocol.
+const (
+	codeNone                      = 0
+	codeOffsetOutOfRange          = 1
+	codeCorruptMessage            = 2
+	codeUnknownTopicOrPartition   = 3
+	codeCoordinatorNotAvailable   = 15
+	codeInvalidTopic              = 17
+	codeIllegalGeneration         = 22
+	codeInconsistentGroupProtocol = 23
+	codeInvalidGroupID            = 24
+	codeUnknownMemberID           = 25
+	codeRebalanceInProgress       = 27
+	codeUnsupportedVersion        = 35
+)
+
+const (
+	clusterID = "mockserver"
+	// maxRequestSize bounds the requests read from clients.
+	maxRequestSize = 64 << 20
+	// noAuthorizedOperations is sent when operations were not requested.
+	noAuthorizedOperations = math.MinInt32
+)
+
+var errShortRequest = errors.New("kafka: malformed request")
+
+func (b *Broker) handleConn(conn net.Conn) {
+	defer b.panics.Contain(context.Background(), panics.ProtocolKafka, conn.RemoteAddr().String())
+	defer conn.Close()
+	// Group members are described with the host they connect from
+	clientHost := conn.RemoteAddr().String()
+	if host, _, err := net.SplitHostPort(clientHost); err == nil {
+		clientHost = "/" + host
+	}
+	for {
+		var size [4]byte
+		if _, err := io.ReadFull(conn, size[:]); err != nil {
+			return
+		}
+		length := int32(binary.BigEndian.Uint32(size[:]))
+		if length < 8 || length > maxRequestSize {
+			logger.Debug("Invalid request size", "bytes", length, "peer", conn.RemoteAddr().String())
+			return
+		}
+		request := make([]byte, length)
+		if _, err := io.ReadFull(conn, request); err != nil {
+			return
+		}
+
+		d := &decoder{buf: request}
+		apiKey, version, correlationID := d.int16(), d.int16(), d.int32()
+		clientID := d.nullableString()
+		e := &encoder{buf: make([]byte, 8, 256)}
+		respond, err := b.handle(apiKey, version, clientID, clientHost, d, e)
+		if err == nil {
+			err = d.err
+		}
+		if err != nil {
+			logger.Debug("Closing connection", "peer", conn.RemoteAddr().String(), "error", err)
+			return
+		}
+		if !respond {
+			continue
+		}
+		binary.BigEndian.PutUint32(e.buf, uint32(len(e.buf)-4))
+		binary.BigEndian.PutUint32(e.buf[4:], uint32(correlationID))
+		if _, err := conn.Write(e.buf); err != nil {
+			return
+		}
+	}
+}
+
+// handle decodes a request body and encodes the response; it returns false
+// when no response is expected.
+func (b *Broker) handle(apiKey, version int16, clientID, clientHost string, d *decoder, e *encoder) (bool, error) {
+	if apiKey == apiVersions {
+		b.apiVersions(version, e)
+		return true, nil
+	}
+	supported := false
+	for _, api := range supportedAPIs {
+		if api.key == apiKey {
+			if version < api.min || version > api.max {
+				return false, fmt.Errorf("unsupported version %d of API %d", version, apiKey)
+			}
+			supported = true
+		}
+	}
+	if !supported {
+		return false, fmt.Errorf("unsupported API %d", apiKey)
+	}
+
+	switch apiKey {
+	case apiMetadata:
+		b.metadata(version, d, e)
+	case apiProduce:
+		return b.produce(version, clientID, d, e), nil
+	case apiFetch:
+		b.fetch(version, d, e)
+	case apiListOffsets:
+		b.listOffsets(version, d, e)
+	case apiInitProducerID:
+		b.initProducerID(d, e)
+	case apiFindCoordinator:
+		b.findCoordinator(version, d, e)
+	case apiJoinGroup:
+		b.joinGroupRequest(version, clientID, clientHost, d, e)
+	case apiSyncGroup:
+		b.syncGroupRequest(version, d, e)
+	case apiHeartbeat:
+		b.heartbeatRequest(version, d, e)
+	case apiLeaveGroup:
+		b.leaveGroupRequest(version, d, e)
+	case apiOffsetCommit:
+		b.offsetCommit(version, d, e)
+	case apiOffsetFetch:
+		b.offsetFetch(version, d, e)
+	case apiDescribeGroups:
+		b.describeGroups(version, d, e)
+	case apiListGroups:
+		b.listGroups(version, e)
+	}
+	return true, nil
+}
+
+// apiVersions answers newer, flexible versions with UNSUPPORTED_VERSION in
+// the version 0 format, which makes clients retry with a version we know.
+func (b *Broker) apiVersions(version int16, e *encoder) {
+	if version > 2 {
+		e.int16(codeUnsupportedVersion)
+	} else {
+		e.int16(codeNone)
+	}
+	e.arrayLen(len(supportedAPIs))
+	for _, api := range supportedAPIs {
+		e.int16(api.key)
+		e.int16(api.min)
+		e.int16(api.max)
+	}
+	if version >= 1 && version <= 2 {
+		e.int32(0) // throttle time
+	}
+}
+
+type topicMetadata struct {
+	name       string
+	code       int16
+	partitions int
+}
+
+func (b *Broker) metadata(version int16, d *decoder, e *encoder) {
+	count := d.arrayLen()
+	all := count < 0 || (version == 0 && count == 0)
+	var names []string
+	for i := 0; i < count; i++ {
+		names = append(names, d.string())
+	}
+	create := true
+	if version >= 4 {
+		create = d.bool()
+	}
+	if version >= 8 {
+		d.bool() // include cluster authorized operations
+		d.bool() // include topic authorized operations
+	}
+
+	b.mutex.Lock()
+	var topics []topicMetadata
+	if all {
+		for name, logs := range b.topics {
+			topics = append(topics, topicMetadata{name: name, partitions: len(logs)})
+		}
+	}
+	for _, name := range names {
+		topic := topicMetadata{name: name, code: codeUnknownTopicOrPartition}
+		if validTopicName(name) != nil {
+			topic.code = codeInvalidTopic
+		} else if logs := b.topic(name, create); logs != nil {
+			topic.code = codeNone
+			topic.partitions = len(logs)
+		}
+		topics = append(topics, topic)
+	}
+	port := b.port
+	b.mutex.Unlock()
+
+	if version >= 3 {
+		e.int32(0) // throttle time
+	}
+	e.arrayLen(1)
+	e.int32(nodeID)
+	e.string(b.host)
+	e.int32(port)
+	if version >= 1 {
+		e.nullableString(nil) // rack
+	}
+	if version >= 2 {
+		cluster := clusterID
+		e.nullableString(&cluster)
+	}
+	if version >= 1 {
+		e.int32(nodeID) // controller
+	}
+	e.arrayLen(len(topics))
+	for _, topic := range topics {
+		e.int16(topic.code)
+		e.string(topic.name)
+		if version >= 1 {
+			e.bool(false) // internal
+		}
+		e.arrayLen(topic.partitions)
+		for partition := 0; partition < topic.partitions; partition++ {
+			e.int16(codeNone)
+			e.int32(int32(partition))
+			e.int32(nodeID) // leader
+			if version >= 7 {
+				e.int32(0) // leader epoch
+			}
+			e.arrayLen(1) // replicas
+			e.int32(nodeID)
+			e.arrayLen(1) // in-sync replicas
+			e.int32(nodeID)
+			if version >= 5 {
+				e.arrayLen(0) // offline replicas
+			}
+		}
+		if version >= 8 {
+			e.int32(noAuthorizedOperations)
+		}
+	}
+	if version >= 8 {
+		e.int32(noAuthorizedOperations)
+	}
+}
+
+func (b *Broker) produce(version int16, clientID string, d *decoder, e *encoder) bool {
+	d.nullableString() // transactional ID
+	acks := d.int16()
+	d.int32() // timeout
+	topics := d.arrayLen()
+	e.arrayLen(max(topics, 0))
+	for i := 0; i < topics; i++ {
+		topic := d.string()
+		partitions := d.arrayLen()
+		e.string(topic)
+		e.arrayLen(max(partitions, 0))
+		for j := 0; j < partitions; j++ {
+			partition := d.int32()
+			records := d.bytes()
+			if d.err != nil {
+				return false
+			}
+			base, code := b.append(clientID, topic, partition, records)
+			e.int32(partition)
+			e.int16(code)
+			e.int64(base)
+			e.int64(-1) // log append time
+			if version >= 5 {
+				e.int64(0) // log start offset
+			}
+			if version >= 8 {
+				e.arrayLen(0)         // record errors
+				e.nullableString(nil) // error message
+			}
+		}
+	}
+	e.int32(0) // throttle time
+	return acks != 0
+}
+
+func (b *Broker) append(clientID, topic string, partition int32, records []byte) (int64, int16) {
+	batches, err := splitBatches(records)
+	if err != nil {
+		logger.Debug("Records rejected", "topic", topic, "partition", partition, "client_id", clientID, "error", err)
+		return -1, codeCorruptMessage
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	l := b.partition(topic, partition, true)
+	if l == nil {
+		return -1, codeUnknownTopicOrPartition
+	}
+	base := l.next
+	for _, batch := range batches {
+		l.append(batch, SourceProducer)
+	}
+	b.notify()
+	logger.Debug("Records produced", "client_id", clientID, "records", l.next-base, "topic", topic, "partition", partition, "offset", base)
+	return base, codeNone
+}
+
+type fetchTopic struct {
+	name       string
+	partitions []fetchPartition
+}
+
+type fetchPartition struct {
+	partition int32
+	offset    int64
+	maxBytes  int32
+
+	code          int16
+	highWatermark int64
+	records       []byte
+}
+
+func (b *Broker) fetch(version int16, d *decoder, e *encoder) {
+	d.int32() // replica ID
+	maxWait := time.Duration(d.int32()) * time.Millisecond
+	minBytes := int(d.int32())
+	maxBytes := int(d.int32())
+	d.int8() // isolation level
+	if version >= 7 {
+		d.int32() // session ID
+		d.int32() // session epoch
+	}
+	topics := make([]fetchTopic, max(d.arrayLen(), 0))
+	for i := range topics {
+		topics[i].name = d.string()
+		topics[i].partitions = make([]fetchPartition, max(d.arrayLen(), 0))
+		for j := range topics[i].partitions {
+			p := &topics[i].partitions[j]
+			p.partition = d.int32()
+			if version >= 9 {
+				d.int32() // current leader epoch
+			}
+			p.offset = d.int64()
+			if version >= 5 {
+				d.int64() // log start offset
+			}
+			p.maxBytes = d.int32()
+		}
+	}
+	if version >= 7 {
+		// Fetch sessions are not supported, so there is nothing to forget
+		for i := d.arrayLen(); i > 0; i-- {
+			d.string()
+			for j := d.arrayLen(); j > 0; j-- {
+				d.int32()
+			}
+		}
+	}
+	if version >= 11 {
+		d.string() // rack ID
+	}
+	if d.err != nil {
+		return
+	}
+
+	// Wait for min_bytes or max_wait, whichever comes first
+	deadline := time.Now().Add(maxWait)
+	for {
+		size, changed := b.read(topics, maxBytes)
+		wait := time.Until(deadline)
+		if size >= minBytes || wait <= 0 {
+			break
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-changed:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+
+	e.int32(0) // throttle time
+	if version >= 7 {
+		e.int16(codeNone)
+		e.int32(0) // session ID
+	}
+	e.arrayLen(len(topics))
+	for _, topic := range topics {
+		e.string(topic.name)
+		e.arrayLen(len(topic.partitions))
+		for _, p := range topic.partitions {
+			e.int32(p.partition)
+			e.int16(p.code)
+			e.int64(p.highWatermark)
+			e.int64(p.highWatermark) // last stable offset
+			if version >= 5 {
+				e.int64(0) // log start offset
+			}
+			e.arrayLen(0) // aborted transactions
+			if version >= 11 {
+				e.int32(-1) // preferred read replica
+			}
+			e.bytes(p.records)
+		}
+	}
+}
+
+// read fills the partitions of a fetch with the batches from their offsets
+// and returns the number of bytes read, along with the channel notifying
+// the next append.
+func (b *Broker) read(topics []fetchTopic, maxBytes int) (int, chan struct{}) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	size := 0
+	for i := range topics {
+		for j := range topics[i].partitions {
+			p := &topics[i].partitions[j]
+			p.records = []byte{}
+			l := b.partition(topics[i].name, p.partition, false)
+			if l == nil {
+				p.code, p.highWatermark = codeUnknownTopicOrPartition, -1
+				continue
+			}
+			p.code, p.highWatermark = codeNone, l.next
+			if p.offset < 0 || p.offset > l.next {
+				p.code = codeOffsetOutOfRange
+				continue
+			}
+			for _, batch := range l.batches {
+				if batch.lastOffset < p.offset {
+					continue
+				}
+				// The first batch is always returned, even when too large
+				if size > 0 && (len(p.records)+len(batch.data) > int(p.maxBytes) || size+len(batch.data) > maxBytes) {
+					break
+				}
+				p.records = append(p.records, batch.data...)
+				size += len(batch.data)
+			}
+		}
+	}
+	return size, b.changed
+}
+
+func (b *Broker) listOffsets(version int16, d *decoder, e *encoder) {
+	d.int32() // replica ID
+	if version >= 2 {
+		d.int8()   // isolation level
+		e.int32(0) // throttle time
+	}
+	topics := d.arrayLen()
+	e.arrayLen(max(topics, 0))
+	for i := 0; i < topics; i++ {
+		topic := d.string()
+		partitions := d.arrayLen()
+		e.string(topic)
+		e.arrayLen(max(partitions, 0))
+		for j := 0; j < partitions; j++ {
+			partition := d.int32()
+			if version >= 4 {
+				d.int32() // current leader epoch
+			}
+			timestamp := d.int64()
+			offset, found, code := b.offsetFor(topic, partition, timestamp)
+			e.int32(partition)
+			e.int16(code)
+			e.int64(found)
+			e.int64(offset)
+			if version >= 4 {
+				e.int32(0) // leader epoch
+			}
+		}
+	}
+}
+
+// Special timestamps of ListOffsets requests.
+const (
+	latestTimestamp   = -1
+	earliestTimestamp = -2
+)
+
+// offsetFor resolves a ListOffsets timestamp to the offset of the first
+// batch with a later timestamp, or -1 when there is none.
+func (b *Broker) offsetFor(topic string, partition int32, timestamp int64) (offset, found int64, code int16) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	l := b.partition(topic, partition, false)
+	if l == nil {
+		return -1, -1, codeUnknownTopicOrPartition
+	}
+	switch timestamp {
+	case latestTimestamp:
+		return l.next, -1, codeNone
+	case earliestTimestamp:
+		return 0, -1, codeNone
+	}
+	for _, batch := range l.batches {
+		if batch.maxTimestamp >= timestamp {
+			return batch.baseOffset, batch.maxTimestamp, codeNone
+		}
+	}
+	return -1, -1, codeNone
+}
+
+// initProducerID hands out producer IDs to idempotent producers; sequence
+// numbers are not checked.
+func (b *Broker) initProducerID(d *decoder, e *encoder) {
+	d.nullableString() // transactional ID
+	d.int32()          // transaction timeout
+	b.mutex.Lock()
+	b.nextProducerID++
+	id := b.nextProducerID
+	b.mutex.Unlock()
+	e.int32(0) // throttle time
+	e.int16(codeNone)
+	e.int64(id)
+	e.int16(0) // producer epoch
+}
+
+// decoder reads the fixed-size encoding of requests; the first error
+// sticks and zero values are returned from then on.
+type decoder struct {
+	buf []byte
+	err error
+}
+
+func (d *decoder) take(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(d.buf) {
+		d.err = errShortRequest
+		return nil
+	}
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+	return b
+}
+
+func (d *decoder) int8() int8 {
+	if b := d.take(1); b != nil {
+		return int8(b[0])
+	}
+	return 0
+}
+
+func (d *decoder) bool() bool {
+	return d.int8() != 0
+}
+
+func (d *decoder) int16() int16 {
+	if b := d.take(2); b != nil {
+		return int16(binary.BigEndian.Uint16(b))
+	}
+	return 0
+}
+
+func (d *decoder) int32() int32 {
+	if b := d.take(4); b != nil {
+		return int32(binary.BigEndian.Uint32(b))
+	}
+	return 0
+}
+
+func (d *decoder) int64() int64 {
+	if b := d.take(8); b != nil {
+		return int64(binary.BigEndian.Uint64(b))
+	}
+	return 0
+}
+
+func (d *decoder) string() string {
+	return string(d.take(int(d.int16())))
+}
+
+// nullableString reads a string whose length may be -1 (null).
+func (d *decoder) nullableString() string {
+	length := d.int16()
+	if length < 0 {
+		return ""
+	}
+	return string(d.take(int(length)))
+}
+
+func (d *decoder) bytes() []byte {
+	length := d.int32()
+	if length < 0 {
+		return nil
+	}
+	return d.take(int(length))
+}
+
+// arrayLen returns the number of elements, -1 for a null array.
+func (d *decoder) arrayLen() int {
+	length := int(d.int32())
+	if d.err == nil && length > len(d.buf) {
+		// Every element takes at least one byte
+		d.err = errShortRequest
+	}
+	if d.err != nil {
+		return 0
+	}
+	return length
+}
+
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) bool(v bool) {
+	if v {
+		e.buf = append(e.buf, 1)
+	} else {
+		e.buf = append(e.buf, 0)
+	}
+}
+
+func (e *encoder) int16(v int16) {
+	e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(v))
+}
+
+func (e *encoder) int32(v int32) {
+	e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(v))
+}
+
+func (e *encoder) int64(v int64) {
+	e.buf = binary.BigEndian.AppendUint64(e.buf, uint64(v))
+}
+
+func (e *encoder) string(s string) {
+	e.int16(int16(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+func (e *encoder) nullableString(s *string) {
+	if s == nil {
+		e.int16(-1)
+		return
+	}
+	e.string(*s)
+}
+
+func (e *encoder) bytes(b []byte) {
+	if b == nil {
+		e.int32(-1)
+		return
+	}
+	e.int32(int32(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+func (e *encoder) arrayLen(n int) {
+	e.int32(int32(n))
+}
@@ -0,0 +1,279 @@
+package kafka
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Record batches (magic 2) are stored as received; only the base offset,
+// which the CRC does not cover, is rewritten when they are appended.
+//
+//	baseOffset int64, batchLength int32, partitionLeaderEpoch int32,
+//	magic int8, crc uint32, attributes int16, lastOffsetDelta int32,
+//	firstTimestamp int64, maxTimestamp int64, producerId int64,
+//	producerEpoch int16, baseSequence int32, records []record
+const (
+	batchHeaderSize = 61
+	// batchLengthOffset is where the bytes counted by batchLength start.
+	batchLengthOffset = 12
+	crcStart          = 21
+)
+
+// Attribute bits of a record batch.
+const (
+	compressionMask = 0x07
+	controlBatch    = 0x20
+)
+
+var compressionNames = []string{"none", "gzip", "snappy", "lz4", "zstd"}
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+var errCorruptBatch = errors.New("corrupt record batch")
+
+type batchHeader []byte
+
+func parseBatchHeader(data []byte) batchHeader {
+	return batchHeader(data[:batchHeaderSize])
+}
+
+func (h batchHeader) setBaseOffset(offset int64) {
+	binary.BigEndian.PutUint64(h, uint64(offset))
+}
+
+func (h batchHeader) attributes() int16 {
+	return int16(binary.BigEndian.Uint16(h[21:]))
+}
+
+func (h batchHeader) lastOffsetDelta() int32 {
+	return int32(binary.BigEndian.Uint32(h[23:]))
+}
+
+func (h batchHeader) firstTimestamp() int64 {
+	return int64(binary.BigEndian.Uint64(h[27:]))
+}
+
+func (h batchHeader) maxTimestamp() int64 {
+	return int64(binary.BigEndian.Uint64(h[35:]))
+}
+
+func (h batchHeader) count() int32 {
+	return int32(binary.BigEndian.Uint32(h[57:]))
+}
+
+// splitBatches checks the record batches of a produce request.
+func splitBatches(data []byte) ([][]byte, error) {
+	var batches [][]byte
+	for len(data) > 0 {
+		if len(data) < batchHeaderSize {
+			return nil, errCorruptBatch
+		}
+		length := int(int32(binary.BigEndian.Uint32(data[8:])))
+		if length < batchHeaderSize-batchLengthOffset || length > len(data)-batchLengthOffset {
+			return nil, errCorruptBatch
+		}
+		batch := data[:batchLengthOffset+length]
+		if magic := batch[16]; magic != 2 {
+			return nil, fmt.Errorf("unsupported record batch magic %d", magic)
+		}
+		if crc32.Checksum(batch[crcStart:], castagnoli) != binary.BigEndian.Uint32(batch[17:]) {
+			return nil, fmt.Errorf("%w: CRC mismatch", errCorruptBatch)
+		}
+		if parseBatchHeader(batch).lastOffsetDelta() < 0 {
+			return nil, errCorruptBatch
+		}
+		batches = append(batches, batch)
+		data = data[len(batch):]
+	}
+	if len(batches) == 0 {
+		return nil, errCorruptBatch
+	}
+	return batches, nil
+}
+
+// encodeBatch builds an uncompressed batch of injected records.
+func encodeBatch(records []Record) []byte {
+	first := records[0].Timestamp.UnixMilli()
+	last := first
+	var body []byte
+	for i, record := range records {
+		timestamp := record.Timestamp.UnixMilli()
+		last = max(last, timestamp)
+
+		rec := []byte{0} // attributes
+		rec = binary.AppendVarint(rec, timestamp-first)
+		rec = binary.AppendVarint(rec, int64(i))
+		rec = appendVarString(rec, record.Key)
+		rec = appendVarString(rec, record.Value)
+		rec = binary.AppendVarint(rec, int64(len(record.Headers)))
+		for key, value := range record.Headers {
+			rec = appendVarString(rec, &key)
+			rec = appendVarString(rec, &value)
+		}
+		body = binary.AppendVarint(body, int64(len(rec)))
+		body = append(body, rec...)
+	}
+
+	batch := make([]byte, batchHeaderSize, batchHeaderSize+len(body))
+	binary.BigEndian.PutUint32(batch[8:], uint32(len(batch)-batchLengthOffset+len(body)))
+	batch[16] = 2
+	binary.BigEndian.PutUint32(batch[23:], uint32(len(records)-1))
+	binary.BigEndian.PutUint64(batch[27:], uint64(first))
+	binary.BigEndian.PutUint64(batch[35:], uint64(last))
+	// No producer ID, epoch or sequence
+	binary.BigEndian.PutUint64(batch[43:], ^uint64(0))
+	binary.BigEndian.PutUint16(batch[51:], ^uint16(0))
+	binary.BigEndian.PutUint32(batch[53:], ^uint32(0))
+	binary.BigEndian.PutUint32(batch[57:], uint32(len(records)))
+	batch = append(batch, body...)
+	binary.BigEndian.PutUint32(batch[17:], crc32.Checksum(batch[crcStart:], castagnoli))
+	return batch
+}
+
+func appendVarString(buf []byte, s *string) []byte {
+	if s == nil {
+		return binary.AppendVarint(buf, -1)
+	}
+	buf = binary.AppendVarint(buf, int64(len(*s)))
+	return append(buf, *s...)
+}
+
+// decodeBatch decodes the records of a stored batch; control batches of
+// transactions have none.
+func decodeBatch(batch []byte) ([]Record, error) {
+	header := parseBatchHeader(batch)
+	if header.attributes()&controlBatch != 0 {
+		return nil, nil
+	}
+	data, err := decompress(header.attributes()&compressionMask, batch[batchHeaderSize:])
+	if err != nil {
+		return nil, err
+	}
+
+	base := int64(binary.BigEndian.Uint64(batch))
+	count := int(header.count())
+	records := make([]Record, 0, min(count, len(data)))
+	for i := 0; i < count; i++ {
+		length, n := binary.Varint(data)
+		if n <= 0 || length < 1 || length > int64(len(data)-n) {
+			return nil, errCorruptBatch
+		}
+		d := &varDecoder{buf: data[n+1 : n+int(length)]} // skip the attributes
+		data = data[n+int(length):]
+
+		timestampDelta := d.varint()
+		offsetDelta := d.varint()
+		record := Record{
+			Offset:    base + offsetDelta,
+			Timestamp: time.UnixMilli(header.firstTimestamp() + timestampDelta),
+			Key:       d.string(),
+			Value:     d.string(),
+		}
+		if headers := d.varint(); headers > 0 {
+			record.Headers = make(map[string]string)
+			for j := int64(0); j < headers && d.err == nil; j++ {
+				key, value := d.string(), d.string()
+				if key != nil && value != nil {
+					record.Headers[*key] = *value
+				}
+			}
+		}
+		if d.err != nil {
+			return nil, d.err
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+type varDecoder struct {
+	buf []byte
+	err error
+}
+
+func (d *varDecoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	value, n := binary.Varint(d.buf)
+	if n <= 0 {
+		d.err = errCorruptBatch
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return value
+}
+
+func (d *varDecoder) string() *string {
+	length := d.varint()
+	if d.err != nil || length < 0 {
+		return nil
+	}
+	if length > int64(len(d.buf)) {
+		d.err = errCorruptBatch
+		return nil
+	}
+	s := string(d.buf[:length])
+	d.buf = d.buf[length:]
+	return &s
+}
+
+var zstdDecoder = sync.OnceValues(func() (*zstd.Decoder, error) {
+	return zstd.NewReader(nil, zstd.WithDecoderConcurrency(1))
+})
+
+// xerialHeader starts snappy data framed by the Java clients.
+var xerialHeader = []byte{0x82, 'S', 'N', 'A', 'P', 'P', 'Y', 0}
+
+func decompress(codec int16, data []byte) ([]byte, error) {
+	switch codec {
+	case 0:
+		return data, nil
+	case 1:
+		reader, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		return io.ReadAll(reader)
+	case 2:
+		if !bytes.HasPrefix(data, xerialHeader) {
+			return snappy.Decode(nil, data)
+		}
+		// Header, version and compatible version, then length-prefixed blocks
+		var out []byte
+		data = data[min(16, len(data)):]
+		for len(data) >= 4 {
+			length := int(binary.BigEndian.Uint32(data))
+			if length > len(data)-4 {
+				return nil, errCorruptBatch
+			}
+			block, err := snappy.Decode(nil, data[4:4+length])
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, block...)
+			data = data[4+length:]
+		}
+		return out, nil
+	case 4:
+		decoder, err := zstdDecoder()
+		if err != nil {
+			return nil, err
+		}
+		return decoder.DecodeAll(data, nil)
+	}
+	if int(codec) < len(compressionNames) {
+		return nil, fmt.Errorf("%s compression is not supported", compressionNames[codec])
+	}
+	return nil, fmt.Errorf("unknown compression %d", codec)
+}
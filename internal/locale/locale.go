@@ -0,0 +1,136 @@
+// Package locale negotiates the language of a response from the
+// Accept-Language request header.
+package locale
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// How a locale was chosen.
+const (
+	MatchExact    = "exact"
+	MatchLookup   = "lookup"
+	MatchPrefix   = "prefix"
+	MatchWildcard = "wildcard"
+	MatchFallback = "fallback"
+	MatchNone     = "none"
+)
+
+// Preference is a language range of an Accept-Language header with its
+// quality.
+type Preference struct {
+	Range   string  `json:"range"`
+	Quality float64 `json:"q"`
+}
+
+// Parse reads an Accept-Language header, most preferred first. Ranges with
+// equal quality keep their order; malformed entries are skipped.
+func Parse(header string) []Preference {
+	var prefs []Preference
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			continue
+		}
+		quality := 1.0
+		if name, value, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(name) == "q" {
+			q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil || q < 0 || q > 1 {
+				continue
+			}
+			quality = q
+		}
+		prefs = append(prefs, Preference{Range: tag, Quality: quality})
+	}
+	sort.SliceStable(prefs, func(i, j int) bool {
+		return prefs[i].Quality > prefs[j].Quality
+	})
+	return prefs
+}
+
+// Match is the result of a negotiation; Locale is empty when nothing matched.
+type Match struct {
+	Locale string `json:"locale"`
+	By     string `json:"matched_by"`
+}
+
+// Negotiate picks one of the supported locales for a header. Each
+// acceptable range, most preferred first, is tried as is, then shortened
+// one subtag at a time ("de-CH-1996", "de-CH", "de"), then as a prefix of
+// a supported locale ("en" picks "en-US"); "*" picks the first supported
+// locale. When no range matches, the first supported entry of the fallback
+// chain is used. Locales refused with q=0 are never picked. Comparison
+// ignores case, and the locale is returned as listed in supported.
+func Negotiate(header string, supported, fallback []string) Match {
+	prefs := Parse(header)
+	var refused []string
+	for _, pref := range prefs {
+		if pref.Quality == 0 {
+			refused = append(refused, pref.Range)
+		}
+	}
+	acceptable := make([]string, 0, len(supported))
+	for _, tag := range supported {
+		if find(refused, tag) == "" {
+			acceptable = append(acceptable, tag)
+		}
+	}
+	supported = acceptable
+
+	for _, pref := range prefs {
+		if pref.Quality == 0 {
+			continue
+		}
+		if pref.Range == "*" {
+			if len(supported) > 0 {
+				return Match{Locale: supported[0], By: MatchWildcard}
+			}
+			continue
+		}
+		if tag := find(supported, pref.Range); tag != "" {
+			return Match{Locale: tag, By: MatchExact}
+		}
+		for shorter := truncate(pref.Range); shorter != ""; shorter = truncate(shorter) {
+			if tag := find(supported, shorter); tag != "" {
+				return Match{Locale: tag, By: MatchLookup}
+			}
+		}
+		for _, tag := range supported {
+			if len(tag) > len(pref.Range) && strings.EqualFold(tag[:len(pref.Range)+1], pref.Range+"-") {
+				return Match{Locale: tag, By: MatchPrefix}
+			}
+		}
+	}
+	for _, candidate := range fallback {
+		if tag := find(supported, candidate); tag != "" {
+			return Match{Locale: tag, By: MatchFallback}
+		}
+	}
+	return Match{By: MatchNone}
+}
+
+func find(supported []string, tag string) string {
+	for _, candidate := range supported {
+		if strings.EqualFold(candidate, tag) {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// truncate drops the last subtag of a range, along with a single-letter
+// subtag left in front of it (RFC 4647, section 3.4).
+func truncate(tag string) string {
+	i := strings.LastIndexByte(tag, '-')
+	if i < 0 {
+		return ""
+	}
+	tag = tag[:i]
+	if i = strings.LastIndexByte(tag, '-'); i >= 0 && len(tag)-i == 2 {
+		tag = tag[:i]
+	}
+	return tag
+}
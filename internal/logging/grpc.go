@@ -0,0 +1,93 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"mockserver/internal/correlation"
+)
+
+// healthService is polled by monitoring, and its calls logged at debug
+// level.
+const healthService = "/grpc.health.v1.Health/"
+
+// UnaryInterceptor gives every unary call its fields (request_id, rpc and
+// correlation_id when there is one), carried by the call context to what the
+// handlers log, and logs the call once it is answered.
+func UnaryInterceptor() grpc.UnaryServerInterceptor {
+	logger := For("grpc")
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		ctx = callContext(ctx, info.FullMethod)
+		resp, err := handler(ctx, req)
+		logCall(ctx, logger, info.FullMethod, err, start)
+		return resp, err
+	}
+}
+
+// StreamInterceptor does for streaming calls what UnaryInterceptor does for
+// unary ones.
+func StreamInterceptor() grpc.StreamServerInterceptor {
+	logger := For("grpc")
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		ctx := callContext(ss.Context(), info.FullMethod)
+		err := handler(srv, &fieldsStream{ServerStream: ss, ctx: ctx})
+		logCall(ctx, logger, info.FullMethod, err, start)
+		return err
+	}
+}
+
+func callContext(ctx context.Context, method string) context.Context {
+	md, _ := metadata.FromIncomingContext(ctx)
+	id := ""
+	if values := md.Get(RequestIDHeader); len(values) > 0 {
+		id = values[0]
+	}
+	if id == "" {
+		id = newRequestID()
+	}
+	args := []any{"request_id", id, "rpc", method}
+	if correlationID := correlation.FromMetadata(md); correlationID != "" {
+		args = append(args, "correlation_id", correlationID)
+	}
+	return With(ctx, args...)
+}
+
+func logCall(ctx context.Context, logger *slog.Logger, method string, err error, start time.Time) {
+	level := slog.LevelInfo
+	if strings.HasPrefix(method, healthService) {
+		level = slog.LevelDebug
+	}
+	if !logger.Enabled(ctx, level) {
+		return
+	}
+	attrs := []slog.Attr{
+		slog.String("code", status.Code(err).String()),
+		slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		attrs = append(attrs, slog.String("peer", p.Addr.String()))
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", status.Convert(err).Message()))
+	}
+	logger.LogAttrs(ctx, level, "gRPC call", attrs...)
+}
+
+// fieldsStream hands the call context, with its fields, to the handler.
+type fieldsStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *fieldsStream) Context() context.Context {
+	return s.ctx
+}
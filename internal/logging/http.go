@@ -0,0 +1,85 @@
+package logging
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"mockserver/internal/correlation"
+)
+
+// RequestIDHeader carries the ID of a request into its log records; one is
+// generated when it is absent.
+const RequestIDHeader = "X-Request-Id"
+
+// QuietHeader marks polling requests, such as those of the dashboard, which
+// are logged at debug level like the paths polled by monitoring.
+const QuietHeader = "X-Mock-Quiet"
+
+// quietPaths are polled by monitoring, and logged at debug level.
+var quietPaths = map[string]bool{
+	"/health":  true,
+	"/metrics": true,
+	"/readyz":  true,
+}
+
+// Middleware gives every HTTP request its fields (request_id, method, path
+// and correlation_id when there is one), carried by the request context to
+// what the handlers log, and logs the request once it is answered. stubID,
+// when set, names the stub that answered the request (journal.MatchedStubID,
+// which this package cannot import: the journal depends on packages that
+// log through it).
+func Middleware(stubID func(echo.Context) string) echo.MiddlewareFunc {
+	logger := For("http")
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			r := c.Request()
+			id := r.Header.Get(RequestIDHeader)
+			if id == "" {
+				id = newRequestID()
+			}
+			args := []any{"request_id", id, "method", r.Method, "path", r.URL.Path}
+			if correlationID := correlation.FromHeader(r.Header); correlationID != "" {
+				args = append(args, "correlation_id", correlationID)
+			}
+			ctx := With(r.Context(), args...)
+			c.SetRequest(r.WithContext(ctx))
+
+			if err := next(c); err != nil {
+				// Let echo write the error response so the logged status is final.
+				c.Error(err)
+			}
+
+			level := slog.LevelInfo
+			if quietPaths[r.URL.Path] || r.Header.Get(QuietHeader) != "" {
+				level = slog.LevelDebug
+			}
+			if !logger.Enabled(ctx, level) {
+				return nil
+			}
+			attrs := []slog.Attr{
+				slog.Int("status", c.Response().Status),
+				slog.Int64("bytes_out", c.Response().Size),
+				slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+				slog.String("remote_ip", c.RealIP()),
+			}
+			if stubID != nil {
+				if id := stubID(c); id != "" {
+					attrs = append(attrs, slog.String("stub_id", id))
+				}
+			}
+			logger.LogAttrs(ctx, level, "HTTP request", attrs...)
+			return nil
+		}
+	}
+}
+
+func newRequestID() string {
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
@@ -0,0 +1,269 @@
+// Package logging sets up the structured logs of the server: log/slog
+// records written as text or JSON, a logger per subsystem whose level can be
+// raised or lowered on its own, and request-scoped fields carried by the
+// context of a request.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// Formats of the records.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// SubsystemKey is the field naming the subsystem that logged a record.
+const SubsystemKey = "subsystem"
+
+// Config selects what is logged and how.
+type Config struct {
+	// Level is the minimum level logged: debug, info (default), warn or
+	// error (also LOG_LEVEL).
+	Level string `json:"level,omitempty"`
+	// Format is text (default) or json (also LOG_FORMAT).
+	Format string `json:"format,omitempty"`
+	// Subsystems overrides the level of some subsystems, such as
+	// {"websocket": "debug"} (also LOG_LEVELS=websocket=debug,grpc=warn).
+	Subsystems map[string]string `json:"subsystems,omitempty"`
+}
+
+func (c Config) Validate() error {
+	if _, err := ParseLevel(c.Level); err != nil {
+		return err
+	}
+	switch c.Format {
+	case "", FormatText, FormatJSON:
+	default:
+		return fmt.Errorf("unknown log format %q (want text or json)", c.Format)
+	}
+	for name, level := range c.Subsystems {
+		if _, err := ParseLevel(level); err != nil {
+			return fmt.Errorf("subsystem %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// ParseLevel parses debug, info, warn or error; "" is info.
+func ParseLevel(level string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(level)) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return 0, fmt.Errorf("unknown log level %q (want debug, info, warn or error)", level)
+}
+
+// ParseSubsystems parses the LOG_LEVELS form of Config.Subsystems:
+// comma-separated subsystem=level pairs.
+func ParseSubsystems(spec string) (map[string]string, error) {
+	levels := make(map[string]string)
+	for _, pair := range strings.Split(spec, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		name, level, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid subsystem level %q (want subsystem=level)", pair)
+		}
+		levels[strings.TrimSpace(name)] = strings.TrimSpace(level)
+	}
+	return levels, nil
+}
+
+// state is the configuration in effect. base writes every record it is
+// given; the levels are checked by the subsystem handlers.
+type state struct {
+	base   slog.Handler
+	out    io.Writer
+	text   bool
+	level  slog.Level
+	levels map[string]slog.Level
+}
+
+func (s *state) levelOf(subsystem string) slog.Level {
+	if level, ok := s.levels[subsystem]; ok {
+		return level
+	}
+	return s.level
+}
+
+var current atomic.Pointer[state]
+
+func init() {
+	current.Store(newState(os.Stderr, false, slog.LevelInfo, nil))
+}
+
+func newState(w io.Writer, json bool, level slog.Level, levels map[string]slog.Level) *state {
+	lowest := level
+	for _, l := range levels {
+		lowest = min(lowest, l)
+	}
+	opts := &slog.HandlerOptions{Level: lowest}
+	s := &state{out: w, text: !json, level: level, levels: levels}
+	if json {
+		s.base = slog.NewJSONHandler(w, opts)
+	} else {
+		s.base = slog.NewTextHandler(w, opts)
+	}
+	return s
+}
+
+// Setup applies cfg, which must be valid, to every logger, writing to w. It
+// also makes it the slog default, through which the standard log package
+// then writes at info level.
+func Setup(cfg Config, w io.Writer) {
+	level, _ := ParseLevel(cfg.Level)
+	var levels map[string]slog.Level
+	if len(cfg.Subsystems) > 0 {
+		levels = make(map[string]slog.Level, len(cfg.Subsystems))
+		for name, l := range cfg.Subsystems {
+			levels[name], _ = ParseLevel(l)
+		}
+	}
+	current.Store(newState(w, cfg.Format == FormatJSON, level, levels))
+	slog.SetDefault(slog.New(&handler{}))
+}
+
+// Describe summarizes cfg for the startup logs, such as "info, text" or
+// "warn, json, websocket=debug".
+func Describe(cfg Config) string {
+	level, _ := ParseLevel(cfg.Level)
+	parts := []string{strings.ToLower(level.String()), FormatText}
+	if cfg.Format == FormatJSON {
+		parts[1] = FormatJSON
+	}
+	names := make([]string, 0, len(cfg.Subsystems))
+	for name := range cfg.Subsystems {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		l, _ := ParseLevel(cfg.Subsystems[name])
+		parts = append(parts, name+"="+strings.ToLower(l.String()))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// For returns the logger of a subsystem, whose records carry it as
+// SubsystemKey. Loggers can be created before Setup, as package variables:
+// they follow the configuration in effect when they log.
+func For(subsystem string) *slog.Logger {
+	return slog.New(&handler{subsystem: subsystem})
+}
+
+// Banner returns the logger of the startup banner, the human-readable
+// listing of the listeners and endpoints. It writes in text format at info
+// level only; JSON consumers get the structured startup records instead.
+func Banner() *log.Logger {
+	s := current.Load()
+	if !s.text || s.level > slog.LevelInfo {
+		return log.New(io.Discard, "", 0)
+	}
+	return log.New(s.out, "", log.LstdFlags)
+}
+
+type fieldsKey struct{}
+
+// With returns a copy of ctx whose records carry args as fields, after
+// those ctx already carries. Args are key-value pairs or slog.Attr values,
+// as for slog.Logger.With.
+func With(ctx context.Context, args ...any) context.Context {
+	var r slog.Record
+	r.Add(args...)
+	fields := append([]slog.Attr(nil), Fields(ctx)...)
+	r.Attrs(func(a slog.Attr) bool {
+		fields = append(fields, a)
+		return true
+	})
+	return context.WithValue(ctx, fieldsKey{}, fields)
+}
+
+// Fields returns the request-scoped fields of ctx.
+func Fields(ctx context.Context) []slog.Attr {
+	if ctx == nil {
+		return nil
+	}
+	fields, _ := ctx.Value(fieldsKey{}).([]slog.Attr)
+	return fields
+}
+
+// Bind returns l with the request-scoped fields of ctx, for what outlives
+// the request, such as the connection it upgraded.
+func Bind(ctx context.Context, l *slog.Logger) *slog.Logger {
+	fields := Fields(ctx)
+	if len(fields) == 0 {
+		return l
+	}
+	args := make([]any, len(fields))
+	for i, field := range fields {
+		args[i] = field
+	}
+	return l.With(args...)
+}
+
+// handler logs through the configuration in effect, adding the subsystem
+// and the fields of the context. The handler derived from the base one is
+// cached until the configuration changes.
+type handler struct {
+	subsystem string
+	wrap      []func(slog.Handler) slog.Handler
+	built     atomic.Pointer[built]
+}
+
+type built struct {
+	state   *state
+	handler slog.Handler
+}
+
+func (h *handler) resolve() slog.Handler {
+	s := current.Load()
+	if b := h.built.Load(); b != nil && b.state == s {
+		return b.handler
+	}
+	out := s.base
+	if h.subsystem != "" {
+		out = out.WithAttrs([]slog.Attr{slog.String(SubsystemKey, h.subsystem)})
+	}
+	for _, wrap := range h.wrap {
+		out = wrap(out)
+	}
+	h.built.Store(&built{state: s, handler: out})
+	return out
+}
+
+func (h *handler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= current.Load().levelOf(h.subsystem)
+}
+
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	r.AddAttrs(Fields(ctx)...)
+	return h.resolve().Handle(ctx, r)
+}
+
+func (h *handler) with(wrap func(slog.Handler) slog.Handler) *handler {
+	return &handler{subsystem: h.subsystem, wrap: append(h.wrap[:len(h.wrap):len(h.wrap)], wrap)}
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.with(func(out slog.Handler) slog.Handler { return out.WithAttrs(attrs) })
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	return h.with(func(out slog.Handler) slog.Handler { return out.WithGroup(name) })
+}
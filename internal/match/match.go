@@ -0,0 +1,151 @@
+// Package match implements the field-level matchers shared by the stub engines.
+//
+// Documents are the generic values produced by encoding/json (maps, slices,
+// strings, float64, bool and nil). Fields are addressed with dotted paths such
+// as "user.address.city" or "items.0.id".
+package match
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// FieldMatcher describes a single condition on a document field. Exactly one of
+// Equals, Regex or Present is normally set; when several are set all of them
+// must hold.
+type FieldMatcher struct {
+	Field   string      `json:"field"`
+	Equals  interface{} `json:"equals,omitempty"`
+	Regex   string      `json:"regex,omitempty"`
+	Present *bool       `json:"present,omitempty"`
+
+	path  []string
+	regex *regexp.Regexp
+}
+
+// Compile validates the matcher and precompiles its field path and regular
+// expression. It must be called before Matches.
+func (m *FieldMatcher) Compile() error {
+	if m.Field == "" {
+		return fmt.Errorf("matcher is missing a field path")
+	}
+	m.path = strings.Split(m.Field, ".")
+	if m.Regex != "" {
+		re, err := regexp.Compile(m.Regex)
+		if err != nil {
+			return fmt.Errorf("field %q: invalid regex: %w", m.Field, err)
+		}
+		m.regex = re
+	}
+	return nil
+}
+
+// Matches reports whether the document satisfies the matcher.
+func (m *FieldMatcher) Matches(doc interface{}) bool {
+	value, found := lookupPath(doc, m.path)
+
+	if m.Present != nil && *m.Present != found {
+		return false
+	}
+	if m.Equals != nil && (!found || !Equal(value, m.Equals)) {
+		return false
+	}
+	if m.Regex != "" {
+		if !found || m.regex == nil || !m.regex.MatchString(Stringify(value)) {
+			return false
+		}
+	}
+	return true
+}
+
+// All reports whether every matcher is satisfied by the document.
+func All(matchers []FieldMatcher, doc interface{}) bool {
+	for i := range matchers {
+		if !matchers[i].Matches(doc) {
+			return false
+		}
+	}
+	return true
+}
+
+// Lookup resolves a dotted path inside a document.
+func Lookup(doc interface{}, path string) (interface{}, bool) {
+	return lookupPath(doc, strings.Split(path, "."))
+}
+
+func lookupPath(doc interface{}, path []string) (interface{}, bool) {
+	current := doc
+	for _, part := range path {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			next, ok := node[part]
+			if !ok {
+				return nil, false
+			}
+			current = next
+		case []interface{}:
+			index, err := strconv.Atoi(part)
+			if err != nil || index < 0 || index >= len(node) {
+				return nil, false
+			}
+			current = node[index]
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// Equal compares two document values. Scalars are compared by their string
+// form so that 42, 42.0 and "42" (protojson renders 64-bit integers as
+// strings) are considered equal.
+func Equal(actual, expected interface{}) bool {
+	// Same-typed scalars are compared directly: matching against large stub
+	// sets evaluates this for every candidate.
+	switch a := actual.(type) {
+	case string:
+		if e, ok := expected.(string); ok {
+			return a == e
+		}
+	case float64:
+		if e, ok := expected.(float64); ok {
+			return a == e
+		}
+	case bool:
+		if e, ok := expected.(bool); ok {
+			return a == e
+		}
+	}
+	if reflect.DeepEqual(actual, expected) {
+		return true
+	}
+	if isScalar(actual) && isScalar(expected) {
+		return Stringify(actual) == Stringify(expected)
+	}
+	return false
+}
+
+// Stringify renders a scalar document value without JSON quoting.
+func Stringify(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func isScalar(value interface{}) bool {
+	switch value.(type) {
+	case string, float64, bool, int, int32, int64:
+		return true
+	}
+	return false
+}
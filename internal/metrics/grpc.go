@@ -0,0 +1,140 @@
+package metrics
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type grpcSeries struct {
+	streamType string
+	codes      map[codes.Code]uint64
+	received   uint64
+	sent       uint64
+	latency    *Histogram
+}
+
+// GRPCMetrics counts the gRPC calls handled, by method and status code, the
+// messages exchanged and the latency of the calls by method.
+type GRPCMetrics struct {
+	methods map[string]*grpcSeries
+	mutex   sync.Mutex
+}
+
+func NewGRPCMetrics() *GRPCMetrics {
+	return &GRPCMetrics{methods: make(map[string]*grpcSeries)}
+}
+
+// UnaryInterceptor measures unary calls.
+func (m *GRPCMetrics) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		sent := uint64(0)
+		if err == nil {
+			sent = 1
+		}
+		m.observe(info.FullMethod, "unary", err, 1, sent, time.Since(start))
+		return resp, err
+	}
+}
+
+// StreamInterceptor measures streaming calls, counting their messages.
+func (m *GRPCMetrics) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		streamType := "server_stream"
+		switch {
+		case info.IsClientStream && info.IsServerStream:
+			streamType = "bidi_stream"
+		case info.IsClientStream:
+			streamType = "client_stream"
+		}
+		counting := &countingStream{ServerStream: ss}
+		err := handler(srv, counting)
+		m.observe(info.FullMethod, streamType, err, counting.received, counting.sent, time.Since(start))
+		return err
+	}
+}
+
+func (m *GRPCMetrics) observe(method, streamType string, err error, received, sent uint64, latency time.Duration) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	s, ok := m.methods[method]
+	if !ok {
+		s = &grpcSeries{streamType: streamType, codes: make(map[codes.Code]uint64), latency: NewHistogram(LatencyBuckets...)}
+		m.methods[method] = s
+	}
+	s.codes[status.Code(err)]++
+	s.received += received
+	s.sent += sent
+	s.latency.Observe(latency.Seconds())
+}
+
+// countingStream counts the messages of a stream; a stream is used by one
+// goroutine per direction.
+type countingStream struct {
+	grpc.ServerStream
+	received uint64
+	sent     uint64
+}
+
+func (s *countingStream) RecvMsg(msg interface{}) error {
+	err := s.ServerStream.RecvMsg(msg)
+	if err == nil {
+		s.received++
+	}
+	return err
+}
+
+func (s *countingStream) SendMsg(msg interface{}) error {
+	err := s.ServerStream.SendMsg(msg)
+	if err == nil {
+		s.sent++
+	}
+	return err
+}
+
+// Collect writes the call counts, the message counts and the latency
+// histograms.
+func (m *GRPCMetrics) Collect(w *Writer) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	methods := make([]string, 0, len(m.methods))
+	for method := range m.methods {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+
+	w.Family("mockserver_grpc_requests_total", "counter", "gRPC calls handled, by method, type and status code.")
+	for _, method := range methods {
+		s := m.methods[method]
+		calls := make([]codes.Code, 0, len(s.codes))
+		for code := range s.codes {
+			calls = append(calls, code)
+		}
+		sort.Slice(calls, func(i, j int) bool { return calls[i] < calls[j] })
+		for _, code := range calls {
+			w.Sample("mockserver_grpc_requests_total", float64(s.codes[code]),
+				"method", method, "type", s.streamType, "code", code.String())
+		}
+	}
+	w.Family("mockserver_grpc_messages_received_total", "counter", "gRPC messages received from clients, by method.")
+	for _, method := range methods {
+		w.Sample("mockserver_grpc_messages_received_total", float64(m.methods[method].received), "method", method)
+	}
+	w.Family("mockserver_grpc_messages_sent_total", "counter", "gRPC messages sent to clients, by method.")
+	for _, method := range methods {
+		w.Sample("mockserver_grpc_messages_sent_total", float64(m.methods[method].sent), "method", method)
+	}
+	w.Family("mockserver_grpc_request_duration_seconds", "histogram", "Time taken to handle the gRPC calls, by method.")
+	for _, method := range methods {
+		m.methods[method].latency.Write(w, "mockserver_grpc_request_duration_seconds", "method", method)
+	}
+}
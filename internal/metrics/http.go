@@ -0,0 +1,125 @@
+package metrics
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"mockserver/internal/journal"
+)
+
+// LatencyBuckets are the bounds, in seconds, of the request latency
+// histograms.
+var LatencyBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// unmatchedRoute labels the requests no route or stub answered, so that
+// scanning unknown paths cannot blow up the number of series.
+const unmatchedRoute = "unmatched"
+
+type httpKey struct {
+	method, route string
+}
+
+type httpSeries struct {
+	statuses map[int]uint64
+	latency  *Histogram
+}
+
+// HTTPMetrics counts the HTTP requests served, by method, route and status,
+// and their latency by method and route. The route is the echo route
+// pattern ("/__admin/stubs/:id"), or "stub:<id>" for a response of a stub.
+type HTTPMetrics struct {
+	series   map[httpKey]*httpSeries
+	inflight int64
+	mutex    sync.Mutex
+}
+
+func NewHTTPMetrics() *HTTPMetrics {
+	return &HTTPMetrics{series: make(map[httpKey]*httpSeries)}
+}
+
+// Middleware measures every request but the metrics scrapes. It lets echo
+// write the error responses so that the final status is counted.
+func (m *HTTPMetrics) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if c.Request().URL.Path == Path {
+				return next(c)
+			}
+			start := time.Now()
+			m.mutex.Lock()
+			m.inflight++
+			m.mutex.Unlock()
+
+			if err := next(c); err != nil {
+				c.Error(err)
+			}
+			m.observe(c, time.Since(start))
+			return nil
+		}
+	}
+}
+
+func (m *HTTPMetrics) observe(c echo.Context, latency time.Duration) {
+	route := c.Path()
+	if id := journal.MatchedStubID(c); id != "" {
+		route = "stub:" + id
+	} else if route == "" || strings.HasSuffix(route, "/*") && c.Response().Status == http.StatusNotFound {
+		route = unmatchedRoute
+	}
+	key := httpKey{method: c.Request().Method, route: route}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.inflight--
+	s, ok := m.series[key]
+	if !ok {
+		s = &httpSeries{statuses: make(map[int]uint64), latency: NewHistogram(LatencyBuckets...)}
+		m.series[key] = s
+	}
+	s.statuses[c.Response().Status]++
+	s.latency.Observe(latency.Seconds())
+}
+
+// Collect writes the request counts, the requests in flight and the latency
+// histograms.
+func (m *HTTPMetrics) Collect(w *Writer) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	keys := make([]httpKey, 0, len(m.series))
+	for key := range m.series {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].route != keys[j].route {
+			return keys[i].route < keys[j].route
+		}
+		return keys[i].method < keys[j].method
+	})
+
+	w.Family("mockserver_http_requests_total", "counter", "HTTP requests served, by method, route and status.")
+	for _, key := range keys {
+		statuses := m.series[key].statuses
+		codes := make([]int, 0, len(statuses))
+		for code := range statuses {
+			codes = append(codes, code)
+		}
+		sort.Ints(codes)
+		for _, code := range codes {
+			w.Sample("mockserver_http_requests_total", float64(statuses[code]),
+				"method", key.method, "route", key.route, "status", strconv.Itoa(code))
+		}
+	}
+	w.Family("mockserver_http_requests_in_flight", "gauge", "HTTP requests being served.")
+	w.Sample("mockserver_http_requests_in_flight", float64(m.inflight))
+	w.Family("mockserver_http_request_duration_seconds", "histogram", "Time taken to serve the HTTP requests, by method and route.")
+	for _, key := range keys {
+		m.series[key].latency.Write(w, "mockserver_http_request_duration_seconds", "method", key.method, "route", key.route)
+	}
+}
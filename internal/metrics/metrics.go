@@ -0,0 +1,147 @@
+// Package metrics exposes the server's own metrics in the Prometheus text
+// format. Subsystems keep their counters and render them on each scrape
+// through a Collector.
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Path is where the metrics are served.
+const Path = "/metrics"
+
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Collector writes the metrics of a subsystem.
+type Collector interface {
+	Collect(w *Writer)
+}
+
+// Registry holds the collectors rendered on a scrape.
+type Registry struct {
+	collectors []Collector
+	mutex      sync.Mutex
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Register adds a collector.
+func (r *Registry) Register(c Collector) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.collectors = append(r.collectors, c)
+}
+
+// Handler serves the metrics of every collector.
+func (r *Registry) Handler(c echo.Context) error {
+	r.mutex.Lock()
+	collectors := append([]Collector(nil), r.collectors...)
+	r.mutex.Unlock()
+
+	w := &Writer{}
+	for _, collector := range collectors {
+		collector.Collect(w)
+	}
+	return c.Blob(http.StatusOK, contentType, w.buf.Bytes())
+}
+
+// Writer renders metric families in the text exposition format.
+type Writer struct {
+	buf bytes.Buffer
+}
+
+// Family starts a metric family; kind is counter, gauge or histogram.
+func (w *Writer) Family(name, kind, help string) {
+	fmt.Fprintf(&w.buf, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// Sample writes one sample; labels alternate names and values.
+func (w *Writer) Sample(name string, value float64, labels ...string) {
+	w.buf.WriteString(name)
+	if len(labels) > 0 {
+		w.buf.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				w.buf.WriteByte(',')
+			}
+			fmt.Fprintf(&w.buf, "%s=\"%s\"", labels[i], escapeLabel(labels[i+1]))
+		}
+		w.buf.WriteByte('}')
+	}
+	w.buf.WriteByte(' ')
+	w.buf.WriteString(formatValue(value))
+	w.buf.WriteByte('\n')
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// Histogram counts observations into cumulative buckets.
+type Histogram struct {
+	bounds []float64
+	counts []uint64
+	sum    float64
+	count  uint64
+	mutex  sync.Mutex
+}
+
+// NewHistogram returns a histogram with the given ascending upper bounds;
+// the +Inf bucket is implied.
+func NewHistogram(bounds ...float64) *Histogram {
+	return &Histogram{bounds: bounds, counts: make([]uint64, len(bounds))}
+}
+
+// Observe records a value.
+func (h *Histogram) Observe(value float64) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	for i, bound := range h.bounds {
+		if value <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += value
+	h.count++
+}
+
+// Write renders the histogram's samples under name (the family header is
+// the caller's).
+func (h *Histogram) Write(w *Writer, name string, labels ...string) {
+	h.mutex.Lock()
+	counts := append([]uint64(nil), h.counts...)
+	sum, count := h.sum, h.count
+	h.mutex.Unlock()
+
+	bucket := func(le string) []string {
+		return append(append(make([]string, 0, len(labels)+2), labels...), "le", le)
+	}
+	for i, bound := range h.bounds {
+		w.Sample(name+"_bucket", float64(counts[i]), bucket(formatValue(bound))...)
+	}
+	w.Sample(name+"_bucket", float64(count), bucket("+Inf")...)
+	w.Sample(name+"_sum", sum, labels...)
+	w.Sample(name+"_count", float64(count), labels...)
+}
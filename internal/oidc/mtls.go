@@ -0,0 +1,105 @@
+package oidc
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"net/url"
+
+	"github.com/labstack/echo/v4"
+
+	"mockserver/internal/proxy"
+)
+
+// MTLSConfig binds access tokens to the client certificate presented at the
+// token endpoint (RFC 8705): the tokens carry its thumbprint in
+// cnf.x5t#S256, and the resource endpoints only accept them with the same
+// certificate.
+type MTLSConfig struct {
+	// CertFile and KeyFile are the certificate of the mTLS listener
+	// (OIDC_MTLS_ADDR); a self-signed one is generated by default.
+	CertFile string `json:"cert_file,omitempty"`
+	KeyFile  string `json:"key_file,omitempty"`
+	// CertificateHeader is the header a TLS-terminating proxy passes the
+	// client certificate in, as URL-encoded PEM (nginx's
+	// $ssl_client_escaped_cert). Only read when set.
+	CertificateHeader string `json:"certificate_header,omitempty"`
+}
+
+// Validate checks that the certificate comes with its key.
+func (c MTLSConfig) Validate() error {
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return fmt.Errorf("mtls: cert_file and key_file go together")
+	}
+	return nil
+}
+
+// TLSConfig is the configuration of the mTLS listener. Clients are asked for
+// a certificate and any is accepted, self-signed ones included: binding a
+// token only takes its thumbprint.
+func (p *Provider) TLSConfig() (*tls.Config, error) {
+	var config *tls.Config
+	if p.config.MTLS.CertFile != "" {
+		pair, err := tls.LoadX509KeyPair(p.config.MTLS.CertFile, p.config.MTLS.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("mtls: %w", err)
+		}
+		config = &tls.Config{Certificates: []tls.Certificate{pair}}
+	} else {
+		ca, err := proxy.GenerateCA("Mock Server OIDC")
+		if err != nil {
+			return nil, fmt.Errorf("mtls: %w", err)
+		}
+		config = ca.TLSConfig()
+	}
+	config.ClientAuth = tls.RequestClientCert
+	return config, nil
+}
+
+// SetMTLSURL publishes the base URL of the mTLS listener in the discovery
+// document (mtls_endpoint_aliases).
+func (p *Provider) SetMTLSURL(base string) {
+	p.mtlsURL = base
+}
+
+// clientCertificate returns the certificate the client presented over TLS,
+// or in the certificate header; nil without one.
+func (p *Provider) clientCertificate(c echo.Context) (*x509.Certificate, error) {
+	if state := c.Request().TLS; state != nil && len(state.PeerCertificates) > 0 {
+		return state.PeerCertificates[0], nil
+	}
+	if p.config.MTLS.CertificateHeader == "" {
+		return nil, nil
+	}
+	value := c.Request().Header.Get(p.config.MTLS.CertificateHeader)
+	if value == "" {
+		return nil, nil
+	}
+	decoded, err := url.QueryUnescape(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s header: %w", p.config.MTLS.CertificateHeader, err)
+	}
+	block, _ := pem.Decode([]byte(decoded))
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("invalid %s header: no PEM certificate", p.config.MTLS.CertificateHeader)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s header: %w", p.config.MTLS.CertificateHeader, err)
+	}
+	return cert, nil
+}
+
+// thumbprint returns the x5t#S256 of the certificate the request presents,
+// empty without one.
+func (p *Provider) thumbprint(c echo.Context) (string, error) {
+	cert, err := p.clientCertificate(c)
+	if cert == nil || err != nil {
+		return "", err
+	}
+	digest := sha256.Sum256(cert.Raw)
+	return base64.RawURLEncoding.EncodeToString(digest[:]), nil
+}
@@ -0,0 +1,219 @@
+// Package oidc is a mock OAuth 2.0 / OpenID Connect provider: discovery,
+// authorization code (with PKCE), refresh token, client credentials and
+// password grants, user info and the signing keys, enough to exercise the
+// login flows of an application without running a real identity provider.
+// Logins are approved without a login page. Access tokens can be bound to
+// the client certificate (RFC 8705) for FAPI-style clients.
+package oidc
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"mockserver/internal/jose"
+)
+
+// Grant types.
+const (
+	GrantAuthorizationCode = "authorization_code"
+	GrantRefreshToken      = "refresh_token"
+	GrantClientCredentials = "client_credentials"
+	GrantPassword          = "password"
+)
+
+// DefaultScopes are supported when the configuration lists none.
+var DefaultScopes = []string{"openid", "profile", "email", "offline_access"}
+
+// Config holds the provider settings from the configuration file.
+type Config struct {
+	Enabled bool `json:"enabled"`
+	// Issuer is the iss of the tokens and the base of the endpoints; by
+	// default the scheme and host the request was sent to.
+	Issuer string `json:"issuer,omitempty"`
+	// Key is the ID of the signing key (see http.keys); the generated RSA
+	// key by default.
+	Key string `json:"key,omitempty"`
+	// Scopes are the scopes clients may request.
+	Scopes []string `json:"scopes,omitempty"`
+	// Token lifetimes: access and ID tokens (default 1h), refresh tokens
+	// (default 24h) and authorization codes (default 1m).
+	AccessTokenTTL  string `json:"access_token_ttl,omitempty"`
+	IDTokenTTL      string `json:"id_token_ttl,omitempty"`
+	RefreshTokenTTL string `json:"refresh_token_ttl,omitempty"`
+	CodeTTL         string `json:"code_ttl,omitempty"`
+	// Clients are the registered clients. Without clients any client ID is
+	// accepted, with any secret and redirect URI.
+	Clients []Client `json:"clients,omitempty"`
+	// Users log in through /authorize (chosen by login_hint, the first one
+	// otherwise) and the password grant. Without users, a user "mock-user"
+	// with any password is assumed.
+	Users []User `json:"users,omitempty"`
+	// MTLS configures certificate-bound access tokens.
+	MTLS MTLSConfig `json:"mtls"`
+}
+
+// Client is a registered OAuth client. A client without secret is public
+// and must use PKCE for the authorization code grant. Empty RedirectURIs,
+// Scopes and GrantTypes allow any.
+type Client struct {
+	ID           string   `json:"id"`
+	Secret       string   `json:"secret,omitempty"`
+	RedirectURIs []string `json:"redirect_uris,omitempty"`
+	Scopes       []string `json:"scopes,omitempty"`
+	GrantTypes   []string `json:"grant_types,omitempty"`
+	// CertificateBound requires a client certificate at the token endpoint,
+	// so that every token of the client is bound to it.
+	CertificateBound bool `json:"tls_client_certificate_bound_access_tokens,omitempty"`
+}
+
+// User is an account of the provider. Claims are added to the ID token and
+// the user info: the standard profile and email claims when their scope was
+// granted, any other claim always.
+type User struct {
+	Subject  string                 `json:"subject"`
+	Username string                 `json:"username"`
+	Password string                 `json:"password,omitempty"`
+	Claims   map[string]interface{} `json:"claims,omitempty"`
+}
+
+// lifetimes are the parsed token lifetimes.
+type lifetimes struct {
+	access, id, refresh, code time.Duration
+}
+
+func (c Config) lifetimes() (lifetimes, error) {
+	l := lifetimes{access: time.Hour, id: time.Hour, refresh: 24 * time.Hour, code: time.Minute}
+	for _, field := range []struct {
+		name, value string
+		target      *time.Duration
+	}{
+		{"access_token_ttl", c.AccessTokenTTL, &l.access},
+		{"id_token_ttl", c.IDTokenTTL, &l.id},
+		{"refresh_token_ttl", c.RefreshTokenTTL, &l.refresh},
+		{"code_ttl", c.CodeTTL, &l.code},
+	} {
+		if field.value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(field.value)
+		if err != nil || d <= 0 {
+			return l, fmt.Errorf("invalid %s %q", field.name, field.value)
+		}
+		*field.target = d
+	}
+	return l, nil
+}
+
+// Validate checks the settings that do not depend on the keys.
+func (c Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if _, err := c.lifetimes(); err != nil {
+		return err
+	}
+	if err := c.MTLS.Validate(); err != nil {
+		return err
+	}
+	scopes := c.scopes()
+	clients := make(map[string]bool, len(c.Clients))
+	for _, client := range c.Clients {
+		if client.ID == "" {
+			return fmt.Errorf("client without id")
+		}
+		if clients[client.ID] {
+			return fmt.Errorf("client %q: duplicate id", client.ID)
+		}
+		clients[client.ID] = true
+		for _, scope := range client.Scopes {
+			if !slices.Contains(scopes, scope) {
+				return fmt.Errorf("client %q: unsupported scope %q", client.ID, scope)
+			}
+		}
+		for _, grant := range client.GrantTypes {
+			switch grant {
+			case GrantAuthorizationCode, GrantRefreshToken, GrantClientCredentials, GrantPassword:
+			default:
+				return fmt.Errorf("client %q: unknown grant type %q", client.ID, grant)
+			}
+		}
+	}
+	users := make(map[string]bool, len(c.Users))
+	for _, user := range c.Users {
+		if user.Subject == "" || user.Username == "" {
+			return fmt.Errorf("users need a subject and a username")
+		}
+		if users[user.Username] {
+			return fmt.Errorf("user %q: duplicate username", user.Username)
+		}
+		users[user.Username] = true
+	}
+	return nil
+}
+
+func (c Config) scopes() []string {
+	if len(c.Scopes) == 0 {
+		return DefaultScopes
+	}
+	return c.Scopes
+}
+
+// keyID returns the signing key, checking that it can sign.
+func (c Config) keyID(keys *jose.KeySet) (string, string, error) {
+	id := c.Key
+	if id == "" {
+		id = jose.DefaultRSAKey
+	}
+	key, err := keys.Lookup(id)
+	if err != nil {
+		return "", "", err
+	}
+	for _, info := range keys.Keys() {
+		if info.ID == id && !info.Private {
+			return "", "", fmt.Errorf("key %q has no private key to sign with", id)
+		}
+	}
+	return id, key.Algorithm, nil
+}
+
+// client returns the registered client, or an unregistered one accepted
+// as is when no client is configured.
+func (c Config) client(id string) (Client, bool) {
+	if len(c.Clients) == 0 {
+		return Client{ID: id}, id != ""
+	}
+	for _, client := range c.Clients {
+		if client.ID == id {
+			return client, true
+		}
+	}
+	return Client{}, false
+}
+
+// user finds a user by username or subject; an empty name picks the first.
+func (c Config) user(name string) (User, bool) {
+	if len(c.Users) == 0 {
+		if name == "" {
+			name = "mock-user"
+		}
+		return User{Subject: name, Username: name}, true
+	}
+	if name == "" {
+		return c.Users[0], true
+	}
+	for _, user := range c.Users {
+		if user.Username == name || user.Subject == name {
+			return user, true
+		}
+	}
+	return User{}, false
+}
+
+func (c Client) allows(grant string) bool {
+	return len(c.GrantTypes) == 0 || slices.Contains(c.GrantTypes, grant)
+}
+
+func (c Client) allowsRedirect(uri string) bool {
+	return len(c.RedirectURIs) == 0 || slices.Contains(c.RedirectURIs, uri)
+}
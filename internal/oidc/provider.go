@@ -0,0 +1,551 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"mockserver/internal/jose"
+	"mockserver/internal/logging"
+)
+
+var logger = logging.For("oidc")
+
+// Endpoint paths, relative to the issuer.
+const (
+	DiscoveryPath = "/.well-known/openid-configuration"
+	AuthorizePath = "/authorize"
+	TokenPath     = "/token"
+	UserInfoPath  = "/userinfo"
+	JWKSPath      = "/jwks"
+	// ResourcePath is a protected resource answering the claims of the
+	// access token, for testing how clients call APIs with it.
+	ResourcePath = "/resource"
+)
+
+// standardClaims are released only with their scope.
+var standardClaims = map[string][]string{
+	"profile": {"name", "family_name", "given_name", "middle_name", "nickname", "preferred_username",
+		"profile", "picture", "website", "gender", "birthdate", "zoneinfo", "locale", "updated_at"},
+	"email": {"email", "email_verified"},
+}
+
+// grant is what a code or token was issued for.
+type grant struct {
+	client    string
+	user      *User
+	scopes    []string
+	nonce     string
+	authTime  time.Time
+	expires   time.Time
+	challenge string
+	method    string
+	redirect  string
+	// thumbprint is the x5t#S256 of the certificate the tokens are bound
+	// to, empty when they are not.
+	thumbprint string
+}
+
+// Provider serves the OpenID Connect endpoints. Codes and tokens live in
+// memory until they expire.
+type Provider struct {
+	config    Config
+	keys      *jose.KeySet
+	keyID     string
+	algorithm string
+	ttl       lifetimes
+	mtlsURL   string
+
+	codes   map[string]*grant
+	access  map[string]*grant
+	refresh map[string]*grant
+	mutex   sync.Mutex
+}
+
+// New returns the provider, failing when the configuration or signing key
+// is invalid.
+func New(config Config, keys *jose.KeySet) (*Provider, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	ttl, _ := config.lifetimes()
+	keyID, algorithm, err := config.keyID(keys)
+	if err != nil {
+		return nil, err
+	}
+	return &Provider{
+		config:    config,
+		keys:      keys,
+		keyID:     keyID,
+		algorithm: algorithm,
+		ttl:       ttl,
+		codes:     make(map[string]*grant),
+		access:    make(map[string]*grant),
+		refresh:   make(map[string]*grant),
+	}, nil
+}
+
+// Register adds the endpoints to the server.
+func (p *Provider) Register(e *echo.Echo) {
+	e.GET(DiscoveryPath, p.Discovery)
+	e.GET(AuthorizePath, p.Authorize)
+	e.POST(TokenPath, p.Token)
+	e.GET(UserInfoPath, p.UserInfo)
+	e.POST(UserInfoPath, p.UserInfo)
+	e.GET(JWKSPath, p.JWKS)
+	e.GET(ResourcePath, p.Resource)
+	e.POST(ResourcePath, p.Resource)
+}
+
+func (p *Provider) issuer(c echo.Context) string {
+	if p.config.Issuer != "" {
+		return strings.TrimRight(p.config.Issuer, "/")
+	}
+	return c.Scheme() + "://" + c.Request().Host
+}
+
+// Discovery serves the provider metadata.
+func (p *Provider) Discovery(c echo.Context) error {
+	issuer := p.issuer(c)
+	grants := []string{GrantAuthorizationCode, GrantRefreshToken, GrantClientCredentials, GrantPassword}
+	metadata := map[string]interface{}{
+		"issuer":                                     issuer,
+		"authorization_endpoint":                     issuer + AuthorizePath,
+		"token_endpoint":                             issuer + TokenPath,
+		"userinfo_endpoint":                          issuer + UserInfoPath,
+		"jwks_uri":                                   issuer + JWKSPath,
+		"scopes_supported":                           p.config.scopes(),
+		"response_types_supported":                   []string{"code"},
+		"response_modes_supported":                   []string{"query"},
+		"grant_types_supported":                      grants,
+		"subject_types_supported":                    []string{"public"},
+		"id_token_signing_alg_values_supported":      []string{p.algorithm},
+		"token_endpoint_auth_methods_supported":      []string{"client_secret_basic", "client_secret_post", "none"},
+		"code_challenge_methods_supported":           []string{"S256", "plain"},
+		"claims_supported":                           []string{"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "name", "preferred_username", "email", "email_verified"},
+		"tls_client_certificate_bound_access_tokens": true,
+	}
+	if p.mtlsURL != "" {
+		metadata["mtls_endpoint_aliases"] = map[string]string{
+			"token_endpoint":    p.mtlsURL + TokenPath,
+			"userinfo_endpoint": p.mtlsURL + UserInfoPath,
+		}
+	}
+	return c.JSON(http.StatusOK, metadata)
+}
+
+// JWKS serves the public keys tokens are signed with.
+func (p *Provider) JWKS(c echo.Context) error {
+	return c.JSON(http.StatusOK, p.keys.JWKS())
+}
+
+// Authorize approves the request right away for the user named by
+// login_hint (the first user otherwise) and redirects back with a code.
+func (p *Provider) Authorize(c echo.Context) error {
+	query := c.Request().URL.Query()
+	client, ok := p.config.client(query.Get("client_id"))
+	if !ok {
+		return oauthError(c, http.StatusBadRequest, "invalid_client", "unknown client_id")
+	}
+	redirect := query.Get("redirect_uri")
+	if redirect == "" && len(client.RedirectURIs) == 1 {
+		redirect = client.RedirectURIs[0]
+	}
+	target, err := url.Parse(redirect)
+	if redirect == "" || err != nil || !target.IsAbs() || !client.allowsRedirect(redirect) {
+		return oauthError(c, http.StatusBadRequest, "invalid_request", "redirect_uri is missing or not registered")
+	}
+
+	// Past this point errors go back to the client
+	state := query.Get("state")
+	fail := func(code, description string) error {
+		return redirectTo(c, target, url.Values{"error": {code}, "error_description": {description}, "state": {state}})
+	}
+	if query.Get("response_type") != "code" {
+		return fail("unsupported_response_type", "only the code response type is supported")
+	}
+	if !client.allows(GrantAuthorizationCode) {
+		return fail("unauthorized_client", "the client may not use the authorization code grant")
+	}
+	scopes, bad := p.scopes(client, query.Get("scope"))
+	if bad != "" {
+		return fail("invalid_scope", "unsupported scope "+bad)
+	}
+	challenge, method := query.Get("code_challenge"), query.Get("code_challenge_method")
+	if method == "" && challenge != "" {
+		method = "plain"
+	}
+	if method != "" && method != "S256" && method != "plain" {
+		return fail("invalid_request", "unsupported code_challenge_method")
+	}
+	if client.Secret == "" && len(p.config.Clients) > 0 && challenge == "" {
+		return fail("invalid_request", "public clients must use PKCE")
+	}
+	user, ok := p.config.user(query.Get("login_hint"))
+	if !ok {
+		return fail("access_denied", "unknown user "+query.Get("login_hint"))
+	}
+
+	now := time.Now()
+	code := p.store(p.codes, &grant{
+		client:    client.ID,
+		user:      &user,
+		scopes:    scopes,
+		nonce:     query.Get("nonce"),
+		authTime:  now,
+		expires:   now.Add(p.ttl.code),
+		challenge: challenge,
+		method:    method,
+		redirect:  query.Get("redirect_uri"),
+	})
+	logger.DebugContext(c.Request().Context(), "Authorized", "username", user.Username, "client_id", client.ID, "scopes", strings.Join(scopes, " "))
+	return redirectTo(c, target, url.Values{"code": {code}, "state": {state}})
+}
+
+// Token exchanges a grant for tokens.
+func (p *Provider) Token(c echo.Context) error {
+	form, err := c.FormParams()
+	if err != nil {
+		return oauthError(c, http.StatusBadRequest, "invalid_request", err.Error())
+	}
+	client, ok := p.authenticate(c, form)
+	if !ok {
+		c.Response().Header().Set("WWW-Authenticate", `Basic realm="oidc"`)
+		return oauthError(c, http.StatusUnauthorized, "invalid_client", "client authentication failed")
+	}
+	grantType := form.Get("grant_type")
+	if !client.allows(grantType) || (grantType == GrantClientCredentials && client.Secret == "" && len(p.config.Clients) > 0) {
+		return oauthError(c, http.StatusBadRequest, "unauthorized_client", "the client may not use the "+grantType+" grant")
+	}
+	thumbprint, err := p.thumbprint(c)
+	if err != nil {
+		return oauthError(c, http.StatusBadRequest, "invalid_request", err.Error())
+	}
+	if client.CertificateBound && thumbprint == "" {
+		return oauthError(c, http.StatusBadRequest, "invalid_request", "the client must present a certificate")
+	}
+
+	now := time.Now()
+	var g *grant
+	switch grantType {
+	case GrantAuthorizationCode:
+		g = p.take(p.codes, form.Get("code"))
+		switch {
+		case g == nil || g.client != client.ID:
+			return oauthError(c, http.StatusBadRequest, "invalid_grant", "unknown or expired code")
+		case g.redirect != "" && g.redirect != form.Get("redirect_uri"):
+			return oauthError(c, http.StatusBadRequest, "invalid_grant", "redirect_uri does not match the authorization request")
+		case !verifyChallenge(g.challenge, g.method, form.Get("code_verifier")):
+			return oauthError(c, http.StatusBadRequest, "invalid_grant", "code_verifier does not match the code_challenge")
+		}
+	case GrantRefreshToken:
+		// Refresh tokens are rotated
+		g = p.take(p.refresh, form.Get("refresh_token"))
+		if g == nil || g.client != client.ID {
+			return oauthError(c, http.StatusBadRequest, "invalid_grant", "unknown or expired refresh_token")
+		}
+		if g.thumbprint != "" && g.thumbprint != thumbprint {
+			return oauthError(c, http.StatusBadRequest, "invalid_grant", "refresh_token is bound to another client certificate")
+		}
+		if requested := form.Get("scope"); requested != "" {
+			scopes := strings.Fields(requested)
+			for _, scope := range scopes {
+				if !slices.Contains(g.scopes, scope) {
+					return oauthError(c, http.StatusBadRequest, "invalid_scope", "scope "+scope+" was not granted")
+				}
+			}
+			g = &grant{client: g.client, user: g.user, scopes: scopes, authTime: g.authTime}
+		}
+	case GrantClientCredentials, GrantPassword:
+		scopes, bad := p.scopes(client, form.Get("scope"))
+		if bad != "" {
+			return oauthError(c, http.StatusBadRequest, "invalid_scope", "unsupported scope "+bad)
+		}
+		g = &grant{client: client.ID, scopes: scopes, authTime: now}
+		if grantType == GrantPassword {
+			user, ok := p.config.user(form.Get("username"))
+			if !ok || form.Get("username") == "" || (user.Password != "" && user.Password != form.Get("password")) {
+				return oauthError(c, http.StatusBadRequest, "invalid_grant", "invalid username or password")
+			}
+			g.user = &user
+		}
+	default:
+		return oauthError(c, http.StatusBadRequest, "unsupported_grant_type", "unsupported grant_type "+grantType)
+	}
+
+	issuer := p.issuer(c)
+	subject := client.ID
+	if g.user != nil {
+		subject = g.user.Subject
+	}
+	accessClaims := map[string]interface{}{
+		"iss":       issuer,
+		"sub":       subject,
+		"aud":       client.ID,
+		"client_id": client.ID,
+		"scope":     strings.Join(g.scopes, " "),
+		"iat":       now.Unix(),
+		"exp":       now.Add(p.ttl.access).Unix(),
+		"jti":       randomToken(),
+	}
+	if thumbprint != "" {
+		accessClaims["cnf"] = map[string]string{"x5t#S256": thumbprint}
+	}
+	accessToken, err := p.sign(accessClaims)
+	if err != nil {
+		return oauthError(c, http.StatusInternalServerError, "server_error", err.Error())
+	}
+	p.put(p.access, accessToken, &grant{client: client.ID, user: g.user, scopes: g.scopes, authTime: g.authTime, expires: now.Add(p.ttl.access), thumbprint: thumbprint})
+
+	response := map[string]interface{}{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   int(p.ttl.access.Seconds()),
+		"scope":        strings.Join(g.scopes, " "),
+	}
+	if g.user != nil && slices.Contains(g.scopes, "openid") {
+		idClaims := p.userClaims(g.user, g.scopes)
+		idClaims["iss"] = issuer
+		idClaims["aud"] = client.ID
+		idClaims["azp"] = client.ID
+		idClaims["iat"] = now.Unix()
+		idClaims["exp"] = now.Add(p.ttl.id).Unix()
+		idClaims["auth_time"] = g.authTime.Unix()
+		if g.nonce != "" {
+			idClaims["nonce"] = g.nonce
+		}
+		idToken, err := p.sign(idClaims)
+		if err != nil {
+			return oauthError(c, http.StatusInternalServerError, "server_error", err.Error())
+		}
+		response["id_token"] = idToken
+	}
+	if g.user != nil && client.allows(GrantRefreshToken) {
+		response["refresh_token"] = p.store(p.refresh, &grant{client: client.ID, user: g.user, scopes: g.scopes, authTime: g.authTime, expires: now.Add(p.ttl.refresh), thumbprint: thumbprint})
+	}
+	logger.DebugContext(c.Request().Context(), "Tokens issued", "client_id", client.ID, "subject", subject, "grant_type", grantType, "certificate_bound", thumbprint != "")
+
+	c.Response().Header().Set("Cache-Control", "no-store")
+	return c.JSON(http.StatusOK, response)
+}
+
+// UserInfo returns the claims of the user an access token was issued for.
+func (p *Provider) UserInfo(c echo.Context) error {
+	g, problem := p.accessGrant(c)
+	if g != nil && g.user == nil {
+		g, problem = nil, "the access token was issued to a client"
+	}
+	if g == nil {
+		return invalidToken(c, problem)
+	}
+	return c.JSON(http.StatusOK, p.userClaims(g.user, g.scopes))
+}
+
+// Resource answers the claims of the access token, once checked like a
+// resource server would, including its certificate binding.
+func (p *Provider) Resource(c echo.Context) error {
+	g, problem := p.accessGrant(c)
+	if g == nil {
+		return invalidToken(c, problem)
+	}
+	claims := map[string]interface{}{
+		"active":    true,
+		"client_id": g.client,
+		"sub":       g.client,
+		"scope":     strings.Join(g.scopes, " "),
+		"exp":       g.expires.Unix(),
+	}
+	if g.user != nil {
+		claims["sub"] = g.user.Subject
+	}
+	if g.thumbprint != "" {
+		claims["cnf"] = map[string]string{"x5t#S256": g.thumbprint}
+	}
+	return c.JSON(http.StatusOK, claims)
+}
+
+// accessGrant returns the grant of the request's access token, or nil with
+// the problem: unknown, expired, or bound to a certificate the request does
+// not present.
+func (p *Provider) accessGrant(c echo.Context) (*grant, string) {
+	token, ok := strings.CutPrefix(c.Request().Header.Get(echo.HeaderAuthorization), "Bearer ")
+	if !ok {
+		token = c.FormValue("access_token")
+	}
+	p.mutex.Lock()
+	g := p.access[token]
+	if g != nil && time.Now().After(g.expires) {
+		delete(p.access, token)
+		g = nil
+	}
+	p.mutex.Unlock()
+	if g == nil {
+		return nil, "unknown or expired access token"
+	}
+	if g.thumbprint != "" {
+		thumbprint, err := p.thumbprint(c)
+		if err != nil {
+			return nil, err.Error()
+		}
+		if subtle.ConstantTimeCompare([]byte(g.thumbprint), []byte(thumbprint)) != 1 {
+			return nil, "the access token is bound to a client certificate the request does not present"
+		}
+	}
+	return g, ""
+}
+
+// authenticate checks the client credentials, sent with HTTP basic auth or
+// in the form.
+func (p *Provider) authenticate(c echo.Context, form url.Values) (Client, bool) {
+	id, secret, basic := c.Request().BasicAuth()
+	if basic {
+		id, _ = url.QueryUnescape(id)
+		secret, _ = url.QueryUnescape(secret)
+	} else {
+		id, secret = form.Get("client_id"), form.Get("client_secret")
+	}
+	client, ok := p.config.client(id)
+	if !ok {
+		return Client{}, false
+	}
+	if client.Secret != "" && subtle.ConstantTimeCompare([]byte(client.Secret), []byte(secret)) != 1 {
+		return Client{}, false
+	}
+	return client, true
+}
+
+// scopes parses the requested scopes, returning an unsupported one if any.
+// Without a request, the client gets every scope it may use.
+func (p *Provider) scopes(client Client, requested string) ([]string, string) {
+	allowed := client.Scopes
+	if len(allowed) == 0 {
+		allowed = p.config.scopes()
+	}
+	scopes := strings.Fields(requested)
+	if len(scopes) == 0 {
+		return allowed, ""
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(allowed, scope) {
+			return nil, scope
+		}
+	}
+	return scopes, ""
+}
+
+// userClaims returns sub, the standard claims of the granted scopes and the
+// user's other claims.
+func (p *Provider) userClaims(user *User, scopes []string) map[string]interface{} {
+	withheld := make(map[string]bool)
+	for scope, names := range standardClaims {
+		if !slices.Contains(scopes, scope) {
+			for _, name := range names {
+				withheld[name] = true
+			}
+		}
+	}
+	claims := make(map[string]interface{}, len(user.Claims)+2)
+	if !withheld["preferred_username"] {
+		claims["preferred_username"] = user.Username
+	}
+	for name, value := range user.Claims {
+		if !withheld[name] {
+			claims[name] = value
+		}
+	}
+	claims["sub"] = user.Subject
+	return claims
+}
+
+func (p *Provider) sign(claims map[string]interface{}) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	return p.keys.Sign(p.keyID, payload)
+}
+
+// store saves a grant under a new random token.
+func (p *Provider) store(table map[string]*grant, g *grant) string {
+	key := randomToken()
+	p.put(table, key, g)
+	return key
+}
+
+// put saves a grant under key and drops the expired grants of the table.
+func (p *Provider) put(table map[string]*grant, key string, g *grant) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	now := time.Now()
+	for k, existing := range table {
+		if now.After(existing.expires) {
+			delete(table, k)
+		}
+	}
+	table[key] = g
+}
+
+// take removes and returns an unexpired grant.
+func (p *Provider) take(table map[string]*grant, key string) *grant {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	g := table[key]
+	delete(table, key)
+	if g == nil || time.Now().After(g.expires) {
+		return nil
+	}
+	return g
+}
+
+func verifyChallenge(challenge, method, verifier string) bool {
+	if challenge == "" {
+		return true
+	}
+	if method == "S256" {
+		digest := sha256.Sum256([]byte(verifier))
+		verifier = base64.RawURLEncoding.EncodeToString(digest[:])
+	}
+	return subtle.ConstantTimeCompare([]byte(challenge), []byte(verifier)) == 1
+}
+
+func randomToken() string {
+	buf := make([]byte, 24)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+func redirectTo(c echo.Context, target *url.URL, params url.Values) error {
+	location := *target
+	query := location.Query()
+	for name, values := range params {
+		if values[0] != "" {
+			query[name] = values
+		}
+	}
+	location.RawQuery = query.Encode()
+	return c.Redirect(http.StatusFound, location.String())
+}
+
+func invalidToken(c echo.Context, description string) error {
+	c.Response().Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+	return oauthError(c, http.StatusUnauthorized, "invalid_token", description)
+}
+
+// oauthError answers in the error format of RFC 6749.
+func oauthError(c echo.Context, status int, code, description string) error {
+	return c.JSON(status, map[string]interface{}{
+		"error":             code,
+		"error_description": description,
+	})
+}
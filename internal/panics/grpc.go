@@ -0,0 +1,45 @@
+package panics
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryInterceptor fails a call whose handler panicked with INTERNAL,
+// naming the panic.
+func (r *Recorder) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		defer func() {
+			if value := recover(); value != nil {
+				resp, err = nil, r.internal(ctx, info.FullMethod, value)
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
+
+// StreamInterceptor does for streaming calls what UnaryInterceptor does for
+// unary ones.
+func (r *Recorder) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if value := recover(); value != nil {
+				err = r.internal(ss.Context(), info.FullMethod, value)
+			}
+		}()
+		return handler(srv, ss)
+	}
+}
+
+func (r *Recorder) internal(ctx context.Context, method string, value any) error {
+	p := r.Record(ctx, ProtocolGRPC, method, value)
+	message := fmt.Sprintf("panic: %s", p.Value)
+	if p.ID != "" {
+		message += " (" + p.ID + ")"
+	}
+	return status.Error(codes.Internal, message)
+}
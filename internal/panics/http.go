@@ -0,0 +1,41 @@
+package panics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Middleware answers a request whose handler panicked with a 500 naming the
+// panic, or leaves the response as it is when it was already started.
+// http.ErrAbortHandler is raised on purpose to drop the connection, and is
+// let through.
+func (r *Recorder) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) (err error) {
+			defer func() {
+				value := recover()
+				if value == nil {
+					return
+				}
+				if value == http.ErrAbortHandler {
+					panic(value)
+				}
+				req := c.Request()
+				p := r.Record(req.Context(), ProtocolHTTP, req.Method+" "+req.URL.Path, value)
+				err = nil
+				if c.Response().Committed {
+					return
+				}
+				err = c.JSON(http.StatusInternalServerError, map[string]interface{}{
+					"error":     "Internal server error",
+					"details":   "panic: " + p.Value,
+					"panic_id":  p.ID,
+					"timestamp": time.Now().Unix(),
+				})
+			}()
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+// Package panics contains the panics raised while serving requests and
+// connections, so that a bad stub or template fails the request it served
+// rather than the whole process, and keeps the recent ones for
+// /__admin/errors.
+package panics
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+
+	"mockserver/internal/logging"
+	"mockserver/internal/metrics"
+)
+
+var logger = logging.For("panics")
+
+// Protocols a panic can be raised on.
+const (
+	ProtocolHTTP      = "http"
+	ProtocolGRPC      = "grpc"
+	ProtocolWebSocket = "websocket"
+	ProtocolTCP       = "tcp"
+	ProtocolUDP       = "udp"
+	ProtocolSMTP      = "smtp"
+	ProtocolKafka     = "kafka"
+	ProtocolSFTP      = "sftp"
+)
+
+// DefaultCapacity is the number of panics kept before the oldest are
+// dropped.
+const DefaultCapacity = 100
+
+// Panic is a contained panic. Source is what was being served: the method
+// and path of an HTTP request, the full method of a gRPC call, the endpoint
+// and connection of a WebSocket, the peer of a raw connection. RequestID and
+// CorrelationID are those of the request's logs (see package logging).
+type Panic struct {
+	ID            string    `json:"id"`
+	Protocol      string    `json:"protocol"`
+	Timestamp     time.Time `json:"timestamp"`
+	Source        string    `json:"source"`
+	Value         string    `json:"value"`
+	Stack         string    `json:"stack"`
+	RequestID     string    `json:"request_id,omitempty"`
+	CorrelationID string    `json:"correlation_id,omitempty"`
+}
+
+// Recorder keeps the most recent panics. A nil Recorder still contains and
+// logs them, without keeping them.
+type Recorder struct {
+	capacity int
+	nextID   int
+	// panics are oldest first.
+	panics []Panic
+	// totals count every panic by protocol, including the dropped ones.
+	totals map[string]int64
+	mutex  sync.Mutex
+}
+
+func NewRecorder(capacity int) *Recorder {
+	if capacity <= 0 {
+		capacity = DefaultCapacity
+	}
+	return &Recorder{capacity: capacity, totals: make(map[string]int64)}
+}
+
+// Record logs and keeps a panic that was recovered with value. It is meant
+// to be called from the deferred function that recovered it, whose stack
+// still holds the frames that panicked.
+func (r *Recorder) Record(ctx context.Context, protocol, source string, value any) Panic {
+	p := Panic{
+		Protocol:  protocol,
+		Timestamp: time.Now(),
+		Source:    source,
+		Value:     fmt.Sprint(value),
+		Stack:     string(debug.Stack()),
+	}
+	for _, field := range logging.Fields(ctx) {
+		switch field.Key {
+		case "request_id":
+			p.RequestID = field.Value.String()
+		case "correlation_id":
+			p.CorrelationID = field.Value.String()
+		}
+	}
+	if r != nil {
+		r.mutex.Lock()
+		r.nextID++
+		p.ID = fmt.Sprintf("panic-%d", r.nextID)
+		r.panics = append(r.panics, p)
+		if len(r.panics) > r.capacity {
+			r.panics = r.panics[len(r.panics)-r.capacity:]
+		}
+		r.totals[protocol]++
+		r.mutex.Unlock()
+	}
+	logger.ErrorContext(ctx, "Panic contained", "panic_id", p.ID, "protocol", protocol,
+		"source", source, "value", p.Value, "stack", p.Stack)
+	return p
+}
+
+// Contain, deferred by a goroutine serving a connection, stops a panic of
+// that goroutine and records it; the goroutine then returns normally.
+func (r *Recorder) Contain(ctx context.Context, protocol, source string) {
+	if value := recover(); value != nil {
+		r.Record(ctx, protocol, source, value)
+	}
+}
+
+// List returns the panics kept, newest first, optionally of one protocol.
+func (r *Recorder) List(protocol string) []Panic {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	panics := []Panic{}
+	for i := len(r.panics) - 1; i >= 0; i-- {
+		if protocol == "" || r.panics[i].Protocol == protocol {
+			panics = append(panics, r.panics[i])
+		}
+	}
+	return panics
+}
+
+// Get returns a panic kept by ID.
+func (r *Recorder) Get(id string) (Panic, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, p := range r.panics {
+		if p.ID == id {
+			return p, true
+		}
+	}
+	return Panic{}, false
+}
+
+// Reset forgets the panics kept. The totals of /metrics keep counting.
+func (r *Recorder) Reset() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.panics = nil
+}
+
+// Collect writes the number of panics contained, by protocol.
+func (r *Recorder) Collect(w *metrics.Writer) {
+	r.mutex.Lock()
+	protocols := make([]string, 0, len(r.totals))
+	for protocol := range r.totals {
+		protocols = append(protocols, protocol)
+	}
+	sort.Strings(protocols)
+	totals := make([]int64, len(protocols))
+	for i, protocol := range protocols {
+		totals[i] = r.totals[protocol]
+	}
+	r.mutex.Unlock()
+
+	w.Family("mockserver_panics_total", "counter", "Panics contained while serving requests and connections, by protocol.")
+	for i, protocol := range protocols {
+		w.Sample("mockserver_panics_total", float64(totals[i]), "protocol", protocol)
+	}
+}
@@ -0,0 +1,408 @@
+package probe
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+
+	"mockserver/internal/journal"
+	"mockserver/internal/selftest"
+	wsHandlers "mockserver/internal/websocket"
+	pb "mockserver/proto"
+)
+
+// roomQuiet is how long the chat check listens to another room to make
+// sure the message stayed in its own.
+const roomQuiet = 200 * time.Millisecond
+
+// streamMessages is the number of messages of the streaming checks.
+const streamMessages = 3
+
+// token returns a value unique to a run, so that concurrent probes and
+// other clients do not confuse the checks.
+func token() string {
+	return fmt.Sprintf("probe-%08x", rand.Uint32())
+}
+
+func check(group, name, target string, run func(ctx context.Context) (string, error)) selftest.Check {
+	return selftest.Check{Name: name, Kind: group, Target: target, Run: run}
+}
+
+func httpChecks(baseURL string) []selftest.Check {
+	return []selftest.Check{
+		check(GroupHTTP, "health", "GET /health", func(ctx context.Context) (string, error) {
+			var body struct {
+				Status string `json:"status"`
+			}
+			if err := getJSON(ctx, http.MethodGet, baseURL+"/health", nil, http.StatusOK, &body); err != nil {
+				return "", err
+			}
+			if body.Status != "healthy" {
+				return "", fmt.Errorf("status %q", body.Status)
+			}
+			return "healthy", nil
+		}),
+		check(GroupHTTP, "echo-get", "GET /echo", func(ctx context.Context) (string, error) {
+			value := token()
+			var body struct {
+				Query map[string][]string `json:"query"`
+			}
+			if err := getJSON(ctx, http.MethodGet, baseURL+"/echo?quiet=false&probe="+value, nil, http.StatusOK, &body); err != nil {
+				return "", err
+			}
+			if got := body.Query["probe"]; len(got) != 1 || got[0] != value {
+				return "", fmt.Errorf("query echoed as %v", got)
+			}
+			return "query echoed", nil
+		}),
+		check(GroupHTTP, "echo-post", "POST /echo", func(ctx context.Context) (string, error) {
+			value := token()
+			var body struct {
+				Body map[string]string `json:"body"`
+			}
+			payload := []byte(`{"probe":"` + value + `"}`)
+			if err := getJSON(ctx, http.MethodPost, baseURL+"/echo?quiet=false", payload, http.StatusOK, &body); err != nil {
+				return "", err
+			}
+			if body.Body["probe"] != value {
+				return "", fmt.Errorf("body echoed as %v", body.Body)
+			}
+			return "body echoed", nil
+		}),
+		check(GroupHTTP, "malformed-json", "POST /echo", func(ctx context.Context) (string, error) {
+			var body struct {
+				ParseError *struct {
+					Error string `json:"error"`
+				} `json:"json_parse_error"`
+			}
+			if err := getJSON(ctx, http.MethodPost, baseURL+"/echo?quiet=false", []byte(`{"probe":`), http.StatusOK, &body); err != nil {
+				return "", err
+			}
+			if body.ParseError == nil {
+				return "", errors.New("no json_parse_error reported")
+			}
+			return "parse error reported", nil
+		}),
+		check(GroupHTTP, "status", "GET /status/418", func(ctx context.Context) (string, error) {
+			return expectStatus(ctx, baseURL+"/status/418", http.StatusTeapot)
+		}),
+	}
+}
+
+// expectStatus requests url and expects the status want.
+func expectStatus(ctx context.Context, url string, want int) (string, error) {
+	if err := getJSON(ctx, http.MethodGet, url, nil, want, nil); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d", want), nil
+}
+
+// getJSON sends a request, expects the status want and decodes the response
+// into v when given.
+func getJSON(ctx context.Context, method, url string, body []byte, want int, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set(journal.UnrecordedHeader, "probe")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != want {
+		return fmt.Errorf("expected status %d, got %d", want, resp.StatusCode)
+	}
+	if v != nil {
+		if err := json.Unmarshal(data, v); err != nil {
+			return fmt.Errorf("decode response: %w", err)
+		}
+	}
+	return nil
+}
+
+func webSocketChecks(baseURL string) []selftest.Check {
+	return []selftest.Check{
+		check(GroupWebSocket, "echo", "/ws/echo", func(ctx context.Context) (string, error) {
+			conn, err := dialWS(ctx, baseURL+"/ws/echo")
+			if err != nil {
+				return "", err
+			}
+			defer conn.Close()
+			value := token()
+			if err := conn.WriteJSON(wsHandlers.Message{Type: "probe", Data: value}); err != nil {
+				return "", err
+			}
+			if _, err := awaitWS(ctx, conn, "echo", value); err != nil {
+				return "", err
+			}
+			return "message echoed", nil
+		}),
+		check(GroupWebSocket, "malformed-json", "/ws/echo", func(ctx context.Context) (string, error) {
+			conn, err := dialWS(ctx, baseURL+"/ws/echo")
+			if err != nil {
+				return "", err
+			}
+			defer conn.Close()
+			if err := conn.WriteMessage(websocket.TextMessage, []byte(`{"type":`)); err != nil {
+				return "", err
+			}
+			if _, err := awaitWS(ctx, conn, "json_error", nil); err != nil {
+				return "", err
+			}
+			return "json_error reported", nil
+		}),
+		check(GroupWebSocket, "broadcast", "/ws/broadcast", func(ctx context.Context) (string, error) {
+			sender, err := dialWS(ctx, baseURL+"/ws/broadcast")
+			if err != nil {
+				return "", err
+			}
+			defer sender.Close()
+			receiver, err := dialWS(ctx, baseURL+"/ws/broadcast")
+			if err != nil {
+				return "", err
+			}
+			defer receiver.Close()
+			// Both are registered once they were welcomed
+			for _, conn := range []*websocket.Conn{sender, receiver} {
+				if _, err := awaitWS(ctx, conn, "welcome", nil); err != nil {
+					return "", err
+				}
+			}
+			value := token()
+			if err := sender.WriteJSON(wsHandlers.Message{Type: "probe", Data: value}); err != nil {
+				return "", err
+			}
+			if _, err := awaitWS(ctx, receiver, "broadcast", value); err != nil {
+				return "", fmt.Errorf("other client: %w", err)
+			}
+			return "delivered to another client", nil
+		}),
+		check(GroupWebSocket, "chat", "/ws/chat/:room", func(ctx context.Context) (string, error) {
+			room := token()
+			sender, err := dialWS(ctx, baseURL+"/ws/chat/"+room+"?username=probe-sender")
+			if err != nil {
+				return "", err
+			}
+			defer sender.Close()
+			member, err := dialWS(ctx, baseURL+"/ws/chat/"+room+"?username=probe-member")
+			if err != nil {
+				return "", err
+			}
+			defer member.Close()
+			outsider, err := dialWS(ctx, baseURL+"/ws/chat/"+room+"-other")
+			if err != nil {
+				return "", err
+			}
+			defer outsider.Close()
+			for _, conn := range []*websocket.Conn{sender, member, outsider} {
+				if _, err := awaitWS(ctx, conn, "welcome", nil); err != nil {
+					return "", err
+				}
+			}
+			value := token()
+			if err := sender.WriteJSON(wsHandlers.Message{Type: "probe", Data: value}); err != nil {
+				return "", err
+			}
+			msg, err := awaitWS(ctx, member, "chat", value)
+			if err != nil {
+				return "", fmt.Errorf("room member: %w", err)
+			}
+			if msg.Username != "probe-sender" {
+				return "", fmt.Errorf("message from %q, expected probe-sender", msg.Username)
+			}
+			quiet, cancel := context.WithTimeout(ctx, roomQuiet)
+			defer cancel()
+			if _, err := awaitWS(quiet, outsider, "chat", value); err == nil {
+				return "", errors.New("message leaked to another room")
+			}
+			return "delivered within the room only", nil
+		}),
+	}
+}
+
+// dialWS connects to an endpoint, turning quiet mode off: the checks expect
+// the welcome and the wrapped messages.
+func dialWS(ctx context.Context, url string) (*websocket.Conn, error) {
+	if strings.Contains(url, "?") {
+		url += "&quiet=false"
+	} else {
+		url += "?quiet=false"
+	}
+	header := http.Header{journal.UnrecordedHeader: {"probe"}}
+	conn, resp, err := websocket.DefaultDialer.DialContext(ctx, url, header)
+	if err != nil {
+		if resp != nil {
+			return nil, fmt.Errorf("handshake answered %d: %w", resp.StatusCode, err)
+		}
+		return nil, err
+	}
+	return conn, nil
+}
+
+// awaitWS reads messages until one of type msgType (carrying data, when
+// not nil) arrives, skipping the others.
+func awaitWS(ctx context.Context, conn *websocket.Conn, msgType string, data interface{}) (wsHandlers.Message, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(time.Minute)
+	}
+	conn.SetReadDeadline(deadline)
+	for {
+		var msg wsHandlers.Message
+		_, raw, err := conn.ReadMessage()
+		if err != nil {
+			return msg, fmt.Errorf("no %s message: %w", msgType, err)
+		}
+		if json.Unmarshal(raw, &msg) != nil {
+			continue
+		}
+		if msg.Type == msgType && (data == nil || msg.Data == data) {
+			return msg, nil
+		}
+	}
+}
+
+func grpcChecks(addr string) []selftest.Check {
+	// dial returns a MockService client; the connection closes with ctx
+	dial := func(ctx context.Context) (pb.MockServiceClient, context.Context, error) {
+		conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			return nil, nil, err
+		}
+		go func() {
+			<-ctx.Done()
+			conn.Close()
+		}()
+		ctx = metadata.AppendToOutgoingContext(ctx, journal.UnrecordedHeader, "probe")
+		return pb.NewMockServiceClient(conn), ctx, nil
+	}
+
+	return []selftest.Check{
+		check(GroupGRPC, "unary", "MockService/Echo", func(ctx context.Context) (string, error) {
+			ctx, cancel := context.WithCancel(ctx)
+			defer cancel()
+			client, ctx, err := dial(ctx)
+			if err != nil {
+				return "", err
+			}
+			value := token()
+			resp, err := client.Echo(ctx, &pb.SimpleRequest{Message: value, Value: 42})
+			if err != nil {
+				return "", err
+			}
+			if !strings.Contains(resp.Message, value) {
+				return "", fmt.Errorf("unexpected reply %q", resp.Message)
+			}
+			return "message echoed", nil
+		}),
+		check(GroupGRPC, "server-stream", "MockService/ServerStream", func(ctx context.Context) (string, error) {
+			ctx, cancel := context.WithCancel(ctx)
+			defer cancel()
+			client, ctx, err := dial(ctx)
+			if err != nil {
+				return "", err
+			}
+			interval := int32(0)
+			stream, err := client.ServerStream(ctx, &pb.StreamRequest{Id: token(), Count: streamMessages, IntervalMs: &interval})
+			if err != nil {
+				return "", err
+			}
+			received := 0
+			for {
+				resp, err := stream.Recv()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					return "", err
+				}
+				received++
+				if resp.Sequence != int32(received) {
+					return "", fmt.Errorf("message %d has sequence %d", received, resp.Sequence)
+				}
+			}
+			if received != streamMessages {
+				return "", fmt.Errorf("received %d messages, expected %d", received, streamMessages)
+			}
+			return fmt.Sprintf("%d messages received", received), nil
+		}),
+		check(GroupGRPC, "client-stream", "MockService/ClientStream", func(ctx context.Context) (string, error) {
+			ctx, cancel := context.WithCancel(ctx)
+			defer cancel()
+			client, ctx, err := dial(ctx)
+			if err != nil {
+				return "", err
+			}
+			stream, err := client.ClientStream(ctx)
+			if err != nil {
+				return "", err
+			}
+			for i := 0; i < streamMessages; i++ {
+				if err := stream.Send(&pb.StreamRequest{Id: token(), Data: fmt.Sprintf("message %d", i+1)}); err != nil {
+					return "", err
+				}
+			}
+			resp, err := stream.CloseAndRecv()
+			if err != nil {
+				return "", err
+			}
+			if want := fmt.Sprintf("Received %d messages", streamMessages); !strings.HasPrefix(resp.Message, want) {
+				return "", fmt.Errorf("unexpected reply %q", resp.Message)
+			}
+			return fmt.Sprintf("%d messages acknowledged", streamMessages), nil
+		}),
+		check(GroupGRPC, "bidi-stream", "MockService/BidiStream", func(ctx context.Context) (string, error) {
+			ctx, cancel := context.WithCancel(ctx)
+			defer cancel()
+			client, ctx, err := dial(ctx)
+			if err != nil {
+				return "", err
+			}
+			stream, err := client.BidiStream(ctx)
+			if err != nil {
+				return "", err
+			}
+			ids := make([]string, streamMessages)
+			for i := range ids {
+				ids[i] = token()
+				if err := stream.Send(&pb.StreamRequest{Id: ids[i], Data: "probe"}); err != nil {
+					return "", err
+				}
+				resp, err := stream.Recv()
+				if err != nil {
+					return "", err
+				}
+				if resp.Id != ids[i] {
+					return "", fmt.Errorf("reply to %s carries id %s", ids[i], resp.Id)
+				}
+			}
+			if err := stream.CloseSend(); err != nil {
+				return "", err
+			}
+			if _, err := stream.Recv(); err != io.EOF {
+				return "", fmt.Errorf("stream did not complete: %v", err)
+			}
+			return fmt.Sprintf("%d messages exchanged", streamMessages), nil
+		}),
+	}
+}
@@ -0,0 +1,155 @@
+// Package probe is a smoke test of a running mock server from the outside:
+// the built-in HTTP endpoints, the echo, broadcast and chat WebSockets and
+// the four kinds of MockService calls, reported as pass/fail per check.
+package probe
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"mockserver/internal/selftest"
+)
+
+// Check groups, selectable with -only.
+const (
+	GroupHTTP      = "http"
+	GroupWebSocket = "ws"
+	GroupGRPC      = "grpc"
+)
+
+// Groups lists every check group.
+var Groups = []string{GroupHTTP, GroupWebSocket, GroupGRPC}
+
+// Config selects the server and the checks.
+type Config struct {
+	// HTTPURL is the base URL of the HTTP and WebSocket listener.
+	HTTPURL string
+	// GRPCAddr is the address of the gRPC listener.
+	GRPCAddr string
+	// Groups are the check groups to run.
+	Groups []string
+}
+
+// Main runs "mockserver probe" with the arguments following the command.
+// It returns an error when a check fails.
+func Main(args []string) error {
+	flags := flag.NewFlagSet("probe", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: mockserver probe [flags] [addr]")
+		fmt.Fprintln(flags.Output(), "\naddr is the HTTP listener, host:port or a URL (default localhost:8080).")
+		flags.PrintDefaults()
+	}
+	grpcAddr := flags.String("grpc", "", "gRPC listener address (default: the host of addr, port 50051)")
+	only := flags.String("only", strings.Join(Groups, ","), "comma-separated check groups to run")
+	timeout := flags.Duration("timeout", 10*time.Second, "time limit for the whole run")
+	asJSON := flags.Bool("json", false, "print the report as JSON")
+	flags.Parse(args)
+
+	// Flags may also follow the address
+	addr := "localhost:8080"
+	if flags.NArg() > 0 {
+		addr = flags.Arg(0)
+		flags.Parse(flags.Args()[1:])
+	}
+	if flags.NArg() > 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	cfg, err := newConfig(addr, *grpcAddr, *only)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	report := Run(ctx, cfg)
+	if *asJSON {
+		out, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Printf("%s\n", out)
+	} else {
+		Print(os.Stdout, report)
+	}
+	if !report.Passed {
+		return fmt.Errorf("%d of %d checks failed", report.Failures, report.Tests)
+	}
+	return nil
+}
+
+func newConfig(addr, grpcAddr, only string) (Config, error) {
+	if !strings.Contains(addr, "://") {
+		addr = "http://" + addr
+	}
+	base, err := url.Parse(addr)
+	if err != nil || base.Host == "" {
+		return Config{}, fmt.Errorf("invalid address %q", addr)
+	}
+	if grpcAddr == "" {
+		grpcAddr = net.JoinHostPort(base.Hostname(), "50051")
+	}
+
+	cfg := Config{HTTPURL: strings.TrimRight(base.String(), "/"), GRPCAddr: grpcAddr}
+	for _, group := range strings.Split(only, ",") {
+		group = strings.TrimSpace(group)
+		if !slices.Contains(Groups, group) {
+			return Config{}, fmt.Errorf("unknown check group %q (want %s)", group, strings.Join(Groups, ", "))
+		}
+		cfg.Groups = append(cfg.Groups, group)
+	}
+	return cfg, nil
+}
+
+// Run performs the checks of the selected groups once.
+func Run(ctx context.Context, cfg Config) selftest.Report {
+	suite := selftest.New()
+	for _, group := range cfg.Groups {
+		var checks []selftest.Check
+		switch group {
+		case GroupHTTP:
+			checks = httpChecks(cfg.HTTPURL)
+		case GroupWebSocket:
+			checks = webSocketChecks(wsURL(cfg.HTTPURL))
+		case GroupGRPC:
+			checks = grpcChecks(cfg.GRPCAddr)
+		}
+		for _, check := range checks {
+			suite.Add(check)
+		}
+	}
+	return suite.Run(ctx, 0)
+}
+
+// Print writes the report as a table followed by a summary line.
+func Print(w io.Writer, report selftest.Report) {
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, result := range report.Results {
+		outcome, detail := "PASS", result.Detail
+		if !result.Passed {
+			outcome = "FAIL"
+			if detail != "" {
+				detail += ": "
+			}
+			detail += result.Error
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%.1fms\n", outcome, result.Kind, result.Name, detail, result.DurationMs)
+	}
+	table.Flush()
+	fmt.Fprintf(w, "%d checks, %d failed (%.1fms)\n", report.Tests, report.Failures, report.DurationMs)
+}
+
+// wsURL turns the HTTP base URL into the WebSocket one.
+func wsURL(httpURL string) string {
+	if rest, ok := strings.CutPrefix(httpURL, "https://"); ok {
+		return "wss://" + rest
+	}
+	return "ws://" + strings.TrimPrefix(httpURL, "http://")
+}
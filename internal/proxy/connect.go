@@ -0,0 +1,142 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+)
+
+// ConnectProxy is a forward HTTP proxy: CONNECT requests become tunnels and
+// absolute-form requests (plain http:// URLs) are forwarded, both routed by
+// the Tunneler.
+type ConnectProxy struct {
+	tunneler *Tunneler
+}
+
+func NewConnectProxy(tunneler *Tunneler) *ConnectProxy {
+	return &ConnectProxy{tunneler: tunneler}
+}
+
+// Server builds the HTTP server for the CONNECT listener.
+func (p *ConnectProxy) Server(addr string) *http.Server {
+	return &http.Server{Addr: addr, Handler: p}
+}
+
+func (p *ConnectProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if auth := p.tunneler.config.Auth; auth != nil && !checkProxyAuth(r, auth) {
+		w.Header().Set("Proxy-Authenticate", fmt.Sprintf("Basic realm=%q", auth.Realm))
+		http.Error(w, "Proxy Authentication Required", http.StatusProxyAuthRequired)
+		return
+	}
+
+	if r.Method == http.MethodConnect {
+		p.connect(w, r)
+		return
+	}
+	if r.URL.IsAbs() {
+		p.forward(w, r)
+		return
+	}
+	http.Error(w, "This listener is an HTTP proxy; send CONNECT or absolute-form requests", http.StatusBadRequest)
+}
+
+func (p *ConnectProxy) connect(w http.ResponseWriter, r *http.Request) {
+	target := normalizeTarget(r.Host, "443")
+	capture := &Capture{Listener: "connect", Client: r.RemoteAddr, Method: r.Method, Target: target}
+
+	route, upstream, err := p.tunneler.resolve(target)
+	capture.Upstream = upstream
+	p.tunneler.record(capture)
+	if err != nil {
+		p.tunneler.update(func() { capture.Status = http.StatusForbidden })
+		p.tunneler.finish(capture, err)
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		p.tunneler.finish(capture, fmt.Errorf("connection cannot be hijacked"))
+		http.Error(w, "CONNECT requires HTTP/1.1", http.StatusHTTPVersionNotSupported)
+		return
+	}
+	server, err := p.tunneler.dial(upstream)
+	if err != nil {
+		p.tunneler.update(func() { capture.Status = http.StatusBadGateway })
+		p.tunneler.finish(capture, err)
+		http.Error(w, "Bad Gateway", http.StatusBadGateway)
+		return
+	}
+	client, buffered, err := hijacker.Hijack()
+	if err != nil {
+		server.Close()
+		p.tunneler.finish(capture, err)
+		return
+	}
+	if _, err := client.Write([]byte("HTTP/1.1 200 Connection Established\r\n\r\n")); err != nil {
+		client.Close()
+		server.Close()
+		p.tunneler.finish(capture, err)
+		return
+	}
+	p.tunneler.update(func() { capture.Status = http.StatusOK })
+	logger.Debug("Tunnel opened", "method", http.MethodConnect, "target", target, "upstream", upstream, "route", route)
+
+	// Bytes the client sent after the CONNECT request are already buffered.
+	conn := client
+	if buffered.Reader.Buffered() > 0 {
+		conn = &bufferedConn{Conn: client, reader: buffered.Reader}
+	}
+	p.tunneler.Tunnel(conn, server, capture, route)
+}
+
+func (p *ConnectProxy) forward(w http.ResponseWriter, r *http.Request) {
+	target := normalizeTarget(r.URL.Host, "80")
+	capture := &Capture{Listener: "connect", Client: r.RemoteAddr, Method: r.Method, Target: target, Request: r.URL.String()}
+
+	route, upstream, err := p.tunneler.resolve(target)
+	capture.Upstream = upstream
+	p.tunneler.record(capture)
+	if err == nil && route == RouteGRPC {
+		err = fmt.Errorf("the gRPC listener cannot serve plain HTTP proxy requests")
+	}
+	if err != nil {
+		p.tunneler.update(func() { capture.Status = http.StatusForbidden })
+		p.tunneler.finish(capture, err)
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	failed := false
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(&url.URL{Scheme: "http", Host: upstream})
+			pr.Out.URL.Path = r.URL.Path
+			pr.Out.URL.RawPath = r.URL.RawPath
+			pr.Out.Host = r.URL.Host
+			pr.Out.Header.Del("Proxy-Authorization")
+			pr.Out.Header.Del("Proxy-Connection")
+		},
+		ModifyResponse: func(res *http.Response) error {
+			p.tunneler.update(func() {
+				capture.Status = res.StatusCode
+				if res.ContentLength > 0 {
+					capture.BytesDown = res.ContentLength
+				}
+			})
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			failed = true
+			p.tunneler.update(func() { capture.Status = http.StatusBadGateway })
+			p.tunneler.finish(capture, err)
+			http.Error(w, "Bad Gateway", http.StatusBadGateway)
+		},
+	}
+	logger.Debug("Forwarding request", "method", r.Method, "target", r.URL.String(), "upstream", upstream, "route", route)
+	proxy.ServeHTTP(w, r)
+	if !failed {
+		p.tunneler.finish(capture, nil)
+	}
+}
@@ -0,0 +1,220 @@
+// Package proxy reproduces the behavior of corporate proxies in front of the
+// mock server's own listeners.
+package proxy
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"mockserver/internal/logging"
+)
+
+var logger = logging.For("proxy")
+
+// AuthConfig requires clients to send Proxy-Authorization (Basic) credentials.
+type AuthConfig struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Realm    string `json:"realm,omitempty"`
+}
+
+// Rewrite replaces text in response bodies. Find is a regular expression when
+// Regex is set; ContentType limits the rewrite to matching media types.
+type Rewrite struct {
+	Find        string `json:"find"`
+	Replace     string `json:"replace"`
+	Regex       bool   `json:"regex,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+}
+
+// TLSConfig re-encrypts traffic with a CA of the proxy's own. Without CertFile
+// and KeyFile a CA is generated at startup.
+type TLSConfig struct {
+	Enabled  bool   `json:"enabled"`
+	CertFile string `json:"ca_cert,omitempty"`
+	KeyFile  string `json:"ca_key,omitempty"`
+}
+
+// FrontConfig holds the behaviors of the fronting listener.
+type FrontConfig struct {
+	Auth     *AuthConfig `json:"auth,omitempty"`
+	Via      string      `json:"via,omitempty"`
+	Rewrites []Rewrite   `json:"rewrites,omitempty"`
+	TLS      TLSConfig   `json:"tls"`
+}
+
+const defaultVia = "1.1 mockserver-proxy"
+
+type compiledRewrite struct {
+	pattern     *regexp.Regexp
+	replace     []byte
+	literal     bool
+	contentType string
+}
+
+// Front is a reverse proxy to one of the mock's listeners that behaves like a
+// corporate proxy: authentication challenges, Via headers, body rewriting and
+// TLS with a different CA.
+type Front struct {
+	config   FrontConfig
+	proxy    *httputil.ReverseProxy
+	rewrites []compiledRewrite
+	ca       *CertificateAuthority
+}
+
+func NewFront(config FrontConfig, target *url.URL) (*Front, error) {
+	if config.Via == "" {
+		config.Via = defaultVia
+	}
+	if config.Auth != nil && config.Auth.Realm == "" {
+		config.Auth.Realm = "mockserver"
+	}
+
+	f := &Front{config: config}
+	for _, rewrite := range config.Rewrites {
+		compiled, err := compileRewrite(rewrite)
+		if err != nil {
+			return nil, err
+		}
+		f.rewrites = append(f.rewrites, compiled)
+	}
+
+	if config.TLS.Enabled {
+		var err error
+		if config.TLS.CertFile != "" || config.TLS.KeyFile != "" {
+			f.ca, err = LoadCA(config.TLS.CertFile, config.TLS.KeyFile)
+		} else {
+			f.ca, err = GenerateCA("Mock Corporate Proxy CA")
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	f.proxy = &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.SetURL(target)
+			r.SetXForwarded()
+			r.Out.Host = r.In.Host
+			r.Out.Header.Del("Proxy-Authorization")
+			r.Out.Header.Add("Via", config.Via)
+		},
+		ModifyResponse: f.modifyResponse,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			logger.WarnContext(r.Context(), "Upstream error", "method", r.Method, "path", r.URL.Path, "error", err)
+			w.Header().Set("Via", config.Via)
+			http.Error(w, "Bad Gateway", http.StatusBadGateway)
+		},
+	}
+	return f, nil
+}
+
+func compileRewrite(rewrite Rewrite) (compiledRewrite, error) {
+	if rewrite.Find == "" {
+		return compiledRewrite{}, fmt.Errorf("rewrite needs a find pattern")
+	}
+	expr := rewrite.Find
+	if !rewrite.Regex {
+		expr = regexp.QuoteMeta(expr)
+	}
+	pattern, err := regexp.Compile(expr)
+	if err != nil {
+		return compiledRewrite{}, fmt.Errorf("invalid rewrite pattern %q: %w", rewrite.Find, err)
+	}
+	return compiledRewrite{
+		pattern:     pattern,
+		replace:     []byte(rewrite.Replace),
+		literal:     !rewrite.Regex,
+		contentType: rewrite.ContentType,
+	}, nil
+}
+
+// CA returns the certificate authority used for TLS, or nil without TLS.
+func (f *Front) CA() *CertificateAuthority {
+	return f.ca
+}
+
+// Server builds the HTTP server for the fronting listener.
+func (f *Front) Server(addr string) *http.Server {
+	server := &http.Server{Addr: addr, Handler: f}
+	if f.ca != nil {
+		server.TLSConfig = f.ca.TLSConfig()
+	}
+	return server
+}
+
+func (f *Front) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if f.config.Auth != nil && !checkProxyAuth(r, f.config.Auth) {
+		logger.DebugContext(r.Context(), "Missing or invalid Proxy-Authorization", "method", r.Method, "path", r.URL.Path)
+		w.Header().Set("Proxy-Authenticate", fmt.Sprintf("Basic realm=%q", f.config.Auth.Realm))
+		w.Header().Set("Via", f.config.Via)
+		http.Error(w, "Proxy Authentication Required", http.StatusProxyAuthRequired)
+		return
+	}
+	f.proxy.ServeHTTP(w, r)
+}
+
+func checkProxyAuth(r *http.Request, auth *AuthConfig) bool {
+	scheme, credentials, ok := strings.Cut(r.Header.Get("Proxy-Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Basic") {
+		return false
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(credentials))
+	if err != nil {
+		return false
+	}
+	username, password, ok := strings.Cut(string(decoded), ":")
+	if !ok {
+		return false
+	}
+	userOK := subtle.ConstantTimeCompare([]byte(username), []byte(auth.Username)) == 1
+	passOK := subtle.ConstantTimeCompare([]byte(password), []byte(auth.Password)) == 1
+	return userOK && passOK
+}
+
+func (f *Front) modifyResponse(res *http.Response) error {
+	res.Header.Add("Via", f.config.Via)
+
+	// Upgraded connections and encoded bodies pass through untouched.
+	if len(f.rewrites) == 0 || res.StatusCode == http.StatusSwitchingProtocols || res.Header.Get("Content-Encoding") != "" {
+		return nil
+	}
+
+	contentType := res.Header.Get("Content-Type")
+	var applicable []compiledRewrite
+	for _, rewrite := range f.rewrites {
+		if rewrite.contentType == "" || strings.HasPrefix(contentType, rewrite.contentType) {
+			applicable = append(applicable, rewrite)
+		}
+	}
+	if len(applicable) == 0 {
+		return nil
+	}
+
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return err
+	}
+	for _, rewrite := range applicable {
+		if rewrite.literal {
+			body = rewrite.pattern.ReplaceAllLiteral(body, rewrite.replace)
+		} else {
+			body = rewrite.pattern.ReplaceAll(body, rewrite.replace)
+		}
+	}
+
+	res.Body = io.NopCloser(bytes.NewReader(body))
+	res.ContentLength = int64(len(body))
+	res.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	return nil
+}
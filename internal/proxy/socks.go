@@ -0,0 +1,218 @@
+package proxy
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// SOCKS5 protocol constants (RFC 1928, RFC 1929).
+const (
+	socksVersion          = 0x05
+	socksAuthNone         = 0x00
+	socksAuthPassword     = 0x02
+	socksAuthNoneFound    = 0xff
+	socksCmdConnect       = 0x01
+	socksAddrIPv4         = 0x01
+	socksAddrDomain       = 0x03
+	socksAddrIPv6         = 0x04
+	socksReplySuccess     = 0x00
+	socksReplyFailure     = 0x01
+	socksReplyNotAllowed  = 0x02
+	socksReplyUnreachable = 0x04
+	socksReplyNoCommand   = 0x07
+	socksReplyNoAddrType  = 0x08
+
+	socksHandshakeTimeout = 10 * time.Second
+)
+
+// SOCKSProxy is a SOCKS5 listener (CONNECT command only) routed by the
+// Tunneler, for clients that cannot use an HTTP proxy.
+type SOCKSProxy struct {
+	tunneler *Tunneler
+}
+
+func NewSOCKSProxy(tunneler *Tunneler) *SOCKSProxy {
+	return &SOCKSProxy{tunneler: tunneler}
+}
+
+// Serve accepts SOCKS5 connections until the listener is closed.
+// Established tunnels outlive the listener.
+func (p *SOCKSProxy) Serve(listener net.Listener) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go p.handle(conn)
+	}
+}
+
+func (p *SOCKSProxy) handle(conn net.Conn) {
+	conn.SetDeadline(time.Now().Add(socksHandshakeTimeout))
+	reader := bufio.NewReader(conn)
+
+	target, err := p.handshake(conn, reader)
+	if err != nil {
+		logger.Debug("SOCKS5 handshake failed", "peer", conn.RemoteAddr().String(), "error", err)
+		conn.Close()
+		return
+	}
+
+	capture := &Capture{Listener: "socks5", Client: conn.RemoteAddr().String(), Method: "CONNECT", Target: target}
+	route, upstream, err := p.tunneler.resolve(target)
+	capture.Upstream = upstream
+	p.tunneler.record(capture)
+	if err != nil {
+		writeSOCKSReply(conn, socksReplyNotAllowed)
+		conn.Close()
+		p.tunneler.finish(capture, err)
+		return
+	}
+
+	server, err := p.tunneler.dial(upstream)
+	if err != nil {
+		writeSOCKSReply(conn, socksReplyUnreachable)
+		conn.Close()
+		p.tunneler.finish(capture, err)
+		return
+	}
+	if err := writeSOCKSReply(conn, socksReplySuccess); err != nil {
+		conn.Close()
+		server.Close()
+		p.tunneler.finish(capture, err)
+		return
+	}
+	conn.SetDeadline(time.Time{})
+	logger.Debug("Tunnel opened", "method", "SOCKS5", "target", target, "upstream", upstream, "route", route)
+
+	p.tunneler.Tunnel(&bufferedConn{Conn: conn, reader: reader}, server, capture, route)
+}
+
+// handshake negotiates authentication and reads the CONNECT request,
+// returning the requested "host:port".
+func (p *SOCKSProxy) handshake(conn net.Conn, reader *bufio.Reader) (string, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return "", err
+	}
+	if header[0] != socksVersion {
+		return "", fmt.Errorf("unsupported SOCKS version %d", header[0])
+	}
+	methods := make([]byte, header[1])
+	if _, err := io.ReadFull(reader, methods); err != nil {
+		return "", err
+	}
+
+	wanted := byte(socksAuthNone)
+	if p.tunneler.config.Auth != nil {
+		wanted = socksAuthPassword
+	}
+	offered := false
+	for _, method := range methods {
+		offered = offered || method == wanted
+	}
+	if !offered {
+		conn.Write([]byte{socksVersion, socksAuthNoneFound})
+		return "", fmt.Errorf("client offers no acceptable authentication method")
+	}
+	if _, err := conn.Write([]byte{socksVersion, wanted}); err != nil {
+		return "", err
+	}
+	if wanted == socksAuthPassword {
+		if err := p.authenticate(conn, reader); err != nil {
+			return "", err
+		}
+	}
+
+	request := make([]byte, 4)
+	if _, err := io.ReadFull(reader, request); err != nil {
+		return "", err
+	}
+	if request[1] != socksCmdConnect {
+		writeSOCKSReply(conn, socksReplyNoCommand)
+		return "", fmt.Errorf("unsupported command %d", request[1])
+	}
+
+	var host string
+	switch request[3] {
+	case socksAddrIPv4, socksAddrIPv6:
+		size := net.IPv4len
+		if request[3] == socksAddrIPv6 {
+			size = net.IPv6len
+		}
+		ip := make([]byte, size)
+		if _, err := io.ReadFull(reader, ip); err != nil {
+			return "", err
+		}
+		host = net.IP(ip).String()
+	case socksAddrDomain:
+		length, err := reader.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		domain := make([]byte, length)
+		if _, err := io.ReadFull(reader, domain); err != nil {
+			return "", err
+		}
+		host = string(domain)
+	default:
+		writeSOCKSReply(conn, socksReplyNoAddrType)
+		return "", fmt.Errorf("unsupported address type %d", request[3])
+	}
+
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(reader, port); err != nil {
+		return "", err
+	}
+	return net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port)))), nil
+}
+
+// authenticate performs RFC 1929 username/password authentication.
+func (p *SOCKSProxy) authenticate(conn net.Conn, reader *bufio.Reader) error {
+	readField := func() (string, error) {
+		length, err := reader.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		field := make([]byte, length)
+		_, err = io.ReadFull(reader, field)
+		return string(field), err
+	}
+
+	if _, err := reader.ReadByte(); err != nil { // sub-negotiation version
+		return err
+	}
+	username, err := readField()
+	if err != nil {
+		return err
+	}
+	password, err := readField()
+	if err != nil {
+		return err
+	}
+
+	auth := p.tunneler.config.Auth
+	userOK := subtle.ConstantTimeCompare([]byte(username), []byte(auth.Username)) == 1
+	passOK := subtle.ConstantTimeCompare([]byte(password), []byte(auth.Password)) == 1
+	if !userOK || !passOK {
+		conn.Write([]byte{0x01, socksReplyFailure})
+		return fmt.Errorf("invalid credentials for user %q", username)
+	}
+	_, err = conn.Write([]byte{0x01, socksReplySuccess})
+	return err
+}
+
+func writeSOCKSReply(conn net.Conn, reply byte) error {
+	_, err := conn.Write([]byte{socksVersion, reply, 0x00, socksAddrIPv4, 0, 0, 0, 0, 0, 0})
+	return err
+}
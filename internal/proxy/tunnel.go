@@ -0,0 +1,294 @@
+package proxy
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Route targets understood besides a plain "host:port" upstream.
+const (
+	RouteDirect = "direct" // dial the requested target
+	RouteReject = "reject" // refuse the tunnel
+	RouteHTTP   = "http"   // the mock's HTTP/WebSocket listener
+	RouteGRPC   = "grpc"   // the mock's gRPC listener
+)
+
+const (
+	defaultCaptureBytes = 4096
+	maxCapturedTunnels  = 200
+	dialTimeout         = 10 * time.Second
+)
+
+// TunnelConfig decides where tunneled connections go. Routes are keyed by
+// "host:port" or "host" (any port) and map to "http", "grpc", "direct",
+// "reject" or another "host:port". Default applies to unmatched targets.
+type TunnelConfig struct {
+	Routes       map[string]string `json:"routes,omitempty"`
+	Default      string            `json:"default,omitempty"`
+	Auth         *AuthConfig       `json:"auth,omitempty"`
+	CertFile     string            `json:"ca_cert,omitempty"`
+	KeyFile      string            `json:"ca_key,omitempty"`
+	CaptureBytes int               `json:"capture_bytes,omitempty"`
+}
+
+// Capture records one tunneled connection or proxied request. Request and
+// Response hold the first bytes sent in each direction (decrypted when TLS was
+// intercepted).
+type Capture struct {
+	ID          string     `json:"id"`
+	Listener    string     `json:"listener"`
+	Client      string     `json:"client"`
+	Method      string     `json:"method"`
+	Target      string     `json:"target"`
+	Upstream    string     `json:"upstream"`
+	Intercepted bool       `json:"tls_intercepted"`
+	Status      int        `json:"status,omitempty"`
+	BytesUp     int64      `json:"bytes_up"`
+	BytesDown   int64      `json:"bytes_down"`
+	Request     string     `json:"request,omitempty"`
+	Response    string     `json:"response,omitempty"`
+	Error       string     `json:"error,omitempty"`
+	StartedAt   time.Time  `json:"started_at"`
+	EndedAt     *time.Time `json:"ended_at,omitempty"`
+}
+
+// Tunneler routes client connections to the mock's own listeners or to real
+// upstreams and keeps a capture of the most recent ones. It is shared by the
+// CONNECT and SOCKS listeners.
+type Tunneler struct {
+	config   TunnelConfig
+	mocks    map[string]string
+	ca       *CertificateAuthority
+	captures []*Capture
+	nextID   atomic.Int64
+	mutex    sync.Mutex
+}
+
+// NewTunneler creates a tunneler. mocks maps "http" and "grpc" to the dialable
+// addresses of the mock's listeners.
+func NewTunneler(config TunnelConfig, mocks map[string]string) (*Tunneler, error) {
+	if config.Default == "" {
+		config.Default = RouteDirect
+	}
+	if config.Auth != nil && config.Auth.Realm == "" {
+		config.Auth.Realm = "mockserver"
+	}
+	if config.CaptureBytes <= 0 {
+		config.CaptureBytes = defaultCaptureBytes
+	}
+
+	var ca *CertificateAuthority
+	var err error
+	if config.CertFile != "" || config.KeyFile != "" {
+		ca, err = LoadCA(config.CertFile, config.KeyFile)
+	} else {
+		ca, err = GenerateCA("Mock Tunnel CA")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Tunneler{config: config, mocks: mocks, ca: ca}, nil
+}
+
+// CA returns the authority used to intercept TLS tunneled to the mock.
+func (t *Tunneler) CA() *CertificateAuthority {
+	return t.ca
+}
+
+// resolve returns the route for a "host:port" target and the address to dial.
+func (t *Tunneler) resolve(target string) (string, string, error) {
+	host, _, err := net.SplitHostPort(target)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid target %q", target)
+	}
+
+	route, ok := t.config.Routes[target]
+	if !ok {
+		route, ok = t.config.Routes[host]
+	}
+	if !ok {
+		route = t.config.Default
+	}
+
+	switch route {
+	case RouteReject:
+		return route, "", fmt.Errorf("target %s is rejected", target)
+	case RouteDirect:
+		return route, target, nil
+	case RouteHTTP, RouteGRPC:
+		return route, t.mocks[route], nil
+	default:
+		return route, route, nil
+	}
+}
+
+// Captures returns the recorded connections, oldest first.
+func (t *Tunneler) Captures() []Capture {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	captures := make([]Capture, len(t.captures))
+	for i, capture := range t.captures {
+		captures[i] = *capture
+	}
+	return captures
+}
+
+// ResetCaptures forgets all recorded connections.
+func (t *Tunneler) ResetCaptures() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.captures = nil
+}
+
+func (t *Tunneler) record(capture *Capture) {
+	capture.ID = fmt.Sprintf("tun-%d", t.nextID.Add(1))
+	capture.StartedAt = time.Now()
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.captures = append(t.captures, capture)
+	if len(t.captures) > maxCapturedTunnels {
+		t.captures = t.captures[len(t.captures)-maxCapturedTunnels:]
+	}
+}
+
+func (t *Tunneler) update(fn func()) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	fn()
+}
+
+// dial connects to the upstream of a tunnel.
+func (t *Tunneler) dial(upstream string) (net.Conn, error) {
+	return net.DialTimeout("tcp", upstream, dialTimeout)
+}
+
+// Tunnel relays between an established client connection and its upstream
+// until either side closes. The handshake of the client protocol (CONNECT,
+// SOCKS) must already have been completed by the caller.
+func (t *Tunneler) Tunnel(client, server net.Conn, capture *Capture, route string) {
+	defer client.Close()
+	defer server.Close()
+
+	var conn net.Conn = client
+	if route == RouteHTTP || route == RouteGRPC {
+		// Clients usually start TLS inside the tunnel; terminate it with our
+		// own CA so the plaintext listeners (and the capture) see the traffic.
+		reader := bufio.NewReader(client)
+		first, err := reader.Peek(1)
+		buffered := &bufferedConn{Conn: client, reader: reader}
+		conn = buffered
+		if err == nil && first[0] == 0x16 {
+			host, _, _ := net.SplitHostPort(capture.Target)
+			config := t.ca.TLSConfig()
+			config.GetCertificate = func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+				if hello.ServerName != "" {
+					return t.ca.Leaf(hello.ServerName)
+				}
+				return t.ca.Leaf(host)
+			}
+			if route == RouteGRPC {
+				config.NextProtos = []string{"h2"}
+			} else {
+				config.NextProtos = []string{"http/1.1"}
+			}
+
+			tlsConn := tls.Server(buffered, config)
+			if err := tlsConn.Handshake(); err != nil {
+				t.finish(capture, fmt.Errorf("TLS interception: %w", err))
+				return
+			}
+			conn = tlsConn
+			t.update(func() { capture.Intercepted = true })
+		}
+	}
+
+	up := &captureWriter{tunneler: t, limit: t.config.CaptureBytes, bytes: &capture.BytesUp, preview: &capture.Request}
+	down := &captureWriter{tunneler: t, limit: t.config.CaptureBytes, bytes: &capture.BytesDown, preview: &capture.Response}
+
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(io.MultiWriter(server, up), conn)
+		closeWrite(server)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(io.MultiWriter(conn, down), server)
+		closeWrite(conn)
+		done <- struct{}{}
+	}()
+	<-done
+	<-done
+
+	t.finish(capture, nil)
+}
+
+func (t *Tunneler) finish(capture *Capture, err error) {
+	t.update(func() {
+		now := time.Now()
+		capture.EndedAt = &now
+		if err != nil {
+			capture.Error = err.Error()
+		}
+	})
+	if err != nil {
+		logger.Debug("Tunnel failed", "method", capture.Method, "target", capture.Target, "upstream", capture.Upstream, "error", err)
+		return
+	}
+	logger.Debug("Tunnel closed", "method", capture.Method, "target", capture.Target, "upstream", capture.Upstream,
+		"bytes_up", capture.BytesUp, "bytes_down", capture.BytesDown)
+}
+
+// captureWriter counts relayed bytes and keeps the first ones as a preview.
+type captureWriter struct {
+	tunneler *Tunneler
+	limit    int
+	bytes    *int64
+	preview  *string
+}
+
+func (w *captureWriter) Write(p []byte) (int, error) {
+	w.tunneler.update(func() {
+		*w.bytes += int64(len(p))
+		if room := w.limit - len(*w.preview); room > 0 {
+			*w.preview += string(p[:min(room, len(p))])
+		}
+	})
+	return len(p), nil
+}
+
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+func closeWrite(conn net.Conn) {
+	switch c := conn.(type) {
+	case interface{ CloseWrite() error }:
+		c.CloseWrite()
+	case *bufferedConn:
+		closeWrite(c.Conn)
+	default:
+		conn.Close()
+	}
+}
+
+func normalizeTarget(target, defaultPort string) string {
+	if _, _, err := net.SplitHostPort(target); err == nil {
+		return target
+	}
+	return net.JoinHostPort(strings.Trim(target, "[]"), defaultPort)
+}
@@ -0,0 +1,623 @@
+package queue
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"mockserver/internal/logging"
+)
+
+var logger = logging.For("queues")
+
+const (
+	// maxMessages bounds each queue; sending beyond it is refused.
+	maxMessages = 100000
+	// maxBody is the largest message body, as in SQS.
+	maxBody = 256 * 1024
+	// pollInterval is how often a long poll looks again for messages whose
+	// visibility timeout or delay ran out.
+	pollInterval = 100 * time.Millisecond
+)
+
+// States of a message, reported by the admin API.
+const (
+	StateVisible  = "visible"
+	StateInFlight = "in_flight"
+	StateDelayed  = "delayed"
+)
+
+// Message is a message as received. ReceiptHandle deletes it or changes its
+// visibility until it is received again. SourceQueue is the queue a
+// dead-lettered message comes from; State and VisibleAt are only reported by
+// the admin API.
+type Message struct {
+	ID              string            `json:"message_id"`
+	Body            string            `json:"body"`
+	MD5OfBody       string            `json:"md5_of_body"`
+	Attributes      map[string]string `json:"attributes,omitempty"`
+	ReceiptHandle   string            `json:"receipt_handle,omitempty"`
+	ReceiveCount    int               `json:"receive_count"`
+	SentAt          time.Time         `json:"sent_at"`
+	FirstReceivedAt *time.Time        `json:"first_received_at,omitempty"`
+	SourceQueue     string            `json:"source_queue,omitempty"`
+	State           string            `json:"state,omitempty"`
+	VisibleAt       *time.Time        `json:"visible_at,omitempty"`
+}
+
+// Queue is a queue with its settings, message counts and counters, and
+// its messages when inspected alone.
+type Queue struct {
+	Name              string    `json:"name"`
+	VisibilityTimeout string    `json:"visibility_timeout"`
+	Delay             string    `json:"delay,omitempty"`
+	MaxReceiveCount   int       `json:"max_receive_count,omitempty"`
+	DeadLetterQueue   string    `json:"dead_letter_queue,omitempty"`
+	Visible           int       `json:"visible"`
+	InFlight          int       `json:"in_flight"`
+	Delayed           int       `json:"delayed"`
+	Sent              int64     `json:"sent"`
+	Received          int64     `json:"received"`
+	Deleted           int64     `json:"deleted"`
+	DeadLettered      int64     `json:"dead_lettered"`
+	Messages          []Message `json:"messages,omitempty"`
+}
+
+// SendRequest is the payload of a sent message. DelaySeconds overrides the
+// delay of the queue.
+type SendRequest struct {
+	Body         string            `json:"body"`
+	DelaySeconds *int              `json:"delay_seconds,omitempty"`
+	Attributes   map[string]string `json:"attributes,omitempty"`
+}
+
+type visibilityRequest struct {
+	VisibilityTimeout *int `json:"visibility_timeout"`
+}
+
+type message struct {
+	id            string
+	body          string
+	md5           string
+	attributes    map[string]string
+	sent          time.Time
+	visibleAt     time.Time
+	receiveCount  int
+	firstReceived time.Time
+	receipt       string
+	source        string
+}
+
+type queue struct {
+	name string
+	settings
+	messages     []*message
+	sent         int64
+	received     int64
+	deleted      int64
+	deadLettered int64
+}
+
+// Broker holds the queues and serves their API.
+type Broker struct {
+	config            Config
+	path              string
+	visibilityTimeout time.Duration
+
+	queues map[string]*queue
+	nextID int
+	// notify is closed, and replaced, when a message is sent, to wake up
+	// the long polls.
+	notify chan struct{}
+	mutex  sync.Mutex
+}
+
+func New(config Config) (*Broker, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	visibilityTimeout, _ := config.visibilityTimeout()
+	b := &Broker{
+		config:            config,
+		path:              config.path(),
+		visibilityTimeout: visibilityTimeout,
+		notify:            make(chan struct{}),
+	}
+	b.queues = b.configuredQueues()
+	return b, nil
+}
+
+// configuredQueues creates the queues of the configuration and their
+// dead-letter queues.
+func (b *Broker) configuredQueues() map[string]*queue {
+	queues := make(map[string]*queue, len(b.config.Queues))
+	for _, qc := range b.config.Queues {
+		s, _ := qc.settings(b.visibilityTimeout)
+		queues[qc.Name] = &queue{name: qc.Name, settings: s}
+	}
+	for _, q := range queues {
+		if q.deadLetterQueue != "" && queues[q.deadLetterQueue] == nil {
+			queues[q.deadLetterQueue] = b.newQueue(q.deadLetterQueue)
+		}
+	}
+	return queues
+}
+
+func (b *Broker) newQueue(name string) *queue {
+	return &queue{name: name, settings: settings{visibilityTimeout: b.visibilityTimeout}}
+}
+
+// Path returns the base path of the API.
+func (b *Broker) Path() string {
+	return b.path
+}
+
+// Register adds the endpoints to the server.
+func (b *Broker) Register(e *echo.Echo) {
+	e.GET(b.path, b.ListQueues)
+	e.PUT(b.path+"/:queue", b.CreateQueue)
+	e.DELETE(b.path+"/:queue", b.DeleteQueue)
+	e.POST(b.path+"/:queue/messages", b.Send)
+	e.GET(b.path+"/:queue/messages", b.Receive)
+	e.DELETE(b.path+"/:queue/messages", b.Purge)
+	e.DELETE(b.path+"/:queue/messages/:receipt", b.Delete)
+	e.PUT(b.path+"/:queue/messages/:receipt/visibility", b.ChangeVisibility)
+}
+
+// ListQueues returns the queues with their message counts.
+func (b *Broker) ListQueues(c echo.Context) error {
+	queues := b.Queues()
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"queues":    queues,
+		"count":     len(queues),
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// CreateQueue creates the queue with the settings of the body, if any. An
+// existing queue is returned unchanged.
+func (b *Broker) CreateQueue(c echo.Context) error {
+	qc := QueueConfig{}
+	if c.Request().ContentLength != 0 {
+		if err := c.Bind(&qc); err != nil {
+			return invalidRequest(c, "Invalid queue", err.Error())
+		}
+	}
+	qc.Name = c.Param("queue")
+	s, err := qc.settings(b.visibilityTimeout)
+	if err != nil {
+		return invalidRequest(c, "Invalid queue", err.Error())
+	}
+
+	b.mutex.Lock()
+	q, exists := b.queues[qc.Name]
+	if !exists {
+		q = &queue{name: qc.Name, settings: s}
+		b.queues[q.name] = q
+		if q.deadLetterQueue != "" && b.queues[q.deadLetterQueue] == nil {
+			b.queues[q.deadLetterQueue] = b.newQueue(q.deadLetterQueue)
+		}
+	}
+	info := q.info(time.Now(), false)
+	b.mutex.Unlock()
+
+	if exists {
+		return c.JSON(http.StatusOK, info)
+	}
+	logger.InfoContext(c.Request().Context(), "Queue created", "queue", qc.Name)
+	return c.JSON(http.StatusCreated, info)
+}
+
+// DeleteQueue deletes a queue and its messages.
+func (b *Broker) DeleteQueue(c echo.Context) error {
+	name := c.Param("queue")
+	b.mutex.Lock()
+	_, ok := b.queues[name]
+	delete(b.queues, name)
+	b.mutex.Unlock()
+	if !ok {
+		return queueNotFound(c)
+	}
+	logger.InfoContext(c.Request().Context(), "Queue deleted", "queue", name)
+	return c.NoContent(http.StatusNoContent)
+}
+
+// Send adds a message to a queue, creating the queue if needed.
+func (b *Broker) Send(c echo.Context) error {
+	var req SendRequest
+	if err := c.Bind(&req); err != nil {
+		return invalidRequest(c, "Invalid message", err.Error())
+	}
+	if req.Body == "" {
+		return invalidRequest(c, "Invalid message", "body is required")
+	}
+	if len(req.Body) > maxBody {
+		return invalidRequest(c, "Invalid message", fmt.Sprintf("body is longer than %d bytes", maxBody))
+	}
+	if req.DelaySeconds != nil && (*req.DelaySeconds < 0 || time.Duration(*req.DelaySeconds)*time.Second > maxDelay) {
+		return invalidRequest(c, "Invalid message", fmt.Sprintf("delay_seconds must be between 0 and %d", int(maxDelay.Seconds())))
+	}
+	name := c.Param("queue")
+	if !validName.MatchString(name) {
+		return invalidRequest(c, "Invalid queue", fmt.Sprintf("invalid queue name %q", name))
+	}
+
+	now := time.Now()
+	digest := md5.Sum([]byte(req.Body))
+	m := &message{body: req.Body, md5: hex.EncodeToString(digest[:]), attributes: req.Attributes, sent: now}
+
+	b.mutex.Lock()
+	q := b.queueLocked(name)
+	if len(q.messages) >= maxMessages {
+		b.mutex.Unlock()
+		return c.JSON(http.StatusServiceUnavailable, map[string]interface{}{
+			"error":     "Queue is full",
+			"provided":  name,
+			"timestamp": now.Unix(),
+		})
+	}
+	delay := q.delay
+	if req.DelaySeconds != nil {
+		delay = time.Duration(*req.DelaySeconds) * time.Second
+	}
+	b.nextID++
+	m.id = fmt.Sprintf("msg-%d", b.nextID)
+	m.visibleAt = now.Add(delay)
+	q.messages = append(q.messages, m)
+	q.sent++
+	close(b.notify)
+	b.notify = make(chan struct{})
+	b.mutex.Unlock()
+
+	logger.DebugContext(c.Request().Context(), "Message sent", "message_id", m.id, "queue", name)
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+		"message_id":  m.id,
+		"md5_of_body": m.md5,
+		"timestamp":   now.Unix(),
+	})
+}
+
+// Receive returns up to max_messages (1 to 10, default 1) visible messages
+// and hides them for visibility_timeout seconds (the queue's by default).
+// With wait_time_seconds (up to 20), it waits for messages when there are
+// none. A message received max_receive_count times is moved to the
+// dead-letter queue instead.
+func (b *Broker) Receive(c echo.Context) error {
+	limit, err := intParam(c, "max_messages", 1, 1, maxReceive)
+	if err != nil {
+		return invalidRequest(c, "Invalid receive request", err.Error())
+	}
+	wait, err := intParam(c, "wait_time_seconds", 0, 0, int(maxWait.Seconds()))
+	if err != nil {
+		return invalidRequest(c, "Invalid receive request", err.Error())
+	}
+	visibility, err := intParam(c, "visibility_timeout", -1, 0, int(maxVisibilityTimeout.Seconds()))
+	if err != nil {
+		return invalidRequest(c, "Invalid receive request", err.Error())
+	}
+
+	name := c.Param("queue")
+	deadline := time.Now().Add(time.Duration(wait) * time.Second)
+	for {
+		b.mutex.Lock()
+		q, ok := b.queues[name]
+		var messages []Message
+		if ok {
+			timeout := q.visibilityTimeout
+			if visibility >= 0 {
+				timeout = time.Duration(visibility) * time.Second
+			}
+			messages = b.receiveLocked(q, limit, timeout, time.Now())
+		}
+		notify := b.notify
+		b.mutex.Unlock()
+		if !ok {
+			return queueNotFound(c)
+		}
+
+		remaining := time.Until(deadline)
+		if len(messages) > 0 || remaining <= 0 {
+			return c.JSON(http.StatusOK, map[string]interface{}{
+				"messages":  messages,
+				"count":     len(messages),
+				"timestamp": time.Now().Unix(),
+			})
+		}
+		timer := time.NewTimer(min(remaining, pollInterval))
+		select {
+		case <-notify:
+		case <-timer.C:
+		case <-c.Request().Context().Done():
+			timer.Stop()
+			return nil
+		}
+		timer.Stop()
+	}
+}
+
+// Delete deletes a received message by its receipt handle. A handle stops
+// working once the message is received again.
+func (b *Broker) Delete(c echo.Context) error {
+	b.mutex.Lock()
+	q, ok := b.queues[c.Param("queue")]
+	if !ok {
+		b.mutex.Unlock()
+		return queueNotFound(c)
+	}
+	i := q.byReceipt(c.Param("receipt"))
+	if i < 0 {
+		b.mutex.Unlock()
+		return receiptNotFound(c)
+	}
+	m := q.messages[i]
+	q.messages = slices.Delete(q.messages, i, i+1)
+	q.deleted++
+	b.mutex.Unlock()
+
+	logger.DebugContext(c.Request().Context(), "Message deleted", "message_id", m.id, "queue", q.name)
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ChangeVisibility hides an in-flight message for visibility_timeout
+// seconds from now; 0 makes it visible again at once.
+func (b *Broker) ChangeVisibility(c echo.Context) error {
+	var req visibilityRequest
+	if err := c.Bind(&req); err != nil || req.VisibilityTimeout == nil ||
+		*req.VisibilityTimeout < 0 || time.Duration(*req.VisibilityTimeout)*time.Second > maxVisibilityTimeout {
+		return invalidRequest(c, "Invalid visibility request",
+			fmt.Sprintf("visibility_timeout must be between 0 and %d", int(maxVisibilityTimeout.Seconds())))
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	q, ok := b.queues[c.Param("queue")]
+	if !ok {
+		return queueNotFound(c)
+	}
+	i := q.byReceipt(c.Param("receipt"))
+	if i < 0 {
+		return receiptNotFound(c)
+	}
+	m := q.messages[i]
+	now := time.Now()
+	if !m.visibleAt.After(now) {
+		return c.JSON(http.StatusConflict, map[string]interface{}{
+			"error":     "Message not in flight",
+			"provided":  m.id,
+			"timestamp": now.Unix(),
+		})
+	}
+	m.visibleAt = now.Add(time.Duration(*req.VisibilityTimeout) * time.Second)
+	return c.JSON(http.StatusOK, m.view(now, true))
+}
+
+// Purge deletes every message of a queue.
+func (b *Broker) Purge(c echo.Context) error {
+	name := c.Param("queue")
+	b.mutex.Lock()
+	q, ok := b.queues[name]
+	var purged int
+	if ok {
+		purged = len(q.messages)
+		q.messages = nil
+	}
+	b.mutex.Unlock()
+	if !ok {
+		return queueNotFound(c)
+	}
+	logger.InfoContext(c.Request().Context(), "Queue purged", "queue", name, "messages", purged)
+	return c.NoContent(http.StatusNoContent)
+}
+
+// Queues returns the queues with their message counts, by name.
+func (b *Broker) Queues() []Queue {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	now := time.Now()
+	queues := make([]Queue, 0, len(b.queues))
+	for _, q := range b.queues {
+		queues = append(queues, q.info(now, false))
+	}
+	sort.Slice(queues, func(i, j int) bool { return queues[i].Name < queues[j].Name })
+	return queues
+}
+
+// Queue returns a queue with its messages, in the order they were sent.
+func (b *Broker) Queue(name string) (Queue, bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	q, ok := b.queues[name]
+	if !ok {
+		return Queue{}, false
+	}
+	return q.info(time.Now(), true), true
+}
+
+// Reset deletes every message and the queues that were not configured, and
+// recreates the configured ones.
+func (b *Broker) Reset() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.queues = b.configuredQueues()
+	logger.Info("All messages removed")
+}
+
+// queueLocked returns a queue, creating it with the defaults if needed.
+func (b *Broker) queueLocked(name string) *queue {
+	q, ok := b.queues[name]
+	if !ok {
+		q = b.newQueue(name)
+		b.queues[name] = q
+		logger.Info("Queue created", "queue", name)
+	}
+	return q
+}
+
+// receiveLocked receives up to limit visible messages of a queue, moving the
+// ones received too many times to its dead-letter queue.
+func (b *Broker) receiveLocked(q *queue, limit int, timeout time.Duration, now time.Time) []Message {
+	messages := []Message{}
+	for i := 0; i < len(q.messages) && len(messages) < limit; {
+		m := q.messages[i]
+		if m.visibleAt.After(now) {
+			i++
+			continue
+		}
+		if q.maxReceiveCount > 0 && m.receiveCount >= q.maxReceiveCount {
+			q.messages = slices.Delete(q.messages, i, i+1)
+			b.deadLetterLocked(q, m, now)
+			continue
+		}
+		m.receiveCount++
+		if m.receiveCount == 1 {
+			m.firstReceived = now
+		}
+		m.receipt = newReceipt()
+		m.visibleAt = now.Add(timeout)
+		q.received++
+		messages = append(messages, m.view(now, false))
+		i++
+	}
+	return messages
+}
+
+func (b *Broker) deadLetterLocked(q *queue, m *message, now time.Time) {
+	dlq := b.queueLocked(q.deadLetterQueue)
+	if m.source == "" {
+		m.source = q.name
+	}
+	m.receipt = ""
+	m.visibleAt = now
+	dlq.messages = append(dlq.messages, m)
+	q.deadLettered++
+	logger.Debug("Message moved to the dead-letter queue", "message_id", m.id, "queue", q.name, "dead_letter_queue", dlq.name, "receives", m.receiveCount)
+}
+
+func (q *queue) byReceipt(receipt string) int {
+	if receipt == "" {
+		return -1
+	}
+	return slices.IndexFunc(q.messages, func(m *message) bool { return m.receipt == receipt })
+}
+
+func (q *queue) info(now time.Time, withMessages bool) Queue {
+	info := Queue{
+		Name:              q.name,
+		VisibilityTimeout: q.visibilityTimeout.String(),
+		MaxReceiveCount:   q.maxReceiveCount,
+		DeadLetterQueue:   q.deadLetterQueue,
+		Sent:              q.sent,
+		Received:          q.received,
+		Deleted:           q.deleted,
+		DeadLettered:      q.deadLettered,
+	}
+	if q.delay > 0 {
+		info.Delay = q.delay.String()
+	}
+	if withMessages {
+		info.Messages = make([]Message, 0, len(q.messages))
+	}
+	for _, m := range q.messages {
+		view := m.view(now, true)
+		switch view.State {
+		case StateVisible:
+			info.Visible++
+		case StateInFlight:
+			info.InFlight++
+		case StateDelayed:
+			info.Delayed++
+		}
+		if withMessages {
+			info.Messages = append(info.Messages, view)
+		}
+	}
+	return info
+}
+
+// view returns a message as received, or with its state for the admin API.
+func (m *message) view(now time.Time, state bool) Message {
+	view := Message{
+		ID:            m.id,
+		Body:          m.body,
+		MD5OfBody:     m.md5,
+		Attributes:    m.attributes,
+		ReceiptHandle: m.receipt,
+		ReceiveCount:  m.receiveCount,
+		SentAt:        m.sent,
+		SourceQueue:   m.source,
+	}
+	if !m.firstReceived.IsZero() {
+		first := m.firstReceived
+		view.FirstReceivedAt = &first
+	}
+	if state {
+		switch {
+		case !m.visibleAt.After(now):
+			view.State = StateVisible
+		case m.receiveCount > 0 && m.receipt != "":
+			view.State = StateInFlight
+		default:
+			view.State = StateDelayed
+		}
+		if view.State != StateVisible {
+			visibleAt := m.visibleAt
+			view.VisibleAt = &visibleAt
+		}
+	}
+	return view
+}
+
+func newReceipt() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// intParam parses an integer query parameter between low and high, def when
+// it is absent.
+func intParam(c echo.Context, name string, def, low, high int) (int, error) {
+	value := c.QueryParam(name)
+	if value == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < low || n > high {
+		return 0, fmt.Errorf("%s must be between %d and %d", name, low, high)
+	}
+	return n, nil
+}
+
+func invalidRequest(c echo.Context, message, details string) error {
+	return c.JSON(http.StatusBadRequest, map[string]interface{}{
+		"error":     message,
+		"details":   details,
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+func queueNotFound(c echo.Context) error {
+	return c.JSON(http.StatusNotFound, map[string]interface{}{
+		"error":     "Queue not found",
+		"provided":  c.Param("queue"),
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+func receiptNotFound(c echo.Context) error {
+	return c.JSON(http.StatusNotFound, map[string]interface{}{
+		"error":     "Receipt handle not found",
+		"details":   "the message was deleted or received again",
+		"provided":  c.Param("receipt"),
+		"timestamp": time.Now().Unix(),
+	})
+}
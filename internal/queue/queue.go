@@ -0,0 +1,138 @@
+// Package queue is an in-memory message queue with the semantics of Amazon
+// SQS standard queues: a received message is hidden for its visibility
+// timeout and delivered again unless it is deleted in time, and after a
+// number of receives it is moved to a dead-letter queue. It lets services
+// consuming queues be integration-tested against the mock alone.
+package queue
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPath              = "/queues"
+	defaultVisibilityTimeout = 30 * time.Second
+
+	// maxVisibilityTimeout, maxDelay and maxWait are the limits of SQS.
+	maxVisibilityTimeout = 12 * time.Hour
+	maxDelay             = 15 * time.Minute
+	maxWait              = 20 * time.Second
+	maxReceive           = 10
+)
+
+// DeadLetterSuffix names the dead-letter queue of a queue that sets
+// max_receive_count without dead_letter_queue.
+const DeadLetterSuffix = "-dlq"
+
+var validName = regexp.MustCompile(`^[A-Za-z0-9_-]{1,80}$`)
+
+// Config holds the queue settings; QUEUES_ENABLED=true also enables them.
+type Config struct {
+	Enabled bool `json:"enabled"`
+	// Path is the base path of the API, /queues by default.
+	Path string `json:"path,omitempty"`
+	// VisibilityTimeout is the default of the queues, 30s by default.
+	VisibilityTimeout string `json:"visibility_timeout,omitempty"`
+	// Queues are created at startup. Sending to an unknown queue creates it
+	// with the defaults.
+	Queues []QueueConfig `json:"queues,omitempty"`
+}
+
+// QueueConfig is a queue. A message received MaxReceiveCount times without
+// being deleted is moved to DeadLetterQueue (the name with "-dlq" by
+// default) instead of being delivered again.
+type QueueConfig struct {
+	Name              string `json:"name"`
+	VisibilityTimeout string `json:"visibility_timeout,omitempty"`
+	// Delay hides the messages sent for a while.
+	Delay           string `json:"delay,omitempty"`
+	MaxReceiveCount int    `json:"max_receive_count,omitempty"`
+	DeadLetterQueue string `json:"dead_letter_queue,omitempty"`
+}
+
+// Validate checks the path and the queues.
+func (c Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.Path != "" && (!strings.HasPrefix(c.Path, "/") || strings.HasPrefix(c.Path, "/__admin")) {
+		return fmt.Errorf("queues: invalid path %q", c.Path)
+	}
+	if _, err := c.visibilityTimeout(); err != nil {
+		return err
+	}
+	seen := make(map[string]bool, len(c.Queues))
+	for _, q := range c.Queues {
+		if seen[q.Name] {
+			return fmt.Errorf("queues: duplicate queue %q", q.Name)
+		}
+		seen[q.Name] = true
+		if _, err := q.settings(defaultVisibilityTimeout); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c Config) path() string {
+	if c.Path == "" {
+		return defaultPath
+	}
+	return strings.TrimRight(c.Path, "/")
+}
+
+func (c Config) visibilityTimeout() (time.Duration, error) {
+	if c.VisibilityTimeout == "" {
+		return defaultVisibilityTimeout, nil
+	}
+	d, err := time.ParseDuration(c.VisibilityTimeout)
+	if err != nil || d < 0 || d > maxVisibilityTimeout {
+		return 0, fmt.Errorf("queues: invalid visibility_timeout %q", c.VisibilityTimeout)
+	}
+	return d, nil
+}
+
+// settings are the parsed settings of a queue.
+type settings struct {
+	visibilityTimeout time.Duration
+	delay             time.Duration
+	maxReceiveCount   int
+	deadLetterQueue   string
+}
+
+func (q QueueConfig) settings(visibilityTimeout time.Duration) (settings, error) {
+	s := settings{visibilityTimeout: visibilityTimeout, maxReceiveCount: q.MaxReceiveCount}
+	if !validName.MatchString(q.Name) {
+		return s, fmt.Errorf("queues: invalid queue name %q", q.Name)
+	}
+	var err error
+	if q.VisibilityTimeout != "" {
+		if s.visibilityTimeout, err = time.ParseDuration(q.VisibilityTimeout); err != nil ||
+			s.visibilityTimeout < 0 || s.visibilityTimeout > maxVisibilityTimeout {
+			return s, fmt.Errorf("queues: %s: invalid visibility_timeout %q", q.Name, q.VisibilityTimeout)
+		}
+	}
+	if q.Delay != "" {
+		if s.delay, err = time.ParseDuration(q.Delay); err != nil || s.delay < 0 || s.delay > maxDelay {
+			return s, fmt.Errorf("queues: %s: invalid delay %q", q.Name, q.Delay)
+		}
+	}
+	if q.MaxReceiveCount < 0 {
+		return s, fmt.Errorf("queues: %s: invalid max_receive_count %d", q.Name, q.MaxReceiveCount)
+	}
+	if q.MaxReceiveCount > 0 {
+		s.deadLetterQueue = q.DeadLetterQueue
+		if s.deadLetterQueue == "" {
+			s.deadLetterQueue = q.Name + DeadLetterSuffix
+		}
+		if s.deadLetterQueue == q.Name || !validName.MatchString(s.deadLetterQueue) {
+			return s, fmt.Errorf("queues: %s: invalid dead_letter_queue %q", q.Name, s.deadLetterQueue)
+		}
+	} else if q.DeadLetterQueue != "" {
+		return s, fmt.Errorf("queues: %s: dead_letter_queue requires max_receive_count", q.Name)
+	}
+	return s, nil
+}
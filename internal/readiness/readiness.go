@@ -0,0 +1,126 @@
+// Package readiness tracks what the server needs before it can take traffic
+// (every configured listener bound and the initial stubs loaded) and
+// reports it on /readyz for Kubernetes readiness and startup probes.
+package readiness
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Path is where readiness is reported.
+const Path = "/readyz"
+
+// startupCheck is the implicit check passing once every server is serving.
+const startupCheck = "startup"
+
+// Check is a condition of readiness. Detail describes it when ready, such as
+// the address of a listener, and Error why it failed.
+type Check struct {
+	Name   string `json:"name"`
+	Ready  bool   `json:"ready"`
+	Detail string `json:"detail,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Tracker holds the checks in the order they were added.
+type Tracker struct {
+	checks  []*Check
+	started bool
+	mutex   sync.Mutex
+}
+
+func New() *Tracker {
+	return &Tracker{}
+}
+
+func (t *Tracker) check(name string) *Check {
+	for _, c := range t.checks {
+		if c.Name == name {
+			return c
+		}
+	}
+	c := &Check{Name: name}
+	t.checks = append(t.checks, c)
+	return c
+}
+
+// Ready marks a check as passing.
+func (t *Tracker) Ready(name, detail string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	c := t.check(name)
+	c.Ready, c.Detail, c.Error = true, detail, ""
+}
+
+// Fail marks a check as failing, such as a listener that stopped serving.
+func (t *Tracker) Fail(name string, err error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	c := t.check(name)
+	c.Ready, c.Error = false, err.Error()
+}
+
+// Started marks the end of the startup: every server is serving.
+func (t *Tracker) Started() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.started = true
+}
+
+// Checks returns the checks, the startup check last, and whether they all
+// pass.
+func (t *Tracker) Checks() ([]Check, bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	checks := make([]Check, 0, len(t.checks)+1)
+	ready := t.started
+	for _, c := range t.checks {
+		checks = append(checks, *c)
+		ready = ready && c.Ready
+	}
+	startup := Check{Name: startupCheck, Ready: t.started}
+	if !t.started {
+		startup.Error = "not finished"
+	}
+	return append(checks, startup), ready
+}
+
+// Handler answers 200 when ready and 503 otherwise. With the verbose query
+// parameter it lists the checks in the format of the Kubernetes API server:
+//
+//	[+]listener:grpc ok (:50051)
+//	[-]startup failed: not finished
+//	readyz check failed
+func (t *Tracker) Handler(c echo.Context) error {
+	checks, ready := t.Checks()
+	status, outcome := http.StatusOK, "passed"
+	if !ready {
+		status, outcome = http.StatusServiceUnavailable, "failed"
+	}
+	if _, verbose := c.QueryParams()["verbose"]; !verbose {
+		if ready {
+			return c.String(status, "ok")
+		}
+		return c.String(status, "readyz check failed")
+	}
+
+	var b strings.Builder
+	for _, check := range checks {
+		switch {
+		case check.Ready && check.Detail != "":
+			fmt.Fprintf(&b, "[+]%s ok (%s)\n", check.Name, check.Detail)
+		case check.Ready:
+			fmt.Fprintf(&b, "[+]%s ok\n", check.Name)
+		default:
+			fmt.Fprintf(&b, "[-]%s failed: %s\n", check.Name, check.Error)
+		}
+	}
+	fmt.Fprintf(&b, "readyz check %s", outcome)
+	return c.String(status, b.String())
+}
@@ -0,0 +1,365 @@
+package replay
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"mockserver/internal/journal"
+)
+
+// DefaultDiffHeaders are the response headers compared when a diff names
+// none.
+var DefaultDiffHeaders = []string{"Content-Type", "Location"}
+
+const (
+	// maxDifferences and maxDiffOutput bound the JSON differences and the
+	// text lines listed per request; the rest are counted as omitted.
+	maxDifferences = 100
+	maxDiffOutput  = 200
+	// maxDiffLines bounds the changed region of text bodies diffed line by
+	// line; beyond it the bodies are only reported as different.
+	maxDiffLines = 500
+)
+
+// DiffOptions tune a diff. Headers are the response headers compared,
+// DefaultDiffHeaders when empty. Ignore lists JSON paths left out of the
+// body comparison with everything under them, such as "$.meta" or
+// "$.items[*].updated_at"; [*] matches any index and .* any key.
+type DiffOptions struct {
+	Headers []string
+	Ignore  []string
+}
+
+// DiffReport compares the responses recorded in the journal with those of
+// the replay target. Unavailable counts the requests that were skipped,
+// failed or are still to be sent.
+type DiffReport struct {
+	RunID       string        `json:"run_id"`
+	State       string        `json:"state"`
+	Target      string        `json:"target"`
+	GeneratedAt time.Time     `json:"generated_at"`
+	Headers     []string      `json:"headers"`
+	Ignore      []string      `json:"ignore,omitempty"`
+	Total       int           `json:"total"`
+	Identical   int           `json:"identical"`
+	Different   int           `json:"different"`
+	Unavailable int           `json:"unavailable"`
+	Requests    []RequestDiff `json:"requests"`
+}
+
+// RequestDiff is the comparison of one request. Headers lists the compared
+// headers that differ.
+type RequestDiff struct {
+	RequestID   string       `json:"request_id"`
+	Method      string       `json:"method"`
+	Path        string       `json:"path"`
+	Identical   bool         `json:"identical"`
+	Unavailable string       `json:"unavailable,omitempty"`
+	Status      *StatusDiff  `json:"status,omitempty"`
+	Headers     []HeaderDiff `json:"headers,omitempty"`
+	Body        *BodyDiff    `json:"body,omitempty"`
+}
+
+// StatusDiff holds both statuses.
+type StatusDiff struct {
+	Original  int  `json:"original"`
+	Replay    int  `json:"replay"`
+	Identical bool `json:"identical"`
+}
+
+// HeaderDiff holds both values of a header, joined with commas.
+type HeaderDiff struct {
+	Name     string `json:"name"`
+	Original string `json:"original"`
+	Replay   string `json:"replay"`
+}
+
+// BodyDiff compares the bodies. Kind is json when both parse as JSON, which
+// are compared structurally, text when both are UTF-8, diffed line by line
+// ("- " original, "+ " replay), and binary otherwise. Truncated means a body
+// was longer than 64 KiB and only the starts were compared.
+type BodyDiff struct {
+	Kind        string       `json:"kind"`
+	Identical   bool         `json:"identical"`
+	Truncated   bool         `json:"truncated,omitempty"`
+	Differences []Difference `json:"differences,omitempty"`
+	Lines       []string     `json:"lines,omitempty"`
+	Omitted     int          `json:"omitted,omitempty"`
+}
+
+// Difference is a JSON value that changed, or was added or removed by the
+// replay.
+type Difference struct {
+	Path     string      `json:"path"`
+	Kind     string      `json:"kind"`
+	Original interface{} `json:"original,omitempty"`
+	Replay   interface{} `json:"replay,omitempty"`
+}
+
+// Diff compares the responses of a run with the recorded ones.
+func (r *Replayer) Diff(id string, opts DiffOptions) (DiffReport, bool) {
+	rn := r.find(id)
+	if rn == nil {
+		return DiffReport{}, false
+	}
+	rn.mutex.Lock()
+	results := append([]Result(nil), rn.Results...)
+	responses := append([]*response(nil), rn.responses...)
+	report := DiffReport{RunID: rn.ID, State: rn.State, Target: rn.Target}
+	rn.mutex.Unlock()
+
+	report.GeneratedAt = time.Now()
+	report.Headers = opts.Headers
+	if len(report.Headers) == 0 {
+		report.Headers = DefaultDiffHeaders
+	}
+	report.Ignore = opts.Ignore
+	ignore := make([]*regexp.Regexp, 0, len(opts.Ignore))
+	for _, path := range opts.Ignore {
+		ignore = append(ignore, ignorePattern(path))
+	}
+
+	report.Total = len(results)
+	report.Requests = make([]RequestDiff, 0, len(results))
+	for i, result := range results {
+		diff := diffRequest(rn.entries[i], result, responses[i], report.Headers, ignore)
+		switch {
+		case diff.Unavailable != "":
+			report.Unavailable++
+		case diff.Identical:
+			report.Identical++
+		default:
+			report.Different++
+		}
+		report.Requests = append(report.Requests, diff)
+	}
+	return report, true
+}
+
+func diffRequest(entry journal.Entry, result Result, answer *response, headers []string, ignore []*regexp.Regexp) RequestDiff {
+	diff := RequestDiff{RequestID: result.RequestID, Method: result.Method, Path: result.Path}
+	switch {
+	case result.Skipped != "":
+		diff.Unavailable = "skipped: " + result.Skipped
+		return diff
+	case result.Error != "":
+		diff.Unavailable = "failed: " + result.Error
+		return diff
+	case answer == nil:
+		diff.Unavailable = "pending"
+		return diff
+	}
+
+	diff.Status = &StatusDiff{Original: entry.Status, Replay: result.Status, Identical: entry.Status == result.Status}
+	original := http.Header(entry.ResponseHeaders)
+	for _, name := range headers {
+		before := strings.Join(original.Values(name), ", ")
+		after := strings.Join(answer.header.Values(name), ", ")
+		if before != after {
+			diff.Headers = append(diff.Headers, HeaderDiff{Name: http.CanonicalHeaderKey(name), Original: before, Replay: after})
+		}
+	}
+	diff.Body = diffBody([]byte(entry.ResponseBody), entry.ResponseSize > int64(len(entry.ResponseBody)), answer, ignore)
+	diff.Identical = diff.Status.Identical && len(diff.Headers) == 0 && diff.Body.Identical
+	return diff
+}
+
+func diffBody(original []byte, truncated bool, answer *response, ignore []*regexp.Regexp) *BodyDiff {
+	replay := answer.body
+	diff := &BodyDiff{Truncated: truncated || answer.truncated}
+	switch {
+	case len(original) > 0 && len(replay) > 0 && json.Valid(original) && json.Valid(replay):
+		diff.Kind = "json"
+		var before, after interface{}
+		json.Unmarshal(original, &before)
+		json.Unmarshal(replay, &after)
+		d := &jsonDiff{ignore: ignore}
+		d.compare("$", before, after)
+		diff.Differences, diff.Omitted = d.differences, d.omitted
+		diff.Identical = d.total() == 0
+	case utf8.Valid(original) && utf8.Valid(replay):
+		diff.Kind = "text"
+		diff.Identical = bytes.Equal(original, replay)
+		if !diff.Identical {
+			diff.Lines, diff.Omitted = diffLines(strings.Split(string(original), "\n"), strings.Split(string(replay), "\n"))
+		}
+	default:
+		diff.Kind = "binary"
+		diff.Identical = bytes.Equal(original, replay)
+	}
+	return diff
+}
+
+// ignorePattern matches a JSON path and everything under it.
+func ignorePattern(path string) *regexp.Regexp {
+	quoted := regexp.QuoteMeta(path)
+	quoted = strings.ReplaceAll(quoted, `\[\*\]`, `\[\d+\]`)
+	quoted = strings.ReplaceAll(quoted, `\.\*`, `(?:\.[^.\[]+|\["(?:[^"\\]|\\.)*"\])`)
+	return regexp.MustCompile(`^` + quoted + `(?:[.\[].*)?$`)
+}
+
+var identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// jsonDiff walks two decoded JSON values, collecting their differences.
+type jsonDiff struct {
+	ignore      []*regexp.Regexp
+	differences []Difference
+	omitted     int
+}
+
+func (d *jsonDiff) total() int {
+	return len(d.differences) + d.omitted
+}
+
+func (d *jsonDiff) add(difference Difference) {
+	if len(d.differences) < maxDifferences {
+		d.differences = append(d.differences, difference)
+	} else {
+		d.omitted++
+	}
+}
+
+func (d *jsonDiff) ignored(path string) bool {
+	for _, pattern := range d.ignore {
+		if pattern.MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *jsonDiff) compare(path string, before, after interface{}) {
+	if d.ignored(path) {
+		return
+	}
+	switch b := before.(type) {
+	case map[string]interface{}:
+		if a, ok := after.(map[string]interface{}); ok {
+			keys := make([]string, 0, len(b)+len(a))
+			for key := range b {
+				keys = append(keys, key)
+			}
+			for key := range a {
+				if _, ok := b[key]; !ok {
+					keys = append(keys, key)
+				}
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				child := path + "[" + strconv.Quote(key) + "]"
+				if identifier.MatchString(key) {
+					child = path + "." + key
+				}
+				d.member(child, b, a, key)
+			}
+			return
+		}
+	case []interface{}:
+		if a, ok := after.([]interface{}); ok {
+			for i := 0; i < max(len(b), len(a)); i++ {
+				child := path + "[" + strconv.Itoa(i) + "]"
+				switch {
+				case i >= len(a):
+					d.removed(child, b[i])
+				case i >= len(b):
+					d.added(child, a[i])
+				default:
+					d.compare(child, b[i], a[i])
+				}
+			}
+			return
+		}
+	}
+	if !reflect.DeepEqual(before, after) {
+		d.add(Difference{Path: path, Kind: "changed", Original: before, Replay: after})
+	}
+}
+
+func (d *jsonDiff) member(path string, before, after map[string]interface{}, key string) {
+	b, inBefore := before[key]
+	a, inAfter := after[key]
+	switch {
+	case !inAfter:
+		d.removed(path, b)
+	case !inBefore:
+		d.added(path, a)
+	default:
+		d.compare(path, b, a)
+	}
+}
+
+func (d *jsonDiff) removed(path string, value interface{}) {
+	if !d.ignored(path) {
+		d.add(Difference{Path: path, Kind: "removed", Original: value})
+	}
+}
+
+func (d *jsonDiff) added(path string, value interface{}) {
+	if !d.ignored(path) {
+		d.add(Difference{Path: path, Kind: "added", Replay: value})
+	}
+}
+
+// diffLines lists the lines removed ("- ") and added ("+ ") between two
+// texts, from their longest common subsequence once the common start and
+// end are set aside.
+func diffLines(before, after []string) ([]string, int) {
+	for len(before) > 0 && len(after) > 0 && before[0] == after[0] {
+		before, after = before[1:], after[1:]
+	}
+	for len(before) > 0 && len(after) > 0 && before[len(before)-1] == after[len(after)-1] {
+		before, after = before[:len(before)-1], after[:len(after)-1]
+	}
+	if len(before) > maxDiffLines || len(after) > maxDiffLines {
+		return nil, len(before) + len(after)
+	}
+
+	// common[i][j] is the length of the common subsequence of before[i:]
+	// and after[j:]
+	common := make([][]int, len(before)+1)
+	for i := range common {
+		common[i] = make([]int, len(after)+1)
+	}
+	for i := len(before) - 1; i >= 0; i-- {
+		for j := len(after) - 1; j >= 0; j-- {
+			if before[i] == after[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else {
+				common[i][j] = max(common[i+1][j], common[i][j+1])
+			}
+		}
+	}
+
+	var lines []string
+	omitted := 0
+	emit := func(line string) {
+		if len(lines) < maxDiffOutput {
+			lines = append(lines, line)
+		} else {
+			omitted++
+		}
+	}
+	i, j := 0, 0
+	for i < len(before) || j < len(after) {
+		switch {
+		case i < len(before) && j < len(after) && before[i] == after[j]:
+			i++
+			j++
+		case j == len(after) || i < len(before) && common[i+1][j] >= common[i][j+1]:
+			emit("- " + before[i])
+			i++
+		default:
+			emit("+ " + after[j])
+			j++
+		}
+	}
+	return lines, omitted
+}
@@ -0,0 +1,96 @@
+package replay
+
+import (
+	"bytes"
+	"encoding/json"
+	"html/template"
+	"strings"
+)
+
+var diffTemplate = template.Must(template.New("diff").Funcs(template.FuncMap{
+	"value": func(v interface{}) string {
+		data, _ := json.Marshal(v)
+		return string(data)
+	},
+	"added": func(line string) bool { return strings.HasPrefix(line, "+ ") },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Replay diff {{.RunID}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin: 0.5em 0; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; vertical-align: top; }
+code, pre { font-family: monospace; }
+pre { background: #f6f6f6; padding: 0.5em; overflow-x: auto; }
+.identical { color: #1a7f37; }
+.different { color: #cf222e; }
+.unavailable { color: #888; }
+.removed { background: #ffebe9; }
+.added { background: #dafbe1; }
+details { margin: 0.3em 0; }
+</style>
+</head>
+<body>
+<h1>Replay diff {{.RunID}}</h1>
+<p>Target <code>{{.Target}}</code>, run {{.State}}, generated {{.GeneratedAt.Format "2006-01-02 15:04:05 MST"}}.</p>
+<p>Headers compared: {{range $i, $h := .Headers}}{{if $i}}, {{end}}<code>{{$h}}</code>{{end}}.
+{{- if .Ignore}} JSON paths ignored: {{range $i, $p := .Ignore}}{{if $i}}, {{end}}<code>{{$p}}</code>{{end}}.{{end}}</p>
+<table>
+<tr><th>Requests</th><th>Identical</th><th>Different</th><th>Unavailable</th></tr>
+<tr><td>{{.Total}}</td><td class="identical">{{.Identical}}</td><td class="different">{{.Different}}</td><td class="unavailable">{{.Unavailable}}</td></tr>
+</table>
+{{range .Requests}}
+<details{{if not .Identical}}{{if not .Unavailable}} open{{end}}{{end}}>
+<summary>
+{{- if .Unavailable}}<span class="unavailable">unavailable</span>
+{{- else if .Identical}}<span class="identical">identical</span>
+{{- else}}<span class="different">different</span>{{end}}
+<code>{{.Method}} {{.Path}}</code> ({{.RequestID}})</summary>
+{{- if .Unavailable}}
+<p class="unavailable">{{.Unavailable}}</p>
+{{- else}}
+<table>
+<tr><th></th><th>Original</th><th>Replay</th></tr>
+<tr{{if not .Status.Identical}} class="different"{{end}}><th>Status</th><td>{{.Status.Original}}</td><td>{{.Status.Replay}}</td></tr>
+{{- range .Headers}}
+<tr class="different"><th>{{.Name}}</th><td><code>{{.Original}}</code></td><td><code>{{.Replay}}</code></td></tr>
+{{- end}}
+</table>
+{{- with .Body}}
+<p>Body ({{.Kind}}{{if .Truncated}}, first 64 KiB compared{{end}}):
+{{if .Identical}}<span class="identical">identical</span>{{else}}<span class="different">different</span>{{end}}</p>
+{{- if .Differences}}
+<table>
+<tr><th>Path</th><th>Change</th><th>Original</th><th>Replay</th></tr>
+{{- range .Differences}}
+<tr><td><code>{{.Path}}</code></td><td>{{.Kind}}</td>
+<td>{{if ne .Kind "added"}}<code>{{value .Original}}</code>{{end}}</td>
+<td>{{if ne .Kind "removed"}}<code>{{value .Replay}}</code>{{end}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- if .Lines}}
+<pre>{{range .Lines}}<span class="{{if added .}}added{{else}}removed{{end}}">{{.}}</span>
+{{end}}</pre>
+{{- end}}
+{{- if .Omitted}}
+<p class="unavailable">{{.Omitted}} more not shown</p>
+{{- end}}
+{{- end}}
+{{- end}}
+</details>
+{{- end}}
+</body>
+</html>
+`))
+
+// HTML renders the report as a standalone page.
+func (report DiffReport) HTML() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := diffTemplate.Execute(&buf, report); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,133 @@
+// Package replay sends HTTP traffic recorded in the request journal again,
+// to the real service or another mock, at its original pace or a multiple
+// of it, turning the traffic of a test run into a load and regression
+// driver.
+package replay
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"mockserver/internal/journal"
+)
+
+// Header marks the replayed requests with the ID of their run. Requests
+// carrying it are never replayed again.
+const Header = "X-Mock-Replay"
+
+// States of a run.
+const (
+	StateRunning   = "running"
+	StateCompleted = "completed"
+	StateCancelled = "cancelled"
+)
+
+const (
+	// maxRuns is the number of runs kept, the oldest finished ones being
+	// forgotten first.
+	maxRuns = 20
+	// maxInFlight bounds the requests awaiting a response; beyond it the
+	// replay falls behind schedule, which shows as lag.
+	maxInFlight = 256
+	// requestTimeout bounds a replayed request.
+	requestTimeout = 30 * time.Second
+)
+
+// Request selects the journal entries to replay and where to send them.
+// Only inbound HTTP requests are replayed; the selection fields filter them
+// like the query parameters of /__admin/requests, and IDs picks entries by
+// ID. The requests are sent to Target, a base URL their paths are appended
+// to, spaced like the originals divided by Speed (1 by default, 0 for no
+// spacing).
+type Request struct {
+	Target             string    `json:"target"`
+	Speed              *float64  `json:"speed,omitempty"`
+	InsecureSkipVerify bool      `json:"insecure_skip_verify,omitempty"`
+	IDs                []string  `json:"ids,omitempty"`
+	Method             string    `json:"method,omitempty"`
+	Path               string    `json:"path,omitempty"`
+	Status             int       `json:"status,omitempty"`
+	StubID             string    `json:"stub_id,omitempty"`
+	CorrelationID      string    `json:"correlation_id,omitempty"`
+	Since              time.Time `json:"since"`
+	Until              time.Time `json:"until"`
+	Limit              int       `json:"limit,omitempty"`
+}
+
+func (r Request) target() (*url.URL, error) {
+	u, err := url.Parse(r.Target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid target %q, expected an http or https base URL", r.Target)
+	}
+	return u, nil
+}
+
+func (r Request) speed() (float64, error) {
+	if r.Speed == nil {
+		return 1, nil
+	}
+	if *r.Speed < 0 {
+		return 0, fmt.Errorf("invalid speed %v", *r.Speed)
+	}
+	return *r.Speed, nil
+}
+
+func (r Request) filter() journal.Filter {
+	return journal.Filter{
+		Protocol:      journal.ProtocolHTTP,
+		Method:        r.Method,
+		Path:          r.Path,
+		Status:        r.Status,
+		StubID:        r.StubID,
+		CorrelationID: r.CorrelationID,
+		Since:         r.Since,
+		Until:         r.Until,
+		Limit:         r.Limit,
+	}
+}
+
+// Run is a replay and its progress. Sent counts the requests dispatched;
+// Failed those that got no response and Mismatched those answered with
+// another status than the original. LagMs is how far behind schedule the
+// most delayed request was sent.
+type Run struct {
+	ID         string       `json:"id"`
+	State      string       `json:"state"`
+	Target     string       `json:"target"`
+	Speed      float64      `json:"speed"`
+	StartedAt  time.Time    `json:"started_at"`
+	FinishedAt *time.Time   `json:"finished_at,omitempty"`
+	Total      int          `json:"total"`
+	Sent       int          `json:"sent"`
+	Failed     int          `json:"failed"`
+	Mismatched int          `json:"mismatched"`
+	Skipped    int          `json:"skipped"`
+	Latency    LatencyStats `json:"latency_ms"`
+	LagMs      float64      `json:"lag_ms"`
+	Results    []Result     `json:"results"`
+}
+
+// LatencyStats summarizes the latency of the replayed requests next to the
+// recorded one.
+type LatencyStats struct {
+	Mean         float64 `json:"mean"`
+	Max          float64 `json:"max"`
+	OriginalMean float64 `json:"original_mean"`
+}
+
+// Result is the outcome of one replayed request. OffsetMs is when it was
+// due after the start of the run; Skipped tells why it was not sent.
+type Result struct {
+	RequestID         string  `json:"request_id"`
+	Method            string  `json:"method"`
+	Path              string  `json:"path"`
+	OffsetMs          float64 `json:"offset_ms"`
+	LagMs             float64 `json:"lag_ms"`
+	OriginalStatus    int     `json:"original_status"`
+	Status            int     `json:"status,omitempty"`
+	LatencyMs         float64 `json:"latency_ms"`
+	OriginalLatencyMs float64 `json:"original_latency_ms"`
+	Error             string  `json:"error,omitempty"`
+	Skipped           string  `json:"skipped,omitempty"`
+}
@@ -0,0 +1,375 @@
+package replay
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"mockserver/internal/journal"
+	"mockserver/internal/logging"
+)
+
+var logger = logging.For("replay")
+
+// skippedHeaders are connection specific or recomputed by the client, which
+// also negotiates compression itself so that the bodies compared are decoded.
+var skippedHeaders = map[string]bool{
+	"Accept-Encoding":     true,
+	"Connection":          true,
+	"Keep-Alive":          true,
+	"Proxy-Authenticate":  true,
+	"Proxy-Authorization": true,
+	"Proxy-Connection":    true,
+	"Te":                  true,
+	"Trailer":             true,
+	"Transfer-Encoding":   true,
+	"Upgrade":             true,
+	"Content-Length":      true,
+}
+
+// ErrNothingToReplay is returned when no journal entry matches a request.
+var ErrNothingToReplay = errors.New("no recorded HTTP request matches the selection")
+
+// maxBody is the start of the response bodies kept for the diff, as much
+// as the journal keeps of the originals.
+const maxBody = 64 * 1024
+
+// response is what the target answered to a replayed request.
+type response struct {
+	header    http.Header
+	body      []byte
+	truncated bool
+}
+
+// run is a replay in progress; the embedded Run is guarded by mutex. The
+// replayed entries and their responses are kept for the diff.
+type run struct {
+	Run
+	entries   []journal.Entry
+	responses []*response
+	latency   float64
+	original  float64
+	answered  int
+	cancel    context.CancelFunc
+	done      chan struct{}
+	mutex     sync.Mutex
+}
+
+func (r *run) snapshot() Run {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	snapshot := r.Run
+	snapshot.Results = append([]Result(nil), r.Results...)
+	return snapshot
+}
+
+// Replayer starts replays of the journal and keeps the recent runs.
+type Replayer struct {
+	journal *journal.Journal
+	runs    []*run
+	nextID  int
+	mutex   sync.Mutex
+}
+
+func New(j *journal.Journal) *Replayer {
+	return &Replayer{journal: j}
+}
+
+// Start snapshots the journal entries selected by req and replays them in
+// the background.
+func (r *Replayer) Start(req Request) (Run, error) {
+	target, err := req.target()
+	if err != nil {
+		return Run{}, err
+	}
+	speed, err := req.speed()
+	if err != nil {
+		return Run{}, err
+	}
+	entries := r.entries(req)
+	if len(entries) == 0 {
+		return Run{}, ErrNothingToReplay
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	rn := &run{
+		entries:   entries,
+		responses: make([]*response, len(entries)),
+		cancel:    cancel,
+		done:      make(chan struct{}),
+	}
+	rn.State = StateRunning
+	rn.Target = target.String()
+	rn.Speed = speed
+	rn.StartedAt = time.Now()
+	rn.Total = len(entries)
+	rn.Results = make([]Result, len(entries))
+	first := entries[0].Timestamp
+	for i, entry := range entries {
+		rn.Results[i] = Result{
+			RequestID:         entry.ID,
+			Method:            entry.Method,
+			Path:              entry.Path,
+			OffsetMs:          durationMs(schedule(entry.Timestamp.Sub(first), speed)),
+			OriginalStatus:    entry.Status,
+			OriginalLatencyMs: entry.LatencyMs,
+		}
+	}
+
+	r.mutex.Lock()
+	r.nextID++
+	rn.ID = fmt.Sprintf("replay-%d", r.nextID)
+	r.runs = append(r.runs, rn)
+	r.evict()
+	r.mutex.Unlock()
+
+	logger.Info("Replay started", "run_id", rn.ID, "requests", len(entries), "target", rn.Target, "speed", speed)
+	go r.replay(ctx, rn, entries, target, req.InsecureSkipVerify)
+	return rn.snapshot(), nil
+}
+
+// entries returns the inbound requests matching req, oldest first, leaving
+// out the replayed ones.
+func (r *Replayer) entries(req Request) []journal.Entry {
+	var ids map[string]bool
+	if len(req.IDs) > 0 {
+		ids = make(map[string]bool, len(req.IDs))
+		for _, id := range req.IDs {
+			ids[id] = true
+		}
+	}
+	var entries []journal.Entry
+	for _, entry := range r.journal.Find(req.filter()) {
+		if entry.Outbound || http.Header(entry.Headers).Get(Header) != "" {
+			continue
+		}
+		if ids != nil && !ids[entry.ID] {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// evict forgets the oldest finished runs beyond maxRuns.
+func (r *Replayer) evict() {
+	for i := 0; len(r.runs) > maxRuns && i < len(r.runs); {
+		if r.runs[i].snapshot().State == StateRunning {
+			i++
+			continue
+		}
+		r.runs = append(r.runs[:i], r.runs[i+1:]...)
+	}
+}
+
+// schedule is when a request recorded at offset is due at speed.
+func schedule(offset time.Duration, speed float64) time.Duration {
+	if speed == 0 {
+		return 0
+	}
+	return time.Duration(float64(offset) / speed)
+}
+
+func (r *Replayer) replay(ctx context.Context, rn *run, entries []journal.Entry, target *url.URL, insecure bool) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: insecure}
+	transport.MaxIdleConnsPerHost = maxInFlight
+	defer transport.CloseIdleConnections()
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   requestTimeout,
+		// Redirects are results of their own, as recorded in the journal
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+
+	start := time.Now()
+	first := entries[0].Timestamp
+	inflight := make(chan struct{}, maxInFlight)
+	var wg sync.WaitGroup
+dispatch:
+	for i, entry := range entries {
+		due := start.Add(schedule(entry.Timestamp.Sub(first), rn.Speed))
+		timer := time.NewTimer(time.Until(due))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			break dispatch
+		}
+		select {
+		case inflight <- struct{}{}:
+		case <-ctx.Done():
+			break dispatch
+		}
+
+		wg.Add(1)
+		go func(i int, entry journal.Entry, lag time.Duration) {
+			defer wg.Done()
+			defer func() { <-inflight }()
+			r.send(ctx, client, rn, i, entry, target, lag)
+		}(i, entry, time.Since(due))
+	}
+	wg.Wait()
+
+	rn.mutex.Lock()
+	rn.State = StateCompleted
+	if ctx.Err() != nil {
+		rn.State = StateCancelled
+	}
+	finished := time.Now()
+	rn.FinishedAt = &finished
+	for i := range rn.Results {
+		if result := &rn.Results[i]; result.Status == 0 && result.Error == "" && result.Skipped == "" {
+			result.Skipped = "cancelled"
+			rn.Skipped++
+		}
+	}
+	if rn.answered > 0 {
+		rn.Latency.Mean = rn.latency / float64(rn.answered)
+		rn.Latency.OriginalMean = rn.original / float64(rn.answered)
+	}
+	logger.Info("Replay finished", "run_id", rn.ID, "state", rn.State,
+		"sent", rn.Sent, "failed", rn.Failed, "mismatched", rn.Mismatched, "skipped", rn.Skipped)
+	rn.mutex.Unlock()
+	rn.cancel()
+	close(rn.done)
+}
+
+// send replays one entry and records its result.
+func (r *Replayer) send(ctx context.Context, client *http.Client, rn *run, i int, entry journal.Entry, target *url.URL, lag time.Duration) {
+	result := rn.Results[i]
+	result.LagMs = durationMs(lag)
+	var answer *response
+	defer func() {
+		rn.mutex.Lock()
+		defer rn.mutex.Unlock()
+		rn.Results[i] = result
+		rn.responses[i] = answer
+		if result.LagMs > rn.LagMs {
+			rn.LagMs = result.LagMs
+		}
+		switch {
+		case result.Skipped != "":
+			rn.Skipped++
+			return
+		case result.Error != "":
+			rn.Failed++
+		case result.Status != result.OriginalStatus:
+			rn.Mismatched++
+		}
+		rn.Sent++
+		if result.Error == "" {
+			rn.answered++
+			rn.latency += result.LatencyMs
+			rn.original += result.OriginalLatencyMs
+			if result.LatencyMs > rn.Latency.Max {
+				rn.Latency.Max = result.LatencyMs
+			}
+		}
+	}()
+
+	switch {
+	// The journal keeps the start of large bodies only
+	case entry.RequestSize > int64(len(entry.Body)):
+		result.Skipped = "body truncated in the journal"
+		return
+	case entry.Status == http.StatusSwitchingProtocols:
+		result.Skipped = "protocol upgrade"
+		return
+	}
+
+	u := *target
+	u.Path = strings.TrimRight(target.Path, "/") + entry.Path
+	u.RawPath = ""
+	u.RawQuery = entry.Query
+	req, err := http.NewRequestWithContext(ctx, entry.Method, u.String(), strings.NewReader(entry.Body))
+	if err != nil {
+		result.Skipped = err.Error()
+		return
+	}
+	for name, values := range entry.Headers {
+		if !skippedHeaders[http.CanonicalHeaderKey(name)] {
+			req.Header[name] = values
+		}
+	}
+	req.Header.Set(Header, rn.ID)
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			result.Skipped = "cancelled"
+			return
+		}
+		result.Error = err.Error()
+		result.LatencyMs = durationMs(time.Since(start))
+		return
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxBody+1))
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	result.Status = resp.StatusCode
+	result.LatencyMs = durationMs(time.Since(start))
+	answer = &response{header: resp.Header, body: body}
+	if len(body) > maxBody {
+		answer.body, answer.truncated = body[:maxBody], true
+	}
+}
+
+// Runs returns the kept runs, oldest first.
+func (r *Replayer) Runs() []Run {
+	r.mutex.Lock()
+	runs := append([]*run(nil), r.runs...)
+	r.mutex.Unlock()
+
+	list := make([]Run, 0, len(runs))
+	for _, rn := range runs {
+		list = append(list, rn.snapshot())
+	}
+	return list
+}
+
+// Get returns a run by ID.
+func (r *Replayer) Get(id string) (Run, bool) {
+	if rn := r.find(id); rn != nil {
+		return rn.snapshot(), true
+	}
+	return Run{}, false
+}
+
+// Cancel stops a run, leaving the requests not sent yet skipped, and waits
+// briefly for it to finish.
+func (r *Replayer) Cancel(id string) (Run, bool) {
+	rn := r.find(id)
+	if rn == nil {
+		return Run{}, false
+	}
+	rn.cancel()
+	select {
+	case <-rn.done:
+	case <-time.After(time.Second):
+	}
+	return rn.snapshot(), true
+}
+
+func (r *Replayer) find(id string) *run {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, rn := range r.runs {
+		if rn.ID == id {
+			return rn
+		}
+	}
+	return nil
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
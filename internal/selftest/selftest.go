@@ -0,0 +1,301 @@
+// Package selftest exercises the mock server from inside the process: each
+// listener it runs and a sample of the HTTP stubs, end to end over the
+// network, so a deployment can check the mock itself before tests run.
+package selftest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+
+	"mockserver/internal/journal"
+	"mockserver/internal/stubs"
+)
+
+// DefaultStubSample is the number of stubs exercised per run.
+const DefaultStubSample = 5
+
+// retryInterval is the pause between attempts while waiting for listeners
+// to come up.
+const retryInterval = 100 * time.Millisecond
+
+// Check kinds.
+const (
+	KindListener = "listener"
+	KindStub     = "stub"
+)
+
+// Check is one probe. Run returns a short description of what it observed.
+type Check struct {
+	Name   string
+	Kind   string
+	Target string
+	Run    func(ctx context.Context) (string, error)
+}
+
+// Result is the outcome of one check.
+type Result struct {
+	Name       string  `json:"name"`
+	Kind       string  `json:"kind"`
+	Target     string  `json:"target"`
+	Passed     bool    `json:"passed"`
+	Detail     string  `json:"detail,omitempty"`
+	Error      string  `json:"error,omitempty"`
+	DurationMs float64 `json:"duration_ms"`
+}
+
+// Report is the outcome of a run.
+type Report struct {
+	Passed     bool      `json:"passed"`
+	Timestamp  time.Time `json:"timestamp"`
+	DurationMs float64   `json:"duration_ms"`
+	Tests      int       `json:"tests"`
+	Failures   int       `json:"failures"`
+	Results    []Result  `json:"results"`
+}
+
+// Suite holds the listener checks and the stubs to sample from. Its traffic
+// carries journal.UnrecordedHeader, so it never shows up in the request
+// journal or verification reports.
+type Suite struct {
+	checks  []Check
+	engine  *stubs.Engine
+	httpURL string
+	mutex   sync.Mutex
+}
+
+func New() *Suite {
+	return &Suite{}
+}
+
+// Add registers a check.
+func (s *Suite) Add(check Check) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.checks = append(s.checks, check)
+}
+
+// SampleStubs makes every run exercise stubs of the engine through the HTTP
+// listener at baseURL.
+func (s *Suite) SampleStubs(engine *stubs.Engine, baseURL string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.engine, s.httpURL = engine, baseURL
+}
+
+// Run performs every check once, with up to sample stubs (none when
+// negative).
+func (s *Suite) Run(ctx context.Context, sample int) Report {
+	return s.run(ctx, sample, false)
+}
+
+// RunUntilReady retries failing checks until they pass or ctx is done, for
+// use while the listeners are still starting.
+func (s *Suite) RunUntilReady(ctx context.Context, sample int) Report {
+	return s.run(ctx, sample, true)
+}
+
+func (s *Suite) run(ctx context.Context, sample int, retry bool) Report {
+	s.mutex.Lock()
+	checks := append([]Check(nil), s.checks...)
+	if s.engine != nil && sample > 0 {
+		checks = append(checks, stubChecks(s.engine.List(), s.httpURL, sample)...)
+	}
+	s.mutex.Unlock()
+
+	start := time.Now()
+	report := Report{Timestamp: start, Results: make([]Result, len(checks))}
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			report.Results[i] = runCheck(ctx, check, retry)
+		}()
+	}
+	wg.Wait()
+
+	report.Tests = len(checks)
+	for _, result := range report.Results {
+		if !result.Passed {
+			report.Failures++
+		}
+	}
+	report.Passed = report.Failures == 0
+	report.DurationMs = float64(time.Since(start).Microseconds()) / 1000
+	return report
+}
+
+func runCheck(ctx context.Context, check Check, retry bool) Result {
+	result := Result{Name: check.Name, Kind: check.Kind, Target: check.Target}
+	start := time.Now()
+	for {
+		detail, err := check.Run(ctx)
+		if err == nil {
+			result.Passed, result.Detail, result.Error = true, detail, ""
+			break
+		}
+		result.Detail, result.Error = detail, err.Error()
+		if !retry || !wait(ctx) {
+			break
+		}
+	}
+	result.DurationMs = float64(time.Since(start).Microseconds()) / 1000
+	return result
+}
+
+// wait pauses before the next attempt and reports whether there is time
+// left for one.
+func wait(ctx context.Context) bool {
+	select {
+	case <-time.After(retryInterval):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// stubChecks picks up to sample stubs that can be requested without
+// knowing their matchers: a plain path (parameters and wildcards are filled
+// in), no query, header or body matchers, and no failure injection or
+// caching whose state the request would change.
+func stubChecks(list []stubs.Stub, baseURL string, sample int) []Check {
+	var eligible []stubs.Stub
+	for _, stub := range list {
+		req := stub.Request
+		if req.Path == "" || len(req.Query) > 0 || len(req.Headers) > 0 || len(req.Body) > 0 ||
+			stub.Fail != nil || stub.Response.Cache != nil {
+			continue
+		}
+		eligible = append(eligible, stub)
+	}
+	rand.Shuffle(len(eligible), func(i, j int) { eligible[i], eligible[j] = eligible[j], eligible[i] })
+	if len(eligible) > sample {
+		eligible = eligible[:sample]
+	}
+
+	checks := make([]Check, len(eligible))
+	for i, stub := range eligible {
+		method := strings.ToUpper(stub.Request.Method)
+		if method == "" || method == "ANY" {
+			method = http.MethodGet
+		}
+		path := samplePath(stub.Request.Path)
+		want := stub.Response.Status
+		if want == 0 {
+			want = http.StatusOK
+		}
+		name := stub.ID
+		if stub.Name != "" {
+			name += " (" + stub.Name + ")"
+		}
+		checks[i] = Check{
+			Name:   name,
+			Kind:   KindStub,
+			Target: method + " " + path,
+			Run: func(ctx context.Context) (string, error) {
+				return HTTPStatus(ctx, method, baseURL+path, want)
+			},
+		}
+	}
+	return checks
+}
+
+// samplePath turns a stub path into a concrete one.
+func samplePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") || segment == "*" {
+			segments[i] = "selftest"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// HTTPStatus requests url and expects the status want.
+func HTTPStatus(ctx context.Context, method, url string, want int) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set(journal.UnrecordedHeader, "selftest")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	n, _ := io.Copy(io.Discard, resp.Body)
+	detail := fmt.Sprintf("%d, %d bytes", resp.StatusCode, n)
+	if resp.StatusCode != want {
+		return detail, fmt.Errorf("expected status %d, got %d", want, resp.StatusCode)
+	}
+	return detail, nil
+}
+
+// HTTP returns a check that expects 200 from url.
+func HTTP(name, url string) Check {
+	return Check{Name: name, Kind: KindListener, Target: url, Run: func(ctx context.Context) (string, error) {
+		return HTTPStatus(ctx, http.MethodGet, url, http.StatusOK)
+	}}
+}
+
+// WebSocket returns a check that completes a handshake on url and closes
+// the connection cleanly.
+func WebSocket(name, url string) Check {
+	return Check{Name: name, Kind: KindListener, Target: url, Run: func(ctx context.Context) (string, error) {
+		header := http.Header{journal.UnrecordedHeader: {"selftest"}}
+		conn, resp, err := websocket.DefaultDialer.DialContext(ctx, url, header)
+		if err != nil {
+			if resp != nil {
+				return fmt.Sprintf("handshake answered %d", resp.StatusCode), err
+			}
+			return "", err
+		}
+		defer conn.Close()
+		conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "selftest"), time.Now().Add(time.Second))
+		return "handshake completed", nil
+	}}
+}
+
+// GRPC returns a check that calls the standard health service at addr. Any
+// answer passes: the serving status may be set to anything on purpose.
+func GRPC(name, addr string) Check {
+	return Check{Name: name, Kind: KindListener, Target: addr, Run: func(ctx context.Context) (string, error) {
+		conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			return "", err
+		}
+		defer conn.Close()
+		ctx = metadata.AppendToOutgoingContext(ctx, journal.UnrecordedHeader, "selftest")
+		resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+		if err != nil {
+			return "", err
+		}
+		return "health " + resp.GetStatus().String(), nil
+	}}
+}
+
+// TCP returns a check that connects to addr.
+func TCP(name, addr string) Check {
+	return Check{Name: name, Kind: KindListener, Target: addr, Run: func(ctx context.Context) (string, error) {
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return "", err
+		}
+		conn.Close()
+		return "connected", nil
+	}}
+}
@@ -0,0 +1,620 @@
+package sftp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Packet types of SFTP version 3 (draft-ietf-secsh-filexfer-02).
+const (
+	fxpInit     = 1
+	fxpVersion  = 2
+	fxpOpen     = 3
+	fxpClose    = 4
+	fxpRead     = 5
+	fxpWrite    = 6
+	fxpLstat    = 7
+	fxpFstat    = 8
+	fxpSetstat  = 9
+	fxpFsetstat = 10
+	fxpOpendir  = 11
+	fxpReaddir  = 12
+	fxpRemove   = 13
+	fxpMkdir    = 14
+	fxpRmdir    = 15
+	fxpRealpath = 16
+	fxpStat     = 17
+	fxpRename   = 18
+	fxpStatus   = 101
+	fxpHandle   = 102
+	fxpData     = 103
+	fxpName     = 104
+	fxpAttrs    = 105
+)
+
+// Status codes.
+const (
+	fxOK               = 0
+	fxEOF              = 1
+	fxNoSuchFile       = 2
+	fxPermissionDenied = 3
+	fxFailure          = 4
+	fxBadMessage       = 5
+	fxOpUnsupported    = 8
+)
+
+// Open flags.
+const (
+	fxfRead   = 0x01
+	fxfWrite  = 0x02
+	fxfAppend = 0x04
+	fxfCreat  = 0x08
+	fxfTrunc  = 0x10
+	fxfExcl   = 0x20
+)
+
+// Attribute flags.
+const (
+	attrSize        = 0x01
+	attrUIDGID      = 0x02
+	attrPermissions = 0x04
+	attrACModTime   = 0x08
+)
+
+const (
+	// maxPacket bounds incoming packets; clients write at most 32 KiB at
+	// a time.
+	maxPacket = 256 << 10
+	// maxRead bounds the data returned by one read.
+	maxRead = 64 << 10
+	// readdirBatch is the number of entries returned per readdir.
+	readdirBatch = 100
+)
+
+var (
+	errBadMessage = errors.New("sftp: malformed packet")
+	errDisconnect = errors.New("sftp: fault dropped the connection")
+	// statusOK reports the success of requests without a result.
+	statusOK = &statusError{fxOK, "OK"}
+)
+
+// statusError is an error answered with a status packet.
+type statusError struct {
+	code    uint32
+	message string
+}
+
+func (e *statusError) Error() string {
+	return e.message
+}
+
+type handle struct {
+	name    string
+	file    *os.File
+	entries []fs.FileInfo
+}
+
+// session serves one sftp subsystem. Requests are answered in order.
+type session struct {
+	server  *Server
+	conn    *ssh.ServerConn
+	channel ssh.Channel
+	handles map[string]*handle
+	nextID  int
+}
+
+func (s *session) serve() error {
+	for {
+		packet, err := s.readPacket()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if err := s.respond(packet); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *session) readPacket() ([]byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(s.channel, header[:]); err != nil {
+		return nil, err
+	}
+	length := binary.BigEndian.Uint32(header[:])
+	if length == 0 || length > maxPacket {
+		return nil, fmt.Errorf("sftp: packet of %d bytes", length)
+	}
+	packet := make([]byte, length)
+	if _, err := io.ReadFull(s.channel, packet); err != nil {
+		return nil, err
+	}
+	return packet, nil
+}
+
+func (s *session) send(packet []byte) error {
+	out := make([]byte, 4, 4+len(packet))
+	binary.BigEndian.PutUint32(out, uint32(len(packet)))
+	_, err := s.channel.Write(append(out, packet...))
+	return err
+}
+
+func (s *session) closeHandles() {
+	for _, h := range s.handles {
+		if h.file != nil {
+			h.file.Close()
+		}
+	}
+}
+
+// respond dispatches a request and writes its response. Only I/O errors on
+// the channel and disconnect faults end the session.
+func (s *session) respond(packet []byte) error {
+	r := &reader{data: packet[1:]}
+	kind := packet[0]
+	if kind == fxpInit {
+		return s.send(newPacket(fxpVersion).uint32(3).bytes())
+	}
+
+	id := r.uint32()
+	response, err := s.dispatch(kind, id, r)
+	if errors.Is(err, errDisconnect) {
+		return err
+	}
+	if err == nil && r.err != nil {
+		err = &statusError{fxBadMessage, "malformed request"}
+	}
+	if err != nil {
+		response = statusPacket(id, err)
+	}
+	return s.send(response)
+}
+
+func (s *session) dispatch(kind byte, id uint32, r *reader) ([]byte, error) {
+	switch kind {
+	case fxpRealpath:
+		name := cleanPath(r.string())
+		// No attributes follow the name
+		return newPacket(fxpName).uint32(id).uint32(1).string(name).string(name).uint32(0).bytes(), nil
+
+	case fxpStat, fxpLstat:
+		name := cleanPath(r.string())
+		if err := s.check("stat", name); err != nil {
+			return nil, err
+		}
+		stat := os.Stat
+		if kind == fxpLstat {
+			stat = os.Lstat
+		}
+		info, err := stat(s.local(name))
+		if err != nil {
+			return nil, err
+		}
+		return newPacket(fxpAttrs).uint32(id).attrs(info).bytes(), nil
+
+	case fxpFstat:
+		h, err := s.lookup(r.string())
+		if err != nil {
+			return nil, err
+		}
+		if err := s.check("stat", h.name); err != nil {
+			return nil, err
+		}
+		info, err := os.Stat(s.local(h.name))
+		if err != nil {
+			return nil, err
+		}
+		return newPacket(fxpAttrs).uint32(id).attrs(info).bytes(), nil
+
+	case fxpOpen:
+		return s.open(id, cleanPath(r.string()), r.uint32())
+
+	case fxpOpendir:
+		name := cleanPath(r.string())
+		if err := s.check("list", name); err != nil {
+			return nil, err
+		}
+		entries, err := readDir(s.local(name))
+		if err != nil {
+			return nil, err
+		}
+		return s.newHandle(id, &handle{name: name, entries: entries}), nil
+
+	case fxpReaddir:
+		h, err := s.lookup(r.string())
+		if err != nil {
+			return nil, err
+		}
+		if h.file != nil {
+			return nil, &statusError{fxFailure, "not a directory handle"}
+		}
+		if len(h.entries) == 0 {
+			return nil, &statusError{fxEOF, "end of directory"}
+		}
+		batch := h.entries[:min(readdirBatch, len(h.entries))]
+		h.entries = h.entries[len(batch):]
+		p := newPacket(fxpName).uint32(id).uint32(uint32(len(batch)))
+		for _, info := range batch {
+			p.string(info.Name()).string(longName(info)).attrs(info)
+		}
+		return p.bytes(), nil
+
+	case fxpRead:
+		h, err := s.lookup(r.string())
+		if err != nil {
+			return nil, err
+		}
+		return s.read(id, h, int64(r.uint64()), int(r.uint32()))
+
+	case fxpWrite:
+		h, err := s.lookup(r.string())
+		if err != nil {
+			return nil, err
+		}
+		offset, data := int64(r.uint64()), r.string()
+		if r.err != nil {
+			return nil, errBadMessage
+		}
+		if h.file == nil {
+			return nil, &statusError{fxFailure, "not a file handle"}
+		}
+		if err := s.check("write", h.name); err != nil {
+			return nil, err
+		}
+		if _, err := h.file.WriteAt([]byte(data), offset); err != nil {
+			return nil, err
+		}
+		return nil, statusOK
+
+	case fxpClose:
+		key := r.string()
+		h, err := s.lookup(key)
+		if err != nil {
+			return nil, err
+		}
+		delete(s.handles, key)
+		if h.file != nil {
+			if err := h.file.Close(); err != nil {
+				return nil, err
+			}
+		}
+		return nil, statusOK
+
+	case fxpSetstat, fxpFsetstat:
+		var name string
+		if kind == fxpSetstat {
+			name = cleanPath(r.string())
+		} else {
+			h, err := s.lookup(r.string())
+			if err != nil {
+				return nil, err
+			}
+			name = h.name
+		}
+		return nil, s.setstat(name, r)
+
+	case fxpRemove:
+		return nil, s.change("remove", cleanPath(r.string()), os.Remove)
+
+	case fxpRmdir:
+		return nil, s.change("rmdir", cleanPath(r.string()), os.Remove)
+
+	case fxpMkdir:
+		return nil, s.change("mkdir", cleanPath(r.string()), func(local string) error {
+			return os.Mkdir(local, 0o755)
+		})
+
+	case fxpRename:
+		from, to := cleanPath(r.string()), cleanPath(r.string())
+		if err := s.check("rename", to); err != nil {
+			return nil, err
+		}
+		return nil, s.change("rename", from, func(local string) error {
+			if _, err := os.Lstat(s.local(to)); err == nil {
+				return &statusError{fxFailure, "target exists"}
+			}
+			return os.Rename(local, s.local(to))
+		})
+	}
+	return nil, &statusError{fxOpUnsupported, fmt.Sprintf("operation %d not supported", kind)}
+}
+
+func (s *session) open(id uint32, name string, pflags uint32) ([]byte, error) {
+	if err := s.check("open", name); err != nil {
+		return nil, err
+	}
+	flags := os.O_RDONLY
+	switch {
+	case pflags&fxfRead != 0 && pflags&fxfWrite != 0:
+		flags = os.O_RDWR
+	case pflags&fxfWrite != 0:
+		flags = os.O_WRONLY
+	}
+	if pflags&fxfAppend != 0 {
+		flags |= os.O_APPEND
+	}
+	if pflags&fxfCreat != 0 {
+		flags |= os.O_CREATE
+	}
+	if pflags&fxfTrunc != 0 {
+		flags |= os.O_TRUNC
+	}
+	if pflags&fxfExcl != 0 {
+		flags |= os.O_EXCL
+	}
+	if flags != os.O_RDONLY && s.server.readOnly {
+		return nil, &statusError{fxPermissionDenied, "read-only server"}
+	}
+
+	file, err := os.OpenFile(s.local(name), flags, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	logger.Debug("File opened", "user", s.conn.User(), "path", name, "flags", fmt.Sprintf("%#x", pflags))
+	return s.newHandle(id, &handle{name: name, file: file}), nil
+}
+
+func (s *session) read(id uint32, h *handle, offset int64, length int) ([]byte, error) {
+	if h.file == nil {
+		return nil, &statusError{fxFailure, "not a file handle"}
+	}
+	fault := s.server.fault("read", h.name)
+	if fault != nil {
+		if err := fault.err(); err != nil {
+			return nil, err
+		}
+		if fault.readDelay > 0 {
+			time.Sleep(fault.readDelay)
+		}
+	}
+
+	length = min(length, maxRead)
+	if fault != nil && fault.TruncateAt != nil {
+		length = int(min(int64(length), max(*fault.TruncateAt-offset, 0)))
+	}
+	if length == 0 {
+		return nil, &statusError{fxEOF, "end of file"}
+	}
+	data := make([]byte, length)
+	n, err := h.file.ReadAt(data, offset)
+	if n == 0 && err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, &statusError{fxEOF, "end of file"}
+		}
+		return nil, err
+	}
+	return newPacket(fxpData).uint32(id).string(string(data[:n])).bytes(), nil
+}
+
+func (s *session) setstat(name string, r *reader) error {
+	flags := r.uint32()
+	var size uint64
+	var mode, atime, mtime uint32
+	if flags&attrSize != 0 {
+		size = r.uint64()
+	}
+	if flags&attrUIDGID != 0 {
+		r.uint32()
+		r.uint32()
+	}
+	if flags&attrPermissions != 0 {
+		mode = r.uint32()
+	}
+	if flags&attrACModTime != 0 {
+		atime, mtime = r.uint32(), r.uint32()
+	}
+	if r.err != nil {
+		return errBadMessage
+	}
+
+	return s.change("setstat", name, func(local string) error {
+		if flags&attrSize != 0 {
+			if err := os.Truncate(local, int64(size)); err != nil {
+				return err
+			}
+		}
+		if flags&attrPermissions != 0 {
+			if err := os.Chmod(local, fs.FileMode(mode&0o777)); err != nil {
+				return err
+			}
+		}
+		if flags&attrACModTime != 0 {
+			return os.Chtimes(local, time.Unix(int64(atime), 0), time.Unix(int64(mtime), 0))
+		}
+		return nil
+	})
+}
+
+// change runs a modifying operation, honouring read-only mode and faults.
+// Success is reported as an OK status.
+func (s *session) change(operation, name string, apply func(local string) error) error {
+	if err := s.check(operation, name); err != nil {
+		return err
+	}
+	if s.server.readOnly {
+		return &statusError{fxPermissionDenied, "read-only server"}
+	}
+	if err := apply(s.local(name)); err != nil {
+		return err
+	}
+	logger.Debug("File operation", "user", s.conn.User(), "operation", operation, "path", name)
+	return statusOK
+}
+
+// check returns the error of a matching fault, if any.
+func (s *session) check(operation, name string) error {
+	if fault := s.server.fault(operation, name); fault != nil {
+		return fault.err()
+	}
+	return nil
+}
+
+func (f *compiledFault) err() error {
+	switch f.Error {
+	case ErrorPermissionDenied:
+		return &statusError{fxPermissionDenied, "permission denied"}
+	case ErrorNoSuchFile:
+		return &statusError{fxNoSuchFile, "no such file"}
+	case ErrorFailure:
+		return &statusError{fxFailure, "failure"}
+	case ErrorDisconnect:
+		return errDisconnect
+	}
+	return nil
+}
+
+func (s *session) newHandle(id uint32, h *handle) []byte {
+	s.nextID++
+	key := strconv.Itoa(s.nextID)
+	s.handles[key] = h
+	return newPacket(fxpHandle).uint32(id).string(key).bytes()
+}
+
+func (s *session) lookup(key string) (*handle, error) {
+	h, ok := s.handles[key]
+	if !ok {
+		return nil, &statusError{fxFailure, "invalid handle"}
+	}
+	return h, nil
+}
+
+// local maps a cleaned SFTP path onto the served directory.
+func (s *session) local(name string) string {
+	return filepath.Join(s.server.root, filepath.FromSlash(name))
+}
+
+// cleanPath resolves a client path against "/", the home directory; ".."
+// never leaves the root.
+func cleanPath(name string) string {
+	return path.Clean("/" + name)
+}
+
+func readDir(dir string) ([]fs.FileInfo, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]fs.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		if info, err := entry.Info(); err == nil {
+			infos = append(infos, info)
+		}
+	}
+	return infos, nil
+}
+
+// longName formats an entry the way "ls -l" does, as clients display it.
+func longName(info fs.FileInfo) string {
+	return fmt.Sprintf("%s    1 mock     mock     %8d %s %s",
+		info.Mode().String(), info.Size(), info.ModTime().Format("Jan _2 15:04"), info.Name())
+}
+
+func statusPacket(id uint32, err error) []byte {
+	var status *statusError
+	switch {
+	case errors.As(err, &status):
+	case errors.Is(err, fs.ErrNotExist):
+		status = &statusError{fxNoSuchFile, "no such file"}
+	case errors.Is(err, fs.ErrPermission):
+		status = &statusError{fxPermissionDenied, "permission denied"}
+	case errors.Is(err, errBadMessage):
+		status = &statusError{fxBadMessage, "malformed request"}
+	default:
+		status = &statusError{fxFailure, err.Error()}
+	}
+	return newPacket(fxpStatus).uint32(id).uint32(status.code).string(status.message).string("en").bytes()
+}
+
+// packet builds an outgoing packet.
+type packet struct {
+	data []byte
+}
+
+func newPacket(kind byte) *packet {
+	return &packet{data: []byte{kind}}
+}
+
+func (p *packet) uint32(v uint32) *packet {
+	p.data = binary.BigEndian.AppendUint32(p.data, v)
+	return p
+}
+
+func (p *packet) uint64(v uint64) *packet {
+	p.data = binary.BigEndian.AppendUint64(p.data, v)
+	return p
+}
+
+func (p *packet) string(s string) *packet {
+	p.uint32(uint32(len(s)))
+	p.data = append(p.data, s...)
+	return p
+}
+
+// attrs encodes size, permissions (with the file type bits) and times.
+func (p *packet) attrs(info fs.FileInfo) *packet {
+	mode := uint32(info.Mode().Perm())
+	switch {
+	case info.IsDir():
+		mode |= 0o040000
+	case info.Mode()&fs.ModeSymlink != 0:
+		mode |= 0o120000
+	default:
+		mode |= 0o100000
+	}
+	mtime := uint32(info.ModTime().Unix())
+	return p.uint32(attrSize | attrPermissions | attrACModTime).
+		uint64(uint64(info.Size())).uint32(mode).uint32(mtime).uint32(mtime)
+}
+
+func (p *packet) bytes() []byte {
+	return p.data
+}
+
+// reader decodes an incoming packet; the first error sticks.
+type reader struct {
+	data []byte
+	err  error
+}
+
+func (r *reader) uint32() uint32 {
+	if len(r.data) < 4 {
+		r.err = errBadMessage
+		return 0
+	}
+	v := binary.BigEndian.Uint32(r.data)
+	r.data = r.data[4:]
+	return v
+}
+
+func (r *reader) uint64() uint64 {
+	if len(r.data) < 8 {
+		r.err = errBadMessage
+		return 0
+	}
+	v := binary.BigEndian.Uint64(r.data)
+	r.data = r.data[8:]
+	return v
+}
+
+func (r *reader) string() string {
+	n := r.uint32()
+	if r.err != nil || uint32(len(r.data)) < n {
+		r.err = errBadMessage
+		return ""
+	}
+	s := string(r.data[:n])
+	r.data = r.data[n:]
+	return s
+}
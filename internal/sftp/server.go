@@ -0,0 +1,348 @@
+// Package sftp serves a directory tree over SFTP (version 3) so that
+// file-exchange integrations can be tested without a separate container.
+// Faults injected per path make operations fail, cut transfers short or
+// slow reads down.
+package sftp
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	"mockserver/internal/logging"
+	"mockserver/internal/panics"
+)
+
+var logger = logging.For("sftp")
+
+// Config holds the SFTP settings from the configuration file.
+type Config struct {
+	// Root is the directory served as "/".
+	Root string `json:"root"`
+	// Users maps user names to passwords. Without users and authorized
+	// keys any login is accepted.
+	Users map[string]string `json:"users,omitempty"`
+	// AuthorizedKeys is an authorized_keys file accepted for every user.
+	AuthorizedKeys string `json:"authorized_keys,omitempty"`
+	// HostKeyFile is a PEM private key; an Ed25519 key is generated at
+	// startup when empty.
+	HostKeyFile string `json:"host_key_file,omitempty"`
+	// ReadOnly rejects every change with "permission denied".
+	ReadOnly bool    `json:"read_only,omitempty"`
+	Faults   []Fault `json:"faults,omitempty"`
+}
+
+// Fault errors for Fault.Error.
+const (
+	ErrorPermissionDenied = "permission_denied"
+	ErrorNoSuchFile       = "no_such_file"
+	ErrorFailure          = "failure"
+	// ErrorDisconnect drops the connection instead of answering.
+	ErrorDisconnect = "disconnect"
+)
+
+// Fault alters the operations on the paths matching Path, a glob such as
+// "/outbox/*.csv". Operation restricts it to one of open, read, write,
+// list, stat, remove, rename, mkdir, rmdir and setstat (all when empty).
+// Error fails the operation; TruncateAt ends reads at that offset, as if
+// the file were shorter; ReadDelay slows down every read. Times limits how
+// often the fault fires (always when 0).
+type Fault struct {
+	ID         string `json:"id,omitempty"`
+	Path       string `json:"path"`
+	Operation  string `json:"operation,omitempty"`
+	Error      string `json:"error,omitempty"`
+	TruncateAt *int64 `json:"truncate_at,omitempty"`
+	ReadDelay  string `json:"read_delay,omitempty"`
+	Times      int    `json:"times,omitempty"`
+	// Hits counts how often the fault fired.
+	Hits int `json:"hits"`
+}
+
+var operations = map[string]bool{
+	"open": true, "read": true, "write": true, "list": true, "stat": true,
+	"remove": true, "rename": true, "mkdir": true, "rmdir": true, "setstat": true,
+}
+
+type compiledFault struct {
+	Fault
+	readDelay time.Duration
+}
+
+// Server is the SFTP listener.
+type Server struct {
+	root      string
+	readOnly  bool
+	sshConfig *ssh.ServerConfig
+	hostKey   ssh.PublicKey
+	faults    []*compiledFault
+	nextID    int
+	mutex     sync.Mutex
+	panics    *panics.Recorder
+}
+
+// NewServer checks the root directory, loads the keys and compiles the
+// configured faults.
+func NewServer(cfg Config) (*Server, error) {
+	if cfg.Root == "" {
+		return nil, fmt.Errorf("root is required")
+	}
+	root, err := filepath.Abs(cfg.Root)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(root); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("root %s is not a directory", cfg.Root)
+	}
+
+	sshConfig, hostKey, err := newSSHConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{root: root, readOnly: cfg.ReadOnly, sshConfig: sshConfig, hostKey: hostKey}
+	for _, fault := range cfg.Faults {
+		if _, err := s.AddFault(fault); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func newSSHConfig(cfg Config) (*ssh.ServerConfig, ssh.PublicKey, error) {
+	sshConfig := &ssh.ServerConfig{ServerVersion: "SSH-2.0-MockServer"}
+
+	var authorized []ssh.PublicKey
+	if cfg.AuthorizedKeys != "" {
+		data, err := os.ReadFile(cfg.AuthorizedKeys)
+		if err != nil {
+			return nil, nil, fmt.Errorf("read authorized_keys: %w", err)
+		}
+		for len(bytes.TrimSpace(data)) > 0 {
+			key, _, _, rest, err := ssh.ParseAuthorizedKey(data)
+			if err != nil {
+				return nil, nil, fmt.Errorf("parse authorized_keys: %w", err)
+			}
+			authorized = append(authorized, key)
+			data = rest
+		}
+	}
+	open := len(cfg.Users) == 0 && len(authorized) == 0
+
+	sshConfig.PasswordCallback = func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+		if open {
+			return nil, nil
+		}
+		if expected, ok := cfg.Users[conn.User()]; ok && expected == string(password) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("invalid password for %q", conn.User())
+	}
+	sshConfig.PublicKeyCallback = func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+		if open {
+			return nil, nil
+		}
+		for _, candidate := range authorized {
+			if bytes.Equal(candidate.Marshal(), key.Marshal()) {
+				return nil, nil
+			}
+		}
+		return nil, fmt.Errorf("unknown public key for %q", conn.User())
+	}
+
+	var signer ssh.Signer
+	if cfg.HostKeyFile != "" {
+		data, err := os.ReadFile(cfg.HostKeyFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("read host key: %w", err)
+		}
+		if signer, err = ssh.ParsePrivateKey(data); err != nil {
+			return nil, nil, fmt.Errorf("parse host key: %w", err)
+		}
+	} else {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, nil, err
+		}
+		if signer, err = ssh.NewSignerFromKey(key); err != nil {
+			return nil, nil, err
+		}
+	}
+	sshConfig.AddHostKey(signer)
+	return sshConfig, signer.PublicKey(), nil
+}
+
+// Fingerprint returns the SHA256 fingerprint of the host key, for clients
+// that pin it.
+func (s *Server) Fingerprint() string {
+	return ssh.FingerprintSHA256(s.hostKey)
+}
+
+// AddFault validates and registers a fault, assigning its ID.
+func (s *Server) AddFault(fault Fault) (Fault, error) {
+	if fault.Path == "" {
+		return Fault{}, fmt.Errorf("fault path is required")
+	}
+	if _, err := path.Match(fault.Path, "/"); err != nil {
+		return Fault{}, fmt.Errorf("invalid fault path %q: %w", fault.Path, err)
+	}
+	if fault.Operation != "" && !operations[fault.Operation] {
+		return Fault{}, fmt.Errorf("unknown fault operation %q", fault.Operation)
+	}
+	switch fault.Error {
+	case "", ErrorPermissionDenied, ErrorNoSuchFile, ErrorFailure, ErrorDisconnect:
+	default:
+		return Fault{}, fmt.Errorf("unknown fault error %q (want %s, %s, %s or %s)",
+			fault.Error, ErrorPermissionDenied, ErrorNoSuchFile, ErrorFailure, ErrorDisconnect)
+	}
+	if fault.TruncateAt != nil && *fault.TruncateAt < 0 {
+		return Fault{}, fmt.Errorf("fault truncate_at must not be negative")
+	}
+	compiled := &compiledFault{Fault: fault}
+	if fault.ReadDelay != "" {
+		delay, err := time.ParseDuration(fault.ReadDelay)
+		if err != nil || delay < 0 {
+			return Fault{}, fmt.Errorf("invalid fault read_delay %q", fault.ReadDelay)
+		}
+		compiled.readDelay = delay
+	}
+	if fault.Error == "" && fault.TruncateAt == nil && compiled.readDelay == 0 {
+		return Fault{}, fmt.Errorf("fault needs an error, truncate_at or read_delay")
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.nextID++
+	compiled.ID = fmt.Sprintf("fault-%d", s.nextID)
+	compiled.Hits = 0
+	s.faults = append(s.faults, compiled)
+	logger.Info("Fault added", "fault_id", compiled.ID, "path", fault.Path, "operation", fault.Operation, "error", fault.Error)
+	return compiled.Fault, nil
+}
+
+// Faults lists the faults with their hit counters.
+func (s *Server) Faults() []Fault {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	faults := make([]Fault, len(s.faults))
+	for i, fault := range s.faults {
+		faults[i] = fault.Fault
+	}
+	return faults
+}
+
+// ResetFaults removes every fault.
+func (s *Server) ResetFaults() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.faults = nil
+	logger.Info("All faults removed")
+}
+
+// fault returns the first active fault for an operation on a path and
+// counts the hit.
+func (s *Server) fault(operation, name string) *compiledFault {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, fault := range s.faults {
+		if fault.Operation != "" && fault.Operation != operation {
+			continue
+		}
+		// Truncation and delays only concern reads
+		if fault.Error == "" && operation != "read" {
+			continue
+		}
+		if fault.Times > 0 && fault.Hits >= fault.Times {
+			continue
+		}
+		if ok, _ := path.Match(fault.Path, name); !ok {
+			continue
+		}
+		fault.Hits++
+		copied := *fault
+		return &copied
+	}
+	return nil
+}
+
+// SetPanics keeps the panics contained on the connections in recorder; it
+// is called before Serve.
+func (s *Server) SetPanics(recorder *panics.Recorder) {
+	s.panics = recorder
+}
+
+// Serve accepts SSH connections until the listener is closed.
+func (s *Server) Serve(lis net.Listener) error {
+	for {
+		conn, err := lis.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go s.handleConn(conn)
+	}
+}
+
+func (s *Server) handleConn(netConn net.Conn) {
+	defer s.panics.Contain(context.Background(), panics.ProtocolSFTP, netConn.RemoteAddr().String())
+	defer netConn.Close()
+	conn, channels, requests, err := ssh.NewServerConn(netConn, s.sshConfig)
+	if err != nil {
+		logger.Debug("Handshake failed", "peer", netConn.RemoteAddr().String(), "error", err)
+		return
+	}
+	defer conn.Close()
+	logger.Debug("User logged in", "user", conn.User(), "peer", conn.RemoteAddr().String())
+	go ssh.DiscardRequests(requests)
+
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "only session channels are supported")
+			continue
+		}
+		channel, channelRequests, err := newChannel.Accept()
+		if err != nil {
+			logger.Warn("Cannot accept channel", "user", conn.User(), "error", err)
+			continue
+		}
+		go s.handleSession(conn, channel, channelRequests)
+	}
+	logger.Debug("User disconnected", "user", conn.User())
+}
+
+// handleSession waits for the "sftp" subsystem request and serves it.
+func (s *Server) handleSession(conn *ssh.ServerConn, channel ssh.Channel, requests <-chan *ssh.Request) {
+	defer s.panics.Contain(context.Background(), panics.ProtocolSFTP, conn.User()+"@"+conn.RemoteAddr().String())
+	defer channel.Close()
+	for req := range requests {
+		if req.Type != "subsystem" || string(req.Payload[min(4, len(req.Payload)):]) != "sftp" {
+			req.Reply(false, nil)
+			continue
+		}
+		req.Reply(true, nil)
+		go ssh.DiscardRequests(requests)
+
+		session := &session{server: s, conn: conn, channel: channel, handles: make(map[string]*handle)}
+		err := session.serve()
+		if err != nil {
+			logger.Debug("Session ended", "user", conn.User(), "error", err)
+		}
+		if errors.Is(err, errDisconnect) {
+			conn.Close()
+		}
+		session.closeHandles()
+		return
+	}
+}
@@ -0,0 +1,72 @@
+// Package signedurl issues HMAC-signed URLs, in the way of S3 pre-signed
+// URLs, and enforces them: a signed request is served until it expires,
+// while a tampered, expired or missing signature is rejected with 403. It
+// lets clients of pre-signed URL workflows be tested against the expiry and
+// tampering cases.
+package signedurl
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Query parameters carrying the expiry (a Unix time) and the signature of a
+// signed URL.
+const (
+	ExpiresParam   = "X-Mock-Expires"
+	SignatureParam = "X-Mock-Signature"
+)
+
+const (
+	defaultExpires = 15 * time.Minute
+	defaultMax     = 7 * 24 * time.Hour
+)
+
+// Config holds the signed URL settings; SIGNED_URLS_ENABLED=true also
+// enables them.
+type Config struct {
+	Enabled bool `json:"enabled"`
+	// Secret is the HMAC key; a random one is generated at startup when
+	// empty.
+	Secret string `json:"secret,omitempty"`
+	// Paths are the path prefixes that require a signature. A request
+	// carrying a signature is verified on any path.
+	Paths []string `json:"paths,omitempty"`
+	// Expires is the lifetime of the URLs issued without one (default 15m)
+	// and MaxExpires the longest lifetime accepted (default 7 days).
+	Expires    string `json:"expires,omitempty"`
+	MaxExpires string `json:"max_expires,omitempty"`
+}
+
+// Validate checks the paths and lifetimes.
+func (c Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	for _, path := range c.Paths {
+		if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "/__admin") {
+			return fmt.Errorf("signed URLs: invalid path %q", path)
+		}
+	}
+	_, _, err := c.lifetimes()
+	return err
+}
+
+func (c Config) lifetimes() (expires, max time.Duration, err error) {
+	expires, max = defaultExpires, defaultMax
+	if c.MaxExpires != "" {
+		if max, err = time.ParseDuration(c.MaxExpires); err != nil || max <= 0 {
+			return 0, 0, fmt.Errorf("signed URLs: invalid max_expires %q", c.MaxExpires)
+		}
+	}
+	if c.Expires != "" {
+		if expires, err = time.ParseDuration(c.Expires); err != nil || expires <= 0 {
+			return 0, 0, fmt.Errorf("signed URLs: invalid expires %q", c.Expires)
+		}
+	}
+	if expires > max {
+		return 0, 0, fmt.Errorf("signed URLs: expires %v is longer than max_expires %v", expires, max)
+	}
+	return expires, max, nil
+}
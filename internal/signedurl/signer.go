@@ -0,0 +1,174 @@
+package signedurl
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"mockserver/internal/logging"
+)
+
+var logger = logging.For("signedurl")
+
+// SignedURL is an issued URL: the signed path with its query, the method it
+// is signed for and its expiry.
+type SignedURL struct {
+	Path      string
+	Method    string
+	ExpiresAt time.Time
+}
+
+// Signer issues and verifies signed URLs.
+type Signer struct {
+	secret     []byte
+	paths      []string
+	expires    time.Duration
+	maxExpires time.Duration
+}
+
+func New(config Config) (*Signer, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	expires, max, _ := config.lifetimes()
+	s := &Signer{secret: []byte(config.Secret), paths: config.Paths, expires: expires, maxExpires: max}
+	if len(s.secret) == 0 {
+		s.secret = make([]byte, 32)
+		rand.Read(s.secret)
+	}
+	return s, nil
+}
+
+// Paths returns the path prefixes that require a signature.
+func (s *Signer) Paths() []string {
+	return s.paths
+}
+
+// ParseExpires parses the lifetime of a URL to sign, in seconds ("300") or
+// as a duration ("5m"). Empty means the default lifetime. A lifetime up to
+// 0 issues a URL that has already expired.
+func (s *Signer) ParseExpires(value string) (time.Duration, error) {
+	if value == "" {
+		return s.expires, nil
+	}
+	ttl, err := time.ParseDuration(value)
+	if seconds, convErr := strconv.ParseInt(value, 10, 64); convErr == nil {
+		ttl, err = time.Duration(seconds)*time.Second, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("invalid expires %q", value)
+	}
+	if ttl > s.maxExpires {
+		return 0, fmt.Errorf("expires %v is longer than %v", ttl, s.maxExpires)
+	}
+	return ttl, nil
+}
+
+// Sign signs target, a path with an optional query, for requests of method
+// that are valid for ttl.
+func (s *Signer) Sign(method, target string, ttl time.Duration) (SignedURL, error) {
+	u, err := url.Parse(target)
+	if err != nil || !strings.HasPrefix(u.Path, "/") || u.Host != "" {
+		return SignedURL{}, fmt.Errorf("invalid path %q", target)
+	}
+	method = strings.ToUpper(method)
+	if method == "" {
+		method = http.MethodGet
+	}
+	expiresAt := time.Now().Add(ttl).Truncate(time.Second)
+	query := u.Query()
+	query.Del(SignatureParam)
+	query.Set(ExpiresParam, strconv.FormatInt(expiresAt.Unix(), 10))
+	query.Set(SignatureParam, s.signature(method, u.EscapedPath(), query))
+	return SignedURL{
+		Path:      u.EscapedPath() + "?" + query.Encode(),
+		Method:    method,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// signature is the hex HMAC-SHA256 of the method, the escaped path and the
+// sorted query without the signature.
+func (s *Signer) signature(method, path string, query url.Values) string {
+	signed := make(url.Values, len(query))
+	for name, values := range query {
+		if name != SignatureParam {
+			signed[name] = values
+		}
+	}
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(method + "\n" + path + "\n" + signed.Encode()))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// verify returns why a request is refused, or "" when it may be served.
+func (s *Signer) verify(req *http.Request) (reason, details string) {
+	query := req.URL.Query()
+	signature := query.Get(SignatureParam)
+	if signature == "" {
+		if !s.required(req.URL.Path) {
+			return "", ""
+		}
+		return "Missing URL signature", "sign the URL with GET /__admin/sign?path=" + url.QueryEscape(req.URL.RequestURI())
+	}
+	// HEAD requests are served with the signature of GET, as by S3
+	method := req.Method
+	if method == http.MethodHead {
+		method = http.MethodGet
+	}
+	expected := s.signature(method, req.URL.EscapedPath(), query)
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return "Invalid URL signature", "the signature does not match the method, path and query"
+	}
+	expires, err := strconv.ParseInt(query.Get(ExpiresParam), 10, 64)
+	if err != nil {
+		return "Invalid URL signature", ExpiresParam + " is missing or invalid"
+	}
+	if expiresAt := time.Unix(expires, 0); !time.Now().Before(expiresAt) {
+		return "Signed URL expired", "expired at " + expiresAt.UTC().Format(time.RFC3339)
+	}
+	return "", ""
+}
+
+// required reports whether path needs a signature.
+func (s *Signer) required(path string) bool {
+	for _, prefix := range s.paths {
+		if path == prefix || strings.HasPrefix(path, strings.TrimSuffix(prefix, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Middleware rejects with 403 the requests with a tampered or expired
+// signature, and those without one on the protected paths. Admin and
+// preflight requests are let through.
+func (s *Signer) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			if req.Method == http.MethodOptions || strings.HasPrefix(req.URL.Path, "/__admin") {
+				return next(c)
+			}
+			reason, details := s.verify(req)
+			if reason == "" {
+				return next(c)
+			}
+			logger.DebugContext(req.Context(), "Request rejected", "reason", reason)
+			return c.JSON(http.StatusForbidden, map[string]interface{}{
+				"error":     reason,
+				"details":   details,
+				"timestamp": time.Now().Unix(),
+			})
+		}
+	}
+}
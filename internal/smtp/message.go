@@ -0,0 +1,281 @@
+package smtp
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// Message is a captured message. From and To are the envelope addresses;
+// the addresses of the headers are in Headers. Header values are decoded
+// (RFC 2047), and the bodies are decoded to UTF-8 text.
+type Message struct {
+	ID          string              `json:"id"`
+	ReceivedAt  time.Time           `json:"received_at"`
+	From        string              `json:"from"`
+	To          []string            `json:"to"`
+	Helo        string              `json:"helo,omitempty"`
+	Peer        string              `json:"peer"`
+	User        string              `json:"user,omitempty"`
+	Subject     string              `json:"subject"`
+	Headers     map[string][]string `json:"headers"`
+	Text        string              `json:"text,omitempty"`
+	HTML        string              `json:"html,omitempty"`
+	Attachments []Attachment        `json:"attachments"`
+	Size        int                 `json:"size"`
+	// ParseError is set when the message is not valid MIME; the raw
+	// message is still available.
+	ParseError string `json:"parse_error,omitempty"`
+	raw        []byte
+}
+
+// Attachment is a part of a message that is not its text or HTML body.
+// Inline parts (images of the HTML body) have a ContentID.
+type Attachment struct {
+	Index       int    `json:"index"`
+	Filename    string `json:"filename,omitempty"`
+	ContentType string `json:"content_type"`
+	ContentID   string `json:"content_id,omitempty"`
+	Inline      bool   `json:"inline,omitempty"`
+	Size        int    `json:"size"`
+	data        []byte
+}
+
+// envelope is what the SMTP session knows about a message.
+type envelope struct {
+	from, helo, peer, user string
+	to                     []string
+}
+
+var headerDecoder = &mime.WordDecoder{CharsetReader: charsetReader}
+
+func parseMessage(raw []byte) *Message {
+	message := &Message{Headers: map[string][]string{}, Attachments: []Attachment{}, Size: len(raw), raw: raw}
+	parsed, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		message.ParseError = err.Error()
+		return message
+	}
+	for name, values := range parsed.Header {
+		decoded := make([]string, len(values))
+		for i, value := range values {
+			if decoded[i], err = headerDecoder.DecodeHeader(value); err != nil {
+				decoded[i] = value
+			}
+		}
+		message.Headers[name] = decoded
+	}
+	if subject := message.Headers["Subject"]; len(subject) > 0 {
+		message.Subject = subject[0]
+	}
+	if err := message.walk(textproto.MIMEHeader(parsed.Header), parsed.Body); err != nil {
+		message.ParseError = err.Error()
+	}
+	return message
+}
+
+// walk sorts the parts of a (possibly multipart) body into the text and
+// HTML bodies and the attachments.
+func (m *Message) walk(header textproto.MIMEHeader, body io.Reader) error {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType, params = "text/plain", map[string]string{}
+	}
+	if strings.HasPrefix(mediaType, "multipart/") {
+		reader := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := reader.NextRawPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err := m.walk(part.Header, part); err != nil {
+				return err
+			}
+		}
+	}
+
+	data, err := io.ReadAll(decodeTransfer(header.Get("Content-Transfer-Encoding"), body))
+	if err != nil {
+		return err
+	}
+	disposition, dispositionParams, _ := mime.ParseMediaType(header.Get("Content-Disposition"))
+	filename := dispositionParams["filename"]
+	if filename == "" {
+		filename = params["name"]
+	}
+	if decoded, err := headerDecoder.DecodeHeader(filename); err == nil {
+		filename = decoded
+	}
+
+	if disposition != "attachment" && filename == "" {
+		switch {
+		case mediaType == "text/plain" && m.Text == "":
+			m.Text = toUTF8(data, params["charset"])
+			return nil
+		case mediaType == "text/html" && m.HTML == "":
+			m.HTML = toUTF8(data, params["charset"])
+			return nil
+		}
+	}
+	m.Attachments = append(m.Attachments, Attachment{
+		Index:       len(m.Attachments),
+		Filename:    filename,
+		ContentType: mediaType,
+		ContentID:   strings.Trim(header.Get("Content-ID"), "<>"),
+		Inline:      disposition == "inline",
+		Size:        len(data),
+		data:        data,
+	})
+	return nil
+}
+
+func decodeTransfer(encoding string, body io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		return quotedprintable.NewReader(body)
+	}
+	return body
+}
+
+// toUTF8 converts Latin-1 text; other charsets are assumed to be UTF-8 or
+// ASCII.
+func toUTF8(data []byte, charset string) string {
+	if !isLatin1(charset) {
+		return string(data)
+	}
+	runes := make([]rune, len(data))
+	for i, b := range data {
+		runes[i] = rune(b)
+	}
+	return string(runes)
+}
+
+func isLatin1(charset string) bool {
+	switch strings.ToLower(charset) {
+	case "iso-8859-1", "latin1", "latin-1":
+		return true
+	}
+	return false
+}
+
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	data, err := io.ReadAll(input)
+	if err != nil {
+		return nil, err
+	}
+	return strings.NewReader(toUTF8(data, charset)), nil
+}
+
+// Filter selects messages; zero values match everything. From, To, Subject
+// and Text are case-insensitive substrings: From and To of the envelope or
+// the headers (To, Cc), Text of the subject and bodies. Limit keeps the
+// latest messages.
+type Filter struct {
+	From    string
+	To      string
+	Subject string
+	Text    string
+	Since   time.Time
+	Limit   int
+}
+
+func (f Filter) matches(m *Message) bool {
+	contains := func(value, part string) bool {
+		return strings.Contains(strings.ToLower(value), strings.ToLower(part))
+	}
+	anyContains := func(values []string, part string) bool {
+		for _, value := range values {
+			if contains(value, part) {
+				return true
+			}
+		}
+		return false
+	}
+	switch {
+	case f.From != "" && !contains(m.From, f.From) && !anyContains(m.Headers["From"], f.From):
+		return false
+	case f.To != "" && !anyContains(m.To, f.To) && !anyContains(m.Headers["To"], f.To) && !anyContains(m.Headers["Cc"], f.To):
+		return false
+	case f.Subject != "" && !contains(m.Subject, f.Subject):
+		return false
+	case f.Text != "" && !contains(m.Subject, f.Text) && !contains(m.Text, f.Text) && !contains(m.HTML, f.Text):
+		return false
+	case !f.Since.IsZero() && m.ReceivedAt.Before(f.Since):
+		return false
+	}
+	return true
+}
+
+// Messages returns the messages matching the filter, oldest first.
+func (s *Server) Messages(filter Filter) []*Message {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	matched := []*Message{}
+	for _, message := range s.messages {
+		if filter.matches(message) {
+			matched = append(matched, message)
+		}
+	}
+	if filter.Limit > 0 && len(matched) > filter.Limit {
+		matched = matched[len(matched)-filter.Limit:]
+	}
+	return matched
+}
+
+// Message returns a message by ID.
+func (s *Server) Message(id string) (*Message, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	for _, message := range s.messages {
+		if message.ID == id {
+			return message, true
+		}
+	}
+	return nil, false
+}
+
+// Raw returns the message as received.
+func (m *Message) Raw() []byte {
+	return m.raw
+}
+
+// Attachment returns the content of an attachment by index.
+func (m *Message) Attachment(index int) (Attachment, []byte, bool) {
+	if index < 0 || index >= len(m.Attachments) {
+		return Attachment{}, nil, false
+	}
+	attachment := m.Attachments[index]
+	return attachment, attachment.data, true
+}
+
+// Delete removes a message.
+func (s *Server) Delete(id string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i, message := range s.messages {
+		if message.ID == id {
+			s.messages = append(s.messages[:i:i], s.messages[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Reset empties the mailbox.
+func (s *Server) Reset() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.messages = nil
+}
@@ -0,0 +1,351 @@
+// Package smtp is a capturing SMTP server: it accepts every message (or
+// rejects the recipients it is told to), keeps the messages in memory and
+// decodes them, so that tests can assert on the mail an application sends
+// through the admin API, the way MailHog is used.
+package smtp
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"mockserver/internal/logging"
+	"mockserver/internal/panics"
+)
+
+var logger = logging.For("smtp")
+
+// Defaults of the configuration.
+const (
+	DefaultHostname       = "mockserver"
+	DefaultMaxMessageSize = 10 << 20
+	DefaultMaxMessages    = 1000
+)
+
+// idleTimeout closes connections that stay silent.
+const idleTimeout = 5 * time.Minute
+
+// Config holds the SMTP settings from the configuration file.
+type Config struct {
+	// Hostname is announced in the greeting.
+	Hostname string `json:"hostname,omitempty"`
+	// MaxMessageSize rejects larger messages (bytes, default 10 MiB).
+	MaxMessageSize int `json:"max_message_size,omitempty"`
+	// MaxMessages is how many messages are kept; the oldest are dropped.
+	MaxMessages int `json:"max_messages,omitempty"`
+	// Users restricts AUTH PLAIN and LOGIN to these user names and
+	// passwords; without users any credentials are accepted. AUTH is
+	// never required.
+	Users map[string]string `json:"users,omitempty"`
+	// RejectRecipients are address globs ("*@bounce.test") answered with
+	// 550, to test bounces.
+	RejectRecipients []string `json:"reject_recipients,omitempty"`
+}
+
+// Server is the SMTP listener and its mailbox.
+type Server struct {
+	config Config
+	nextID int
+	// messages are in the order they were received.
+	messages []*Message
+	mutex    sync.RWMutex
+	panics   *panics.Recorder
+}
+
+// NewServer validates the configuration.
+func NewServer(cfg Config) (*Server, error) {
+	if cfg.Hostname == "" {
+		cfg.Hostname = DefaultHostname
+	}
+	if cfg.MaxMessageSize < 0 || cfg.MaxMessages < 0 {
+		return nil, errors.New("max_message_size and max_messages must not be negative")
+	}
+	if cfg.MaxMessageSize == 0 {
+		cfg.MaxMessageSize = DefaultMaxMessageSize
+	}
+	if cfg.MaxMessages == 0 {
+		cfg.MaxMessages = DefaultMaxMessages
+	}
+	for _, pattern := range cfg.RejectRecipients {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid reject_recipients pattern %q", pattern)
+		}
+	}
+	return &Server{config: cfg}, nil
+}
+
+// SetPanics keeps the panics contained on the connections in recorder; it
+// is called before Serve.
+func (s *Server) SetPanics(recorder *panics.Recorder) {
+	s.panics = recorder
+}
+
+// Serve accepts connections until the listener is closed.
+func (s *Server) Serve(lis net.Listener) error {
+	for {
+		conn, err := lis.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go s.handleConn(conn)
+	}
+}
+
+// session is the state of one connection.
+type session struct {
+	server *Server
+	conn   net.Conn
+	text   *textproto.Conn
+	helo   string
+	user   string
+	// mail is set by MAIL FROM, whose address may be empty (bounces).
+	mail bool
+	from string
+	to   []string
+}
+
+func (s *Server) handleConn(conn net.Conn) {
+	defer s.panics.Contain(context.Background(), panics.ProtocolSMTP, conn.RemoteAddr().String())
+	defer conn.Close()
+	sess := &session{server: s, conn: conn, text: textproto.NewConn(conn)}
+	sess.reply(220, "%s ESMTP mockserver ready", s.config.Hostname)
+	for {
+		conn.SetReadDeadline(time.Now().Add(idleTimeout))
+		line, err := sess.text.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		if !sess.command(strings.ToUpper(verb), strings.TrimSpace(arg)) {
+			return
+		}
+	}
+}
+
+func (sess *session) reply(code int, format string, args ...interface{}) {
+	sess.text.PrintfLine("%d %s", code, fmt.Sprintf(format, args...))
+}
+
+// command runs one command and reports whether the session goes on.
+func (sess *session) command(verb, arg string) bool {
+	cfg := sess.server.config
+	switch verb {
+	case "HELO":
+		sess.helo = arg
+		sess.reset()
+		sess.reply(250, "%s", cfg.Hostname)
+	case "EHLO":
+		sess.helo = arg
+		sess.reset()
+		sess.text.PrintfLine("250-%s greets %s", cfg.Hostname, arg)
+		sess.text.PrintfLine("250-SIZE %d", cfg.MaxMessageSize)
+		sess.text.PrintfLine("250-8BITMIME")
+		sess.text.PrintfLine("250-SMTPUTF8")
+		sess.text.PrintfLine("250-PIPELINING")
+		sess.text.PrintfLine("250 AUTH PLAIN LOGIN")
+	case "AUTH":
+		sess.auth(arg)
+	case "MAIL":
+		address, params, ok := parsePath(arg, "FROM:")
+		switch {
+		case !ok:
+			sess.reply(501, "Syntax: MAIL FROM:<address>")
+		case sess.mail:
+			sess.reply(503, "Sender already given")
+		case tooLarge(params, cfg.MaxMessageSize):
+			sess.reply(552, "Message size exceeds fixed limit")
+		default:
+			sess.mail, sess.from = true, address
+			sess.reply(250, "OK")
+		}
+	case "RCPT":
+		address, _, ok := parsePath(arg, "TO:")
+		switch {
+		case !ok || address == "":
+			sess.reply(501, "Syntax: RCPT TO:<address>")
+		case !sess.mail:
+			sess.reply(503, "Need MAIL first")
+		case sess.server.rejected(address):
+			sess.reply(550, "Mailbox unavailable: %s", address)
+		default:
+			sess.to = append(sess.to, address)
+			sess.reply(250, "OK")
+		}
+	case "DATA":
+		if len(sess.to) == 0 {
+			sess.reply(503, "Need RCPT first")
+			return true
+		}
+		sess.reply(354, "End data with <CR><LF>.<CR><LF>")
+		sess.data()
+	case "RSET":
+		sess.reset()
+		sess.reply(250, "OK")
+	case "NOOP":
+		sess.reply(250, "OK")
+	case "VRFY":
+		sess.reply(252, "Cannot VRFY user, but will accept message")
+	case "QUIT":
+		sess.reply(221, "Bye")
+		return false
+	default:
+		sess.reply(502, "Command not implemented")
+	}
+	return true
+}
+
+// data reads the message and stores it.
+func (sess *session) data() {
+	limit := int64(sess.server.config.MaxMessageSize)
+	raw, err := io.ReadAll(io.LimitReader(sess.text.DotReader(), limit+1))
+	if err != nil {
+		sess.reply(451, "Failed to read message: %v", err)
+		return
+	}
+	if int64(len(raw)) > limit {
+		// Drain the rest of the message before answering
+		io.Copy(io.Discard, sess.text.DotReader())
+		sess.reply(552, "Message size exceeds fixed limit")
+	} else {
+		message := sess.server.store(raw, envelope{
+			from: sess.from, to: sess.to, helo: sess.helo, peer: sess.peer(), user: sess.user,
+		})
+		sess.reply(250, "OK: queued as %s", message.ID)
+	}
+	sess.reset()
+}
+
+// reset forgets the transaction in progress.
+func (sess *session) reset() {
+	sess.mail, sess.from, sess.to = false, "", nil
+}
+
+func (sess *session) peer() string {
+	return sess.conn.RemoteAddr().String()
+}
+
+// auth accepts AUTH PLAIN and LOGIN, checking the credentials when users are
+// configured.
+func (sess *session) auth(arg string) {
+	mechanism, initial, _ := strings.Cut(arg, " ")
+	var user, password string
+	switch strings.ToUpper(mechanism) {
+	case "PLAIN":
+		if initial == "" {
+			sess.text.PrintfLine("334 ")
+			initial, _ = sess.text.ReadLine()
+		}
+		decoded, err := base64.StdEncoding.DecodeString(initial)
+		parts := strings.Split(string(decoded), "\x00")
+		if err != nil || len(parts) != 3 {
+			sess.reply(501, "Invalid PLAIN credentials")
+			return
+		}
+		user, password = parts[1], parts[2]
+	case "LOGIN":
+		var ok bool
+		if user, ok = sess.prompt("VXNlcm5hbWU6", initial); !ok {
+			return
+		}
+		if password, ok = sess.prompt("UGFzc3dvcmQ6", ""); !ok {
+			return
+		}
+	default:
+		sess.reply(504, "Unrecognized authentication type")
+		return
+	}
+
+	users := sess.server.config.Users
+	if len(users) > 0 && (users[user] == "" || users[user] != password) {
+		logger.Info("Authentication failed", "user", user, "peer", sess.peer())
+		sess.reply(535, "Authentication credentials invalid")
+		return
+	}
+	sess.user = user
+	sess.reply(235, "Authentication successful")
+}
+
+// prompt asks for a base64 value of AUTH LOGIN, unless it was given.
+func (sess *session) prompt(challenge, given string) (string, bool) {
+	if given == "" {
+		sess.text.PrintfLine("334 %s", challenge)
+		given, _ = sess.text.ReadLine()
+	}
+	decoded, err := base64.StdEncoding.DecodeString(given)
+	if err != nil {
+		sess.reply(501, "Invalid base64")
+		return "", false
+	}
+	return string(decoded), true
+}
+
+// parsePath reads "FROM:<address> PARAMS"; the null path <> is allowed.
+func parsePath(arg, prefix string) (string, string, bool) {
+	if len(arg) < len(prefix) || !strings.EqualFold(arg[:len(prefix)], prefix) {
+		return "", "", false
+	}
+	rest := strings.TrimSpace(arg[len(prefix):])
+	if !strings.HasPrefix(rest, "<") {
+		address, params, _ := strings.Cut(rest, " ")
+		return address, params, address != ""
+	}
+	end := strings.IndexByte(rest, '>')
+	if end < 0 {
+		return "", "", false
+	}
+	return rest[1:end], strings.TrimSpace(rest[end+1:]), true
+}
+
+// tooLarge checks the SIZE parameter of MAIL FROM.
+func tooLarge(params string, limit int) bool {
+	for _, param := range strings.Fields(params) {
+		name, value, _ := strings.Cut(param, "=")
+		if strings.EqualFold(name, "SIZE") {
+			size, err := strconv.Atoi(value)
+			return err == nil && size > limit
+		}
+	}
+	return false
+}
+
+func (s *Server) rejected(address string) bool {
+	address = strings.ToLower(address)
+	for _, pattern := range s.config.RejectRecipients {
+		if ok, _ := path.Match(strings.ToLower(pattern), address); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// store parses and keeps a message, dropping the oldest past the limit.
+func (s *Server) store(raw []byte, env envelope) *Message {
+	message := parseMessage(raw)
+	message.From, message.To = env.from, append([]string(nil), env.to...)
+	message.Helo, message.Peer, message.User = env.helo, env.peer, env.user
+	message.ReceivedAt = time.Now()
+
+	s.mutex.Lock()
+	s.nextID++
+	message.ID = fmt.Sprintf("msg-%d", s.nextID)
+	s.messages = append(s.messages, message)
+	if excess := len(s.messages) - s.config.MaxMessages; excess > 0 {
+		s.messages = append([]*Message(nil), s.messages[excess:]...)
+	}
+	s.mutex.Unlock()
+
+	logger.Debug("Message received", "message_id", message.ID, "from", env.from, "to", env.to, "bytes", len(raw), "subject", message.Subject)
+	return message
+}
@@ -0,0 +1,156 @@
+package stubs
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxCacheEntries bounds memory use; once reached, new responses are only
+// cached after expired entries have been purged.
+const maxCacheEntries = 10000
+
+type renderedResponse struct {
+	status  int
+	headers http.Header
+	body    []byte
+}
+
+type cacheEntry struct {
+	response renderedResponse
+	expires  time.Time
+}
+
+// CacheStats reports response cache effectiveness.
+type CacheStats struct {
+	Hits    int64                     `json:"hits"`
+	Misses  int64                     `json:"misses"`
+	Entries int                       `json:"entries"`
+	Stubs   map[string]StubCacheStats `json:"stubs"`
+}
+
+// StubCacheStats are the hit/miss counters of a single stub.
+type StubCacheStats struct {
+	Hits   int64 `json:"hits"`
+	Misses int64 `json:"misses"`
+}
+
+// responseCache memoizes rendered stub responses.
+type responseCache struct {
+	entries map[string]cacheEntry
+	stats   map[string]*StubCacheStats
+	mutex   sync.Mutex
+}
+
+func newResponseCache() *responseCache {
+	return &responseCache{
+		entries: make(map[string]cacheEntry),
+		stats:   make(map[string]*StubCacheStats),
+	}
+}
+
+// cacheKey identifies a response by stub, method, path and sorted query, plus
+// the stub's vary headers and the negotiated locale.
+func cacheKey(stub *compiledStub, r *http.Request, bodyLocale string) string {
+	var key strings.Builder
+	key.WriteString(stub.ID)
+	key.WriteByte(' ')
+	key.WriteString(r.Method)
+	key.WriteByte(' ')
+	key.WriteString(r.URL.Path)
+	key.WriteByte('?')
+	key.WriteString(r.URL.Query().Encode())
+	for _, name := range stub.Response.Cache.VaryHeaders {
+		key.WriteByte('\n')
+		key.WriteString(strings.ToLower(name))
+		key.WriteByte('=')
+		key.WriteString(r.Header.Get(name))
+	}
+	if stub.locales != nil {
+		key.WriteString("\nlocale=")
+		key.WriteString(bodyLocale)
+	}
+	return key.String()
+}
+
+func (c *responseCache) get(stubID, key string) (renderedResponse, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	stats := c.stubStats(stubID)
+	entry, ok := c.entries[key]
+	if !ok || time.Now().After(entry.expires) {
+		stats.Misses++
+		return renderedResponse{}, false
+	}
+	stats.Hits++
+	return entry.response, true
+}
+
+func (c *responseCache) put(key string, response renderedResponse, ttl time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if len(c.entries) >= maxCacheEntries {
+		c.purgeExpired()
+		if len(c.entries) >= maxCacheEntries {
+			return
+		}
+	}
+	c.entries[key] = cacheEntry{response: response, expires: time.Now().Add(ttl)}
+}
+
+func (c *responseCache) stubStats(stubID string) *StubCacheStats {
+	stats, ok := c.stats[stubID]
+	if !ok {
+		stats = &StubCacheStats{}
+		c.stats[stubID] = stats
+	}
+	return stats
+}
+
+func (c *responseCache) purgeExpired() {
+	now := time.Now()
+	for key, entry := range c.entries {
+		if now.After(entry.expires) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// invalidate drops the cached responses of one stub.
+func (c *responseCache) invalidate(stubID string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	prefix := stubID + " "
+	for key := range c.entries {
+		if strings.HasPrefix(key, prefix) {
+			delete(c.entries, key)
+		}
+	}
+	delete(c.stats, stubID)
+}
+
+// flush drops every cached response and resets the counters.
+func (c *responseCache) flush() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.entries = make(map[string]cacheEntry)
+	c.stats = make(map[string]*StubCacheStats)
+}
+
+func (c *responseCache) snapshot() CacheStats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.purgeExpired()
+	stats := CacheStats{Entries: len(c.entries), Stubs: make(map[string]StubCacheStats, len(c.stats))}
+	for id, s := range c.stats {
+		stats.Hits += s.Hits
+		stats.Misses += s.Misses
+		stats.Stubs[id] = *s
+	}
+	return stats
+}
@@ -0,0 +1,289 @@
+package stubs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"mockserver/internal/correlation"
+	"mockserver/internal/jose"
+	"mockserver/internal/journal"
+)
+
+const (
+	// maxDeliveries bounds the delivery log; the oldest are dropped beyond it.
+	maxDeliveries = 1000
+
+	callbackTimeout    = 10 * time.Second
+	defaultRetryDelay  = time.Second
+	maxCallbackRetries = 10
+)
+
+// Delivery states.
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// Callback is an outbound HTTP request a stub sends when it serves a
+// response, to simulate APIs answering through webhooks. URL, Headers and
+// the body are templates rendered with the triggering request, like
+// templated responses. The request is sent after Delay and, while it fails
+// (an error or a status outside 2xx), retried Retries times, waiting
+// RetryDelay (default 1s) and then twice as long each time.
+type Callback struct {
+	URL        string            `json:"url"`
+	Method     string            `json:"method,omitempty"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	Body       string            `json:"body,omitempty"`
+	JSONBody   json.RawMessage   `json:"json_body,omitempty"`
+	Delay      string            `json:"delay,omitempty"`
+	Retries    int               `json:"retries,omitempty"`
+	RetryDelay string            `json:"retry_delay,omitempty"`
+}
+
+// Delivery is a callback sent, or being sent, and its attempts.
+type Delivery struct {
+	ID            string            `json:"id"`
+	Stub          string            `json:"stub"`
+	Method        string            `json:"method"`
+	URL           string            `json:"url"`
+	State         string            `json:"state"`
+	CorrelationID string            `json:"correlation_id,omitempty"`
+	TriggeredAt   time.Time         `json:"triggered_at"`
+	Attempts      []DeliveryAttempt `json:"attempts"`
+}
+
+// DeliveryAttempt is one try of a delivery.
+type DeliveryAttempt struct {
+	Timestamp  time.Time `json:"timestamp"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	LatencyMs  float64   `json:"latency_ms"`
+}
+
+type compiledCallback struct {
+	method      string
+	url         *template.Template
+	headers     map[string]*template.Template
+	body        *template.Template
+	contentType string
+	delay       time.Duration
+	retries     int
+	retryDelay  time.Duration
+}
+
+func compileCallbacks(callbacks []Callback, keys *jose.KeySet, jitter *jitterSource) ([]compiledCallback, error) {
+	compiled := make([]compiledCallback, len(callbacks))
+	for i, cb := range callbacks {
+		c, err := compileCallback(cb, keys, jitter)
+		if err != nil {
+			return nil, fmt.Errorf("callbacks[%d]: %w", i, err)
+		}
+		compiled[i] = c
+	}
+	return compiled, nil
+}
+
+func compileCallback(cb Callback, keys *jose.KeySet, jitter *jitterSource) (compiledCallback, error) {
+	c := compiledCallback{method: strings.ToUpper(cb.Method), retries: cb.Retries, retryDelay: defaultRetryDelay}
+	if c.method == "" {
+		c.method = http.MethodPost
+	}
+	if cb.URL == "" {
+		return c, fmt.Errorf("url is required")
+	}
+	var err error
+	if c.url, err = newTemplate("url", keys, jitter).Parse(cb.URL); err != nil {
+		return c, fmt.Errorf("invalid url template: %w", err)
+	}
+	c.headers = make(map[string]*template.Template, len(cb.Headers))
+	for name, value := range cb.Headers {
+		if c.headers[name], err = newTemplate(name, keys, jitter).Parse(value); err != nil {
+			return c, fmt.Errorf("invalid template for header %s: %w", name, err)
+		}
+	}
+	body := cb.Body
+	if len(cb.JSONBody) > 0 {
+		body = string(cb.JSONBody)
+		c.contentType = "application/json"
+	}
+	if c.body, err = newTemplate("body", keys, jitter).Parse(body); err != nil {
+		return c, fmt.Errorf("invalid body template: %w", err)
+	}
+	if cb.Delay != "" {
+		if c.delay, err = time.ParseDuration(cb.Delay); err != nil || c.delay < 0 {
+			return c, fmt.Errorf("invalid delay %q", cb.Delay)
+		}
+	}
+	if cb.Retries < 0 || cb.Retries > maxCallbackRetries {
+		return c, fmt.Errorf("retries must be between 0 and %d", maxCallbackRetries)
+	}
+	if cb.RetryDelay != "" {
+		if c.retryDelay, err = time.ParseDuration(cb.RetryDelay); err != nil || c.retryDelay <= 0 {
+			return c, fmt.Errorf("invalid retry_delay %q", cb.RetryDelay)
+		}
+	}
+	return c, nil
+}
+
+// outboundCallback is a rendered callback, ready to be sent.
+type outboundCallback struct {
+	compiledCallback
+	delivery *Delivery
+	headers  http.Header
+	body     []byte
+}
+
+// render renders a callback for the request that triggered it.
+func (c compiledCallback) render(data templateData) (*outboundCallback, error) {
+	var target, body bytes.Buffer
+	if err := c.url.Execute(&target, data); err != nil {
+		return nil, fmt.Errorf("url: %w", err)
+	}
+	u, err := url.Parse(strings.TrimSpace(target.String()))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid url %q", target.String())
+	}
+	headers := http.Header{}
+	if c.contentType != "" {
+		headers.Set("Content-Type", c.contentType)
+	}
+	for name, tmpl := range c.headers {
+		var value bytes.Buffer
+		if err := tmpl.Execute(&value, data); err != nil {
+			return nil, fmt.Errorf("header %s: %w", name, err)
+		}
+		headers.Set(name, value.String())
+	}
+	if err := c.body.Execute(&body, data); err != nil {
+		return nil, fmt.Errorf("body: %w", err)
+	}
+	return &outboundCallback{
+		compiledCallback: c,
+		delivery:         &Delivery{Method: c.method, URL: u.String(), State: DeliveryPending, Attempts: []DeliveryAttempt{}},
+		headers:          headers,
+		body:             body.Bytes(),
+	}, nil
+}
+
+// callbackSender sends the callbacks and keeps the log of their deliveries.
+type callbackSender struct {
+	client     *http.Client
+	journal    *journal.Journal
+	deliveries []*Delivery
+	nextID     int
+	mutex      sync.Mutex
+}
+
+func newCallbackSender() *callbackSender {
+	return &callbackSender{client: &http.Client{Timeout: callbackTimeout}}
+}
+
+// send logs a delivery and sends it in the background. The correlation ID of
+// the triggering request goes along in X-Mock-Test-ID, so that the callback
+// joins the same correlated action.
+func (s *callbackSender) send(stubID, correlationID string, cb *outboundCallback) {
+	d := cb.delivery
+	d.Stub = stubID
+	d.CorrelationID = correlationID
+	d.TriggeredAt = time.Now()
+	if correlationID != "" && cb.headers.Get(correlation.Header) == "" {
+		cb.headers.Set(correlation.Header, correlationID)
+	}
+
+	s.mutex.Lock()
+	s.nextID++
+	d.ID = fmt.Sprintf("callback-%d", s.nextID)
+	s.deliveries = append(s.deliveries, d)
+	if len(s.deliveries) > maxDeliveries {
+		s.deliveries = s.deliveries[1:]
+	}
+	s.mutex.Unlock()
+
+	go s.deliver(cb)
+}
+
+func (s *callbackSender) deliver(cb *outboundCallback) {
+	d := cb.delivery
+	time.Sleep(cb.delay)
+	wait := cb.retryDelay
+	for attempt := 0; ; attempt++ {
+		status, err := s.attempt(cb)
+		if err == nil && status >= 200 && status < 300 {
+			s.setState(d, DeliveryDelivered)
+			logger.Debug("Callback delivered", "callback_id", d.ID, "stub_id", d.Stub, "url", d.URL, "status", status)
+			return
+		}
+		if attempt == cb.retries {
+			s.setState(d, DeliveryFailed)
+			logger.Warn("Callback failed", "callback_id", d.ID, "stub_id", d.Stub, "url", d.URL, "attempts", attempt+1)
+			return
+		}
+		time.Sleep(wait)
+		wait *= 2
+	}
+}
+
+// attempt sends a callback once, recording the attempt in the delivery and
+// in the journal.
+func (s *callbackSender) attempt(cb *outboundCallback) (int, error) {
+	d := cb.delivery
+	start := time.Now()
+	req, err := http.NewRequest(d.Method, d.URL, bytes.NewReader(cb.body))
+	var status int
+	if err == nil {
+		req.Header = cb.headers.Clone()
+		var resp *http.Response
+		if resp, err = s.client.Do(req); err == nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			status = resp.StatusCode
+		}
+	}
+
+	result := DeliveryAttempt{Timestamp: start, StatusCode: status, LatencyMs: float64(time.Since(start).Microseconds()) / 1000}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	s.mutex.Lock()
+	d.Attempts = append(d.Attempts, result)
+	j := s.journal
+	s.mutex.Unlock()
+	if j != nil && req != nil {
+		j.RecordOutbound(req, cb.body, status, err, start, d.Stub)
+	}
+	return status, err
+}
+
+func (s *callbackSender) setState(d *Delivery, state string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	d.State = state
+}
+
+func (s *callbackSender) snapshot() []Delivery {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	deliveries := make([]Delivery, len(s.deliveries))
+	for i, d := range s.deliveries {
+		deliveries[i] = *d
+		deliveries[i].Attempts = append([]DeliveryAttempt{}, d.Attempts...)
+	}
+	return deliveries
+}
+
+// reset clears the delivery log; callbacks being sent are still sent.
+func (s *callbackSender) reset() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.deliveries = nil
+}
@@ -0,0 +1,226 @@
+package stubs
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"mockserver/internal/metrics"
+)
+
+// CostConfig is synthetic cost metadata for capacity modeling: every
+// response the stub serves adds Units, amounts by dimension in whatever unit
+// the capacity model uses ("cpu_ms": 12, "db_queries": 3), to the totals of
+// its route. Route defaults to the method and path of the stub
+// ("GET /users/:id"), so that the variants of an endpoint add up together.
+type CostConfig struct {
+	Route string             `json:"route,omitempty"`
+	Units map[string]float64 `json:"units,omitempty"`
+}
+
+// CostReport is the cost accumulated by the routes since Since, the start
+// or the last reset. Totals sums the units over the routes.
+type CostReport struct {
+	Since    time.Time          `json:"since"`
+	Requests int64              `json:"requests"`
+	Totals   map[string]float64 `json:"totals"`
+	Routes   []RouteCost        `json:"routes"`
+}
+
+// RouteCost is the traffic and cost of one route. Throughput is measured
+// between its first and last requests, and so are the per second rates.
+type RouteCost struct {
+	Route         string              `json:"route"`
+	Stubs         []string            `json:"stubs"`
+	Requests      int64               `json:"requests"`
+	FirstRequest  time.Time           `json:"first_request"`
+	LastRequest   time.Time           `json:"last_request"`
+	ThroughputRPS float64             `json:"throughput_rps"`
+	Latency       LatencyStats        `json:"latency_ms"`
+	Cost          map[string]CostStat `json:"cost"`
+}
+
+// LatencyStats summarizes the time taken to serve the responses, delays and
+// locks included.
+type LatencyStats struct {
+	Mean float64 `json:"mean"`
+	Max  float64 `json:"max"`
+}
+
+// CostStat is the accumulated amount of a dimension.
+type CostStat struct {
+	Total      float64 `json:"total"`
+	PerRequest float64 `json:"per_request"`
+	PerSecond  float64 `json:"per_second"`
+}
+
+// costLatencyBuckets are the bounds, in seconds, of the latency histograms.
+var costLatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// compileCost validates the cost of a stub and sets its default route.
+func (c *compiledStub) compileCost() error {
+	cost := *c.Cost
+	for dimension, amount := range cost.Units {
+		if dimension == "" {
+			return fmt.Errorf("cost.units: empty dimension")
+		}
+		if amount < 0 || math.IsNaN(amount) || math.IsInf(amount, 0) {
+			return fmt.Errorf("cost.units: invalid amount %v for %s", amount, dimension)
+		}
+	}
+	if cost.Route == "" {
+		method := c.method
+		if method == "" {
+			method = "ANY"
+		}
+		path := c.Request.Path
+		if path == "" {
+			path = c.Request.PathRegex
+		}
+		if path == "" {
+			path = "*"
+		}
+		cost.Route = method + " " + path
+	}
+	c.Cost = &cost
+	return nil
+}
+
+type routeCosts struct {
+	stubs      map[string]bool
+	requests   int64
+	first      time.Time
+	last       time.Time
+	latency    time.Duration
+	maxLatency time.Duration
+	units      map[string]float64
+	histogram  *metrics.Histogram
+}
+
+// costLedger accumulates the cost of the stubs by route.
+type costLedger struct {
+	routes map[string]*routeCosts
+	since  time.Time
+	mutex  sync.Mutex
+}
+
+func newCostLedger() *costLedger {
+	return &costLedger{routes: make(map[string]*routeCosts), since: time.Now()}
+}
+
+// record adds a response served by a stub with a cost.
+func (l *costLedger) record(stub *compiledStub, start time.Time) {
+	now := time.Now()
+	latency := now.Sub(start)
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	route, ok := l.routes[stub.Cost.Route]
+	if !ok {
+		route = &routeCosts{
+			stubs:     make(map[string]bool),
+			first:     now,
+			units:     make(map[string]float64),
+			histogram: metrics.NewHistogram(costLatencyBuckets...),
+		}
+		l.routes[stub.Cost.Route] = route
+	}
+	route.stubs[stub.ID] = true
+	route.requests++
+	route.last = now
+	route.latency += latency
+	if latency > route.maxLatency {
+		route.maxLatency = latency
+	}
+	for dimension, amount := range stub.Cost.Units {
+		route.units[dimension] += amount
+	}
+	route.histogram.Observe(latency.Seconds())
+}
+
+func (l *costLedger) reset() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.routes = make(map[string]*routeCosts)
+	l.since = time.Now()
+}
+
+func (l *costLedger) report() CostReport {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	report := CostReport{Since: l.since, Totals: map[string]float64{}, Routes: make([]RouteCost, 0, len(l.routes))}
+	for name, route := range l.routes {
+		rc := RouteCost{
+			Route:        name,
+			Stubs:        make([]string, 0, len(route.stubs)),
+			Requests:     route.requests,
+			FirstRequest: route.first,
+			LastRequest:  route.last,
+			Latency: LatencyStats{
+				Mean: durationMs(route.latency) / float64(route.requests),
+				Max:  durationMs(route.maxLatency),
+			},
+			Cost: make(map[string]CostStat, len(route.units)),
+		}
+		for id := range route.stubs {
+			rc.Stubs = append(rc.Stubs, id)
+		}
+		sort.Strings(rc.Stubs)
+		window := route.last.Sub(route.first).Seconds()
+		if window > 0 {
+			rc.ThroughputRPS = float64(route.requests) / window
+		}
+		for dimension, total := range route.units {
+			stat := CostStat{Total: total, PerRequest: total / float64(route.requests)}
+			if window > 0 {
+				stat.PerSecond = total / window
+			}
+			rc.Cost[dimension] = stat
+			report.Totals[dimension] += total
+		}
+		report.Requests += route.requests
+		report.Routes = append(report.Routes, rc)
+	}
+	sort.Slice(report.Routes, func(i, j int) bool { return report.Routes[i].Route < report.Routes[j].Route })
+	return report
+}
+
+// collect writes the requests, units and latency of the routes.
+func (l *costLedger) collect(w *metrics.Writer) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	names := make([]string, 0, len(l.routes))
+	for name := range l.routes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	w.Family("mockserver_stub_cost_requests_total", "counter", "Responses served by the stubs with a cost, by route.")
+	for _, name := range names {
+		w.Sample("mockserver_stub_cost_requests_total", float64(l.routes[name].requests), "route", name)
+	}
+	w.Family("mockserver_stub_cost_units_total", "counter", "Synthetic cost units accumulated, by route and dimension.")
+	for _, name := range names {
+		route := l.routes[name]
+		dimensions := make([]string, 0, len(route.units))
+		for dimension := range route.units {
+			dimensions = append(dimensions, dimension)
+		}
+		sort.Strings(dimensions)
+		for _, dimension := range dimensions {
+			w.Sample("mockserver_stub_cost_units_total", route.units[dimension], "route", name, "dimension", dimension)
+		}
+	}
+	w.Family("mockserver_stub_cost_latency_seconds", "histogram", "Time taken to serve the responses of the stubs with a cost, by route.")
+	for _, name := range names {
+		l.routes[name].histogram.Write(w, "mockserver_stub_cost_latency_seconds", "route", name)
+	}
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
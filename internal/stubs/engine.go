@@ -0,0 +1,564 @@
+package stubs
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"mockserver/internal/correlation"
+	"mockserver/internal/jose"
+	"mockserver/internal/journal"
+	"mockserver/internal/logging"
+	"mockserver/internal/metrics"
+	"mockserver/internal/timefmt"
+)
+
+var logger = logging.For("stubs")
+
+// CacheHeader reports whether a cached stub response was served (HIT) or
+// rendered (MISS).
+const CacheHeader = "X-Mock-Cache"
+
+// Engine stores HTTP stubs and serves the ones matching incoming requests.
+type Engine struct {
+	stubs      []*compiledStub
+	byID       map[string]*compiledStub
+	index      *stubIndex
+	nextID     int
+	seq        int
+	cache      *responseCache
+	failures   *failureCounters
+	locks      *lockTable
+	callbacks  *callbackSender
+	costs      *costLedger
+	keys       *jose.KeySet
+	timestamps timefmt.Formatter
+	dev        bool
+	mutex      sync.RWMutex
+}
+
+func NewEngine() *Engine {
+	return &Engine{
+		byID:      make(map[string]*compiledStub),
+		cache:     newResponseCache(),
+		failures:  newFailureCounters(),
+		locks:     newLockTable(),
+		callbacks: newCallbackSender(),
+		costs:     newCostLedger(),
+	}
+}
+
+// SetKeys makes the keys available to the jws and jwe template helpers and
+// post-processing steps of stubs added afterwards.
+func (e *Engine) SetKeys(keys *jose.KeySet) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.keys = keys
+}
+
+// SetTimestamps sets the format and zone of {{.Timestamp}} in the templates
+// of stubs added afterwards.
+func (e *Engine) SetTimestamps(f timefmt.Formatter) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.timestamps = f
+}
+
+// SetJournal records the callbacks sent by stubs in the journal.
+func (e *Engine) SetJournal(j *journal.Journal) {
+	e.callbacks.mutex.Lock()
+	defer e.callbacks.mutex.Unlock()
+	e.callbacks.journal = j
+}
+
+// SetDevMode makes stubs added afterwards follow their body files: edits
+// are served on the next request, and a file that is missing or whose
+// template does not compile answers 500 with the error instead of
+// rejecting the stub.
+func (e *Engine) SetDevMode(enabled bool) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.dev = enabled
+}
+
+// Add validates and registers a stub, assigning an ID when it has none. A
+// stub with an existing ID replaces it.
+func (e *Engine) Add(stub Stub) (Stub, error) {
+	e.mutex.RLock()
+	keys, timestamps, dev := e.keys, e.timestamps, e.dev
+	e.mutex.RUnlock()
+
+	compiled, err := compile(stub, keys, dev)
+	if err != nil {
+		return Stub{}, fmt.Errorf("stub %s: %w", describe(stub), err)
+	}
+	compiled.timestamps = timestamps
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.addLocked(compiled, stub.ID == "")
+	return compiled.Stub, nil
+}
+
+// Swap removes the stubs with the given IDs and adds stubs in one step, so
+// requests see either the old or the new set. Nothing changes when one of
+// the stubs is invalid.
+func (e *Engine) Swap(remove []string, add []Stub) ([]Stub, error) {
+	e.mutex.RLock()
+	keys, timestamps, dev := e.keys, e.timestamps, e.dev
+	e.mutex.RUnlock()
+
+	compiled := make([]*compiledStub, len(add))
+	for i, stub := range add {
+		c, err := compile(stub, keys, dev)
+		if err != nil {
+			return nil, fmt.Errorf("stub %s: %w", describe(stub), err)
+		}
+		c.timestamps = timestamps
+		compiled[i] = c
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	for _, id := range remove {
+		e.removeLocked(id)
+	}
+	added := make([]Stub, len(compiled))
+	for i, c := range compiled {
+		e.addLocked(c, add[i].ID == "")
+		added[i] = c.Stub
+	}
+	return added, nil
+}
+
+// Validate checks stubs the way Add would, without registering them.
+func (e *Engine) Validate(stubs []Stub) error {
+	e.mutex.RLock()
+	keys, dev := e.keys, e.dev
+	e.mutex.RUnlock()
+
+	for _, stub := range stubs {
+		if _, err := compile(stub, keys, dev); err != nil {
+			return fmt.Errorf("stub %s: %w", describe(stub), err)
+		}
+	}
+	return nil
+}
+
+// addLocked registers a compiled stub; generated asks for a fresh ID even
+// when the compiled stub has one.
+func (e *Engine) addLocked(compiled *compiledStub, generated bool) {
+	for compiled.ID == "" || (generated && e.byID[compiled.ID] != nil) {
+		e.nextID++
+		compiled.ID = fmt.Sprintf("stub-%d", e.nextID)
+	}
+	e.seq++
+	compiled.seq = e.seq
+	if compiled.Lock != nil && compiled.Lock.Name == "" {
+		compiled.Lock.Name = compiled.ID
+	}
+
+	if existing, ok := e.byID[compiled.ID]; ok {
+		compiled.seq = existing.seq
+		e.stubs[e.positionLocked(compiled.ID)] = compiled
+	} else {
+		e.stubs = append(e.stubs, compiled)
+	}
+	e.byID[compiled.ID] = compiled
+	e.index = nil
+	e.cache.invalidate(compiled.ID)
+	e.failures.invalidate(compiled.ID)
+
+	logger.Debug("Stub registered", "stub_id", compiled.ID, "stub", describe(compiled.Stub))
+}
+
+func (e *Engine) positionLocked(id string) int {
+	for i, stub := range e.stubs {
+		if stub.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// indexed returns the lookup index, sorting the stubs and rebuilding the
+// index after they changed. Doing this lazily keeps bulk loading linear.
+func (e *Engine) indexed() *stubIndex {
+	e.mutex.RLock()
+	index := e.index
+	e.mutex.RUnlock()
+	if index != nil {
+		return index
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.index == nil {
+		sort.SliceStable(e.stubs, func(i, j int) bool {
+			if e.stubs[i].Priority != e.stubs[j].Priority {
+				return e.stubs[i].Priority > e.stubs[j].Priority
+			}
+			return e.stubs[i].seq < e.stubs[j].seq
+		})
+		e.index = newStubIndex(e.stubs)
+	}
+	return e.index
+}
+
+// Remove deletes a stub by ID.
+func (e *Engine) Remove(id string) bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.removeLocked(id)
+}
+
+func (e *Engine) removeLocked(id string) bool {
+	if _, ok := e.byID[id]; !ok {
+		return false
+	}
+	i := e.positionLocked(id)
+	e.stubs = append(e.stubs[:i], e.stubs[i+1:]...)
+	delete(e.byID, id)
+	e.index = nil
+	e.cache.invalidate(id)
+	e.failures.invalidate(id)
+	logger.Info("Stub removed", "stub_id", id)
+	return true
+}
+
+// Reset removes every stub.
+func (e *Engine) Reset() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.stubs = nil
+	e.byID = make(map[string]*compiledStub)
+	e.index = nil
+	e.cache.flush()
+	e.failures.reset("")
+	logger.Info("All stubs removed")
+}
+
+// Get returns a stub by ID.
+func (e *Engine) Get(id string) (Stub, bool) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	if stub, ok := e.byID[id]; ok {
+		return stub.Stub, true
+	}
+	return Stub{}, false
+}
+
+// List returns the stubs in evaluation order.
+func (e *Engine) List() []Stub {
+	index := e.indexed()
+	stubs := make([]Stub, len(index.ordered))
+	for i, stub := range index.ordered {
+		stubs[i] = stub.Stub
+	}
+	return stubs
+}
+
+// CacheStats returns the response cache counters.
+func (e *Engine) CacheStats() CacheStats {
+	return e.cache.snapshot()
+}
+
+// FlushCache drops every cached response.
+func (e *Engine) FlushCache() {
+	e.cache.flush()
+	logger.Info("Response cache flushed")
+}
+
+// Attempts returns the call counters of stubs using "fail", keyed by stub ID
+// and request id ("stub-1|abc").
+func (e *Engine) Attempts() map[string]int {
+	return e.failures.snapshot()
+}
+
+// ResetAttempts clears one call counter, or all of them when key is empty.
+func (e *Engine) ResetAttempts(key string) {
+	e.failures.reset(key)
+	logger.Info("Attempt counters reset", "key", key)
+}
+
+// Locks returns the resources locked by requests being processed, keyed by
+// lock name and resource ("stub-1|/accounts/7").
+func (e *Engine) Locks() []HeldLock {
+	return e.locks.snapshot()
+}
+
+// ReleaseLocks releases one lock, or all of them when key is empty, and
+// returns how many were held. The requests holding them still complete.
+func (e *Engine) ReleaseLocks(key string) int {
+	n := e.locks.release(key)
+	logger.Info("Locks released", "locks", n, "key", key)
+	return n
+}
+
+// Callbacks returns the deliveries of the callbacks sent by stubs, oldest
+// first.
+func (e *Engine) Callbacks() []Delivery {
+	return e.callbacks.snapshot()
+}
+
+// ResetCallbacks clears the delivery log.
+func (e *Engine) ResetCallbacks() {
+	e.callbacks.reset()
+	logger.Info("Callback deliveries cleared")
+}
+
+// Costs reports the cost accumulated by the routes of the stubs with a cost.
+func (e *Engine) Costs() CostReport {
+	return e.costs.report()
+}
+
+// ResetCosts clears the cost report.
+func (e *Engine) ResetCosts() {
+	e.costs.reset()
+	logger.Info("Cost report reset")
+}
+
+// Collect writes the cost metrics of the routes.
+func (e *Engine) Collect(w *metrics.Writer) {
+	e.costs.collect(w)
+}
+
+func (e *Engine) find(req *requestData) (*compiledStub, map[string]string) {
+	return e.indexed().find(req)
+}
+
+// Middleware answers requests matching a stub and passes everything else
+// (including the admin API) to the regular routes.
+func (e *Engine) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			r := c.Request()
+			if strings.HasPrefix(r.URL.Path, "/__admin") {
+				return next(c)
+			}
+
+			req := newRequestData(r)
+			stub, params := e.find(req)
+			if stub == nil {
+				return next(c)
+			}
+			req.PathParams = params
+			return e.serve(c, stub, req)
+		}
+	}
+}
+
+func (e *Engine) serve(c echo.Context, stub *compiledStub, req *requestData) error {
+	start := time.Now()
+	r := c.Request()
+	journal.MatchedStub(c, stub.ID)
+	if stub.Fail != nil {
+		key := failureKey(stub, r)
+		attempt := e.failures.next(key)
+		c.Response().Header().Set(AttemptHeader, strconv.Itoa(attempt))
+		if attempt <= stub.Fail.Times {
+			logger.DebugContext(r.Context(), "Stub call failing", "stub_id", stub.ID, "attempt", attempt, "failures", stub.Fail.Times, "status", stub.Fail.Status, "counter", key)
+			for name, value := range stub.Fail.Headers {
+				c.Response().Header().Set(name, value)
+			}
+			return c.String(stub.Fail.Status, stub.Fail.Body)
+		}
+	}
+
+	if stub.Lock != nil {
+		key, err := lockKey(stub, req)
+		if err != nil {
+			return renderError(c, stub, fmt.Errorf("lock.key: %w", err))
+		}
+		release, wait, ok := e.locks.acquire(key, stub.ID, stub.lockHold)
+		if !ok {
+			logger.DebugContext(r.Context(), "Stub call rejected by a lock", "stub_id", stub.ID, "status", stub.Lock.Status, "lock", key)
+			for name, value := range stub.Lock.Headers {
+				c.Response().Header().Set(name, value)
+			}
+			c.Response().Header().Set("Retry-After", retryAfter(wait))
+			return c.String(stub.Lock.Status, stub.Lock.Body)
+		}
+		defer release()
+
+		timer := time.NewTimer(stub.lockHold)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-r.Context().Done():
+			return nil
+		}
+	}
+
+	if stub.delay > 0 {
+		timer := time.NewTimer(stub.delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-r.Context().Done():
+			return nil
+		}
+	}
+
+	body, bodyLocale := stub.selectBody(r)
+	if body.watch != nil {
+		current, changed, err := body.watch.current()
+		if err != nil {
+			return fixtureError(c, stub, body.watch.path, err)
+		}
+		if changed {
+			e.cache.invalidate(stub.ID)
+		}
+		body = current
+	}
+	var response renderedResponse
+	cacheState := ""
+	if stub.cacheTTL > 0 {
+		key := cacheKey(stub, r, bodyLocale)
+		cached, ok := e.cache.get(stub.ID, key)
+		if ok {
+			response = cached
+			cacheState = "HIT"
+		} else {
+			rendered, err := stub.render(req, body, bodyLocale)
+			if err != nil {
+				return renderError(c, stub, err)
+			}
+			e.cache.put(key, rendered, stub.cacheTTL)
+			response = rendered
+			cacheState = "MISS"
+		}
+	} else {
+		rendered, err := stub.render(req, body, bodyLocale)
+		if err != nil {
+			return renderError(c, stub, err)
+		}
+		response = rendered
+	}
+
+	if len(stub.callbacks) > 0 {
+		e.triggerCallbacks(stub, req)
+	}
+
+	logger.DebugContext(r.Context(), "Stub matched", "stub_id", stub.ID)
+	header := c.Response().Header()
+	for name, values := range response.headers {
+		header[name] = values
+	}
+	if cacheState != "" {
+		header.Set(CacheHeader, cacheState)
+	}
+	contentType := response.headers.Get("Content-Type")
+	if contentType == "" {
+		contentType = body.contentType
+	}
+	err := c.Blob(response.status, contentType, response.body)
+	if stub.Cost != nil {
+		e.costs.record(stub, start)
+	}
+	return err
+}
+
+// triggerCallbacks renders the callbacks of a stub for a request and sends
+// them. A callback that does not render is logged and skipped; the response
+// is served regardless.
+func (e *Engine) triggerCallbacks(stub *compiledStub, req *requestData) {
+	req.readBody()
+	data := templateData{Request: req, timestamps: stub.timestamps}
+	correlationID := correlation.FromHeader(req.request.Header)
+	for i, cb := range stub.callbacks {
+		outbound, err := cb.render(data)
+		if err != nil {
+			logger.WarnContext(req.request.Context(), "Cannot render callback", "stub_id", stub.ID, "callback", i, "error", err)
+			continue
+		}
+		e.callbacks.send(stub.ID, correlationID, outbound)
+	}
+}
+
+// render produces the response of a stub for a request from the negotiated
+// body, post-processing included.
+func (c *compiledStub) render(req *requestData, body compiledBody, bodyLocale string) (renderedResponse, error) {
+	response, err := c.renderBody(req, body, bodyLocale)
+	if err != nil {
+		return response, err
+	}
+	if c.locales != nil {
+		response.headers.Add("Vary", "Accept-Language")
+		if bodyLocale != "" && response.headers.Get("Content-Language") == "" {
+			response.headers.Set("Content-Language", bodyLocale)
+		}
+	}
+	if len(c.postProcess) > 0 && response.headers.Get("Content-Type") == "" {
+		// Steps see, and may replace, the content type the response gets.
+		response.headers.Set("Content-Type", body.contentType)
+	}
+	for i, step := range c.postProcess {
+		if err := step(&response); err != nil {
+			return response, fmt.Errorf("post_process[%d]: %w", i, err)
+		}
+	}
+	return response, nil
+}
+
+func (c *compiledStub) renderBody(req *requestData, body compiledBody, bodyLocale string) (renderedResponse, error) {
+	response := renderedResponse{status: c.Response.Status, headers: http.Header{}}
+
+	if body.tmpl == nil {
+		for name, value := range c.Response.Headers {
+			response.headers.Set(name, value)
+		}
+		response.body = body.data
+		return response, nil
+	}
+
+	req.readBody()
+	data := templateData{Request: req, Locale: bodyLocale, timestamps: c.timestamps}
+	var buf bytes.Buffer
+	if err := body.tmpl.Execute(&buf, data); err != nil {
+		return response, err
+	}
+	response.body = buf.Bytes()
+
+	for name, tmpl := range c.headerTmpls {
+		var value bytes.Buffer
+		if err := tmpl.Execute(&value, data); err != nil {
+			return response, fmt.Errorf("header %s: %w", name, err)
+		}
+		response.headers.Set(name, value.String())
+	}
+	return response, nil
+}
+
+func renderError(c echo.Context, stub *compiledStub, err error) error {
+	logger.WarnContext(c.Request().Context(), "Cannot render stub", "stub_id", stub.ID, "error", err)
+	return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+		"error":     "Stub template failed",
+		"stub":      stub.ID,
+		"details":   err.Error(),
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+func describe(stub Stub) string {
+	method := stub.Request.Method
+	if method == "" {
+		method = "ANY"
+	}
+	path := stub.Request.Path
+	if path == "" {
+		path = stub.Request.PathRegex
+	}
+	if stub.Name != "" {
+		return fmt.Sprintf("%s: %s %s", stub.Name, method, path)
+	}
+	return method + " " + path
+}
@@ -0,0 +1,119 @@
+package stubs
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+
+	"mockserver/internal/match"
+)
+
+const benchmarkStubs = 10000
+
+// newBenchmarkEngine loads n stubs mixing literal paths, path parameters and
+// header, query and body matchers with regexes, the shapes large recorded
+// mapping sets consist of.
+func newBenchmarkEngine(b *testing.B, n int) *Engine {
+	log.SetOutput(io.Discard)
+	b.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	engine := NewEngine()
+	for i := 0; i < n; i++ {
+		stub := Stub{Response: Response{JSONBody: []byte(fmt.Sprintf(`{"id":%d}`, i))}}
+		switch i % 3 {
+		case 0:
+			stub.Request = Request{Method: "GET", Path: fmt.Sprintf("/api/items/%d", i)}
+		case 1:
+			stub.Request = Request{
+				Method:  "GET",
+				Path:    fmt.Sprintf("/api/users-%d/:id", i),
+				Headers: []match.FieldMatcher{{Field: "X-Tenant", Regex: fmt.Sprintf("^tenant-%d$", i)}},
+				Query:   []match.FieldMatcher{{Field: "page", Regex: `^\d+$`}},
+			}
+		default:
+			stub.Request = Request{
+				Method: "POST",
+				Path:   "/api/orders",
+				Body:   []match.FieldMatcher{{Field: "order.customer.id", Equals: float64(i)}},
+			}
+			stub.Response.Template = true
+			stub.Response.JSONBody = []byte(`{"order":"{{.Request.JSON.order.customer.id}}","at":"{{now}}"}`)
+		}
+		if _, err := engine.Add(stub); err != nil {
+			b.Fatal(err)
+		}
+	}
+	return engine
+}
+
+// lastOf returns the index of the last stub of the given kind, so lookups
+// have to get past every other candidate.
+func lastOf(kind int) int {
+	i := benchmarkStubs - 1
+	for i%3 != kind {
+		i--
+	}
+	return i
+}
+
+func benchmarkRequests(b *testing.B, engine *Engine, newRequest func() *http.Request) {
+	e := echo.New()
+	handler := engine.Middleware()(func(c echo.Context) error {
+		return c.NoContent(http.StatusNotFound)
+	})
+
+	// Warm the index so the first iteration doesn't pay for sorting.
+	engine.List()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rec := httptest.NewRecorder()
+		if err := handler(e.NewContext(newRequest(), rec)); err != nil {
+			b.Fatal(err)
+		}
+		if rec.Code != http.StatusOK {
+			b.Fatalf("unexpected status %d", rec.Code)
+		}
+	}
+}
+
+func BenchmarkLoadStubs(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		newBenchmarkEngine(b, benchmarkStubs).List()
+	}
+}
+
+func BenchmarkLiteralPath(b *testing.B) {
+	engine := newBenchmarkEngine(b, benchmarkStubs)
+	benchmarkRequests(b, engine, func() *http.Request {
+		return httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/items/%d", lastOf(0)), nil)
+	})
+}
+
+func BenchmarkPathParamsAndRegex(b *testing.B) {
+	engine := newBenchmarkEngine(b, benchmarkStubs)
+	last := lastOf(1)
+	benchmarkRequests(b, engine, func() *http.Request {
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/users-%d/42?page=3", last), nil)
+		req.Header.Set("X-Tenant", fmt.Sprintf("tenant-%d", last))
+		return req
+	})
+}
+
+func BenchmarkBodyMatcherTemplate(b *testing.B) {
+	engine := newBenchmarkEngine(b, benchmarkStubs)
+	body := fmt.Sprintf(`{"order":{"customer":{"id":%d}}}`, lastOf(2))
+	benchmarkRequests(b, engine, func() *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/api/orders", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		return req
+	})
+}
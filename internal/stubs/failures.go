@@ -0,0 +1,108 @@
+package stubs
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// AttemptHeader carries the call number of stubs using "fail".
+	AttemptHeader = "X-Mock-Attempt"
+	// DefaultFailKey is the request header identifying a logical request.
+	DefaultFailKey = "X-Request-Id"
+
+	failureTTL = 10 * time.Minute
+)
+
+// FailConfig makes the first Times requests matching a stub fail before its
+// response is served, counted per request id: the value of the Key header
+// (default X-Request-Id), or a single counter when it is absent.
+type FailConfig struct {
+	Times   int               `json:"times"`
+	Status  int               `json:"status,omitempty"`
+	Body    string            `json:"body,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Key     string            `json:"key,omitempty"`
+}
+
+type failureRecord struct {
+	count    int
+	lastSeen time.Time
+}
+
+// failureCounters counts calls per stub and request id. Idle counters expire
+// so unique request ids don't accumulate.
+type failureCounters struct {
+	counts map[string]*failureRecord
+	mutex  sync.Mutex
+}
+
+func newFailureCounters() *failureCounters {
+	return &failureCounters{counts: make(map[string]*failureRecord)}
+}
+
+func failureKey(stub *compiledStub, r *http.Request) string {
+	if id := r.Header.Get(stub.Fail.Key); id != "" {
+		return stub.ID + "|" + id
+	}
+	return stub.ID
+}
+
+// next records a call and returns its 1-based number.
+func (f *failureCounters) next(key string) int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	now := time.Now()
+	for k, record := range f.counts {
+		if now.Sub(record.lastSeen) > failureTTL {
+			delete(f.counts, k)
+		}
+	}
+
+	record, ok := f.counts[key]
+	if !ok {
+		record = &failureRecord{}
+		f.counts[key] = record
+	}
+	record.count++
+	record.lastSeen = now
+	return record.count
+}
+
+// reset clears one counter, or all of them when key is empty.
+func (f *failureCounters) reset(key string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if key == "" {
+		f.counts = make(map[string]*failureRecord)
+		return
+	}
+	delete(f.counts, key)
+}
+
+// invalidate clears every counter of a stub.
+func (f *failureCounters) invalidate(stubID string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	for key := range f.counts {
+		if key == stubID || strings.HasPrefix(key, stubID+"|") {
+			delete(f.counts, key)
+		}
+	}
+}
+
+func (f *failureCounters) snapshot() map[string]int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	counts := make(map[string]int, len(f.counts))
+	for key, record := range f.counts {
+		counts[key] = record.count
+	}
+	return counts
+}
@@ -0,0 +1,89 @@
+package stubs
+
+import (
+	"fmt"
+	mathrand "math/rand/v2"
+	"strings"
+	"time"
+)
+
+// Word lists backing the fake* template helpers. They are small on purpose:
+// the goal is plausible looking data, not realistic distributions.
+var (
+	firstNames = []string{"Alice", "Bob", "Carol", "David", "Emma", "Farid", "Grace", "Hiro", "Ines", "Jamal", "Keiko", "Liam", "Maria", "Noah", "Olga", "Priya", "Quinn", "Rosa", "Sven", "Tariq", "Uma", "Victor", "Wen", "Yara", "Zoe"}
+	lastNames  = []string{"Anderson", "Brown", "Chen", "Dubois", "Evans", "Fischer", "Garcia", "Hernandez", "Ivanova", "Johnson", "Kim", "Lopez", "Müller", "Nguyen", "Okafor", "Patel", "Rossi", "Smith", "Tanaka", "Williams"}
+	cities     = []string{"Amsterdam", "Berlin", "Cairo", "Dublin", "Hanoi", "Lagos", "Lima", "Lisbon", "Melbourne", "Montreal", "Mumbai", "Nairobi", "Osaka", "Paris", "Seoul", "Toronto"}
+	countries  = []string{"Australia", "Brazil", "Canada", "Egypt", "France", "Germany", "India", "Japan", "Kenya", "Mexico", "Netherlands", "Nigeria", "Peru", "Portugal", "South Korea", "Vietnam"}
+	companies  = []string{"Acme", "Globex", "Initech", "Umbrella", "Hooli", "Stark Industries", "Wayne Enterprises", "Wonka", "Cyberdyne", "Soylent"}
+	domains    = []string{"example.com", "example.org", "example.net", "mail.test"}
+	words      = []string{"lorem", "ipsum", "dolor", "sit", "amet", "consectetur", "adipiscing", "elit", "sed", "do", "eiusmod", "tempor", "incididunt", "ut", "labore", "et", "dolore", "magna", "aliqua", "enim", "minim", "veniam"}
+)
+
+var fakerFuncs = map[string]interface{}{
+	"fakeFirstName": func() string { return pick(firstNames) },
+	"fakeLastName":  func() string { return pick(lastNames) },
+	"fakeName":      fakeName,
+	"fakeEmail":     fakeEmail,
+	"fakeUsername":  fakeUsername,
+	"fakePhone":     fakePhone,
+	"fakeCity":      func() string { return pick(cities) },
+	"fakeCountry":   func() string { return pick(countries) },
+	"fakeCompany":   func() string { return pick(companies) },
+	"fakeWord":      func() string { return pick(words) },
+	"fakeSentence":  fakeSentence,
+	"fakeUUID":      newUUID,
+	"fakeInt":       randomInt,
+	"fakeFloat":     fakeFloat,
+	"fakeBool":      func() bool { return mathrand.IntN(2) == 1 },
+	"fakeIPv4":      fakeIPv4,
+	"fakeDate":      fakeDate,
+}
+
+func pick(list []string) string {
+	return list[mathrand.IntN(len(list))]
+}
+
+func fakeName() string {
+	return pick(firstNames) + " " + pick(lastNames)
+}
+
+func fakeUsername() string {
+	return strings.ToLower(pick(firstNames)) + fmt.Sprint(mathrand.IntN(1000))
+}
+
+func fakeEmail() string {
+	return strings.ToLower(pick(firstNames)+"."+pick(lastNames)) + "@" + pick(domains)
+}
+
+func fakePhone() string {
+	return fmt.Sprintf("+1-555-%03d-%04d", mathrand.IntN(1000), mathrand.IntN(10000))
+}
+
+// fakeSentence returns n words (default 8) starting with a capital letter.
+func fakeSentence(n ...int) string {
+	count := 8
+	if len(n) > 0 && n[0] > 0 {
+		count = n[0]
+	}
+	parts := make([]string, count)
+	for i := range parts {
+		parts[i] = pick(words)
+	}
+	sentence := strings.Join(parts, " ")
+	return strings.ToUpper(sentence[:1]) + sentence[1:] + "."
+}
+
+// fakeFloat returns a number in [min, max) rounded to two decimals.
+func fakeFloat(min, max float64) float64 {
+	value := min + mathrand.Float64()*(max-min)
+	return float64(int64(value*100)) / 100
+}
+
+func fakeIPv4() string {
+	return fmt.Sprintf("%d.%d.%d.%d", 1+mathrand.IntN(223), mathrand.IntN(256), mathrand.IntN(256), 1+mathrand.IntN(254))
+}
+
+// fakeDate returns a date within the last year formatted as YYYY-MM-DD.
+func fakeDate() string {
+	return time.Now().AddDate(0, 0, -mathrand.IntN(365)).Format("2006-01-02")
+}
@@ -0,0 +1,96 @@
+package stubs
+
+import (
+	"sort"
+	"strings"
+)
+
+// stubIndex is an immutable snapshot of the stubs in evaluation order. Path
+// patterns are stored in a segment tree so a lookup in a large stub set only
+// evaluates the stubs whose path can match; regex and path-less stubs are
+// always candidates.
+type stubIndex struct {
+	ordered []*compiledStub
+	paths   *pathNode
+	always  []int
+}
+
+// pathNode holds the stubs whose pattern ends at this depth (exact), the ones
+// with a trailing "*" here (wildcard), and the literal and ":param" children.
+type pathNode struct {
+	literal  map[string]*pathNode
+	param    *pathNode
+	exact    []int
+	wildcard []int
+}
+
+func newStubIndex(stubs []*compiledStub) *stubIndex {
+	index := &stubIndex{
+		ordered: append([]*compiledStub(nil), stubs...),
+		paths:   &pathNode{},
+	}
+	for i, stub := range index.ordered {
+		if stub.pathRegex != nil || stub.segments == nil {
+			index.always = append(index.always, i)
+			continue
+		}
+		node := index.paths
+		for _, segment := range stub.segments {
+			node = node.child(segment)
+		}
+		if stub.wildcard {
+			node.wildcard = append(node.wildcard, i)
+		} else {
+			node.exact = append(node.exact, i)
+		}
+	}
+	return index
+}
+
+func (n *pathNode) child(segment string) *pathNode {
+	if strings.HasPrefix(segment, ":") {
+		if n.param == nil {
+			n.param = &pathNode{}
+		}
+		return n.param
+	}
+	if n.literal == nil {
+		n.literal = make(map[string]*pathNode)
+	}
+	next, ok := n.literal[segment]
+	if !ok {
+		next = &pathNode{}
+		n.literal[segment] = next
+	}
+	return next
+}
+
+// collect appends the positions of every stub whose path pattern can match
+// parts, starting at depth.
+func (n *pathNode) collect(parts []string, depth int, positions []int) []int {
+	positions = append(positions, n.wildcard...)
+	if depth == len(parts) {
+		return append(positions, n.exact...)
+	}
+	if next, ok := n.literal[parts[depth]]; ok {
+		positions = next.collect(parts, depth+1, positions)
+	}
+	if n.param != nil {
+		positions = n.param.collect(parts, depth+1, positions)
+	}
+	return positions
+}
+
+// find returns the first stub in evaluation order matching the request.
+func (index *stubIndex) find(req *requestData) (*compiledStub, map[string]string) {
+	positions := index.paths.collect(req.pathParts(), 0, append([]int(nil), index.always...))
+	sort.Ints(positions)
+
+	for _, position := range positions {
+		stub := index.ordered[position]
+		if params, ok := stub.matches(req); ok {
+			return stub, params
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,137 @@
+package stubs
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	mathrand "math/rand/v2"
+	"strconv"
+	"sync"
+	"text/template"
+)
+
+// jitterSource backs the jitter helpers of one stub. With a seed, the values
+// a stub produces repeat from one run (or stub update) to the next.
+type jitterSource struct {
+	rng    *mathrand.Rand
+	drifts map[string]float64
+	mutex  sync.Mutex
+}
+
+func newJitterSource(seed *uint64) *jitterSource {
+	var src mathrand.Source
+	if seed != nil {
+		src = mathrand.NewPCG(*seed, *seed)
+	} else {
+		src = mathrand.NewPCG(mathrand.Uint64(), mathrand.Uint64())
+	}
+	return &jitterSource{rng: mathrand.New(src), drifts: make(map[string]float64)}
+}
+
+// jitterFuncs binds the jitter helpers to the source of a stub:
+//
+//	{"price": {{jitter 19.99 0.5}}, "stock": {{jitterPct 120 10}},
+//	 "rate": {{drift `eur` 1.08 0.01 1 1.2 4}}}
+//
+// jitter varies a value by up to spread either way, jitterPct by up to a
+// percentage of it, and drift walks a named value (from start, by up to step
+// per call, within min and max) so consecutive responses stay close. Results
+// are integers when the arguments are; floats are rounded to 2 decimals, or
+// to the optional last argument.
+func jitterFuncs(j *jitterSource) template.FuncMap {
+	return template.FuncMap{
+		"jitter": func(value, spread interface{}, decimals ...int) (interface{}, error) {
+			args, integer, err := jitterArgs(value, spread)
+			if err != nil {
+				return nil, err
+			}
+			return jitterResult(args[0]+j.uniform(args[1]), integer, decimals), nil
+		},
+		"jitterPct": func(value, percent interface{}, decimals ...int) (interface{}, error) {
+			args, integer, err := jitterArgs(value, percent)
+			if err != nil {
+				return nil, err
+			}
+			return jitterResult(args[0]+j.uniform(math.Abs(args[0])*args[1]/100), integer, decimals), nil
+		},
+		"drift": func(name string, start, step, min, max interface{}, decimals ...int) (interface{}, error) {
+			args, integer, err := jitterArgs(start, step, min, max)
+			if err != nil {
+				return nil, err
+			}
+			if args[2] > args[3] {
+				return nil, fmt.Errorf("drift %s: min %v is above max %v", name, args[2], args[3])
+			}
+			return jitterResult(j.drift(name, args[0], args[1], args[2], args[3]), integer, decimals), nil
+		},
+	}
+}
+
+// uniform returns a value in [-spread, spread].
+func (j *jitterSource) uniform(spread float64) float64 {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	return (j.rng.Float64()*2 - 1) * spread
+}
+
+func (j *jitterSource) drift(name string, start, step, min, max float64) float64 {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	value, ok := j.drifts[name]
+	if !ok {
+		value = start
+	} else {
+		value += (j.rng.Float64()*2 - 1) * step
+	}
+	value = math.Max(min, math.Min(max, value))
+	j.drifts[name] = value
+	return value
+}
+
+// jitterArgs converts numeric template arguments, reporting whether they
+// are all integers.
+func jitterArgs(values ...interface{}) ([]float64, bool, error) {
+	args := make([]float64, len(values))
+	integer := true
+	for i, value := range values {
+		switch v := value.(type) {
+		case int:
+			args[i] = float64(v)
+		case int64:
+			args[i] = float64(v)
+		case float64:
+			args[i], integer = v, integer && v == math.Trunc(v)
+		case json.Number:
+			f, err := v.Float64()
+			if err != nil {
+				return nil, false, fmt.Errorf("not a number: %q", v)
+			}
+			args[i] = f
+			_, intErr := v.Int64()
+			integer = integer && intErr == nil
+		case string:
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return nil, false, fmt.Errorf("not a number: %q", v)
+			}
+			args[i] = f
+			_, intErr := strconv.ParseInt(v, 10, 64)
+			integer = integer && intErr == nil
+		default:
+			return nil, false, fmt.Errorf("not a number: %v", value)
+		}
+	}
+	return args, integer, nil
+}
+
+func jitterResult(value float64, integer bool, decimals []int) interface{} {
+	if integer && len(decimals) == 0 {
+		return int64(math.Round(value))
+	}
+	places := 2
+	if len(decimals) > 0 {
+		places = decimals[0]
+	}
+	scale := math.Pow(10, float64(places))
+	return math.Round(value*scale) / scale
+}
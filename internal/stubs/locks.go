@@ -0,0 +1,129 @@
+package stubs
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LockConfig simulates a locked resource: a request matching the stub locks
+// the resource named by Key (a template, default the request path) for the
+// processing time Duration before its response is served, and requests for
+// the same resource arriving meanwhile get Status (default 423), Body and
+// Headers plus a Retry-After. Stubs with the same Name share their locks,
+// e.g. the PUT and DELETE of one resource; the default is the stub ID.
+type LockConfig struct {
+	Key      string            `json:"key,omitempty"`
+	Name     string            `json:"name,omitempty"`
+	Duration string            `json:"duration"`
+	Status   int               `json:"status,omitempty"`
+	Body     string            `json:"body,omitempty"`
+	Headers  map[string]string `json:"headers,omitempty"`
+}
+
+// HeldLock is a resource locked by a request being processed.
+type HeldLock struct {
+	Key   string    `json:"key"`
+	Stub  string    `json:"stub"`
+	Since time.Time `json:"since"`
+	Until time.Time `json:"until"`
+}
+
+type heldLock struct {
+	HeldLock
+	token uint64
+}
+
+// lockTable holds the locked resources, keyed by lock name and resource.
+// A lock lasts until the response of its holder is sent.
+type lockTable struct {
+	locks map[string]*heldLock
+	next  uint64
+	mutex sync.Mutex
+}
+
+func newLockTable() *lockTable {
+	return &lockTable{locks: make(map[string]*heldLock)}
+}
+
+// lockKey renders the resource a request locks.
+func lockKey(stub *compiledStub, req *requestData) (string, error) {
+	resource := req.Path
+	if stub.lockKey != nil {
+		req.readBody()
+		var key strings.Builder
+		if err := stub.lockKey.Execute(&key, templateData{Request: req, timestamps: stub.timestamps}); err != nil {
+			return "", err
+		}
+		resource = key.String()
+	}
+	return stub.Lock.Name + "|" + resource, nil
+}
+
+// acquire locks a resource for processing time d. When it is held already,
+// ok is false and retry is the processing time its holder has left.
+func (t *lockTable) acquire(key, stubID string, d time.Duration) (release func(), retry time.Duration, ok bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	now := time.Now()
+	if held, exists := t.locks[key]; exists {
+		return nil, held.Until.Sub(now), false
+	}
+	t.next++
+	lock := &heldLock{
+		HeldLock: HeldLock{Key: key, Stub: stubID, Since: now, Until: now.Add(d)},
+		token:    t.next,
+	}
+	t.locks[key] = lock
+	return func() {
+		t.mutex.Lock()
+		defer t.mutex.Unlock()
+		// The lock may have been released from the admin API meanwhile
+		if held := t.locks[key]; held != nil && held.token == lock.token {
+			delete(t.locks, key)
+		}
+	}, 0, true
+}
+
+// release drops one lock, or all of them when key is empty, and returns how
+// many were held.
+func (t *lockTable) release(key string) int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if key == "" {
+		n := len(t.locks)
+		t.locks = make(map[string]*heldLock)
+		return n
+	}
+	if _, ok := t.locks[key]; !ok {
+		return 0
+	}
+	delete(t.locks, key)
+	return 1
+}
+
+func (t *lockTable) snapshot() []HeldLock {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	locks := make([]HeldLock, 0, len(t.locks))
+	for _, lock := range t.locks {
+		locks = append(locks, lock.HeldLock)
+	}
+	sort.Slice(locks, func(i, j int) bool { return locks[i].Since.Before(locks[j].Since) })
+	return locks
+}
+
+// retryAfter renders a wait as Retry-After seconds, rounded up so that a
+// client retrying on time finds the resource free.
+func retryAfter(wait time.Duration) string {
+	seconds := int64((wait + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	return strconv.FormatInt(seconds, 10)
+}
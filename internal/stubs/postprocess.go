@@ -0,0 +1,226 @@
+package stubs
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+
+	"mockserver/internal/jose"
+)
+
+// PostProcessor is one step of a stub's post-processing pipeline, applied in
+// order to the rendered response. Type selects the step and the fields it
+// uses:
+//
+//   - "headers": sets Headers.
+//   - "gzip": compresses the body and sets Content-Encoding.
+//   - "sign": sets Header (default X-Signature) to Prefix plus the HMAC of the
+//     body keyed with Secret, using Algorithm (sha256, the default, sha1 or
+//     sha512) and Encoding (hex, the default, or base64).
+//   - "envelope": wraps the body in a JSON object under Field (default
+//     "data") next to the fields of Envelope. Non-JSON bodies are embedded
+//     as strings.
+//   - "truncate": cuts the body to Bytes bytes.
+//   - "jws": replaces the body with its compact JWS signed with Key.
+//   - "jwe": replaces the body with its compact JWE encrypted for Key, with
+//     ContentType as the "cty" header (e.g. "JWT" after a "jws" step).
+type PostProcessor struct {
+	Type        string            `json:"type"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	Header      string            `json:"header,omitempty"`
+	Secret      string            `json:"secret,omitempty"`
+	Algorithm   string            `json:"algorithm,omitempty"`
+	Encoding    string            `json:"encoding,omitempty"`
+	Prefix      string            `json:"prefix,omitempty"`
+	Field       string            `json:"field,omitempty"`
+	Envelope    json.RawMessage   `json:"envelope,omitempty"`
+	Bytes       *int              `json:"bytes,omitempty"`
+	Key         string            `json:"key,omitempty"`
+	ContentType string            `json:"content_type,omitempty"`
+}
+
+// DefaultSignatureHeader carries the body signature of "sign" steps.
+const DefaultSignatureHeader = "X-Signature"
+
+type postProcessFunc func(*renderedResponse) error
+
+var errNoKeys = errors.New("no JOSE keys configured")
+
+// compilePostProcessors validates the pipeline and returns its steps.
+func compilePostProcessors(steps []PostProcessor, keys *jose.KeySet) ([]postProcessFunc, error) {
+	funcs := make([]postProcessFunc, 0, len(steps))
+	for i, step := range steps {
+		fn, err := step.compile(keys)
+		if err != nil {
+			return nil, fmt.Errorf("post_process[%d]: %w", i, err)
+		}
+		funcs = append(funcs, fn)
+	}
+	return funcs, nil
+}
+
+func (p PostProcessor) compile(keys *jose.KeySet) (postProcessFunc, error) {
+	switch p.Type {
+	case "headers":
+		headers := p.Headers
+		return func(res *renderedResponse) error {
+			for name, value := range headers {
+				res.headers.Set(name, value)
+			}
+			return nil
+		}, nil
+
+	case "gzip":
+		return gzipBody, nil
+
+	case "sign":
+		return p.compileSign()
+
+	case "envelope":
+		field := p.Field
+		if field == "" {
+			field = "data"
+		}
+		envelope := map[string]json.RawMessage{}
+		if len(p.Envelope) > 0 {
+			if err := json.Unmarshal(p.Envelope, &envelope); err != nil {
+				return nil, fmt.Errorf("envelope must be a JSON object: %w", err)
+			}
+		}
+		return func(res *renderedResponse) error {
+			return wrapBody(res, field, envelope)
+		}, nil
+
+	case "truncate":
+		if p.Bytes == nil || *p.Bytes < 0 {
+			return nil, fmt.Errorf("truncate needs a non-negative bytes")
+		}
+		n := *p.Bytes
+		return func(res *renderedResponse) error {
+			if len(res.body) > n {
+				res.body = res.body[:n]
+			}
+			return nil
+		}, nil
+
+	case "jws", "jwe":
+		return p.compileJOSE(keys)
+	}
+	return nil, fmt.Errorf("unknown type %q (want headers, gzip, sign, envelope, truncate, jws or jwe)", p.Type)
+}
+
+func (p PostProcessor) compileSign() (postProcessFunc, error) {
+	if p.Secret == "" {
+		return nil, fmt.Errorf("sign needs a secret")
+	}
+	var newHash func() hash.Hash
+	switch p.Algorithm {
+	case "", "sha256":
+		newHash = sha256.New
+	case "sha1":
+		newHash = sha1.New
+	case "sha512":
+		newHash = sha512.New
+	default:
+		return nil, fmt.Errorf("unknown algorithm %q (want sha256, sha1 or sha512)", p.Algorithm)
+	}
+	var encode func([]byte) string
+	switch p.Encoding {
+	case "", "hex":
+		encode = hex.EncodeToString
+	case "base64":
+		encode = base64.StdEncoding.EncodeToString
+	default:
+		return nil, fmt.Errorf("unknown encoding %q (want hex or base64)", p.Encoding)
+	}
+	header := p.Header
+	if header == "" {
+		header = DefaultSignatureHeader
+	}
+	secret := []byte(p.Secret)
+	prefix := p.Prefix
+
+	return func(res *renderedResponse) error {
+		mac := hmac.New(newHash, secret)
+		mac.Write(res.body)
+		res.headers.Set(header, prefix+encode(mac.Sum(nil)))
+		return nil
+	}, nil
+}
+
+func (p PostProcessor) compileJOSE(keys *jose.KeySet) (postProcessFunc, error) {
+	if keys == nil {
+		return nil, errNoKeys
+	}
+	if p.Key == "" {
+		return nil, fmt.Errorf("%s needs a key", p.Type)
+	}
+	if _, err := keys.Lookup(p.Key); err != nil {
+		return nil, err
+	}
+	kid, cty, encrypt := p.Key, p.ContentType, p.Type == "jwe"
+
+	return func(res *renderedResponse) error {
+		var token string
+		var err error
+		if encrypt {
+			token, err = keys.Encrypt(kid, res.body, cty)
+		} else {
+			token, err = keys.Sign(kid, res.body)
+		}
+		if err != nil {
+			return err
+		}
+		res.body = []byte(token)
+		res.headers.Set("Content-Type", "application/jose")
+		return nil
+	}, nil
+}
+
+func gzipBody(res *renderedResponse) error {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(res.body); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	res.body = buf.Bytes()
+	res.headers.Set("Content-Encoding", "gzip")
+	res.headers.Add("Vary", "Accept-Encoding")
+	return nil
+}
+
+func wrapBody(res *renderedResponse, field string, envelope map[string]json.RawMessage) error {
+	body := json.RawMessage(res.body)
+	if !json.Valid(res.body) {
+		quoted, err := json.Marshal(string(res.body))
+		if err != nil {
+			return err
+		}
+		body = quoted
+	}
+	wrapped := make(map[string]json.RawMessage, len(envelope)+1)
+	for key, value := range envelope {
+		wrapped[key] = value
+	}
+	wrapped[field] = body
+
+	data, err := json.Marshal(wrapped)
+	if err != nil {
+		return err
+	}
+	res.body = data
+	res.headers.Set("Content-Type", "application/json")
+	return nil
+}
@@ -0,0 +1,365 @@
+package stubs
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/labstack/echo/v4"
+
+	"mockserver/internal/match"
+)
+
+const (
+	// maxRecordings bounds the recordings kept; the oldest are dropped.
+	maxRecordings = 1000
+	// maxRecordedBody caps the bodies kept in a recording. Larger responses
+	// are relayed whole but cannot be turned into stubs.
+	maxRecordedBody = 10 * 1024 * 1024
+
+	upstreamTimeout = 30 * time.Second
+)
+
+// hopHeaders are connection specific and never forwarded, recorded or
+// replayed. Content-Length and Date are recomputed on replay.
+var hopHeaders = map[string]bool{
+	"Connection":          true,
+	"Keep-Alive":          true,
+	"Proxy-Authenticate":  true,
+	"Proxy-Authorization": true,
+	"Proxy-Connection":    true,
+	"Te":                  true,
+	"Trailer":             true,
+	"Transfer-Encoding":   true,
+	"Upgrade":             true,
+}
+
+// ProxyConfig configures the record-and-proxy mode of the HTTP stubs.
+type ProxyConfig struct {
+	// Upstream is the base URL requests are forwarded to, e.g.
+	// "https://api.example.com".
+	Upstream string `json:"upstream,omitempty"`
+	// InsecureSkipVerify skips the verification of the upstream certificate.
+	InsecureSkipVerify bool `json:"insecure_skip_verify,omitempty"`
+	// MatchHeaders are the request headers the generated stubs match, in
+	// addition to the method, path, query and JSON body fields.
+	MatchHeaders []string `json:"match_headers,omitempty"`
+}
+
+// Recording is one proxied request and the upstream response. Bodies that
+// are not valid UTF-8 are base64 encoded.
+type Recording struct {
+	ID                 string            `json:"id"`
+	Timestamp          time.Time         `json:"timestamp"`
+	Method             string            `json:"method"`
+	Path               string            `json:"path"`
+	Query              string            `json:"query,omitempty"`
+	RequestHeaders     map[string]string `json:"request_headers,omitempty"`
+	RequestBody        string            `json:"request_body,omitempty"`
+	RequestBodyBase64  bool              `json:"request_body_base64,omitempty"`
+	Status             int               `json:"status"`
+	ResponseHeaders    map[string]string `json:"response_headers,omitempty"`
+	ResponseBody       string            `json:"response_body,omitempty"`
+	ResponseBodyBase64 bool              `json:"response_body_base64,omitempty"`
+	Truncated          bool              `json:"truncated,omitempty"`
+	Error              string            `json:"error,omitempty"`
+	LatencyMs          float64           `json:"latency_ms"`
+}
+
+// Recorder forwards the requests no stub or route answers to an upstream
+// server and records them, so they can be turned into stubs for offline
+// runs.
+type Recorder struct {
+	config     ProxyConfig
+	upstream   *url.URL
+	client     *http.Client
+	recordings []Recording
+	nextID     int
+	mutex      sync.RWMutex
+}
+
+func NewRecorder(config ProxyConfig) (*Recorder, error) {
+	upstream, err := url.Parse(config.Upstream)
+	if err != nil || (upstream.Scheme != "http" && upstream.Scheme != "https") || upstream.Host == "" {
+		return nil, fmt.Errorf("invalid proxy upstream %q", config.Upstream)
+	}
+	upstream.Path = strings.TrimRight(upstream.Path, "/")
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: config.InsecureSkipVerify}
+	return &Recorder{
+		config:   config,
+		upstream: upstream,
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   upstreamTimeout,
+			// Redirects are relayed to the client and recorded as such
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+	}, nil
+}
+
+// Upstream returns the base URL requests are forwarded to.
+func (r *Recorder) Upstream() string {
+	return r.upstream.String()
+}
+
+// Forward relays a request to the upstream and records the exchange. It is
+// the handler of the requests no route matches; admin requests keep their
+// 404.
+func (r *Recorder) Forward(c echo.Context) error {
+	req := c.Request()
+	if strings.HasPrefix(req.URL.Path, "/__admin") {
+		return echo.ErrNotFound
+	}
+
+	start := time.Now()
+	recording := Recording{Timestamp: start, Method: req.Method, Path: req.URL.Path, Query: req.URL.RawQuery}
+	body, err := io.ReadAll(io.LimitReader(req.Body, maxRecordedBody+1))
+	if err != nil {
+		return r.fail(c, recording, start, err)
+	}
+	recorded := body
+	if len(recorded) > maxRecordedBody {
+		recording.Truncated = true
+		recorded = recorded[:maxRecordedBody]
+	}
+	recording.RequestBody, recording.RequestBodyBase64 = encodeBody(recorded)
+
+	target := *r.upstream
+	target.Path = r.upstream.Path + req.URL.Path
+	target.RawPath = ""
+	target.RawQuery = req.URL.RawQuery
+	out, err := http.NewRequestWithContext(req.Context(), req.Method, target.String(), io.MultiReader(bytes.NewReader(body), req.Body))
+	if err != nil {
+		return r.fail(c, recording, start, err)
+	}
+	out.ContentLength = req.ContentLength
+	for name, values := range req.Header {
+		// The transport negotiates compression itself and hands back the
+		// decoded body, which is what the stubs need
+		if hopHeaders[name] || name == "Accept-Encoding" {
+			continue
+		}
+		out.Header[name] = values
+	}
+	recording.RequestHeaders = flattenHeader(out.Header)
+
+	resp, err := r.client.Do(out)
+	if err != nil {
+		return r.fail(c, recording, start, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxRecordedBody+1))
+	if err != nil {
+		return r.fail(c, recording, start, err)
+	}
+	recorded = data
+	if len(recorded) > maxRecordedBody {
+		recording.Truncated = true
+		recorded = recorded[:maxRecordedBody]
+	}
+	recording.Status = resp.StatusCode
+	recording.ResponseHeaders = flattenHeader(replayedHeader(resp.Header))
+	recording.ResponseBody, recording.ResponseBodyBase64 = encodeBody(recorded)
+
+	header := c.Response().Header()
+	for name, values := range resp.Header {
+		if !hopHeaders[name] {
+			header[name] = values
+		}
+	}
+	if resp.Uncompressed {
+		header.Del("Content-Length")
+		header.Del("Content-Encoding")
+	}
+	c.Response().WriteHeader(resp.StatusCode)
+	c.Response().Write(data)
+	_, err = io.Copy(c.Response(), resp.Body)
+	r.store(c.Request().Context(), recording, start)
+	return err
+}
+
+// fail records a request the upstream did not answer and replies 502.
+func (r *Recorder) fail(c echo.Context, recording Recording, start time.Time, err error) error {
+	recording.Status = http.StatusBadGateway
+	recording.Error = err.Error()
+	r.store(c.Request().Context(), recording, start)
+	return c.JSON(http.StatusBadGateway, map[string]interface{}{
+		"error":     "Upstream request failed",
+		"details":   err.Error(),
+		"provided":  r.upstream.String(),
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+func (r *Recorder) store(ctx context.Context, recording Recording, start time.Time) {
+	recording.LatencyMs = durationMs(time.Since(start))
+	r.mutex.Lock()
+	r.nextID++
+	recording.ID = fmt.Sprintf("rec-%d", r.nextID)
+	r.recordings = append(r.recordings, recording)
+	if len(r.recordings) > maxRecordings {
+		r.recordings = r.recordings[len(r.recordings)-maxRecordings:]
+	}
+	r.mutex.Unlock()
+
+	if recording.Error != "" {
+		logger.WarnContext(ctx, "Upstream request failed", "upstream", r.upstream.String(), "recording_id", recording.ID, "error", recording.Error)
+		return
+	}
+	logger.DebugContext(ctx, "Proxied request", "upstream", r.upstream.String(), "status", recording.Status, "recording_id", recording.ID)
+}
+
+// Recordings returns the recorded requests, oldest first.
+func (r *Recorder) Recordings() []Recording {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return append([]Recording{}, r.recordings...)
+}
+
+// ResetRecordings drops every recording.
+func (r *Recorder) ResetRecordings() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.recordings = nil
+	logger.Info("Proxy recordings cleared")
+}
+
+// Stubs converts the recordings into stubs, one per distinct request. A
+// stub matches the method, the path, the query parameters, the configured
+// headers and the top-level scalar fields of a JSON body, and answers with
+// the recorded status, headers and body. Failed, truncated and binary
+// recordings, and the repeats of a request, are skipped.
+func (r *Recorder) Stubs() ([]Stub, []string) {
+	var stubs []Stub
+	var skipped []string
+	seen := make(map[string]string)
+	for _, recording := range r.Recordings() {
+		stub, err := r.stub(recording)
+		if err != nil {
+			skipped = append(skipped, fmt.Sprintf("%s %s %s: %v", recording.ID, recording.Method, recording.Path, err))
+			continue
+		}
+		key, _ := json.Marshal(stub.Request)
+		if first, ok := seen[string(key)]; ok {
+			skipped = append(skipped, fmt.Sprintf("%s %s %s: same request as %s", recording.ID, recording.Method, recording.Path, first))
+			continue
+		}
+		seen[string(key)] = recording.ID
+		stubs = append(stubs, stub)
+	}
+	return stubs, skipped
+}
+
+// stubPathSyntax finds the path segments a stub path would read as
+// parameters or wildcards.
+var stubPathSyntax = regexp.MustCompile(`(^|/)[:*]`)
+
+func (r *Recorder) stub(recording Recording) (Stub, error) {
+	switch {
+	case recording.Error != "":
+		return Stub{}, fmt.Errorf("upstream failed: %s", recording.Error)
+	case recording.Truncated:
+		return Stub{}, fmt.Errorf("body larger than %d bytes", maxRecordedBody)
+	case recording.ResponseBodyBase64:
+		return Stub{}, fmt.Errorf("binary response body")
+	}
+
+	stub := Stub{
+		Name:    recording.ID,
+		Request: Request{Method: recording.Method, Path: recording.Path},
+		Response: Response{
+			Status:  recording.Status,
+			Headers: recording.ResponseHeaders,
+		},
+	}
+	if stubPathSyntax.MatchString(recording.Path) {
+		stub.Request.Path = ""
+		stub.Request.PathRegex = "^" + regexp.QuoteMeta(recording.Path) + "$"
+	}
+
+	query, _ := url.ParseQuery(recording.Query)
+	for _, name := range slices.Sorted(maps.Keys(query)) {
+		stub.Request.Query = append(stub.Request.Query, match.FieldMatcher{Field: name, Equals: query.Get(name)})
+	}
+	for _, name := range r.config.MatchHeaders {
+		if value, ok := recording.RequestHeaders[http.CanonicalHeaderKey(name)]; ok {
+			stub.Request.Headers = append(stub.Request.Headers, match.FieldMatcher{Field: name, Equals: value})
+		}
+	}
+	if !recording.RequestBodyBase64 {
+		stub.Request.Body = BodyMatchers(recording.RequestBody)
+	}
+
+	contentType := recording.ResponseHeaders["Content-Type"]
+	if strings.Contains(contentType, "json") && json.Valid([]byte(recording.ResponseBody)) {
+		stub.Response.JSONBody = json.RawMessage(recording.ResponseBody)
+	} else {
+		stub.Response.Body = recording.ResponseBody
+	}
+	return stub, nil
+}
+
+// BodyMatchers matches the scalar top-level fields of a JSON object body,
+// in field name order, the way the stubs generated from recordings do.
+func BodyMatchers(body string) []match.FieldMatcher {
+	var fields map[string]interface{}
+	if json.Unmarshal([]byte(body), &fields) != nil {
+		return nil
+	}
+	var matchers []match.FieldMatcher
+	for _, name := range slices.Sorted(maps.Keys(fields)) {
+		switch value := fields[name].(type) {
+		case string, float64, bool:
+			matchers = append(matchers, match.FieldMatcher{Field: name, Equals: value})
+		}
+	}
+	return matchers
+}
+
+// replayedHeader drops the headers a stub sets on its own.
+func replayedHeader(header http.Header) http.Header {
+	out := http.Header{}
+	for name, values := range header {
+		switch {
+		case hopHeaders[name], name == "Content-Length", name == "Content-Encoding", name == "Date":
+			continue
+		}
+		out[name] = values
+	}
+	return out
+}
+
+func flattenHeader(header http.Header) map[string]string {
+	if len(header) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(header))
+	for name, values := range header {
+		out[name] = strings.Join(values, ",")
+	}
+	return out
+}
+
+// encodeBody returns a body as text, or base64 encoded when it is not valid
+// UTF-8.
+func encodeBody(body []byte) (string, bool) {
+	if utf8.Valid(body) {
+		return string(body), false
+	}
+	return base64.StdEncoding.EncodeToString(body), true
+}
@@ -0,0 +1,108 @@
+package stubs
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// maxMatchedBody caps how much of a request body is read for matching and
+// templating.
+const maxMatchedBody = 10 * 1024 * 1024
+
+// requestData is the view of an incoming request used for matching and as
+// template data. Derived documents are built lazily since most stubs only
+// look at the method and path.
+type requestData struct {
+	Method     string
+	Path       string
+	Query      map[string]string
+	Headers    map[string]string
+	Body       string
+	PathParams map[string]string
+
+	request  *http.Request
+	bodyRead bool
+	json     interface{}
+	jsonDone bool
+	parts    []string
+	query    map[string]interface{}
+	headers  map[string]interface{}
+}
+
+func newRequestData(r *http.Request) *requestData {
+	query := make(map[string]string)
+	for key, values := range r.URL.Query() {
+		query[key] = values[0]
+	}
+	headers := make(map[string]string, len(r.Header))
+	for key, values := range r.Header {
+		headers[strings.ToLower(key)] = values[0]
+	}
+	return &requestData{
+		Method:  r.Method,
+		Path:    r.URL.Path,
+		Query:   query,
+		Headers: headers,
+		request: r,
+	}
+}
+
+// readBody loads the body once and puts it back for later handlers.
+func (d *requestData) readBody() {
+	if d.bodyRead || d.request.Body == nil {
+		d.bodyRead = true
+		return
+	}
+	d.bodyRead = true
+
+	data, err := io.ReadAll(io.LimitReader(d.request.Body, maxMatchedBody))
+	d.request.Body.Close()
+	if err != nil {
+		return
+	}
+	d.Body = string(data)
+	d.request.Body = io.NopCloser(bytes.NewReader(data))
+}
+
+// JSON returns the body parsed as JSON, or nil when it is not JSON.
+func (d *requestData) JSON() interface{} {
+	if !d.jsonDone {
+		d.jsonDone = true
+		d.readBody()
+		if d.Body != "" {
+			json.Unmarshal([]byte(d.Body), &d.json)
+		}
+	}
+	return d.json
+}
+
+// pathParts splits the path into segments once per request.
+func (d *requestData) pathParts() []string {
+	if d.parts == nil {
+		d.parts = strings.Split(strings.Trim(d.Path, "/"), "/")
+	}
+	return d.parts
+}
+
+func (d *requestData) queryDoc() map[string]interface{} {
+	if d.query == nil {
+		d.query = make(map[string]interface{}, len(d.Query))
+		for key, value := range d.Query {
+			d.query[key] = value
+		}
+	}
+	return d.query
+}
+
+func (d *requestData) headerDoc() map[string]interface{} {
+	if d.headers == nil {
+		d.headers = make(map[string]interface{}, len(d.Headers))
+		for key, value := range d.Headers {
+			d.headers[key] = value
+		}
+	}
+	return d.headers
+}
@@ -0,0 +1,57 @@
+package stubs
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ParseShorthand builds a stub from the one-line form used on the command
+// line:
+//
+//	[METHOD] PATH -> STATUS [BODY]
+//
+// such as "GET /users/1 -> 200 @users.json" or "POST /login -> 401". Without
+// a method the stub answers any. BODY is "@file" to serve a file (its
+// Content-Type guessed from the extension), inline JSON, or plain text.
+func ParseShorthand(spec string) (Stub, error) {
+	left, right, ok := strings.Cut(spec, "->")
+	if !ok {
+		return Stub{}, fmt.Errorf("stub %q: expected \"[METHOD] PATH -> STATUS [BODY]\"", spec)
+	}
+
+	stub := Stub{Name: strings.TrimSpace(spec)}
+	switch fields := strings.Fields(left); len(fields) {
+	case 1:
+		stub.Request.Path = fields[0]
+	case 2:
+		stub.Request.Method, stub.Request.Path = strings.ToUpper(fields[0]), fields[1]
+	default:
+		return Stub{}, fmt.Errorf("stub %q: expected \"[METHOD] PATH\" before ->", spec)
+	}
+
+	right = strings.TrimSpace(right)
+	status, body, _ := strings.Cut(right, " ")
+	code, err := strconv.Atoi(status)
+	if err != nil || code < 100 || code > 599 {
+		return Stub{}, fmt.Errorf("stub %q: invalid status %q", spec, status)
+	}
+	stub.Response.Status = code
+
+	switch body = strings.TrimSpace(body); {
+	case body == "":
+	case strings.HasPrefix(body, "@"):
+		stub.Response.BodyFile = body[1:]
+		if contentType := mime.TypeByExtension(filepath.Ext(body)); contentType != "" {
+			stub.Response.Headers = map[string]string{"Content-Type": contentType}
+		}
+	case json.Valid([]byte(body)):
+		stub.Response.JSONBody = json.RawMessage(body)
+	default:
+		stub.Response.Body = body
+	}
+	return stub, nil
+}
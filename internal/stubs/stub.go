@@ -0,0 +1,418 @@
+// Package stubs implements configurable HTTP stubs: request patterns, canned
+// or templated responses and the admin-managed store that serves them.
+package stubs
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"mockserver/internal/jose"
+	"mockserver/internal/locale"
+	"mockserver/internal/match"
+	"mockserver/internal/timefmt"
+)
+
+// Stub maps a request pattern to a response. Stubs with a higher Priority are
+// evaluated first; ties keep the order in which stubs were added.
+type Stub struct {
+	ID       string      `json:"id,omitempty"`
+	Name     string      `json:"name,omitempty"`
+	Priority int         `json:"priority,omitempty"`
+	Request  Request     `json:"request"`
+	Response Response    `json:"response"`
+	Fail     *FailConfig `json:"fail,omitempty"`
+	Lock     *LockConfig `json:"lock,omitempty"`
+	// Callbacks are sent whenever the stub serves its response.
+	Callbacks []Callback `json:"callbacks,omitempty"`
+	// Cost is accumulated into the cost report of its route.
+	Cost *CostConfig `json:"cost,omitempty"`
+}
+
+// Request describes the requests a stub answers. Path may contain ":name"
+// segments (captured as path parameters) and a trailing "*"; PathRegex is an
+// alternative full-path regular expression. Query and header matchers address
+// parameters by name; body matchers address fields of a JSON body.
+type Request struct {
+	Method    string               `json:"method,omitempty"`
+	Path      string               `json:"path,omitempty"`
+	PathRegex string               `json:"path_regex,omitempty"`
+	Query     []match.FieldMatcher `json:"query,omitempty"`
+	Headers   []match.FieldMatcher `json:"headers,omitempty"`
+	Body      []match.FieldMatcher `json:"body,omitempty"`
+}
+
+// Response is what a matched stub sends. Exactly one of Body, JSONBody and
+// BodyFile is normally set. With Template, the body and header values are Go
+// templates rendered per request. PostProcess steps then transform the
+// rendered response in order.
+//
+// Locales are alternative bodies keyed by language tag, chosen from the
+// Accept-Language header; when no preference matches, the locales of
+// LocaleFallback are tried in order before falling back to the body above.
+//
+// JitterSeed seeds the jitter template helpers of the stub, so that the
+// values they produce repeat from one run to the next.
+type Response struct {
+	Status         int                      `json:"status,omitempty"`
+	Headers        map[string]string        `json:"headers,omitempty"`
+	Body           string                   `json:"body,omitempty"`
+	JSONBody       json.RawMessage          `json:"json_body,omitempty"`
+	BodyFile       string                   `json:"body_file,omitempty"`
+	Locales        map[string]LocalizedBody `json:"locales,omitempty"`
+	LocaleFallback []string                 `json:"locale_fallback,omitempty"`
+	Template       bool                     `json:"template,omitempty"`
+	JitterSeed     *uint64                  `json:"jitter_seed,omitempty"`
+	Delay          string                   `json:"delay,omitempty"`
+	Cache          *CacheConfig             `json:"cache,omitempty"`
+	PostProcess    []PostProcessor          `json:"post_process,omitempty"`
+}
+
+// LocalizedBody is the body of a response in one locale, set like the body
+// of the response itself.
+type LocalizedBody struct {
+	Body     string          `json:"body,omitempty"`
+	JSONBody json.RawMessage `json:"json_body,omitempty"`
+	BodyFile string          `json:"body_file,omitempty"`
+}
+
+// CacheConfig memoizes rendered responses per method, path and query string
+// (plus the listed request headers) for TTL.
+type CacheConfig struct {
+	TTL         string   `json:"ttl"`
+	VaryHeaders []string `json:"vary_headers,omitempty"`
+}
+
+type compiledStub struct {
+	Stub
+	seq         int
+	method      string
+	segments    []string
+	wildcard    bool
+	pathRegex   *regexp.Regexp
+	body        compiledBody
+	locales     map[string]compiledBody
+	localeTags  []string
+	headerTmpls map[string]*template.Template
+	delay       time.Duration
+	cacheTTL    time.Duration
+	postProcess []postProcessFunc
+	timestamps  timefmt.Formatter
+	jitter      *jitterSource
+	lockKey     *template.Template
+	lockHold    time.Duration
+	callbacks   []compiledCallback
+}
+
+func compile(stub Stub, keys *jose.KeySet, dev bool) (*compiledStub, error) {
+	c := &compiledStub{Stub: stub, method: strings.ToUpper(stub.Request.Method)}
+	if c.method == "ANY" {
+		c.method = ""
+	}
+
+	req := stub.Request
+	if req.Path != "" && req.PathRegex != "" {
+		return nil, fmt.Errorf("path and path_regex are mutually exclusive")
+	}
+	if req.Path != "" {
+		if !strings.HasPrefix(req.Path, "/") {
+			return nil, fmt.Errorf("path %q must start with /", req.Path)
+		}
+		c.segments = strings.Split(strings.Trim(req.Path, "/"), "/")
+		if last := len(c.segments) - 1; c.segments[last] == "*" {
+			c.wildcard = true
+			c.segments = c.segments[:last]
+		}
+	}
+	if req.PathRegex != "" {
+		re, err := regexp.Compile(req.PathRegex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid path_regex: %w", err)
+		}
+		c.pathRegex = re
+	}
+
+	// Matchers are copied so compiling them never touches the caller's stub.
+	c.Request.Query = append([]match.FieldMatcher(nil), req.Query...)
+	c.Request.Headers = append([]match.FieldMatcher(nil), req.Headers...)
+	c.Request.Body = append([]match.FieldMatcher(nil), req.Body...)
+	for i := range c.Request.Headers {
+		c.Request.Headers[i].Field = strings.ToLower(c.Request.Headers[i].Field)
+	}
+	for _, matchers := range [][]match.FieldMatcher{c.Request.Query, c.Request.Headers, c.Request.Body} {
+		for i := range matchers {
+			if err := matchers[i].Compile(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	res := stub.Response
+	if res.Status == 0 {
+		c.Response.Status = http.StatusOK
+	}
+	if c.Response.Status < 100 || c.Response.Status > 599 {
+		return nil, fmt.Errorf("invalid status %d", c.Response.Status)
+	}
+
+	c.jitter = newJitterSource(res.JitterSeed)
+	body, err := compileBody(LocalizedBody{Body: res.Body, JSONBody: res.JSONBody, BodyFile: res.BodyFile}, res.Template, keys, c.jitter, dev)
+	if err != nil {
+		return nil, err
+	}
+	c.body = body
+	if err := c.compileLocales(keys, dev); err != nil {
+		return nil, err
+	}
+
+	if res.Template {
+		c.headerTmpls = make(map[string]*template.Template, len(res.Headers))
+		for name, value := range res.Headers {
+			tmpl, err := newTemplate(name, keys, c.jitter).Parse(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid template for header %s: %w", name, err)
+			}
+			c.headerTmpls[name] = tmpl
+		}
+	}
+
+	postProcess, err := compilePostProcessors(res.PostProcess, keys)
+	if err != nil {
+		return nil, err
+	}
+	c.postProcess = postProcess
+
+	if stub.Fail != nil {
+		fail := *stub.Fail
+		if fail.Times < 0 {
+			return nil, fmt.Errorf("fail.times must not be negative")
+		}
+		if fail.Status == 0 {
+			fail.Status = http.StatusServiceUnavailable
+		}
+		if fail.Status < 100 || fail.Status > 599 {
+			return nil, fmt.Errorf("invalid fail.status %d", fail.Status)
+		}
+		if fail.Key == "" {
+			fail.Key = DefaultFailKey
+		}
+		c.Fail = &fail
+	}
+
+	if c.callbacks, err = compileCallbacks(stub.Callbacks, keys, c.jitter); err != nil {
+		return nil, err
+	}
+
+	if stub.Lock != nil {
+		if err := c.compileLock(keys); err != nil {
+			return nil, err
+		}
+	}
+
+	if stub.Cost != nil {
+		if err := c.compileCost(); err != nil {
+			return nil, err
+		}
+	}
+
+	if res.Delay != "" {
+		delay, err := time.ParseDuration(res.Delay)
+		if err != nil || delay < 0 {
+			return nil, fmt.Errorf("invalid delay %q", res.Delay)
+		}
+		c.delay = delay
+	}
+	if res.Cache != nil {
+		ttl, err := time.ParseDuration(res.Cache.TTL)
+		if err != nil || ttl <= 0 {
+			return nil, fmt.Errorf("invalid cache ttl %q", res.Cache.TTL)
+		}
+		c.cacheTTL = ttl
+	}
+	return c, nil
+}
+
+// compileLock validates the lock of a stub. The lock name defaults to the
+// stub ID, which is only known once the stub is registered.
+func (c *compiledStub) compileLock(keys *jose.KeySet) error {
+	lock := *c.Lock
+	hold, err := time.ParseDuration(lock.Duration)
+	if err != nil || hold <= 0 {
+		return fmt.Errorf("invalid lock.duration %q", lock.Duration)
+	}
+	if lock.Status == 0 {
+		lock.Status = http.StatusLocked
+	}
+	if lock.Status < 400 || lock.Status > 599 {
+		return fmt.Errorf("invalid lock.status %d", lock.Status)
+	}
+	if lock.Key != "" {
+		tmpl, err := newTemplate("lock", keys, c.jitter).Parse(lock.Key)
+		if err != nil {
+			return fmt.Errorf("invalid lock.key template: %w", err)
+		}
+		c.lockKey = tmpl
+	}
+	c.lockHold = hold
+	c.Lock = &lock
+	return nil
+}
+
+// compiledBody is a response body, with its template when the response is
+// templated.
+type compiledBody struct {
+	data        []byte
+	contentType string
+	tmpl        *template.Template
+	// watch follows the body_file in dev mode.
+	watch *watchedFile
+}
+
+// compileBody loads a body. In dev mode a body_file is watched instead, and
+// problems with it are reported when the stub is requested.
+func compileBody(body LocalizedBody, templated bool, keys *jose.KeySet, jitter *jitterSource, dev bool) (compiledBody, error) {
+	var c compiledBody
+	if dev && len(body.JSONBody) == 0 && body.BodyFile != "" {
+		w := watchFile(body.BodyFile, templated, keys, jitter)
+		c = w.body
+		c.watch = w
+		return c, nil
+	}
+	switch {
+	case len(body.JSONBody) > 0:
+		c.data = body.JSONBody
+		c.contentType = "application/json"
+	case body.BodyFile != "":
+		data, err := os.ReadFile(body.BodyFile)
+		if err != nil {
+			return c, fmt.Errorf("read body_file: %w", err)
+		}
+		c.data = data
+		c.contentType = http.DetectContentType(data)
+	default:
+		c.data = []byte(body.Body)
+		c.contentType = "text/plain; charset=utf-8"
+	}
+
+	if templated {
+		tmpl, err := newTemplate("body", keys, jitter).Parse(string(c.data))
+		if err != nil {
+			return c, fmt.Errorf("invalid body template: %w", err)
+		}
+		c.tmpl = tmpl
+	}
+	return c, nil
+}
+
+// compileLocales compiles the localized bodies. The locales are negotiated
+// in the order of the fallback chain, then alphabetically, so that "*"
+// picks the first fallback.
+func (c *compiledStub) compileLocales(keys *jose.KeySet, dev bool) error {
+	res := c.Response
+	if len(res.Locales) == 0 {
+		if len(res.LocaleFallback) > 0 {
+			return fmt.Errorf("locale_fallback requires locales")
+		}
+		return nil
+	}
+	c.locales = make(map[string]compiledBody, len(res.Locales))
+	tags := make([]string, 0, len(res.Locales))
+	for tag, localized := range res.Locales {
+		if tag == "" || tag == "*" {
+			return fmt.Errorf("invalid locale %q", tag)
+		}
+		body, err := compileBody(localized, res.Template, keys, c.jitter, dev)
+		if err != nil {
+			return fmt.Errorf("locale %s: %w", tag, err)
+		}
+		c.locales[tag] = body
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	for _, tag := range res.LocaleFallback {
+		if _, ok := res.Locales[tag]; !ok {
+			return fmt.Errorf("locale_fallback: unknown locale %q", tag)
+		}
+		if !slices.Contains(c.localeTags, tag) {
+			c.localeTags = append(c.localeTags, tag)
+		}
+	}
+	for _, tag := range tags {
+		if !slices.Contains(c.localeTags, tag) {
+			c.localeTags = append(c.localeTags, tag)
+		}
+	}
+	return nil
+}
+
+// selectBody negotiates the body for a request, returning its locale ("" for
+// the default body).
+func (c *compiledStub) selectBody(r *http.Request) (compiledBody, string) {
+	if c.locales == nil {
+		return c.body, ""
+	}
+	match := locale.Negotiate(r.Header.Get("Accept-Language"), c.localeTags, c.Response.LocaleFallback)
+	if match.Locale == "" {
+		return c.body, ""
+	}
+	return c.locales[match.Locale], match.Locale
+}
+
+// matchPath checks the request path and returns the captured path parameters.
+func (c *compiledStub) matchPath(req *requestData) (map[string]string, bool) {
+	if c.pathRegex != nil {
+		return nil, c.pathRegex.MatchString(req.Path)
+	}
+	if c.segments == nil {
+		return nil, true
+	}
+
+	parts := req.pathParts()
+	if len(parts) < len(c.segments) || (!c.wildcard && len(parts) != len(c.segments)) {
+		return nil, false
+	}
+
+	var params map[string]string
+	for i, segment := range c.segments {
+		if strings.HasPrefix(segment, ":") {
+			if params == nil {
+				params = make(map[string]string)
+			}
+			params[segment[1:]] = parts[i]
+			continue
+		}
+		if segment != parts[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// matches evaluates the whole request pattern.
+func (c *compiledStub) matches(req *requestData) (map[string]string, bool) {
+	if c.method != "" && c.method != req.Method {
+		return nil, false
+	}
+	params, ok := c.matchPath(req)
+	if !ok {
+		return nil, false
+	}
+	if len(c.Request.Query) > 0 && !match.All(c.Request.Query, req.queryDoc()) {
+		return nil, false
+	}
+	if len(c.Request.Headers) > 0 && !match.All(c.Request.Headers, req.headerDoc()) {
+		return nil, false
+	}
+	if len(c.Request.Body) > 0 && !match.All(c.Request.Body, req.JSON()) {
+		return nil, false
+	}
+	return params, true
+}
@@ -0,0 +1,140 @@
+package stubs
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	mathrand "math/rand/v2"
+	"strings"
+	"text/template"
+	"time"
+
+	"mockserver/internal/jose"
+	"mockserver/internal/timefmt"
+)
+
+// templateData is the root object of response templates:
+//
+//	{{.Request.Method}} {{.Request.Path}} {{.Request.Query.page}}
+//	{{.Request.Headers.authorization}} {{.Request.PathParams.id}}
+//	{{.Request.Body}} {{(.Request.JSON).user.name}}
+//
+// Locale is the negotiated locale of a localized response, or "", and
+// {{.Timestamp}} the current time in the configured timestamp format.
+type templateData struct {
+	Request    *requestData
+	Locale     string
+	timestamps timefmt.Formatter
+}
+
+func (d templateData) Timestamp() interface{} {
+	return d.timestamps.Value(time.Now())
+}
+
+var templateFuncs = template.FuncMap{
+	"now":       templateNow,
+	"nowIn":     templateNowIn,
+	"uuid":      newUUID,
+	"randomInt": randomInt,
+	"json":      toJSON,
+	"default":   defaultValue,
+	"upper":     strings.ToUpper,
+	"lower":     strings.ToLower,
+	"trim":      strings.TrimSpace,
+}
+
+func init() {
+	for name, fn := range fakerFuncs {
+		templateFuncs[name] = fn
+	}
+}
+
+func newTemplate(name string, keys *jose.KeySet, jitter *jitterSource) *template.Template {
+	return template.New(name).Funcs(templateFuncs).Funcs(joseFuncs(keys)).Funcs(jitterFuncs(jitter)).Option("missingkey=zero")
+}
+
+// joseFuncs binds the jws and jwe helpers to the engine keys:
+//
+//	{{jws "mock-rs256" .Request.JSON}} {{jwe "mock-rs256" "secret"}}
+//
+// String payloads are used as is, anything else is encoded as JSON.
+func joseFuncs(keys *jose.KeySet) template.FuncMap {
+	return template.FuncMap{
+		"jws": func(kid string, payload interface{}) (string, error) {
+			data, err := josePayload(keys, payload)
+			if err != nil {
+				return "", err
+			}
+			return keys.Sign(kid, data)
+		},
+		"jwe": func(kid string, payload interface{}) (string, error) {
+			data, err := josePayload(keys, payload)
+			if err != nil {
+				return "", err
+			}
+			return keys.Encrypt(kid, data, "")
+		},
+	}
+}
+
+func josePayload(keys *jose.KeySet, payload interface{}) ([]byte, error) {
+	if keys == nil {
+		return nil, errNoKeys
+	}
+	if s, ok := payload.(string); ok {
+		return []byte(s), nil
+	}
+	return json.Marshal(payload)
+}
+
+// templateNow formats the current local time; the format is a timefmt
+// format ("unix_ms", "rfc1123", "us") or a layout, RFC3339 by default.
+func templateNow(format ...string) string {
+	return nowFormatter(format).In(time.Local).String(time.Now())
+}
+
+// templateNowIn formats the current time in a zone:
+//
+//	{{nowIn "Asia/Kolkata"}} {{nowIn "-08:00" "rfc1123z"}}
+func templateNowIn(zone string, format ...string) (string, error) {
+	loc, err := timefmt.LoadZone(zone)
+	if err != nil {
+		return "", err
+	}
+	return nowFormatter(format).In(loc).String(time.Now()), nil
+}
+
+func nowFormatter(format []string) timefmt.Formatter {
+	if len(format) == 0 {
+		return timefmt.Formatter{}.WithFormat("rfc3339")
+	}
+	return timefmt.Formatter{}.WithFormat(format[0])
+}
+
+func newUUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// randomInt returns a number in [min, max].
+func randomInt(min, max int) int {
+	if max <= min {
+		return min
+	}
+	return min + mathrand.IntN(max-min+1)
+}
+
+func toJSON(value interface{}) (string, error) {
+	data, err := json.Marshal(value)
+	return string(data), err
+}
+
+func defaultValue(fallback, value interface{}) interface{} {
+	if value == nil || value == "" {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,80 @@
+package stubs
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"mockserver/internal/jose"
+)
+
+// watchedFile is a body_file followed in dev mode: the file is checked on
+// every request and recompiled when it changed, so edits show up without
+// reloading the stubs. A file that cannot be read or whose template does
+// not compile is kept as an error and reported in the response.
+type watchedFile struct {
+	path      string
+	templated bool
+	keys      *jose.KeySet
+	jitter    *jitterSource
+
+	mutex   sync.Mutex
+	modTime time.Time
+	size    int64
+	body    compiledBody
+	err     error
+}
+
+func watchFile(path string, templated bool, keys *jose.KeySet, jitter *jitterSource) *watchedFile {
+	w := &watchedFile{path: path, templated: templated, keys: keys, jitter: jitter}
+	w.current()
+	return w
+}
+
+// current returns the body of the file as it is now, and whether it was
+// reloaded.
+func (w *watchedFile) current() (compiledBody, bool, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	info, err := os.Stat(w.path)
+	if err != nil {
+		changed := w.size >= 0
+		w.err, w.modTime, w.size = fmt.Errorf("read body_file: %w", err), time.Time{}, -1
+		if changed {
+			logger.Warn("Fixture unreadable", "path", w.path, "error", w.err)
+		}
+		return w.body, changed, w.err
+	}
+	if info.ModTime().Equal(w.modTime) && info.Size() == w.size {
+		return w.body, false, w.err
+	}
+
+	w.modTime, w.size = info.ModTime(), info.Size()
+	body, err := compileBody(LocalizedBody{BodyFile: w.path}, w.templated, w.keys, w.jitter, false)
+	if err == nil {
+		body.watch = w
+		w.body = body
+	}
+	if w.err != nil && err == nil {
+		logger.Info("Fixture fixed", "path", w.path)
+	} else if err != nil {
+		logger.Warn("Fixture invalid", "path", w.path, "error", err)
+	}
+	w.err = err
+	return w.body, true, err
+}
+
+func fixtureError(c echo.Context, stub *compiledStub, path string, err error) error {
+	return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+		"error":     "Stub fixture failed",
+		"stub":      stub.ID,
+		"file":      path,
+		"details":   err.Error(),
+		"timestamp": time.Now().Unix(),
+	})
+}
@@ -0,0 +1,156 @@
+// Package tcp is a raw TCP listener running a script on every connection:
+// expect bytes (literal, hex or a regular expression), send bytes, wait,
+// close. It stands in for proprietary line and binary protocols that the
+// HTTP, gRPC and WebSocket mocks do not speak.
+package tcp
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// Defaults of the configuration.
+const (
+	DefaultReadTimeout = 30 * time.Second
+	DefaultMaxSessions = 100
+)
+
+// maxExpectBuffer bounds the input buffered while waiting for an expected
+// regular expression.
+const maxExpectBuffer = 64 << 10
+
+// Config holds the TCP settings from the configuration file.
+type Config struct {
+	// Steps is the script run on every connection. When it ends, the
+	// connection stays open until the client closes it, unless the last
+	// step closes it or Repeat starts the script over.
+	Steps []Step `json:"steps"`
+	// Repeat runs the script again after its last step, for protocols
+	// answering one request after another on a connection.
+	Repeat bool `json:"repeat,omitempty"`
+	// ReadTimeout bounds the wait for expected input (default 30s).
+	ReadTimeout string `json:"read_timeout,omitempty"`
+	// Mismatch is sent before closing the connection when the input does
+	// not match what a step expects.
+	Mismatch string `json:"mismatch,omitempty"`
+	// MaxSessions is how many finished connections the admin API keeps
+	// (default 100).
+	MaxSessions int `json:"max_sessions,omitempty"`
+}
+
+// Step does exactly one thing. Expect waits for literal bytes at the start
+// of the input, ExpectHex for hex-encoded bytes and ExpectRegex for a
+// regular expression matching at the start of the input (end it with a
+// delimiter such as \n, or it may match a partial read); the matched bytes
+// are consumed. Send and SendHex write bytes; Send expands ${1} or ${name}
+// to the groups of the last ExpectRegex. Wait pauses (a Go duration) and
+// Close ends the connection.
+type Step struct {
+	Expect      string `json:"expect,omitempty"`
+	ExpectHex   string `json:"expect_hex,omitempty"`
+	ExpectRegex string `json:"expect_regex,omitempty"`
+	Send        string `json:"send,omitempty"`
+	SendHex     string `json:"send_hex,omitempty"`
+	Wait        string `json:"wait,omitempty"`
+	Close       bool   `json:"close,omitempty"`
+}
+
+// step is a compiled Step.
+type step struct {
+	expect   []byte
+	pattern  *regexp.Regexp
+	send     []byte
+	template bool
+	wait     time.Duration
+	close    bool
+}
+
+// script is a compiled configuration.
+type script struct {
+	steps       []step
+	repeat      bool
+	readTimeout time.Duration
+	mismatch    []byte
+}
+
+func (c Config) compile() (*script, error) {
+	if len(c.Steps) == 0 {
+		return nil, errors.New("the script has no steps")
+	}
+	s := &script{repeat: c.Repeat, readTimeout: DefaultReadTimeout, mismatch: []byte(c.Mismatch)}
+	if c.ReadTimeout != "" {
+		d, err := time.ParseDuration(c.ReadTimeout)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid read_timeout %q", c.ReadTimeout)
+		}
+		s.readTimeout = d
+	}
+	if c.MaxSessions < 0 {
+		return nil, errors.New("max_sessions must not be negative")
+	}
+	for i, st := range c.Steps {
+		compiled, err := st.compile()
+		if err != nil {
+			return nil, fmt.Errorf("step %d: %w", i+1, err)
+		}
+		s.steps = append(s.steps, compiled)
+	}
+	return s, nil
+}
+
+func (s Step) compile() (step, error) {
+	var compiled step
+	actions := 0
+	if s.Expect != "" {
+		actions++
+		compiled.expect = []byte(s.Expect)
+	}
+	if s.ExpectHex != "" {
+		actions++
+		data, err := hex.DecodeString(s.ExpectHex)
+		if err != nil {
+			return compiled, fmt.Errorf("invalid expect_hex: %w", err)
+		}
+		compiled.expect = data
+	}
+	if s.ExpectRegex != "" {
+		actions++
+		pattern, err := regexp.Compile(`\A(?:` + s.ExpectRegex + `)`)
+		if err != nil {
+			return compiled, fmt.Errorf("invalid expect_regex: %w", err)
+		}
+		compiled.pattern = pattern
+	}
+	if s.Send != "" {
+		actions++
+		compiled.send = []byte(s.Send)
+		compiled.template = true
+	}
+	if s.SendHex != "" {
+		actions++
+		data, err := hex.DecodeString(s.SendHex)
+		if err != nil {
+			return compiled, fmt.Errorf("invalid send_hex: %w", err)
+		}
+		compiled.send = data
+	}
+	if s.Wait != "" {
+		actions++
+		d, err := time.ParseDuration(s.Wait)
+		if err != nil || d < 0 {
+			return compiled, fmt.Errorf("invalid wait %q", s.Wait)
+		}
+		compiled.wait = d
+	}
+	if s.Close {
+		actions++
+		compiled.close = true
+	}
+	if actions != 1 {
+		return compiled, errors.New("a step needs exactly one of expect, expect_hex, expect_regex, send, send_hex, wait and close")
+	}
+	return compiled, nil
+}
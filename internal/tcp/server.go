@@ -0,0 +1,364 @@
+package tcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"mockserver/internal/logging"
+	"mockserver/internal/panics"
+)
+
+var logger = logging.For("tcp")
+
+// Session outcomes.
+const (
+	OutcomeOpen = "open"
+	// OutcomeCompleted: the script ran to its end.
+	OutcomeCompleted = "completed"
+	// OutcomeClientClosed: the client closed the connection before the end
+	// of the script.
+	OutcomeClientClosed = "client_closed"
+	OutcomeMismatch     = "mismatch"
+	OutcomeTimeout      = "timeout"
+	OutcomeError        = "error"
+)
+
+// maxTranscript bounds the bytes kept per direction and session.
+const maxTranscript = 64 << 10
+
+// Session is a connection, running or finished, with what was exchanged
+// (the first 64 KiB each way, as text and hex).
+type Session struct {
+	ID        string     `json:"id"`
+	Peer      string     `json:"peer"`
+	StartedAt time.Time  `json:"started_at"`
+	EndedAt   *time.Time `json:"ended_at,omitempty"`
+	// Steps counts the steps run, over every run of a repeated script.
+	Steps       int    `json:"steps"`
+	Outcome     string `json:"outcome"`
+	Error       string `json:"error,omitempty"`
+	Received    string `json:"received"`
+	ReceivedHex string `json:"received_hex"`
+	Sent        string `json:"sent"`
+	SentHex     string `json:"sent_hex"`
+}
+
+// Server is the TCP listener with the history of its connections.
+type Server struct {
+	script      *script
+	maxSessions int
+
+	nextID int
+	// sessions are in the order the connections were accepted.
+	sessions []*session
+	mutex    sync.Mutex
+	panics   *panics.Recorder
+}
+
+// NewServer validates the configuration.
+func NewServer(cfg Config) (*Server, error) {
+	compiled, err := cfg.compile()
+	if err != nil {
+		return nil, err
+	}
+	maxSessions := cfg.MaxSessions
+	if maxSessions == 0 {
+		maxSessions = DefaultMaxSessions
+	}
+	return &Server{script: compiled, maxSessions: maxSessions}, nil
+}
+
+// SetPanics keeps the panics contained on the connections in recorder; it
+// is called before Serve.
+func (s *Server) SetPanics(recorder *panics.Recorder) {
+	s.panics = recorder
+}
+
+// Serve accepts connections until the listener is closed.
+func (s *Server) Serve(lis net.Listener) error {
+	for {
+		conn, err := lis.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go s.handleConn(conn)
+	}
+}
+
+// session is the state of a connection; the server's mutex guards the
+// fields shared with the admin API.
+type session struct {
+	Session
+	received, sent []byte
+}
+
+func (s *Server) open(conn net.Conn) *session {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.nextID++
+	sess := &session{Session: Session{
+		ID:        fmt.Sprintf("conn-%d", s.nextID),
+		Peer:      conn.RemoteAddr().String(),
+		StartedAt: time.Now(),
+		Outcome:   OutcomeOpen,
+	}}
+	s.sessions = append(s.sessions, sess)
+	s.trimLocked()
+	return sess
+}
+
+// trimLocked drops the oldest finished sessions past the limit.
+func (s *Server) trimLocked() {
+	excess := len(s.sessions) - s.maxSessions
+	if excess <= 0 {
+		return
+	}
+	kept := s.sessions[:0:0]
+	for _, sess := range s.sessions {
+		if excess > 0 && sess.Outcome != OutcomeOpen {
+			excess--
+			continue
+		}
+		kept = append(kept, sess)
+	}
+	s.sessions = kept
+}
+
+func (s *Server) record(sess *session, received, sent []byte) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	sess.received = appendCapped(sess.received, received)
+	sess.sent = appendCapped(sess.sent, sent)
+}
+
+func appendCapped(transcript, data []byte) []byte {
+	if room := maxTranscript - len(transcript); len(data) > room {
+		data = data[:room]
+	}
+	return append(transcript, data...)
+}
+
+func (s *Server) finish(sess *session, steps int, outcome string, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	now := time.Now()
+	sess.EndedAt, sess.Steps, sess.Outcome = &now, steps, outcome
+	if err != nil {
+		sess.Error = err.Error()
+	}
+	s.trimLocked()
+	if err != nil {
+		logger.Debug("Connection ended", "connection_id", sess.ID, "peer", sess.Peer, "outcome", outcome, "steps", steps, "error", err)
+	} else {
+		logger.Debug("Connection ended", "connection_id", sess.ID, "peer", sess.Peer, "outcome", outcome, "steps", steps)
+	}
+}
+
+// errMismatch reports input that does not match a step.
+var errMismatch = errors.New("unexpected input")
+
+func (s *Server) handleConn(conn net.Conn) {
+	defer conn.Close()
+	sess := s.open(conn)
+	logger.Debug("Connection accepted", "connection_id", sess.ID, "peer", sess.Peer)
+	run := &runner{server: s, sess: sess, conn: conn}
+	defer func() {
+		if value := recover(); value != nil {
+			p := s.panics.Record(context.Background(), panics.ProtocolTCP, sess.Peer, value)
+			s.finish(sess, run.steps, OutcomeError, fmt.Errorf("panic: %s", p.Value))
+		}
+	}()
+	outcome, err := run.script()
+	s.finish(sess, run.steps, outcome, err)
+}
+
+// runner runs the script on a connection.
+type runner struct {
+	server *Server
+	sess   *session
+	conn   net.Conn
+	steps  int
+	// pending is the input received and not consumed yet.
+	pending []byte
+	// match and groups are the input and submatch indices of the last
+	// expect_regex step.
+	match  []byte
+	groups []int
+	last   *step
+}
+
+func (r *runner) script() (string, error) {
+	sc := r.server.script
+	for iteration := 0; ; iteration++ {
+		for i := range sc.steps {
+			st := &sc.steps[i]
+			if st.close {
+				r.steps++
+				return OutcomeCompleted, nil
+			}
+			if err := r.step(st); err != nil {
+				switch {
+				// A repeated script may end between two runs
+				case errors.Is(err, io.EOF) && iteration > 0 && i == 0 && len(r.pending) == 0:
+					return OutcomeCompleted, nil
+				case errors.Is(err, io.EOF):
+					return OutcomeClientClosed, nil
+				case errors.Is(err, errMismatch):
+					r.send(sc.mismatch)
+					return OutcomeMismatch, fmt.Errorf("step %d: %w", i+1, err)
+				case errors.Is(err, os.ErrDeadlineExceeded):
+					r.send(sc.mismatch)
+					return OutcomeTimeout, fmt.Errorf("step %d: no matching input within %v", i+1, sc.readTimeout)
+				default:
+					return OutcomeError, fmt.Errorf("step %d: %w", i+1, err)
+				}
+			}
+			r.steps++
+		}
+		if !sc.repeat {
+			break
+		}
+	}
+
+	// The script is over: hold the connection until the client closes it
+	r.conn.SetReadDeadline(time.Time{})
+	buf := make([]byte, 4096)
+	for {
+		n, err := r.conn.Read(buf)
+		r.server.record(r.sess, buf[:n], nil)
+		if err != nil {
+			return OutcomeCompleted, nil
+		}
+	}
+}
+
+func (r *runner) step(st *step) error {
+	switch {
+	case st.pattern != nil:
+		return r.expectRegex(st)
+	case st.expect != nil:
+		return r.expect(st.expect)
+	case st.send != nil:
+		data := st.send
+		if st.template && r.last != nil {
+			data = r.last.pattern.Expand(nil, st.send, r.match, r.groups)
+		}
+		return r.send(data)
+	default:
+		time.Sleep(st.wait)
+		return nil
+	}
+}
+
+// expect consumes want from the start of the input.
+func (r *runner) expect(want []byte) error {
+	for len(r.pending) < len(want) {
+		if !bytes.HasPrefix(want, r.pending) {
+			return fmt.Errorf("%w: expected %q, got %q", errMismatch, want, r.pending)
+		}
+		if err := r.fill(); err != nil {
+			return err
+		}
+	}
+	if !bytes.HasPrefix(r.pending, want) {
+		return fmt.Errorf("%w: expected %q, got %q", errMismatch, want, r.pending[:len(want)])
+	}
+	r.pending = r.pending[len(want):]
+	return nil
+}
+
+// expectRegex consumes the match of the step's pattern at the start of the
+// input.
+func (r *runner) expectRegex(st *step) error {
+	for {
+		if loc := st.pattern.FindSubmatchIndex(r.pending); loc != nil {
+			r.match, r.groups, r.last = append([]byte(nil), r.pending[:loc[1]]...), loc, st
+			r.pending = r.pending[loc[1]:]
+			return nil
+		}
+		if len(r.pending) >= maxExpectBuffer {
+			return fmt.Errorf("%w: %d bytes without a match of %s", errMismatch, len(r.pending), st.pattern)
+		}
+		if err := r.fill(); err != nil {
+			return err
+		}
+	}
+}
+
+// fill reads more input, within the read timeout.
+func (r *runner) fill() error {
+	r.conn.SetReadDeadline(time.Now().Add(r.server.script.readTimeout))
+	buf := make([]byte, 4096)
+	n, err := r.conn.Read(buf)
+	r.pending = append(r.pending, buf[:n]...)
+	r.server.record(r.sess, buf[:n], nil)
+	if n > 0 {
+		return nil
+	}
+	return err
+}
+
+func (r *runner) send(data []byte) error {
+	if len(data) == 0 {
+		return nil
+	}
+	if _, err := r.conn.Write(data); err != nil {
+		return err
+	}
+	r.server.record(r.sess, nil, data)
+	return nil
+}
+
+// Sessions returns the connections, oldest first.
+func (s *Server) Sessions() []Session {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	sessions := make([]Session, len(s.sessions))
+	for i, sess := range s.sessions {
+		sessions[i] = sess.snapshot()
+	}
+	return sessions
+}
+
+// Session returns a connection by ID.
+func (s *Server) Session(id string) (Session, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, sess := range s.sessions {
+		if sess.ID == id {
+			return sess.snapshot(), true
+		}
+	}
+	return Session{}, false
+}
+
+// Reset forgets the finished connections.
+func (s *Server) Reset() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	kept := s.sessions[:0:0]
+	for _, sess := range s.sessions {
+		if sess.Outcome == OutcomeOpen {
+			kept = append(kept, sess)
+		}
+	}
+	s.sessions = kept
+}
+
+func (sess *session) snapshot() Session {
+	snapshot := sess.Session
+	snapshot.Received, snapshot.ReceivedHex = string(sess.received), hex.EncodeToString(sess.received)
+	snapshot.Sent, snapshot.SentHex = string(sess.sent), hex.EncodeToString(sess.sent)
+	return snapshot
+}
@@ -0,0 +1,470 @@
+package telemetry
+
+import (
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"mockserver/internal/logging"
+)
+
+var logger = logging.For("telemetry")
+
+// maxOTLPBody bounds an export request once decompressed.
+const maxOTLPBody = 32 << 20
+
+// OTLPHandler receives OTLP/HTTP exports on /v1/logs, /v1/traces and
+// /v1/metrics, encoded as protobuf or JSON, and records every log record,
+// span and metric.
+type OTLPHandler struct {
+	store *Store
+}
+
+func NewOTLPHandler(store *Store) *OTLPHandler {
+	return &OTLPHandler{store: store}
+}
+
+func (h *OTLPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var signal string
+	switch r.URL.Path {
+	case "/v1/logs":
+		signal = SignalLog
+	case "/v1/traces":
+		signal = SignalTrace
+	case "/v1/metrics":
+		signal = SignalMetric
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var reader io.Reader = r.Body
+	if strings.EqualFold(r.Header.Get("Content-Encoding"), "gzip") {
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			http.Error(w, "invalid gzip body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		reader = gz
+	}
+	body, err := io.ReadAll(io.LimitReader(reader, maxOTLPBody+1))
+	if err != nil || len(body) > maxOTLPBody {
+		http.Error(w, "unreadable or oversized body", http.StatusBadRequest)
+		return
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	isJSON := mediaType == "application/json"
+	transport := "http/protobuf"
+	if isJSON {
+		transport = "http/json"
+	}
+
+	var entries []Entry
+	switch signal {
+	case SignalLog:
+		var req logsRequest
+		if err = decodeOTLP(body, isJSON, &req, req.unmarshalProto); err == nil {
+			entries = req.entries()
+		}
+	case SignalTrace:
+		var req tracesRequest
+		if err = decodeOTLP(body, isJSON, &req, req.unmarshalProto); err == nil {
+			entries = req.entries()
+		}
+	case SignalMetric:
+		var req metricsRequest
+		if err = decodeOTLP(body, isJSON, &req, req.unmarshalProto); err == nil {
+			entries = req.entries()
+		}
+	}
+	if err != nil {
+		logger.DebugContext(r.Context(), "Invalid OTLP export", "signal", signal, "peer", r.RemoteAddr, "error", err)
+		http.Error(w, "invalid export request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	for i := range entries {
+		entries[i].Transport = transport
+		entries[i].Peer = r.RemoteAddr
+	}
+	h.store.Record(entries...)
+	logger.DebugContext(r.Context(), "OTLP export received", "signal", signal, "entries", len(entries), "peer", r.RemoteAddr, "transport", transport)
+
+	// An empty Export*ServiceResponse means full success
+	if isJSON {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("{}"))
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	w.WriteHeader(http.StatusOK)
+}
+
+func decodeOTLP(body []byte, isJSON bool, v interface{}, unmarshalProto func([]byte) error) error {
+	if isJSON {
+		return json.Unmarshal(body, v)
+	}
+	return unmarshalProto(body)
+}
+
+// The types below mirror the OTLP messages, with the field names of their
+// JSON encoding; protobuf payloads are decoded into the same types.
+
+type resource struct {
+	Attributes []keyValue `json:"attributes"`
+}
+
+type scope struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type keyValue struct {
+	Key   string   `json:"key"`
+	Value anyValue `json:"value"`
+}
+
+type anyValue struct {
+	StringValue *string      `json:"stringValue,omitempty"`
+	BoolValue   *bool        `json:"boolValue,omitempty"`
+	IntValue    *int64Value  `json:"intValue,omitempty"`
+	DoubleValue *float64     `json:"doubleValue,omitempty"`
+	ArrayValue  *arrayValue  `json:"arrayValue,omitempty"`
+	KvlistValue *kvlistValue `json:"kvlistValue,omitempty"`
+	// BytesValue is base64 in JSON, like every protobuf bytes field.
+	BytesValue []byte `json:"bytesValue,omitempty"`
+}
+
+type arrayValue struct {
+	Values []anyValue `json:"values"`
+}
+
+type kvlistValue struct {
+	Values []keyValue `json:"values"`
+}
+
+// int64Value and uint64Value accept the quoted form JSON uses for 64-bit
+// integers as well as plain numbers.
+type int64Value int64
+
+func (v *int64Value) UnmarshalJSON(data []byte) error {
+	n, err := strconv.ParseInt(strings.Trim(string(data), `"`), 10, 64)
+	*v = int64Value(n)
+	return err
+}
+
+type uint64Value uint64
+
+func (v *uint64Value) UnmarshalJSON(data []byte) error {
+	n, err := strconv.ParseUint(strings.Trim(string(data), `"`), 10, 64)
+	*v = uint64Value(n)
+	return err
+}
+
+// value converts an AnyValue to the equivalent JSON value.
+func (v anyValue) value() interface{} {
+	switch {
+	case v.StringValue != nil:
+		return *v.StringValue
+	case v.BoolValue != nil:
+		return *v.BoolValue
+	case v.IntValue != nil:
+		return int64(*v.IntValue)
+	case v.DoubleValue != nil:
+		return *v.DoubleValue
+	case v.ArrayValue != nil:
+		values := make([]interface{}, len(v.ArrayValue.Values))
+		for i, item := range v.ArrayValue.Values {
+			values[i] = item.value()
+		}
+		return values
+	case v.KvlistValue != nil:
+		return attributes(v.KvlistValue.Values)
+	case v.BytesValue != nil:
+		return base64.StdEncoding.EncodeToString(v.BytesValue)
+	}
+	return nil
+}
+
+func attributes(kvs []keyValue) map[string]interface{} {
+	if len(kvs) == 0 {
+		return nil
+	}
+	attrs := make(map[string]interface{}, len(kvs))
+	for _, kv := range kvs {
+		attrs[kv.Key] = kv.Value.value()
+	}
+	return attrs
+}
+
+// base builds the fields shared by the entries of a resource and scope.
+func base(signal string, res resource, sc scope) Entry {
+	entry := Entry{Signal: signal, Protocol: ProtocolOTLP, Resource: attributes(res.Attributes), Scope: sc.Name}
+	if service, ok := entry.Resource["service.name"].(string); ok {
+		entry.Service = service
+	}
+	return entry
+}
+
+func unixNano(v uint64Value) *time.Time {
+	if v == 0 {
+		return nil
+	}
+	t := time.Unix(0, int64(v)).UTC()
+	return &t
+}
+
+// hexID reads trace and span IDs, which JSON carries as hex strings.
+type hexID []byte
+
+func (id *hexID) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	decoded, err := hex.DecodeString(s)
+	if err != nil {
+		return fmt.Errorf("invalid ID %q", s)
+	}
+	*id = decoded
+	return nil
+}
+
+func (id hexID) String() string {
+	return hex.EncodeToString(id)
+}
+
+type logsRequest struct {
+	ResourceLogs []resourceLogs `json:"resourceLogs"`
+}
+
+type resourceLogs struct {
+	Resource  resource    `json:"resource"`
+	ScopeLogs []scopeLogs `json:"scopeLogs"`
+}
+
+type scopeLogs struct {
+	Scope      scope       `json:"scope"`
+	LogRecords []logRecord `json:"logRecords"`
+}
+
+type logRecord struct {
+	TimeUnixNano         uint64Value `json:"timeUnixNano"`
+	ObservedTimeUnixNano uint64Value `json:"observedTimeUnixNano"`
+	SeverityNumber       int         `json:"severityNumber"`
+	SeverityText         string      `json:"severityText"`
+	Body                 anyValue    `json:"body"`
+	Attributes           []keyValue  `json:"attributes"`
+	TraceID              hexID       `json:"traceId"`
+	SpanID               hexID       `json:"spanId"`
+}
+
+// severityNumbers names the ranges of OTLP severity numbers.
+var severityNumbers = []string{"TRACE", "DEBUG", "INFO", "WARN", "ERROR", "FATAL"}
+
+func (r logsRequest) entries() []Entry {
+	var entries []Entry
+	for _, rl := range r.ResourceLogs {
+		for _, sl := range rl.ScopeLogs {
+			for _, record := range sl.LogRecords {
+				entry := base(SignalLog, rl.Resource, sl.Scope)
+				entry.Timestamp = unixNano(record.TimeUnixNano)
+				if entry.Timestamp == nil {
+					entry.Timestamp = unixNano(record.ObservedTimeUnixNano)
+				}
+				entry.Severity = record.SeverityText
+				if entry.Severity == "" && record.SeverityNumber >= 1 && record.SeverityNumber <= 24 {
+					entry.Severity = severityNumbers[(record.SeverityNumber-1)/4]
+				}
+				entry.Body = record.Body.value()
+				entry.Attributes = attributes(record.Attributes)
+				entry.TraceID = record.TraceID.String()
+				entry.SpanID = record.SpanID.String()
+				entries = append(entries, entry)
+			}
+		}
+	}
+	return entries
+}
+
+type tracesRequest struct {
+	ResourceSpans []resourceSpans `json:"resourceSpans"`
+}
+
+type resourceSpans struct {
+	Resource   resource     `json:"resource"`
+	ScopeSpans []scopeSpans `json:"scopeSpans"`
+}
+
+type scopeSpans struct {
+	Scope scope  `json:"scope"`
+	Spans []span `json:"spans"`
+}
+
+type span struct {
+	TraceID           hexID       `json:"traceId"`
+	SpanID            hexID       `json:"spanId"`
+	ParentSpanID      hexID       `json:"parentSpanId"`
+	Name              string      `json:"name"`
+	Kind              int         `json:"kind"`
+	StartTimeUnixNano uint64Value `json:"startTimeUnixNano"`
+	EndTimeUnixNano   uint64Value `json:"endTimeUnixNano"`
+	Attributes        []keyValue  `json:"attributes"`
+	Status            status      `json:"status"`
+}
+
+type status struct {
+	Message string `json:"message"`
+	Code    int    `json:"code"`
+}
+
+var (
+	spanKinds   = []string{"unspecified", "internal", "server", "client", "producer", "consumer"}
+	statusCodes = []string{"unset", "ok", "error"}
+)
+
+func enumName(names []string, value int) string {
+	if value >= 0 && value < len(names) {
+		return names[value]
+	}
+	return strconv.Itoa(value)
+}
+
+func (r tracesRequest) entries() []Entry {
+	var entries []Entry
+	for _, rs := range r.ResourceSpans {
+		for _, ss := range rs.ScopeSpans {
+			for _, s := range ss.Spans {
+				entry := base(SignalTrace, rs.Resource, ss.Scope)
+				entry.Attributes = attributes(s.Attributes)
+				entry.TraceID = s.TraceID.String()
+				entry.SpanID = s.SpanID.String()
+				info := &SpanInfo{
+					Name:          s.Name,
+					Kind:          enumName(spanKinds, s.Kind),
+					ParentSpanID:  s.ParentSpanID.String(),
+					StatusCode:    enumName(statusCodes, s.Status.Code),
+					StatusMessage: s.Status.Message,
+				}
+				if start := unixNano(s.StartTimeUnixNano); start != nil {
+					info.StartTime = *start
+					entry.Timestamp = start
+				}
+				if end := unixNano(s.EndTimeUnixNano); end != nil {
+					info.EndTime = *end
+				}
+				if s.EndTimeUnixNano > s.StartTimeUnixNano {
+					info.DurationMs = float64(s.EndTimeUnixNano-s.StartTimeUnixNano) / 1e6
+				}
+				entry.Span = info
+				entries = append(entries, entry)
+			}
+		}
+	}
+	return entries
+}
+
+type metricsRequest struct {
+	ResourceMetrics []resourceMetrics `json:"resourceMetrics"`
+}
+
+type resourceMetrics struct {
+	Resource     resource       `json:"resource"`
+	ScopeMetrics []scopeMetrics `json:"scopeMetrics"`
+}
+
+type scopeMetrics struct {
+	Scope   scope    `json:"scope"`
+	Metrics []metric `json:"metrics"`
+}
+
+type metric struct {
+	Name                 string      `json:"name"`
+	Description          string      `json:"description"`
+	Unit                 string      `json:"unit"`
+	Gauge                *metricData `json:"gauge"`
+	Sum                  *metricData `json:"sum"`
+	Histogram            *metricData `json:"histogram"`
+	ExponentialHistogram *metricData `json:"exponentialHistogram"`
+	Summary              *metricData `json:"summary"`
+}
+
+type metricData struct {
+	DataPoints []dataPoint `json:"dataPoints"`
+}
+
+// dataPoint merges the number, histogram and summary data points.
+type dataPoint struct {
+	Attributes   []keyValue   `json:"attributes"`
+	TimeUnixNano uint64Value  `json:"timeUnixNano"`
+	AsDouble     *float64     `json:"asDouble"`
+	AsInt        *int64Value  `json:"asInt"`
+	Count        *uint64Value `json:"count"`
+	Sum          *float64     `json:"sum"`
+}
+
+func (m metric) data() (string, *metricData) {
+	switch {
+	case m.Gauge != nil:
+		return "gauge", m.Gauge
+	case m.Sum != nil:
+		return "sum", m.Sum
+	case m.Histogram != nil:
+		return "histogram", m.Histogram
+	case m.ExponentialHistogram != nil:
+		return "exponential_histogram", m.ExponentialHistogram
+	case m.Summary != nil:
+		return "summary", m.Summary
+	}
+	return "empty", &metricData{}
+}
+
+func (r metricsRequest) entries() []Entry {
+	var entries []Entry
+	for _, rm := range r.ResourceMetrics {
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				entry := base(SignalMetric, rm.Resource, sm.Scope)
+				kind, data := m.data()
+				info := &MetricInfo{Name: m.Name, Description: m.Description, Unit: m.Unit, Type: kind, DataPoints: []DataPoint{}}
+				for _, dp := range data.DataPoints {
+					point := DataPoint{
+						Attributes: attributes(dp.Attributes),
+						Timestamp:  unixNano(dp.TimeUnixNano),
+						Value:      dp.AsDouble,
+						Sum:        dp.Sum,
+					}
+					if dp.AsInt != nil {
+						value := float64(*dp.AsInt)
+						point.Value = &value
+					}
+					if dp.Count != nil {
+						count := uint64(*dp.Count)
+						point.Count = &count
+					}
+					if point.Timestamp != nil && (entry.Timestamp == nil || point.Timestamp.After(*entry.Timestamp)) {
+						entry.Timestamp = point.Timestamp
+					}
+					info.DataPoints = append(info.DataPoints, point)
+				}
+				entry.Metric = info
+				entries = append(entries, entry)
+			}
+		}
+	}
+	return entries
+}
@@ -0,0 +1,362 @@
+package telemetry
+
+import (
+	"errors"
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// The OTLP protobuf messages are decoded by hand, field by field, into the
+// same types as their JSON encoding. Unknown fields are skipped.
+
+var errMalformed = errors.New("malformed protobuf")
+
+// field is a decoded protobuf field: bytes holds length-delimited values,
+// scalar the varint and fixed-width ones.
+type field struct {
+	num    protowire.Number
+	bytes  []byte
+	scalar uint64
+}
+
+// fields calls fn for every field of a message.
+func fields(b []byte, fn func(f field) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return errMalformed
+		}
+		b = b[n:]
+		f := field{num: num}
+		switch typ {
+		case protowire.VarintType:
+			f.scalar, n = protowire.ConsumeVarint(b)
+		case protowire.Fixed64Type:
+			f.scalar, n = protowire.ConsumeFixed64(b)
+		case protowire.Fixed32Type:
+			var v uint32
+			v, n = protowire.ConsumeFixed32(b)
+			f.scalar = uint64(v)
+		case protowire.BytesType:
+			f.bytes, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+			if n < 0 {
+				return errMalformed
+			}
+			b = b[n:]
+			continue
+		}
+		if n < 0 {
+			return errMalformed
+		}
+		b = b[n:]
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// message decodes a repeated or singular message field and appends it.
+func message[T any](list *[]T, b []byte, decode func(*T, []byte) error) error {
+	var item T
+	if err := decode(&item, b); err != nil {
+		return err
+	}
+	*list = append(*list, item)
+	return nil
+}
+
+func decodeResource(r *resource, b []byte) error {
+	return fields(b, func(f field) error {
+		if f.num == 1 {
+			return message(&r.Attributes, f.bytes, decodeKeyValue)
+		}
+		return nil
+	})
+}
+
+func decodeScope(s *scope, b []byte) error {
+	return fields(b, func(f field) error {
+		switch f.num {
+		case 1:
+			s.Name = string(f.bytes)
+		case 2:
+			s.Version = string(f.bytes)
+		}
+		return nil
+	})
+}
+
+func decodeKeyValue(kv *keyValue, b []byte) error {
+	return fields(b, func(f field) error {
+		switch f.num {
+		case 1:
+			kv.Key = string(f.bytes)
+		case 2:
+			return decodeAnyValue(&kv.Value, f.bytes)
+		}
+		return nil
+	})
+}
+
+func decodeAnyValue(v *anyValue, b []byte) error {
+	return fields(b, func(f field) error {
+		switch f.num {
+		case 1:
+			s := string(f.bytes)
+			v.StringValue = &s
+		case 2:
+			flag := f.scalar != 0
+			v.BoolValue = &flag
+		case 3:
+			n := int64Value(f.scalar)
+			v.IntValue = &n
+		case 4:
+			d := math.Float64frombits(f.scalar)
+			v.DoubleValue = &d
+		case 5:
+			v.ArrayValue = &arrayValue{}
+			return fields(f.bytes, func(item field) error {
+				if item.num == 1 {
+					return message(&v.ArrayValue.Values, item.bytes, decodeAnyValue)
+				}
+				return nil
+			})
+		case 6:
+			v.KvlistValue = &kvlistValue{}
+			return fields(f.bytes, func(item field) error {
+				if item.num == 1 {
+					return message(&v.KvlistValue.Values, item.bytes, decodeKeyValue)
+				}
+				return nil
+			})
+		case 7:
+			v.BytesValue = append([]byte{}, f.bytes...)
+		}
+		return nil
+	})
+}
+
+func (r *logsRequest) unmarshalProto(b []byte) error {
+	return fields(b, func(f field) error {
+		if f.num == 1 {
+			return message(&r.ResourceLogs, f.bytes, decodeResourceLogs)
+		}
+		return nil
+	})
+}
+
+func decodeResourceLogs(rl *resourceLogs, b []byte) error {
+	return fields(b, func(f field) error {
+		switch f.num {
+		case 1:
+			return decodeResource(&rl.Resource, f.bytes)
+		case 2:
+			return message(&rl.ScopeLogs, f.bytes, decodeScopeLogs)
+		}
+		return nil
+	})
+}
+
+func decodeScopeLogs(sl *scopeLogs, b []byte) error {
+	return fields(b, func(f field) error {
+		switch f.num {
+		case 1:
+			return decodeScope(&sl.Scope, f.bytes)
+		case 2:
+			return message(&sl.LogRecords, f.bytes, decodeLogRecord)
+		}
+		return nil
+	})
+}
+
+func decodeLogRecord(lr *logRecord, b []byte) error {
+	return fields(b, func(f field) error {
+		switch f.num {
+		case 1:
+			lr.TimeUnixNano = uint64Value(f.scalar)
+		case 2:
+			lr.SeverityNumber = int(f.scalar)
+		case 3:
+			lr.SeverityText = string(f.bytes)
+		case 5:
+			return decodeAnyValue(&lr.Body, f.bytes)
+		case 6:
+			return message(&lr.Attributes, f.bytes, decodeKeyValue)
+		case 9:
+			lr.TraceID = hexID(f.bytes)
+		case 10:
+			lr.SpanID = hexID(f.bytes)
+		case 11:
+			lr.ObservedTimeUnixNano = uint64Value(f.scalar)
+		}
+		return nil
+	})
+}
+
+func (r *tracesRequest) unmarshalProto(b []byte) error {
+	return fields(b, func(f field) error {
+		if f.num == 1 {
+			return message(&r.ResourceSpans, f.bytes, decodeResourceSpans)
+		}
+		return nil
+	})
+}
+
+func decodeResourceSpans(rs *resourceSpans, b []byte) error {
+	return fields(b, func(f field) error {
+		switch f.num {
+		case 1:
+			return decodeResource(&rs.Resource, f.bytes)
+		case 2:
+			return message(&rs.ScopeSpans, f.bytes, decodeScopeSpans)
+		}
+		return nil
+	})
+}
+
+func decodeScopeSpans(ss *scopeSpans, b []byte) error {
+	return fields(b, func(f field) error {
+		switch f.num {
+		case 1:
+			return decodeScope(&ss.Scope, f.bytes)
+		case 2:
+			return message(&ss.Spans, f.bytes, decodeSpan)
+		}
+		return nil
+	})
+}
+
+func decodeSpan(s *span, b []byte) error {
+	return fields(b, func(f field) error {
+		switch f.num {
+		case 1:
+			s.TraceID = hexID(f.bytes)
+		case 2:
+			s.SpanID = hexID(f.bytes)
+		case 4:
+			s.ParentSpanID = hexID(f.bytes)
+		case 5:
+			s.Name = string(f.bytes)
+		case 6:
+			s.Kind = int(f.scalar)
+		case 7:
+			s.StartTimeUnixNano = uint64Value(f.scalar)
+		case 8:
+			s.EndTimeUnixNano = uint64Value(f.scalar)
+		case 9:
+			return message(&s.Attributes, f.bytes, decodeKeyValue)
+		case 15:
+			return fields(f.bytes, func(sf field) error {
+				switch sf.num {
+				case 2:
+					s.Status.Message = string(sf.bytes)
+				case 3:
+					s.Status.Code = int(sf.scalar)
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+}
+
+func (r *metricsRequest) unmarshalProto(b []byte) error {
+	return fields(b, func(f field) error {
+		if f.num == 1 {
+			return message(&r.ResourceMetrics, f.bytes, decodeResourceMetrics)
+		}
+		return nil
+	})
+}
+
+func decodeResourceMetrics(rm *resourceMetrics, b []byte) error {
+	return fields(b, func(f field) error {
+		switch f.num {
+		case 1:
+			return decodeResource(&rm.Resource, f.bytes)
+		case 2:
+			return message(&rm.ScopeMetrics, f.bytes, decodeScopeMetrics)
+		}
+		return nil
+	})
+}
+
+func decodeScopeMetrics(sm *scopeMetrics, b []byte) error {
+	return fields(b, func(f field) error {
+		switch f.num {
+		case 1:
+			return decodeScope(&sm.Scope, f.bytes)
+		case 2:
+			return message(&sm.Metrics, f.bytes, decodeMetric)
+		}
+		return nil
+	})
+}
+
+func decodeMetric(m *metric, b []byte) error {
+	return fields(b, func(f field) error {
+		var data **metricData
+		var attributesField protowire.Number
+		switch f.num {
+		case 1:
+			m.Name = string(f.bytes)
+		case 2:
+			m.Description = string(f.bytes)
+		case 3:
+			m.Unit = string(f.bytes)
+		case 5:
+			data, attributesField = &m.Gauge, 7
+		case 7:
+			data, attributesField = &m.Sum, 7
+		case 9:
+			data, attributesField = &m.Histogram, 9
+		case 10:
+			data, attributesField = &m.ExponentialHistogram, 1
+		case 11:
+			data, attributesField = &m.Summary, 7
+		}
+		if data == nil {
+			return nil
+		}
+		*data = &metricData{}
+		return fields(f.bytes, func(df field) error {
+			if df.num != 1 {
+				return nil
+			}
+			return message(&(*data).DataPoints, df.bytes, func(dp *dataPoint, b []byte) error {
+				return decodeDataPoint(dp, b, f.num, attributesField)
+			})
+		})
+	})
+}
+
+// decodeDataPoint reads any of the data point messages; they share the
+// time, count and sum fields but number their attributes differently.
+func decodeDataPoint(dp *dataPoint, b []byte, kind, attributesField protowire.Number) error {
+	isNumber := kind == 5 || kind == 7
+	return fields(b, func(f field) error {
+		switch {
+		case f.num == attributesField:
+			return message(&dp.Attributes, f.bytes, decodeKeyValue)
+		case f.num == 3:
+			dp.TimeUnixNano = uint64Value(f.scalar)
+		case f.num == 4 && isNumber:
+			d := math.Float64frombits(f.scalar)
+			dp.AsDouble = &d
+		case f.num == 6 && isNumber:
+			n := int64Value(f.scalar)
+			dp.AsInt = &n
+		case f.num == 4:
+			count := uint64Value(f.scalar)
+			dp.Count = &count
+		case f.num == 5 && !isNumber:
+			sum := math.Float64frombits(f.scalar)
+			dp.Sum = &sum
+		}
+		return nil
+	})
+}
@@ -0,0 +1,167 @@
+package telemetry
+
+import (
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/klauspost/compress/snappy"
+)
+
+// remoteWriteTypes names the metric types of the MetricMetadata message.
+var remoteWriteTypes = []string{"unknown", "counter", "gauge", "histogram", "gaugehistogram", "summary", "info", "stateset"}
+
+// RemoteWriteHandler receives Prometheus remote-write (1.0) requests: a
+// snappy-compressed protobuf WriteRequest per POST. Every time series is
+// recorded as a metric with one data point per sample.
+type RemoteWriteHandler struct {
+	store *Store
+}
+
+func NewRemoteWriteHandler(store *Store) *RemoteWriteHandler {
+	return &RemoteWriteHandler{store: store}
+}
+
+func (h *RemoteWriteHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/api/v1/write" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	// Remote-write 2.0 announces itself with a proto parameter
+	_, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if proto := params["proto"]; proto != "" && proto != "prometheus.WriteRequest" {
+		http.Error(w, "unsupported remote-write message "+proto, http.StatusUnsupportedMediaType)
+		return
+	}
+
+	compressed, err := io.ReadAll(io.LimitReader(r.Body, maxOTLPBody+1))
+	if err != nil || len(compressed) > maxOTLPBody {
+		http.Error(w, "unreadable or oversized body", http.StatusBadRequest)
+		return
+	}
+	body, err := snappy.Decode(nil, compressed)
+	if err != nil {
+		http.Error(w, "invalid snappy body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	entries, err := decodeWriteRequest(body)
+	if err != nil {
+		logger.DebugContext(r.Context(), "Invalid remote-write request", "peer", r.RemoteAddr, "error", err)
+		http.Error(w, "invalid write request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	for i := range entries {
+		entries[i].Transport = "http"
+		entries[i].Peer = r.RemoteAddr
+	}
+	h.store.Record(entries...)
+	logger.DebugContext(r.Context(), "Remote-write series received", "series", len(entries), "peer", r.RemoteAddr)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// decodeWriteRequest reads a WriteRequest (timeseries 1, metadata 3). The
+// metric name is the __name__ label and the service the job label; the
+// other labels become the attributes of every sample.
+func decodeWriteRequest(b []byte) ([]Entry, error) {
+	var entries []Entry
+	types := make(map[string]string)
+	err := fields(b, func(f field) error {
+		switch f.num {
+		case 1:
+			entry, err := decodeTimeSeries(f.bytes)
+			if err != nil {
+				return err
+			}
+			entries = append(entries, entry)
+		case 3:
+			var kind int
+			var family string
+			err := fields(f.bytes, func(mf field) error {
+				switch mf.num {
+				case 1:
+					kind = int(mf.scalar)
+				case 2:
+					family = string(mf.bytes)
+				}
+				return nil
+			})
+			types[family] = enumName(remoteWriteTypes, kind)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		if kind, ok := types[entry.Metric.Name]; ok {
+			entry.Metric.Type = kind
+		}
+	}
+	return entries, nil
+}
+
+func decodeTimeSeries(b []byte) (Entry, error) {
+	labels := make(map[string]interface{})
+	var points []DataPoint
+	err := fields(b, func(f field) error {
+		switch f.num {
+		case 1:
+			var name, value string
+			err := fields(f.bytes, func(lf field) error {
+				switch lf.num {
+				case 1:
+					name = string(lf.bytes)
+				case 2:
+					value = string(lf.bytes)
+				}
+				return nil
+			})
+			labels[name] = value
+			return err
+		case 2:
+			var point DataPoint
+			err := fields(f.bytes, func(sf field) error {
+				switch sf.num {
+				case 1:
+					value := math.Float64frombits(sf.scalar)
+					point.Value = &value
+				case 2:
+					timestamp := time.UnixMilli(int64(sf.scalar)).UTC()
+					point.Timestamp = &timestamp
+				}
+				return nil
+			})
+			points = append(points, point)
+			return err
+		}
+		return nil
+	})
+
+	name, _ := labels["__name__"].(string)
+	delete(labels, "__name__")
+	entry := Entry{Signal: SignalMetric, Protocol: ProtocolRemoteWrite}
+	entry.Service, _ = labels["job"].(string)
+	if len(labels) == 0 {
+		labels = nil
+	}
+	info := &MetricInfo{Name: name, Type: remoteWriteTypes[0], DataPoints: []DataPoint{}}
+	for _, point := range points {
+		point.Attributes = labels
+		if point.Timestamp != nil && (entry.Timestamp == nil || point.Timestamp.After(*entry.Timestamp)) {
+			entry.Timestamp = point.Timestamp
+		}
+		info.DataPoints = append(info.DataPoints, point)
+	}
+	entry.Metric = info
+	return entry, err
+}
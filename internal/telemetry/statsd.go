@@ -0,0 +1,108 @@
+package telemetry
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// statsdTypes names the StatsD metric types.
+var statsdTypes = map[string]string{
+	"c":  "counter",
+	"g":  "gauge",
+	"ms": "timer",
+	"h":  "histogram",
+	"d":  "distribution",
+	"s":  "set",
+}
+
+// StatsDServer receives StatsD metrics over UDP, including DogStatsD tags,
+// and records every metric line.
+type StatsDServer struct {
+	store *Store
+}
+
+func NewStatsDServer(store *Store) *StatsDServer {
+	return &StatsDServer{store: store}
+}
+
+// ServeUDP reads datagrams of newline-separated metrics until the
+// connection is closed.
+func (s *StatsDServer) ServeUDP(conn net.PacketConn) error {
+	buf := make([]byte, 65535)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		var entries []Entry
+		for _, line := range strings.Split(string(buf[:n]), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" {
+				continue
+			}
+			entry, ok := parseStatsD(line)
+			if !ok {
+				logger.Debug("Ignoring malformed StatsD metric", "peer", addr.String(), "line", line)
+				continue
+			}
+			entry.Transport = "udp"
+			entry.Peer = addr.String()
+			entries = append(entries, entry)
+		}
+		s.store.Record(entries...)
+	}
+}
+
+// parseStatsD reads "name:value|type[|@rate][|#tag:value,...]". The value
+// is kept as sent in Raw, since sets count strings and signed gauge values
+// are deltas.
+func parseStatsD(line string) (Entry, bool) {
+	name, rest, ok := strings.Cut(line, ":")
+	if !ok || name == "" {
+		return Entry{}, false
+	}
+	sections := strings.Split(rest, "|")
+	if len(sections) < 2 {
+		return Entry{}, false
+	}
+	kind, ok := statsdTypes[sections[1]]
+	if !ok {
+		return Entry{}, false
+	}
+
+	point := DataPoint{Raw: sections[0]}
+	if kind != "set" {
+		value, err := strconv.ParseFloat(sections[0], 64)
+		if err != nil {
+			return Entry{}, false
+		}
+		point.Value = &value
+	}
+	for _, section := range sections[2:] {
+		switch {
+		case strings.HasPrefix(section, "@"):
+			rate, err := strconv.ParseFloat(section[1:], 64)
+			if err != nil {
+				return Entry{}, false
+			}
+			point.SampleRate = rate
+		case strings.HasPrefix(section, "#"):
+			point.Attributes = make(map[string]interface{})
+			for _, tag := range strings.Split(section[1:], ",") {
+				key, value, _ := strings.Cut(tag, ":")
+				point.Attributes[key] = value
+			}
+		}
+	}
+
+	return Entry{
+		Signal:   SignalMetric,
+		Protocol: ProtocolStatsD,
+		Metric:   &MetricInfo{Name: name, Type: kind, DataPoints: []DataPoint{point}},
+	}, true
+}
@@ -0,0 +1,210 @@
+// Package telemetry receives the logs, traces and metrics that services
+// export (syslog, OTLP/HTTP, StatsD and Prometheus remote-write) and keeps them for tests to query, so that
+// exporter configuration can be verified without a collector.
+package telemetry
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Signals recorded in the store.
+const (
+	SignalLog    = "log"
+	SignalTrace  = "trace"
+	SignalMetric = "metric"
+)
+
+// Protocols the telemetry arrived with.
+const (
+	ProtocolSyslog      = "syslog"
+	ProtocolOTLP        = "otlp"
+	ProtocolStatsD      = "statsd"
+	ProtocolRemoteWrite = "remote_write"
+)
+
+// DefaultCapacity is the number of entries kept before the oldest are dropped.
+const DefaultCapacity = 10000
+
+// Entry is one received log record, span or metric. Service is the syslog
+// app name or the OTLP service.name resource attribute; Timestamp is the
+// time reported by the sender.
+type Entry struct {
+	ID         string                 `json:"id"`
+	Signal     string                 `json:"signal"`
+	Protocol   string                 `json:"protocol"`
+	Transport  string                 `json:"transport"`
+	Peer       string                 `json:"peer,omitempty"`
+	ReceivedAt time.Time              `json:"received_at"`
+	Timestamp  *time.Time             `json:"timestamp,omitempty"`
+	Service    string                 `json:"service,omitempty"`
+	Resource   map[string]interface{} `json:"resource,omitempty"`
+	Scope      string                 `json:"scope,omitempty"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+	TraceID    string                 `json:"trace_id,omitempty"`
+	SpanID     string                 `json:"span_id,omitempty"`
+
+	// Severity and Body describe log records.
+	Severity string      `json:"severity,omitempty"`
+	Body     interface{} `json:"body,omitempty"`
+	Syslog   *SyslogInfo `json:"syslog,omitempty"`
+
+	Span   *SpanInfo   `json:"span,omitempty"`
+	Metric *MetricInfo `json:"metric,omitempty"`
+}
+
+// SyslogInfo holds the header fields of a syslog message.
+type SyslogInfo struct {
+	Format         string                       `json:"format"`
+	Facility       int                          `json:"facility"`
+	SeverityCode   int                          `json:"severity_code"`
+	Hostname       string                       `json:"hostname,omitempty"`
+	AppName        string                       `json:"app_name,omitempty"`
+	ProcID         string                       `json:"proc_id,omitempty"`
+	MsgID          string                       `json:"msg_id,omitempty"`
+	StructuredData map[string]map[string]string `json:"structured_data,omitempty"`
+}
+
+// SpanInfo describes a received span.
+type SpanInfo struct {
+	Name          string    `json:"name"`
+	Kind          string    `json:"kind"`
+	ParentSpanID  string    `json:"parent_span_id,omitempty"`
+	StartTime     time.Time `json:"start_time"`
+	EndTime       time.Time `json:"end_time"`
+	DurationMs    float64   `json:"duration_ms"`
+	StatusCode    string    `json:"status_code"`
+	StatusMessage string    `json:"status_message,omitempty"`
+}
+
+// MetricInfo describes a received metric and its data points.
+type MetricInfo struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description,omitempty"`
+	Unit        string      `json:"unit,omitempty"`
+	Type        string      `json:"type"`
+	DataPoints  []DataPoint `json:"data_points"`
+}
+
+// DataPoint is a metric value; histograms and summaries have a count and a
+// sum instead. StatsD metrics keep the value as sent in Raw, along with
+// their sample rate.
+type DataPoint struct {
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+	Timestamp  *time.Time             `json:"timestamp,omitempty"`
+	Value      *float64               `json:"value,omitempty"`
+	Count      *uint64                `json:"count,omitempty"`
+	Sum        *float64               `json:"sum,omitempty"`
+	Raw        string                 `json:"raw,omitempty"`
+	SampleRate float64                `json:"sample_rate,omitempty"`
+}
+
+// text is what the contains filter searches: the log body, span name or
+// metric name.
+func (e *Entry) text() string {
+	switch {
+	case e.Span != nil:
+		return e.Span.Name
+	case e.Metric != nil:
+		return e.Metric.Name
+	case e.Body != nil:
+		return fmt.Sprint(e.Body)
+	}
+	return ""
+}
+
+// Filter selects entries; zero values match everything. Contains is a
+// case-sensitive substring of the log body, span name or metric name, while
+// Name is an exact metric name.
+type Filter struct {
+	Signal   string
+	Protocol string
+	Service  string
+	Name     string
+	TraceID  string
+	Contains string
+	Since    time.Time
+	Limit    int
+}
+
+func (f Filter) matches(entry *Entry) bool {
+	if f.Signal != "" && entry.Signal != f.Signal {
+		return false
+	}
+	if f.Protocol != "" && entry.Protocol != f.Protocol {
+		return false
+	}
+	if f.Service != "" && entry.Service != f.Service {
+		return false
+	}
+	if f.Name != "" && (entry.Metric == nil || entry.Metric.Name != f.Name) {
+		return false
+	}
+	if f.TraceID != "" && !strings.EqualFold(entry.TraceID, f.TraceID) {
+		return false
+	}
+	if f.Contains != "" && !strings.Contains(entry.text(), f.Contains) {
+		return false
+	}
+	if !f.Since.IsZero() && entry.ReceivedAt.Before(f.Since) {
+		return false
+	}
+	return true
+}
+
+// Store keeps the most recent entries in memory.
+type Store struct {
+	entries  []Entry
+	capacity int
+	nextID   int64
+	mutex    sync.Mutex
+}
+
+func NewStore(capacity int) *Store {
+	if capacity <= 0 {
+		capacity = DefaultCapacity
+	}
+	return &Store{capacity: capacity}
+}
+
+// Record adds entries, assigning their IDs and reception time.
+func (s *Store) Record(entries ...Entry) {
+	now := time.Now()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, entry := range entries {
+		s.nextID++
+		entry.ID = fmt.Sprintf("tel-%d", s.nextID)
+		entry.ReceivedAt = now
+		s.entries = append(s.entries, entry)
+	}
+	if len(s.entries) > s.capacity {
+		s.entries = s.entries[len(s.entries)-s.capacity:]
+	}
+}
+
+// Find returns the entries matching the filter, oldest first. With a Limit
+// only the most recent matches are returned.
+func (s *Store) Find(filter Filter) []Entry {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	matched := []Entry{}
+	for i := range s.entries {
+		if filter.matches(&s.entries[i]) {
+			matched = append(matched, s.entries[i])
+		}
+	}
+	if filter.Limit > 0 && len(matched) > filter.Limit {
+		matched = matched[len(matched)-filter.Limit:]
+	}
+	return matched
+}
+
+// Reset removes every entry.
+func (s *Store) Reset() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.entries = nil
+}
@@ -0,0 +1,232 @@
+package telemetry
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxSyslogMessage bounds a single syslog message; longer TCP frames end
+// the connection.
+const maxSyslogMessage = 64 * 1024
+
+var severityNames = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+// SyslogServer receives syslog messages (RFC 5424 and RFC 3164) over UDP
+// and TCP and records them as log entries.
+type SyslogServer struct {
+	store *Store
+}
+
+func NewSyslogServer(store *Store) *SyslogServer {
+	return &SyslogServer{store: store}
+}
+
+// ServeUDP reads one message per datagram until the connection is closed.
+func (s *SyslogServer) ServeUDP(conn net.PacketConn) error {
+	buf := make([]byte, maxSyslogMessage)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		s.record(string(buf[:n]), "udp", addr.String())
+	}
+}
+
+// ServeTCP accepts connections until the listener is closed. Messages are
+// framed by octet counting or separated by newlines (RFC 6587).
+func (s *SyslogServer) ServeTCP(lis net.Listener) error {
+	for {
+		conn, err := lis.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go s.handleConn(conn)
+	}
+}
+
+func (s *SyslogServer) handleConn(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	peer := conn.RemoteAddr().String()
+	for {
+		message, err := readFrame(reader)
+		if message != "" {
+			s.record(message, "tcp", peer)
+		}
+		if err != nil {
+			if err != io.EOF {
+				logger.Debug("Closing syslog connection", "peer", peer, "error", err)
+			}
+			return
+		}
+	}
+}
+
+// readFrame reads an octet-counted ("LEN MSG") or newline-terminated message.
+func readFrame(reader *bufio.Reader) (string, error) {
+	first, err := reader.Peek(1)
+	if err != nil {
+		return "", err
+	}
+	if first[0] >= '1' && first[0] <= '9' {
+		prefix, err := reader.ReadString(' ')
+		if err != nil {
+			return "", err
+		}
+		length, err := strconv.Atoi(strings.TrimSuffix(prefix, " "))
+		if err != nil || length > maxSyslogMessage {
+			return "", errors.New("invalid octet count")
+		}
+		buf := make([]byte, length)
+		if _, err := io.ReadFull(reader, buf); err != nil {
+			return "", err
+		}
+		return string(buf), nil
+	}
+	line, err := reader.ReadString('\n')
+	if len(line) > maxSyslogMessage {
+		return "", errors.New("message too long")
+	}
+	return line, err
+}
+
+func (s *SyslogServer) record(message, transport, peer string) {
+	message = strings.TrimRight(message, "\r\n\x00")
+	if message == "" {
+		return
+	}
+	entry := parseSyslog(message)
+	entry.Transport = transport
+	entry.Peer = peer
+	s.store.Record(entry)
+}
+
+// parseSyslog decodes a message; what cannot be parsed ends up in the body.
+func parseSyslog(message string) Entry {
+	entry := Entry{Signal: SignalLog, Protocol: ProtocolSyslog, Body: message}
+	if !strings.HasPrefix(message, "<") {
+		return entry
+	}
+	end := strings.IndexByte(message, '>')
+	priority, err := strconv.Atoi(message[1:max(end, 1)])
+	if end < 2 || end > 4 || err != nil || priority > 191 {
+		return entry
+	}
+	info := &SyslogInfo{Facility: priority / 8, SeverityCode: priority % 8}
+	entry.Syslog = info
+	entry.Severity = severityNames[info.SeverityCode]
+	rest := message[end+1:]
+
+	if strings.HasPrefix(rest, "1 ") {
+		parseRFC5424(&entry, rest[2:])
+	} else {
+		parseRFC3164(&entry, rest)
+	}
+	entry.Service = info.AppName
+	return entry
+}
+
+// parseRFC5424 reads TIMESTAMP HOSTNAME APP-NAME PROCID MSGID
+// STRUCTURED-DATA MSG, where "-" is an empty field.
+func parseRFC5424(entry *Entry, rest string) {
+	info := entry.Syslog
+	info.Format = "rfc5424"
+	fields := make([]string, 5)
+	for i := range fields {
+		fields[i], rest, _ = strings.Cut(rest, " ")
+		if fields[i] == "-" {
+			fields[i] = ""
+		}
+	}
+	if timestamp, err := time.Parse(time.RFC3339Nano, fields[0]); err == nil {
+		entry.Timestamp = &timestamp
+	}
+	info.Hostname, info.AppName, info.ProcID, info.MsgID = fields[1], fields[2], fields[3], fields[4]
+
+	if strings.HasPrefix(rest, "-") {
+		rest = rest[1:]
+	} else {
+		info.StructuredData, rest = parseStructuredData(rest)
+	}
+	rest = strings.TrimPrefix(rest, " ")
+	entry.Body = strings.TrimPrefix(rest, "\ufeff")
+}
+
+// parseStructuredData reads [id name="value" ...] elements and returns the
+// remaining message.
+func parseStructuredData(rest string) (map[string]map[string]string, string) {
+	data := make(map[string]map[string]string)
+	for strings.HasPrefix(rest, "[") {
+		rest = rest[1:]
+		var id string
+		id, rest = cutAny(rest, " ]")
+		params := make(map[string]string)
+		data[id] = params
+		for strings.HasPrefix(rest, " ") {
+			var name string
+			name, rest, _ = strings.Cut(rest[1:], "=\"")
+			var value strings.Builder
+			for len(rest) > 0 && rest[0] != '"' {
+				if rest[0] == '\\' && len(rest) > 1 {
+					rest = rest[1:]
+				}
+				value.WriteByte(rest[0])
+				rest = rest[1:]
+			}
+			params[name] = value.String()
+			rest = strings.TrimPrefix(rest, "\"")
+		}
+		rest = strings.TrimPrefix(rest, "]")
+	}
+	return data, rest
+}
+
+// cutAny splits s before the first of the characters in chars.
+func cutAny(s, chars string) (string, string) {
+	if i := strings.IndexAny(s, chars); i >= 0 {
+		return s[:i], s[i:]
+	}
+	return s, ""
+}
+
+// parseRFC3164 reads the BSD format: "Mmm dd hh:mm:ss HOSTNAME TAG[PID]: MSG".
+func parseRFC3164(entry *Entry, rest string) {
+	info := entry.Syslog
+	info.Format = "rfc3164"
+	entry.Body = rest
+	if len(rest) < 16 || rest[15] != ' ' {
+		return
+	}
+	timestamp, err := time.ParseInLocation(time.Stamp, rest[:15], time.Local)
+	if err != nil {
+		return
+	}
+	timestamp = timestamp.AddDate(time.Now().Year(), 0, 0)
+	entry.Timestamp = &timestamp
+	rest = rest[16:]
+
+	info.Hostname, rest, _ = strings.Cut(rest, " ")
+	tag, message, ok := strings.Cut(rest, ": ")
+	if !ok || strings.ContainsAny(tag, " ") {
+		entry.Body = rest
+		return
+	}
+	if open := strings.IndexByte(tag, '['); open >= 0 && strings.HasSuffix(tag, "]") {
+		info.ProcID = tag[open+1 : len(tag)-1]
+		tag = tag[:open]
+	}
+	info.AppName = tag
+	entry.Body = message
+}
@@ -0,0 +1,135 @@
+// Package timefmt renders timestamps in a configurable zone and format, so
+// that clients can be tested against the date representations they meet in
+// the wild.
+package timefmt
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	_ "time/tzdata" // zones resolve without a system zoneinfo database
+)
+
+// Epoch formats render a number rather than a string.
+const (
+	Unix      = "unix"
+	UnixMilli = "unix_ms"
+	UnixMicro = "unix_us"
+	UnixNano  = "unix_ns"
+)
+
+// Named formats. The "us" and "eu" formats cannot be told apart on days up to
+// the 12th, and "naive" drops the offset; they exist to trip up parsers.
+var layouts = map[string]string{
+	"rfc3339":     time.RFC3339,
+	"rfc3339nano": time.RFC3339Nano,
+	"rfc1123":     time.RFC1123,
+	"rfc1123z":    time.RFC1123Z,
+	"rfc850":      time.RFC850,
+	"rfc822":      time.RFC822,
+	"ansic":       time.ANSIC,
+	"iso8601":     "2006-01-02T15:04:05.000Z0700",
+	"naive":       "2006-01-02T15:04:05",
+	"date":        time.DateOnly,
+	"us":          "01/02/2006 15:04:05",
+	"eu":          "02/01/2006 15:04:05",
+	"short_year":  "01/02/06 03:04 PM",
+}
+
+// Config selects the format and zone of timestamps. Format is an epoch or
+// named format, or else a Go reference layout ("Mon Jan 2 15:04:05 2006");
+// it defaults to unix seconds. Zone is an IANA name ("Asia/Kolkata"),
+// "Local", or a fixed offset ("+05:30"); it defaults to UTC.
+type Config struct {
+	Format string `json:"format,omitempty"`
+	Zone   string `json:"zone,omitempty"`
+}
+
+// Formatter renders timestamps; the zero value renders unix seconds, and
+// formats times in UTC.
+type Formatter struct {
+	format string
+	loc    *time.Location
+}
+
+// New returns the formatter of a configuration.
+func New(cfg Config) (Formatter, error) {
+	f := Formatter{}.WithFormat(cfg.Format)
+	if cfg.Zone != "" {
+		loc, err := LoadZone(cfg.Zone)
+		if err != nil {
+			return Formatter{}, err
+		}
+		f = f.In(loc)
+	}
+	return f, nil
+}
+
+// WithFormat returns a copy of f rendering the given format.
+func (f Formatter) WithFormat(format string) Formatter {
+	f.format = format
+	if name := strings.ToLower(format); isEpoch(name) || layouts[name] != "" {
+		f.format = name
+	}
+	return f
+}
+
+// In returns a copy of f rendering times in loc.
+func (f Formatter) In(loc *time.Location) Formatter {
+	f.loc = loc
+	return f
+}
+
+// LoadZone resolves a zone name or a fixed "+hh:mm" offset.
+func LoadZone(zone string) (*time.Location, error) {
+	if strings.HasPrefix(zone, "+") || strings.HasPrefix(zone, "-") {
+		offset, err := time.Parse("-07:00", zone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid zone offset %q", zone)
+		}
+		_, seconds := offset.Zone()
+		return time.FixedZone(zone, seconds), nil
+	}
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		return nil, fmt.Errorf("unknown zone %q", zone)
+	}
+	return loc, nil
+}
+
+// Value renders t as an int64 for epoch formats and as a string otherwise,
+// ready to be encoded as JSON.
+func (f Formatter) Value(t time.Time) interface{} {
+	switch f.format {
+	case "", Unix:
+		return t.Unix()
+	case UnixMilli:
+		return t.UnixMilli()
+	case UnixMicro:
+		return t.UnixMicro()
+	case UnixNano:
+		return t.UnixNano()
+	}
+	if f.loc != nil {
+		t = t.In(f.loc)
+	} else {
+		t = t.UTC()
+	}
+	if layout, ok := layouts[f.format]; ok {
+		return t.Format(layout)
+	}
+	return t.Format(f.format)
+}
+
+// String renders t as text.
+func (f Formatter) String(t time.Time) string {
+	return fmt.Sprint(f.Value(t))
+}
+
+func isEpoch(format string) bool {
+	switch format {
+	case Unix, UnixMilli, UnixMicro, UnixNano:
+		return true
+	}
+	return false
+}
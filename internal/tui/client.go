@@ -0,0 +1,148 @@
+package tui
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// tailSize is how many journal entries are fetched on each refresh.
+const tailSize = 200
+
+// client calls the admin API of the server.
+type client struct {
+	base string
+	http *http.Client
+}
+
+func newClient(base string) *client {
+	return &client{base: base, http: &http.Client{Timeout: 2 * time.Second}}
+}
+
+// call sends a request and decodes the JSON response into out, if given.
+func (c *client) call(method, path string, body interface{}, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, c.base+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		var failure struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&failure)
+		return fmt.Errorf("%s %s: %d %s", method, path, resp.StatusCode, failure.Error)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func (c *client) snapshot() snapshot {
+	var s snapshot
+	var stubs struct {
+		Stubs []stubSummary `json:"stubs"`
+	}
+	if s.err = c.call(http.MethodGet, "/__admin/stubs", nil, &stubs); s.err != nil {
+		return s
+	}
+	s.stubs = stubs.Stubs
+
+	var requests struct {
+		Requests []requestSummary `json:"requests"`
+	}
+	if s.err = c.call(http.MethodGet, fmt.Sprintf("/__admin/requests?limit=%d", tailSize), nil, &requests); s.err != nil {
+		return s
+	}
+	s.requests = requests.Requests
+	var stats struct {
+		Entries int `json:"entries"`
+	}
+	if c.call(http.MethodGet, "/__admin/requests/stats", nil, &stats) == nil {
+		s.requestCount = stats.Entries
+	}
+
+	var connections struct {
+		Count int `json:"count"`
+	}
+	if c.call(http.MethodGet, "/__admin/ws/connections", nil, &connections) == nil {
+		s.connections = connections.Count
+	}
+	c.call(http.MethodGet, "/__admin/ws/chaos", nil, &s.chaos)
+	return s
+}
+
+// toggleChaos pauses or resumes the WebSocket chaos and reports the outcome.
+func (c *client) toggleChaos() string {
+	var state chaosState
+	if err := c.call(http.MethodGet, "/__admin/ws/chaos", nil, &state); err != nil {
+		return "chaos: " + err.Error()
+	}
+	if len(state.Endpoints) == 0 {
+		return "chaos: not configured (websocket.chaos)"
+	}
+	if err := c.call(http.MethodPut, "/__admin/ws/chaos", map[string]bool{"enabled": !state.Enabled}, &state); err != nil {
+		return "chaos: " + err.Error()
+	}
+	if state.Enabled {
+		return "chaos resumed"
+	}
+	return "chaos paused"
+}
+
+// push sends a WebSocket message typed as "[#room] message"; a message that
+// is valid JSON is sent as such.
+func (c *client) push(line string) string {
+	line = strings.TrimSpace(line)
+	room := ""
+	if strings.HasPrefix(line, "#") {
+		room, line, _ = strings.Cut(line[1:], " ")
+		line = strings.TrimSpace(line)
+	}
+	var data interface{} = line
+	if json.Valid([]byte(line)) {
+		data = json.RawMessage(line)
+	}
+
+	var result struct {
+		Delivered int `json:"delivered"`
+	}
+	if err := c.call(http.MethodPost, "/__admin/ws/push", map[string]interface{}{"room": room, "data": data}, &result); err != nil {
+		return "push: " + err.Error()
+	}
+	target := "broadcast"
+	if room != "" {
+		target = "room " + room
+	}
+	return fmt.Sprintf("pushed to %s: %d client(s)", target, result.Delivered)
+}
+
+// reset clears the request journal and the HTTP and gRPC attempt counters.
+func (c *client) reset() string {
+	for _, path := range []string{"/__admin/requests", "/__admin/stubs/attempts", "/__admin/grpc/attempts"} {
+		if err := c.call(http.MethodDelete, path, nil, nil); err != nil {
+			return "reset: " + err.Error()
+		}
+	}
+	return "journal and attempt counters reset"
+}
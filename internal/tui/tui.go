@@ -0,0 +1,335 @@
+// Package tui is a terminal UI for a running mock server: a live tail of the
+// request journal, the HTTP stubs and quick actions (pause WebSocket chaos,
+// push a WebSocket message, reset the journal), all through the admin API.
+package tui
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// Config selects the server the UI watches.
+type Config struct {
+	// URL is the base URL of the server's HTTP listener.
+	URL string
+	// Interval is how often the screen is refreshed.
+	Interval time.Duration
+}
+
+// Main runs "mockserver tui" with the arguments following the command.
+func Main(args []string) error {
+	flags := flag.NewFlagSet("tui", flag.ExitOnError)
+	url := flags.String("url", "http://localhost:8080", "base URL of the mock server")
+	interval := flags.Duration("interval", time.Second, "refresh interval")
+	flags.Parse(args)
+
+	if *interval <= 0 {
+		return fmt.Errorf("interval must be positive")
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return Run(ctx, Config{URL: strings.TrimRight(*url, "/"), Interval: *interval}, os.Stdin, os.Stdout)
+}
+
+// Run draws the UI on out and reads keys from in, which must be a terminal,
+// until q is pressed or ctx ends.
+func Run(ctx context.Context, cfg Config, in, out *os.File) error {
+	if !term.IsTerminal(int(in.Fd())) {
+		return errors.New("tui needs an interactive terminal")
+	}
+	state, err := term.MakeRaw(int(in.Fd()))
+	if err != nil {
+		return fmt.Errorf("enter raw mode: %w", err)
+	}
+	defer term.Restore(int(in.Fd()), state)
+	// Alternate screen, hidden cursor
+	fmt.Fprint(out, "\x1b[?1049h\x1b[?25l")
+	defer fmt.Fprint(out, "\x1b[?25h\x1b[?1049l")
+
+	keys := make(chan string)
+	go readKeys(in, keys)
+
+	ui := &ui{api: newClient(cfg.URL), out: out, fd: int(out.Fd())}
+	ui.refresh()
+	ticker := time.NewTicker(cfg.Interval)
+	defer ticker.Stop()
+	for {
+		ui.draw()
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			ui.refresh()
+		case key, ok := <-keys:
+			if !ok || ui.handle(key) {
+				return nil
+			}
+		}
+	}
+}
+
+// readKeys forwards what the terminal sends, one read at a time, so escape
+// sequences arrive whole.
+func readKeys(in io.Reader, keys chan<- string) {
+	defer close(keys)
+	buf := make([]byte, 64)
+	for {
+		n, err := in.Read(buf)
+		if err != nil {
+			return
+		}
+		keys <- string(buf[:n])
+	}
+}
+
+// ui is the state of the screen.
+type ui struct {
+	api *client
+	out io.Writer
+	fd  int
+
+	snapshot snapshot
+	// prompt is set while a line is being typed; input holds it so far.
+	prompt string
+	input  []rune
+	status string
+}
+
+func (u *ui) refresh() {
+	u.snapshot = u.api.snapshot()
+}
+
+// handle applies a key and reports whether to quit.
+func (u *ui) handle(key string) bool {
+	if u.prompt != "" {
+		u.edit(key)
+		return false
+	}
+	switch key {
+	case "q", "\x03":
+		return true
+	case "c":
+		u.status = u.api.toggleChaos()
+	case "p":
+		u.prompt, u.input = "push [#room] message: ", nil
+		return false
+	case "r":
+		u.status = u.api.reset()
+	default:
+		return false
+	}
+	u.refresh()
+	return false
+}
+
+// edit handles a key typed at the prompt: Enter submits, Esc cancels.
+func (u *ui) edit(key string) {
+	switch {
+	case key == "\r" || key == "\n":
+		u.status = u.api.push(string(u.input))
+		u.prompt, u.input = "", nil
+		u.refresh()
+	case key == "\x1b" || key == "\x03":
+		u.prompt, u.input, u.status = "", nil, "push cancelled"
+	case key == "\x7f" || key == "\b":
+		if len(u.input) > 0 {
+			u.input = u.input[:len(u.input)-1]
+		}
+	case strings.HasPrefix(key, "\x1b"):
+		// arrows and other sequences are not editable
+	default:
+		for _, r := range key {
+			if r >= ' ' {
+				u.input = append(u.input, r)
+			}
+		}
+	}
+}
+
+func (u *ui) draw() {
+	width, height, err := term.GetSize(u.fd)
+	if err != nil || width < 20 || height < 8 {
+		width, height = 80, 24
+	}
+	s := u.snapshot
+
+	var lines []string
+	health := "\x1b[32m● up\x1b[0m"
+	if s.err != nil {
+		health = "\x1b[31m● " + s.err.Error() + "\x1b[0m"
+	}
+	lines = append(lines, fmt.Sprintf("\x1b[1mmockserver\x1b[0m %s  %s", u.api.base, health))
+	chaos := "off"
+	if len(s.chaos.Endpoints) == 0 {
+		chaos = "not configured"
+	} else if s.chaos.Enabled {
+		chaos = "on (" + strings.Join(s.chaos.Endpoints, ", ") + ")"
+	}
+	lines = append(lines, fmt.Sprintf("stubs %d · requests %d · ws connections %d · chaos %s",
+		len(s.stubs), s.requestCount, s.connections, chaos))
+
+	// The stubs get up to a third of the screen, the tail the rest
+	body := height - len(lines) - 4
+	stubRows := min(len(s.stubs), max(body/3-1, 1))
+	lines = append(lines, "", "\x1b[1mStubs\x1b[0m")
+	for _, stub := range s.stubs[:stubRows] {
+		lines = append(lines, stub.line())
+	}
+	if more := len(s.stubs) - stubRows; more > 0 {
+		lines = append(lines, fmt.Sprintf("  … %d more", more))
+	}
+
+	lines = append(lines, "", "\x1b[1mRequests\x1b[0m")
+	tail := max(height-len(lines)-2, 0)
+	requests := s.requests
+	if len(requests) > tail {
+		requests = requests[len(requests)-tail:]
+	}
+	for _, entry := range requests {
+		lines = append(lines, entry.line())
+	}
+	for len(lines) < height-2 {
+		lines = append(lines, "")
+	}
+
+	if u.prompt != "" {
+		lines = append(lines, u.prompt+string(u.input)+"▏")
+	} else {
+		lines = append(lines, u.status)
+	}
+	lines = append(lines, "\x1b[7m p push  c chaos  r reset  q quit \x1b[0m")
+
+	var buf bytes.Buffer
+	buf.WriteString("\x1b[H")
+	for i, line := range lines[:min(len(lines), height)] {
+		if i > 0 {
+			buf.WriteString("\r\n")
+		}
+		buf.WriteString(truncate(line, width))
+		buf.WriteString("\x1b[K")
+	}
+	buf.WriteString("\x1b[J")
+	u.out.Write(buf.Bytes())
+}
+
+// truncate cuts a line to width visible characters, not counting the ANSI
+// escape sequences it contains.
+func truncate(line string, width int) string {
+	var b strings.Builder
+	visible := 0
+	for i := 0; i < len(line); {
+		if line[i] == '\x1b' {
+			end := strings.IndexByte(line[i:], 'm')
+			if end < 0 {
+				break
+			}
+			b.WriteString(line[i : i+end+1])
+			i += end + 1
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(line[i:])
+		if visible == width {
+			b.WriteString("\x1b[0m")
+			break
+		}
+		b.WriteRune(r)
+		visible++
+		i += size
+	}
+	return b.String()
+}
+
+// snapshot is what the admin API reported on the last refresh.
+type snapshot struct {
+	err          error
+	stubs        []stubSummary
+	requests     []requestSummary
+	requestCount int
+	connections  int
+	chaos        chaosState
+}
+
+type stubSummary struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Request struct {
+		Method    string `json:"method"`
+		Path      string `json:"path"`
+		PathRegex string `json:"path_regex"`
+	} `json:"request"`
+	Response struct {
+		Status int `json:"status"`
+	} `json:"response"`
+}
+
+func (s stubSummary) line() string {
+	method := s.Request.Method
+	if method == "" {
+		method = "ANY"
+	}
+	path := s.Request.Path
+	if path == "" {
+		path = "~" + s.Request.PathRegex
+	}
+	status := s.Response.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	return fmt.Sprintf("  %-7s %-40s → %d  \x1b[2m%s %s\x1b[0m", method, path, status, s.ID, s.Name)
+}
+
+type requestSummary struct {
+	Timestamp time.Time `json:"timestamp"`
+	Protocol  string    `json:"protocol"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	Query     string    `json:"query"`
+	Status    int       `json:"status"`
+	Code      string    `json:"code"`
+	LatencyMs float64   `json:"latency_ms"`
+	StubID    string    `json:"stub_id"`
+}
+
+func (r requestSummary) line() string {
+	target := r.Path
+	if r.Query != "" {
+		target += "?" + r.Query
+	}
+	result := r.Code
+	color := "32"
+	if r.Status > 0 {
+		result = fmt.Sprint(r.Status)
+		switch {
+		case r.Status >= 500:
+			color = "31"
+		case r.Status >= 400:
+			color = "33"
+		}
+	} else if result != "" && result != "OK" {
+		color = "31"
+	}
+	stub := ""
+	if r.StubID != "" {
+		stub = "  \x1b[2mstub " + r.StubID + "\x1b[0m"
+	}
+	return fmt.Sprintf("  %s %-5s %-7s %-40s \x1b[%sm%s\x1b[0m %7.1fms%s",
+		r.Timestamp.Local().Format("15:04:05.000"), r.Protocol, r.Method, target, color, result, r.LatencyMs, stub)
+}
+
+type chaosState struct {
+	Enabled   bool     `json:"enabled"`
+	Endpoints []string `json:"endpoints"`
+}
@@ -0,0 +1,500 @@
+package tus
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"mockserver/internal/logging"
+)
+
+var logger = logging.For("tus")
+
+// offsetContentType is the content type of upload data.
+const offsetContentType = "application/offset+octet-stream"
+
+// maxUploads bounds memory use; the oldest uploads are forgotten beyond it.
+const maxUploads = 1000
+
+// Upload is the state of an upload. Length is null while it is deferred.
+type Upload struct {
+	ID          string            `json:"id"`
+	Length      *int64            `json:"length"`
+	Offset      int64             `json:"offset"`
+	Complete    bool              `json:"complete"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	InterruptAt []int64           `json:"interrupt_at,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+	Chunks      []Chunk           `json:"chunks"`
+}
+
+// Chunk is the data received by one request. Interrupted chunks are the
+// ones cut by a dropped connection, injected or not.
+type Chunk struct {
+	Offset      int64     `json:"offset"`
+	Length      int64     `json:"length"`
+	SHA256      string    `json:"sha256"`
+	ReceivedAt  time.Time `json:"received_at"`
+	Interrupted bool      `json:"interrupted,omitempty"`
+}
+
+type upload struct {
+	Upload
+	data []byte
+	// busy rejects concurrent writes to the upload
+	busy sync.Mutex
+}
+
+// Server serves the uploads.
+type Server struct {
+	config  Config
+	path    string
+	maxSize int64
+
+	uploads map[string]*upload
+	order   []string
+	nextID  int
+	mutex   sync.Mutex
+}
+
+func New(config Config) (*Server, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return &Server{
+		config:  config,
+		path:    config.path(),
+		maxSize: config.maxSize(),
+		uploads: make(map[string]*upload),
+	}, nil
+}
+
+// Path returns the base path of the uploads.
+func (s *Server) Path() string {
+	return s.path
+}
+
+// exposedHeaders are the response headers browser clients need to read.
+const exposedHeaders = "Location, Upload-Offset, Upload-Length, Upload-Defer-Length, Upload-Metadata, Tus-Resumable, Tus-Version, Tus-Extension, Tus-Max-Size"
+
+// Register adds the endpoints to the server. POST to an upload is accepted
+// with X-HTTP-Method-Override, for clients that can only send GET and POST.
+func (s *Server) Register(e *echo.Echo) {
+	e.Pre(s.discovery)
+	e.POST(s.path, s.protocol(s.Create))
+	e.HEAD(s.path+"/:id", s.protocol(s.Head))
+	e.PATCH(s.path+"/:id", s.protocol(s.Patch))
+	e.DELETE(s.path+"/:id", s.protocol(s.Terminate))
+	e.POST(s.path+"/:id", s.protocol(s.override))
+}
+
+// discovery answers the OPTIONS requests of the uploads, which the CORS
+// middleware would take for preflights, and exposes the protocol headers to
+// cross-origin clients. Actual preflights are left to the CORS middleware.
+func (s *Server) discovery(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		r := c.Request()
+		if r.URL.Path != s.path && !strings.HasPrefix(r.URL.Path, s.path+"/") {
+			return next(c)
+		}
+		if r.Method == http.MethodOptions && r.Header.Get(echo.HeaderAccessControlRequestMethod) == "" {
+			return s.Options(c)
+		}
+		if r.Header.Get(echo.HeaderOrigin) != "" {
+			c.Response().Header().Set(echo.HeaderAccessControlExposeHeaders, exposedHeaders)
+		}
+		return next(c)
+	}
+}
+
+// Options describes the server.
+func (s *Server) Options(c echo.Context) error {
+	header := c.Response().Header()
+	header.Set("Tus-Resumable", Version)
+	header.Set("Tus-Version", Version)
+	header.Set("Tus-Extension", Extensions)
+	header.Set("Tus-Max-Size", strconv.FormatInt(s.maxSize, 10))
+	return c.NoContent(http.StatusNoContent)
+}
+
+// protocol checks the protocol version of a request and sets the one of the
+// response.
+func (s *Server) protocol(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		header := c.Response().Header()
+		header.Set("Tus-Resumable", Version)
+		if version := c.Request().Header.Get("Tus-Resumable"); version != Version {
+			header.Set("Tus-Version", Version)
+			return tusError(c, http.StatusPreconditionFailed, "Unsupported protocol version", version)
+		}
+		return next(c)
+	}
+}
+
+func (s *Server) override(c echo.Context) error {
+	switch strings.ToUpper(c.Request().Header.Get("X-HTTP-Method-Override")) {
+	case http.MethodPatch:
+		return s.Patch(c)
+	case http.MethodDelete:
+		return s.Terminate(c)
+	case http.MethodHead:
+		return s.Head(c)
+	}
+	return c.NoContent(http.StatusMethodNotAllowed)
+}
+
+// Create creates an upload, writing the request body to it when it has
+// upload data (creation-with-upload).
+func (s *Server) Create(c echo.Context) error {
+	r := c.Request()
+	u := &upload{Upload: Upload{CreatedAt: time.Now(), Chunks: []Chunk{}}}
+	if value := r.Header.Get("Upload-Length"); value != "" {
+		length, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || length < 0 {
+			return tusError(c, http.StatusBadRequest, "Invalid Upload-Length", value)
+		}
+		if length > s.maxSize {
+			return tusError(c, http.StatusRequestEntityTooLarge, "Upload exceeds Tus-Max-Size", value)
+		}
+		u.Length = &length
+	} else if r.Header.Get("Upload-Defer-Length") != "1" {
+		return tusError(c, http.StatusBadRequest, "Upload-Length or Upload-Defer-Length is required", "")
+	}
+	metadata, err := parseMetadata(r.Header.Get("Upload-Metadata"))
+	if err != nil {
+		return tusError(c, http.StatusBadRequest, "Invalid Upload-Metadata", err.Error())
+	}
+	u.Metadata = metadata
+	u.InterruptAt = s.config.InterruptAt
+	if value := r.Header.Get(InterruptHeader); value != "" {
+		if u.InterruptAt, err = parseOffsets(value); err != nil {
+			return tusError(c, http.StatusBadRequest, "Invalid "+InterruptHeader, value)
+		}
+	}
+	u.InterruptAt = sortedOffsets(u.InterruptAt)
+
+	withData := r.Header.Get(echo.HeaderContentType) == offsetContentType
+	if withData {
+		u.busy.Lock()
+		defer u.busy.Unlock()
+	}
+
+	s.mutex.Lock()
+	s.nextID++
+	u.ID = fmt.Sprintf("upload-%d", s.nextID)
+	s.uploads[u.ID] = u
+	s.order = append(s.order, u.ID)
+	if len(s.order) > maxUploads {
+		delete(s.uploads, s.order[0])
+		s.order = s.order[1:]
+	}
+	s.mutex.Unlock()
+
+	logger.DebugContext(c.Request().Context(), "Upload created", "upload_id", u.ID, "length", describeLength(u.Length))
+	c.Response().Header().Set(echo.HeaderLocation, c.Scheme()+"://"+r.Host+s.path+"/"+u.ID)
+	if withData {
+		if done, err := s.write(c, u); done || err != nil {
+			return err
+		}
+	}
+	return c.NoContent(http.StatusCreated)
+}
+
+// Head reports the offset of an upload.
+func (s *Server) Head(c echo.Context) error {
+	u := s.upload(c.Param("id"))
+	if u == nil {
+		return c.NoContent(http.StatusNotFound)
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	header := c.Response().Header()
+	header.Set("Cache-Control", "no-store")
+	header.Set("Upload-Offset", strconv.FormatInt(u.Offset, 10))
+	if u.Length != nil {
+		header.Set("Upload-Length", strconv.FormatInt(*u.Length, 10))
+	} else {
+		header.Set("Upload-Defer-Length", "1")
+	}
+	if len(u.Metadata) > 0 {
+		header.Set("Upload-Metadata", formatMetadata(u.Metadata))
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+// Patch appends the request body to an upload at Upload-Offset.
+func (s *Server) Patch(c echo.Context) error {
+	r := c.Request()
+	u := s.upload(c.Param("id"))
+	if u == nil {
+		return tusError(c, http.StatusNotFound, "Upload not found", c.Param("id"))
+	}
+	if r.Header.Get(echo.HeaderContentType) != offsetContentType {
+		return tusError(c, http.StatusUnsupportedMediaType, "Content-Type must be "+offsetContentType, r.Header.Get(echo.HeaderContentType))
+	}
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		return tusError(c, http.StatusBadRequest, "Invalid Upload-Offset", r.Header.Get("Upload-Offset"))
+	}
+	if !u.busy.TryLock() {
+		return tusError(c, http.StatusLocked, "Upload is being written by another request", u.ID)
+	}
+	defer u.busy.Unlock()
+
+	s.mutex.Lock()
+	current := u.Offset
+	var lengthErr string
+	if value := r.Header.Get("Upload-Length"); value != "" && u.Length == nil {
+		length, err := strconv.ParseInt(value, 10, 64)
+		switch {
+		case err != nil || length < current:
+			lengthErr = "Invalid Upload-Length"
+		case length > s.maxSize:
+			lengthErr = "Upload exceeds Tus-Max-Size"
+		default:
+			u.Length = &length
+		}
+	}
+	s.mutex.Unlock()
+	if lengthErr != "" {
+		return tusError(c, http.StatusBadRequest, lengthErr, r.Header.Get("Upload-Length"))
+	}
+	if offset != current {
+		return tusError(c, http.StatusConflict, "Upload-Offset does not match the upload", strconv.FormatInt(current, 10))
+	}
+	if done, err := s.write(c, u); done || err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// Terminate deletes an upload.
+func (s *Server) Terminate(c echo.Context) error {
+	if !s.Remove(c.Param("id")) {
+		return tusError(c, http.StatusNotFound, "Upload not found", c.Param("id"))
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// write appends the request body to an upload and sets Upload-Offset. When
+// the data crosses an interruption offset, the bytes up to it are kept and
+// the connection is dropped: done is then true and no response must be
+// written. The caller holds the busy lock of the upload.
+func (s *Server) write(c echo.Context, u *upload) (done bool, err error) {
+	s.mutex.Lock()
+	offset := u.Offset
+	budget := s.maxSize - offset
+	if u.Length != nil {
+		budget = *u.Length - offset
+	}
+	stop := int64(-1)
+	for _, at := range u.InterruptAt {
+		if at > offset {
+			stop = at - offset
+			break
+		}
+	}
+	s.mutex.Unlock()
+
+	// One byte more tells whether the data goes past the limit
+	limit := budget + 1
+	if stop >= 0 && stop < limit {
+		limit = stop + 1
+	}
+	data, readErr := io.ReadAll(io.LimitReader(c.Request().Body, limit))
+	interrupted := readErr != nil
+	injected := stop >= 0 && int64(len(data)) > stop
+	switch {
+	case injected:
+		data = data[:stop]
+		interrupted = true
+	case int64(len(data)) > budget:
+		return false, tusError(c, http.StatusRequestEntityTooLarge, "Data exceeds the upload length", strconv.FormatInt(budget, 10))
+	}
+
+	s.mutex.Lock()
+	sum := sha256.Sum256(data)
+	u.data = append(u.data, data...)
+	u.Offset += int64(len(data))
+	u.Chunks = append(u.Chunks, Chunk{
+		Offset:      offset,
+		Length:      int64(len(data)),
+		SHA256:      hex.EncodeToString(sum[:]),
+		ReceivedAt:  time.Now(),
+		Interrupted: interrupted,
+	})
+	if injected {
+		u.InterruptAt = u.InterruptAt[1:]
+		for len(u.InterruptAt) > 0 && u.InterruptAt[0] <= u.Offset {
+			u.InterruptAt = u.InterruptAt[1:]
+		}
+	}
+	newOffset := u.Offset
+	u.Complete = u.Length != nil && u.Offset == *u.Length
+	complete := u.Complete
+	s.mutex.Unlock()
+
+	if injected {
+		logger.DebugContext(c.Request().Context(), "Dropping the connection", "upload_id", u.ID, "offset", newOffset)
+		dropConnection(c)
+		return true, nil
+	}
+	if readErr != nil {
+		logger.DebugContext(c.Request().Context(), "Upload interrupted", "upload_id", u.ID, "offset", newOffset, "error", readErr)
+		return true, nil
+	}
+	if complete {
+		logger.DebugContext(c.Request().Context(), "Upload completed", "upload_id", u.ID, "bytes", newOffset)
+	}
+	c.Response().Header().Set("Upload-Offset", strconv.FormatInt(newOffset, 10))
+	return false, nil
+}
+
+// dropConnection closes the client connection without a response.
+func dropConnection(c echo.Context) {
+	conn, _, err := http.NewResponseController(c.Response()).Hijack()
+	if err != nil {
+		// HTTP/2 streams are reset instead
+		panic(http.ErrAbortHandler)
+	}
+	conn.Close()
+}
+
+func (s *Server) upload(id string) *upload {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.uploads[id]
+}
+
+// Uploads returns the uploads, oldest first.
+func (s *Server) Uploads() []Upload {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	uploads := make([]Upload, 0, len(s.order))
+	for _, id := range s.order {
+		uploads = append(uploads, s.uploads[id].snapshot())
+	}
+	return uploads
+}
+
+// Upload returns an upload and its data.
+func (s *Server) Upload(id string) (Upload, []byte, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	u, ok := s.uploads[id]
+	if !ok {
+		return Upload{}, nil, false
+	}
+	return u.snapshot(), append([]byte(nil), u.data...), true
+}
+
+// Remove deletes an upload.
+func (s *Server) Remove(id string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, ok := s.uploads[id]; !ok {
+		return false
+	}
+	delete(s.uploads, id)
+	for i, other := range s.order {
+		if other == id {
+			s.order = append(s.order[:i], s.order[i+1:]...)
+			break
+		}
+	}
+	logger.Info("Upload removed", "upload_id", id)
+	return true
+}
+
+// Reset deletes every upload.
+func (s *Server) Reset() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.uploads = make(map[string]*upload)
+	s.order = nil
+	logger.Info("All uploads removed")
+}
+
+func (u *upload) snapshot() Upload {
+	view := u.Upload
+	view.Chunks = append([]Chunk{}, u.Chunks...)
+	view.InterruptAt = append([]int64(nil), u.InterruptAt...)
+	return view
+}
+
+// parseMetadata decodes Upload-Metadata: comma-separated keys, each followed
+// by a space and its base64 value unless it has none.
+func parseMetadata(header string) (map[string]string, error) {
+	if strings.TrimSpace(header) == "" {
+		return nil, nil
+	}
+	metadata := make(map[string]string)
+	for _, pair := range strings.Split(header, ",") {
+		key, encoded, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			return nil, fmt.Errorf("empty key")
+		}
+		value, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", key, err)
+		}
+		metadata[key] = string(value)
+	}
+	return metadata, nil
+}
+
+func formatMetadata(metadata map[string]string) string {
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		pairs[i] = key
+		if value := metadata[key]; value != "" {
+			pairs[i] += " " + base64.StdEncoding.EncodeToString([]byte(value))
+		}
+	}
+	return strings.Join(pairs, ",")
+}
+
+func parseOffsets(value string) ([]int64, error) {
+	var offsets []int64
+	for _, field := range strings.Split(value, ",") {
+		offset, err := strconv.ParseInt(strings.TrimSpace(field), 10, 64)
+		if err != nil || offset <= 0 {
+			return nil, fmt.Errorf("invalid offset %q", field)
+		}
+		offsets = append(offsets, offset)
+	}
+	return offsets, nil
+}
+
+func describeLength(length *int64) string {
+	if length == nil {
+		return "deferred"
+	}
+	return strconv.FormatInt(*length, 10)
+}
+
+func tusError(c echo.Context, status int, message, provided string) error {
+	body := map[string]interface{}{
+		"error":     message,
+		"timestamp": time.Now().Unix(),
+	}
+	if provided != "" {
+		body["provided"] = provided
+	}
+	return c.JSON(status, body)
+}
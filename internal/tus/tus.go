@@ -0,0 +1,89 @@
+// Package tus is a mock server of the tus resumable upload protocol
+// (https://tus.io, version 1.0.0, with the creation, creation-with-upload,
+// creation-defer-length and termination extensions). Connections can be
+// dropped at given byte offsets to exercise the resume logic of clients,
+// and the chunks received are kept for inspection.
+package tus
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Version is the protocol version served.
+const Version = "1.0.0"
+
+// Extensions are the protocol extensions supported.
+const Extensions = "creation,creation-with-upload,creation-defer-length,termination"
+
+// InterruptHeader lists, on the creation request, the byte offsets at which
+// the connections uploading a file are dropped ("1024,65536"), instead of
+// the configured ones.
+const InterruptHeader = "X-Mock-Tus-Interrupt"
+
+const (
+	defaultPath    = "/files"
+	defaultMaxSize = 64 * 1024 * 1024
+)
+
+// Config holds the upload server settings; TUS_ENABLED=true also enables it.
+type Config struct {
+	Enabled bool `json:"enabled"`
+	// Path is the base path of the uploads, /files by default.
+	Path string `json:"path,omitempty"`
+	// MaxSize is the largest upload accepted, in bytes (default 64 MiB).
+	// Uploads are kept in memory.
+	MaxSize int64 `json:"max_size,omitempty"`
+	// InterruptAt lists the byte offsets at which the connection uploading a
+	// file is dropped, once each per upload: the bytes up to the offset are
+	// stored and the connection is closed without a response.
+	InterruptAt []int64 `json:"interrupt_at,omitempty"`
+}
+
+// Validate checks the path, size and interruption offsets.
+func (c Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.Path != "" && (!strings.HasPrefix(c.Path, "/") || strings.HasPrefix(c.Path, "/__admin")) {
+		return fmt.Errorf("tus: invalid path %q", c.Path)
+	}
+	if c.MaxSize < 0 {
+		return fmt.Errorf("tus: invalid max_size %d", c.MaxSize)
+	}
+	for _, offset := range c.InterruptAt {
+		if offset <= 0 {
+			return fmt.Errorf("tus: invalid interruption offset %d", offset)
+		}
+	}
+	return nil
+}
+
+func (c Config) path() string {
+	if c.Path == "" {
+		return defaultPath
+	}
+	return strings.TrimRight(c.Path, "/")
+}
+
+func (c Config) maxSize() int64 {
+	if c.MaxSize == 0 {
+		return defaultMaxSize
+	}
+	return c.MaxSize
+}
+
+// sortedOffsets returns the interruption offsets in increasing order,
+// without duplicates.
+func sortedOffsets(offsets []int64) []int64 {
+	sorted := append([]int64(nil), offsets...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	unique := sorted[:0]
+	for i, offset := range sorted {
+		if i == 0 || offset != sorted[i-1] {
+			unique = append(unique, offset)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,222 @@
+// Package udp is a UDP listener that echoes datagrams or answers them by
+// rules, with simulated packet loss and delay, for clients of UDP protocols
+// such as metrics agents and discovery protocols.
+package udp
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/rand"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// DefaultMaxPackets is how many datagrams the admin API keeps by default.
+const DefaultMaxPackets = 1000
+
+// Config holds the UDP settings from the configuration file.
+type Config struct {
+	// Rules answer the datagrams they match; the first matching rule wins.
+	Rules []Rule `json:"rules,omitempty"`
+	// Unmatched is what happens to datagrams no rule matches: "echo" (the
+	// default) sends them back, "drop" ignores them.
+	Unmatched string `json:"unmatched,omitempty"`
+	// LossRate is the probability, from 0 to 1, that an answer is lost.
+	LossRate float64 `json:"loss_rate,omitempty"`
+	// Delay holds every answer back, fixed ("200ms") or random within a
+	// range ("100ms-2s").
+	Delay string `json:"delay,omitempty"`
+	// MaxPackets is how many datagrams the admin API keeps (default 1000).
+	MaxPackets int `json:"max_packets,omitempty"`
+}
+
+// Rule answers the datagrams it matches. Match and MatchHex match the
+// start of a datagram and MatchRegex any part of it (anchor it with ^ and
+// $ to match it whole); a rule without any matches every datagram.
+// Respond and RespondHex answer with other bytes, Respond expanding ${1} or
+// ${name} to the groups of MatchRegex; Drop answers nothing; a rule with
+// none of them echoes the datagram. Delay and LossRate override the
+// settings of the listener.
+type Rule struct {
+	Match      string   `json:"match,omitempty"`
+	MatchHex   string   `json:"match_hex,omitempty"`
+	MatchRegex string   `json:"match_regex,omitempty"`
+	Respond    string   `json:"respond,omitempty"`
+	RespondHex string   `json:"respond_hex,omitempty"`
+	Drop       bool     `json:"drop,omitempty"`
+	Delay      string   `json:"delay,omitempty"`
+	LossRate   *float64 `json:"loss_rate,omitempty"`
+}
+
+// rule is a compiled Rule.
+type rule struct {
+	prefix   []byte
+	pattern  *regexp.Regexp
+	respond  []byte
+	template bool
+	drop     bool
+	delay    *durationRange
+	lossRate *float64
+}
+
+// durationRange is a fixed duration when min equals max.
+type durationRange struct {
+	min, max time.Duration
+}
+
+// parseDurationRange parses "200ms" or "100ms-2s"; "" is no delay.
+func parseDurationRange(value string) (durationRange, error) {
+	if value == "" {
+		return durationRange{}, nil
+	}
+	low, high, isRange := strings.Cut(value, "-")
+	min, err := time.ParseDuration(strings.TrimSpace(low))
+	if err != nil || min < 0 {
+		return durationRange{}, fmt.Errorf("invalid duration %q", value)
+	}
+	max := min
+	if isRange {
+		if max, err = time.ParseDuration(strings.TrimSpace(high)); err != nil || max < min {
+			return durationRange{}, fmt.Errorf("invalid duration range %q", value)
+		}
+	}
+	return durationRange{min: min, max: max}, nil
+}
+
+func (d durationRange) pick() time.Duration {
+	if d.max <= d.min {
+		return d.min
+	}
+	return d.min + time.Duration(rand.Int63n(int64(d.max-d.min)+1))
+}
+
+// responder is a compiled configuration.
+type responder struct {
+	rules      []rule
+	drop       bool
+	lossRate   float64
+	delay      durationRange
+	maxPackets int
+}
+
+func (c Config) compile() (*responder, error) {
+	r := &responder{lossRate: c.LossRate, maxPackets: c.MaxPackets}
+	switch c.Unmatched {
+	case "", "echo":
+	case "drop":
+		r.drop = true
+	default:
+		return nil, fmt.Errorf("invalid unmatched %q (want echo or drop)", c.Unmatched)
+	}
+	if err := checkRate(c.LossRate); err != nil {
+		return nil, err
+	}
+	delay, err := parseDurationRange(c.Delay)
+	if err != nil {
+		return nil, fmt.Errorf("delay: %w", err)
+	}
+	r.delay = delay
+	if c.MaxPackets < 0 {
+		return nil, errors.New("max_packets must not be negative")
+	}
+	if r.maxPackets == 0 {
+		r.maxPackets = DefaultMaxPackets
+	}
+	for i, rl := range c.Rules {
+		compiled, err := rl.compile()
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i+1, err)
+		}
+		r.rules = append(r.rules, compiled)
+	}
+	return r, nil
+}
+
+func checkRate(rate float64) error {
+	if rate < 0 || rate > 1 {
+		return fmt.Errorf("loss_rate must be between 0 and 1, got %v", rate)
+	}
+	return nil
+}
+
+func (r Rule) compile() (rule, error) {
+	var compiled rule
+	matches := 0
+	if r.Match != "" {
+		matches++
+		compiled.prefix = []byte(r.Match)
+	}
+	if r.MatchHex != "" {
+		matches++
+		data, err := hex.DecodeString(r.MatchHex)
+		if err != nil {
+			return compiled, fmt.Errorf("invalid match_hex: %w", err)
+		}
+		compiled.prefix = data
+	}
+	if r.MatchRegex != "" {
+		matches++
+		pattern, err := regexp.Compile(r.MatchRegex)
+		if err != nil {
+			return compiled, fmt.Errorf("invalid match_regex: %w", err)
+		}
+		compiled.pattern = pattern
+	}
+	if matches > 1 {
+		return compiled, errors.New("a rule takes at most one of match, match_hex and match_regex")
+	}
+
+	answers := 0
+	if r.Respond != "" {
+		answers++
+		compiled.respond = []byte(r.Respond)
+		compiled.template = compiled.pattern != nil
+	}
+	if r.RespondHex != "" {
+		answers++
+		data, err := hex.DecodeString(r.RespondHex)
+		if err != nil {
+			return compiled, fmt.Errorf("invalid respond_hex: %w", err)
+		}
+		compiled.respond = data
+	}
+	if r.Drop {
+		answers++
+		compiled.drop = true
+	}
+	if answers > 1 {
+		return compiled, errors.New("a rule takes at most one of respond, respond_hex and drop")
+	}
+
+	if r.Delay != "" {
+		delay, err := parseDurationRange(r.Delay)
+		if err != nil {
+			return compiled, fmt.Errorf("delay: %w", err)
+		}
+		compiled.delay = &delay
+	}
+	if r.LossRate != nil {
+		if err := checkRate(*r.LossRate); err != nil {
+			return compiled, err
+		}
+		compiled.lossRate = r.LossRate
+	}
+	return compiled, nil
+}
+
+// match reports whether the rule matches a datagram, with the submatch
+// indices of its regular expression.
+func (r *rule) match(data []byte) (bool, []int) {
+	switch {
+	case r.pattern != nil:
+		loc := r.pattern.FindSubmatchIndex(data)
+		return loc != nil, loc
+	case r.prefix != nil:
+		return bytes.HasPrefix(data, r.prefix), nil
+	default:
+		return true, nil
+	}
+}
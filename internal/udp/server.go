@@ -0,0 +1,185 @@
+package udp
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"mockserver/internal/logging"
+	"mockserver/internal/panics"
+)
+
+var logger = logging.For("udp")
+
+// Packet outcomes.
+const (
+	OutcomeEchoed    = "echoed"
+	OutcomeResponded = "responded"
+	OutcomeDropped   = "dropped"
+	// OutcomeLost: the answer was lost on purpose (loss_rate).
+	OutcomeLost = "lost"
+)
+
+// maxDatagram is the largest UDP payload.
+const maxDatagram = 65535
+
+// Packet is a datagram received, with what was done about it.
+type Packet struct {
+	ID         string    `json:"id"`
+	Peer       string    `json:"peer"`
+	ReceivedAt time.Time `json:"received_at"`
+	Size       int       `json:"size"`
+	Data       string    `json:"data"`
+	DataHex    string    `json:"data_hex"`
+	// Rule is the number of the rule that matched, from 1; 0 when none did.
+	Rule    int    `json:"rule"`
+	Outcome string `json:"outcome"`
+	// DelayMs is how long the answer was held back.
+	DelayMs     int64  `json:"delay_ms,omitempty"`
+	Response    string `json:"response,omitempty"`
+	ResponseHex string `json:"response_hex,omitempty"`
+}
+
+// Server is the UDP listener with the history of the datagrams it received.
+type Server struct {
+	responder *responder
+
+	nextID int
+	// packets are in the order they were received.
+	packets []Packet
+	mutex   sync.Mutex
+	panics  *panics.Recorder
+}
+
+// NewServer validates the configuration.
+func NewServer(cfg Config) (*Server, error) {
+	compiled, err := cfg.compile()
+	if err != nil {
+		return nil, err
+	}
+	return &Server{responder: compiled}, nil
+}
+
+// SetPanics keeps the panics contained on the connections in recorder; it
+// is called before Serve.
+func (s *Server) SetPanics(recorder *panics.Recorder) {
+	s.panics = recorder
+}
+
+// Serve answers datagrams until the connection is closed.
+func (s *Server) Serve(conn net.PacketConn) error {
+	buf := make([]byte, maxDatagram)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		s.serveDatagram(conn, append([]byte(nil), buf[:n]...), addr)
+	}
+}
+
+// serveDatagram records a datagram and answers it. A panic doing so is
+// contained to the datagram.
+func (s *Server) serveDatagram(conn net.PacketConn, data []byte, addr net.Addr) {
+	defer s.panics.Contain(context.Background(), panics.ProtocolUDP, addr.String())
+	packet, response, delay := s.answer(data)
+	packet.Peer = addr.String()
+	s.record(&packet)
+	logger.Debug("Packet received", "packet_id", packet.ID, "bytes", packet.Size, "peer", packet.Peer, "outcome", packet.Outcome)
+	if response == nil {
+		return
+	}
+	send := func() {
+		if _, err := conn.WriteTo(response, addr); err != nil && !errors.Is(err, net.ErrClosed) {
+			logger.Debug("Cannot answer packet", "packet_id", packet.ID, "error", err)
+		}
+	}
+	if delay > 0 {
+		time.AfterFunc(delay, send)
+	} else {
+		send()
+	}
+}
+
+// answer applies the rules to a datagram and returns the answer, nil when
+// none is sent.
+func (s *Server) answer(data []byte) (Packet, []byte, time.Duration) {
+	r := s.responder
+	packet := Packet{
+		ReceivedAt: time.Now(),
+		Size:       len(data),
+		Data:       string(data),
+		DataHex:    hex.EncodeToString(data),
+		Outcome:    OutcomeEchoed,
+	}
+	response, drop := data, r.drop
+	delay, lossRate := r.delay, r.lossRate
+	for i := range r.rules {
+		rl := &r.rules[i]
+		matched, groups := rl.match(data)
+		if !matched {
+			continue
+		}
+		packet.Rule, drop = i+1, rl.drop
+		if rl.respond != nil {
+			response = rl.respond
+			if rl.template {
+				response = rl.pattern.Expand(nil, rl.respond, data, groups)
+			}
+			packet.Outcome = OutcomeResponded
+		}
+		if rl.delay != nil {
+			delay = *rl.delay
+		}
+		if rl.lossRate != nil {
+			lossRate = *rl.lossRate
+		}
+		break
+	}
+
+	switch {
+	case drop:
+		packet.Outcome = OutcomeDropped
+		return packet, nil, 0
+	case lossRate > 0 && rand.Float64() < lossRate:
+		packet.Outcome = OutcomeLost
+		return packet, nil, 0
+	}
+	wait := delay.pick()
+	packet.DelayMs = wait.Milliseconds()
+	packet.Response, packet.ResponseHex = string(response), hex.EncodeToString(response)
+	return packet, response, wait
+}
+
+func (s *Server) record(packet *Packet) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.nextID++
+	packet.ID = fmt.Sprintf("pkt-%d", s.nextID)
+	s.packets = append(s.packets, *packet)
+	if excess := len(s.packets) - s.responder.maxPackets; excess > 0 {
+		s.packets = append(s.packets[:0:0], s.packets[excess:]...)
+	}
+}
+
+// Packets returns the datagrams received, oldest first.
+func (s *Server) Packets() []Packet {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]Packet{}, s.packets...)
+}
+
+// Reset forgets the datagrams received.
+func (s *Server) Reset() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.packets = nil
+}
@@ -0,0 +1,154 @@
+package verify
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+
+	"mockserver/internal/journal"
+)
+
+// suiteName names the report in CI test results.
+const suiteName = "mockserver.verifications"
+
+// Result is the outcome of one spec.
+type Result struct {
+	Spec     Spec     `json:"spec"`
+	Name     string   `json:"name"`
+	Passed   bool     `json:"passed"`
+	Expected string   `json:"expected"`
+	Matched  int      `json:"matched"`
+	Requests []string `json:"requests,omitempty"`
+	Message  string   `json:"message,omitempty"`
+}
+
+// Report is the outcome of evaluating every stored spec.
+type Report struct {
+	Name       string    `json:"name"`
+	Timestamp  time.Time `json:"timestamp"`
+	DurationMs float64   `json:"duration_ms"`
+	Tests      int       `json:"tests"`
+	Failures   int       `json:"failures"`
+	Results    []Result  `json:"results"`
+}
+
+// Evaluate checks every stored spec against the journal.
+func (s *Store) Evaluate(j *journal.Journal) Report {
+	start := time.Now()
+	report := Report{Name: suiteName, Timestamp: start, Results: []Result{}}
+
+	for _, spec := range s.List() {
+		entries := j.Find(spec.Filter())
+		result := Result{
+			Spec:     spec,
+			Name:     spec.describe(),
+			Passed:   spec.check(len(entries)),
+			Expected: spec.expectation(),
+			Matched:  len(entries),
+		}
+		for _, entry := range entries {
+			result.Requests = append(result.Requests, entry.ID)
+		}
+		if !result.Passed {
+			result.Message = fmt.Sprintf("expected %s matching request(s), got %d", result.Expected, result.Matched)
+			report.Failures++
+		}
+		report.Results = append(report.Results, result)
+	}
+
+	report.Tests = len(report.Results)
+	report.DurationMs = float64(time.Since(start).Microseconds()) / 1000
+	return report
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// JUnit renders the report as JUnit XML, one test case per spec.
+func (r Report) JUnit() ([]byte, error) {
+	suite := junitTestSuite{
+		Name:      r.Name,
+		Tests:     r.Tests,
+		Failures:  r.Failures,
+		Time:      fmt.Sprintf("%.3f", r.DurationMs/1000),
+		Timestamp: r.Timestamp.UTC().Format("2006-01-02T15:04:05"),
+	}
+	for _, result := range r.Results {
+		testCase := junitTestCase{
+			Name:      result.Name,
+			Classname: r.Name,
+			Time:      "0.000",
+		}
+		if !result.Passed {
+			text := fmt.Sprintf("%s\nfilter: %s", result.Message, describeFilter(result.Spec))
+			if len(result.Requests) > 0 {
+				text += "\nmatched: " + strings.Join(result.Requests, ", ")
+			}
+			testCase.Failure = &junitFailure{
+				Message: result.Message,
+				Type:    "VerificationFailure",
+				Text:    text,
+			}
+		}
+		suite.Cases = append(suite.Cases, testCase)
+	}
+
+	data, err := xml.MarshalIndent(junitTestSuites{
+		Tests:    r.Tests,
+		Failures: r.Failures,
+		Suites:   []junitTestSuite{suite},
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(data, '\n')...), nil
+}
+
+func describeFilter(s Spec) string {
+	var parts []string
+	for _, field := range []struct{ name, value string }{
+		{"protocol", s.Protocol},
+		{"method", s.Method},
+		{"path", s.Path},
+		{"code", s.Code},
+	} {
+		if field.value != "" {
+			parts = append(parts, field.name+"="+field.value)
+		}
+	}
+	if s.Status != 0 {
+		parts = append(parts, fmt.Sprintf("status=%d", s.Status))
+	}
+	if len(parts) == 0 {
+		return "any request"
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,214 @@
+// Package verify keeps stored verification specs, expectations on the
+// requests recorded in the journal, and evaluates them into reports that CI
+// can consume.
+package verify
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"mockserver/internal/journal"
+	"mockserver/internal/logging"
+)
+
+var logger = logging.For("verify")
+
+// Spec expects a number of journal entries matching its filter fields. Count
+// asks for an exact number; AtLeast and AtMost bound it. Without any of them
+// at least one matching request is expected.
+type Spec struct {
+	ID       string `json:"id,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Protocol string `json:"protocol,omitempty"`
+	Method   string `json:"method,omitempty"`
+	Path     string `json:"path,omitempty"`
+	Status   int    `json:"status,omitempty"`
+	Code     string `json:"code,omitempty"`
+	Count    *int   `json:"count,omitempty"`
+	AtLeast  *int   `json:"at_least,omitempty"`
+	AtMost   *int   `json:"at_most,omitempty"`
+}
+
+func (s Spec) validate() error {
+	for name, value := range map[string]*int{"count": s.Count, "at_least": s.AtLeast, "at_most": s.AtMost} {
+		if value != nil && *value < 0 {
+			return fmt.Errorf("%s must not be negative", name)
+		}
+	}
+	if s.Count != nil && (s.AtLeast != nil || s.AtMost != nil) {
+		return fmt.Errorf("count and at_least/at_most are mutually exclusive")
+	}
+	if s.AtLeast != nil && s.AtMost != nil && *s.AtLeast > *s.AtMost {
+		return fmt.Errorf("at_least is greater than at_most")
+	}
+	return nil
+}
+
+// Filter returns the journal filter selecting the requests the spec counts.
+func (s Spec) Filter() journal.Filter {
+	return journal.Filter{
+		Protocol: s.Protocol,
+		Method:   s.Method,
+		Path:     s.Path,
+		Status:   s.Status,
+		Code:     s.Code,
+	}
+}
+
+// check reports whether n matching requests satisfy the spec.
+func (s Spec) check(n int) bool {
+	switch {
+	case s.Count != nil:
+		return n == *s.Count
+	case s.AtLeast == nil && s.AtMost == nil:
+		return n >= 1
+	}
+	if s.AtLeast != nil && n < *s.AtLeast {
+		return false
+	}
+	if s.AtMost != nil && n > *s.AtMost {
+		return false
+	}
+	return true
+}
+
+// expectation describes the expected count, e.g. "exactly 2".
+func (s Spec) expectation() string {
+	switch {
+	case s.Count != nil:
+		return fmt.Sprintf("exactly %d", *s.Count)
+	case s.AtLeast != nil && s.AtMost != nil:
+		return fmt.Sprintf("between %d and %d", *s.AtLeast, *s.AtMost)
+	case s.AtMost != nil:
+		return fmt.Sprintf("at most %d", *s.AtMost)
+	case s.AtLeast != nil:
+		return fmt.Sprintf("at least %d", *s.AtLeast)
+	}
+	return "at least 1"
+}
+
+// describe names the spec after its filter when it has no name.
+func (s Spec) describe() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	var parts []string
+	if s.Protocol != "" {
+		parts = append(parts, s.Protocol)
+	}
+	if s.Method != "" {
+		parts = append(parts, strings.ToUpper(s.Method))
+	}
+	if s.Path != "" {
+		parts = append(parts, s.Path+"*")
+	}
+	if s.Status != 0 {
+		parts = append(parts, fmt.Sprintf("status %d", s.Status))
+	}
+	if s.Code != "" {
+		parts = append(parts, "code "+s.Code)
+	}
+	if len(parts) == 0 {
+		parts = append(parts, "any request")
+	}
+	return fmt.Sprintf("%s received %s time(s)", strings.Join(parts, " "), s.expectation())
+}
+
+// Store holds the verification specs registered through the configuration
+// file and the admin API.
+type Store struct {
+	specs  []Spec
+	nextID int
+	mutex  sync.RWMutex
+}
+
+func NewStore() *Store {
+	return &Store{}
+}
+
+// Add validates and stores a spec, assigning an ID when it has none. A spec
+// with an existing ID replaces it.
+func (s *Store) Add(spec Spec) (Spec, error) {
+	if err := spec.validate(); err != nil {
+		return Spec{}, fmt.Errorf("verification %s: %w", spec.describe(), err)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.addLocked(spec), nil
+}
+
+func (s *Store) addLocked(spec Spec) Spec {
+	if spec.ID == "" {
+		s.nextID++
+		spec.ID = fmt.Sprintf("verify-%d", s.nextID)
+	}
+	for i := range s.specs {
+		if s.specs[i].ID == spec.ID {
+			s.specs[i] = spec
+			logger.Info("Verification replaced", "verification_id", spec.ID, "expectation", spec.describe())
+			return spec
+		}
+	}
+	s.specs = append(s.specs, spec)
+	logger.Info("Verification registered", "verification_id", spec.ID, "expectation", spec.describe())
+	return spec
+}
+
+// Swap removes the specs with the given IDs and adds specs in one step.
+// Nothing changes when one of the specs is invalid.
+func (s *Store) Swap(remove []string, add []Spec) ([]Spec, error) {
+	for _, spec := range add {
+		if err := spec.validate(); err != nil {
+			return nil, fmt.Errorf("verification %s: %w", spec.describe(), err)
+		}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	kept := s.specs[:0:0]
+	for _, spec := range s.specs {
+		if !slices.Contains(remove, spec.ID) {
+			kept = append(kept, spec)
+		}
+	}
+	s.specs = kept
+	added := make([]Spec, len(add))
+	for i, spec := range add {
+		added[i] = s.addLocked(spec)
+	}
+	return added, nil
+}
+
+// Remove deletes a spec by ID.
+func (s *Store) Remove(id string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for i := range s.specs {
+		if s.specs[i].ID == id {
+			s.specs = append(s.specs[:i], s.specs[i+1:]...)
+			logger.Info("Verification removed", "verification_id", id)
+			return true
+		}
+	}
+	return false
+}
+
+// Reset removes every spec.
+func (s *Store) Reset() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.specs = nil
+	logger.Info("All verifications removed")
+}
+
+// List returns the specs in registration order.
+func (s *Store) List() []Spec {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return append([]Spec{}, s.specs...)
+}
@@ -0,0 +1,121 @@
+package websocket
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo/v4"
+)
+
+// AuthConfig requires a token on the WebSocket handshake, passed as the
+// token query parameter (browsers cannot set headers on a WebSocket) or as
+// an Authorization header, with or without the Bearer scheme.
+type AuthConfig struct {
+	// Tokens are the accepted tokens; authentication is off when empty.
+	Tokens []string `json:"tokens,omitempty"`
+	// Endpoints limits authentication to the listed endpoints (echo,
+	// broadcast, chat, subprotocol, stream, scenario and socketio); all when
+	// empty.
+	Endpoints []string `json:"endpoints,omitempty"`
+	// CloseCode, when set, completes unauthenticated handshakes and closes
+	// them right away with this code (e.g. 1008 or 4401) instead of
+	// answering 401.
+	CloseCode int `json:"close_code,omitempty"`
+}
+
+func (a AuthConfig) validate() error {
+	for _, endpoint := range a.Endpoints {
+		if !endpoints[endpoint] {
+			return fmt.Errorf("auth: unknown endpoint %q", endpoint)
+		}
+	}
+	if a.CloseCode != 0 && !validCloseCode(a.CloseCode) {
+		return fmt.Errorf("auth: invalid close_code %d", a.CloseCode)
+	}
+	return nil
+}
+
+// required reports whether handshakes on an endpoint need a token.
+func (a AuthConfig) required(endpoint string) bool {
+	if len(a.Tokens) == 0 {
+		return false
+	}
+	if len(a.Endpoints) == 0 {
+		return true
+	}
+	for _, e := range a.Endpoints {
+		if e == endpoint {
+			return true
+		}
+	}
+	return false
+}
+
+// accepts reports whether a token is one of the configured tokens.
+func (a AuthConfig) accepts(token string) bool {
+	accepted := false
+	for _, t := range a.Tokens {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			accepted = true
+		}
+	}
+	return accepted
+}
+
+// handshakeToken returns the token of a handshake request, "" without one.
+func handshakeToken(r *http.Request) string {
+	if token := r.URL.Query().Get("token"); token != "" {
+		return token
+	}
+	header := strings.TrimSpace(r.Header.Get("Authorization"))
+	if scheme, token, ok := strings.Cut(header, " "); ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+	return header
+}
+
+// authenticate checks the token of a handshake. When it is missing or not
+// accepted, the handshake is refused (401, or closed with the configured
+// code) and false is returned along with the handler's result.
+func (h *WebSocketHandlers) authenticate(c echo.Context, endpoint string) (bool, error) {
+	auth := h.config.Auth
+	if !auth.required(endpoint) {
+		return true, nil
+	}
+	token := handshakeToken(c.Request())
+	if token != "" && auth.accepts(token) {
+		return true, nil
+	}
+
+	details := "Missing token"
+	if token != "" {
+		details = "Invalid token"
+	}
+	if auth.CloseCode != 0 {
+		ws, err := h.upgrader.Upgrade(c.Response(), c.Request(), nil)
+		if err != nil {
+			logger.DebugContext(c.Request().Context(), "Upgrade failed", "error", err)
+			return false, err
+		}
+		logger.InfoContext(c.Request().Context(), "Handshake unauthorized, closing", "reason", details, "endpoint", endpoint, "close_code", auth.CloseCode)
+		ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(auth.CloseCode, strings.ToLower(details)), time.Now().Add(writeWait))
+		ws.Close()
+		return false, nil
+	}
+
+	logger.InfoContext(c.Request().Context(), "Handshake unauthorized, rejecting", "reason", details, "endpoint", endpoint)
+	challenge := `Bearer realm="mockserver"`
+	if token != "" {
+		challenge += `, error="invalid_token"`
+	}
+	c.Response().Header().Set("WWW-Authenticate", challenge)
+	return false, c.JSON(http.StatusUnauthorized, map[string]interface{}{
+		"error":     "Unauthorized",
+		"details":   details,
+		"timestamp": time.Now().Unix(),
+	})
+}
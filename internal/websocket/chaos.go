@@ -0,0 +1,196 @@
+package websocket
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Endpoints that chaos can be configured for.
+const (
+	EndpointEcho        = "echo"
+	EndpointBroadcast   = "broadcast"
+	EndpointChat        = "chat"
+	EndpointSubprotocol = "subprotocol"
+	EndpointStream      = "stream"
+	EndpointScenario    = "scenario"
+	EndpointSocketIO    = "socketio"
+)
+
+var endpoints = map[string]bool{
+	EndpointEcho: true, EndpointBroadcast: true, EndpointChat: true,
+	EndpointSubprotocol: true, EndpointStream: true, EndpointScenario: true,
+	EndpointSocketIO: true,
+}
+
+// ChaosConfig makes the connections of an endpoint behave like a flaky
+// network. Rates are probabilities from 0 to 1, rolled for every message
+// the server sends; durations are fixed ("200ms") or random within a range
+// ("100ms-2s").
+type ChaosConfig struct {
+	// DropRate silently discards messages.
+	DropRate float64 `json:"drop_rate,omitempty"`
+	// DuplicateRate sends messages twice.
+	DuplicateRate float64 `json:"duplicate_rate,omitempty"`
+	// Delay holds every message back; later messages wait behind it.
+	Delay string `json:"delay,omitempty"`
+	// DisconnectRate closes the connection instead of sending a message.
+	DisconnectRate float64 `json:"disconnect_rate,omitempty"`
+	// DisconnectAfter closes every connection once it has been open that long.
+	DisconnectAfter string `json:"disconnect_after,omitempty"`
+	// CloseCodes are picked from at random when disconnecting; 1006 drops
+	// the connection without a close frame, which is also the default.
+	CloseCodes []int `json:"close_codes,omitempty"`
+}
+
+type chaos struct {
+	ChaosConfig
+	delay, lifetime durationRange
+}
+
+// durationRange is a fixed duration when min equals max.
+type durationRange struct {
+	min, max time.Duration
+}
+
+func parseDurationRange(value string) (durationRange, error) {
+	if value == "" {
+		return durationRange{}, nil
+	}
+	lowText, highText, isRange := strings.Cut(value, "-")
+	low, err := time.ParseDuration(strings.TrimSpace(lowText))
+	if err != nil || low < 0 {
+		return durationRange{}, fmt.Errorf("invalid duration %q", value)
+	}
+	high := low
+	if isRange {
+		if high, err = time.ParseDuration(strings.TrimSpace(highText)); err != nil || high < low {
+			return durationRange{}, fmt.Errorf("invalid duration range %q", value)
+		}
+	}
+	return durationRange{min: low, max: high}, nil
+}
+
+func (r durationRange) pick() time.Duration {
+	if r.max <= r.min {
+		return r.min
+	}
+	return r.min + time.Duration(rand.Int63n(int64(r.max-r.min)+1))
+}
+
+func compileChaos(config map[string]ChaosConfig) (map[string]*chaos, error) {
+	compiled := make(map[string]*chaos, len(config))
+	for endpoint, cfg := range config {
+		if !endpoints[endpoint] {
+			return nil, fmt.Errorf("chaos: unknown endpoint %q", endpoint)
+		}
+		c, err := newChaos(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("chaos for %s: %w", endpoint, err)
+		}
+		compiled[endpoint] = c
+	}
+	return compiled, nil
+}
+
+func newChaos(cfg ChaosConfig) (*chaos, error) {
+	for name, rate := range map[string]float64{
+		"drop_rate": cfg.DropRate, "duplicate_rate": cfg.DuplicateRate, "disconnect_rate": cfg.DisconnectRate,
+	} {
+		if rate < 0 || rate > 1 {
+			return nil, fmt.Errorf("%s must be between 0 and 1", name)
+		}
+	}
+	for _, code := range cfg.CloseCodes {
+		if code != websocket.CloseAbnormalClosure && !validCloseCode(code) {
+			return nil, fmt.Errorf("invalid close code %d", code)
+		}
+	}
+	c := &chaos{ChaosConfig: cfg}
+	var err error
+	if c.delay, err = parseDurationRange(cfg.Delay); err != nil {
+		return nil, fmt.Errorf("delay: %w", err)
+	}
+	if c.lifetime, err = parseDurationRange(cfg.DisconnectAfter); err != nil {
+		return nil, fmt.Errorf("disconnect_after: %w", err)
+	}
+	return c, nil
+}
+
+// validCloseCode reports whether a code may be sent in a close frame.
+func validCloseCode(code int) bool {
+	switch code {
+	case 1000, 1001, 1002, 1003, 1007, 1008, 1009, 1010, 1011, 1012, 1013, 1014:
+		return true
+	}
+	return code >= 3000 && code <= 4999
+}
+
+func (c *chaos) closeCode() int {
+	if len(c.CloseCodes) == 0 {
+		return websocket.CloseAbnormalClosure
+	}
+	return c.CloseCodes[rand.Intn(len(c.CloseCodes))]
+}
+
+// chaosDisconnect ends a connection the way the chaos configuration says.
+func (c *client) chaosDisconnect(reason string) {
+	code := c.chaos.closeCode()
+	c.log.Debug("Chaos disconnecting", "close_code", code, "reason", reason)
+	c.closeWithCode(code, "chaos")
+}
+
+// chaosCopies returns how often a message should be written: 0 drops it.
+// It also applies the delay and returns -1 when the connection must be
+// closed instead.
+func (c *client) chaosCopies() int {
+	if rand.Float64() < c.chaos.DisconnectRate {
+		return -1
+	}
+	if rand.Float64() < c.chaos.DropRate {
+		return 0
+	}
+	copies := 1
+	if rand.Float64() < c.chaos.DuplicateRate {
+		copies = 2
+	}
+	if delay := c.chaos.delay.pick(); delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-c.done:
+			return 0
+		}
+	}
+	return copies
+}
+
+// ChaosEndpoints lists the endpoints chaos is configured for.
+func (h *WebSocketHandlers) ChaosEndpoints() []string {
+	names := make([]string, 0, len(h.hub.chaos))
+	for endpoint := range h.hub.chaos {
+		names = append(names, endpoint)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ChaosEnabled reports whether the configured chaos is applied.
+func (h *WebSocketHandlers) ChaosEnabled() bool {
+	return !h.hub.chaosPaused.Load()
+}
+
+// SetChaosEnabled pauses or resumes the configured chaos on every
+// connection, open ones included. While paused, messages are delivered
+// normally and disconnect_after timers that expire are ignored.
+func (h *WebSocketHandlers) SetChaosEnabled(enabled bool) {
+	if h.hub.chaosPaused.Swap(!enabled) == !enabled {
+		return
+	}
+	logger.Info("Chaos toggled", "enabled", enabled)
+}
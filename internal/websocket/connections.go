@@ -0,0 +1,115 @@
+package websocket
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// ErrUnknownConnection is returned for a connection ID that is not open.
+var ErrUnknownConnection = errors.New("websocket: unknown connection")
+
+// ConnectionInfo describes an open connection. Room is set for chat clients
+// once they joined.
+type ConnectionInfo struct {
+	ID          string    `json:"id"`
+	Endpoint    string    `json:"endpoint"`
+	Room        string    `json:"room,omitempty"`
+	Username    string    `json:"username,omitempty"`
+	RemoteAddr  string    `json:"remote_addr"`
+	Subprotocol string    `json:"subprotocol,omitempty"`
+	ConnectedAt time.Time `json:"connected_at"`
+	// Queued is the number of messages waiting to be written.
+	Queued int `json:"queued"`
+}
+
+// RoomInfo describes a chat room and its members.
+type RoomInfo struct {
+	Name        string   `json:"name"`
+	MemberCount int      `json:"member_count"`
+	Members     []Member `json:"members"`
+}
+
+// Connections lists the open connections, oldest first.
+func (h *WebSocketHandlers) Connections() []ConnectionInfo {
+	h.hub.mutex.Lock()
+	defer h.hub.mutex.Unlock()
+	connections := make([]ConnectionInfo, 0, len(h.hub.clients))
+	for c := range h.hub.clients {
+		info := ConnectionInfo{
+			ID:          c.id,
+			Endpoint:    c.endpoint,
+			Username:    c.username,
+			RemoteAddr:  c.conn.RemoteAddr().String(),
+			Subprotocol: c.conn.Subprotocol(),
+			ConnectedAt: c.connectedAt,
+			Queued:      len(c.send),
+		}
+		if c.joined {
+			info.Room = c.room
+		}
+		connections = append(connections, info)
+	}
+	sort.Slice(connections, func(i, j int) bool {
+		return connections[i].ConnectedAt.Before(connections[j].ConnectedAt)
+	})
+	return connections
+}
+
+// Rooms lists the chat rooms that have members, sorted by name, and returns
+// the number of /ws/broadcast clients alongside.
+func (h *WebSocketHandlers) Rooms() ([]RoomInfo, int) {
+	h.hub.mutex.Lock()
+	names := make([]string, 0, len(h.hub.rooms))
+	for name := range h.hub.rooms {
+		if name != broadcastRoom {
+			names = append(names, name)
+		}
+	}
+	broadcastClients := len(h.hub.rooms[broadcastRoom])
+	h.hub.mutex.Unlock()
+
+	sort.Strings(names)
+	rooms := make([]RoomInfo, 0, len(names))
+	for _, name := range names {
+		members := h.hub.members(name)
+		if len(members) == 0 {
+			continue
+		}
+		rooms = append(rooms, RoomInfo{Name: name, MemberCount: len(members), Members: members})
+	}
+	return rooms, broadcastClients
+}
+
+// Disconnect closes a connection with the given close code and reason; 1006
+// drops it without a close frame. The endpoint handler then cleans up as if
+// the client had left, e.g. announcing the leave to a chat room.
+func (h *WebSocketHandlers) Disconnect(id string, code int, reason string) error {
+	if code != websocket.CloseAbnormalClosure && !validCloseCode(code) {
+		return fmt.Errorf("invalid close code %d", code)
+	}
+	h.hub.mutex.Lock()
+	var target *client
+	for c := range h.hub.clients {
+		if c.id == id {
+			target = c
+			break
+		}
+	}
+	h.hub.mutex.Unlock()
+	if target == nil {
+		return ErrUnknownConnection
+	}
+
+	target.log.Info("Disconnecting on request", "close_code", code)
+	target.closeWithCode(code, reason)
+	return nil
+}
+
+// RoomSize returns the number of clients in a chat room.
+func (h *WebSocketHandlers) RoomSize(room string) int {
+	return h.hub.size(room)
+}
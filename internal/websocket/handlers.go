@@ -1,40 +1,151 @@
 package websocket
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
-	"log"
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
 	"github.com/labstack/echo/v4"
+
+	"mockserver/internal/correlation"
+	"mockserver/internal/events"
+	"mockserver/internal/panics"
 )
 
-var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		return true
-	},
+// DefaultEphemeralEvents are the chat message types that are relayed to the
+// other room members as-is instead of being turned into chat messages.
+var DefaultEphemeralEvents = []string{"typing", "read_receipt", "presence"}
+
+// Config holds the WebSocket settings from the configuration file.
+type Config struct {
+	// EphemeralEvents overrides DefaultEphemeralEvents for every room.
+	EphemeralEvents []string              `json:"ephemeral_events,omitempty"`
+	Rooms           map[string]RoomConfig `json:"rooms,omitempty"`
+	// Subprotocols are the protocols /ws/subprotocol accepts, in order of
+	// preference; DefaultSubprotocols when empty.
+	Subprotocols []string `json:"subprotocols,omitempty"`
+	// Compression negotiates permessage-deflate with clients that offer it.
+	Compression CompressionConfig `json:"compression"`
+	// Scenarios are the scripted conversations of /ws/scenario/:name.
+	Scenarios map[string]Scenario `json:"scenarios,omitempty"`
+	// Chaos disturbs the connections of the endpoints it is keyed by: echo,
+	// broadcast, chat, subprotocol, stream, scenario and socketio.
+	Chaos map[string]ChaosConfig `json:"chaos,omitempty"`
+	// Limits bounds message sizes, buffers and the number of connections.
+	Limits LimitsConfig `json:"limits"`
+	// Auth requires a token on the handshake.
+	Auth AuthConfig `json:"auth"`
+	// SocketIO sets the heartbeat of /socket.io/.
+	SocketIO SocketIOConfig `json:"socketio"`
+	// Echo transforms the responses of /ws/echo.
+	Echo EchoConfig `json:"echo"`
+	// Quiet lists the endpoints (echo, broadcast, chat and subprotocol)
+	// that add nothing of their own: no welcome, join or leave messages, and
+	// frames relayed as they came rather than wrapped in a message with a
+	// timestamp. The quiet query parameter overrides it per connection.
+	Quiet []string `json:"quiet,omitempty"`
+}
+
+// CompressionConfig controls permessage-deflate. Messages smaller than
+// Threshold bytes are sent uncompressed; Level is the flate level from 1
+// (fastest, the default) to 9 (smallest).
+type CompressionConfig struct {
+	Enabled   bool `json:"enabled"`
+	Threshold int  `json:"threshold,omitempty"`
+	Level     int  `json:"level,omitempty"`
+}
+
+// RoomConfig holds per-room overrides.
+type RoomConfig struct {
+	EphemeralEvents []string `json:"ephemeral_events,omitempty"`
 }
 
 type WebSocketHandlers struct {
-	clients map[*websocket.Conn]bool
-	rooms   map[string]map[*websocket.Conn]bool
-	mutex   sync.RWMutex
+	config    Config
+	bus       *events.Bus
+	hub       *Hub
+	upgrader  websocket.Upgrader
+	scenarios map[string]*compiledScenario
+	// scenariosMutex guards scenarios, which the admin API may change.
+	scenariosMutex sync.RWMutex
+	echo           echoTransform
 }
 
-func NewWebSocketHandlers() *WebSocketHandlers {
+// NewWebSocketHandlers creates the handlers. Invalid scenarios and chaos
+// settings are skipped; Config.Validate reports them.
+func NewWebSocketHandlers(config Config, bus *events.Bus) *WebSocketHandlers {
+	hub := NewHub()
+	hub.compression = config.Compression
+	hub.chaos, _ = compileChaos(config.Chaos)
+	hub.limits = config.Limits
+	scenarios := make(map[string]*compiledScenario, len(config.Scenarios))
+	for name, scenario := range config.Scenarios {
+		if compiled, err := compileScenario(scenario); err == nil {
+			scenarios[name] = compiled
+		}
+	}
+	var echo echoTransform
+	if transform, err := config.Echo.compile(); err == nil {
+		echo = *transform
+	}
 	return &WebSocketHandlers{
-		clients: make(map[*websocket.Conn]bool),
-		rooms:   make(map[string]map[*websocket.Conn]bool),
+		config:    config,
+		bus:       bus,
+		hub:       hub,
+		scenarios: scenarios,
+		echo:      echo,
+		upgrader: websocket.Upgrader{
+			CheckOrigin: func(r *http.Request) bool {
+				return true
+			},
+			EnableCompression: config.Compression.Enabled,
+			ReadBufferSize:    config.Limits.ReadBufferSize,
+			WriteBufferSize:   config.Limits.WriteBufferSize,
+		},
+	}
+}
+
+// SetPanics keeps the panics contained on the connections in recorder.
+func (h *WebSocketHandlers) SetPanics(recorder *panics.Recorder) {
+	h.hub.panics = recorder
+}
+
+// isEphemeral reports whether a message type is an ephemeral event in the room.
+// Ephemeral events (typing indicators, read receipts, presence pings) are
+// broadcast to the other members but never stored or echoed as chat messages.
+func (h *WebSocketHandlers) isEphemeral(room, msgType string) bool {
+	events := DefaultEphemeralEvents
+	if h.config.EphemeralEvents != nil {
+		events = h.config.EphemeralEvents
+	}
+	if roomConfig, ok := h.config.Rooms[room]; ok && roomConfig.EphemeralEvents != nil {
+		events = roomConfig.EphemeralEvents
 	}
+
+	for _, event := range events {
+		if event == msgType {
+			return true
+		}
+	}
+	return false
 }
 
+// Message is the JSON frame exchanged with clients. CorrelationID ties a
+// message to the action that caused it; relayed messages take it from the
+// client message or from the connection's X-Mock-Test-ID/traceparent.
+// Username names the chat client a room message comes from.
 type Message struct {
-	Type      string      `json:"type"`
-	Data      interface{} `json:"data"`
-	Timestamp int64       `json:"timestamp"`
-	Room      string      `json:"room,omitempty"`
+	Type          string      `json:"type"`
+	Data          interface{} `json:"data"`
+	Timestamp     int64       `json:"timestamp"`
+	Room          string      `json:"room,omitempty"`
+	Username      string      `json:"username,omitempty"`
+	CorrelationID string      `json:"correlation_id,omitempty"`
 }
 
 type ErrorMessage struct {
@@ -46,9 +157,9 @@ type ErrorMessage struct {
 }
 
 // Helper function to safely read WebSocket JSON messages
-func safeReadJSON(ws *websocket.Conn) (*Message, error) {
+func safeReadJSON(conn *client) (*Message, error) {
 	// First, read the raw message
-	messageType, data, err := ws.ReadMessage()
+	messageType, data, err := conn.read()
 	if err != nil {
 		return nil, err
 	}
@@ -62,6 +173,12 @@ func safeReadJSON(ws *websocket.Conn) (*Message, error) {
 		}, nil
 	}
 
+	return parseMessage(data), nil
+}
+
+// parseMessage decodes a text frame, turning invalid JSON into a json_error
+// message
+func parseMessage(data []byte) *Message {
 	// Try to parse as JSON
 	var msg Message
 	if err := json.Unmarshal(data, &msg); err != nil {
@@ -74,7 +191,7 @@ func safeReadJSON(ws *websocket.Conn) (*Message, error) {
 				"raw_data": string(data),
 			},
 			Timestamp: time.Now().Unix(),
-		}, nil
+		}
 	}
 
 	// Set timestamp if not provided
@@ -82,89 +199,200 @@ func safeReadJSON(ws *websocket.Conn) (*Message, error) {
 		msg.Timestamp = time.Now().Unix()
 	}
 
-	return &msg, nil
+	return &msg
 }
 
-// Helper function to safely write JSON to WebSocket
-func safeWriteJSON(ws *websocket.Conn, data interface{}) error {
-	// Set a write deadline to prevent hanging
-	ws.SetWriteDeadline(time.Now().Add(10 * time.Second))
-	return ws.WriteJSON(data)
+// BinaryEnvelope describes a binary frame echoed by /ws/echo?envelope=true;
+// it is sent as a "binary" text message right before the echoed bytes.
+type BinaryEnvelope struct {
+	Size   int    `json:"size"`
+	SHA256 string `json:"sha256"`
 }
 
-// Echo WebSocket - echoes back messages with error handling
+// Echo WebSocket - echoes back messages with error handling. Binary frames
+// are echoed back unchanged, preceded by a size/checksum envelope when the
+// envelope query parameter is true. Responses go through the configured
+// echo transformations, which query parameters override per connection.
+// A quiet connection gets no welcome and every frame back as it came.
 func (h *WebSocketHandlers) Echo(c echo.Context) error {
-	ws, err := upgrader.Upgrade(c.Response(), c.Request(), nil)
+	envelope, _ := strconv.ParseBool(c.QueryParam("envelope"))
+	transform, err := h.echo.withQuery(c.QueryParams())
 	if err != nil {
-		log.Printf("WebSocket upgrade error: %v", err)
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error":     "Invalid echo parameters",
+			"details":   err.Error(),
+			"timestamp": time.Now().Unix(),
+		})
+	}
+	quiet, err := h.quiet(c, EndpointEcho)
+	if err != nil {
+		return invalidQuiet(c, err)
+	}
+
+	if ok, err := h.authenticate(c, EndpointEcho); !ok {
 		return err
 	}
-	defer ws.Close()
 
-	log.Printf("WebSocket Echo: New connection established")
+	ws, err := h.upgrader.Upgrade(c.Response(), c.Request(), nil)
+	if err != nil {
+		logger.DebugContext(c.Request().Context(), "Upgrade failed", "error", err)
+		return err
+	}
+	conn, err := h.hub.connect(c.Request().Context(), ws, EndpointEcho)
+	if err != nil {
+		return nil
+	}
+	defer h.hub.disconnect(conn)
+	defer conn.contain()
+
+	conn.log.Debug("Echo connection established", "quiet", quiet)
 
 	// Send welcome message
-	welcome := Message{
-		Type:      "welcome",
-		Data:      "Connected to Echo WebSocket. Send any JSON message to echo it back.",
-		Timestamp: time.Now().Unix(),
+	if !quiet {
+		welcome := Message{
+			Type:      "welcome",
+			Data:      "Connected to Echo WebSocket. Send any JSON or binary message to echo it back.",
+			Timestamp: time.Now().Unix(),
+		}
+		if err := conn.writeJSON(welcome); err != nil {
+			conn.log.Debug("Cannot send welcome message", "error", err)
+			return nil
+		}
 	}
-	if err := safeWriteJSON(ws, welcome); err != nil {
-		log.Printf("WebSocket Echo: Failed to send welcome message: %v", err)
-		return nil
+
+	emit := conn.writeFrame
+	if transform.active() {
+		responses := make(chan frame, sendBuffer)
+		go transform.run(conn, responses)
+		defer close(responses)
+		emit = func(messageType int, data []byte) error {
+			select {
+			case responses <- frame{messageType, data}:
+				return nil
+			case <-conn.done:
+				return errClientClosed
+			}
+		}
+	}
+	emitJSON := func(v interface{}) error {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		return emit(websocket.TextMessage, data)
 	}
 
 	for {
-		msg, err := safeReadJSON(ws)
+		messageType, data, err := conn.read()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				log.Printf("WebSocket Echo read error: %v", err)
+				conn.log.Debug("Read failed", "error", err)
 			}
 			break
 		}
 
-		log.Printf("WebSocket Echo: Received message: %+v", msg)
+		if quiet {
+			if err := echoQuiet(emit, transform, messageType, data); err != nil {
+				conn.log.Debug("Write failed", "error", err)
+				break
+			}
+			conn.log.Debug("Echoed quietly", "bytes", len(data))
+			continue
+		}
+
+		if messageType == websocket.BinaryMessage {
+			if err := echoBinary(emit, data, envelope); err != nil {
+				conn.log.Debug("Write failed", "error", err)
+				break
+			}
+			conn.log.Debug("Echoed binary message", "bytes", len(data))
+			continue
+		}
+
+		msg := parseMessage(data)
+		conn.log.Debug("Message received", "type", msg.Type)
 
 		// If it's a JSON error, send the error back as is
 		if msg.Type == "json_error" {
-			if err := safeWriteJSON(ws, msg); err != nil {
-				log.Printf("WebSocket Echo write error: %v", err)
+			if err := emitJSON(msg); err != nil {
+				conn.log.Debug("Write failed", "error", err)
 				break
 			}
-			log.Printf("WebSocket Echo: Sent JSON error response")
+			conn.log.Debug("JSON error sent back")
 			continue
 		}
 
 		// Normal echo response
 		response := Message{
 			Type:      "echo",
-			Data:      msg.Data,
+			Data:      transform.mutate(msg.Data),
 			Timestamp: time.Now().Unix(),
 		}
 
-		if err := safeWriteJSON(ws, response); err != nil {
-			log.Printf("WebSocket Echo write error: %v", err)
+		if err := emitJSON(response); err != nil {
+			conn.log.Debug("Write failed", "error", err)
 			break
 		}
 
-		log.Printf("WebSocket Echo: Sent response: %+v", response)
+		conn.log.Debug("Echo sent")
 	}
 
-	log.Printf("WebSocket Echo: Connection closed")
+	conn.log.Debug("Connection closed")
 	return nil
 }
 
-// Broadcast WebSocket - broadcasts to all connected clients with error handling
+// echoBinary sends a binary frame back, optionally preceded by its envelope
+func echoBinary(emit func(messageType int, data []byte) error, data []byte, envelope bool) error {
+	if envelope {
+		sum := sha256.Sum256(data)
+		info, err := json.Marshal(Message{
+			Type:      "binary",
+			Data:      BinaryEnvelope{Size: len(data), SHA256: hex.EncodeToString(sum[:])},
+			Timestamp: time.Now().Unix(),
+		})
+		if err != nil {
+			return err
+		}
+		if err := emit(websocket.TextMessage, info); err != nil {
+			return err
+		}
+	}
+	return emit(websocket.BinaryMessage, data)
+}
+
+// Broadcast WebSocket - broadcasts to all connected clients with error handling.
+// A quiet connection gets no welcome and its frames reach everyone as they
+// came.
 func (h *WebSocketHandlers) Broadcast(c echo.Context) error {
-	ws, err := upgrader.Upgrade(c.Response(), c.Request(), nil)
+	quiet, err := h.quiet(c, EndpointBroadcast)
 	if err != nil {
-		log.Printf("WebSocket upgrade error: %v", err)
+		return invalidQuiet(c, err)
+	}
+	if ok, err := h.authenticate(c, EndpointBroadcast); !ok {
 		return err
 	}
-	defer h.removeClient(ws)
 
-	log.Printf("WebSocket Broadcast: New connection established")
-	h.addClient(ws)
+	ws, err := h.upgrader.Upgrade(c.Response(), c.Request(), nil)
+	if err != nil {
+		logger.DebugContext(c.Request().Context(), "Upgrade failed", "error", err)
+		return err
+	}
+	conn, err := h.hub.connect(c.Request().Context(), ws, EndpointBroadcast)
+	if err != nil {
+		return nil
+	}
+	defer h.hub.disconnect(conn)
+	defer conn.contain()
+
+	conn.log.Debug("Broadcast connection established", "quiet", quiet)
+	h.hub.join(conn, broadcastRoom)
+	correlationID := correlation.FromHeader(c.Request().Header)
+
+	if quiet {
+		h.relayQuietly(conn, broadcastRoom, correlationID)
+		conn.log.Debug("Connection closed")
+		return nil
+	}
 
 	// Send welcome message
 	welcome := Message{
@@ -172,65 +400,98 @@ func (h *WebSocketHandlers) Broadcast(c echo.Context) error {
 		Data:      "Connected to Broadcast WebSocket. Your messages will be sent to all connected clients.",
 		Timestamp: time.Now().Unix(),
 	}
-	if err := safeWriteJSON(ws, welcome); err != nil {
-		log.Printf("WebSocket Broadcast: Failed to send welcome message: %v", err)
+	if err := conn.writeJSON(welcome); err != nil {
+		conn.log.Debug("Cannot send welcome message", "error", err)
 	}
 
 	for {
-		msg, err := safeReadJSON(ws)
+		msg, err := safeReadJSON(conn)
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				log.Printf("WebSocket Broadcast read error: %v", err)
+				conn.log.Debug("Read failed", "error", err)
 			}
 			break
 		}
 
-		log.Printf("WebSocket Broadcast: Received message: %+v", msg)
+		conn.log.Debug("Message received", "type", msg.Type)
 
 		// If it's a JSON error, only send back to the sender
 		if msg.Type == "json_error" {
-			if err := safeWriteJSON(ws, msg); err != nil {
-				log.Printf("WebSocket Broadcast write error: %v", err)
+			if err := conn.writeJSON(msg); err != nil {
+				conn.log.Debug("Write failed", "error", err)
 				break
 			}
-			log.Printf("WebSocket Broadcast: Sent JSON error response to sender only")
+			conn.log.Debug("JSON error sent back")
 			continue
 		}
 
 		// Normal broadcast
 		broadcast := Message{
-			Type:      "broadcast",
-			Data:      msg.Data,
-			Timestamp: time.Now().Unix(),
+			Type:          "broadcast",
+			Data:          msg.Data,
+			Timestamp:     time.Now().Unix(),
+			CorrelationID: correlated(msg, correlationID),
 		}
 
 		h.broadcastToAll(broadcast)
-		log.Printf("WebSocket Broadcast: Message broadcasted to all clients")
+		h.publish("ws.broadcast", broadcast)
 	}
 
-	log.Printf("WebSocket Broadcast: Connection closed")
+	conn.log.Debug("Connection closed")
 	return nil
 }
 
-// Chat WebSocket - room-based chat with error handling
+// Chat WebSocket - room-based chat with error handling. Clients introduce
+// themselves with the username query parameter, or join under their
+// connection ID and may name themselves with an identify message. A quiet
+// connection gets no welcome, is not announced and relays its frames to the
+// room as they came.
 func (h *WebSocketHandlers) Chat(c echo.Context) error {
 	room := c.Param("room")
 	if room == "" {
-		log.Printf("WebSocket Chat: Missing room parameter")
 		return c.JSON(http.StatusBadRequest, map[string]string{
 			"error": "Room parameter is required",
 		})
 	}
+	username := cleanUsername(c.QueryParam("username"))
+	if username == "" && c.QueryParam("username") != "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid username (at most 64 characters)",
+		})
+	}
+	quiet, err := h.quiet(c, EndpointChat)
+	if err != nil {
+		return invalidQuiet(c, err)
+	}
 
-	ws, err := upgrader.Upgrade(c.Response(), c.Request(), nil)
+	if ok, err := h.authenticate(c, EndpointChat); !ok {
+		return err
+	}
+
+	ws, err := h.upgrader.Upgrade(c.Response(), c.Request(), nil)
 	if err != nil {
-		log.Printf("WebSocket upgrade error: %v", err)
+		logger.DebugContext(c.Request().Context(), "Upgrade failed", "error", err)
 		return err
 	}
-	defer h.removeFromRoom(ws, room)
+	conn, err := h.hub.connect(c.Request().Context(), ws, EndpointChat)
+	if err != nil {
+		return nil
+	}
+	defer h.hub.disconnect(conn)
+	defer conn.contain()
 
-	log.Printf("WebSocket Chat: New connection to room '%s'", room)
-	h.addToRoom(ws, room)
+	conn.log.Debug("Chat connection established", "room", room, "quiet", quiet)
+	h.hub.join(conn, room)
+	correlationID := correlation.FromHeader(c.Request().Header)
+
+	if quiet {
+		if username != "" {
+			h.hub.setUsername(conn, username)
+		}
+		h.relayQuietly(conn, room, correlationID)
+		conn.log.Debug("Connection closed")
+		return nil
+	}
 
 	// Send welcome message to the new user
 	welcome := Message{
@@ -239,165 +500,167 @@ func (h *WebSocketHandlers) Chat(c echo.Context) error {
 		Timestamp: time.Now().Unix(),
 		Room:      room,
 	}
-	if err := safeWriteJSON(ws, welcome); err != nil {
-		log.Printf("WebSocket Chat: Failed to send welcome message: %v", err)
+	if err := conn.writeJSON(welcome); err != nil {
+		conn.log.Debug("Cannot send welcome message", "error", err)
 	}
 
-	// Send join message to room
+	// Send join message to room; without a username the client joins
+	// under its connection ID until it identifies
+	identified := username != ""
+	if identified {
+		h.hub.setUsername(conn, username)
+	} else {
+		username = conn.id
+	}
 	joinMsg := Message{
 		Type:      "join",
-		Data:      map[string]string{"message": "User joined room " + room},
+		Data:      map[string]string{"message": "User joined room " + room, "username": username},
 		Timestamp: time.Now().Unix(),
 		Room:      room,
+		Username:  username,
 	}
 	h.broadcastToRoom(room, joinMsg)
-	log.Printf("WebSocket Chat: User joined room '%s'", room)
+	conn.log.Debug("User joined room", "username", username, "room", room)
 
 	for {
-		msg, err := safeReadJSON(ws)
+		msg, err := safeReadJSON(conn)
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				log.Printf("WebSocket Chat read error: %v", err)
+				conn.log.Debug("Read failed", "error", err)
 			}
 			break
 		}
 
-		log.Printf("WebSocket Chat: Received message in room '%s': %+v", room, msg)
+		conn.log.Debug("Message received", "type", msg.Type)
 
 		// If it's a JSON error, only send back to the sender
 		if msg.Type == "json_error" {
 			msg.Room = room // Add room info to error
-			if err := safeWriteJSON(ws, msg); err != nil {
-				log.Printf("WebSocket Chat write error: %v", err)
+			if err := conn.writeJSON(msg); err != nil {
+				conn.log.Debug("Write failed", "error", err)
 				break
 			}
-			log.Printf("WebSocket Chat: Sent JSON error response to sender in room '%s'", room)
+			conn.log.Debug("JSON error sent back")
+			continue
+		}
+
+		// Without a username parameter, the client may name itself once;
+		// the room learns the new name from an identify event
+		if msg.Type == "identify" {
+			name := identifyUsername(msg.Data)
+			var problem string
+			switch {
+			case identified:
+				problem = "Username already set"
+			case name == "":
+				problem = "identify needs a username of at most 64 characters"
+			default:
+				previous := username
+				username, identified = name, true
+				h.hub.setUsername(conn, username)
+				h.broadcastToRoom(room, Message{
+					Type:      "identify",
+					Data:      map[string]string{"message": previous + " is now " + username, "username": username, "previous": previous},
+					Timestamp: time.Now().Unix(),
+					Room:      room,
+					Username:  username,
+				})
+				conn.log.Debug("User identified", "username", username, "previous", previous, "room", room)
+				continue
+			}
+			conn.writeJSON(Message{Type: "error", Data: problem, Timestamp: time.Now().Unix(), Room: room})
+			continue
+		}
+
+		// Members requests are answered to the sender only
+		if msg.Type == "members" {
+			members := h.hub.members(room)
+			conn.writeJSON(Message{
+				Type:          "members",
+				Data:          map[string]interface{}{"members": members, "count": len(members)},
+				Timestamp:     time.Now().Unix(),
+				Room:          room,
+				CorrelationID: correlated(msg, correlationID),
+			})
+			continue
+		}
+
+		// Ephemeral events go to everyone else in the room unchanged
+		if h.isEphemeral(room, msg.Type) {
+			ephemeralMsg := Message{
+				Type:          msg.Type,
+				Data:          msg.Data,
+				Timestamp:     time.Now().Unix(),
+				Room:          room,
+				Username:      username,
+				CorrelationID: correlated(msg, correlationID),
+			}
+			h.broadcastToRoomExcept(room, ephemeralMsg, conn)
+			h.publish("ws.chat."+room, ephemeralMsg)
+			conn.log.Debug("Ephemeral event relayed", "type", msg.Type, "room", room)
 			continue
 		}
 
 		// Normal chat message
 		chatMsg := Message{
-			Type:      "chat",
-			Data:      msg.Data,
-			Timestamp: time.Now().Unix(),
-			Room:      room,
+			Type:          "chat",
+			Data:          msg.Data,
+			Timestamp:     time.Now().Unix(),
+			Room:          room,
+			Username:      username,
+			CorrelationID: correlated(msg, correlationID),
 		}
 
 		h.broadcastToRoom(room, chatMsg)
-		log.Printf("WebSocket Chat: Message broadcasted to room '%s'", room)
+		h.publish("ws.chat."+room, chatMsg)
 	}
 
 	// Send leave message to room
 	leaveMsg := Message{
 		Type:      "leave",
-		Data:      map[string]string{"message": "User left room " + room},
+		Data:      map[string]string{"message": "User left room " + room, "username": username},
 		Timestamp: time.Now().Unix(),
 		Room:      room,
+		Username:  username,
 	}
 	h.broadcastToRoom(room, leaveMsg)
-	log.Printf("WebSocket Chat: Connection to room '%s' closed", room)
+	conn.log.Debug("Connection closed")
 	return nil
 }
 
-func (h *WebSocketHandlers) addClient(conn *websocket.Conn) {
-	h.mutex.Lock()
-	defer h.mutex.Unlock()
-	h.clients[conn] = true
-	log.Printf("WebSocket: Client added. Total clients: %d", len(h.clients))
+// publish mirrors a delivered WebSocket message onto the event bus.
+func (h *WebSocketHandlers) publish(topic string, msg Message) {
+	h.bus.Publish(events.Event{
+		Topic:         topic,
+		Type:          msg.Type,
+		Source:        "websocket",
+		Data:          msg.Data,
+		Timestamp:     msg.Timestamp,
+		CorrelationID: msg.CorrelationID,
+	})
 }
 
-func (h *WebSocketHandlers) removeClient(conn *websocket.Conn) {
-	h.mutex.Lock()
-	defer h.mutex.Unlock()
-	if _, exists := h.clients[conn]; exists {
-		delete(h.clients, conn)
-		conn.Close()
-		log.Printf("WebSocket: Client removed. Total clients: %d", len(h.clients))
+// correlated returns the correlation ID of a client message, falling back to
+// the one of its connection.
+func correlated(msg *Message, connection string) string {
+	if msg.CorrelationID != "" {
+		return msg.CorrelationID
 	}
+	return connection
 }
 
-func (h *WebSocketHandlers) addToRoom(conn *websocket.Conn, room string) {
-	h.mutex.Lock()
-	defer h.mutex.Unlock()
-	if h.rooms[room] == nil {
-		h.rooms[room] = make(map[*websocket.Conn]bool)
-	}
-	h.rooms[room][conn] = true
-	log.Printf("WebSocket: Client added to room '%s'. Room size: %d", room, len(h.rooms[room]))
+func (h *WebSocketHandlers) broadcastToAll(msg Message) {
+	delivered := h.hub.broadcast(broadcastRoom, msg, nil)
+	logger.Debug("Broadcast queued", "clients", delivered)
 }
 
-func (h *WebSocketHandlers) removeFromRoom(conn *websocket.Conn, room string) {
-	h.mutex.Lock()
-	defer h.mutex.Unlock()
-	if h.rooms[room] != nil {
-		if _, exists := h.rooms[room][conn]; exists {
-			delete(h.rooms[room], conn)
-			if len(h.rooms[room]) == 0 {
-				delete(h.rooms, room)
-				log.Printf("WebSocket: Room '%s' deleted (empty)", room)
-			} else {
-				log.Printf("WebSocket: Client removed from room '%s'. Room size: %d", room, len(h.rooms[room]))
-			}
-		}
-	}
-	conn.Close()
+func (h *WebSocketHandlers) broadcastToRoom(room string, msg Message) {
+	h.broadcastToRoomExcept(room, msg, nil)
 }
 
-func (h *WebSocketHandlers) broadcastToAll(msg Message) {
-	h.mutex.RLock()
-	clientCount := len(h.clients)
-	h.mutex.RUnlock()
-
-	if clientCount == 0 {
-		log.Printf("WebSocket Broadcast: No clients to broadcast to")
-		return
-	}
-
-	h.mutex.RLock()
-	defer h.mutex.RUnlock()
-
-	successCount := 0
-	for client := range h.clients {
-		if err := safeWriteJSON(client, msg); err != nil {
-			log.Printf("Broadcast error to client: %v", err)
-			// Note: We can't modify the map here due to RLock, 
-			// cleanup will happen when the client's read loop exits
-		} else {
-			successCount++
-		}
-	}
-	log.Printf("WebSocket Broadcast: Message sent to %d/%d clients", successCount, clientCount)
+// broadcastToRoomExcept sends a message to every client in the room except the
+// given connection (nil sends to all).
+func (h *WebSocketHandlers) broadcastToRoomExcept(room string, msg Message, except *client) {
+	delivered := h.hub.broadcast(room, msg, except)
+	logger.Debug("Room message queued", "room", room, "clients", delivered)
 }
-
-func (h *WebSocketHandlers) broadcastToRoom(room string, msg Message) {
-	h.mutex.RLock()
-	roomClients := h.rooms[room]
-	if roomClients == nil {
-		h.mutex.RUnlock()
-		log.Printf("WebSocket Room Broadcast: Room '%s' not found", room)
-		return
-	}
-	
-	clientCount := len(roomClients)
-	h.mutex.RUnlock()
-
-	if clientCount == 0 {
-		log.Printf("WebSocket Room Broadcast: No clients in room '%s'", room)
-		return
-	}
-
-	h.mutex.RLock()
-	defer h.mutex.RUnlock()
-
-	successCount := 0
-	for client := range h.rooms[room] {
-		if err := safeWriteJSON(client, msg); err != nil {
-			log.Printf("Room broadcast error to client in room '%s': %v", room, err)
-			// Note: We can't modify the map here due to RLock,
-			// cleanup will happen when the client's read loop exits
-		} else {
-			successCount++
-		}
-	}
-	log.Printf("WebSocket Room Broadcast: Message sent to %d/%d clients in room '%s'", successCount, clientCount, room)
-}
\ No newline at end of file
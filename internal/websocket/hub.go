@@ -0,0 +1,392 @@
+package websocket
+
+import (
+	"compress/flate"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"mockserver/internal/logging"
+	"mockserver/internal/panics"
+)
+
+var logger = logging.For("websocket")
+
+const (
+	// sendBuffer is the number of outgoing messages a connection may have
+	// queued; a client falling further behind is disconnected rather than
+	// slowing down the others.
+	sendBuffer = 256
+	// writeWait bounds a single write to a connection.
+	writeWait = 10 * time.Second
+)
+
+// broadcastRoom is the room of the /ws/broadcast clients; chat rooms always
+// have a name.
+const broadcastRoom = ""
+
+var errClientClosed = errors.New("websocket: connection closed")
+
+// frame is a queued outgoing message.
+type frame struct {
+	messageType int
+	data        []byte
+}
+
+// client is one WebSocket connection. Outgoing messages are queued on send
+// and written by the connection's own write pump, so each connection has a
+// single writer and a slow client never blocks the goroutine sending to it.
+type client struct {
+	id          string
+	conn        *websocket.Conn
+	hub         *Hub
+	endpoint    string
+	connectedAt time.Time
+	room        string
+	joined      bool
+	// username is the name a chat client introduced itself with.
+	username  string
+	send      chan frame
+	done      chan struct{}
+	closeOnce sync.Once
+	// compressFrom is the smallest message size compressed when
+	// permessage-deflate was negotiated, or -1 when compression is off.
+	compressFrom int
+	// chaos disturbs the delivery of data messages when configured for the
+	// endpoint.
+	chaos *chaos
+	// socketIO clients receive room messages as Socket.IO events.
+	socketIO bool
+	// ctx and log carry the fields of the upgrade request, log those of
+	// the connection too.
+	ctx context.Context
+	log *slog.Logger
+}
+
+// contain, deferred by the goroutines serving a connection, stops a panic
+// raised while serving it: the panic is recorded and the client gets a 1011
+// close frame, rather than the process going down.
+func (c *client) contain() {
+	if value := recover(); value != nil {
+		c.hub.panics.Record(c.ctx, panics.ProtocolWebSocket, c.endpoint+" "+c.id, value)
+		c.closeWithCode(websocket.CloseInternalServerErr, "internal error")
+	}
+}
+
+func (c *client) writePump() {
+	defer c.contain()
+	var lifetime <-chan time.Time
+	if c.chaos != nil && c.chaos.lifetime.max > 0 {
+		timer := time.NewTimer(c.chaos.lifetime.pick())
+		defer timer.Stop()
+		lifetime = timer.C
+	}
+	for {
+		select {
+		case f := <-c.send:
+			copies := 1
+			if c.chaos != nil && f.messageType != websocket.CloseMessage && !c.hub.chaosPaused.Load() {
+				copies = c.chaosCopies()
+				if copies < 0 {
+					c.chaosDisconnect("disconnect_rate")
+					return
+				}
+			}
+			for i := 0; i < copies; i++ {
+				c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+				if c.compressFrom >= 0 {
+					c.conn.EnableWriteCompression(len(f.data) >= c.compressFrom)
+				}
+				if err := c.conn.WriteMessage(f.messageType, f.data); err != nil {
+					c.log.Debug("Write failed", "error", err)
+					c.close()
+					return
+				}
+				c.sent(f)
+			}
+		case <-lifetime:
+			if c.hub.chaosPaused.Load() {
+				lifetime = nil
+				continue
+			}
+			c.chaosDisconnect("disconnect_after")
+			return
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// enqueue queues a message without blocking. A client whose queue is full is
+// closed, which also ends its read loop.
+func (c *client) enqueue(f frame) bool {
+	select {
+	case <-c.done:
+		return false
+	default:
+	}
+	select {
+	case c.send <- f:
+		return true
+	case <-c.done:
+		return false
+	default:
+		c.log.Warn("Client too slow, disconnecting", "queued", len(c.send))
+		c.hub.metrics.evicted.Add(1)
+		c.close()
+		return false
+	}
+}
+
+// read reads the next message from the client, counting it.
+func (c *client) read() (int, []byte, error) {
+	messageType, data, err := c.conn.ReadMessage()
+	if err == nil {
+		if counters := c.hub.metrics.endpoints[c.endpoint]; counters != nil {
+			counters.messagesIn.Add(1)
+			counters.bytesIn.Add(uint64(len(data)))
+		}
+	}
+	return messageType, data, err
+}
+
+// writeJSON queues a message for this client only.
+func (c *client) writeJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if !c.enqueue(frame{websocket.TextMessage, data}) {
+		return errClientClosed
+	}
+	return nil
+}
+
+// writeFrame queues a raw text or binary message for this client only.
+func (c *client) writeFrame(messageType int, data []byte) error {
+	if !c.enqueue(frame{messageType, data}) {
+		return errClientClosed
+	}
+	return nil
+}
+
+// closeWithCode sends a close frame, unless the code is 1006 (abnormal
+// closure), and closes the connection.
+func (c *client) closeWithCode(code int, reason string) {
+	if code != websocket.CloseAbnormalClosure {
+		c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(writeWait))
+	}
+	c.close()
+}
+
+// close stops the write pump and closes the connection; it is idempotent.
+func (c *client) close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.conn.Close()
+	})
+}
+
+// Hub tracks the open connections and the rooms they joined, and fans
+// messages out to them.
+type Hub struct {
+	clients map[*client]bool
+	rooms   map[string]map[*client]bool
+	nextID  atomic.Uint64
+	mutex   sync.Mutex
+	// compression, chaos and limits are set before the first connection.
+	compression CompressionConfig
+	chaos       map[string]*chaos
+	limits      LimitsConfig
+	// chaosPaused turns the chaos off without forgetting it.
+	chaosPaused atomic.Bool
+	metrics     *hubMetrics
+	// panics keeps the panics contained on the connections.
+	panics *panics.Recorder
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		clients: make(map[*client]bool),
+		rooms:   make(map[string]map[*client]bool),
+		metrics: newHubMetrics(),
+	}
+}
+
+// connect registers a connection to an endpoint and starts its write pump.
+// Past the connection limit, the connection is closed and refused.
+func (h *Hub) connect(ctx context.Context, conn *websocket.Conn, endpoint string) (*client, error) {
+	c := &client{
+		id:           fmt.Sprintf("ws-%d", h.nextID.Add(1)),
+		conn:         conn,
+		hub:          h,
+		endpoint:     endpoint,
+		connectedAt:  time.Now(),
+		send:         make(chan frame, sendBuffer),
+		done:         make(chan struct{}),
+		compressFrom: -1,
+		chaos:        h.chaos[endpoint],
+		ctx:          ctx,
+	}
+	c.log = logging.Bind(ctx, logger).With("connection_id", c.id, "endpoint", endpoint)
+	if h.compression.Enabled {
+		level := h.compression.Level
+		if level == 0 {
+			level = flate.BestSpeed
+		}
+		if err := conn.SetCompressionLevel(level); err != nil {
+			c.log.Warn("Invalid compression level", "level", level, "error", err)
+		}
+		c.compressFrom = h.compression.Threshold
+	}
+	if h.limits.MaxMessageSize > 0 {
+		conn.SetReadLimit(h.limits.MaxMessageSize)
+	}
+
+	h.mutex.Lock()
+	if limit := h.limits.MaxConnections; limit > 0 && len(h.clients) >= limit {
+		h.mutex.Unlock()
+		code := h.limits.connectionLimitCloseCode()
+		c.log.Warn("Connection limit reached, refusing connection", "limit", limit, "close_code", code)
+		if counters := h.metrics.endpoints[endpoint]; counters != nil {
+			counters.refused.Add(1)
+		}
+		c.closeWithCode(code, "too many connections")
+		return nil, errTooManyConnections
+	}
+	h.clients[c] = true
+	if counters := h.metrics.endpoints[endpoint]; counters != nil {
+		counters.connects.Add(1)
+	}
+	c.log.Debug("Client connected", "connections", len(h.clients))
+	h.mutex.Unlock()
+
+	go c.writePump()
+	return c, nil
+}
+
+// join adds a client to a room; a client is in at most one room, so it
+// leaves the one it was in.
+func (h *Hub) join(c *client, room string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if c.joined {
+		h.leaveLocked(c)
+	}
+	if h.rooms[room] == nil {
+		h.rooms[room] = make(map[*client]bool)
+	}
+	h.rooms[room][c] = true
+	c.room = room
+	c.joined = true
+	if room == broadcastRoom {
+		c.log.Debug("Client joined the broadcast", "clients", len(h.rooms[room]))
+	} else {
+		c.log.Debug("Client joined room", "room", room, "room_size", len(h.rooms[room]))
+	}
+}
+
+// disconnect removes a client from the hub and its room and closes it.
+func (h *Hub) disconnect(c *client) {
+	h.mutex.Lock()
+	h.remove(c)
+	h.mutex.Unlock()
+	c.close()
+}
+
+// leave removes a client from its room, keeping it connected.
+func (h *Hub) leave(c *client) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if c.joined && h.clients[c] {
+		h.leaveLocked(c)
+	}
+	c.joined = false
+}
+
+// remove must be called with the mutex held.
+func (h *Hub) remove(c *client) {
+	if !h.clients[c] {
+		return
+	}
+	delete(h.clients, c)
+	if counters := h.metrics.endpoints[c.endpoint]; counters != nil {
+		counters.disconnects.Add(1)
+	}
+	if c.joined {
+		h.leaveLocked(c)
+	}
+}
+
+// leaveLocked must be called with the mutex held, for a client in a room.
+func (h *Hub) leaveLocked(c *client) {
+	members := h.rooms[c.room]
+	delete(members, c)
+	switch {
+	case c.room == broadcastRoom:
+		c.log.Debug("Client left the broadcast", "clients", len(members))
+	case len(members) == 0:
+		delete(h.rooms, c.room)
+		c.log.Debug("Room deleted (empty)", "room", c.room)
+	default:
+		c.log.Debug("Client left room", "room", c.room, "room_size", len(members))
+	}
+}
+
+// broadcast queues a message for every member of the room except one (nil
+// sends to all) and returns how many clients it was queued for. The message
+// is encoded once; clients too slow to take it are evicted on the spot.
+func (h *Hub) broadcast(room string, msg interface{}, except *client) int {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		logger.Error("Cannot encode message", "room", room, "error", err)
+		return 0
+	}
+	return h.broadcastFrame(room, frame{websocket.TextMessage, data}, except)
+}
+
+// broadcastFrame queues a frame as it is, like broadcast. Socket.IO clients
+// get text frames as events and never binary ones.
+func (h *Hub) broadcastFrame(room string, f frame, except *client) int {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	var event *frame // the message as a Socket.IO event, encoded on first use
+	delivered := 0
+	for c := range h.rooms[room] {
+		if c == except {
+			continue
+		}
+		out := f
+		if c.socketIO {
+			if f.messageType != websocket.TextMessage {
+				continue
+			}
+			if event == nil {
+				event = &frame{websocket.TextMessage, socketIOEvent(f.data)}
+			}
+			out = *event
+		}
+		if c.enqueue(out) {
+			delivered++
+		} else {
+			h.remove(c)
+		}
+	}
+	h.metrics.fanout.Observe(float64(delivered))
+	return delivered
+}
+
+// size returns the number of clients in a room.
+func (h *Hub) size(room string) int {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return len(h.rooms[room])
+}
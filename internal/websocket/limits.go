@@ -0,0 +1,42 @@
+package websocket
+
+import (
+	"errors"
+	"fmt"
+)
+
+var errTooManyConnections = errors.New("websocket: too many connections")
+
+// LimitsConfig bounds the resources of WebSocket clients. Zero values mean
+// no limit and the default 4 KB buffers.
+type LimitsConfig struct {
+	// MaxMessageSize is the largest message accepted from a client; larger
+	// messages close the connection with 1009 (message too big).
+	MaxMessageSize int64 `json:"max_message_size,omitempty"`
+	// MaxConnections caps the open connections across all endpoints.
+	// Connections beyond it are accepted and closed right away with
+	// ConnectionLimitCloseCode, 1013 (try again later) by default.
+	MaxConnections           int `json:"max_connections,omitempty"`
+	ConnectionLimitCloseCode int `json:"connection_limit_close_code,omitempty"`
+	// ReadBufferSize and WriteBufferSize size the I/O buffers of a
+	// connection; messages may be larger than the buffers.
+	ReadBufferSize  int `json:"read_buffer_size,omitempty"`
+	WriteBufferSize int `json:"write_buffer_size,omitempty"`
+}
+
+func (l LimitsConfig) validate() error {
+	if l.MaxMessageSize < 0 || l.MaxConnections < 0 || l.ReadBufferSize < 0 || l.WriteBufferSize < 0 {
+		return fmt.Errorf("limits must not be negative")
+	}
+	if l.ConnectionLimitCloseCode != 0 && !validCloseCode(l.ConnectionLimitCloseCode) {
+		return fmt.Errorf("limits: invalid connection_limit_close_code %d", l.ConnectionLimitCloseCode)
+	}
+	return nil
+}
+
+func (l LimitsConfig) connectionLimitCloseCode() int {
+	if l.ConnectionLimitCloseCode == 0 {
+		return 1013
+	}
+	return l.ConnectionLimitCloseCode
+}
@@ -0,0 +1,115 @@
+package websocket
+
+import (
+	"sort"
+	"sync/atomic"
+
+	"github.com/gorilla/websocket"
+
+	"mockserver/internal/metrics"
+)
+
+// endpointCounters is the traffic of one endpoint.
+type endpointCounters struct {
+	connects    atomic.Uint64
+	disconnects atomic.Uint64
+	refused     atomic.Uint64
+	messagesIn  atomic.Uint64
+	messagesOut atomic.Uint64
+	bytesIn     atomic.Uint64
+	bytesOut    atomic.Uint64
+}
+
+// hubMetrics counts the traffic of the hub. The per-endpoint counters are
+// created up front, so they are updated without locking.
+type hubMetrics struct {
+	endpoints map[string]*endpointCounters
+	evicted   atomic.Uint64
+	fanout    *metrics.Histogram
+}
+
+func newHubMetrics() *hubMetrics {
+	m := &hubMetrics{
+		endpoints: make(map[string]*endpointCounters, len(endpoints)),
+		fanout:    metrics.NewHistogram(0, 1, 2, 5, 10, 25, 50, 100, 250, 500, 1000),
+	}
+	for endpoint := range endpoints {
+		m.endpoints[endpoint] = &endpointCounters{}
+	}
+	return m
+}
+
+// sent counts a data message written to a client.
+func (c *client) sent(f frame) {
+	if f.messageType != websocket.TextMessage && f.messageType != websocket.BinaryMessage {
+		return
+	}
+	if counters := c.hub.metrics.endpoints[c.endpoint]; counters != nil {
+		counters.messagesOut.Add(1)
+		counters.bytesOut.Add(uint64(len(f.data)))
+	}
+}
+
+// Collect writes the WebSocket metrics: connections and traffic per
+// endpoint, broadcast fan-out sizes and chat room sizes.
+func (h *WebSocketHandlers) Collect(w *metrics.Writer) {
+	h.hub.collect(w)
+}
+
+func (h *Hub) collect(w *metrics.Writer) {
+	h.mutex.Lock()
+	active := make(map[string]int, len(endpoints))
+	for c := range h.clients {
+		active[c.endpoint]++
+	}
+	rooms := make(map[string]int, len(h.rooms))
+	for room, members := range h.rooms {
+		if room != broadcastRoom {
+			rooms[room] = len(members)
+		}
+	}
+	h.mutex.Unlock()
+
+	names := make([]string, 0, len(endpoints))
+	for endpoint := range endpoints {
+		names = append(names, endpoint)
+	}
+	sort.Strings(names)
+
+	w.Family("mockserver_ws_connections", "gauge", "Open WebSocket connections.")
+	for _, endpoint := range names {
+		w.Sample("mockserver_ws_connections", float64(active[endpoint]), "endpoint", endpoint)
+	}
+	for _, counter := range []struct {
+		name, help string
+		value      func(*endpointCounters) uint64
+	}{
+		{"mockserver_ws_connects_total", "WebSocket connections accepted.", func(c *endpointCounters) uint64 { return c.connects.Load() }},
+		{"mockserver_ws_disconnects_total", "WebSocket connections closed.", func(c *endpointCounters) uint64 { return c.disconnects.Load() }},
+		{"mockserver_ws_refused_total", "WebSocket connections refused by the connection limit.", func(c *endpointCounters) uint64 { return c.refused.Load() }},
+		{"mockserver_ws_messages_received_total", "WebSocket messages received from clients.", func(c *endpointCounters) uint64 { return c.messagesIn.Load() }},
+		{"mockserver_ws_messages_sent_total", "WebSocket data messages sent to clients.", func(c *endpointCounters) uint64 { return c.messagesOut.Load() }},
+		{"mockserver_ws_received_bytes_total", "Payload bytes of the WebSocket messages received.", func(c *endpointCounters) uint64 { return c.bytesIn.Load() }},
+		{"mockserver_ws_sent_bytes_total", "Payload bytes of the WebSocket data messages sent.", func(c *endpointCounters) uint64 { return c.bytesOut.Load() }},
+	} {
+		w.Family(counter.name, "counter", counter.help)
+		for _, endpoint := range names {
+			w.Sample(counter.name, float64(counter.value(h.metrics.endpoints[endpoint])), "endpoint", endpoint)
+		}
+	}
+
+	w.Family("mockserver_ws_evicted_total", "counter", "Slow WebSocket clients disconnected because their queue was full.")
+	w.Sample("mockserver_ws_evicted_total", float64(h.metrics.evicted.Load()))
+	w.Family("mockserver_ws_broadcast_fanout", "histogram", "Clients a broadcast or room message was queued for.")
+	h.metrics.fanout.Write(w, "mockserver_ws_broadcast_fanout")
+
+	roomNames := make([]string, 0, len(rooms))
+	for room := range rooms {
+		roomNames = append(roomNames, room)
+	}
+	sort.Strings(roomNames)
+	w.Family("mockserver_ws_room_clients", "gauge", "Members of each chat room.")
+	for _, room := range roomNames {
+		w.Sample("mockserver_ws_room_clients", float64(rooms[room]), "room", room)
+	}
+}
@@ -0,0 +1,69 @@
+package websocket
+
+import (
+	"sort"
+	"strings"
+)
+
+// maxUsernameLength bounds the names chat clients pick.
+const maxUsernameLength = 64
+
+// Member is a client of a chat room, as listed in the reply to a "members"
+// request.
+type Member struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+}
+
+// setUsername names a chat client.
+func (h *Hub) setUsername(c *client, username string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	c.username = username
+}
+
+// members lists the clients of a room, sorted by username. Clients that
+// have not introduced themselves yet are listed under their connection ID.
+func (h *Hub) members(room string) []Member {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	members := make([]Member, 0, len(h.rooms[room]))
+	for c := range h.rooms[room] {
+		username := c.username
+		if username == "" {
+			username = c.id
+		}
+		members = append(members, Member{ID: c.id, Username: username})
+	}
+	sort.Slice(members, func(i, j int) bool {
+		if members[i].Username != members[j].Username {
+			return members[i].Username < members[j].Username
+		}
+		return members[i].ID < members[j].ID
+	})
+	return members
+}
+
+// identifyUsername extracts the name of an identify message, which carries
+// it either as data.username or as a plain string.
+func identifyUsername(data interface{}) string {
+	switch v := data.(type) {
+	case string:
+		return cleanUsername(v)
+	case map[string]interface{}:
+		if username, ok := v["username"].(string); ok {
+			return cleanUsername(username)
+		}
+	}
+	return ""
+}
+
+// cleanUsername trims a username and rejects it (returning "") when it is
+// empty or too long.
+func cleanUsername(username string) string {
+	username = strings.TrimSpace(username)
+	if len(username) > maxUsernameLength {
+		return ""
+	}
+	return username
+}
@@ -0,0 +1,89 @@
+package websocket
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"mockserver/internal/correlation"
+)
+
+// maxPublishBody bounds the messages accepted by the publish endpoints.
+const maxPublishBody = 1 << 20
+
+// Push sends a server-originated message to every /ws/broadcast client, or to
+// the members of room when it is set, and returns how many clients it was
+// queued for.
+// The message is mirrored onto the event bus like client traffic.
+func (h *WebSocketHandlers) Push(room string, msg Message) int {
+	if msg.Timestamp == 0 {
+		msg.Timestamp = time.Now().Unix()
+	}
+	msg.Room = room
+
+	delivered := h.hub.broadcast(room, msg, nil)
+	if room != "" {
+		h.publish("ws.chat."+room, msg)
+	} else {
+		h.publish("ws.broadcast", msg)
+	}
+	logger.Info("Message pushed", "type", msg.Type, "room", room, "clients", delivered)
+	return delivered
+}
+
+// PublishBroadcast delivers the JSON message of the request body to every
+// /ws/broadcast client, so scripts without a WebSocket client can trigger
+// deliveries.
+func (h *WebSocketHandlers) PublishBroadcast(c echo.Context) error {
+	return h.publishHTTP(c, broadcastRoom)
+}
+
+// PublishChat delivers the JSON message of the request body to the members
+// of a chat room.
+func (h *WebSocketHandlers) PublishChat(c echo.Context) error {
+	return h.publishHTTP(c, c.Param("room"))
+}
+
+// publishHTTP reads a {"type", "data", "correlation_id"} message; the type
+// defaults to "publish" and the correlation ID to the one of the request
+// headers.
+func (h *WebSocketHandlers) publishHTTP(c echo.Context, room string) error {
+	body, err := io.ReadAll(io.LimitReader(c.Request().Body, maxPublishBody))
+	if err != nil {
+		return invalidPublish(c, err.Error())
+	}
+	var req struct {
+		Type          string      `json:"type"`
+		Data          interface{} `json:"data"`
+		CorrelationID string      `json:"correlation_id"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return invalidPublish(c, err.Error())
+	}
+	if req.Type == "" {
+		req.Type = "publish"
+	}
+	if req.CorrelationID == "" {
+		req.CorrelationID = correlation.FromHeader(c.Request().Header)
+	}
+
+	delivered := h.Push(room, Message{Type: req.Type, Data: req.Data, CorrelationID: req.CorrelationID})
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"room":           room,
+		"type":           req.Type,
+		"delivered":      delivered,
+		"correlation_id": req.CorrelationID,
+		"timestamp":      time.Now().Unix(),
+	})
+}
+
+func invalidPublish(c echo.Context, details string) error {
+	return c.JSON(http.StatusBadRequest, map[string]interface{}{
+		"error":     "Invalid publish payload, expected a JSON message",
+		"details":   details,
+		"timestamp": time.Now().Unix(),
+	})
+}
@@ -0,0 +1,126 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo/v4"
+)
+
+// quietEndpoints are the endpoints that can be made quiet; the others either
+// send nothing of their own or exist for their messages.
+var quietEndpoints = map[string]bool{
+	EndpointEcho: true, EndpointBroadcast: true, EndpointChat: true, EndpointSubprotocol: true,
+}
+
+func validateQuiet(names []string) error {
+	for _, endpoint := range names {
+		if !quietEndpoints[endpoint] {
+			return fmt.Errorf("quiet: unknown endpoint %q (want echo, broadcast, chat or subprotocol)", endpoint)
+		}
+	}
+	return nil
+}
+
+// quiet reports whether a connection to an endpoint is quiet: the quiet
+// query parameter when given, the configuration otherwise.
+func (h *WebSocketHandlers) quiet(c echo.Context, endpoint string) (bool, error) {
+	if value := c.QueryParam("quiet"); value != "" {
+		quiet, err := strconv.ParseBool(value)
+		if err != nil {
+			return false, fmt.Errorf("invalid quiet %q", value)
+		}
+		return quiet, nil
+	}
+	for _, name := range h.config.Quiet {
+		if name == endpoint {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// invalidQuiet rejects a handshake with an invalid quiet parameter.
+func invalidQuiet(c echo.Context, err error) error {
+	return c.JSON(http.StatusBadRequest, map[string]interface{}{
+		"error":     "Invalid quiet parameter",
+		"details":   err.Error(),
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// echoQuiet echoes the frame as it came. Set and drop still apply to text
+// frames holding JSON, which are then re-encoded.
+func echoQuiet(emit func(messageType int, data []byte) error, transform *echoTransform, messageType int, data []byte) error {
+	if messageType == websocket.TextMessage && (len(transform.set) > 0 || len(transform.drop) > 0) {
+		var value interface{}
+		if json.Unmarshal(data, &value) == nil {
+			if mutated, err := json.Marshal(transform.mutate(value)); err == nil {
+				data = mutated
+			}
+		}
+	}
+	return emit(messageType, data)
+}
+
+// relayQuietly relays the frames of a quiet connection unchanged to the
+// members of its room until it closes, and mirrors them onto the event bus.
+// In a chat room, ephemeral events skip the sender like in a loud one.
+func (h *WebSocketHandlers) relayQuietly(conn *client, room, correlationID string) {
+	topic, msgType := "ws.broadcast", "broadcast"
+	if room != broadcastRoom {
+		topic, msgType = "ws.chat."+room, "chat"
+	}
+	for {
+		messageType, data, err := conn.read()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+				conn.log.Debug("Read failed", "error", err)
+			}
+			return
+		}
+
+		msg := Message{Type: msgType, Data: frameData(messageType, data), Timestamp: time.Now().Unix(), CorrelationID: correlationID}
+		var except *client
+		if room != broadcastRoom {
+			msg.Room, msg.Username = room, conn.username
+			if eventType := frameType(messageType, data); eventType != "" && h.isEphemeral(room, eventType) {
+				msg.Type, except = eventType, conn
+			}
+		}
+		delivered := h.hub.broadcastFrame(room, frame{messageType, data}, except)
+		h.publish(topic, msg)
+		conn.log.Debug("Frame relayed quietly", "bytes", len(data), "clients", delivered)
+	}
+}
+
+// frameData is the content of a frame as event data: the decoded JSON of a
+// text frame when it is JSON, its text otherwise, and the bytes of a binary
+// frame.
+func frameData(messageType int, data []byte) interface{} {
+	if messageType == websocket.BinaryMessage {
+		return data
+	}
+	var value interface{}
+	if json.Unmarshal(data, &value) == nil {
+		return value
+	}
+	return string(data)
+}
+
+// frameType returns the type field of a text frame holding a JSON object,
+// "" for anything else.
+func frameType(messageType int, data []byte) string {
+	if messageType != websocket.TextMessage {
+		return ""
+	}
+	var msg struct {
+		Type string `json:"type"`
+	}
+	json.Unmarshal(data, &msg)
+	return msg.Type
+}
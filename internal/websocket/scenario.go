@@ -0,0 +1,384 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo/v4"
+
+	"mockserver/internal/match"
+)
+
+// Scenario scripts the conversation of /ws/scenario/:name. Steps run in
+// order once the client connects; Replies answer matching client messages
+// at any time, including while a step waits.
+type Scenario struct {
+	Steps   []ScenarioStep  `json:"steps,omitempty"`
+	Replies []ScenarioReply `json:"replies,omitempty"`
+}
+
+// ScenarioStep waits for Delay, then for a client message matching Expect
+// (when set), then sends Send and finally closes the connection with Close.
+// Every part is optional.
+type ScenarioStep struct {
+	Delay  string               `json:"delay,omitempty"`
+	Expect []match.FieldMatcher `json:"expect,omitempty"`
+	Send   json.RawMessage      `json:"send,omitempty"`
+	Close  *ScenarioClose       `json:"close,omitempty"`
+}
+
+// ScenarioReply sends Send, and then closes with Close, whenever a client
+// message matches Match. The first matching reply wins.
+type ScenarioReply struct {
+	Match []match.FieldMatcher `json:"match"`
+	Send  json.RawMessage      `json:"send,omitempty"`
+	Close *ScenarioClose       `json:"close,omitempty"`
+}
+
+// ScenarioClose ends the conversation with a close frame; Code defaults to
+// 1000 (normal closure).
+type ScenarioClose struct {
+	Code   int    `json:"code,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+type scenarioStep struct {
+	ScenarioStep
+	delay time.Duration
+}
+
+type compiledScenario struct {
+	source  Scenario
+	steps   []scenarioStep
+	replies []ScenarioReply
+}
+
+// Validate checks the scenarios and chaos settings of the configuration.
+func (c Config) Validate() error {
+	if _, err := compileScenarios(c.Scenarios); err != nil {
+		return err
+	}
+	if _, err := compileChaos(c.Chaos); err != nil {
+		return err
+	}
+	if err := c.Limits.validate(); err != nil {
+		return err
+	}
+	if err := c.Auth.validate(); err != nil {
+		return err
+	}
+	if err := c.SocketIO.validate(); err != nil {
+		return err
+	}
+	if err := validateQuiet(c.Quiet); err != nil {
+		return err
+	}
+	_, err := c.Echo.compile()
+	return err
+}
+
+func compileScenarios(scenarios map[string]Scenario) (map[string]*compiledScenario, error) {
+	compiled := make(map[string]*compiledScenario, len(scenarios))
+	for name, scenario := range scenarios {
+		c, err := compileScenario(scenario)
+		if err != nil {
+			return nil, fmt.Errorf("scenario %q: %w", name, err)
+		}
+		compiled[name] = c
+	}
+	return compiled, nil
+}
+
+func compileScenario(scenario Scenario) (*compiledScenario, error) {
+	c := &compiledScenario{source: scenario}
+	for i, step := range scenario.Steps {
+		compiled := scenarioStep{ScenarioStep: step}
+		if step.Delay != "" {
+			delay, err := time.ParseDuration(step.Delay)
+			if err != nil || delay < 0 {
+				return nil, fmt.Errorf("step %d: invalid delay %q", i, step.Delay)
+			}
+			compiled.delay = delay
+		}
+		if step.Expect != nil {
+			// An empty expect still waits for a message
+			compiled.Expect = append(make([]match.FieldMatcher, 0, len(step.Expect)), step.Expect...)
+		}
+		if err := compileMatchers(compiled.Expect); err != nil {
+			return nil, fmt.Errorf("step %d: %w", i, err)
+		}
+		if err := checkSend(step.Send); err != nil {
+			return nil, fmt.Errorf("step %d: %w", i, err)
+		}
+		c.steps = append(c.steps, compiled)
+	}
+	for i, reply := range scenario.Replies {
+		if len(reply.Match) == 0 {
+			return nil, fmt.Errorf("reply %d: match is required", i)
+		}
+		reply.Match = append([]match.FieldMatcher(nil), reply.Match...)
+		if err := compileMatchers(reply.Match); err != nil {
+			return nil, fmt.Errorf("reply %d: %w", i, err)
+		}
+		if err := checkSend(reply.Send); err != nil {
+			return nil, fmt.Errorf("reply %d: %w", i, err)
+		}
+		c.replies = append(c.replies, reply)
+	}
+	return c, nil
+}
+
+func compileMatchers(matchers []match.FieldMatcher) error {
+	for i := range matchers {
+		if err := matchers[i].Compile(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkSend(send json.RawMessage) error {
+	if len(send) > 0 && !json.Valid(send) {
+		return fmt.Errorf("send is not valid JSON")
+	}
+	return nil
+}
+
+// ScenarioNames lists the configured scenarios.
+func (h *WebSocketHandlers) ScenarioNames() []string {
+	h.scenariosMutex.RLock()
+	defer h.scenariosMutex.RUnlock()
+	names := make([]string, 0, len(h.scenarios))
+	for name := range h.scenarios {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Scenarios returns the definitions of the configured scenarios.
+func (h *WebSocketHandlers) Scenarios() map[string]Scenario {
+	h.scenariosMutex.RLock()
+	defer h.scenariosMutex.RUnlock()
+	scenarios := make(map[string]Scenario, len(h.scenarios))
+	for name, scenario := range h.scenarios {
+		scenarios[name] = scenario.source
+	}
+	return scenarios
+}
+
+// SetScenario adds or replaces a scenario; connections already playing the
+// previous version keep it.
+func (h *WebSocketHandlers) SetScenario(name string, scenario Scenario) error {
+	if name == "" || strings.Contains(name, "/") {
+		return fmt.Errorf("invalid scenario name %q", name)
+	}
+	compiled, err := compileScenario(scenario)
+	if err != nil {
+		return fmt.Errorf("scenario %q: %w", name, err)
+	}
+	h.scenariosMutex.Lock()
+	defer h.scenariosMutex.Unlock()
+	h.scenarios[name] = compiled
+	return nil
+}
+
+// RemoveScenario deletes a scenario and reports whether it existed.
+func (h *WebSocketHandlers) RemoveScenario(name string) bool {
+	h.scenariosMutex.Lock()
+	defer h.scenariosMutex.Unlock()
+	_, ok := h.scenarios[name]
+	delete(h.scenarios, name)
+	return ok
+}
+
+// errScenarioClosed stops a scenario after it sent a close frame.
+var errScenarioClosed = errors.New("websocket: scenario closed the connection")
+
+// Scenario plays the named scenario to the client.
+func (h *WebSocketHandlers) Scenario(c echo.Context) error {
+	name := c.Param("name")
+	h.scenariosMutex.RLock()
+	scenario, ok := h.scenarios[name]
+	h.scenariosMutex.RUnlock()
+	if !ok {
+		return c.JSON(http.StatusNotFound, map[string]interface{}{
+			"error":     "Unknown WebSocket scenario",
+			"provided":  name,
+			"available": h.ScenarioNames(),
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	if ok, err := h.authenticate(c, EndpointScenario); !ok {
+		return err
+	}
+
+	ws, err := h.upgrader.Upgrade(c.Response(), c.Request(), nil)
+	if err != nil {
+		logger.DebugContext(c.Request().Context(), "Upgrade failed", "error", err)
+		return err
+	}
+	conn, err := h.hub.connect(c.Request().Context(), ws, EndpointScenario)
+	if err != nil {
+		return nil
+	}
+	defer h.hub.disconnect(conn)
+	defer conn.contain()
+	conn.log.Debug("Scenario playing", "scenario", name)
+
+	incoming := make(chan []byte)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		defer conn.contain()
+		defer close(incoming)
+		for {
+			_, data, err := conn.read()
+			if err != nil {
+				return
+			}
+			select {
+			case incoming <- data:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	player := &scenarioPlayer{conn: conn, scenario: scenario, incoming: incoming}
+	err = player.play()
+	if err == nil {
+		// The script is over; keep answering replies until the client leaves
+		err = player.serveReplies()
+	}
+	if err == errScenarioClosed {
+		// Give the client a moment to answer the close frame
+		select {
+		case <-incoming:
+		case <-time.After(writeWait):
+		}
+	}
+	conn.log.Debug("Scenario ended", "scenario", name, "error", err)
+	return nil
+}
+
+type scenarioPlayer struct {
+	conn     *client
+	scenario *compiledScenario
+	incoming chan []byte
+}
+
+// errClientGone ends a scenario when the client disconnects.
+var errClientGone = errors.New("websocket: client disconnected")
+
+func (p *scenarioPlayer) play() error {
+	for i, step := range p.scenario.steps {
+		if step.delay > 0 {
+			if err := p.wait(step.delay); err != nil {
+				return err
+			}
+		}
+		if step.Expect != nil {
+			if err := p.expect(step.Expect); err != nil {
+				return err
+			}
+		}
+		if err := p.send(step.Send, step.Close); err != nil {
+			return err
+		}
+		p.conn.log.Debug("Scenario step completed", "step", i)
+	}
+	return nil
+}
+
+// wait lets time pass while still answering replies.
+func (p *scenarioPlayer) wait(delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			return nil
+		case data, ok := <-p.incoming:
+			if !ok {
+				return errClientGone
+			}
+			if err := p.reply(decodeScenarioMessage(data)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// expect waits for a message matching the step; other messages get replies.
+func (p *scenarioPlayer) expect(matchers []match.FieldMatcher) error {
+	for data := range p.incoming {
+		doc := decodeScenarioMessage(data)
+		if match.All(matchers, doc) {
+			return nil
+		}
+		if err := p.reply(doc); err != nil {
+			return err
+		}
+	}
+	return errClientGone
+}
+
+func (p *scenarioPlayer) serveReplies() error {
+	for data := range p.incoming {
+		if err := p.reply(decodeScenarioMessage(data)); err != nil {
+			return err
+		}
+	}
+	return errClientGone
+}
+
+func (p *scenarioPlayer) reply(doc interface{}) error {
+	for _, reply := range p.scenario.replies {
+		if match.All(reply.Match, doc) {
+			return p.send(reply.Send, reply.Close)
+		}
+	}
+	return nil
+}
+
+// send writes a message, then the close frame when there is one. JSON
+// strings are sent as raw text so that non-JSON protocols can be scripted.
+func (p *scenarioPlayer) send(send json.RawMessage, closing *ScenarioClose) error {
+	if len(send) > 0 {
+		data := []byte(send)
+		var text string
+		if json.Unmarshal(send, &text) == nil {
+			data = []byte(text)
+		}
+		if err := p.conn.writeFrame(websocket.TextMessage, data); err != nil {
+			return err
+		}
+	}
+	if closing != nil {
+		code := closing.Code
+		if code == 0 {
+			code = websocket.CloseNormalClosure
+		}
+		p.conn.writeFrame(websocket.CloseMessage, websocket.FormatCloseMessage(code, closing.Reason))
+		return errScenarioClosed
+	}
+	return nil
+}
+
+// decodeScenarioMessage parses a client message for matching. Messages that
+// are not JSON objects are matched as {"text": "<message>"}.
+func decodeScenarioMessage(data []byte) interface{} {
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil || doc == nil {
+		return map[string]interface{}{"text": string(data)}
+	}
+	return doc
+}
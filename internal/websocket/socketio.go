@@ -0,0 +1,385 @@
+package websocket
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo/v4"
+
+	"mockserver/internal/correlation"
+)
+
+// Engine.IO (v4) and Socket.IO (v5) packet types. A Socket.IO packet travels
+// as the payload of an Engine.IO message packet: "42[...]" is an event.
+const (
+	eioOpen    = '0'
+	eioClose   = '1'
+	eioPing    = '2'
+	eioPong    = '3'
+	eioMessage = '4'
+
+	sioConnect      = '0'
+	sioDisconnect   = '1'
+	sioEvent        = '2'
+	sioAck          = '3'
+	sioConnectError = '4'
+)
+
+const (
+	defaultPingInterval = 25 * time.Second
+	defaultPingTimeout  = 20 * time.Second
+	defaultMaxPayload   = 1000000
+)
+
+// SocketIOConfig sets the heartbeat announced in the Engine.IO handshake:
+// the server pings every PingInterval and drops clients that do not answer
+// within PingTimeout (defaults 25s and 20s).
+type SocketIOConfig struct {
+	PingInterval string `json:"ping_interval,omitempty"`
+	PingTimeout  string `json:"ping_timeout,omitempty"`
+}
+
+func (s SocketIOConfig) validate() error {
+	for name, value := range map[string]string{"ping_interval": s.PingInterval, "ping_timeout": s.PingTimeout} {
+		if value == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(value); err != nil || d <= 0 {
+			return fmt.Errorf("socketio: invalid %s %q", name, value)
+		}
+	}
+	return nil
+}
+
+// heartbeat returns the ping interval and timeout, defaults for invalid
+// values.
+func (s SocketIOConfig) heartbeat() (time.Duration, time.Duration) {
+	interval, timeout := defaultPingInterval, defaultPingTimeout
+	if d, err := time.ParseDuration(s.PingInterval); err == nil && d > 0 {
+		interval = d
+	}
+	if d, err := time.ParseDuration(s.PingTimeout); err == nil && d > 0 {
+		timeout = d
+	}
+	return interval, timeout
+}
+
+// sioPacket is a decoded Socket.IO packet.
+type sioPacket struct {
+	kind      byte
+	namespace string
+	ackID     string
+	data      json.RawMessage
+}
+
+// parseSocketIO decodes a Socket.IO packet: type, optional "/namespace,",
+// optional ack id, then JSON data.
+func parseSocketIO(payload string) (sioPacket, error) {
+	if payload == "" {
+		return sioPacket{}, fmt.Errorf("empty packet")
+	}
+	p := sioPacket{kind: payload[0], namespace: "/"}
+	rest := payload[1:]
+	if strings.HasPrefix(rest, "/") {
+		namespace, after, ok := strings.Cut(rest, ",")
+		if !ok {
+			namespace, after = rest, ""
+		}
+		p.namespace, rest = namespace, after
+	}
+	i := 0
+	for i < len(rest) && rest[i] >= '0' && rest[i] <= '9' {
+		i++
+	}
+	p.ackID, rest = rest[:i], rest[i:]
+	if rest != "" {
+		if !json.Valid([]byte(rest)) {
+			return sioPacket{}, fmt.Errorf("invalid packet data")
+		}
+		p.data = json.RawMessage(rest)
+	}
+	return p, nil
+}
+
+// socketIOEvent turns a room message into a Socket.IO event named after its
+// type, with its data as the argument.
+func socketIOEvent(data []byte) []byte {
+	var msg struct {
+		Type string          `json:"type"`
+		Data json.RawMessage `json:"data"`
+	}
+	if json.Unmarshal(data, &msg) != nil || msg.Type == "" {
+		msg.Type, msg.Data = "message", data
+		if !json.Valid(data) {
+			// A quiet room relays text that is not JSON
+			msg.Data, _ = json.Marshal(string(data))
+		}
+	}
+	if len(msg.Data) == 0 {
+		msg.Data = json.RawMessage("null")
+	}
+	event, _ := json.Marshal([]interface{}{msg.Type, msg.Data})
+	return append([]byte{eioMessage, sioEvent}, event...)
+}
+
+// SocketIO emulates a Socket.IO server over the WebSocket transport
+// (clients need transports: ["websocket"]; Engine.IO 4, i.e. Socket.IO 3
+// and later). Only the main namespace exists. The built-in "join" and
+// "leave" events move the socket between the rooms it shares with
+// /ws/chat: events emitted in a room reach its other members, and room
+// messages reach the socket as events named after their type. Outside a
+// room, events are emitted back to the sender. Events with an ack are
+// acknowledged with their own arguments.
+func (h *WebSocketHandlers) SocketIO(c echo.Context) error {
+	query := c.Request().URL.Query()
+	switch {
+	case query.Get("EIO") != "4":
+		return socketIOError(c, 5, "Unsupported protocol version")
+	case query.Get("transport") != "websocket":
+		return socketIOError(c, 0, "Transport unknown")
+	case query.Get("sid") != "":
+		return socketIOError(c, 1, "Session ID unknown")
+	}
+
+	if ok, err := h.authenticate(c, EndpointSocketIO); !ok {
+		return err
+	}
+
+	ws, err := h.upgrader.Upgrade(c.Response(), c.Request(), nil)
+	if err != nil {
+		logger.DebugContext(c.Request().Context(), "Upgrade failed", "error", err)
+		return err
+	}
+	conn, err := h.hub.connect(c.Request().Context(), ws, EndpointSocketIO)
+	if err != nil {
+		return nil
+	}
+	conn.socketIO = true
+	defer h.hub.disconnect(conn)
+	defer conn.contain()
+
+	s := &socketIOSession{h: h, conn: conn, correlationID: correlation.FromHeader(c.Request().Header)}
+	defer s.leave()
+
+	interval, timeout := h.config.SocketIO.heartbeat()
+	maxPayload := int64(defaultMaxPayload)
+	if h.config.Limits.MaxMessageSize > 0 {
+		maxPayload = h.config.Limits.MaxMessageSize
+	}
+	open, _ := json.Marshal(map[string]interface{}{
+		"sid":          newSessionID(),
+		"upgrades":     []string{},
+		"pingInterval": interval.Milliseconds(),
+		"pingTimeout":  timeout.Milliseconds(),
+		"maxPayload":   maxPayload,
+	})
+	if err := s.send(eioOpen, string(open)); err != nil {
+		return nil
+	}
+	conn.log.Debug("Socket.IO client connected")
+
+	go func() {
+		defer conn.contain()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if s.send(eioPing, "") != nil {
+					return
+				}
+			case <-conn.done:
+				return
+			}
+		}
+	}()
+
+	for {
+		ws.SetReadDeadline(time.Now().Add(interval + timeout))
+		messageType, data, err := conn.read()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				conn.log.Debug("Read failed", "error", err)
+			}
+			return nil
+		}
+		if messageType != websocket.TextMessage || len(data) == 0 {
+			conn.log.Debug("Ignoring Socket.IO binary message (attachments are not supported)")
+			continue
+		}
+
+		packet := string(data)
+		switch packet[0] {
+		case eioPing:
+			s.send(eioPong, packet[1:])
+		case eioPong:
+		case eioClose:
+			conn.log.Debug("Socket.IO session closed by the client")
+			return nil
+		case eioMessage:
+			if !s.handle(packet[1:]) {
+				return nil
+			}
+		default:
+			conn.log.Debug("Unexpected Engine.IO packet", "packet", packet)
+			return nil
+		}
+	}
+}
+
+// socketIOSession is the state of one Socket.IO connection.
+type socketIOSession struct {
+	h             *WebSocketHandlers
+	conn          *client
+	connected     bool
+	correlationID string
+}
+
+// send queues an Engine.IO packet.
+func (s *socketIOSession) send(kind byte, payload string) error {
+	return s.conn.writeFrame(websocket.TextMessage, append([]byte{kind}, payload...))
+}
+
+// emit queues a Socket.IO packet of the main namespace.
+func (s *socketIOSession) emit(kind byte, ackID string, data interface{}) error {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return s.send(eioMessage, string(kind)+ackID+string(encoded))
+}
+
+// handle processes a Socket.IO packet and reports whether the connection
+// stays open.
+func (s *socketIOSession) handle(payload string) bool {
+	packet, err := parseSocketIO(payload)
+	if err != nil {
+		s.conn.log.Debug("Invalid Socket.IO packet", "error", err)
+		return false
+	}
+	if packet.namespace != "/" {
+		if packet.kind == sioConnect {
+			s.send(eioMessage, string(sioConnectError)+packet.namespace+`,{"message":"Invalid namespace"}`)
+		}
+		return true
+	}
+
+	switch packet.kind {
+	case sioConnect:
+		s.connected = true
+		s.emit(sioConnect, "", map[string]string{"sid": s.conn.id})
+	case sioDisconnect:
+		s.conn.log.Debug("Socket.IO client disconnected")
+		return false
+	case sioEvent:
+		if !s.connected {
+			return true
+		}
+		var args []json.RawMessage
+		var name string
+		if json.Unmarshal(packet.data, &args) != nil || len(args) == 0 || json.Unmarshal(args[0], &name) != nil {
+			s.conn.log.Debug("Invalid Socket.IO event", "data", packet.data)
+			return false
+		}
+		s.event(name, args[1:], packet.ackID)
+	case sioAck:
+	default:
+		s.conn.log.Debug("Unsupported Socket.IO packet type", "type", string(packet.kind))
+	}
+	return true
+}
+
+// event handles an event emitted by the client.
+func (s *socketIOSession) event(name string, args []json.RawMessage, ackID string) {
+	ack := []interface{}{}
+	for _, arg := range args {
+		ack = append(ack, arg)
+	}
+
+	switch name {
+	case "join":
+		var room string
+		if len(args) == 0 || json.Unmarshal(args[0], &room) != nil || room == "" {
+			ack = []interface{}{map[string]string{"error": "join expects a room name"}}
+			break
+		}
+		s.leave()
+		s.h.hub.join(s.conn, room)
+		s.h.broadcastToRoomExcept(room, Message{
+			Type:      "join",
+			Data:      map[string]string{"message": "Socket " + s.conn.id + " joined room " + room},
+			Timestamp: time.Now().Unix(),
+			Room:      room,
+		}, s.conn)
+		ack = []interface{}{map[string]interface{}{"room": room, "size": s.h.hub.size(room)}}
+		s.conn.log.Debug("Socket.IO client joined room", "room", room)
+	case "leave":
+		room := s.leave()
+		ack = []interface{}{map[string]string{"room": room}}
+	default:
+		var data interface{}
+		switch len(args) {
+		case 0:
+		case 1:
+			data = args[0]
+		default:
+			data = args
+		}
+		msg := Message{
+			Type:          name,
+			Data:          data,
+			Timestamp:     time.Now().Unix(),
+			CorrelationID: s.correlationID,
+		}
+		if s.conn.joined {
+			msg.Room = s.conn.room
+			s.h.broadcastToRoomExcept(msg.Room, msg, s.conn)
+			s.h.publish("ws.socketio."+msg.Room, msg)
+		} else {
+			s.emit(sioEvent, "", append([]interface{}{name}, ack...))
+			s.h.publish("ws.socketio", msg)
+		}
+	}
+
+	if ackID != "" {
+		s.emit(sioAck, ackID, ack)
+	}
+}
+
+// leave takes the socket out of its room, announcing it to the other
+// members, and returns the room ("" when it was in none).
+func (s *socketIOSession) leave() string {
+	if !s.conn.joined {
+		return ""
+	}
+	room := s.conn.room
+	s.h.hub.leave(s.conn)
+	s.h.broadcastToRoom(room, Message{
+		Type:      "leave",
+		Data:      map[string]string{"message": "Socket " + s.conn.id + " left room " + room},
+		Timestamp: time.Now().Unix(),
+		Room:      room,
+	})
+	return room
+}
+
+// socketIOError answers a handshake the way a Socket.IO server rejects it.
+func socketIOError(c echo.Context, code int, message string) error {
+	logger.DebugContext(c.Request().Context(), "Rejecting Socket.IO handshake", "reason", message)
+	return c.JSON(http.StatusBadRequest, map[string]interface{}{
+		"code":    code,
+		"message": message,
+	})
+}
+
+func newSessionID() string {
+	b := make([]byte, 15)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
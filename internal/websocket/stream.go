@@ -0,0 +1,152 @@
+package websocket
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	defaultStreamInterval = time.Second
+	minStreamInterval     = time.Millisecond
+	maxStreamSize         = 16 << 20
+)
+
+// StreamData is the data of the messages pushed by /ws/stream.
+type StreamData struct {
+	Seq     int    `json:"seq"`
+	Payload string `json:"payload,omitempty"`
+}
+
+// Stream pushes generated "stream" messages without waiting for the client:
+// one every interval (default 1s), count of them (default: until the client
+// goes away) with a payload of size bytes ("512", "1kb", "2mb"). Sequence
+// numbers start at start (default 1) so a reconnecting client can resume
+// where it left off. After the last message the server closes the
+// connection normally. A client that does not keep up fills its send queue
+// and is disconnected.
+func (h *WebSocketHandlers) Stream(c echo.Context) error {
+	interval, count, size, start, err := streamParams(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error":     "Invalid stream parameters",
+			"details":   err.Error(),
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	if ok, err := h.authenticate(c, EndpointStream); !ok {
+		return err
+	}
+
+	ws, err := h.upgrader.Upgrade(c.Response(), c.Request(), nil)
+	if err != nil {
+		logger.DebugContext(c.Request().Context(), "Upgrade failed", "error", err)
+		return err
+	}
+	conn, err := h.hub.connect(c.Request().Context(), ws, EndpointStream)
+	if err != nil {
+		return nil
+	}
+	defer h.hub.disconnect(conn)
+	defer conn.contain()
+
+	conn.log.Debug("Stream started", "count", count, "size", size, "interval", interval)
+
+	// Incoming messages are discarded; reading detects the client closing
+	closed := make(chan struct{})
+	go func() {
+		defer conn.contain()
+		defer close(closed)
+		for {
+			if _, _, err := conn.read(); err != nil {
+				return
+			}
+		}
+	}()
+
+	payload := strings.Repeat("x", size)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for sent := 0; count == 0 || sent < count; sent++ {
+		msg := Message{
+			Type:      "stream",
+			Data:      StreamData{Seq: start + sent, Payload: payload},
+			Timestamp: time.Now().Unix(),
+		}
+		if err := conn.writeJSON(msg); err != nil {
+			conn.log.Debug("Stream stopped", "sent", sent, "error", err)
+			return nil
+		}
+
+		select {
+		case <-ticker.C:
+		case <-closed:
+			conn.log.Debug("Stream left by the client", "sent", sent+1)
+			return nil
+		}
+	}
+
+	closeMsg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "stream complete")
+	conn.writeFrame(websocket.CloseMessage, closeMsg)
+	select {
+	case <-closed:
+	case <-time.After(writeWait):
+	}
+	conn.log.Debug("Stream completed", "sent", count)
+	return nil
+}
+
+func streamParams(c echo.Context) (interval time.Duration, count, size, start int, err error) {
+	interval = defaultStreamInterval
+	start = 1
+	if value := c.QueryParam("interval"); value != "" {
+		if interval, err = time.ParseDuration(value); err != nil || interval < minStreamInterval {
+			return 0, 0, 0, 0, fmt.Errorf("interval must be a duration of at least %v, got %q", minStreamInterval, value)
+		}
+	}
+	if value := c.QueryParam("count"); value != "" {
+		if count, err = strconv.Atoi(value); err != nil || count < 0 {
+			return 0, 0, 0, 0, fmt.Errorf("count must be a non-negative integer, got %q", value)
+		}
+	}
+	if value := c.QueryParam("size"); value != "" {
+		if size, err = parseSize(value); err != nil || size > maxStreamSize {
+			return 0, 0, 0, 0, fmt.Errorf("size must be a byte size up to 16mb, got %q", value)
+		}
+	}
+	if value := c.QueryParam("start"); value != "" {
+		if start, err = strconv.Atoi(value); err != nil {
+			return 0, 0, 0, 0, fmt.Errorf("start must be an integer, got %q", value)
+		}
+	}
+	return interval, count, size, start, nil
+}
+
+// parseSize reads a byte size with an optional b, kb or mb suffix (powers
+// of 1024, case-insensitive).
+func parseSize(value string) (int, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	multiplier := 1
+	for _, unit := range []struct {
+		suffix string
+		factor int
+	}{{"kb", 1 << 10}, {"mb", 1 << 20}, {"b", 1}} {
+		if strings.HasSuffix(value, unit.suffix) {
+			value = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix))
+			multiplier = unit.factor
+			break
+		}
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", value)
+	}
+	return n * multiplier, nil
+}
@@ -0,0 +1,132 @@
+package websocket
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo/v4"
+)
+
+// DefaultSubprotocols are accepted by /ws/subprotocol unless configured
+// otherwise.
+var DefaultSubprotocols = []string{"graphql-transport-ws", "graphql-ws", "mqtt", "v12.stomp", "wamp.2.json", "json"}
+
+// SubprotocolInfo is the data of the /ws/subprotocol welcome message.
+type SubprotocolInfo struct {
+	// Protocol is the negotiated subprotocol, empty when none was agreed.
+	Protocol  string   `json:"protocol"`
+	Offered   []string `json:"offered"`
+	Supported []string `json:"supported"`
+}
+
+// Subprotocol negotiates a subprotocol from Sec-WebSocket-Protocol, reports
+// the outcome in its welcome message and then echoes every frame back
+// unchanged. The supported protocols are tried in order of preference and
+// the first one the client offered wins. The protocols query parameter (comma separated) replaces the
+// supported list for the connection, and strict=true rejects the handshake
+// with 400 when no protocol can be agreed. A quiet connection gets no
+// welcome message.
+func (h *WebSocketHandlers) Subprotocol(c echo.Context) error {
+	supported := h.config.Subprotocols
+	if len(supported) == 0 {
+		supported = DefaultSubprotocols
+	}
+	if param := c.QueryParam("protocols"); param != "" {
+		supported = splitProtocols(param)
+	}
+	offered := websocket.Subprotocols(c.Request())
+	strict, _ := strconv.ParseBool(c.QueryParam("strict"))
+	quiet, err := h.quiet(c, EndpointSubprotocol)
+	if err != nil {
+		return invalidQuiet(c, err)
+	}
+
+	if strict && negotiate(offered, supported) == "" {
+		logger.DebugContext(c.Request().Context(), "No supported subprotocol offered, rejecting", "offered", offered)
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error":     "No supported subprotocol offered",
+			"provided":  offered,
+			"supported": supported,
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	if ok, err := h.authenticate(c, EndpointSubprotocol); !ok {
+		return err
+	}
+
+	upgrader := h.upgrader
+	upgrader.Subprotocols = supported
+	ws, err := upgrader.Upgrade(c.Response(), c.Request(), nil)
+	if err != nil {
+		logger.DebugContext(c.Request().Context(), "Upgrade failed", "error", err)
+		return err
+	}
+	conn, err := h.hub.connect(c.Request().Context(), ws, EndpointSubprotocol)
+	if err != nil {
+		return nil
+	}
+	defer h.hub.disconnect(conn)
+	defer conn.contain()
+
+	if offered == nil {
+		offered = []string{}
+	}
+	info := SubprotocolInfo{Protocol: ws.Subprotocol(), Offered: offered, Supported: supported}
+	conn.log.Debug("Subprotocol negotiated", "protocol", info.Protocol, "offered", offered)
+
+	if !quiet {
+		welcome := Message{
+			Type:      "welcome",
+			Data:      info,
+			Timestamp: time.Now().Unix(),
+		}
+		if err := conn.writeJSON(welcome); err != nil {
+			conn.log.Debug("Cannot send welcome message", "error", err)
+			return nil
+		}
+	}
+
+	for {
+		messageType, data, err := conn.read()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+				conn.log.Debug("Read failed", "error", err)
+			}
+			break
+		}
+		if err := conn.writeFrame(messageType, data); err != nil {
+			conn.log.Debug("Write failed", "error", err)
+			break
+		}
+	}
+
+	conn.log.Debug("Connection closed")
+	return nil
+}
+
+// negotiate mirrors the upgrader's choice: the first supported protocol
+// that was offered.
+func negotiate(offered, supported []string) string {
+	for _, protocol := range supported {
+		for _, candidate := range offered {
+			if protocol == candidate {
+				return protocol
+			}
+		}
+	}
+	return ""
+}
+
+func splitProtocols(list string) []string {
+	var protocols []string
+	for _, protocol := range strings.Split(list, ",") {
+		if protocol = strings.TrimSpace(protocol); protocol != "" {
+			protocols = append(protocols, protocol)
+		}
+	}
+	return protocols
+}
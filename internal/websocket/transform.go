@@ -0,0 +1,237 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// reorderFlush is how long a partly filled reorder window waits for more
+// responses before it is released.
+const reorderFlush = time.Second
+
+// EchoConfig makes /ws/echo behave like an imperfect server. Unlike chaos,
+// the transformations are deterministic (apart from delay ranges and the
+// order within a window) and only apply to echoed messages.
+type EchoConfig struct {
+	// Delay holds every response back, fixed ("200ms") or random within a
+	// range ("100ms-2s"); responses keep their order.
+	Delay string `json:"delay,omitempty"`
+	// Duplicates is the number of extra copies sent of every response.
+	Duplicates int `json:"duplicates,omitempty"`
+	// ReorderWindow collects that many responses and sends them in a
+	// shuffled order; a partial window is released after a second.
+	ReorderWindow int `json:"reorder_window,omitempty"`
+	// Set overrides fields of the echoed data, addressed by dotted paths.
+	Set map[string]interface{} `json:"set,omitempty"`
+	// Drop removes fields of the echoed data, addressed by dotted paths.
+	Drop []string `json:"drop,omitempty"`
+}
+
+type echoTransform struct {
+	delay      durationRange
+	duplicates int
+	window     int
+	set        map[string]interface{}
+	drop       []string
+}
+
+func (e EchoConfig) compile() (*echoTransform, error) {
+	if e.Duplicates < 0 || e.ReorderWindow < 0 {
+		return nil, fmt.Errorf("echo: duplicates and reorder_window must not be negative")
+	}
+	delay, err := parseDurationRange(e.Delay)
+	if err != nil {
+		return nil, fmt.Errorf("echo: delay: %w", err)
+	}
+	return &echoTransform{
+		delay:      delay,
+		duplicates: e.Duplicates,
+		window:     e.ReorderWindow,
+		set:        e.Set,
+		drop:       e.Drop,
+	}, nil
+}
+
+// withQuery applies the per-connection overrides of the delay, duplicates,
+// reorder, set (field=value, the value parsed as JSON when it is JSON) and
+// drop query parameters; set and drop may be repeated.
+func (t echoTransform) withQuery(query url.Values) (*echoTransform, error) {
+	if value := query.Get("delay"); value != "" {
+		delay, err := parseDurationRange(value)
+		if err != nil {
+			return nil, fmt.Errorf("delay: %w", err)
+		}
+		t.delay = delay
+	}
+	for name, target := range map[string]*int{"duplicate": &t.duplicates, "reorder": &t.window} {
+		if value := query.Get(name); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid %s %q", name, value)
+			}
+			*target = n
+		}
+	}
+	if fields := query["set"]; len(fields) > 0 {
+		set := make(map[string]interface{}, len(t.set)+len(fields))
+		for path, value := range t.set {
+			set[path] = value
+		}
+		for _, field := range fields {
+			path, raw, ok := strings.Cut(field, "=")
+			if !ok || path == "" {
+				return nil, fmt.Errorf("invalid set %q, expected field=value", field)
+			}
+			var value interface{} = raw
+			if json.Unmarshal([]byte(raw), &value) != nil {
+				value = raw
+			}
+			set[path] = value
+		}
+		t.set = set
+	}
+	if fields := query["drop"]; len(fields) > 0 {
+		t.drop = append(append([]string(nil), t.drop...), fields...)
+	}
+	return &t, nil
+}
+
+func (t *echoTransform) active() bool {
+	return t.delay.max > 0 || t.duplicates > 0 || t.window > 1 || len(t.set) > 0 || len(t.drop) > 0
+}
+
+// mutate applies set and drop to the data of a response; data that is not
+// a JSON object is left alone unless a field is set on it.
+func (t *echoTransform) mutate(data interface{}) interface{} {
+	if len(t.set) == 0 && len(t.drop) == 0 {
+		return data
+	}
+	object, ok := cloneJSON(data).(map[string]interface{})
+	if !ok {
+		if len(t.set) == 0 {
+			return data
+		}
+		object = map[string]interface{}{}
+	}
+	for path, value := range t.set {
+		setPath(object, strings.Split(path, "."), value)
+	}
+	for _, path := range t.drop {
+		dropPath(object, strings.Split(path, "."))
+	}
+	return object
+}
+
+func setPath(object map[string]interface{}, path []string, value interface{}) {
+	for _, key := range path[:len(path)-1] {
+		child, ok := object[key].(map[string]interface{})
+		if !ok {
+			child = map[string]interface{}{}
+			object[key] = child
+		}
+		object = child
+	}
+	object[path[len(path)-1]] = value
+}
+
+func dropPath(object map[string]interface{}, path []string) {
+	for _, key := range path[:len(path)-1] {
+		child, ok := object[key].(map[string]interface{})
+		if !ok {
+			return
+		}
+		object = child
+	}
+	delete(object, path[len(path)-1])
+}
+
+// cloneJSON deep-copies decoded JSON so mutations never touch the original.
+func cloneJSON(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		clone := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			clone[key] = cloneJSON(item)
+		}
+		return clone
+	case []interface{}:
+		clone := make([]interface{}, len(v))
+		for i, item := range v {
+			clone[i] = cloneJSON(item)
+		}
+		return clone
+	}
+	return value
+}
+
+// run delivers the responses queued on in, applying the delay, reordering
+// and duplication, until in is closed.
+func (t *echoTransform) run(conn *client, in <-chan frame) {
+	defer conn.contain()
+	var window []frame
+	var flush <-chan time.Time
+	release := func() {
+		for _, f := range shuffled(window) {
+			if t.delay.max > 0 {
+				select {
+				case <-time.After(t.delay.pick()):
+				case <-conn.done:
+					return
+				}
+			}
+			for i := 0; i <= t.duplicates; i++ {
+				if err := conn.writeFrame(f.messageType, f.data); err != nil {
+					return
+				}
+			}
+		}
+		window, flush = nil, nil
+	}
+
+	for {
+		select {
+		case f, ok := <-in:
+			if !ok {
+				release()
+				return
+			}
+			window = append(window, f)
+			if len(window) >= t.window {
+				release()
+			} else if flush == nil {
+				flush = time.After(reorderFlush)
+			}
+		case <-flush:
+			conn.log.Debug("Releasing partial reorder window", "responses", len(window))
+			release()
+		case <-conn.done:
+			return
+		}
+	}
+}
+
+// shuffled returns the frames in a random order that differs from the
+// original one when there are at least two.
+func shuffled(frames []frame) []frame {
+	if len(frames) < 2 {
+		return frames
+	}
+	order := rand.Perm(len(frames))
+	identity := true
+	for i, j := range order {
+		identity = identity && i == j
+	}
+	if identity {
+		order = append(order[1:], order[0])
+	}
+	out := make([]frame, len(frames))
+	for i, j := range order {
+		out[i] = frames[j]
+	}
+	return out
+}
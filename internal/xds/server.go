@@ -0,0 +1,321 @@
+// Package xds implements a minimal xDS (ADS) control plane for testing gRPC
+// clients that use xds:/// targets.
+//
+// Each configured service becomes a Listener (with an inline route to a single
+// cluster), an EDS Cluster and a ClusterLoadAssignment. Endpoints can be
+// changed at runtime through the admin API; every change publishes a new
+// snapshot version to all connected clients.
+package xds
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	clusterv3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	endpointv3 "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	listenerv3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	routev3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	routerv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/router/v3"
+	hcmv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	discoverygrpc "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	xdsserver "github.com/envoyproxy/go-control-plane/pkg/server/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"mockserver/internal/logging"
+)
+
+var logger = logging.For("xds")
+
+// snapshotNode is the single cache key: every client gets the same view.
+const snapshotNode = "mockserver"
+
+// Endpoint is one backend address of a service.
+type Endpoint struct {
+	Address  string `json:"address"`
+	Port     uint32 `json:"port"`
+	Weight   uint32 `json:"weight,omitempty"`
+	Health   string `json:"health,omitempty"`
+	Locality string `json:"locality,omitempty"`
+}
+
+// Service is what a client resolves through xds:///<name>.
+type Service struct {
+	Name      string     `json:"name"`
+	Endpoints []Endpoint `json:"endpoints"`
+}
+
+// Config holds the xDS settings from the configuration file.
+type Config struct {
+	Services []Service `json:"services,omitempty"`
+}
+
+type sharedNodeHash struct{}
+
+func (sharedNodeHash) ID(*corev3.Node) string { return snapshotNode }
+
+// Server holds the configured services and serves them as ADS snapshots.
+type Server struct {
+	cache    cache.SnapshotCache
+	server   xdsserver.Server
+	services map[string]Service
+	version  int
+	mutex    sync.Mutex
+}
+
+func NewServer(cfg Config) (*Server, error) {
+	snapshotCache := cache.NewSnapshotCache(true, sharedNodeHash{}, nil)
+	s := &Server{
+		cache:    snapshotCache,
+		server:   xdsserver.NewServer(context.Background(), snapshotCache, nil),
+		services: make(map[string]Service),
+	}
+
+	for _, service := range cfg.Services {
+		if err := validate(service); err != nil {
+			return nil, err
+		}
+		s.services[service.Name] = service
+	}
+	if err := s.publish(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Register exposes the Aggregated Discovery Service on a gRPC server.
+func (s *Server) Register(srv *grpc.Server) {
+	discoverygrpc.RegisterAggregatedDiscoveryServiceServer(srv, s.server)
+}
+
+// Services returns the current services sorted by name, plus the snapshot version.
+func (s *Server) Services() ([]Service, string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	services := make([]Service, 0, len(s.services))
+	for _, service := range s.services {
+		services = append(services, service)
+	}
+	sort.Slice(services, func(i, j int) bool { return services[i].Name < services[j].Name })
+	return services, strconv.Itoa(s.version)
+}
+
+// SetService creates or replaces a service and pushes a new snapshot.
+func (s *Server) SetService(service Service) (string, error) {
+	if err := validate(service); err != nil {
+		return "", err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.services[service.Name] = service
+	if err := s.publishLocked(); err != nil {
+		return "", err
+	}
+	return strconv.Itoa(s.version), nil
+}
+
+// DeleteService removes a service and pushes a new snapshot.
+func (s *Server) DeleteService(name string) (string, bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.services[name]; !ok {
+		return "", false, nil
+	}
+	delete(s.services, name)
+	if err := s.publishLocked(); err != nil {
+		return "", true, err
+	}
+	return strconv.Itoa(s.version), true, nil
+}
+
+// Bootstrap returns a gRPC xDS bootstrap document pointing at serverURI.
+func Bootstrap(serverURI string) map[string]interface{} {
+	return map[string]interface{}{
+		"xds_servers": []interface{}{
+			map[string]interface{}{
+				"server_uri":      serverURI,
+				"channel_creds":   []interface{}{map[string]string{"type": "insecure"}},
+				"server_features": []string{"xds_v3"},
+			},
+		},
+		"node": map[string]interface{}{
+			"id":       "mockserver-client",
+			"locality": map[string]string{"zone": "mock"},
+		},
+	}
+}
+
+func validate(service Service) error {
+	if service.Name == "" {
+		return fmt.Errorf("service name is required")
+	}
+	for _, endpoint := range service.Endpoints {
+		if endpoint.Address == "" || endpoint.Port == 0 {
+			return fmt.Errorf("service %s: endpoints need an address and a port", service.Name)
+		}
+		if _, err := parseHealth(endpoint.Health); err != nil {
+			return fmt.Errorf("service %s: %w", service.Name, err)
+		}
+	}
+	return nil
+}
+
+func (s *Server) publish() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.publishLocked()
+}
+
+func (s *Server) publishLocked() error {
+	s.version++
+	resources := map[resource.Type][]types.Resource{}
+
+	for _, service := range s.services {
+		listener, err := buildListener(service.Name)
+		if err != nil {
+			return err
+		}
+		resources[resource.ListenerType] = append(resources[resource.ListenerType], listener)
+		resources[resource.ClusterType] = append(resources[resource.ClusterType], buildCluster(service.Name))
+		resources[resource.EndpointType] = append(resources[resource.EndpointType], buildAssignment(service))
+	}
+
+	snapshot, err := cache.NewSnapshot(strconv.Itoa(s.version), resources)
+	if err != nil {
+		return fmt.Errorf("build xDS snapshot: %w", err)
+	}
+	if err := snapshot.Consistent(); err != nil {
+		return fmt.Errorf("inconsistent xDS snapshot: %w", err)
+	}
+	if err := s.cache.SetSnapshot(context.Background(), snapshotNode, snapshot); err != nil {
+		return fmt.Errorf("set xDS snapshot: %w", err)
+	}
+
+	logger.Info("Snapshot published", "version", s.version, "services", len(s.services))
+	return nil
+}
+
+func clusterName(service string) string {
+	return service + "-cluster"
+}
+
+// buildListener creates the API listener gRPC clients look up by target name,
+// with an inline route sending everything to the service cluster.
+func buildListener(name string) (*listenerv3.Listener, error) {
+	router, err := anypb.New(&routerv3.Router{})
+	if err != nil {
+		return nil, err
+	}
+
+	manager, err := anypb.New(&hcmv3.HttpConnectionManager{
+		RouteSpecifier: &hcmv3.HttpConnectionManager_RouteConfig{
+			RouteConfig: &routev3.RouteConfiguration{
+				Name: name + "-route",
+				VirtualHosts: []*routev3.VirtualHost{{
+					Name:    name,
+					Domains: []string{"*"},
+					Routes: []*routev3.Route{{
+						Match: &routev3.RouteMatch{PathSpecifier: &routev3.RouteMatch_Prefix{Prefix: ""}},
+						Action: &routev3.Route_Route{Route: &routev3.RouteAction{
+							ClusterSpecifier: &routev3.RouteAction_Cluster{Cluster: clusterName(name)},
+						}},
+					}},
+				}},
+			},
+		},
+		HttpFilters: []*hcmv3.HttpFilter{{
+			Name:       "envoy.filters.http.router",
+			ConfigType: &hcmv3.HttpFilter_TypedConfig{TypedConfig: router},
+		}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &listenerv3.Listener{
+		Name:        name,
+		ApiListener: &listenerv3.ApiListener{ApiListener: manager},
+	}, nil
+}
+
+func buildCluster(name string) *clusterv3.Cluster {
+	return &clusterv3.Cluster{
+		Name:                 clusterName(name),
+		ClusterDiscoveryType: &clusterv3.Cluster_Type{Type: clusterv3.Cluster_EDS},
+		EdsClusterConfig: &clusterv3.Cluster_EdsClusterConfig{
+			EdsConfig: &corev3.ConfigSource{
+				ConfigSourceSpecifier: &corev3.ConfigSource_Ads{Ads: &corev3.AggregatedConfigSource{}},
+			},
+		},
+		LbPolicy: clusterv3.Cluster_ROUND_ROBIN,
+	}
+}
+
+func buildAssignment(service Service) *endpointv3.ClusterLoadAssignment {
+	localities := map[string]*endpointv3.LocalityLbEndpoints{}
+	var order []string
+
+	for _, endpoint := range service.Endpoints {
+		zone := endpoint.Locality
+		if zone == "" {
+			zone = "default"
+		}
+		group, ok := localities[zone]
+		if !ok {
+			group = &endpointv3.LocalityLbEndpoints{
+				Locality:            &corev3.Locality{Zone: zone},
+				LoadBalancingWeight: wrapperspb.UInt32(1),
+			}
+			localities[zone] = group
+			order = append(order, zone)
+		}
+
+		weight := endpoint.Weight
+		if weight == 0 {
+			weight = 1
+		}
+		health, _ := parseHealth(endpoint.Health)
+
+		group.LbEndpoints = append(group.LbEndpoints, &endpointv3.LbEndpoint{
+			HostIdentifier: &endpointv3.LbEndpoint_Endpoint{Endpoint: &endpointv3.Endpoint{
+				Address: &corev3.Address{Address: &corev3.Address_SocketAddress{SocketAddress: &corev3.SocketAddress{
+					Address:       endpoint.Address,
+					PortSpecifier: &corev3.SocketAddress_PortValue{PortValue: endpoint.Port},
+				}}},
+			}},
+			HealthStatus:        health,
+			LoadBalancingWeight: wrapperspb.UInt32(weight),
+		})
+	}
+
+	assignment := &endpointv3.ClusterLoadAssignment{ClusterName: clusterName(service.Name)}
+	for _, zone := range order {
+		assignment.Endpoints = append(assignment.Endpoints, localities[zone])
+	}
+	return assignment
+}
+
+// parseHealth maps "HEALTHY", "UNHEALTHY", "DRAINING", ... (case-insensitive)
+// to the envoy enum. An empty value means HEALTHY.
+func parseHealth(value string) (corev3.HealthStatus, error) {
+	if value == "" {
+		return corev3.HealthStatus_HEALTHY, nil
+	}
+	status, ok := corev3.HealthStatus_value[strings.ToUpper(value)]
+	if !ok {
+		return corev3.HealthStatus_UNKNOWN, fmt.Errorf("unknown health status %q", value)
+	}
+	return corev3.HealthStatus(status), nil
+}
@@ -0,0 +1,451 @@
+// Package adminclient is a typed client for the mock server's /__admin API,
+// for Go test suites driving a standalone or embedded server:
+//
+//	admin := adminclient.New("http://localhost:8080")
+//	admin.Isolate(t)
+//
+//	admin.MustCreateStub(t, adminclient.Stub{
+//		Request:  adminclient.Request{Method: "GET", Path: "/users/:id"},
+//		Response: adminclient.Response{Status: 200, JSONBody: []byte(`{"id":1}`)},
+//	})
+//
+//	// ... exercise the system under test ...
+//
+//	admin.ExpectRequests(t, adminclient.Filter{Method: "GET", Path: "/users/"}, 1)
+package adminclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"mockserver/internal/events"
+	"mockserver/internal/journal"
+	"mockserver/internal/match"
+	"mockserver/internal/stubs"
+	"mockserver/internal/verify"
+)
+
+// Stub definitions share their types with the server, so the JSON accepted
+// by /__admin/stubs and the fields available here never drift apart.
+type (
+	Stub          = stubs.Stub
+	Request       = stubs.Request
+	Response      = stubs.Response
+	CacheConfig   = stubs.CacheConfig
+	FailConfig    = stubs.FailConfig
+	PostProcessor = stubs.PostProcessor
+	FieldMatcher  = match.FieldMatcher
+)
+
+// Entry is a request recorded in the journal; Filter selects entries.
+type (
+	Entry  = journal.Entry
+	Filter = journal.Filter
+)
+
+// Event is an event published on the server's event bus.
+type Event = events.Event
+
+// VerificationSpec is a stored request expectation; Report and
+// VerificationResult are the outcome of evaluating the stored specs.
+type (
+	VerificationSpec   = verify.Spec
+	Report             = verify.Report
+	VerificationResult = verify.Result
+)
+
+// Int returns a pointer to n, for the optional counts of VerificationSpec.
+func Int(n int) *int {
+	return &n
+}
+
+// Client calls the admin API of one mock server.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithHTTPClient replaces the default client (10s timeout).
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// New returns a client for the server at baseURL, e.g. "http://localhost:8080".
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Error is returned when the admin API answers with a non-2xx status.
+type Error struct {
+	StatusCode int
+	Message    string
+	Details    string
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("admin API returned %d", e.StatusCode)
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	if e.Details != "" {
+		msg += " (" + e.Details + ")"
+	}
+	return msg
+}
+
+// CreateStub registers a stub and returns it with its assigned ID. Creating a
+// stub whose ID already exists fails; use PutStub to replace one.
+func (c *Client) CreateStub(ctx context.Context, stub Stub) (Stub, error) {
+	var created Stub
+	err := c.do(ctx, http.MethodPost, "/__admin/stubs", nil, stub, &created)
+	return created, err
+}
+
+// PutStub creates or replaces the stub with stub.ID.
+func (c *Client) PutStub(ctx context.Context, stub Stub) (Stub, error) {
+	if stub.ID == "" {
+		return Stub{}, fmt.Errorf("adminclient: PutStub needs a stub ID")
+	}
+	var saved Stub
+	err := c.do(ctx, http.MethodPut, "/__admin/stubs/"+url.PathEscape(stub.ID), nil, stub, &saved)
+	return saved, err
+}
+
+// GetStub returns a stub by ID.
+func (c *Client) GetStub(ctx context.Context, id string) (Stub, error) {
+	var stub Stub
+	err := c.do(ctx, http.MethodGet, "/__admin/stubs/"+url.PathEscape(id), nil, nil, &stub)
+	return stub, err
+}
+
+// DeleteStub removes a stub by ID.
+func (c *Client) DeleteStub(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/__admin/stubs/"+url.PathEscape(id), nil, nil, nil)
+}
+
+// Stubs returns every stub in evaluation order.
+func (c *Client) Stubs(ctx context.Context) ([]Stub, error) {
+	var resp struct {
+		Stubs []Stub `json:"stubs"`
+	}
+	err := c.do(ctx, http.MethodGet, "/__admin/stubs", nil, nil, &resp)
+	return resp.Stubs, err
+}
+
+// ImportHAR registers stubs answering the requests of a HAR file with their
+// recorded responses, and returns them with the entries it skipped. A
+// non-empty host keeps the entries sent to that host; matchHeaders are the
+// request headers the stubs match.
+func (c *Client) ImportHAR(ctx context.Context, file []byte, host string, matchHeaders ...string) ([]Stub, []string, error) {
+	query := url.Values{}
+	if host != "" {
+		query.Set("host", host)
+	}
+	if len(matchHeaders) > 0 {
+		query.Set("match_headers", strings.Join(matchHeaders, ","))
+	}
+	var resp struct {
+		Stubs   []Stub   `json:"stubs"`
+		Skipped []string `json:"skipped"`
+	}
+	err := c.do(ctx, http.MethodPost, "/__admin/stubs/import/har", query, json.RawMessage(file), &resp)
+	return resp.Stubs, resp.Skipped, err
+}
+
+// ResetStubs removes every stub.
+func (c *Client) ResetStubs(ctx context.Context) error {
+	return c.do(ctx, http.MethodDelete, "/__admin/stubs", nil, nil, nil)
+}
+
+// Requests returns the journal entries matching filter.
+func (c *Client) Requests(ctx context.Context, filter Filter) ([]Entry, error) {
+	var resp struct {
+		Requests []Entry `json:"requests"`
+	}
+	err := c.do(ctx, http.MethodGet, "/__admin/requests", filterQuery(filter), nil, &resp)
+	return resp.Requests, err
+}
+
+// ExportGoTest generates a Go test replaying the recorded requests matching
+// filter against an embedded server with the current stubs. pkg and test
+// name the package and test function; empty values use the defaults.
+func (c *Client) ExportGoTest(ctx context.Context, filter Filter, pkg, test string) ([]byte, error) {
+	query := filterQuery(filter)
+	if pkg != "" {
+		query.Set("package", pkg)
+	}
+	if test != "" {
+		query.Set("test", test)
+	}
+	var source []byte
+	err := c.do(ctx, http.MethodGet, "/__admin/requests/export/go", query, nil, &source)
+	return source, err
+}
+
+// ExportHAR returns the recorded HTTP requests matching filter as a HAR
+// file.
+func (c *Client) ExportHAR(ctx context.Context, filter Filter) ([]byte, error) {
+	var file []byte
+	err := c.do(ctx, http.MethodGet, "/__admin/requests/export/har", filterQuery(filter), nil, &file)
+	return file, err
+}
+
+// ResetRequests clears the request journal.
+func (c *Client) ResetRequests(ctx context.Context) error {
+	return c.do(ctx, http.MethodDelete, "/__admin/requests", nil, nil, nil)
+}
+
+// VerificationError reports a request count that didn't match.
+type VerificationError struct {
+	Filter   Filter
+	Expected int
+	Entries  []Entry
+}
+
+func (e *VerificationError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "expected %d request(s) matching %s, got %d", e.Expected, describeFilter(e.Filter), len(e.Entries))
+	for _, entry := range e.Entries {
+		fmt.Fprintf(&b, "\n  %s %s %s %s", entry.ID, entry.Protocol, entry.Method, entry.Path)
+	}
+	return b.String()
+}
+
+// Verify checks that exactly times requests matching filter were recorded.
+// A mismatch is reported as a *VerificationError listing the matches.
+func (c *Client) Verify(ctx context.Context, filter Filter, times int) error {
+	entries, err := c.Requests(ctx, filter)
+	if err != nil {
+		return err
+	}
+	if len(entries) != times {
+		return &VerificationError{Filter: filter, Expected: times, Entries: entries}
+	}
+	return nil
+}
+
+// CreateVerification stores a verification spec and returns it with its
+// assigned ID.
+func (c *Client) CreateVerification(ctx context.Context, spec VerificationSpec) (VerificationSpec, error) {
+	var created VerificationSpec
+	err := c.do(ctx, http.MethodPost, "/__admin/verifications", nil, spec, &created)
+	return created, err
+}
+
+// Verifications returns the stored verification specs.
+func (c *Client) Verifications(ctx context.Context) ([]VerificationSpec, error) {
+	var resp struct {
+		Verifications []VerificationSpec `json:"verifications"`
+	}
+	err := c.do(ctx, http.MethodGet, "/__admin/verifications", nil, nil, &resp)
+	return resp.Verifications, err
+}
+
+// DeleteVerification removes a stored verification spec.
+func (c *Client) DeleteVerification(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/__admin/verifications/"+url.PathEscape(id), nil, nil, nil)
+}
+
+// ResetVerifications removes every stored verification spec.
+func (c *Client) ResetVerifications(ctx context.Context) error {
+	return c.do(ctx, http.MethodDelete, "/__admin/verifications", nil, nil, nil)
+}
+
+// Report evaluates the stored verification specs.
+func (c *Client) Report(ctx context.Context) (Report, error) {
+	var report Report
+	err := c.do(ctx, http.MethodGet, "/__admin/verifications/report", nil, nil, &report)
+	return report, err
+}
+
+// JUnitReport evaluates the stored verification specs and returns the report
+// as JUnit XML.
+func (c *Client) JUnitReport(ctx context.Context) ([]byte, error) {
+	var data []byte
+	query := url.Values{"format": {"junit"}}
+	err := c.do(ctx, http.MethodGet, "/__admin/verifications/report", query, nil, &data)
+	return data, err
+}
+
+// Reset returns the server to a clean state between tests: it removes all
+// stubs and clears the request journal and the HTTP and gRPC attempt
+// counters. Endpoints the server doesn't have (the embedded server has no
+// gRPC side) are skipped.
+func (c *Client) Reset(ctx context.Context) error {
+	for _, path := range []string{"/__admin/stubs", "/__admin/stubs/attempts", "/__admin/grpc/attempts", "/__admin/requests"} {
+		if err := c.do(ctx, http.MethodDelete, path, nil, nil, nil); err != nil && !isNotFound(err) {
+			return fmt.Errorf("reset %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// PushWS sends a message to every /ws/broadcast client, or to the members of
+// room when it is set, and returns how many clients received it.
+func (c *Client) PushWS(ctx context.Context, room, msgType string, data interface{}) (int, error) {
+	body := map[string]interface{}{"room": room, "type": msgType, "data": data}
+	var resp struct {
+		Delivered int `json:"delivered"`
+	}
+	err := c.do(ctx, http.MethodPost, "/__admin/ws/push", nil, body, &resp)
+	return resp.Delivered, err
+}
+
+// PublishEvent publishes an event on the server's event bus, e.g. for gRPC
+// Subscribe clients.
+func (c *Client) PublishEvent(ctx context.Context, topic, eventType string, data interface{}) (Event, error) {
+	body := map[string]interface{}{"topic": topic, "type": eventType, "data": data}
+	var event Event
+	err := c.do(ctx, http.MethodPost, "/__admin/events", nil, body, &event)
+	return event, err
+}
+
+// SetGRPCHealth sets the serving status (SERVING, NOT_SERVING or
+// SERVICE_UNKNOWN) of a gRPC health service; "" is the overall status.
+func (c *Client) SetGRPCHealth(ctx context.Context, service, status string) error {
+	body := map[string]string{"service": service, "status": status}
+	return c.do(ctx, http.MethodPost, "/__admin/grpc/health", nil, body, nil)
+}
+
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, in, out interface{}) error {
+	target := c.baseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("adminclient: encode request: %w", err)
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		apiErr := &Error{StatusCode: resp.StatusCode}
+		var payload struct {
+			Error   string `json:"error"`
+			Details string `json:"details"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&payload) == nil {
+			apiErr.Message = payload.Error
+			apiErr.Details = payload.Details
+		}
+		return apiErr
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if raw, ok := out.(*[]byte); ok {
+		*raw, err = io.ReadAll(resp.Body)
+		return err
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("adminclient: decode %s %s: %w", method, path, err)
+	}
+	return nil
+}
+
+func isNotFound(err error) bool {
+	apiErr, ok := err.(*Error)
+	return ok && apiErr.StatusCode == http.StatusNotFound
+}
+
+func filterQuery(f Filter) url.Values {
+	query := url.Values{}
+	if f.Protocol != "" {
+		query.Set("protocol", f.Protocol)
+	}
+	if f.Method != "" {
+		query.Set("method", f.Method)
+	}
+	if f.Path != "" {
+		query.Set("path", f.Path)
+	}
+	if f.Status != 0 {
+		query.Set("status", strconv.Itoa(f.Status))
+	}
+	if f.Code != "" {
+		query.Set("code", f.Code)
+	}
+	if f.CorrelationID != "" {
+		query.Set("correlation_id", f.CorrelationID)
+	}
+	if f.StubID != "" {
+		query.Set("stub_id", f.StubID)
+	}
+	if !f.Since.IsZero() {
+		query.Set("since", f.Since.Format(time.RFC3339Nano))
+	}
+	if !f.Until.IsZero() {
+		query.Set("until", f.Until.Format(time.RFC3339Nano))
+	}
+	if f.Limit > 0 {
+		query.Set("limit", strconv.Itoa(f.Limit))
+	}
+	return query
+}
+
+func describeFilter(f Filter) string {
+	var parts []string
+	for _, field := range []struct{ name, value string }{
+		{"protocol", f.Protocol},
+		{"method", f.Method},
+		{"path", f.Path},
+		{"code", f.Code},
+		{"correlation_id", f.CorrelationID},
+		{"stub_id", f.StubID},
+	} {
+		if field.value != "" {
+			parts = append(parts, field.name+"="+field.value)
+		}
+	}
+	if f.Status != 0 {
+		parts = append(parts, "status="+strconv.Itoa(f.Status))
+	}
+	if !f.Since.IsZero() {
+		parts = append(parts, "since="+f.Since.Format(time.RFC3339))
+	}
+	if !f.Until.IsZero() {
+		parts = append(parts, "until="+f.Until.Format(time.RFC3339))
+	}
+	if len(parts) == 0 {
+		return "{any}"
+	}
+	return "{" + strings.Join(parts, " ") + "}"
+}
@@ -0,0 +1,79 @@
+package adminclient
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// pollInterval is how often WaitForRequests re-reads the journal.
+const pollInterval = 20 * time.Millisecond
+
+// Isolate resets the server now and again when the test finishes, so tests
+// sharing a server don't see each other's stubs or requests.
+func (c *Client) Isolate(t testing.TB) {
+	t.Helper()
+	if err := c.Reset(context.Background()); err != nil {
+		t.Fatalf("adminclient: reset: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := c.Reset(context.Background()); err != nil {
+			t.Errorf("adminclient: reset: %v", err)
+		}
+	})
+}
+
+// MustCreateStub registers a stub, failing the test on error, and removes it
+// again when the test finishes.
+func (c *Client) MustCreateStub(t testing.TB, stub Stub) Stub {
+	t.Helper()
+	created, err := c.CreateStub(context.Background(), stub)
+	if err != nil {
+		t.Fatalf("adminclient: create stub: %v", err)
+	}
+	t.Cleanup(func() {
+		// A reset from Isolate may already have removed it.
+		if err := c.DeleteStub(context.Background(), created.ID); err != nil && !isNotFound(err) {
+			t.Errorf("adminclient: delete stub %s: %v", created.ID, err)
+		}
+	})
+	return created
+}
+
+// ExpectRequests marks the test failed unless exactly times requests matching
+// filter were recorded.
+func (c *Client) ExpectRequests(t testing.TB, filter Filter, times int) {
+	t.Helper()
+	if err := c.Verify(context.Background(), filter, times); err != nil {
+		t.Error(err)
+	}
+}
+
+// WaitForRequests polls the journal until at least n requests match filter
+// and returns them, failing the test after timeout. Use it when the system
+// under test calls the mock asynchronously.
+func (c *Client) WaitForRequests(t testing.TB, filter Filter, n int, timeout time.Duration) []Entry {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	var entries []Entry
+	for {
+		var err error
+		entries, err = c.Requests(ctx, filter)
+		if err == nil && len(entries) >= n {
+			return entries
+		}
+		select {
+		case <-ctx.Done():
+			if err != nil {
+				t.Fatalf("adminclient: waiting for %d request(s) matching %s: %v", n, describeFilter(filter), err)
+			}
+			t.Fatalf("adminclient: timed out after %s waiting for %d request(s) matching %s, got %d", timeout, n, describeFilter(filter), len(entries))
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
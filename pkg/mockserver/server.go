@@ -0,0 +1,185 @@
+// Package mockserver embeds the mock HTTP server in Go programs and tests.
+//
+// A Server listens on a loopback port and serves the built-in HTTP routes,
+// stubs and admin API of the standalone server. Tests add their own handlers
+// with Handle, typically built with Stream to script chunked responses:
+//
+//	srv, err := mockserver.Start(mockserver.Options{})
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	defer srv.Close()
+//
+//	srv.Handle("GET /events", mockserver.Stream().
+//		Header("Content-Type", "text/event-stream").
+//		Chunk("data: 1\n\n").Flush().
+//		Delay(100*time.Millisecond).
+//		Chunk("data: 2\n\n").Flush())
+//
+//	resp, err := http.Get(srv.URL() + "/events")
+package mockserver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"mockserver/internal/admin"
+	httpHandlers "mockserver/internal/http"
+	"mockserver/internal/journal"
+	"mockserver/internal/logging"
+	"mockserver/internal/panics"
+	"mockserver/internal/stubs"
+	"mockserver/internal/verify"
+)
+
+var logger = logging.For("embedded")
+
+// Options configure an embedded server.
+type Options struct {
+	// Addr is the listen address; the default picks a free loopback port.
+	Addr string
+}
+
+// Server is a running embedded mock server.
+type Server struct {
+	echo     *echo.Echo
+	server   *http.Server
+	listener net.Listener
+	mux      *http.ServeMux
+	journal  *journal.Journal
+	url      string
+}
+
+// Start listens on opts.Addr and serves in the background until Close.
+func Start(opts Options) (*Server, error) {
+	addr := opts.Addr
+	if addr == "" {
+		addr = "127.0.0.1:0"
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		echo:     echo.New(),
+		listener: listener,
+		mux:      http.NewServeMux(),
+		journal:  journal.New(journal.DefaultCapacity),
+		url:      "http://" + listener.Addr().String(),
+	}
+	s.echo.HideBanner = true
+	s.echo.HidePort = true
+
+	// Same pipeline as the standalone server, with the handlers added through
+	// Handle taking precedence over stubs and built-in routes.
+	stubEngine := stubs.NewEngine()
+	panicRecorder := panics.NewRecorder(panics.DefaultCapacity)
+	s.echo.Use(s.journal.Middleware())
+	s.echo.Use(panicRecorder.Middleware())
+	s.echo.Use(s.handlers)
+	s.echo.Use(stubEngine.Middleware())
+
+	httpHandler := httpHandlers.NewHTTPHandlers()
+	s.echo.GET("/health", httpHandler.Health)
+	s.echo.GET("/echo", httpHandler.EchoGet)
+	s.echo.POST("/echo", httpHandler.EchoPost)
+	s.echo.GET("/delay/:seconds", httpHandler.Delay)
+	s.echo.GET("/status/:code", httpHandler.Status)
+
+	stubHandler := admin.NewStubHandlers(stubEngine)
+	s.echo.GET("/__admin/stubs", stubHandler.List)
+	s.echo.POST("/__admin/stubs", stubHandler.Create)
+	s.echo.DELETE("/__admin/stubs", stubHandler.Reset)
+	s.echo.POST("/__admin/stubs/import/har", stubHandler.ImportHAR)
+	s.echo.GET("/__admin/stubs/cache", stubHandler.CacheStats)
+	s.echo.DELETE("/__admin/stubs/cache", stubHandler.FlushCache)
+	s.echo.GET("/__admin/stubs/attempts", stubHandler.Attempts)
+	s.echo.DELETE("/__admin/stubs/attempts", stubHandler.ResetAttempts)
+	s.echo.GET("/__admin/stubs/:id", stubHandler.Get)
+	s.echo.PUT("/__admin/stubs/:id", stubHandler.Update)
+	s.echo.DELETE("/__admin/stubs/:id", stubHandler.Delete)
+
+	requestHandler := admin.NewRequestHandlers(s.journal)
+	s.echo.GET("/__admin/requests", requestHandler.List)
+	s.echo.DELETE("/__admin/requests", requestHandler.Reset)
+	s.echo.GET("/__admin/requests/stats", requestHandler.Stats)
+	s.echo.GET("/__admin/requests/:id", requestHandler.Get)
+	exportHandler := admin.NewExportHandlers(s.journal, stubEngine, nil)
+	s.echo.GET("/__admin/requests/export/go", exportHandler.GoTest)
+	s.echo.GET("/__admin/requests/export/har", exportHandler.HAR)
+
+	errorHandler := admin.NewErrorHandlers(panicRecorder)
+	s.echo.GET("/__admin/errors", errorHandler.List)
+	s.echo.DELETE("/__admin/errors", errorHandler.Reset)
+	s.echo.GET("/__admin/errors/:id", errorHandler.Get)
+
+	verificationHandler := admin.NewVerificationHandlers(verify.NewStore(), s.journal)
+	s.echo.GET("/__admin/verifications", verificationHandler.List)
+	s.echo.POST("/__admin/verifications", verificationHandler.Create)
+	s.echo.DELETE("/__admin/verifications", verificationHandler.Reset)
+	s.echo.GET("/__admin/verifications/report", verificationHandler.Report)
+	s.echo.DELETE("/__admin/verifications/:id", verificationHandler.Delete)
+
+	s.server = &http.Server{Handler: s.echo}
+	go func() {
+		if err := s.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("Embedded server failed", "error", err)
+		}
+	}()
+	return s, nil
+}
+
+// URL returns the base URL, e.g. "http://127.0.0.1:41234".
+func (s *Server) URL() string {
+	return s.url
+}
+
+// Addr returns the address the server listens on.
+func (s *Server) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Handle registers a handler for an http.ServeMux pattern such as
+// "GET /items/{id}". Handlers may be added while the server is running and
+// take precedence over stubs and the built-in routes.
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+// HandleFunc registers a handler function, see Handle.
+func (s *Server) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	s.mux.HandleFunc(pattern, handler)
+}
+
+// Close stops the server, interrupting in-flight streams, and flushes the
+// request journal.
+func (s *Server) Close() error {
+	err := s.server.Close()
+	s.journal.Close()
+	return err
+}
+
+// Shutdown stops the server gracefully, waiting for in-flight requests, and
+// flushes the request journal.
+func (s *Server) Shutdown(ctx context.Context) error {
+	err := s.server.Shutdown(ctx)
+	s.journal.Close()
+	return err
+}
+
+// handlers serves requests matching a pattern registered with Handle.
+func (s *Server) handlers(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if _, pattern := s.mux.Handler(c.Request()); pattern == "" {
+			return next(c)
+		}
+		// Served through the mux so handlers see r.PathValue.
+		s.mux.ServeHTTP(c.Response(), c.Request())
+		return nil
+	}
+}
@@ -0,0 +1,177 @@
+package mockserver
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// StreamBuilder scripts a chunked HTTP response step by step: chunks are
+// written in order, Flush pushes what was written so far to the client and
+// Delay pauses before the next step. A StreamBuilder is an http.Handler and
+// can be registered with Server.Handle (or any other router) directly.
+type StreamBuilder struct {
+	status int
+	header http.Header
+	steps  []streamStep
+}
+
+type streamStep struct {
+	data  []byte
+	flush bool
+	delay time.Duration
+	abort bool
+}
+
+// Stream starts an empty script answering 200 OK.
+func Stream() *StreamBuilder {
+	return &StreamBuilder{status: http.StatusOK, header: http.Header{}}
+}
+
+// Status sets the response status code.
+func (b *StreamBuilder) Status(code int) *StreamBuilder {
+	b.status = code
+	return b
+}
+
+// Header adds a response header.
+func (b *StreamBuilder) Header(name, value string) *StreamBuilder {
+	b.header.Add(name, value)
+	return b
+}
+
+// Chunk writes data without flushing it.
+func (b *StreamBuilder) Chunk(data string) *StreamBuilder {
+	b.steps = append(b.steps, streamStep{data: []byte(data)})
+	return b
+}
+
+// ChunkBytes writes binary data without flushing it.
+func (b *StreamBuilder) ChunkBytes(data []byte) *StreamBuilder {
+	b.steps = append(b.steps, streamStep{data: append([]byte(nil), data...)})
+	return b
+}
+
+// Flush sends the headers and everything written so far.
+func (b *StreamBuilder) Flush() *StreamBuilder {
+	b.steps = append(b.steps, streamStep{flush: true})
+	return b
+}
+
+// Delay pauses before the next step. The stream ends early when the client
+// goes away.
+func (b *StreamBuilder) Delay(d time.Duration) *StreamBuilder {
+	b.steps = append(b.steps, streamStep{delay: d})
+	return b
+}
+
+// Repeat writes and flushes data n times, pausing interval between chunks.
+func (b *StreamBuilder) Repeat(n int, data string, interval time.Duration) *StreamBuilder {
+	for i := 0; i < n; i++ {
+		if i > 0 && interval > 0 {
+			b.Delay(interval)
+		}
+		b.Chunk(data).Flush()
+	}
+	return b
+}
+
+// Abort drops the connection at this point, simulating a server that dies
+// mid-response.
+func (b *StreamBuilder) Abort() *StreamBuilder {
+	b.steps = append(b.steps, streamStep{abort: true})
+	return b
+}
+
+// ServeHTTP plays the script.
+func (b *StreamBuilder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	for name, values := range b.header {
+		w.Header()[name] = append([]string(nil), values...)
+	}
+	w.WriteHeader(b.status)
+
+	stream := newStreamWriter(w, r)
+	for _, step := range b.steps {
+		switch {
+		case step.abort:
+			stream.Flush()
+			panic(http.ErrAbortHandler)
+		case step.delay > 0:
+			if !stream.Sleep(step.delay) {
+				return
+			}
+		case step.flush:
+			if stream.Flush() != nil {
+				return
+			}
+		default:
+			if _, err := stream.Write(step.data); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// StreamFunc adapts a function that drives the response itself, for
+// behaviors a fixed script can't express (e.g. chunks derived from the
+// request).
+func StreamFunc(fn func(*StreamWriter) error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		stream := newStreamWriter(w, r)
+		if err := fn(stream); err != nil && !stream.written {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// StreamWriter writes a streamed response with explicit flushes and delays.
+type StreamWriter struct {
+	http.ResponseWriter
+	Request    *http.Request
+	controller *http.ResponseController
+	written    bool
+}
+
+func newStreamWriter(w http.ResponseWriter, r *http.Request) *StreamWriter {
+	return &StreamWriter{ResponseWriter: w, Request: r, controller: http.NewResponseController(w)}
+}
+
+// Write writes a chunk without flushing it.
+func (s *StreamWriter) Write(data []byte) (int, error) {
+	s.written = true
+	return s.ResponseWriter.Write(data)
+}
+
+// WriteString writes a string chunk without flushing it.
+func (s *StreamWriter) WriteString(data string) (int, error) {
+	return s.Write([]byte(data))
+}
+
+// WriteHeader sends the status code.
+func (s *StreamWriter) WriteHeader(code int) {
+	s.written = true
+	s.ResponseWriter.WriteHeader(code)
+}
+
+// Flush sends everything written so far to the client.
+func (s *StreamWriter) Flush() error {
+	s.written = true
+	return s.controller.Flush()
+}
+
+// Sleep waits for d, returning false when the client went away first.
+func (s *StreamWriter) Sleep(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-s.Request.Context().Done():
+		return false
+	}
+}
+
+// Context is the request context, cancelled when the client goes away.
+func (s *StreamWriter) Context() context.Context {
+	return s.Request.Context()
+}
@@ -9,6 +9,11 @@ package proto
 import (
 	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
 	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	anypb "google.golang.org/protobuf/types/known/anypb"
+	durationpb "google.golang.org/protobuf/types/known/durationpb"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
 	reflect "reflect"
 	sync "sync"
 	unsafe "unsafe"
@@ -21,6 +26,63 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+// Serialization test messages
+type Color int32
+
+const (
+	Color_COLOR_UNSPECIFIED Color = 0
+	Color_COLOR_RED         Color = 1
+	Color_COLOR_GREEN       Color = 2
+	Color_COLOR_BLUE        Color = 3
+	// Negative values are encoded as 10-byte varints
+	Color_COLOR_NEGATIVE Color = -1
+)
+
+// Enum value maps for Color.
+var (
+	Color_name = map[int32]string{
+		0:  "COLOR_UNSPECIFIED",
+		1:  "COLOR_RED",
+		2:  "COLOR_GREEN",
+		3:  "COLOR_BLUE",
+		-1: "COLOR_NEGATIVE",
+	}
+	Color_value = map[string]int32{
+		"COLOR_UNSPECIFIED": 0,
+		"COLOR_RED":         1,
+		"COLOR_GREEN":       2,
+		"COLOR_BLUE":        3,
+		"COLOR_NEGATIVE":    -1,
+	}
+)
+
+func (x Color) Enum() *Color {
+	p := new(Color)
+	*p = x
+	return p
+}
+
+func (x Color) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Color) Descriptor() protoreflect.EnumDescriptor {
+	return file_proto_mock_proto_enumTypes[0].Descriptor()
+}
+
+func (Color) Type() protoreflect.EnumType {
+	return &file_proto_mock_proto_enumTypes[0]
+}
+
+func (x Color) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Color.Descriptor instead.
+func (Color) EnumDescriptor() ([]byte, []int) {
+	return file_proto_mock_proto_rawDescGZIP(), []int{0}
+}
+
 // Simple message for unary calls
 type SimpleRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
@@ -128,9 +190,17 @@ func (x *SimpleResponse) GetTimestamp() int64 {
 
 // Streaming messages
 type StreamRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
-	Data          string                 `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Data  string                 `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+	// Number of messages to send (ServerStream default 5); BidiStream echoes
+	// each message this many times (default 1)
+	Count int32 `protobuf:"varint,3,opt,name=count,proto3" json:"count,omitempty"`
+	// Delay between messages in milliseconds (ServerStream default 100);
+	// BidiStream waits this long before each echo (default 0)
+	IntervalMs *int32 `protobuf:"varint,4,opt,name=interval_ms,json=intervalMs,proto3,oneof" json:"interval_ms,omitempty"`
+	// Size in bytes of the payload attached to each message
+	PayloadSize   int32 `protobuf:"varint,5,opt,name=payload_size,json=payloadSize,proto3" json:"payload_size,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -179,12 +249,34 @@ func (x *StreamRequest) GetData() string {
 	return ""
 }
 
+func (x *StreamRequest) GetCount() int32 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+func (x *StreamRequest) GetIntervalMs() int32 {
+	if x != nil && x.IntervalMs != nil {
+		return *x.IntervalMs
+	}
+	return 0
+}
+
+func (x *StreamRequest) GetPayloadSize() int32 {
+	if x != nil {
+		return x.PayloadSize
+	}
+	return 0
+}
+
 type StreamResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
 	Data          string                 `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
 	Timestamp     int64                  `protobuf:"varint,3,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
 	Sequence      int32                  `protobuf:"varint,4,opt,name=sequence,proto3" json:"sequence,omitempty"`
+	Payload       []byte                 `protobuf:"bytes,5,opt,name=payload,proto3" json:"payload,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -247,84 +339,1390 @@ func (x *StreamResponse) GetSequence() int32 {
 	return 0
 }
 
-var File_proto_mock_proto protoreflect.FileDescriptor
+func (x *StreamResponse) GetPayload() []byte {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
 
-const file_proto_mock_proto_rawDesc = "" +
-	"\n" +
-	"\x10proto/mock.proto\x12\x04mock\"?\n" +
-	"\rSimpleRequest\x12\x18\n" +
-	"\amessage\x18\x01 \x01(\tR\amessage\x12\x14\n" +
-	"\x05value\x18\x02 \x01(\x05R\x05value\"H\n" +
-	"\x0eSimpleResponse\x12\x18\n" +
-	"\amessage\x18\x01 \x01(\tR\amessage\x12\x1c\n" +
-	"\ttimestamp\x18\x02 \x01(\x03R\ttimestamp\"3\n" +
-	"\rStreamRequest\x12\x0e\n" +
-	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
-	"\x04data\x18\x02 \x01(\tR\x04data\"n\n" +
-	"\x0eStreamResponse\x12\x0e\n" +
-	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
-	"\x04data\x18\x02 \x01(\tR\x04data\x12\x1c\n" +
-	"\ttimestamp\x18\x03 \x01(\x03R\ttimestamp\x12\x1a\n" +
-	"\bsequence\x18\x04 \x01(\x05R\bsequence2\xf7\x01\n" +
-	"\vMockService\x121\n" +
-	"\x04Echo\x12\x13.mock.SimpleRequest\x1a\x14.mock.SimpleResponse\x12;\n" +
-	"\fServerStream\x12\x13.mock.StreamRequest\x1a\x14.mock.StreamResponse0\x01\x12;\n" +
-	"\fClientStream\x12\x13.mock.StreamRequest\x1a\x14.mock.SimpleResponse(\x01\x12;\n" +
-	"\n" +
-	"BidiStream\x12\x13.mock.StreamRequest\x1a\x14.mock.StreamResponse(\x010\x01B\x12Z\x10mockserver/protob\x06proto3"
+// Event bus messages
+type StreamFilter struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Topics to receive, e.g. "ws.broadcast" or "ws.chat.*"; empty receives all
+	Topics []string `protobuf:"bytes,1,rep,name=topics,proto3" json:"topics,omitempty"`
+	// Event types to receive; empty receives all
+	Types         []string `protobuf:"bytes,2,rep,name=types,proto3" json:"types,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
 
-var (
-	file_proto_mock_proto_rawDescOnce sync.Once
-	file_proto_mock_proto_rawDescData []byte
-)
+func (x *StreamFilter) Reset() {
+	*x = StreamFilter{}
+	mi := &file_proto_mock_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
 
-func file_proto_mock_proto_rawDescGZIP() []byte {
-	file_proto_mock_proto_rawDescOnce.Do(func() {
-		file_proto_mock_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_proto_mock_proto_rawDesc), len(file_proto_mock_proto_rawDesc)))
-	})
-	return file_proto_mock_proto_rawDescData
+func (x *StreamFilter) String() string {
+	return protoimpl.X.MessageStringOf(x)
 }
 
-var file_proto_mock_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
-var file_proto_mock_proto_goTypes = []any{
-	(*SimpleRequest)(nil),  // 0: mock.SimpleRequest
-	(*SimpleResponse)(nil), // 1: mock.SimpleResponse
-	(*StreamRequest)(nil),  // 2: mock.StreamRequest
-	(*StreamResponse)(nil), // 3: mock.StreamResponse
+func (*StreamFilter) ProtoMessage() {}
+
+func (x *StreamFilter) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_mock_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
 }
-var file_proto_mock_proto_depIdxs = []int32{
-	0, // 0: mock.MockService.Echo:input_type -> mock.SimpleRequest
-	2, // 1: mock.MockService.ServerStream:input_type -> mock.StreamRequest
-	2, // 2: mock.MockService.ClientStream:input_type -> mock.StreamRequest
-	2, // 3: mock.MockService.BidiStream:input_type -> mock.StreamRequest
-	1, // 4: mock.MockService.Echo:output_type -> mock.SimpleResponse
-	3, // 5: mock.MockService.ServerStream:output_type -> mock.StreamResponse
-	1, // 6: mock.MockService.ClientStream:output_type -> mock.SimpleResponse
-	3, // 7: mock.MockService.BidiStream:output_type -> mock.StreamResponse
-	4, // [4:8] is the sub-list for method output_type
-	0, // [0:4] is the sub-list for method input_type
-	0, // [0:0] is the sub-list for extension type_name
-	0, // [0:0] is the sub-list for extension extendee
-	0, // [0:0] is the sub-list for field type_name
+
+// Deprecated: Use StreamFilter.ProtoReflect.Descriptor instead.
+func (*StreamFilter) Descriptor() ([]byte, []int) {
+	return file_proto_mock_proto_rawDescGZIP(), []int{4}
 }
 
-func init() { file_proto_mock_proto_init() }
-func file_proto_mock_proto_init() {
-	if File_proto_mock_proto != nil {
-		return
+func (x *StreamFilter) GetTopics() []string {
+	if x != nil {
+		return x.Topics
+	}
+	return nil
+}
+
+func (x *StreamFilter) GetTypes() []string {
+	if x != nil {
+		return x.Types
+	}
+	return nil
+}
+
+type Event struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Id        string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Topic     string                 `protobuf:"bytes,2,opt,name=topic,proto3" json:"topic,omitempty"`
+	Type      string                 `protobuf:"bytes,3,opt,name=type,proto3" json:"type,omitempty"`
+	Source    string                 `protobuf:"bytes,4,opt,name=source,proto3" json:"source,omitempty"`
+	Data      *structpb.Value        `protobuf:"bytes,5,opt,name=data,proto3" json:"data,omitempty"`
+	Timestamp int64                  `protobuf:"varint,6,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	// X-Mock-Test-ID or trace ID of the request that caused the event.
+	CorrelationId string `protobuf:"bytes,7,opt,name=correlation_id,json=correlationId,proto3" json:"correlation_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Event) Reset() {
+	*x = Event{}
+	mi := &file_proto_mock_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Event) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Event) ProtoMessage() {}
+
+func (x *Event) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_mock_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Event.ProtoReflect.Descriptor instead.
+func (*Event) Descriptor() ([]byte, []int) {
+	return file_proto_mock_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Event) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Event) GetTopic() string {
+	if x != nil {
+		return x.Topic
+	}
+	return ""
+}
+
+func (x *Event) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *Event) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *Event) GetData() *structpb.Value {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *Event) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *Event) GetCorrelationId() string {
+	if x != nil {
+		return x.CorrelationId
+	}
+	return ""
+}
+
+// Any and unknown field test messages
+type AnyRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Optional payload echoed back as the first entry of the response
+	Echo          *anypb.Any `protobuf:"bytes,1,opt,name=echo,proto3" json:"echo,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AnyRequest) Reset() {
+	*x = AnyRequest{}
+	mi := &file_proto_mock_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AnyRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AnyRequest) ProtoMessage() {}
+
+func (x *AnyRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_mock_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AnyRequest.ProtoReflect.Descriptor instead.
+func (*AnyRequest) Descriptor() ([]byte, []int) {
+	return file_proto_mock_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *AnyRequest) GetEcho() *anypb.Any {
+	if x != nil {
+		return x.Echo
+	}
+	return nil
+}
+
+type AnyResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Payloads      []*anypb.Any           `protobuf:"bytes,1,rep,name=payloads,proto3" json:"payloads,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AnyResponse) Reset() {
+	*x = AnyResponse{}
+	mi := &file_proto_mock_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AnyResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AnyResponse) ProtoMessage() {}
+
+func (x *AnyResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_mock_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AnyResponse.ProtoReflect.Descriptor instead.
+func (*AnyResponse) Descriptor() ([]byte, []int) {
+	return file_proto_mock_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *AnyResponse) GetPayloads() []*anypb.Any {
+	if x != nil {
+		return x.Payloads
+	}
+	return nil
+}
+
+type UnknownFieldsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Message       string                 `protobuf:"bytes,1,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UnknownFieldsRequest) Reset() {
+	*x = UnknownFieldsRequest{}
+	mi := &file_proto_mock_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UnknownFieldsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UnknownFieldsRequest) ProtoMessage() {}
+
+func (x *UnknownFieldsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_mock_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UnknownFieldsRequest.ProtoReflect.Descriptor instead.
+func (*UnknownFieldsRequest) Descriptor() ([]byte, []int) {
+	return file_proto_mock_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *UnknownFieldsRequest) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+type Nested struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Depth         int32                  `protobuf:"varint,2,opt,name=depth,proto3" json:"depth,omitempty"`
+	Child         *Nested                `protobuf:"bytes,3,opt,name=child,proto3" json:"child,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Nested) Reset() {
+	*x = Nested{}
+	mi := &file_proto_mock_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Nested) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Nested) ProtoMessage() {}
+
+func (x *Nested) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_mock_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Nested.ProtoReflect.Descriptor instead.
+func (*Nested) Descriptor() ([]byte, []int) {
+	return file_proto_mock_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *Nested) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Nested) GetDepth() int32 {
+	if x != nil {
+		return x.Depth
+	}
+	return 0
+}
+
+func (x *Nested) GetChild() *Nested {
+	if x != nil {
+		return x.Child
+	}
+	return nil
+}
+
+// AllTypes covers every proto3 field kind
+type AllTypes struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Scalars
+	DoubleValue   float64 `protobuf:"fixed64,1,opt,name=double_value,json=doubleValue,proto3" json:"double_value,omitempty"`
+	FloatValue    float32 `protobuf:"fixed32,2,opt,name=float_value,json=floatValue,proto3" json:"float_value,omitempty"`
+	Int32Value    int32   `protobuf:"varint,3,opt,name=int32_value,json=int32Value,proto3" json:"int32_value,omitempty"`
+	Int64Value    int64   `protobuf:"varint,4,opt,name=int64_value,json=int64Value,proto3" json:"int64_value,omitempty"`
+	Uint32Value   uint32  `protobuf:"varint,5,opt,name=uint32_value,json=uint32Value,proto3" json:"uint32_value,omitempty"`
+	Uint64Value   uint64  `protobuf:"varint,6,opt,name=uint64_value,json=uint64Value,proto3" json:"uint64_value,omitempty"`
+	Sint32Value   int32   `protobuf:"zigzag32,7,opt,name=sint32_value,json=sint32Value,proto3" json:"sint32_value,omitempty"`
+	Sint64Value   int64   `protobuf:"zigzag64,8,opt,name=sint64_value,json=sint64Value,proto3" json:"sint64_value,omitempty"`
+	Fixed32Value  uint32  `protobuf:"fixed32,9,opt,name=fixed32_value,json=fixed32Value,proto3" json:"fixed32_value,omitempty"`
+	Fixed64Value  uint64  `protobuf:"fixed64,10,opt,name=fixed64_value,json=fixed64Value,proto3" json:"fixed64_value,omitempty"`
+	Sfixed32Value int32   `protobuf:"fixed32,11,opt,name=sfixed32_value,json=sfixed32Value,proto3" json:"sfixed32_value,omitempty"`
+	Sfixed64Value int64   `protobuf:"fixed64,12,opt,name=sfixed64_value,json=sfixed64Value,proto3" json:"sfixed64_value,omitempty"`
+	BoolValue     bool    `protobuf:"varint,13,opt,name=bool_value,json=boolValue,proto3" json:"bool_value,omitempty"`
+	StringValue   string  `protobuf:"bytes,14,opt,name=string_value,json=stringValue,proto3" json:"string_value,omitempty"`
+	BytesValue    []byte  `protobuf:"bytes,15,opt,name=bytes_value,json=bytesValue,proto3" json:"bytes_value,omitempty"`
+	// Explicit presence
+	OptionalInt32  *int32  `protobuf:"varint,16,opt,name=optional_int32,json=optionalInt32,proto3,oneof" json:"optional_int32,omitempty"`
+	OptionalString *string `protobuf:"bytes,17,opt,name=optional_string,json=optionalString,proto3,oneof" json:"optional_string,omitempty"`
+	// Enums and messages
+	Color  Color   `protobuf:"varint,18,opt,name=color,proto3,enum=mock.Color" json:"color,omitempty"`
+	Nested *Nested `protobuf:"bytes,19,opt,name=nested,proto3" json:"nested,omitempty"`
+	// Repeated fields (packed and unpacked kinds)
+	RepeatedInt32  []int32   `protobuf:"varint,20,rep,packed,name=repeated_int32,json=repeatedInt32,proto3" json:"repeated_int32,omitempty"`
+	RepeatedDouble []float64 `protobuf:"fixed64,21,rep,packed,name=repeated_double,json=repeatedDouble,proto3" json:"repeated_double,omitempty"`
+	RepeatedString []string  `protobuf:"bytes,22,rep,name=repeated_string,json=repeatedString,proto3" json:"repeated_string,omitempty"`
+	RepeatedBytes  [][]byte  `protobuf:"bytes,23,rep,name=repeated_bytes,json=repeatedBytes,proto3" json:"repeated_bytes,omitempty"`
+	RepeatedColor  []Color   `protobuf:"varint,24,rep,packed,name=repeated_color,json=repeatedColor,proto3,enum=mock.Color" json:"repeated_color,omitempty"`
+	RepeatedNested []*Nested `protobuf:"bytes,25,rep,name=repeated_nested,json=repeatedNested,proto3" json:"repeated_nested,omitempty"`
+	// Maps
+	StringMap map[string]string `protobuf:"bytes,26,rep,name=string_map,json=stringMap,proto3" json:"string_map,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	IntMap    map[int64]*Nested `protobuf:"bytes,27,rep,name=int_map,json=intMap,proto3" json:"int_map,omitempty" protobuf_key:"varint,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	BoolMap   map[bool][]byte   `protobuf:"bytes,28,rep,name=bool_map,json=boolMap,proto3" json:"bool_map,omitempty" protobuf_key:"varint,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	EnumMap   map[uint32]Color  `protobuf:"bytes,29,rep,name=enum_map,json=enumMap,proto3" json:"enum_map,omitempty" protobuf_key:"varint,1,opt,name=key" protobuf_val:"varint,2,opt,name=value,enum=mock.Color"`
+	// Oneof
+	//
+	// Types that are valid to be assigned to Choice:
+	//
+	//	*AllTypes_ChoiceString
+	//	*AllTypes_ChoiceInt
+	//	*AllTypes_ChoiceNested
+	//	*AllTypes_ChoiceColor
+	Choice isAllTypes_Choice `protobuf_oneof:"choice"`
+	// Well-known types
+	Timestamp     *timestamppb.Timestamp `protobuf:"bytes,34,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Duration      *durationpb.Duration   `protobuf:"bytes,35,opt,name=duration,proto3" json:"duration,omitempty"`
+	Any           *anypb.Any             `protobuf:"bytes,36,opt,name=any,proto3" json:"any,omitempty"`
+	StructValue   *structpb.Struct       `protobuf:"bytes,37,opt,name=struct_value,json=structValue,proto3" json:"struct_value,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AllTypes) Reset() {
+	*x = AllTypes{}
+	mi := &file_proto_mock_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AllTypes) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AllTypes) ProtoMessage() {}
+
+func (x *AllTypes) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_mock_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AllTypes.ProtoReflect.Descriptor instead.
+func (*AllTypes) Descriptor() ([]byte, []int) {
+	return file_proto_mock_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *AllTypes) GetDoubleValue() float64 {
+	if x != nil {
+		return x.DoubleValue
+	}
+	return 0
+}
+
+func (x *AllTypes) GetFloatValue() float32 {
+	if x != nil {
+		return x.FloatValue
+	}
+	return 0
+}
+
+func (x *AllTypes) GetInt32Value() int32 {
+	if x != nil {
+		return x.Int32Value
+	}
+	return 0
+}
+
+func (x *AllTypes) GetInt64Value() int64 {
+	if x != nil {
+		return x.Int64Value
+	}
+	return 0
+}
+
+func (x *AllTypes) GetUint32Value() uint32 {
+	if x != nil {
+		return x.Uint32Value
+	}
+	return 0
+}
+
+func (x *AllTypes) GetUint64Value() uint64 {
+	if x != nil {
+		return x.Uint64Value
+	}
+	return 0
+}
+
+func (x *AllTypes) GetSint32Value() int32 {
+	if x != nil {
+		return x.Sint32Value
+	}
+	return 0
+}
+
+func (x *AllTypes) GetSint64Value() int64 {
+	if x != nil {
+		return x.Sint64Value
+	}
+	return 0
+}
+
+func (x *AllTypes) GetFixed32Value() uint32 {
+	if x != nil {
+		return x.Fixed32Value
+	}
+	return 0
+}
+
+func (x *AllTypes) GetFixed64Value() uint64 {
+	if x != nil {
+		return x.Fixed64Value
+	}
+	return 0
+}
+
+func (x *AllTypes) GetSfixed32Value() int32 {
+	if x != nil {
+		return x.Sfixed32Value
+	}
+	return 0
+}
+
+func (x *AllTypes) GetSfixed64Value() int64 {
+	if x != nil {
+		return x.Sfixed64Value
+	}
+	return 0
+}
+
+func (x *AllTypes) GetBoolValue() bool {
+	if x != nil {
+		return x.BoolValue
+	}
+	return false
+}
+
+func (x *AllTypes) GetStringValue() string {
+	if x != nil {
+		return x.StringValue
+	}
+	return ""
+}
+
+func (x *AllTypes) GetBytesValue() []byte {
+	if x != nil {
+		return x.BytesValue
+	}
+	return nil
+}
+
+func (x *AllTypes) GetOptionalInt32() int32 {
+	if x != nil && x.OptionalInt32 != nil {
+		return *x.OptionalInt32
+	}
+	return 0
+}
+
+func (x *AllTypes) GetOptionalString() string {
+	if x != nil && x.OptionalString != nil {
+		return *x.OptionalString
+	}
+	return ""
+}
+
+func (x *AllTypes) GetColor() Color {
+	if x != nil {
+		return x.Color
+	}
+	return Color_COLOR_UNSPECIFIED
+}
+
+func (x *AllTypes) GetNested() *Nested {
+	if x != nil {
+		return x.Nested
+	}
+	return nil
+}
+
+func (x *AllTypes) GetRepeatedInt32() []int32 {
+	if x != nil {
+		return x.RepeatedInt32
+	}
+	return nil
+}
+
+func (x *AllTypes) GetRepeatedDouble() []float64 {
+	if x != nil {
+		return x.RepeatedDouble
+	}
+	return nil
+}
+
+func (x *AllTypes) GetRepeatedString() []string {
+	if x != nil {
+		return x.RepeatedString
+	}
+	return nil
+}
+
+func (x *AllTypes) GetRepeatedBytes() [][]byte {
+	if x != nil {
+		return x.RepeatedBytes
+	}
+	return nil
+}
+
+func (x *AllTypes) GetRepeatedColor() []Color {
+	if x != nil {
+		return x.RepeatedColor
+	}
+	return nil
+}
+
+func (x *AllTypes) GetRepeatedNested() []*Nested {
+	if x != nil {
+		return x.RepeatedNested
+	}
+	return nil
+}
+
+func (x *AllTypes) GetStringMap() map[string]string {
+	if x != nil {
+		return x.StringMap
+	}
+	return nil
+}
+
+func (x *AllTypes) GetIntMap() map[int64]*Nested {
+	if x != nil {
+		return x.IntMap
+	}
+	return nil
+}
+
+func (x *AllTypes) GetBoolMap() map[bool][]byte {
+	if x != nil {
+		return x.BoolMap
+	}
+	return nil
+}
+
+func (x *AllTypes) GetEnumMap() map[uint32]Color {
+	if x != nil {
+		return x.EnumMap
+	}
+	return nil
+}
+
+func (x *AllTypes) GetChoice() isAllTypes_Choice {
+	if x != nil {
+		return x.Choice
+	}
+	return nil
+}
+
+func (x *AllTypes) GetChoiceString() string {
+	if x != nil {
+		if x, ok := x.Choice.(*AllTypes_ChoiceString); ok {
+			return x.ChoiceString
+		}
+	}
+	return ""
+}
+
+func (x *AllTypes) GetChoiceInt() int64 {
+	if x != nil {
+		if x, ok := x.Choice.(*AllTypes_ChoiceInt); ok {
+			return x.ChoiceInt
+		}
+	}
+	return 0
+}
+
+func (x *AllTypes) GetChoiceNested() *Nested {
+	if x != nil {
+		if x, ok := x.Choice.(*AllTypes_ChoiceNested); ok {
+			return x.ChoiceNested
+		}
+	}
+	return nil
+}
+
+func (x *AllTypes) GetChoiceColor() Color {
+	if x != nil {
+		if x, ok := x.Choice.(*AllTypes_ChoiceColor); ok {
+			return x.ChoiceColor
+		}
+	}
+	return Color_COLOR_UNSPECIFIED
+}
+
+func (x *AllTypes) GetTimestamp() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Timestamp
+	}
+	return nil
+}
+
+func (x *AllTypes) GetDuration() *durationpb.Duration {
+	if x != nil {
+		return x.Duration
+	}
+	return nil
+}
+
+func (x *AllTypes) GetAny() *anypb.Any {
+	if x != nil {
+		return x.Any
+	}
+	return nil
+}
+
+func (x *AllTypes) GetStructValue() *structpb.Struct {
+	if x != nil {
+		return x.StructValue
+	}
+	return nil
+}
+
+type isAllTypes_Choice interface {
+	isAllTypes_Choice()
+}
+
+type AllTypes_ChoiceString struct {
+	ChoiceString string `protobuf:"bytes,30,opt,name=choice_string,json=choiceString,proto3,oneof"`
+}
+
+type AllTypes_ChoiceInt struct {
+	ChoiceInt int64 `protobuf:"varint,31,opt,name=choice_int,json=choiceInt,proto3,oneof"`
+}
+
+type AllTypes_ChoiceNested struct {
+	ChoiceNested *Nested `protobuf:"bytes,32,opt,name=choice_nested,json=choiceNested,proto3,oneof"`
+}
+
+type AllTypes_ChoiceColor struct {
+	ChoiceColor Color `protobuf:"varint,33,opt,name=choice_color,json=choiceColor,proto3,enum=mock.Color,oneof"`
+}
+
+func (*AllTypes_ChoiceString) isAllTypes_Choice() {}
+
+func (*AllTypes_ChoiceInt) isAllTypes_Choice() {}
+
+func (*AllTypes_ChoiceNested) isAllTypes_Choice() {}
+
+func (*AllTypes_ChoiceColor) isAllTypes_Choice() {}
+
+type OversizedRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Response size (serialized bytes) the client accepts; the response is
+	// larger than this by overshoot_bytes
+	LimitBytes int64 `protobuf:"varint,1,opt,name=limit_bytes,json=limitBytes,proto3" json:"limit_bytes,omitempty"`
+	// How far past the limit the response goes (default 1)
+	OvershootBytes int64 `protobuf:"varint,2,opt,name=overshoot_bytes,json=overshootBytes,proto3" json:"overshoot_bytes,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *OversizedRequest) Reset() {
+	*x = OversizedRequest{}
+	mi := &file_proto_mock_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *OversizedRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OversizedRequest) ProtoMessage() {}
+
+func (x *OversizedRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_mock_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OversizedRequest.ProtoReflect.Descriptor instead.
+func (*OversizedRequest) Descriptor() ([]byte, []int) {
+	return file_proto_mock_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *OversizedRequest) GetLimitBytes() int64 {
+	if x != nil {
+		return x.LimitBytes
+	}
+	return 0
+}
+
+func (x *OversizedRequest) GetOvershootBytes() int64 {
+	if x != nil {
+		return x.OvershootBytes
+	}
+	return 0
+}
+
+type OversizedResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Serialized size of this response
+	SizeBytes     int64  `protobuf:"varint,1,opt,name=size_bytes,json=sizeBytes,proto3" json:"size_bytes,omitempty"`
+	Payload       []byte `protobuf:"bytes,2,opt,name=payload,proto3" json:"payload,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *OversizedResponse) Reset() {
+	*x = OversizedResponse{}
+	mi := &file_proto_mock_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *OversizedResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OversizedResponse) ProtoMessage() {}
+
+func (x *OversizedResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_mock_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OversizedResponse.ProtoReflect.Descriptor instead.
+func (*OversizedResponse) Descriptor() ([]byte, []int) {
+	return file_proto_mock_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *OversizedResponse) GetSizeBytes() int64 {
+	if x != nil {
+		return x.SizeBytes
+	}
+	return 0
+}
+
+func (x *OversizedResponse) GetPayload() []byte {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+type PayloadRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Number of payload bytes to return
+	SizeBytes int64 `protobuf:"varint,1,opt,name=size_bytes,json=sizeBytes,proto3" json:"size_bytes,omitempty"`
+	// Payload content: "zeros" (default), "sequence" (0x00..0xff repeating) or
+	// "random"
+	Fill string `protobuf:"bytes,2,opt,name=fill,proto3" json:"fill,omitempty"`
+	// Optional upload, reported back with its checksum
+	Payload       []byte `protobuf:"bytes,3,opt,name=payload,proto3" json:"payload,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PayloadRequest) Reset() {
+	*x = PayloadRequest{}
+	mi := &file_proto_mock_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PayloadRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PayloadRequest) ProtoMessage() {}
+
+func (x *PayloadRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_mock_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PayloadRequest.ProtoReflect.Descriptor instead.
+func (*PayloadRequest) Descriptor() ([]byte, []int) {
+	return file_proto_mock_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *PayloadRequest) GetSizeBytes() int64 {
+	if x != nil {
+		return x.SizeBytes
+	}
+	return 0
+}
+
+func (x *PayloadRequest) GetFill() string {
+	if x != nil {
+		return x.Fill
+	}
+	return ""
+}
+
+func (x *PayloadRequest) GetPayload() []byte {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+type PayloadResponse struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Payload []byte                 `protobuf:"bytes,1,opt,name=payload,proto3" json:"payload,omitempty"`
+	// Hex SHA-256 of payload
+	Sha256    string `protobuf:"bytes,2,opt,name=sha256,proto3" json:"sha256,omitempty"`
+	SizeBytes int64  `protobuf:"varint,3,opt,name=size_bytes,json=sizeBytes,proto3" json:"size_bytes,omitempty"`
+	// Size and hex SHA-256 of the uploaded payload
+	ReceivedBytes  int64  `protobuf:"varint,4,opt,name=received_bytes,json=receivedBytes,proto3" json:"received_bytes,omitempty"`
+	ReceivedSha256 string `protobuf:"bytes,5,opt,name=received_sha256,json=receivedSha256,proto3" json:"received_sha256,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *PayloadResponse) Reset() {
+	*x = PayloadResponse{}
+	mi := &file_proto_mock_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PayloadResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PayloadResponse) ProtoMessage() {}
+
+func (x *PayloadResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_mock_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PayloadResponse.ProtoReflect.Descriptor instead.
+func (*PayloadResponse) Descriptor() ([]byte, []int) {
+	return file_proto_mock_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *PayloadResponse) GetPayload() []byte {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+func (x *PayloadResponse) GetSha256() string {
+	if x != nil {
+		return x.Sha256
+	}
+	return ""
+}
+
+func (x *PayloadResponse) GetSizeBytes() int64 {
+	if x != nil {
+		return x.SizeBytes
+	}
+	return 0
+}
+
+func (x *PayloadResponse) GetReceivedBytes() int64 {
+	if x != nil {
+		return x.ReceivedBytes
+	}
+	return 0
+}
+
+func (x *PayloadResponse) GetReceivedSha256() string {
+	if x != nil {
+		return x.ReceivedSha256
+	}
+	return ""
+}
+
+type DeadlineRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// How long to keep working past the client's deadline (default 1000);
+	// without a deadline the server works for this long
+	OverrunMs int64 `protobuf:"varint,1,opt,name=overrun_ms,json=overrunMs,proto3" json:"overrun_ms,omitempty"`
+	// How often ctx.Err() is checked (default 50)
+	CheckIntervalMs int64 `protobuf:"varint,2,opt,name=check_interval_ms,json=checkIntervalMs,proto3" json:"check_interval_ms,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *DeadlineRequest) Reset() {
+	*x = DeadlineRequest{}
+	mi := &file_proto_mock_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeadlineRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeadlineRequest) ProtoMessage() {}
+
+func (x *DeadlineRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_mock_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeadlineRequest.ProtoReflect.Descriptor instead.
+func (*DeadlineRequest) Descriptor() ([]byte, []int) {
+	return file_proto_mock_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *DeadlineRequest) GetOverrunMs() int64 {
+	if x != nil {
+		return x.OverrunMs
+	}
+	return 0
+}
+
+func (x *DeadlineRequest) GetCheckIntervalMs() int64 {
+	if x != nil {
+		return x.CheckIntervalMs
+	}
+	return 0
+}
+
+type DeadlineResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Whether the call carried a deadline, and how much of it was left on arrival
+	DeadlineSet         bool  `protobuf:"varint,1,opt,name=deadline_set,json=deadlineSet,proto3" json:"deadline_set,omitempty"`
+	DeadlineRemainingMs int64 `protobuf:"varint,2,opt,name=deadline_remaining_ms,json=deadlineRemainingMs,proto3" json:"deadline_remaining_ms,omitempty"`
+	WorkedMs            int64 `protobuf:"varint,3,opt,name=worked_ms,json=workedMs,proto3" json:"worked_ms,omitempty"`
+	unknownFields       protoimpl.UnknownFields
+	sizeCache           protoimpl.SizeCache
+}
+
+func (x *DeadlineResponse) Reset() {
+	*x = DeadlineResponse{}
+	mi := &file_proto_mock_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeadlineResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeadlineResponse) ProtoMessage() {}
+
+func (x *DeadlineResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_mock_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeadlineResponse.ProtoReflect.Descriptor instead.
+func (*DeadlineResponse) Descriptor() ([]byte, []int) {
+	return file_proto_mock_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *DeadlineResponse) GetDeadlineSet() bool {
+	if x != nil {
+		return x.DeadlineSet
+	}
+	return false
+}
+
+func (x *DeadlineResponse) GetDeadlineRemainingMs() int64 {
+	if x != nil {
+		return x.DeadlineRemainingMs
+	}
+	return 0
+}
+
+func (x *DeadlineResponse) GetWorkedMs() int64 {
+	if x != nil {
+		return x.WorkedMs
+	}
+	return 0
+}
+
+var File_proto_mock_proto protoreflect.FileDescriptor
+
+const file_proto_mock_proto_rawDesc = "" +
+	"\n" +
+	"\x10proto/mock.proto\x12\x04mock\x1a\x19google/protobuf/any.proto\x1a\x1egoogle/protobuf/duration.proto\x1a\x1bgoogle/protobuf/empty.proto\x1a\x1cgoogle/protobuf/struct.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"?\n" +
+	"\rSimpleRequest\x12\x18\n" +
+	"\amessage\x18\x01 \x01(\tR\amessage\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x05R\x05value\"H\n" +
+	"\x0eSimpleResponse\x12\x18\n" +
+	"\amessage\x18\x01 \x01(\tR\amessage\x12\x1c\n" +
+	"\ttimestamp\x18\x02 \x01(\x03R\ttimestamp\"\xa2\x01\n" +
+	"\rStreamRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04data\x18\x02 \x01(\tR\x04data\x12\x14\n" +
+	"\x05count\x18\x03 \x01(\x05R\x05count\x12$\n" +
+	"\vinterval_ms\x18\x04 \x01(\x05H\x00R\n" +
+	"intervalMs\x88\x01\x01\x12!\n" +
+	"\fpayload_size\x18\x05 \x01(\x05R\vpayloadSizeB\x0e\n" +
+	"\f_interval_ms\"\x88\x01\n" +
+	"\x0eStreamResponse\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04data\x18\x02 \x01(\tR\x04data\x12\x1c\n" +
+	"\ttimestamp\x18\x03 \x01(\x03R\ttimestamp\x12\x1a\n" +
+	"\bsequence\x18\x04 \x01(\x05R\bsequence\x12\x18\n" +
+	"\apayload\x18\x05 \x01(\fR\apayload\"<\n" +
+	"\fStreamFilter\x12\x16\n" +
+	"\x06topics\x18\x01 \x03(\tR\x06topics\x12\x14\n" +
+	"\x05types\x18\x02 \x03(\tR\x05types\"\xca\x01\n" +
+	"\x05Event\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x14\n" +
+	"\x05topic\x18\x02 \x01(\tR\x05topic\x12\x12\n" +
+	"\x04type\x18\x03 \x01(\tR\x04type\x12\x16\n" +
+	"\x06source\x18\x04 \x01(\tR\x06source\x12*\n" +
+	"\x04data\x18\x05 \x01(\v2\x16.google.protobuf.ValueR\x04data\x12\x1c\n" +
+	"\ttimestamp\x18\x06 \x01(\x03R\ttimestamp\x12%\n" +
+	"\x0ecorrelation_id\x18\a \x01(\tR\rcorrelationId\"6\n" +
+	"\n" +
+	"AnyRequest\x12(\n" +
+	"\x04echo\x18\x01 \x01(\v2\x14.google.protobuf.AnyR\x04echo\"?\n" +
+	"\vAnyResponse\x120\n" +
+	"\bpayloads\x18\x01 \x03(\v2\x14.google.protobuf.AnyR\bpayloads\"0\n" +
+	"\x14UnknownFieldsRequest\x12\x18\n" +
+	"\amessage\x18\x01 \x01(\tR\amessage\"V\n" +
+	"\x06Nested\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x14\n" +
+	"\x05depth\x18\x02 \x01(\x05R\x05depth\x12\"\n" +
+	"\x05child\x18\x03 \x01(\v2\f.mock.NestedR\x05child\"\xe9\x0e\n" +
+	"\bAllTypes\x12!\n" +
+	"\fdouble_value\x18\x01 \x01(\x01R\vdoubleValue\x12\x1f\n" +
+	"\vfloat_value\x18\x02 \x01(\x02R\n" +
+	"floatValue\x12\x1f\n" +
+	"\vint32_value\x18\x03 \x01(\x05R\n" +
+	"int32Value\x12\x1f\n" +
+	"\vint64_value\x18\x04 \x01(\x03R\n" +
+	"int64Value\x12!\n" +
+	"\fuint32_value\x18\x05 \x01(\rR\vuint32Value\x12!\n" +
+	"\fuint64_value\x18\x06 \x01(\x04R\vuint64Value\x12!\n" +
+	"\fsint32_value\x18\a \x01(\x11R\vsint32Value\x12!\n" +
+	"\fsint64_value\x18\b \x01(\x12R\vsint64Value\x12#\n" +
+	"\rfixed32_value\x18\t \x01(\aR\ffixed32Value\x12#\n" +
+	"\rfixed64_value\x18\n" +
+	" \x01(\x06R\ffixed64Value\x12%\n" +
+	"\x0esfixed32_value\x18\v \x01(\x0fR\rsfixed32Value\x12%\n" +
+	"\x0esfixed64_value\x18\f \x01(\x10R\rsfixed64Value\x12\x1d\n" +
+	"\n" +
+	"bool_value\x18\r \x01(\bR\tboolValue\x12!\n" +
+	"\fstring_value\x18\x0e \x01(\tR\vstringValue\x12\x1f\n" +
+	"\vbytes_value\x18\x0f \x01(\fR\n" +
+	"bytesValue\x12*\n" +
+	"\x0eoptional_int32\x18\x10 \x01(\x05H\x01R\roptionalInt32\x88\x01\x01\x12,\n" +
+	"\x0foptional_string\x18\x11 \x01(\tH\x02R\x0eoptionalString\x88\x01\x01\x12!\n" +
+	"\x05color\x18\x12 \x01(\x0e2\v.mock.ColorR\x05color\x12$\n" +
+	"\x06nested\x18\x13 \x01(\v2\f.mock.NestedR\x06nested\x12%\n" +
+	"\x0erepeated_int32\x18\x14 \x03(\x05R\rrepeatedInt32\x12'\n" +
+	"\x0frepeated_double\x18\x15 \x03(\x01R\x0erepeatedDouble\x12'\n" +
+	"\x0frepeated_string\x18\x16 \x03(\tR\x0erepeatedString\x12%\n" +
+	"\x0erepeated_bytes\x18\x17 \x03(\fR\rrepeatedBytes\x122\n" +
+	"\x0erepeated_color\x18\x18 \x03(\x0e2\v.mock.ColorR\rrepeatedColor\x125\n" +
+	"\x0frepeated_nested\x18\x19 \x03(\v2\f.mock.NestedR\x0erepeatedNested\x12<\n" +
+	"\n" +
+	"string_map\x18\x1a \x03(\v2\x1d.mock.AllTypes.StringMapEntryR\tstringMap\x123\n" +
+	"\aint_map\x18\x1b \x03(\v2\x1a.mock.AllTypes.IntMapEntryR\x06intMap\x126\n" +
+	"\bbool_map\x18\x1c \x03(\v2\x1b.mock.AllTypes.BoolMapEntryR\aboolMap\x126\n" +
+	"\benum_map\x18\x1d \x03(\v2\x1b.mock.AllTypes.EnumMapEntryR\aenumMap\x12%\n" +
+	"\rchoice_string\x18\x1e \x01(\tH\x00R\fchoiceString\x12\x1f\n" +
+	"\n" +
+	"choice_int\x18\x1f \x01(\x03H\x00R\tchoiceInt\x123\n" +
+	"\rchoice_nested\x18  \x01(\v2\f.mock.NestedH\x00R\fchoiceNested\x120\n" +
+	"\fchoice_color\x18! \x01(\x0e2\v.mock.ColorH\x00R\vchoiceColor\x128\n" +
+	"\ttimestamp\x18\" \x01(\v2\x1a.google.protobuf.TimestampR\ttimestamp\x125\n" +
+	"\bduration\x18# \x01(\v2\x19.google.protobuf.DurationR\bduration\x12&\n" +
+	"\x03any\x18$ \x01(\v2\x14.google.protobuf.AnyR\x03any\x12:\n" +
+	"\fstruct_value\x18% \x01(\v2\x17.google.protobuf.StructR\vstructValue\x1a<\n" +
+	"\x0eStringMapEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\x1aG\n" +
+	"\vIntMapEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\x03R\x03key\x12\"\n" +
+	"\x05value\x18\x02 \x01(\v2\f.mock.NestedR\x05value:\x028\x01\x1a:\n" +
+	"\fBoolMapEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\bR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\fR\x05value:\x028\x01\x1aG\n" +
+	"\fEnumMapEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\rR\x03key\x12!\n" +
+	"\x05value\x18\x02 \x01(\x0e2\v.mock.ColorR\x05value:\x028\x01B\b\n" +
+	"\x06choiceB\x11\n" +
+	"\x0f_optional_int32B\x12\n" +
+	"\x10_optional_string\"\\\n" +
+	"\x10OversizedRequest\x12\x1f\n" +
+	"\vlimit_bytes\x18\x01 \x01(\x03R\n" +
+	"limitBytes\x12'\n" +
+	"\x0fovershoot_bytes\x18\x02 \x01(\x03R\x0eovershootBytes\"L\n" +
+	"\x11OversizedResponse\x12\x1d\n" +
+	"\n" +
+	"size_bytes\x18\x01 \x01(\x03R\tsizeBytes\x12\x18\n" +
+	"\apayload\x18\x02 \x01(\fR\apayload\"]\n" +
+	"\x0ePayloadRequest\x12\x1d\n" +
+	"\n" +
+	"size_bytes\x18\x01 \x01(\x03R\tsizeBytes\x12\x12\n" +
+	"\x04fill\x18\x02 \x01(\tR\x04fill\x12\x18\n" +
+	"\apayload\x18\x03 \x01(\fR\apayload\"\xb2\x01\n" +
+	"\x0fPayloadResponse\x12\x18\n" +
+	"\apayload\x18\x01 \x01(\fR\apayload\x12\x16\n" +
+	"\x06sha256\x18\x02 \x01(\tR\x06sha256\x12\x1d\n" +
+	"\n" +
+	"size_bytes\x18\x03 \x01(\x03R\tsizeBytes\x12%\n" +
+	"\x0ereceived_bytes\x18\x04 \x01(\x03R\rreceivedBytes\x12'\n" +
+	"\x0freceived_sha256\x18\x05 \x01(\tR\x0ereceivedSha256\"\\\n" +
+	"\x0fDeadlineRequest\x12\x1d\n" +
+	"\n" +
+	"overrun_ms\x18\x01 \x01(\x03R\toverrunMs\x12*\n" +
+	"\x11check_interval_ms\x18\x02 \x01(\x03R\x0fcheckIntervalMs\"\x86\x01\n" +
+	"\x10DeadlineResponse\x12!\n" +
+	"\fdeadline_set\x18\x01 \x01(\bR\vdeadlineSet\x122\n" +
+	"\x15deadline_remaining_ms\x18\x02 \x01(\x03R\x13deadlineRemainingMs\x12\x1b\n" +
+	"\tworked_ms\x18\x03 \x01(\x03R\bworkedMs*k\n" +
+	"\x05Color\x12\x15\n" +
+	"\x11COLOR_UNSPECIFIED\x10\x00\x12\r\n" +
+	"\tCOLOR_RED\x10\x01\x12\x0f\n" +
+	"\vCOLOR_GREEN\x10\x02\x12\x0e\n" +
+	"\n" +
+	"COLOR_BLUE\x10\x03\x12\x1b\n" +
+	"\x0eCOLOR_NEGATIVE\x10\xff\xff\xff\xff\xff\xff\xff\xff\xff\x012\xf9\x05\n" +
+	"\vMockService\x121\n" +
+	"\x04Echo\x12\x13.mock.SimpleRequest\x1a\x14.mock.SimpleResponse\x12;\n" +
+	"\fServerStream\x12\x13.mock.StreamRequest\x1a\x14.mock.StreamResponse0\x01\x12;\n" +
+	"\fClientStream\x12\x13.mock.StreamRequest\x1a\x14.mock.SimpleResponse(\x01\x12;\n" +
+	"\n" +
+	"BidiStream\x12\x13.mock.StreamRequest\x1a\x14.mock.StreamResponse(\x010\x01\x12.\n" +
+	"\tSubscribe\x12\x12.mock.StreamFilter\x1a\v.mock.Event0\x01\x122\n" +
+	"\vAnyPayloads\x12\x10.mock.AnyRequest\x1a\x11.mock.AnyResponse\x12A\n" +
+	"\rUnknownFields\x12\x1a.mock.UnknownFieldsRequest\x1a\x14.mock.SimpleResponse\x12.\n" +
+	"\fEchoAllTypes\x12\x0e.mock.AllTypes\x1a\x0e.mock.AllTypes\x128\n" +
+	"\x12EchoAllTypesStream\x12\x0e.mock.AllTypes\x1a\x0e.mock.AllTypes(\x010\x01\x128\n" +
+	"\x0eSampleAllTypes\x12\x16.google.protobuf.Empty\x1a\x0e.mock.AllTypes\x12<\n" +
+	"\tOversized\x12\x16.mock.OversizedRequest\x1a\x17.mock.OversizedResponse\x126\n" +
+	"\aPayload\x12\x14.mock.PayloadRequest\x1a\x15.mock.PayloadResponse\x12?\n" +
+	"\x0eExceedDeadline\x12\x15.mock.DeadlineRequest\x1a\x16.mock.DeadlineResponseB\x12Z\x10mockserver/protob\x06proto3"
+
+var (
+	file_proto_mock_proto_rawDescOnce sync.Once
+	file_proto_mock_proto_rawDescData []byte
+)
+
+func file_proto_mock_proto_rawDescGZIP() []byte {
+	file_proto_mock_proto_rawDescOnce.Do(func() {
+		file_proto_mock_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_proto_mock_proto_rawDesc), len(file_proto_mock_proto_rawDesc)))
+	})
+	return file_proto_mock_proto_rawDescData
+}
+
+var file_proto_mock_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_proto_mock_proto_msgTypes = make([]protoimpl.MessageInfo, 21)
+var file_proto_mock_proto_goTypes = []any{
+	(Color)(0),                    // 0: mock.Color
+	(*SimpleRequest)(nil),         // 1: mock.SimpleRequest
+	(*SimpleResponse)(nil),        // 2: mock.SimpleResponse
+	(*StreamRequest)(nil),         // 3: mock.StreamRequest
+	(*StreamResponse)(nil),        // 4: mock.StreamResponse
+	(*StreamFilter)(nil),          // 5: mock.StreamFilter
+	(*Event)(nil),                 // 6: mock.Event
+	(*AnyRequest)(nil),            // 7: mock.AnyRequest
+	(*AnyResponse)(nil),           // 8: mock.AnyResponse
+	(*UnknownFieldsRequest)(nil),  // 9: mock.UnknownFieldsRequest
+	(*Nested)(nil),                // 10: mock.Nested
+	(*AllTypes)(nil),              // 11: mock.AllTypes
+	(*OversizedRequest)(nil),      // 12: mock.OversizedRequest
+	(*OversizedResponse)(nil),     // 13: mock.OversizedResponse
+	(*PayloadRequest)(nil),        // 14: mock.PayloadRequest
+	(*PayloadResponse)(nil),       // 15: mock.PayloadResponse
+	(*DeadlineRequest)(nil),       // 16: mock.DeadlineRequest
+	(*DeadlineResponse)(nil),      // 17: mock.DeadlineResponse
+	nil,                           // 18: mock.AllTypes.StringMapEntry
+	nil,                           // 19: mock.AllTypes.IntMapEntry
+	nil,                           // 20: mock.AllTypes.BoolMapEntry
+	nil,                           // 21: mock.AllTypes.EnumMapEntry
+	(*structpb.Value)(nil),        // 22: google.protobuf.Value
+	(*anypb.Any)(nil),             // 23: google.protobuf.Any
+	(*timestamppb.Timestamp)(nil), // 24: google.protobuf.Timestamp
+	(*durationpb.Duration)(nil),   // 25: google.protobuf.Duration
+	(*structpb.Struct)(nil),       // 26: google.protobuf.Struct
+	(*emptypb.Empty)(nil),         // 27: google.protobuf.Empty
+}
+var file_proto_mock_proto_depIdxs = []int32{
+	22, // 0: mock.Event.data:type_name -> google.protobuf.Value
+	23, // 1: mock.AnyRequest.echo:type_name -> google.protobuf.Any
+	23, // 2: mock.AnyResponse.payloads:type_name -> google.protobuf.Any
+	10, // 3: mock.Nested.child:type_name -> mock.Nested
+	0,  // 4: mock.AllTypes.color:type_name -> mock.Color
+	10, // 5: mock.AllTypes.nested:type_name -> mock.Nested
+	0,  // 6: mock.AllTypes.repeated_color:type_name -> mock.Color
+	10, // 7: mock.AllTypes.repeated_nested:type_name -> mock.Nested
+	18, // 8: mock.AllTypes.string_map:type_name -> mock.AllTypes.StringMapEntry
+	19, // 9: mock.AllTypes.int_map:type_name -> mock.AllTypes.IntMapEntry
+	20, // 10: mock.AllTypes.bool_map:type_name -> mock.AllTypes.BoolMapEntry
+	21, // 11: mock.AllTypes.enum_map:type_name -> mock.AllTypes.EnumMapEntry
+	10, // 12: mock.AllTypes.choice_nested:type_name -> mock.Nested
+	0,  // 13: mock.AllTypes.choice_color:type_name -> mock.Color
+	24, // 14: mock.AllTypes.timestamp:type_name -> google.protobuf.Timestamp
+	25, // 15: mock.AllTypes.duration:type_name -> google.protobuf.Duration
+	23, // 16: mock.AllTypes.any:type_name -> google.protobuf.Any
+	26, // 17: mock.AllTypes.struct_value:type_name -> google.protobuf.Struct
+	10, // 18: mock.AllTypes.IntMapEntry.value:type_name -> mock.Nested
+	0,  // 19: mock.AllTypes.EnumMapEntry.value:type_name -> mock.Color
+	1,  // 20: mock.MockService.Echo:input_type -> mock.SimpleRequest
+	3,  // 21: mock.MockService.ServerStream:input_type -> mock.StreamRequest
+	3,  // 22: mock.MockService.ClientStream:input_type -> mock.StreamRequest
+	3,  // 23: mock.MockService.BidiStream:input_type -> mock.StreamRequest
+	5,  // 24: mock.MockService.Subscribe:input_type -> mock.StreamFilter
+	7,  // 25: mock.MockService.AnyPayloads:input_type -> mock.AnyRequest
+	9,  // 26: mock.MockService.UnknownFields:input_type -> mock.UnknownFieldsRequest
+	11, // 27: mock.MockService.EchoAllTypes:input_type -> mock.AllTypes
+	11, // 28: mock.MockService.EchoAllTypesStream:input_type -> mock.AllTypes
+	27, // 29: mock.MockService.SampleAllTypes:input_type -> google.protobuf.Empty
+	12, // 30: mock.MockService.Oversized:input_type -> mock.OversizedRequest
+	14, // 31: mock.MockService.Payload:input_type -> mock.PayloadRequest
+	16, // 32: mock.MockService.ExceedDeadline:input_type -> mock.DeadlineRequest
+	2,  // 33: mock.MockService.Echo:output_type -> mock.SimpleResponse
+	4,  // 34: mock.MockService.ServerStream:output_type -> mock.StreamResponse
+	2,  // 35: mock.MockService.ClientStream:output_type -> mock.SimpleResponse
+	4,  // 36: mock.MockService.BidiStream:output_type -> mock.StreamResponse
+	6,  // 37: mock.MockService.Subscribe:output_type -> mock.Event
+	8,  // 38: mock.MockService.AnyPayloads:output_type -> mock.AnyResponse
+	2,  // 39: mock.MockService.UnknownFields:output_type -> mock.SimpleResponse
+	11, // 40: mock.MockService.EchoAllTypes:output_type -> mock.AllTypes
+	11, // 41: mock.MockService.EchoAllTypesStream:output_type -> mock.AllTypes
+	11, // 42: mock.MockService.SampleAllTypes:output_type -> mock.AllTypes
+	13, // 43: mock.MockService.Oversized:output_type -> mock.OversizedResponse
+	15, // 44: mock.MockService.Payload:output_type -> mock.PayloadResponse
+	17, // 45: mock.MockService.ExceedDeadline:output_type -> mock.DeadlineResponse
+	33, // [33:46] is the sub-list for method output_type
+	20, // [20:33] is the sub-list for method input_type
+	20, // [20:20] is the sub-list for extension type_name
+	20, // [20:20] is the sub-list for extension extendee
+	0,  // [0:20] is the sub-list for field type_name
+}
+
+func init() { file_proto_mock_proto_init() }
+func file_proto_mock_proto_init() {
+	if File_proto_mock_proto != nil {
+		return
+	}
+	file_proto_mock_proto_msgTypes[2].OneofWrappers = []any{}
+	file_proto_mock_proto_msgTypes[10].OneofWrappers = []any{
+		(*AllTypes_ChoiceString)(nil),
+		(*AllTypes_ChoiceInt)(nil),
+		(*AllTypes_ChoiceNested)(nil),
+		(*AllTypes_ChoiceColor)(nil),
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_mock_proto_rawDesc), len(file_proto_mock_proto_rawDesc)),
-			NumEnums:      0,
-			NumMessages:   4,
+			NumEnums:      1,
+			NumMessages:   21,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
 		GoTypes:           file_proto_mock_proto_goTypes,
 		DependencyIndexes: file_proto_mock_proto_depIdxs,
+		EnumInfos:         file_proto_mock_proto_enumTypes,
 		MessageInfos:      file_proto_mock_proto_msgTypes,
 	}.Build()
 	File_proto_mock_proto = out.File
@@ -11,6 +11,7 @@ import (
 	grpc "google.golang.org/grpc"
 	codes "google.golang.org/grpc/codes"
 	status "google.golang.org/grpc/status"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
 )
 
 // This is a compile-time assertion to ensure that this generated file
@@ -19,10 +20,19 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	MockService_Echo_FullMethodName         = "/mock.MockService/Echo"
-	MockService_ServerStream_FullMethodName = "/mock.MockService/ServerStream"
-	MockService_ClientStream_FullMethodName = "/mock.MockService/ClientStream"
-	MockService_BidiStream_FullMethodName   = "/mock.MockService/BidiStream"
+	MockService_Echo_FullMethodName               = "/mock.MockService/Echo"
+	MockService_ServerStream_FullMethodName       = "/mock.MockService/ServerStream"
+	MockService_ClientStream_FullMethodName       = "/mock.MockService/ClientStream"
+	MockService_BidiStream_FullMethodName         = "/mock.MockService/BidiStream"
+	MockService_Subscribe_FullMethodName          = "/mock.MockService/Subscribe"
+	MockService_AnyPayloads_FullMethodName        = "/mock.MockService/AnyPayloads"
+	MockService_UnknownFields_FullMethodName      = "/mock.MockService/UnknownFields"
+	MockService_EchoAllTypes_FullMethodName       = "/mock.MockService/EchoAllTypes"
+	MockService_EchoAllTypesStream_FullMethodName = "/mock.MockService/EchoAllTypesStream"
+	MockService_SampleAllTypes_FullMethodName     = "/mock.MockService/SampleAllTypes"
+	MockService_Oversized_FullMethodName          = "/mock.MockService/Oversized"
+	MockService_Payload_FullMethodName            = "/mock.MockService/Payload"
+	MockService_ExceedDeadline_FullMethodName     = "/mock.MockService/ExceedDeadline"
 )
 
 // MockServiceClient is the client API for MockService service.
@@ -39,6 +49,29 @@ type MockServiceClient interface {
 	ClientStream(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[StreamRequest, SimpleResponse], error)
 	// Bidirectional streaming RPC
 	BidiStream(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[StreamRequest, StreamResponse], error)
+	// Streams mock events (WebSocket traffic, admin pushes) matching the filter
+	Subscribe(ctx context.Context, in *StreamFilter, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Event], error)
+	// Returns Any payloads of well-known, MockService and unregistered types
+	AnyPayloads(ctx context.Context, in *AnyRequest, opts ...grpc.CallOption) (*AnyResponse, error)
+	// Returns a SimpleResponse carrying fields the client does not know about,
+	// plus any unknown fields sent in the request
+	UnknownFields(ctx context.Context, in *UnknownFieldsRequest, opts ...grpc.CallOption) (*SimpleResponse, error)
+	// Returns the AllTypes message unchanged
+	EchoAllTypes(ctx context.Context, in *AllTypes, opts ...grpc.CallOption) (*AllTypes, error)
+	// Echoes every AllTypes message received on the stream
+	EchoAllTypesStream(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[AllTypes, AllTypes], error)
+	// Returns AllTypes populated with edge-case values (extremes, NaN,
+	// non-ASCII text, empty and nested values)
+	SampleAllTypes(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*AllTypes, error)
+	// Returns a response just over the client's message size limit, to test
+	// how clients and proxies handle RESOURCE_EXHAUSTED
+	Oversized(ctx context.Context, in *OversizedRequest, opts ...grpc.CallOption) (*OversizedResponse, error)
+	// Returns size_bytes of payload with its SHA-256, and checksums any uploaded
+	// payload, for throughput and message size testing
+	Payload(ctx context.Context, in *PayloadRequest, opts ...grpc.CallOption) (*PayloadResponse, error)
+	// Keeps working past the client's deadline, checking for cancellation and
+	// logging when (and why) the call was abandoned
+	ExceedDeadline(ctx context.Context, in *DeadlineRequest, opts ...grpc.CallOption) (*DeadlineResponse, error)
 }
 
 type mockServiceClient struct {
@@ -104,6 +137,108 @@ func (c *mockServiceClient) BidiStream(ctx context.Context, opts ...grpc.CallOpt
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type MockService_BidiStreamClient = grpc.BidiStreamingClient[StreamRequest, StreamResponse]
 
+func (c *mockServiceClient) Subscribe(ctx context.Context, in *StreamFilter, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Event], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &MockService_ServiceDesc.Streams[3], MockService_Subscribe_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StreamFilter, Event]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type MockService_SubscribeClient = grpc.ServerStreamingClient[Event]
+
+func (c *mockServiceClient) AnyPayloads(ctx context.Context, in *AnyRequest, opts ...grpc.CallOption) (*AnyResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AnyResponse)
+	err := c.cc.Invoke(ctx, MockService_AnyPayloads_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *mockServiceClient) UnknownFields(ctx context.Context, in *UnknownFieldsRequest, opts ...grpc.CallOption) (*SimpleResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SimpleResponse)
+	err := c.cc.Invoke(ctx, MockService_UnknownFields_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *mockServiceClient) EchoAllTypes(ctx context.Context, in *AllTypes, opts ...grpc.CallOption) (*AllTypes, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AllTypes)
+	err := c.cc.Invoke(ctx, MockService_EchoAllTypes_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *mockServiceClient) EchoAllTypesStream(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[AllTypes, AllTypes], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &MockService_ServiceDesc.Streams[4], MockService_EchoAllTypesStream_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[AllTypes, AllTypes]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type MockService_EchoAllTypesStreamClient = grpc.BidiStreamingClient[AllTypes, AllTypes]
+
+func (c *mockServiceClient) SampleAllTypes(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*AllTypes, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AllTypes)
+	err := c.cc.Invoke(ctx, MockService_SampleAllTypes_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *mockServiceClient) Oversized(ctx context.Context, in *OversizedRequest, opts ...grpc.CallOption) (*OversizedResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(OversizedResponse)
+	err := c.cc.Invoke(ctx, MockService_Oversized_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *mockServiceClient) Payload(ctx context.Context, in *PayloadRequest, opts ...grpc.CallOption) (*PayloadResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PayloadResponse)
+	err := c.cc.Invoke(ctx, MockService_Payload_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *mockServiceClient) ExceedDeadline(ctx context.Context, in *DeadlineRequest, opts ...grpc.CallOption) (*DeadlineResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeadlineResponse)
+	err := c.cc.Invoke(ctx, MockService_ExceedDeadline_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // MockServiceServer is the server API for MockService service.
 // All implementations must embed UnimplementedMockServiceServer
 // for forward compatibility.
@@ -118,6 +253,29 @@ type MockServiceServer interface {
 	ClientStream(grpc.ClientStreamingServer[StreamRequest, SimpleResponse]) error
 	// Bidirectional streaming RPC
 	BidiStream(grpc.BidiStreamingServer[StreamRequest, StreamResponse]) error
+	// Streams mock events (WebSocket traffic, admin pushes) matching the filter
+	Subscribe(*StreamFilter, grpc.ServerStreamingServer[Event]) error
+	// Returns Any payloads of well-known, MockService and unregistered types
+	AnyPayloads(context.Context, *AnyRequest) (*AnyResponse, error)
+	// Returns a SimpleResponse carrying fields the client does not know about,
+	// plus any unknown fields sent in the request
+	UnknownFields(context.Context, *UnknownFieldsRequest) (*SimpleResponse, error)
+	// Returns the AllTypes message unchanged
+	EchoAllTypes(context.Context, *AllTypes) (*AllTypes, error)
+	// Echoes every AllTypes message received on the stream
+	EchoAllTypesStream(grpc.BidiStreamingServer[AllTypes, AllTypes]) error
+	// Returns AllTypes populated with edge-case values (extremes, NaN,
+	// non-ASCII text, empty and nested values)
+	SampleAllTypes(context.Context, *emptypb.Empty) (*AllTypes, error)
+	// Returns a response just over the client's message size limit, to test
+	// how clients and proxies handle RESOURCE_EXHAUSTED
+	Oversized(context.Context, *OversizedRequest) (*OversizedResponse, error)
+	// Returns size_bytes of payload with its SHA-256, and checksums any uploaded
+	// payload, for throughput and message size testing
+	Payload(context.Context, *PayloadRequest) (*PayloadResponse, error)
+	// Keeps working past the client's deadline, checking for cancellation and
+	// logging when (and why) the call was abandoned
+	ExceedDeadline(context.Context, *DeadlineRequest) (*DeadlineResponse, error)
 	mustEmbedUnimplementedMockServiceServer()
 }
 
@@ -140,6 +298,33 @@ func (UnimplementedMockServiceServer) ClientStream(grpc.ClientStreamingServer[St
 func (UnimplementedMockServiceServer) BidiStream(grpc.BidiStreamingServer[StreamRequest, StreamResponse]) error {
 	return status.Errorf(codes.Unimplemented, "method BidiStream not implemented")
 }
+func (UnimplementedMockServiceServer) Subscribe(*StreamFilter, grpc.ServerStreamingServer[Event]) error {
+	return status.Errorf(codes.Unimplemented, "method Subscribe not implemented")
+}
+func (UnimplementedMockServiceServer) AnyPayloads(context.Context, *AnyRequest) (*AnyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AnyPayloads not implemented")
+}
+func (UnimplementedMockServiceServer) UnknownFields(context.Context, *UnknownFieldsRequest) (*SimpleResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UnknownFields not implemented")
+}
+func (UnimplementedMockServiceServer) EchoAllTypes(context.Context, *AllTypes) (*AllTypes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method EchoAllTypes not implemented")
+}
+func (UnimplementedMockServiceServer) EchoAllTypesStream(grpc.BidiStreamingServer[AllTypes, AllTypes]) error {
+	return status.Errorf(codes.Unimplemented, "method EchoAllTypesStream not implemented")
+}
+func (UnimplementedMockServiceServer) SampleAllTypes(context.Context, *emptypb.Empty) (*AllTypes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SampleAllTypes not implemented")
+}
+func (UnimplementedMockServiceServer) Oversized(context.Context, *OversizedRequest) (*OversizedResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Oversized not implemented")
+}
+func (UnimplementedMockServiceServer) Payload(context.Context, *PayloadRequest) (*PayloadResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Payload not implemented")
+}
+func (UnimplementedMockServiceServer) ExceedDeadline(context.Context, *DeadlineRequest) (*DeadlineResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ExceedDeadline not implemented")
+}
 func (UnimplementedMockServiceServer) mustEmbedUnimplementedMockServiceServer() {}
 func (UnimplementedMockServiceServer) testEmbeddedByValue()                     {}
 
@@ -204,6 +389,150 @@ func _MockService_BidiStream_Handler(srv interface{}, stream grpc.ServerStream)
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type MockService_BidiStreamServer = grpc.BidiStreamingServer[StreamRequest, StreamResponse]
 
+func _MockService_Subscribe_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamFilter)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(MockServiceServer).Subscribe(m, &grpc.GenericServerStream[StreamFilter, Event]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type MockService_SubscribeServer = grpc.ServerStreamingServer[Event]
+
+func _MockService_AnyPayloads_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AnyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MockServiceServer).AnyPayloads(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MockService_AnyPayloads_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MockServiceServer).AnyPayloads(ctx, req.(*AnyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MockService_UnknownFields_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UnknownFieldsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MockServiceServer).UnknownFields(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MockService_UnknownFields_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MockServiceServer).UnknownFields(ctx, req.(*UnknownFieldsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MockService_EchoAllTypes_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AllTypes)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MockServiceServer).EchoAllTypes(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MockService_EchoAllTypes_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MockServiceServer).EchoAllTypes(ctx, req.(*AllTypes))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MockService_EchoAllTypesStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(MockServiceServer).EchoAllTypesStream(&grpc.GenericServerStream[AllTypes, AllTypes]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type MockService_EchoAllTypesStreamServer = grpc.BidiStreamingServer[AllTypes, AllTypes]
+
+func _MockService_SampleAllTypes_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MockServiceServer).SampleAllTypes(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MockService_SampleAllTypes_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MockServiceServer).SampleAllTypes(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MockService_Oversized_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(OversizedRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MockServiceServer).Oversized(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MockService_Oversized_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MockServiceServer).Oversized(ctx, req.(*OversizedRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MockService_Payload_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PayloadRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MockServiceServer).Payload(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MockService_Payload_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MockServiceServer).Payload(ctx, req.(*PayloadRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MockService_ExceedDeadline_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeadlineRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MockServiceServer).ExceedDeadline(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MockService_ExceedDeadline_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MockServiceServer).ExceedDeadline(ctx, req.(*DeadlineRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // MockService_ServiceDesc is the grpc.ServiceDesc for MockService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -215,6 +544,34 @@ var MockService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Echo",
 			Handler:    _MockService_Echo_Handler,
 		},
+		{
+			MethodName: "AnyPayloads",
+			Handler:    _MockService_AnyPayloads_Handler,
+		},
+		{
+			MethodName: "UnknownFields",
+			Handler:    _MockService_UnknownFields_Handler,
+		},
+		{
+			MethodName: "EchoAllTypes",
+			Handler:    _MockService_EchoAllTypes_Handler,
+		},
+		{
+			MethodName: "SampleAllTypes",
+			Handler:    _MockService_SampleAllTypes_Handler,
+		},
+		{
+			MethodName: "Oversized",
+			Handler:    _MockService_Oversized_Handler,
+		},
+		{
+			MethodName: "Payload",
+			Handler:    _MockService_Payload_Handler,
+		},
+		{
+			MethodName: "ExceedDeadline",
+			Handler:    _MockService_ExceedDeadline_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -233,6 +590,17 @@ var MockService_ServiceDesc = grpc.ServiceDesc{
 			ServerStreams: true,
 			ClientStreams: true,
 		},
+		{
+			StreamName:    "Subscribe",
+			Handler:       _MockService_Subscribe_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "EchoAllTypesStream",
+			Handler:       _MockService_EchoAllTypesStream_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
 	},
 	Metadata: "proto/mock.proto",
 }